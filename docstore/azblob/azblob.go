@@ -7,6 +7,7 @@ import (
 	"crypto/rsa"
 	"crypto/x509"
 	"fmt"
+	"net/http"
 	"net/url"
 	"os"
 	"path/filepath"
@@ -24,6 +25,9 @@ const (
 )
 
 var _ docstore.DocStore = &Store{}
+var _ docstore.TTLPutter = &Store{}
+var _ docstore.Pinger = &Store{}
+var _ docstore.ConditionalStore = &Store{}
 
 func decodePkcs12(pkcs []byte, password string) (*x509.Certificate, *rsa.PrivateKey, error) {
 	privateKey, certificate, err := pkcs12.Decode(pkcs, password)
@@ -132,16 +136,18 @@ type Store struct {
 	containerURL azblob.ContainerURL
 }
 
-func getBufFromBlob(ctx context.Context, blobURL azblob.BlockBlobURL) ([]byte, error) {
+func getBufFromBlob(ctx context.Context, blobURL azblob.BlockBlobURL) ([]byte, azblob.ETag, error) {
 	_, err := blobURL.GetProperties(ctx, azblob.BlobAccessConditions{}, azblob.ClientProvidedKeyOptions{})
 	if err != nil {
 		serr, ok := err.(azblob.StorageError)
 		if ok && serr.Response().StatusCode == 404 {
-			return nil, docstore.ErrRequestNotFound
+			return nil, "", docstore.ErrRequestNotFound
 		}
-		return nil, err
+		return nil, "", err
 	}
 
+	// The entity tag of the download response matches the returned bytes,
+	// even if the blob was written since its properties were read.
 	downloadResponse, err := blobURL.Download(ctx,
 		0,
 		azblob.CountToEnd,
@@ -150,7 +156,7 @@ func getBufFromBlob(ctx context.Context, blobURL azblob.BlockBlobURL) ([]byte, e
 		azblob.ClientProvidedKeyOptions{})
 
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 
 	bodyStream := downloadResponse.Body(azblob.RetryReaderOptions{MaxRetryRequests: 3})
@@ -158,32 +164,38 @@ func getBufFromBlob(ctx context.Context, blobURL azblob.BlockBlobURL) ([]byte, e
 	downloadedData := bytes.Buffer{}
 	_, err = downloadedData.ReadFrom(bodyStream)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 
-	return downloadedData.Bytes(), nil
+	return downloadedData.Bytes(), downloadResponse.ETag(), nil
 }
 
 // Get reads bytes from azure blob.
 func (s *Store) Get(ctx context.Context, key string) ([]byte, error) {
+	b, _, err := s.GetWithETag(ctx, key)
+	return b, err
+}
+
+// GetWithETag reads bytes from azure blob and the blob's ETag.
+func (s *Store) GetWithETag(ctx context.Context, key string) ([]byte, string, error) {
 	err := docstore.ValidKey(key)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 	blobURL := s.containerURL.NewBlockBlobURL(fmt.Sprintf("%s/%s", s.prefix, key))
-	b, err := getBufFromBlob(ctx, blobURL)
+	b, etag, err := getBufFromBlob(ctx, blobURL)
 	if err != nil {
-		return nil, fmt.Errorf("az get: %w", err)
+		return nil, "", fmt.Errorf("az get: %w", err)
 	}
 
-	return b, nil
+	return b, string(etag), nil
 }
 
-func putBufToBlob(ctx context.Context, blobURL azblob.BlockBlobURL, blob []byte) error {
+func putBufToBlob(ctx context.Context, blobURL azblob.BlockBlobURL, blob []byte, metadata azblob.Metadata) error {
 	_, err := azblob.UploadStreamToBlockBlob(ctx,
 		bytes.NewReader(blob),
 		blobURL,
-		azblob.UploadStreamToBlockBlobOptions{})
+		azblob.UploadStreamToBlockBlobOptions{Metadata: metadata})
 	if err != nil {
 		return err
 	}
@@ -193,13 +205,27 @@ func putBufToBlob(ctx context.Context, blobURL azblob.BlockBlobURL, blob []byte)
 
 // Put writes bytes to azure blob.
 func (s *Store) Put(ctx context.Context, key string, body []byte) error {
+	return s.put(ctx, key, body, nil)
+}
+
+// PutWithTTL writes bytes to azure blob with metadata recording its expiry,
+// for consumption by lifecycle management.
+func (s *Store) PutWithTTL(ctx context.Context, key string, body []byte, ttl time.Duration) error {
+	tags, err := docstore.TTLTags(time.Now(), ttl)
+	if err != nil {
+		return err
+	}
+	return s.put(ctx, key, body, azblob.Metadata(tags))
+}
+
+func (s *Store) put(ctx context.Context, key string, body []byte, metadata azblob.Metadata) error {
 	err := docstore.ValidKey(key)
 	if err != nil {
 		return err
 	}
 
 	blobURL := s.containerURL.NewBlockBlobURL(fmt.Sprintf("%s/%s", s.prefix, key))
-	err = putBufToBlob(ctx, blobURL, body)
+	err = putBufToBlob(ctx, blobURL, body, metadata)
 	if err != nil {
 		return fmt.Errorf("az put: %w", err)
 	}
@@ -207,6 +233,51 @@ func (s *Store) Put(ctx context.Context, key string, body []byte) error {
 	return nil
 }
 
+// PutIf writes bytes to azure blob if cond holds, using the blob's ETag.
+func (s *Store) PutIf(ctx context.Context, key string, body []byte, cond docstore.Precondition) (string, error) {
+	err := docstore.ValidKey(key)
+	if err != nil {
+		return "", err
+	}
+	if err := cond.Valid(); err != nil {
+		return "", err
+	}
+
+	blobURL := s.containerURL.NewBlockBlobURL(fmt.Sprintf("%s/%s", s.prefix, key))
+	ac := azblob.BlobAccessConditions{
+		ModifiedAccessConditions: azblob.ModifiedAccessConditions{
+			IfMatch:     azblob.ETag(cond.IfMatch),
+			IfNoneMatch: azblob.ETag(cond.IfNoneMatch),
+		},
+	}
+	// A single upload applies the access conditions atomically, unlike the
+	// staged blocks of Put.
+	resp, err := blobURL.Upload(ctx, bytes.NewReader(body), azblob.BlobHTTPHeaders{}, nil, ac,
+		azblob.DefaultAccessTier, nil, azblob.ClientProvidedKeyOptions{}, azblob.ImmutabilityPolicyOptions{})
+	if err != nil {
+		serr, ok := err.(azblob.StorageError)
+		if ok && preconditionStatus(cond, serr.Response().StatusCode) {
+			return "", docstore.ErrPreconditionFailed
+		}
+		return "", fmt.Errorf("az put: %w", err)
+	}
+
+	return string(resp.ETag()), nil
+}
+
+// preconditionStatus returns true for the statuses of failed conditional
+// writes: if-match fails with 412, or 404 if the blob is missing, and
+// if-none-match with 409.
+func preconditionStatus(cond docstore.Precondition, code int) bool {
+	switch code {
+	case http.StatusPreconditionFailed, http.StatusConflict:
+		return true
+	case http.StatusNotFound:
+		return cond.IfMatch != ""
+	}
+	return false
+}
+
 // Delete deletes bytes from azure blob.
 func (s *Store) Delete(ctx context.Context, key string) error {
 	err := docstore.ValidKey(key)
@@ -222,3 +293,13 @@ func (s *Store) Delete(ctx context.Context, key string) error {
 
 	return nil
 }
+
+// Ping checks the container exists and is accessible by getting its
+// properties.
+func (s *Store) Ping(ctx context.Context) error {
+	_, err := s.containerURL.GetProperties(ctx, azblob.LeaseAccessConditions{})
+	if err != nil {
+		return fmt.Errorf("az ping: %w", err)
+	}
+	return nil
+}
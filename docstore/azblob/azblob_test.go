@@ -73,15 +73,36 @@ func do(t *testing.T, store *Store) {
 	bg := context.Background()
 	ctx, done := context.WithTimeout(bg, reqTimeout)
 	defer done()
+	err = store.Ping(ctx)
+	require.NoError(t, err)
+
+	ctx, done = context.WithTimeout(bg, reqTimeout)
+	defer done()
 	err = store.Put(ctx, testKey, data)
 	require.NoError(t, err)
 
 	ctx, done = context.WithTimeout(bg, reqTimeout)
 	defer done()
-	b, err := store.Get(ctx, testKey)
+	b, etag, err := store.GetWithETag(ctx, testKey)
 	require.NoError(t, err)
 	require.Equal(t, b, data)
 
+	ctx, done = context.WithTimeout(bg, reqTimeout)
+	defer done()
+	_, err = store.PutIf(ctx, testKey, data, docstore.IfNotExists)
+	require.ErrorIs(t, err, docstore.ErrPreconditionFailed)
+
+	ctx, done = context.WithTimeout(bg, reqTimeout)
+	defer done()
+	newETag, err := store.PutIf(ctx, testKey, data, docstore.Precondition{IfMatch: etag})
+	require.NoError(t, err)
+	require.NotEqual(t, etag, newETag)
+
+	ctx, done = context.WithTimeout(bg, reqTimeout)
+	defer done()
+	_, err = store.PutIf(ctx, testKey, data, docstore.Precondition{IfMatch: etag})
+	require.ErrorIs(t, err, docstore.ErrPreconditionFailed)
+
 	ctx, done = context.WithTimeout(bg, reqTimeout)
 	defer done()
 	err = store.Delete(ctx, testKey)
@@ -0,0 +1,83 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package docstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrPreconditionFailed is returned by PutIf when the precondition of a
+// write does not hold.
+var ErrPreconditionFailed = errors.New("precondition failed")
+
+// Precondition conditions a write on the current state of a document, with
+// the semantics of the HTTP If-Match and If-None-Match headers.  At most one
+// field may be set; the zero Precondition always holds.
+type Precondition struct {
+	// IfMatch writes the document only if it exists and its entity tag is
+	// IfMatch.
+	IfMatch string
+	// IfNoneMatch "*" writes the document only if it does not exist.
+	IfNoneMatch string
+}
+
+// IfNotExists is the precondition of writes creating a document.
+var IfNotExists = Precondition{IfNoneMatch: "*"}
+
+// Valid returns an error if the precondition is not supported.
+func (p Precondition) Valid() error {
+	if p.IfMatch != "" && p.IfNoneMatch != "" {
+		return fmt.Errorf("precondition: both if-match and if-none-match set")
+	}
+	if p.IfNoneMatch != "" && p.IfNoneMatch != "*" {
+		return fmt.Errorf("precondition: unsupported if-none-match %q", p.IfNoneMatch)
+	}
+	return nil
+}
+
+// ConditionalStore reads and writes documents with optimistic concurrency.
+// Entity tags identify a version of a document; they are opaque and change
+// on every write.
+type ConditionalStore interface {
+	// GetWithETag retrieves the document and its entity tag.
+	GetWithETag(ctx context.Context, key string) ([]byte, string, error)
+	// PutIf stores the document if cond holds, returning its new entity
+	// tag, and ErrPreconditionFailed otherwise.
+	PutIf(ctx context.Context, key string, body []byte, cond Precondition) (string, error)
+}
+
+// maxUpdateAttempts bounds the retries of Update on concurrent writes.
+const maxUpdateAttempts = 5
+
+// Update applies fn to the document at key and stores the result, retrying
+// when the document was written concurrently.  fn is called with a nil body
+// if the document does not exist, and may be called more than once.  The
+// write is conditioned on the version read, so concurrent writers, e.g.
+// oracle replicas sharing state, do not clobber each other's writes.
+func Update(ctx context.Context, store ConditionalStore, key string, fn func(body []byte) ([]byte, error)) error {
+	var err error
+	for attempt := 0; attempt < maxUpdateAttempts; attempt++ {
+		body, etag, getErr := store.GetWithETag(ctx, key)
+		cond := Precondition{IfMatch: etag}
+		switch {
+		case errors.Is(getErr, ErrRequestNotFound):
+			body, cond = nil, IfNotExists
+		case getErr != nil:
+			return fmt.Errorf("update get: %w", getErr)
+		}
+		body, err = fn(body)
+		if err != nil {
+			return err
+		}
+		_, err = store.PutIf(ctx, key, body, cond)
+		if !errors.Is(err, ErrPreconditionFailed) {
+			break
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("update put: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package docstore
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// versionStore is a ConditionalStore whose entity tags are versions.
+type versionStore struct {
+	docs     map[string][]byte
+	versions map[string]int
+	// race writes the document concurrently before the next n writes.
+	race int
+}
+
+func (m *versionStore) GetWithETag(ctx context.Context, key string) ([]byte, string, error) {
+	b, ok := m.docs[key]
+	if !ok {
+		return nil, "", ErrRequestNotFound
+	}
+	return b, strconv.Itoa(m.versions[key]), nil
+}
+
+func (m *versionStore) PutIf(ctx context.Context, key string, body []byte, cond Precondition) (string, error) {
+	if m.race > 0 {
+		m.race--
+		m.docs[key] = append(m.docs[key], 'x')
+		m.versions[key]++
+	}
+	_, exists := m.docs[key]
+	if (cond == IfNotExists && exists) || (cond.IfMatch != "" && cond.IfMatch != strconv.Itoa(m.versions[key])) {
+		return "", ErrPreconditionFailed
+	}
+	m.docs[key] = body
+	m.versions[key]++
+	return strconv.Itoa(m.versions[key]), nil
+}
+
+func TestUpdate(t *testing.T) {
+	ctx := context.Background()
+	store := &versionStore{docs: map[string][]byte{}, versions: map[string]int{}}
+	appendA := func(body []byte) ([]byte, error) {
+		return append(body, 'a'), nil
+	}
+	require.NoError(t, Update(ctx, store, "k", appendA))
+	require.Equal(t, "a", string(store.docs["k"]))
+
+	// Concurrent writes are not clobbered.
+	store.race = 2
+	require.NoError(t, Update(ctx, store, "k", appendA))
+	require.Equal(t, "axxa", string(store.docs["k"]))
+
+	store.race = maxUpdateAttempts
+	require.ErrorIs(t, Update(ctx, store, "k", appendA), ErrPreconditionFailed)
+
+	errFail := errors.New("fail")
+	require.ErrorIs(t, Update(ctx, store, "k", func([]byte) ([]byte, error) { return nil, errFail }), errFail)
+}
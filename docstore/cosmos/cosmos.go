@@ -0,0 +1,309 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+// Package cosmos implements a DocStore on an Azure Cosmos DB for NoSQL
+// container, for small documents which must be read and written with low
+// latency, e.g. idempotency records, sessions and webhook delivery state.
+//
+// The store uses the Cosmos DB REST API with the account key.  The
+// container's partition key path must be "/id", and time to live must be
+// enabled on the container (default time to live -1) for documents stored
+// with PutWithTTL to expire.  Documents are limited to 2MB by Cosmos DB.
+package cosmos
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/luthersystems/svc/docstore"
+)
+
+const (
+	apiVersion = "2018-12-31"
+	maxRetries = 5
+)
+
+var _ docstore.DocStore = &Store{}
+var _ docstore.TTLPutter = &Store{}
+var _ docstore.Pinger = &Store{}
+var _ docstore.ConditionalStore = &Store{}
+
+// New returns a new Store configured for the specified container and prefix.
+// The endpoint is the account's URI, e.g.
+// "https://<account>.documents.azure.com", and accountKey its base64
+// encoded primary or secondary key.
+func New(endpoint string, accountKey string, database string, container string, prefix string) (*Store, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("invalid endpoint: %q", endpoint)
+	}
+	key, err := base64.StdEncoding.DecodeString(accountKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid account key: %w", err)
+	}
+	if database == "" || container == "" {
+		return nil, fmt.Errorf("missing database or container")
+	}
+	return &Store{
+		endpoint:  strings.TrimSuffix(u.String(), "/"),
+		key:       key,
+		collLink:  fmt.Sprintf("dbs/%s/colls/%s", database, container),
+		prefix:    prefix,
+		client:    &http.Client{Timeout: 30 * time.Second},
+		now:       time.Now,
+		retryWait: time.Second,
+	}, nil
+}
+
+// Store is a Cosmos DB implementation of a DocStore.
+type Store struct {
+	endpoint  string
+	key       []byte
+	collLink  string
+	prefix    string
+	client    *http.Client
+	now       func() time.Time
+	retryWait time.Duration
+}
+
+// StatusError is returned when Cosmos DB responds with an unexpected status.
+type StatusError struct {
+	StatusCode int
+	Message    string
+}
+
+// Error implements error.
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("status %d: %s", e.StatusCode, e.Message)
+}
+
+// HTTPStatusCode returns the status of the response.
+func (e *StatusError) HTTPStatusCode() int {
+	return e.StatusCode
+}
+
+// document is the JSON representation of a stored document.  Cosmos DB
+// forbids "/" in ids, so the id is derived from the key, which is stored
+// alongside for reference.
+type document struct {
+	ID   string `json:"id"`
+	Key  string `json:"key"`
+	Body []byte `json:"body"`
+	TTL  int64  `json:"ttl,omitempty"`
+	ETag string `json:"_etag,omitempty"`
+}
+
+func (a *Store) docID(key string) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s/%s", a.prefix, key)))
+	return hex.EncodeToString(sum[:])
+}
+
+// Put writes bytes to a Cosmos DB document.
+func (a *Store) Put(ctx context.Context, key string, body []byte) error {
+	_, err := a.put(ctx, key, body, 0, docstore.Precondition{}, true)
+	return err
+}
+
+// PutWithTTL writes bytes to a Cosmos DB document which expires after ttl,
+// rounded up to whole seconds.
+func (a *Store) PutWithTTL(ctx context.Context, key string, body []byte, ttl time.Duration) error {
+	if ttl <= 0 {
+		return fmt.Errorf("invalid ttl: %v", ttl)
+	}
+	_, err := a.put(ctx, key, body, ttl, docstore.Precondition{}, true)
+	return err
+}
+
+// PutIf writes bytes to a Cosmos DB document if cond holds.
+func (a *Store) PutIf(ctx context.Context, key string, body []byte, cond docstore.Precondition) (string, error) {
+	if err := cond.Valid(); err != nil {
+		return "", err
+	}
+	return a.put(ctx, key, body, 0, cond, cond.IfNoneMatch == "")
+}
+
+func (a *Store) put(ctx context.Context, key string, body []byte, ttl time.Duration, cond docstore.Precondition, upsert bool) (string, error) {
+	if err := docstore.ValidKey(key); err != nil {
+		return "", err
+	}
+	id := a.docID(key)
+	doc := document{ID: id, Key: key, Body: body}
+	if ttl > 0 {
+		doc.TTL = int64(math.Ceil(ttl.Seconds()))
+	}
+	b, err := json.Marshal(doc)
+	if err != nil {
+		return "", fmt.Errorf("cosmos put: %w", err)
+	}
+	// Creating a document fails with a conflict if it exists, replacing it
+	// with a precondition failure if its etag changed.
+	method, resLink, path := http.MethodPost, a.collLink, a.collLink+"/docs"
+	header := http.Header{}
+	switch {
+	case cond.IfMatch != "":
+		method, resLink = http.MethodPut, a.collLink+"/docs/"+id
+		path = resLink
+		header.Set("If-Match", cond.IfMatch)
+	case upsert:
+		header.Set("x-ms-documentdb-is-upsert", "True")
+	}
+	header.Set("Content-Type", "application/json")
+	resp, err := a.do(ctx, method, "docs", resLink, path, id, header, b)
+	if err != nil {
+		return "", fmt.Errorf("cosmos put: %w", err)
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusCreated:
+		return resp.Header.Get("etag"), nil
+	case http.StatusConflict, http.StatusPreconditionFailed:
+		return "", docstore.ErrPreconditionFailed
+	case http.StatusNotFound:
+		if cond.IfMatch != "" {
+			return "", docstore.ErrPreconditionFailed
+		}
+	}
+	return "", fmt.Errorf("cosmos put: %w", statusError(resp))
+}
+
+// Get reads bytes stored in a Cosmos DB document.
+func (a *Store) Get(ctx context.Context, key string) ([]byte, error) {
+	body, _, err := a.GetWithETag(ctx, key)
+	return body, err
+}
+
+// GetWithETag reads bytes stored in a Cosmos DB document and their entity
+// tag.
+func (a *Store) GetWithETag(ctx context.Context, key string) ([]byte, string, error) {
+	if err := docstore.ValidKey(key); err != nil {
+		return nil, "", err
+	}
+	id := a.docID(key)
+	link := a.collLink + "/docs/" + id
+	resp, err := a.do(ctx, http.MethodGet, "docs", link, link, id, http.Header{}, nil)
+	if err != nil {
+		return nil, "", fmt.Errorf("cosmos get: %w", err)
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, "", docstore.ErrRequestNotFound
+	default:
+		return nil, "", fmt.Errorf("cosmos get: %w", statusError(resp))
+	}
+	var doc document
+	if err := json.NewDecoder(resp.Body).Decode(&doc); err != nil {
+		return nil, "", fmt.Errorf("failed to read result body: %w", err)
+	}
+	return doc.Body, doc.ETag, nil
+}
+
+// Delete removes a document from the Cosmos DB container.
+func (a *Store) Delete(ctx context.Context, key string) error {
+	if err := docstore.ValidKey(key); err != nil {
+		return err
+	}
+	id := a.docID(key)
+	link := a.collLink + "/docs/" + id
+	resp, err := a.do(ctx, http.MethodDelete, "docs", link, link, id, http.Header{}, nil)
+	if err != nil {
+		return fmt.Errorf("cosmos delete: %w", err)
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusNoContent, http.StatusOK:
+		return nil
+	case http.StatusNotFound:
+		return docstore.ErrRequestNotFound
+	}
+	return fmt.Errorf("cosmos delete: %w", statusError(resp))
+}
+
+// Ping checks the container exists and is accessible by reading its
+// properties.
+func (a *Store) Ping(ctx context.Context) error {
+	resp, err := a.do(ctx, http.MethodGet, "colls", a.collLink, a.collLink, "", http.Header{}, nil)
+	if err != nil {
+		return fmt.Errorf("cosmos ping: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("cosmos ping: %w", statusError(resp))
+	}
+	return nil
+}
+
+// do sends an authorized request, retrying requests throttled by Cosmos DB.
+// The partition key header is set when id is not empty.
+func (a *Store) do(ctx context.Context, method string, resType string, resLink string, path string, id string, header http.Header, body []byte) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, method, a.endpoint+"/"+path, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		date := strings.ToLower(a.now().UTC().Format(http.TimeFormat))
+		req.Header.Set("x-ms-date", date)
+		req.Header.Set("x-ms-version", apiVersion)
+		req.Header.Set("Authorization", a.authorization(method, resType, resLink, date))
+		if id != "" {
+			pk, err := json.Marshal([]string{id})
+			if err != nil {
+				return nil, err
+			}
+			req.Header.Set("x-ms-documentdb-partitionkey", string(pk))
+		}
+		resp, err := a.client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusTooManyRequests || attempt >= maxRetries {
+			return resp, nil
+		}
+		resp.Body.Close()
+		wait := a.retryWait
+		if ms, err := strconv.Atoi(resp.Header.Get("x-ms-retry-after-ms")); err == nil {
+			wait = time.Duration(ms) * time.Millisecond
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+// authorization returns the master key authorization header of a request.
+func (a *Store) authorization(method string, resType string, resLink string, date string) string {
+	payload := strings.ToLower(method) + "\n" + strings.ToLower(resType) + "\n" + resLink + "\n" + date + "\n\n"
+	mac := hmac.New(sha256.New, a.key)
+	mac.Write([]byte(payload))
+	sig := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+	return url.QueryEscape("type=master&ver=1.0&sig=" + sig)
+}
+
+func statusError(resp *http.Response) error {
+	var msg struct {
+		Message string `json:"message"`
+	}
+	b, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if json.Unmarshal(b, &msg) != nil || msg.Message == "" {
+		msg.Message = http.StatusText(resp.StatusCode)
+	}
+	return &StatusError{StatusCode: resp.StatusCode, Message: msg.Message}
+}
@@ -0,0 +1,144 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package cosmos
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/luthersystems/svc/docstore"
+	"github.com/stretchr/testify/require"
+)
+
+const collLink = "dbs/db/colls/docs"
+
+// fakeContainer serves the Cosmos DB REST API of a single container.
+type fakeContainer struct {
+	t        *testing.T
+	store    *Store
+	mu       sync.Mutex
+	docs     map[string]document
+	version  int
+	throttle int
+}
+
+func (f *fakeContainer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.throttle > 0 {
+		f.throttle--
+		w.Header().Set("x-ms-retry-after-ms", "1")
+		w.WriteHeader(http.StatusTooManyRequests)
+		return
+	}
+	path := strings.TrimPrefix(r.URL.Path, "/")
+	resType, resLink := "docs", path
+	switch {
+	case path == collLink:
+		resType = "colls"
+	case path == collLink+"/docs":
+		resLink = collLink
+	}
+	want := f.store.authorization(r.Method, resType, resLink, r.Header.Get("x-ms-date"))
+	require.Equal(f.t, want, r.Header.Get("Authorization"))
+	require.Equal(f.t, apiVersion, r.Header.Get("x-ms-version"))
+	if resType == "colls" {
+		return
+	}
+	id := strings.TrimPrefix(path, collLink+"/docs/")
+	var doc document
+	if r.Method == http.MethodPost || r.Method == http.MethodPut {
+		b, _ := io.ReadAll(r.Body)
+		require.NoError(f.t, json.Unmarshal(b, &doc))
+		id = doc.ID
+	}
+	require.Equal(f.t, fmt.Sprintf("[%q]", id), r.Header.Get("x-ms-documentdb-partitionkey"))
+	old, exists := f.docs[id]
+	switch r.Method {
+	case http.MethodGet, http.MethodDelete:
+		if !exists {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if r.Method == http.MethodDelete {
+			delete(f.docs, id)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(old)
+	case http.MethodPost, http.MethodPut:
+		if r.Method == http.MethodPost && exists && r.Header.Get("x-ms-documentdb-is-upsert") != "True" {
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		if r.Method == http.MethodPut && !exists {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if m := r.Header.Get("If-Match"); m != "" && m != old.ETag {
+			w.WriteHeader(http.StatusPreconditionFailed)
+			return
+		}
+		f.version++
+		doc.ETag = fmt.Sprintf("\"%d\"", f.version)
+		f.docs[id] = doc
+		w.Header().Set("etag", doc.ETag)
+		w.WriteHeader(http.StatusCreated)
+	}
+}
+
+func TestStore(t *testing.T) {
+	f := &fakeContainer{t: t, docs: make(map[string]document)}
+	srv := httptest.NewServer(f)
+	defer srv.Close()
+	key := base64.StdEncoding.EncodeToString([]byte("account-key"))
+	store, err := New(srv.URL, key, "db", "docs", "test")
+	require.NoError(t, err)
+	f.store = store
+	ctx := context.Background()
+
+	require.NoError(t, store.Ping(ctx))
+	_, err = store.Get(ctx, "a/b.json")
+	require.ErrorIs(t, err, docstore.ErrRequestNotFound)
+	require.NoError(t, store.Put(ctx, "a/b.json", []byte("{}")))
+	require.NoError(t, store.Put(ctx, "a/b.json", []byte("{\"v\":1}")))
+	body, etag, err := store.GetWithETag(ctx, "a/b.json")
+	require.NoError(t, err)
+	require.Equal(t, []byte("{\"v\":1}"), body)
+	require.Equal(t, "a/b.json", f.docs[store.docID("a/b.json")].Key)
+
+	_, err = store.PutIf(ctx, "a/b.json", []byte("2"), docstore.IfNotExists)
+	require.ErrorIs(t, err, docstore.ErrPreconditionFailed)
+	_, err = store.PutIf(ctx, "a/b.json", []byte("2"), docstore.Precondition{IfMatch: "stale"})
+	require.ErrorIs(t, err, docstore.ErrPreconditionFailed)
+	etag2, err := store.PutIf(ctx, "a/b.json", []byte("2"), docstore.Precondition{IfMatch: etag})
+	require.NoError(t, err)
+	require.NotEqual(t, etag, etag2)
+	_, err = store.PutIf(ctx, "c.json", []byte("2"), docstore.Precondition{IfMatch: etag2})
+	require.ErrorIs(t, err, docstore.ErrPreconditionFailed)
+	_, err = store.PutIf(ctx, "c.json", []byte("2"), docstore.IfNotExists)
+	require.NoError(t, err)
+
+	require.NoError(t, store.PutWithTTL(ctx, "d.json", []byte("{}"), 1500*time.Millisecond))
+	require.Equal(t, int64(2), f.docs[store.docID("d.json")].TTL)
+
+	// Throttled requests are retried.
+	f.throttle = 2
+	require.NoError(t, store.Delete(ctx, "a/b.json"))
+	require.ErrorIs(t, store.Delete(ctx, "a/b.json"), docstore.ErrRequestNotFound)
+	f.throttle = maxRetries + 1
+	err = store.Delete(ctx, "c.json")
+	require.Equal(t, docstore.ErrorClassThrottled, docstore.ErrorClass(err))
+
+	_, err = New(srv.URL, "not base64!", "db", "docs", "test")
+	require.Error(t, err)
+}
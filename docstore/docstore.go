@@ -33,6 +33,14 @@ type Deleter interface {
 	Delete(ctx context.Context, key string) error
 }
 
+// Pinger checks connectivity to the underlying storage.
+type Pinger interface {
+	// Ping returns an error if the storage is unreachable or the
+	// configured bucket or container is inaccessible.  It does not read
+	// or write documents.
+	Ping(ctx context.Context) error
+}
+
 // DocStore provides document services.
 type DocStore interface {
 	Getter
@@ -0,0 +1,240 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+// Package dynamodb implements a DocStore on a DynamoDB table, for small
+// documents which must be read and written with low latency, e.g.
+// idempotency records, sessions and webhook delivery state.  Items are
+// limited to 400KB by DynamoDB.
+package dynamodb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/google/uuid"
+	"github.com/luthersystems/svc/docstore"
+)
+
+// Attributes of the items holding documents.  The table's partition key is
+// KeyAttribute, of type string, and TTLAttribute should be configured as
+// the table's time to live attribute.
+const (
+	// KeyAttribute holds the key of the document.
+	KeyAttribute = "key"
+	// BodyAttribute holds the document.
+	BodyAttribute = "body"
+	// ETagAttribute holds the entity tag of the document.
+	ETagAttribute = "etag"
+	// TTLAttribute holds the unix time in seconds after which the document
+	// expires.
+	TTLAttribute = "expires_at"
+)
+
+var _ docstore.DocStore = &Store{}
+var _ docstore.TTLPutter = &Store{}
+var _ docstore.Pinger = &Store{}
+var _ docstore.ConditionalStore = &Store{}
+
+// New returns a new Store configured for the specified table and prefix.
+func New(region string, table string, prefix string) (*Store, error) {
+	sess, err := session.NewSession(&aws.Config{Region: aws.String(region)})
+	if err != nil {
+		return nil, err
+	}
+	return NewWithSession(sess, table, prefix)
+}
+
+// NewWithSession returns a new Store configured for the specified session.
+func NewWithSession(sess *session.Session, table string, prefix string) (*Store, error) {
+	return NewWithClient(dynamodb.New(sess), table, prefix)
+}
+
+// NewWithClient returns a new Store using the specified DynamoDB client.
+func NewWithClient(svc dynamodbiface.DynamoDBAPI, table string, prefix string) (*Store, error) {
+	if table == "" {
+		return nil, fmt.Errorf("missing table")
+	}
+	return &Store{table: table, prefix: prefix, svc: svc, now: time.Now}, nil
+}
+
+// Store is a DynamoDB implementation of a DocStore.  Expired documents are
+// not returned, even before DynamoDB deletes them.
+type Store struct {
+	table  string
+	prefix string
+	svc    dynamodbiface.DynamoDBAPI
+	now    func() time.Time
+}
+
+func (a *Store) itemKey(key string) map[string]*dynamodb.AttributeValue {
+	return map[string]*dynamodb.AttributeValue{
+		KeyAttribute: {S: aws.String(fmt.Sprintf("%s/%s", a.prefix, key))},
+	}
+}
+
+// Put writes bytes to a DynamoDB item.
+func (a *Store) Put(ctx context.Context, key string, body []byte) error {
+	_, err := a.put(ctx, key, body, 0, docstore.Precondition{})
+	return err
+}
+
+// PutWithTTL writes bytes to a DynamoDB item which expires after ttl.
+func (a *Store) PutWithTTL(ctx context.Context, key string, body []byte, ttl time.Duration) error {
+	if ttl <= 0 {
+		return fmt.Errorf("invalid ttl: %v", ttl)
+	}
+	_, err := a.put(ctx, key, body, ttl, docstore.Precondition{})
+	return err
+}
+
+// PutIf writes bytes to a DynamoDB item if cond holds.  An expired item is
+// treated as missing.
+func (a *Store) PutIf(ctx context.Context, key string, body []byte, cond docstore.Precondition) (string, error) {
+	return a.put(ctx, key, body, 0, cond)
+}
+
+func (a *Store) put(ctx context.Context, key string, body []byte, ttl time.Duration, cond docstore.Precondition) (string, error) {
+	if err := docstore.ValidKey(key); err != nil {
+		return "", err
+	}
+	if err := cond.Valid(); err != nil {
+		return "", err
+	}
+	now := a.now()
+	etag := uuid.NewString()
+	item := a.itemKey(key)
+	item[BodyAttribute] = &dynamodb.AttributeValue{B: body}
+	item[ETagAttribute] = &dynamodb.AttributeValue{S: aws.String(etag)}
+	if ttl > 0 {
+		item[TTLAttribute] = unixAttribute(now.Add(ttl))
+	}
+	input := &dynamodb.PutItemInput{
+		TableName: aws.String(a.table),
+		Item:      item,
+	}
+	switch {
+	case cond.IfNoneMatch != "":
+		input.ConditionExpression = aws.String("attribute_not_exists(#key) OR #ttl <= :now")
+		input.ExpressionAttributeNames = map[string]*string{
+			"#key": aws.String(KeyAttribute),
+			"#ttl": aws.String(TTLAttribute),
+		}
+		input.ExpressionAttributeValues = map[string]*dynamodb.AttributeValue{
+			":now": unixAttribute(now),
+		}
+	case cond.IfMatch != "":
+		input.ConditionExpression = aws.String("#etag = :etag AND (attribute_not_exists(#ttl) OR #ttl > :now)")
+		input.ExpressionAttributeNames = map[string]*string{
+			"#etag": aws.String(ETagAttribute),
+			"#ttl":  aws.String(TTLAttribute),
+		}
+		input.ExpressionAttributeValues = map[string]*dynamodb.AttributeValue{
+			":etag": {S: aws.String(cond.IfMatch)},
+			":now":  unixAttribute(now),
+		}
+	}
+	_, err := a.svc.PutItemWithContext(ctx, input)
+	if err != nil {
+		if isConditionFailed(err) {
+			return "", docstore.ErrPreconditionFailed
+		}
+		return "", fmt.Errorf("dynamodb put: %w", err)
+	}
+	return etag, nil
+}
+
+// Get reads bytes stored in a DynamoDB item.
+func (a *Store) Get(ctx context.Context, key string) ([]byte, error) {
+	body, _, err := a.GetWithETag(ctx, key)
+	return body, err
+}
+
+// GetWithETag reads bytes stored in a DynamoDB item and their entity tag.
+func (a *Store) GetWithETag(ctx context.Context, key string) ([]byte, string, error) {
+	if err := docstore.ValidKey(key); err != nil {
+		return nil, "", err
+	}
+	input := &dynamodb.GetItemInput{
+		TableName:      aws.String(a.table),
+		Key:            a.itemKey(key),
+		ConsistentRead: aws.Bool(true),
+	}
+	result, err := a.svc.GetItemWithContext(ctx, input)
+	if err != nil {
+		return nil, "", fmt.Errorf("dynamodb get: %w", err)
+	}
+	if result.Item == nil || a.expired(result.Item) {
+		return nil, "", docstore.ErrRequestNotFound
+	}
+	var etag string
+	if v := result.Item[ETagAttribute]; v != nil {
+		etag = aws.StringValue(v.S)
+	}
+	var body []byte
+	if v := result.Item[BodyAttribute]; v != nil {
+		body = v.B
+	}
+	return body, etag, nil
+}
+
+// expired returns true if DynamoDB has not yet deleted an expired item.
+func (a *Store) expired(item map[string]*dynamodb.AttributeValue) bool {
+	v := item[TTLAttribute]
+	if v == nil || v.N == nil {
+		return false
+	}
+	expiresAt, err := strconv.ParseInt(*v.N, 10, 64)
+	if err != nil {
+		return false
+	}
+	return expiresAt <= a.now().Unix()
+}
+
+// Delete removes an item from the DynamoDB table.
+func (a *Store) Delete(ctx context.Context, key string) error {
+	if err := docstore.ValidKey(key); err != nil {
+		return err
+	}
+	input := &dynamodb.DeleteItemInput{
+		TableName:    aws.String(a.table),
+		Key:          a.itemKey(key),
+		ReturnValues: aws.String(dynamodb.ReturnValueAllOld),
+	}
+	result, err := a.svc.DeleteItemWithContext(ctx, input)
+	if err != nil {
+		return fmt.Errorf("dynamodb delete: %w", err)
+	}
+	if result.Attributes == nil || a.expired(result.Attributes) {
+		return docstore.ErrRequestNotFound
+	}
+	return nil
+}
+
+// Ping checks the table exists and is accessible with a DescribeTable
+// request.
+func (a *Store) Ping(ctx context.Context) error {
+	input := &dynamodb.DescribeTableInput{
+		TableName: aws.String(a.table),
+	}
+	_, err := a.svc.DescribeTableWithContext(ctx, input)
+	if err != nil {
+		return fmt.Errorf("dynamodb ping: %w", err)
+	}
+	return nil
+}
+
+func unixAttribute(t time.Time) *dynamodb.AttributeValue {
+	return &dynamodb.AttributeValue{N: aws.String(strconv.FormatInt(t.Unix(), 10))}
+}
+
+func isConditionFailed(err error) bool {
+	var aerr awserr.Error
+	return errors.As(err, &aerr) && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException
+}
@@ -0,0 +1,106 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package dynamodb
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/luthersystems/svc/docstore"
+	"github.com/stretchr/testify/require"
+)
+
+// memTable is a DynamoDB table supporting the conditions used by Store.
+type memTable struct {
+	dynamodbiface.DynamoDBAPI
+	items map[string]map[string]*dynamodb.AttributeValue
+}
+
+func (m *memTable) holds(input *dynamodb.PutItemInput, old map[string]*dynamodb.AttributeValue) bool {
+	if input.ConditionExpression == nil {
+		return true
+	}
+	now, _ := strconv.ParseInt(*input.ExpressionAttributeValues[":now"].N, 10, 64)
+	live := old != nil
+	if v := old[TTLAttribute]; live && v != nil {
+		expiresAt, _ := strconv.ParseInt(*v.N, 10, 64)
+		live = expiresAt > now
+	}
+	if etag := input.ExpressionAttributeValues[":etag"]; etag != nil {
+		return live && *old[ETagAttribute].S == *etag.S
+	}
+	return !live
+}
+
+func (m *memTable) PutItemWithContext(ctx aws.Context, input *dynamodb.PutItemInput, opts ...request.Option) (*dynamodb.PutItemOutput, error) {
+	key := *input.Item[KeyAttribute].S
+	if !m.holds(input, m.items[key]) {
+		return nil, awserr.New(dynamodb.ErrCodeConditionalCheckFailedException, "conditional check failed", nil)
+	}
+	m.items[key] = input.Item
+	return &dynamodb.PutItemOutput{}, nil
+}
+
+func (m *memTable) GetItemWithContext(ctx aws.Context, input *dynamodb.GetItemInput, opts ...request.Option) (*dynamodb.GetItemOutput, error) {
+	return &dynamodb.GetItemOutput{Item: m.items[*input.Key[KeyAttribute].S]}, nil
+}
+
+func (m *memTable) DeleteItemWithContext(ctx aws.Context, input *dynamodb.DeleteItemInput, opts ...request.Option) (*dynamodb.DeleteItemOutput, error) {
+	key := *input.Key[KeyAttribute].S
+	old := m.items[key]
+	delete(m.items, key)
+	return &dynamodb.DeleteItemOutput{Attributes: old}, nil
+}
+
+func TestStore(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	table := &memTable{items: make(map[string]map[string]*dynamodb.AttributeValue)}
+	store, err := NewWithClient(table, "docs", "test")
+	require.NoError(t, err)
+	store.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	_, err = store.Get(ctx, "a.json")
+	require.ErrorIs(t, err, docstore.ErrRequestNotFound)
+	require.NoError(t, store.Put(ctx, "a.json", []byte("{}")))
+	body, etag, err := store.GetWithETag(ctx, "a.json")
+	require.NoError(t, err)
+	require.Equal(t, []byte("{}"), body)
+	require.Contains(t, table.items, "test/a.json")
+
+	// Conditional writes.
+	_, err = store.PutIf(ctx, "a.json", []byte("1"), docstore.IfNotExists)
+	require.ErrorIs(t, err, docstore.ErrPreconditionFailed)
+	_, err = store.PutIf(ctx, "a.json", []byte("1"), docstore.Precondition{IfMatch: "stale"})
+	require.ErrorIs(t, err, docstore.ErrPreconditionFailed)
+	etag2, err := store.PutIf(ctx, "a.json", []byte("1"), docstore.Precondition{IfMatch: etag})
+	require.NoError(t, err)
+	require.NotEqual(t, etag, etag2)
+	_, err = store.PutIf(ctx, "a.json", []byte("2"), docstore.Precondition{IfMatch: etag})
+	require.ErrorIs(t, err, docstore.ErrPreconditionFailed)
+	_, err = store.PutIf(ctx, "a.json", []byte("2"), docstore.Precondition{IfNoneMatch: "etag"})
+	require.Error(t, err)
+
+	// Expired documents are missing before DynamoDB deletes them.
+	require.NoError(t, store.PutWithTTL(ctx, "b.json", []byte("{}"), time.Hour))
+	require.Equal(t, strconv.FormatInt(now.Add(time.Hour).Unix(), 10), *table.items["test/b.json"][TTLAttribute].N)
+	_, err = store.Get(ctx, "b.json")
+	require.NoError(t, err)
+	now = now.Add(time.Hour)
+	_, err = store.Get(ctx, "b.json")
+	require.ErrorIs(t, err, docstore.ErrRequestNotFound)
+	_, err = store.PutIf(ctx, "b.json", []byte("{}"), docstore.IfNotExists)
+	require.NoError(t, err)
+	require.Error(t, store.PutWithTTL(ctx, "b.json", []byte("{}"), 0))
+
+	require.NoError(t, store.Delete(ctx, "a.json"))
+	require.ErrorIs(t, store.Delete(ctx, "a.json"), docstore.ErrRequestNotFound)
+	require.Error(t, store.Put(ctx, "../a.json", nil))
+}
@@ -0,0 +1,299 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package docstore
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/luthersystems/svc/docstore"
+
+// Error classes of the docstore_errors_total metric.
+const (
+	ErrorClassNotFound     = "not_found"
+	ErrorClassPrecondition = "precondition_failed"
+	ErrorClassThrottled    = "throttled"
+	ErrorClassOther        = "other"
+)
+
+// ErrMetricsRegistered is returned by RegisterMetrics when metrics have
+// already been registered.
+var ErrMetricsRegistered = errors.New("docstore metrics already registered")
+
+var (
+	operationDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "docstore_operation_duration_seconds",
+			Help:    "Duration of document store operations, partitioned by store, operation and result.",
+			Buckets: prometheus.DefBuckets,
+		},
+		[]string{"store", "op", "result"},
+	)
+	operationErrors = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "docstore_errors_total",
+			Help: "How many document store operations failed, partitioned by store, operation and error class.",
+		},
+		[]string{"store", "op", "class"},
+	)
+	payloadBytes = prometheus.NewSummaryVec(
+		prometheus.SummaryOpts{
+			Name:       "docstore_payload_bytes",
+			Help:       "Size of documents read and written, partitioned by store and operation.",
+			Objectives: map[float64]float64{0.5: 0.05, 0.9: 0.01, 0.99: 0.001},
+		},
+		[]string{"store", "op"},
+	)
+
+	// metricsOnce guards metrics registration.
+	metricsOnce sync.Once
+)
+
+// Collectors returns the prometheus collectors populated by instrumented
+// stores, for callers which register them themselves.
+func Collectors() []prometheus.Collector {
+	return []prometheus.Collector{operationDuration, operationErrors, payloadBytes}
+}
+
+// RegisterMetrics registers the package's metrics with reg.  It must be
+// called before any instrumented store is used, otherwise the metrics are
+// lazily registered with the default prometheus registerer and
+// ErrMetricsRegistered is returned.  A nil reg disables registration.
+func RegisterMetrics(reg prometheus.Registerer) error {
+	err := ErrMetricsRegistered
+	metricsOnce.Do(func() {
+		err = registerMetrics(reg)
+	})
+	return err
+}
+
+func registerMetrics(reg prometheus.Registerer) error {
+	if reg == nil {
+		return nil
+	}
+	for _, c := range Collectors() {
+		if err := reg.Register(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ensureMetrics lazily registers metrics with the default registerer, unless
+// RegisterMetrics has been called.
+func ensureMetrics() {
+	metricsOnce.Do(func() {
+		_ = registerMetrics(prometheus.DefaultRegisterer)
+	})
+}
+
+// Instrument wraps inner, recording prometheus metrics and creating an
+// OpenTelemetry span for each operation.  Metrics are labeled with name,
+// which identifies the backend.  The returned store implements TTLPutter,
+// Pinger and ConditionalStore when inner does.
+func Instrument(inner DocStore, name string) DocStore {
+	s := &instrumented{inner: inner, name: name}
+	_, ttl := inner.(TTLPutter)
+	_, ping := inner.(Pinger)
+	_, cond := inner.(ConditionalStore)
+	switch {
+	case ttl && ping && cond:
+		return struct {
+			DocStore
+			TTLPutter
+			Pinger
+			ConditionalStore
+		}{s, instrumentedTTL{s}, instrumentedPinger{s}, instrumentedConditional{s}}
+	case ttl && ping:
+		return struct {
+			DocStore
+			TTLPutter
+			Pinger
+		}{s, instrumentedTTL{s}, instrumentedPinger{s}}
+	case ttl && cond:
+		return struct {
+			DocStore
+			TTLPutter
+			ConditionalStore
+		}{s, instrumentedTTL{s}, instrumentedConditional{s}}
+	case ping && cond:
+		return struct {
+			DocStore
+			Pinger
+			ConditionalStore
+		}{s, instrumentedPinger{s}, instrumentedConditional{s}}
+	case ttl:
+		return struct {
+			DocStore
+			TTLPutter
+		}{s, instrumentedTTL{s}}
+	case ping:
+		return struct {
+			DocStore
+			Pinger
+		}{s, instrumentedPinger{s}}
+	case cond:
+		return struct {
+			DocStore
+			ConditionalStore
+		}{s, instrumentedConditional{s}}
+	}
+	return s
+}
+
+type instrumented struct {
+	inner DocStore
+	name  string
+}
+
+// Get implements Getter.
+func (s *instrumented) Get(ctx context.Context, key string) (body []byte, err error) {
+	ctx, done := s.start(ctx, "get", key)
+	defer func() { done(len(body), err) }()
+	return s.inner.Get(ctx, key)
+}
+
+// Put implements Putter.
+func (s *instrumented) Put(ctx context.Context, key string, body []byte) (err error) {
+	ctx, done := s.start(ctx, "put", key)
+	defer func() { done(len(body), err) }()
+	return s.inner.Put(ctx, key, body)
+}
+
+// Delete implements Deleter.
+func (s *instrumented) Delete(ctx context.Context, key string) (err error) {
+	ctx, done := s.start(ctx, "delete", key)
+	defer func() { done(-1, err) }()
+	return s.inner.Delete(ctx, key)
+}
+
+type instrumentedTTL struct {
+	*instrumented
+}
+
+// PutWithTTL implements TTLPutter.
+func (s instrumentedTTL) PutWithTTL(ctx context.Context, key string, body []byte, ttl time.Duration) (err error) {
+	ctx, done := s.start(ctx, "put_with_ttl", key)
+	defer func() { done(len(body), err) }()
+	return s.inner.(TTLPutter).PutWithTTL(ctx, key, body, ttl)
+}
+
+type instrumentedPinger struct {
+	*instrumented
+}
+
+// Ping implements Pinger.
+func (s instrumentedPinger) Ping(ctx context.Context) (err error) {
+	ctx, done := s.start(ctx, "ping", "")
+	defer func() { done(-1, err) }()
+	return s.inner.(Pinger).Ping(ctx)
+}
+
+type instrumentedConditional struct {
+	*instrumented
+}
+
+// GetWithETag implements ConditionalStore.
+func (s instrumentedConditional) GetWithETag(ctx context.Context, key string) (body []byte, etag string, err error) {
+	ctx, done := s.start(ctx, "get", key)
+	defer func() { done(len(body), err) }()
+	return s.inner.(ConditionalStore).GetWithETag(ctx, key)
+}
+
+// PutIf implements ConditionalStore.
+func (s instrumentedConditional) PutIf(ctx context.Context, key string, body []byte, cond Precondition) (etag string, err error) {
+	ctx, done := s.start(ctx, "put_if", key)
+	defer func() { done(len(body), err) }()
+	return s.inner.(ConditionalStore).PutIf(ctx, key, body, cond)
+}
+
+// start starts an operation, returning a function to call with the payload
+// size, or -1 if the operation has no payload, and the result once the
+// operation is done.
+func (s *instrumented) start(ctx context.Context, op string, key string) (context.Context, func(size int, err error)) {
+	ensureMetrics()
+	attrs := []attribute.KeyValue{
+		attribute.String("docstore.name", s.name),
+		attribute.String("docstore.op", op),
+	}
+	if key != "" {
+		attrs = append(attrs, attribute.String("docstore.key", key))
+	}
+	ctx, span := otel.Tracer(tracerName).Start(ctx, "docstore."+op,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attrs...))
+	start := time.Now()
+	return ctx, func(size int, err error) {
+		defer span.End()
+		result := "ok"
+		if err != nil {
+			class := ErrorClass(err)
+			result = "error"
+			operationErrors.WithLabelValues(s.name, op, class).Inc()
+			span.SetAttributes(attribute.String("docstore.error_class", class))
+			if class != ErrorClassNotFound && class != ErrorClassPrecondition {
+				span.RecordError(err)
+				span.SetStatus(codes.Error, err.Error())
+			}
+		} else if size >= 0 {
+			payloadBytes.WithLabelValues(s.name, op).Observe(float64(size))
+			span.SetAttributes(attribute.Int("docstore.size", size))
+		}
+		operationDuration.WithLabelValues(s.name, op, result).Observe(time.Since(start).Seconds())
+	}
+}
+
+// throttlingCodes are the error codes storage services return when
+// throttling requests.
+var throttlingCodes = map[string]bool{
+	"SlowDown":                 true,
+	"Throttling":               true,
+	"ThrottlingException":      true,
+	"RequestLimitExceeded":     true,
+	"TooManyRequestsException": true,
+	"ServerBusy":               true,
+
+	"ProvisionedThroughputExceededException": true,
+}
+
+// ErrorClass classifies a document store error as ErrorClassNotFound,
+// ErrorClassPrecondition, ErrorClassThrottled or ErrorClassOther.
+// Throttling is detected from the error codes and HTTP status codes of the
+// storage backends.
+func ErrorClass(err error) string {
+	if errors.Is(err, ErrRequestNotFound) {
+		return ErrorClassNotFound
+	}
+	if errors.Is(err, ErrPreconditionFailed) {
+		return ErrorClassPrecondition
+	}
+	var coder interface{ ErrorCode() string }
+	if errors.As(err, &coder) && throttlingCodes[coder.ErrorCode()] {
+		return ErrorClassThrottled
+	}
+	var status interface{ HTTPStatusCode() int }
+	if errors.As(err, &status) && throttlingStatus(status.HTTPStatusCode()) {
+		return ErrorClassThrottled
+	}
+	var responder interface{ Response() *http.Response }
+	if errors.As(err, &responder) {
+		if resp := responder.Response(); resp != nil && throttlingStatus(resp.StatusCode) {
+			return ErrorClassThrottled
+		}
+	}
+	return ErrorClassOther
+}
+
+func throttlingStatus(code int) bool {
+	return code == http.StatusTooManyRequests || code == http.StatusServiceUnavailable
+}
@@ -0,0 +1,114 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package docstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+type ttlMemStore struct {
+	memStore
+}
+
+func (m ttlMemStore) PutWithTTL(ctx context.Context, key string, body []byte, ttl time.Duration) error {
+	return m.Put(ctx, key, body)
+}
+
+type condMemStore struct {
+	memStore
+}
+
+func (m condMemStore) GetWithETag(ctx context.Context, key string) ([]byte, string, error) {
+	b, err := m.Get(ctx, key)
+	return b, string(b), err
+}
+
+func (m condMemStore) PutIf(ctx context.Context, key string, body []byte, cond Precondition) (string, error) {
+	if _, ok := m.memStore[key]; ok && cond == IfNotExists {
+		return "", ErrPreconditionFailed
+	}
+	return string(body), m.Put(ctx, key, body)
+}
+
+type statusError int
+
+func (e statusError) Error() string       { return fmt.Sprintf("status %d", int(e)) }
+func (e statusError) HTTPStatusCode() int { return int(e) }
+
+type codeError string
+
+func (e codeError) Error() string     { return string(e) }
+func (e codeError) ErrorCode() string { return string(e) }
+
+type responseError int
+
+func (e responseError) Error() string { return "response error" }
+func (e responseError) Response() *http.Response {
+	return &http.Response{StatusCode: int(e)}
+}
+
+func TestErrorClass(t *testing.T) {
+	require.Equal(t, ErrorClassNotFound, ErrorClass(fmt.Errorf("get: %w", ErrRequestNotFound)))
+	require.Equal(t, ErrorClassPrecondition, ErrorClass(fmt.Errorf("put: %w", ErrPreconditionFailed)))
+	require.Equal(t, ErrorClassThrottled, ErrorClass(fmt.Errorf("put: %w", statusError(http.StatusServiceUnavailable))))
+	require.Equal(t, ErrorClassThrottled, ErrorClass(codeError("SlowDown")))
+	require.Equal(t, ErrorClassThrottled, ErrorClass(responseError(http.StatusTooManyRequests)))
+	require.Equal(t, ErrorClassOther, ErrorClass(statusError(http.StatusForbidden)))
+	require.Equal(t, ErrorClassOther, ErrorClass(errors.New("boom")))
+}
+
+func TestInstrument(t *testing.T) {
+	require.NoError(t, RegisterMetrics(prometheus.NewRegistry()))
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(tp)
+	defer otel.SetTracerProvider(prev)
+
+	ctx := context.Background()
+	store := Instrument(memStore{}, "mem")
+	_, ok := store.(TTLPutter)
+	require.False(t, ok)
+	_, ok = Instrument(ttlMemStore{memStore{}}, "ttl").(TTLPutter)
+	require.True(t, ok)
+	cond, ok := Instrument(condMemStore{memStore{}}, "cond").(ConditionalStore)
+	require.True(t, ok)
+	_, err := cond.PutIf(ctx, "a.json", []byte("{}"), IfNotExists)
+	require.NoError(t, err)
+	_, err = cond.PutIf(ctx, "a.json", []byte("{}"), IfNotExists)
+	require.ErrorIs(t, err, ErrPreconditionFailed)
+	require.Equal(t, float64(1), testutil.ToFloat64(operationErrors.WithLabelValues("cond", "put_if", ErrorClassPrecondition)))
+	exporter.Reset()
+
+	require.NoError(t, store.Put(ctx, "a.json", []byte("{}")))
+	body, err := store.Get(ctx, "a.json")
+	require.NoError(t, err)
+	require.Equal(t, []byte("{}"), body)
+	_, err = store.Get(ctx, "b.json")
+	require.ErrorIs(t, err, ErrRequestNotFound)
+	require.NoError(t, store.Delete(ctx, "a.json"))
+
+	require.Equal(t, float64(1), testutil.ToFloat64(operationErrors.WithLabelValues("mem", "get", ErrorClassNotFound)))
+	var m dto.Metric
+	require.NoError(t, payloadBytes.WithLabelValues("mem", "put").(prometheus.Metric).Write(&m))
+	require.Equal(t, uint64(1), m.GetSummary().GetSampleCount())
+	require.Equal(t, float64(2), m.GetSummary().GetSampleSum())
+
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 4)
+	require.Equal(t, "docstore.put", spans[0].Name)
+	require.Equal(t, "docstore.get", spans[1].Name)
+}
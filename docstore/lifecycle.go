@@ -0,0 +1,83 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package docstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+)
+
+const (
+	// TrashPrefix prefixes the keys of soft-deleted documents.  Storage
+	// lifecycle rules should expire objects under this prefix.
+	TrashPrefix = ".trash/"
+
+	// TTLDaysTag is the S3 object tag and azure blob metadata key holding the
+	// number of days a document is retained.  Lifecycle rules filter on this
+	// value to expire documents.
+	TTLDaysTag = "docstore_ttl_days"
+
+	// ExpiresAtTag is the S3 object tag and azure blob metadata key holding
+	// the RFC 3339 time after which a document may be deleted.
+	ExpiresAtTag = "docstore_expires_at"
+)
+
+// TTLPutter stores documents which expire.
+type TTLPutter interface {
+	// PutWithTTL stores the document, marking it for deletion by storage
+	// lifecycle rules once ttl has elapsed.
+	PutWithTTL(ctx context.Context, key string, body []byte, ttl time.Duration) error
+}
+
+// TTLTags returns the tags recording a ttl of a document stored at now.  The
+// ttl is rounded up to whole days, the granularity of lifecycle rules.
+func TTLTags(now time.Time, ttl time.Duration) (map[string]string, error) {
+	if ttl <= 0 {
+		return nil, fmt.Errorf("invalid ttl: %v", ttl)
+	}
+	days := int(math.Ceil(ttl.Hours() / 24))
+	return map[string]string{
+		TTLDaysTag:   strconv.Itoa(days),
+		ExpiresAtTag: now.Add(ttl).UTC().Format(time.RFC3339),
+	}, nil
+}
+
+// TrashKey returns the key of the soft-deleted document stored at key.
+func TrashKey(key string) string {
+	return TrashPrefix + key
+}
+
+// SoftDelete deletes the document at key by moving it under TrashPrefix, from
+// where it can be restored with Undelete.
+func SoftDelete(ctx context.Context, store DocStore, key string) error {
+	return move(ctx, store, key, TrashKey(key))
+}
+
+// Undelete restores a document deleted by SoftDelete.
+func Undelete(ctx context.Context, store DocStore, key string) error {
+	return move(ctx, store, TrashKey(key), key)
+}
+
+func move(ctx context.Context, store DocStore, from string, to string) error {
+	if err := ValidKey(from); err != nil {
+		return err
+	}
+	if err := ValidKey(to); err != nil {
+		return err
+	}
+	body, err := store.Get(ctx, from)
+	if err != nil {
+		return fmt.Errorf("move get: %w", err)
+	}
+	if err := store.Put(ctx, to, body); err != nil {
+		return fmt.Errorf("move put: %w", err)
+	}
+	if err := store.Delete(ctx, from); err != nil && !errors.Is(err, ErrRequestNotFound) {
+		return fmt.Errorf("move delete: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package docstore
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type memStore map[string][]byte
+
+func (m memStore) Get(ctx context.Context, key string) ([]byte, error) {
+	b, ok := m[key]
+	if !ok {
+		return nil, ErrRequestNotFound
+	}
+	return b, nil
+}
+
+func (m memStore) Put(ctx context.Context, key string, body []byte) error {
+	m[key] = body
+	return nil
+}
+
+func (m memStore) Delete(ctx context.Context, key string) error {
+	delete(m, key)
+	return nil
+}
+
+func TestSoftDelete(t *testing.T) {
+	ctx := context.Background()
+	store := memStore{"a/b.json": []byte("{}")}
+
+	require.NoError(t, SoftDelete(ctx, store, "a/b.json"))
+	_, err := store.Get(ctx, "a/b.json")
+	require.ErrorIs(t, err, ErrRequestNotFound)
+	require.Equal(t, []byte("{}"), store[TrashKey("a/b.json")])
+
+	require.NoError(t, Undelete(ctx, store, "a/b.json"))
+	require.Equal(t, []byte("{}"), store["a/b.json"])
+	require.NotContains(t, store, TrashKey("a/b.json"))
+
+	require.ErrorIs(t, Undelete(ctx, store, "a/b.json"), ErrRequestNotFound)
+	require.Error(t, SoftDelete(ctx, store, "../a"))
+}
+
+func TestTTLTags(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tags, err := TTLTags(now, 36*time.Hour)
+	require.NoError(t, err)
+	require.Equal(t, "2", tags[TTLDaysTag])
+	require.Equal(t, "2024-01-02T12:00:00Z", tags[ExpiresAtTag])
+
+	_, err = TTLTags(now, 0)
+	require.Error(t, err)
+}
@@ -8,6 +8,8 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
@@ -23,6 +25,9 @@ type missingRetryer struct {
 }
 
 var _ docstore.DocStore = &Store{}
+var _ docstore.TTLPutter = &Store{}
+var _ docstore.Pinger = &Store{}
+var _ docstore.ConditionalStore = &Store{}
 
 func (retryer missingRetryer) ShouldRetry(req *request.Request) bool {
 	if req.HTTPResponse.StatusCode == 404 {
@@ -56,33 +61,102 @@ type Store struct {
 
 // Put writes bytes to an S3 object.
 func (a *Store) Put(ctx context.Context, key string, body []byte) error {
-	err := docstore.ValidKey(key)
+	_, err := a.put(ctx, key, body, nil, docstore.Precondition{})
+	return err
+}
+
+// PutWithTTL writes bytes to an S3 object tagged for expiry by bucket
+// lifecycle rules filtering on the docstore.TTLDaysTag tag.
+func (a *Store) PutWithTTL(ctx context.Context, key string, body []byte, ttl time.Duration) error {
+	tags, err := docstore.TTLTags(time.Now(), ttl)
 	if err != nil {
 		return err
 	}
+	_, err = a.put(ctx, key, body, tags, docstore.Precondition{})
+	return err
+}
+
+// PutIf writes bytes to an S3 object if cond holds, using S3 conditional
+// writes.
+func (a *Store) PutIf(ctx context.Context, key string, body []byte, cond docstore.Precondition) (string, error) {
+	if err := cond.Valid(); err != nil {
+		return "", err
+	}
+	return a.put(ctx, key, body, nil, cond)
+}
+
+func (a *Store) put(ctx context.Context, key string, body []byte, tags map[string]string, cond docstore.Precondition) (string, error) {
+	err := docstore.ValidKey(key)
+	if err != nil {
+		return "", err
+	}
 
 	input := &s3.PutObjectInput{
 		Body:   aws.ReadSeekCloser(bytes.NewReader(body)),
 		Bucket: aws.String(a.bucket),
 		Key:    aws.String(fmt.Sprintf("%s/%s", a.prefix, key)),
 	}
+	if len(tags) > 0 {
+		tagging := url.Values{}
+		for k, v := range tags {
+			tagging.Set(k, v)
+		}
+		input.Tagging = aws.String(tagging.Encode())
+	}
 
-	request, _ := a.svc.PutObjectRequest(input)
-	request.Retryer = client.DefaultRetryer{NumMaxRetries: 5}
-	request.SetContext(ctx)
-	err = request.Send()
+	req, result := a.svc.PutObjectRequest(input)
+	req.Retryer = client.DefaultRetryer{NumMaxRetries: 5}
+	req.SetContext(ctx)
+	// The SDK predates S3 conditional writes, so the conditions are set on
+	// the HTTP request before it is signed.
+	if cond != (docstore.Precondition{}) {
+		req.Handlers.Build.PushBack(func(r *request.Request) {
+			if cond.IfMatch != "" {
+				r.HTTPRequest.Header.Set("If-Match", cond.IfMatch)
+			}
+			if cond.IfNoneMatch != "" {
+				r.HTTPRequest.Header.Set("If-None-Match", cond.IfNoneMatch)
+			}
+		})
+	}
+	err = req.Send()
 	if err != nil {
-		return fmt.Errorf("s3 put: %w", err)
+		if reqErr, ok := err.(awserr.RequestFailure); ok && preconditionStatus(cond, reqErr.StatusCode()) {
+			return "", docstore.ErrPreconditionFailed
+		}
+		return "", fmt.Errorf("s3 put: %w", err)
 	}
 
-	return nil
+	return aws.StringValue(result.ETag), nil
+}
+
+// preconditionStatus returns true for the statuses of failed conditional
+// writes: 412, 409 when a concurrent conditional write won, and 404 when
+// the object of an if-match write is missing.
+func preconditionStatus(cond docstore.Precondition, code int) bool {
+	if cond == (docstore.Precondition{}) {
+		return false
+	}
+	switch code {
+	case http.StatusPreconditionFailed, http.StatusConflict:
+		return true
+	case http.StatusNotFound:
+		return cond.IfMatch != ""
+	}
+	return false
 }
 
 // Get reads bytes stored in an S3 document.
 func (a *Store) Get(ctx context.Context, key string) ([]byte, error) {
+	body, _, err := a.GetWithETag(ctx, key)
+	return body, err
+}
+
+// GetWithETag reads bytes stored in an S3 document and the object's ETag.
+func (a *Store) GetWithETag(ctx context.Context, key string) ([]byte, string, error) {
 	err := docstore.ValidKey(key)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 	input := &s3.GetObjectInput{
 		Bucket: aws.String(a.bucket),
@@ -98,16 +172,17 @@ func (a *Store) Get(ctx context.Context, key string) ([]byte, error) {
 		if aerr, ok := err.(awserr.Error); ok {
 			switch aerr.Code() {
 			case s3.ErrCodeNoSuchKey:
-				return nil, docstore.ErrRequestNotFound
+				return nil, "", docstore.ErrRequestNotFound
 			}
 		}
-		return nil, fmt.Errorf("s3 get: %w", err)
+		return nil, "", fmt.Errorf("s3 get: %w", err)
 	}
+	defer result.Body.Close()
 	body, err := io.ReadAll(result.Body)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read result body: %w", err)
+		return nil, "", fmt.Errorf("failed to read result body: %w", err)
 	}
-	return body, nil
+	return body, aws.StringValue(result.ETag), nil
 }
 
 // GetStreaming streams an S3 document's bytes into the supplied
@@ -165,3 +240,15 @@ func (a *Store) Delete(ctx context.Context, key string) error {
 	}
 	return nil
 }
+
+// Ping checks the bucket exists and is accessible with a HEAD bucket request.
+func (a *Store) Ping(ctx context.Context) error {
+	input := &s3.HeadBucketInput{
+		Bucket: aws.String(a.bucket),
+	}
+	_, err := a.svc.HeadBucketWithContext(ctx, input)
+	if err != nil {
+		return fmt.Errorf("s3 ping: %w", err)
+	}
+	return nil
+}
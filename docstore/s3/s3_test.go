@@ -0,0 +1,50 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package s3
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/luthersystems/svc/docstore"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPutIf(t *testing.T) {
+	etag := `"v1"`
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, http.MethodPut, r.Method)
+		require.Equal(t, "/bucket/prefix/a.json", r.URL.Path)
+		if r.Header.Get("If-None-Match") == "*" || (r.Header.Get("If-Match") != "" && r.Header.Get("If-Match") != etag) {
+			w.WriteHeader(http.StatusPreconditionFailed)
+			return
+		}
+		etag = `"v2"`
+		w.Header().Set("ETag", etag)
+	}))
+	defer srv.Close()
+	sess, err := session.NewSession(&aws.Config{
+		Region:           aws.String("us-east-1"),
+		Endpoint:         aws.String(srv.URL),
+		S3ForcePathStyle: aws.Bool(true),
+		Credentials:      credentials.NewStaticCredentials("id", "secret", ""),
+	})
+	require.NoError(t, err)
+	store, err := NewWithSession(sess, "bucket", "prefix")
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	_, err = store.PutIf(ctx, "a.json", []byte("{}"), docstore.IfNotExists)
+	require.ErrorIs(t, err, docstore.ErrPreconditionFailed)
+	_, err = store.PutIf(ctx, "a.json", []byte("{}"), docstore.Precondition{IfMatch: `"v0"`})
+	require.ErrorIs(t, err, docstore.ErrPreconditionFailed)
+	newETag, err := store.PutIf(ctx, "a.json", []byte("{}"), docstore.Precondition{IfMatch: `"v1"`})
+	require.NoError(t, err)
+	require.Equal(t, `"v2"`, newETag)
+	require.NoError(t, store.Put(ctx, "a.json", []byte("{}")))
+}
@@ -8,6 +8,7 @@ package grpclogging
 import (
 	"context"
 
+	"github.com/luthersystems/svc/logging"
 	"github.com/sirupsen/logrus"
 	"google.golang.org/grpc"
 )
@@ -29,7 +30,35 @@ func ReqID(ctx context.Context) string {
 // LogrusMethodInterceptor returns a middleware that associates logrus.Fields
 // with a handler's context.Context, accessible through func GetLogrusEntry(),
 // and automatically logs method metadata.
-func LogrusMethodInterceptor(base *logrus.Entry, t Timer, now Time) grpc.UnaryServerInterceptor {
+func LogrusMethodInterceptor(base *logrus.Entry, t Timer, now Time, opts ...InterceptorOption) grpc.UnaryServerInterceptor {
+	return MethodInterceptor(logging.NewLogrus(base), t, now, opts...)
+}
+
+// MethodInterceptor returns a middleware like LogrusMethodInterceptor which
+// logs with any logging.Logger, e.g. a zap logger.  Method level overrides
+// of a LevelController only apply to loggers created by logging.NewLogrus.
+// Options which cannot be applied, e.g. metrics which fail to register, are
+// ignored; use NewMethodInterceptor to handle their errors.
+func MethodInterceptor(base logging.Logger, t Timer, now Time, opts ...InterceptorOption) grpc.UnaryServerInterceptor {
+	cfg := newInterceptorConfig(opts)
 	// Middleware to log details about method calls.
-	return newGRPCMethodLogInterceptor(base, t, now)
+	return newGRPCMethodLogInterceptor(base, t, now, cfg)
+}
+
+// NewMethodInterceptor returns a middleware like MethodInterceptor, or an
+// error if any of the options cannot be applied.
+func NewMethodInterceptor(base logging.Logger, t Timer, now Time, opts ...InterceptorOption) (grpc.UnaryServerInterceptor, error) {
+	cfg := newInterceptorConfig(opts)
+	if cfg.err != nil {
+		return nil, cfg.err
+	}
+	return newGRPCMethodLogInterceptor(base, t, now, cfg), nil
+}
+
+func newInterceptorConfig(opts []InterceptorOption) *interceptorConfig {
+	cfg := &interceptorConfig{}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	return cfg
 }
@@ -0,0 +1,74 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package grpclogging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/luthersystems/svc/logging"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestMethodInterceptorZap(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	interceptor := MethodInterceptor(logging.NewZap(zap.New(core)), SimpleTimer(), nil)
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-request-id", "r1"))
+	_, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/pkg.Service/Get"}, func(ctx context.Context, req interface{}) (interface{}, error) {
+		require.Equal(t, "r1", ReqID(ctx))
+		AddLogrusField(ctx, "user", "u1")
+		GetLogger(ctx, logging.NewZap(zap.New(core))).Info("handling")
+		return nil, errors.New("boom")
+	})
+	require.Error(t, err)
+
+	entries := logs.AllUntimed()
+	require.Len(t, entries, 3)
+	require.Equal(t, "RPC method begin", entries[0].Message)
+	require.Equal(t, "handling", entries[1].Message)
+	require.Equal(t, "u1", entries[1].ContextMap()["user"])
+
+	called := entries[2]
+	require.Equal(t, "RPC method called", called.Message)
+	require.Equal(t, zapcore.InfoLevel, called.Level)
+	fields := called.ContextMap()
+	require.Equal(t, "/pkg.Service/Get", fields["rpc_method"])
+	require.Equal(t, "r1", fields["req_id"])
+	require.Equal(t, "u1", fields["user"])
+	require.Equal(t, "boom", fields["error"])
+	require.Contains(t, fields, "rpc_dur")
+}
+
+func TestWithRequestIDs(t *testing.T) {
+	n := 0
+	interceptor := LogrusMethodInterceptor(logrus.NewEntry(logrus.New()), UpperBoundTimer(time.Millisecond), RealTime(),
+		WithRequestIDs(func() string {
+			n++
+			return fmt.Sprintf("req-%d", n)
+		}))
+	var reqID string
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		reqID = ReqID(ctx)
+		return nil, nil
+	}
+	info := &grpc.UnaryServerInfo{FullMethod: "/pkg.Service/Get"}
+	_, err := interceptor(context.Background(), nil, info, handler)
+	require.NoError(t, err)
+	require.Equal(t, "req-1", reqID)
+
+	// Request IDs in metadata are kept.
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-request-id", "given"))
+	_, err = interceptor(ctx, nil, info, handler)
+	require.NoError(t, err)
+	require.Equal(t, "given", reqID)
+	require.Equal(t, 1, n)
+}
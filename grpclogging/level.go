@@ -0,0 +1,255 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package grpclogging
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// LevelController changes log levels at runtime, either for a logger as a
+// whole or for gRPC methods matching a prefix.  Changes may be temporary, in
+// which case they automatically revert after a given duration.
+type LevelController struct {
+	logger *logrus.Logger
+	now    func() time.Time
+
+	// writeMut serializes entries written by method loggers.
+	writeMut sync.Mutex
+
+	mut       sync.RWMutex
+	baseLevel logrus.Level
+	revert    *time.Timer
+	methods   map[string]*methodLevel
+}
+
+type methodLevel struct {
+	level   logrus.Level
+	expires time.Time
+	logger  *logrus.Logger
+}
+
+// NewLevelController returns a LevelController for logger.
+func NewLevelController(logger *logrus.Logger) *LevelController {
+	return &LevelController{
+		logger:    logger,
+		now:       time.Now,
+		baseLevel: logger.GetLevel(),
+		methods:   make(map[string]*methodLevel),
+	}
+}
+
+// SetLevel sets the logger's level.  If d is positive the level reverts to
+// the level in effect before the first temporary change after d elapses.
+func (c *LevelController) SetLevel(level logrus.Level, d time.Duration) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	if c.revert != nil {
+		c.revert.Stop()
+		c.revert = nil
+	} else {
+		c.baseLevel = c.logger.GetLevel()
+	}
+	c.logger.SetLevel(level)
+	if d <= 0 {
+		c.baseLevel = level
+		return
+	}
+	c.revert = time.AfterFunc(d, func() {
+		c.mut.Lock()
+		defer c.mut.Unlock()
+		c.logger.SetLevel(c.baseLevel)
+		c.revert = nil
+	})
+}
+
+// SetMethodLevel sets the log level used while handling gRPC methods whose
+// full name begins with prefix (e.g. "/pkg.Service/").  If d is positive the
+// override expires after d.  Setting a level on an existing prefix replaces
+// the previous override.
+func (c *LevelController) SetMethodLevel(prefix string, level logrus.Level, d time.Duration) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	ml := &methodLevel{level: level, logger: c.wrapLogger(level)}
+	if d > 0 {
+		ml.expires = c.now().Add(d)
+	}
+	c.methods[prefix] = ml
+}
+
+// ClearMethodLevel removes the override for prefix.
+func (c *LevelController) ClearMethodLevel(prefix string) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	delete(c.methods, prefix)
+}
+
+// wrapLogger returns a logger with a different level which forwards its
+// entries to the controlled logger, so they are written with its current
+// output, formatter, and hooks.
+func (c *LevelController) wrapLogger(level logrus.Level) *logrus.Logger {
+	hooks := make(logrus.LevelHooks)
+	hooks.Add(&forwardHook{c: c})
+	return &logrus.Logger{
+		Out:          io.Discard,
+		Hooks:        hooks,
+		Formatter:    discardFormatter{},
+		ReportCaller: c.logger.ReportCaller,
+		Level:        level,
+		ExitFunc:     c.logger.ExitFunc,
+	}
+}
+
+// forwardHook logs the entries of a method logger with the controlled
+// logger.
+type forwardHook struct {
+	c *LevelController
+}
+
+// Levels implements logrus.Hook.
+func (h *forwardHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+// Fire implements logrus.Hook.
+func (h *forwardHook) Fire(entry *logrus.Entry) error {
+	base := h.c.logger
+	e := base.WithFields(entry.Data).WithTime(entry.Time).WithContext(entry.Context)
+	if base.IsLevelEnabled(entry.Level) {
+		e.Log(entry.Level, entry.Message)
+		return nil
+	}
+	// The controlled logger drops entries below its level, so entries of
+	// a more verbose method level are written directly.  They are
+	// serialized with each other but not with the controlled logger's own
+	// writes, which hold its internal lock.
+	e.Level = entry.Level
+	e.Message = entry.Message
+	h.c.writeMut.Lock()
+	defer h.c.writeMut.Unlock()
+	if err := base.Hooks.Fire(e.Level, e); err != nil {
+		return err
+	}
+	b, err := base.Formatter.Format(e)
+	if err != nil {
+		return err
+	}
+	_, err = base.Out.Write(b)
+	return err
+}
+
+// discardFormatter skips formatting entries which are forwarded.
+type discardFormatter struct{}
+
+// Format implements logrus.Formatter.
+func (discardFormatter) Format(*logrus.Entry) ([]byte, error) {
+	return nil, nil
+}
+
+// methodLogger returns the logger overriding the level for method, if any.
+// The longest matching prefix wins.
+func (c *LevelController) methodLogger(method string) *logrus.Logger {
+	c.mut.RLock()
+	defer c.mut.RUnlock()
+	var match string
+	var logger *logrus.Logger
+	now := c.now()
+	for prefix, ml := range c.methods {
+		if !strings.HasPrefix(method, prefix) || len(prefix) < len(match) {
+			continue
+		}
+		if !ml.expires.IsZero() && now.After(ml.expires) {
+			continue
+		}
+		match = prefix
+		logger = ml.logger
+	}
+	return logger
+}
+
+type levelStatus struct {
+	Level   string                  `json:"level"`
+	Methods map[string]methodStatus `json:"methods,omitempty"`
+}
+
+type methodStatus struct {
+	Level   string     `json:"level"`
+	Expires *time.Time `json:"expires,omitempty"`
+}
+
+func (c *LevelController) status() *levelStatus {
+	c.mut.RLock()
+	defer c.mut.RUnlock()
+	s := &levelStatus{
+		Level:   c.logger.GetLevel().String(),
+		Methods: make(map[string]methodStatus, len(c.methods)),
+	}
+	now := c.now()
+	for prefix, ml := range c.methods {
+		if !ml.expires.IsZero() && now.After(ml.expires) {
+			continue
+		}
+		ms := methodStatus{Level: ml.level.String()}
+		if !ml.expires.IsZero() {
+			expires := ml.expires
+			ms.Expires = &expires
+		}
+		s.Methods[prefix] = ms
+	}
+	return s
+}
+
+// Handler returns an http.Handler which reports the current log levels (GET)
+// and changes them (POST).  POST requests take the query parameters "level"
+// (required), "method" (optional method prefix), and "duration" (optional, in
+// time.ParseDuration format).  The handler must only be served on a private
+// listener.
+func (c *LevelController) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			q := r.URL.Query()
+			level, err := logrus.ParseLevel(q.Get("level"))
+			if err != nil {
+				http.Error(w, "invalid level", http.StatusBadRequest)
+				return
+			}
+			var d time.Duration
+			if q.Get("duration") != "" {
+				d, err = time.ParseDuration(q.Get("duration"))
+				if err != nil {
+					http.Error(w, "invalid duration", http.StatusBadRequest)
+					return
+				}
+			}
+			if method := q.Get("method"); method != "" {
+				c.SetMethodLevel(method, level, d)
+			} else {
+				c.SetLevel(level, d)
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(c.status())
+	})
+}
+
+// methodLoggerCtxKey is a key to store a method specific logger within
+// context.
+type methodLoggerCtxKey struct{}
+
+// withMethodLogger returns a context using logger in place of the base
+// logger's in GetLogrusEntry.
+func withMethodLogger(ctx context.Context, logger *logrus.Logger) context.Context {
+	return context.WithValue(ctx, methodLoggerCtxKey{}, logger)
+}
+
+func ctxMethodLogger(ctx context.Context) *logrus.Logger {
+	logger, _ := ctx.Value(methodLoggerCtxKey{}).(*logrus.Logger)
+	return logger
+}
@@ -0,0 +1,57 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package grpclogging
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLevelController(t *testing.T) {
+	logger := logrus.New()
+	logger.SetLevel(logrus.InfoLevel)
+	c := NewLevelController(logger)
+
+	c.SetLevel(logrus.DebugLevel, 10*time.Millisecond)
+	require.Equal(t, logrus.DebugLevel, logger.GetLevel())
+	require.Eventually(t, func() bool {
+		return logger.GetLevel() == logrus.InfoLevel
+	}, time.Second, time.Millisecond)
+
+	c.SetMethodLevel("/pkg.Service/", logrus.TraceLevel, 0)
+	c.SetMethodLevel("/pkg.Service/Get", logrus.DebugLevel, 0)
+	require.Nil(t, c.methodLogger("/pkg.Other/Get"))
+	require.Equal(t, logrus.TraceLevel, c.methodLogger("/pkg.Service/List").GetLevel())
+	require.Equal(t, logrus.DebugLevel, c.methodLogger("/pkg.Service/Get").GetLevel())
+
+	ctx := withMethodLogger(NewContext(context.Background()), c.methodLogger("/pkg.Service/Get"))
+	entry := GetLogrusEntry(ctx, logrus.NewEntry(logger).WithField("app", "test"))
+	require.True(t, entry.Logger.IsLevelEnabled(logrus.DebugLevel))
+	require.Equal(t, "test", entry.Data["app"])
+	require.False(t, logger.IsLevelEnabled(logrus.DebugLevel))
+
+	// Method loggers write with the current output, formatter, and hooks
+	// of the controlled logger.
+	var out bytes.Buffer
+	logger.SetOutput(&out)
+	logger.SetFormatter(&logrus.JSONFormatter{DisableTimestamp: true})
+	hook := test.NewLocal(logger)
+	entry.Debug("verbose")
+	entry.Info("normal")
+	entry.Trace("dropped")
+	require.Equal(t, `{"app":"test","level":"debug","msg":"verbose"}`+"\n"+`{"app":"test","level":"info","msg":"normal"}`+"\n", out.String())
+	require.Len(t, hook.AllEntries(), 2)
+
+	now := time.Now()
+	c.now = func() time.Time { return now }
+	c.SetMethodLevel("/pkg.Temp/", logrus.DebugLevel, time.Minute)
+	require.NotNil(t, c.methodLogger("/pkg.Temp/Get"))
+	c.now = func() time.Time { return now.Add(2 * time.Minute) }
+	require.Nil(t, c.methodLogger("/pkg.Temp/Get"))
+}
@@ -0,0 +1,91 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package grpclogging
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/luthersystems/svc/promreg"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/status"
+)
+
+// ExemplarTraceIDLabel is the exemplar label holding the trace ID of an
+// observation.
+const ExemplarTraceIDLabel = "trace_id"
+
+// serverMetrics are server side method metrics populated by the interceptor.
+type serverMetrics struct {
+	duration *prometheus.HistogramVec
+	errors   *prometheus.CounterVec
+}
+
+// WithMetrics registers a per-method handler latency histogram and error
+// counter with reg and populates them from the interceptor.  This provides
+// server side timings without installing the grpc_prometheus server
+// interceptors.  Latencies of sampled requests carry the trace ID as an
+// exemplar, exposed when metrics are served in the OpenMetrics format.
+// Registering the metrics more than once with the same
+// registerer reuses the existing collectors.  If the metrics cannot be
+// registered the option is not applied and NewMethodInterceptor returns the
+// error.
+func WithMetrics(reg prometheus.Registerer) InterceptorOption {
+	return func(cfg *interceptorConfig) {
+		m, err := newServerMetrics(reg)
+		if err != nil {
+			cfg.fail(fmt.Errorf("method metrics: %w", err))
+			return
+		}
+		cfg.metrics = m
+	}
+}
+
+func newServerMetrics(reg prometheus.Registerer) (*serverMetrics, error) {
+	duration := prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "grpc_method_duration_seconds",
+			Help:    "Duration of gRPC method handlers, partitioned by method and status code.",
+			Buckets: prometheus.ExponentialBuckets(0.005, 2, 14),
+		},
+		[]string{"method", "code"},
+	)
+	errs := prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "grpc_method_errors_total",
+			Help: "How many gRPC method handlers returned an error, partitioned by method and status code.",
+		},
+		[]string{"method", "code"},
+	)
+	var err error
+	if duration, err = promreg.Register(reg, duration); err != nil {
+		return nil, err
+	}
+	if errs, err = promreg.Register(reg, errs); err != nil {
+		return nil, err
+	}
+	return &serverMetrics{duration: duration, errors: errs}, nil
+}
+
+// observe records a completed method call.
+func (m *serverMetrics) observe(ctx context.Context, method string, d time.Duration, err error) {
+	code := status.Code(err).String()
+	ObserveWithExemplar(ctx, m.duration.WithLabelValues(method, code), d.Seconds())
+	if err != nil {
+		m.errors.WithLabelValues(method, code).Inc()
+	}
+}
+
+// ObserveWithExemplar observes v with o.  If the span of ctx is sampled and o
+// supports exemplars, such as a histogram, the trace ID is attached as an
+// exemplar so that metrics backends can link the observation to its trace.
+func ObserveWithExemplar(ctx context.Context, o prometheus.Observer, v float64) {
+	sc := trace.SpanContextFromContext(ctx)
+	if eo, ok := o.(prometheus.ExemplarObserver); ok && sc.IsValid() && sc.IsSampled() {
+		eo.ObserveWithExemplar(v, prometheus.Labels{ExemplarTraceIDLabel: sc.TraceID().String()})
+		return
+	}
+	o.Observe(v)
+}
@@ -0,0 +1,146 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package grpclogging
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/luthersystems/svc/logging"
+	"github.com/luthersystems/svc/promreg"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestWithMetrics(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	interceptor := LogrusMethodInterceptor(logrus.NewEntry(logrus.New()), UpperBoundTimer(time.Millisecond), RealTime(), WithMetrics(reg))
+	// A second interceptor shares the registered collectors.
+	_ = LogrusMethodInterceptor(logrus.NewEntry(logrus.New()), UpperBoundTimer(time.Millisecond), RealTime(), WithMetrics(reg))
+
+	info := &grpc.UnaryServerInfo{FullMethod: "/pkg.Service/Get"}
+	ok := func(ctx context.Context, req interface{}) (interface{}, error) { return "ok", nil }
+	fail := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, status.Error(codes.NotFound, "missing")
+	}
+	_, err := interceptor(context.Background(), nil, info, ok)
+	require.NoError(t, err)
+	_, err = interceptor(context.Background(), nil, info, fail)
+	require.Error(t, err)
+
+	m, err := newServerMetrics(reg)
+	require.NoError(t, err)
+	require.Equal(t, 2, testutil.CollectAndCount(m.duration))
+	require.Equal(t, 1.0, testutil.ToFloat64(m.errors.WithLabelValues("/pkg.Service/Get", "NotFound")))
+}
+
+func TestNewMethodInterceptorError(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	// A collector with the same name but different labels conflicts.
+	require.NoError(t, reg.Register(prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "grpc_method_outcomes_total",
+		Help: "Conflicting.",
+	}, []string{"method"})))
+	base := logging.NewLogrus(logrus.NewEntry(logrus.New()))
+	_, err := NewMethodInterceptor(base, UpperBoundTimer(time.Millisecond), RealTime(), WithOutcomeMetrics(reg))
+	require.Error(t, err)
+	_, err = NewMethodInterceptor(base, UpperBoundTimer(time.Millisecond), RealTime(), WithMetrics(reg))
+	require.NoError(t, err)
+	// MethodInterceptor ignores the option.
+	require.NotNil(t, MethodInterceptor(base, UpperBoundTimer(time.Millisecond), RealTime(), WithOutcomeMetrics(reg)))
+}
+
+func TestWithOutcomeMetrics(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	interceptor := LogrusMethodInterceptor(logrus.NewEntry(logrus.New()), UpperBoundTimer(time.Millisecond), RealTime(), WithOutcomeMetrics(reg))
+	info := &grpc.UnaryServerInfo{FullMethod: "/pkg.Service/Get"}
+
+	ok := func(ctx context.Context, req interface{}) (interface{}, error) {
+		SetStage(ctx, StagePhylum)
+		SetStage(ctx, StageAfterPhylum)
+		return "ok", nil
+	}
+	_, err := interceptor(context.Background(), nil, info, ok)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	abandoned := func(ctx context.Context, req interface{}) (interface{}, error) {
+		SetStage(ctx, StagePhylum)
+		cancel()
+		<-ctx.Done()
+		// Give the stage capture a chance to run before leaving the stage.
+		time.Sleep(10 * time.Millisecond)
+		SetStage(ctx, StageAfterPhylum)
+		return nil, status.FromContextError(ctx.Err()).Err()
+	}
+	_, err = interceptor(ctx, nil, info, abandoned)
+	require.Error(t, err)
+
+	slow := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, status.Error(codes.DeadlineExceeded, "phylum timeout")
+	}
+	_, err = interceptor(context.Background(), nil, info, slow)
+	require.Error(t, err)
+
+	outcomes := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "grpc_method_outcomes_total",
+		Help: "How many gRPC method calls finished, partitioned by method, outcome and stage.",
+	}, []string{"method", "outcome", "stage"})
+	outcomes, err = promreg.Register(reg, outcomes)
+	require.NoError(t, err)
+	for _, want := range []struct {
+		outcome Outcome
+		stage   Stage
+	}{
+		{OutcomeCompleted, StageAfterPhylum},
+		{OutcomeCancelled, StagePhylum},
+		{OutcomeDeadlineExceeded, StageBeforePhylum},
+	} {
+		require.Equal(t, 1.0, testutil.ToFloat64(outcomes.WithLabelValues(info.FullMethod, string(want.outcome), string(want.stage))), want.outcome)
+	}
+	require.Equal(t, 3, testutil.CollectAndCount(outcomes))
+	require.Equal(t, Stage(""), GetStage(context.Background()))
+}
+
+func TestObserveWithExemplar(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	hist := prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "test_duration_seconds",
+		Help:    "Test durations.",
+		Buckets: []float64{1},
+	})
+	reg.MustRegister(hist)
+
+	traceID := trace.TraceID{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}
+	sampled := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    traceID,
+		SpanID:     trace.SpanID{1, 2, 3, 4, 5, 6, 7, 8},
+		TraceFlags: trace.FlagsSampled,
+	}))
+
+	exemplar := func() *dto.Exemplar {
+		mfs, err := reg.Gather()
+		require.NoError(t, err)
+		require.Len(t, mfs, 1)
+		return mfs[0].GetMetric()[0].GetHistogram().GetBucket()[0].GetExemplar()
+	}
+
+	// Observations without a sampled span carry no exemplar.
+	ObserveWithExemplar(context.Background(), hist, 0.5)
+	require.Nil(t, exemplar())
+
+	ObserveWithExemplar(sampled, hist, 0.5)
+	ex := exemplar()
+	require.NotNil(t, ex)
+	require.Equal(t, ExemplarTraceIDLabel, ex.GetLabel()[0].GetName())
+	require.Equal(t, traceID.String(), ex.GetLabel()[0].GetValue())
+	require.Equal(t, 0.5, ex.GetValue())
+}
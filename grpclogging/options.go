@@ -0,0 +1,57 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package grpclogging
+
+import (
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// InterceptorOption configures optional behavior of LogrusMethodInterceptor.
+type InterceptorOption func(*interceptorConfig)
+
+type interceptorConfig struct {
+	levels   *LevelController
+	metrics  *serverMetrics
+	outcomes *prometheus.CounterVec
+
+	slowThresholds SlowThresholds
+	slow           *prometheus.CounterVec
+
+	newRequestID func() string
+
+	// err holds the errors of options which could not be applied.
+	err error
+}
+
+// requestID returns a new request ID.
+func (cfg *interceptorConfig) requestID() string {
+	if cfg.newRequestID != nil {
+		return cfg.newRequestID()
+	}
+	return uuid.New().String()
+}
+
+// fail records the error of an option which could not be applied.
+func (cfg *interceptorConfig) fail(err error) {
+	cfg.err = errors.Join(cfg.err, err)
+}
+
+// WithLevelController applies method level overrides configured on c to
+// requests handled by the interceptor.
+func WithLevelController(c *LevelController) InterceptorOption {
+	return func(cfg *interceptorConfig) {
+		cfg.levels = c
+	}
+}
+
+// WithRequestIDs generates the IDs of requests which carry no x-request-id
+// metadata with newID, e.g. a reproducible sequence in tests.  Defaults to
+// random UUIDs.
+func WithRequestIDs(newID func() string) InterceptorOption {
+	return func(cfg *interceptorConfig) {
+		cfg.newRequestID = newID
+	}
+}
@@ -5,9 +5,10 @@ package grpclogging
 import (
 	"context"
 	"strings"
+	"sync"
 	"time"
 
-	"github.com/google/uuid"
+	"github.com/luthersystems/svc/logging"
 	"github.com/sirupsen/logrus"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/trace"
@@ -24,7 +25,7 @@ func isHealthCheck(method string) bool {
 // the grpc method being handled and its duration. A debug message is printed
 // at the beginning of a handler's execution and its duration is logged at the
 // end
-func newGRPCMethodLogInterceptor(base *logrus.Entry, t Timer, lutherTime Time) grpc.UnaryServerInterceptor {
+func newGRPCMethodLogInterceptor(base logging.Logger, t Timer, lutherTime Time, cfg *interceptorConfig) grpc.UnaryServerInterceptor {
 	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
 		var nowFn func() time.Time
 		if lutherTime != nil {
@@ -32,8 +33,9 @@ func newGRPCMethodLogInterceptor(base *logrus.Entry, t Timer, lutherTime Time) g
 		}
 		// The start time includes setup for and logging
 		stopTimer := t.StartTimer(nowFn)
+		start := time.Now()
 
-		reqID := uuid.New().String()
+		var reqID string
 		md, ok := metadata.FromIncomingContext(ctx)
 		if ok {
 			mdID := md["x-request-id"]
@@ -41,11 +43,30 @@ func newGRPCMethodLogInterceptor(base *logrus.Entry, t Timer, lutherTime Time) g
 				reqID = mdID[0]
 			}
 		}
+		if reqID == "" {
+			reqID = cfg.requestID()
+		}
 		ctx = newContextWithFields(ctx, logrus.Fields{
 			"rpc_method": info.FullMethod,
 			"req_id":     reqID,
 		})
-		GetLogrusEntry(ctx, base).Debug("RPC method begin")
+		ctx = withTraceContext(ctx, md)
+		if cfg.levels != nil {
+			if logger := cfg.levels.methodLogger(info.FullMethod); logger != nil {
+				ctx = withMethodLogger(ctx, logger)
+			}
+		}
+		ctx, stages := newStageContext(ctx)
+		// Capture the stage at the moment the request is cancelled or
+		// exceeds its deadline, as the handler may continue afterwards.
+		var doneStage Stage
+		var doneMut sync.Mutex
+		stopDone := context.AfterFunc(ctx, func() {
+			doneMut.Lock()
+			defer doneMut.Unlock()
+			doneStage = stages.get()
+		})
+		GetLogger(ctx, base).Debug("RPC method begin")
 
 		span := trace.SpanFromContext(ctx)
 		span.SetAttributes(attribute.String("app.request.id", reqID))
@@ -53,20 +74,49 @@ func newGRPCMethodLogInterceptor(base *logrus.Entry, t Timer, lutherTime Time) g
 		// Defer to the method's handler and save the results to pass through
 		// for the interceptor's caller.
 		resp, err := handler(ctx, req)
+		if cfg.metrics != nil {
+			cfg.metrics.observe(ctx, info.FullMethod, time.Since(start), err)
+		}
+		stage := stages.get()
+		if !stopDone() {
+			doneMut.Lock()
+			if doneStage != "" {
+				stage = doneStage
+			}
+			doneMut.Unlock()
+		}
+		outcome := outcomeOf(ctx, err)
+		if cfg.outcomes != nil {
+			cfg.outcomes.WithLabelValues(info.FullMethod, string(outcome), string(stage)).Inc()
+		}
 
-		// Create a logrus.Entry with additional (and potentially modified)
-		// fields to describe the completed RPC.
-		mLog := GetLogrusEntry(ctx, base)
+		// Create a logger with additional (and potentially modified) fields
+		// to describe the completed RPC.
+		mLog := GetLogger(ctx, base)
 		if err != nil {
 			mLog = mLog.WithError(err)
 		}
+		if outcome != OutcomeCompleted {
+			mLog = mLog.WithFields(logging.Fields{
+				"rpc_outcome": outcome,
+				"rpc_stage":   stage,
+			})
+		}
 		// Compute call duration as late as possible to give the most accurate
 		// representation of the call duration (excluding network
 		// transmission).
 		dur := stopTimer()
 		mLog = mLog.WithField("rpc_dur", dur)
 
-		if isHealthCheck(info.FullMethod) {
+		if threshold := cfg.slowThresholds.threshold(info.FullMethod); threshold > 0 && dur > threshold {
+			if cfg.slow != nil {
+				cfg.slow.WithLabelValues(info.FullMethod).Inc()
+			}
+			mLog.WithFields(logging.Fields{
+				"slow":           true,
+				"slow_threshold": threshold,
+			}).Warn("RPC method called")
+		} else if isHealthCheck(info.FullMethod) {
 			mLog.Debug("RPC method called")
 		} else {
 			mLog.Info("RPC method called")
@@ -0,0 +1,147 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package grpclogging
+
+import (
+	"fmt"
+	"regexp"
+	"sync"
+
+	"github.com/luthersystems/svc/promreg"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Redacted replaces log field values removed by the scrub policy.
+const Redacted = "[redacted]"
+
+// Reasons a field was scrubbed.
+const (
+	scrubKey   = "key"
+	scrubValue = "value"
+)
+
+// Redactor returns s with sensitive data replaced, e.g. with Redacted.
+type Redactor func(s string) string
+
+var (
+	emailRegexp = regexp.MustCompile(`[a-zA-Z0-9._%+-]+@[a-zA-Z0-9.-]+\.[a-zA-Z]{2,}`)
+	tokenRegexp = regexp.MustCompile(`eyJ[a-zA-Z0-9_-]*\.[a-zA-Z0-9_-]+\.[a-zA-Z0-9_-]*|(?i)\b(bearer|basic)\s+[a-zA-Z0-9._~+/=-]+`)
+)
+
+// RedactEmails replaces email addresses in s.
+func RedactEmails(s string) string {
+	return emailRegexp.ReplaceAllString(s, Redacted)
+}
+
+// RedactTokens replaces JSON web tokens and bearer or basic credentials in
+// s.
+func RedactTokens(s string) string {
+	return tokenRegexp.ReplaceAllString(s, Redacted)
+}
+
+// ScrubPolicy removes sensitive data from log fields, e.g. tokens or emails
+// added with AddLogrusField, before GetLogrusFields returns them for
+// emission.
+type ScrubPolicy struct {
+	// DenyKeys are regular expressions matching the keys of fields whose
+	// value is always replaced with Redacted, e.g. "(?i)password".
+	DenyKeys []string
+	// Redactors are applied in order to the string values of other fields.
+	Redactors []Redactor
+}
+
+// DefaultScrubPolicy returns a policy redacting fields with credential
+// keys, and emails and tokens in other fields.
+func DefaultScrubPolicy() *ScrubPolicy {
+	return &ScrubPolicy{
+		DenyKeys:  []string{`(?i)(password|secret|token|authorization|cookie|api[_-]?key)`},
+		Redactors: []Redactor{RedactEmails, RedactTokens},
+	}
+}
+
+// fieldScrubber applies a compiled scrub policy.
+type fieldScrubber struct {
+	denyKeys  []*regexp.Regexp
+	redactors []Redactor
+	scrubbed  *prometheus.CounterVec
+}
+
+var (
+	scrubberMut sync.RWMutex
+	scrubber    *fieldScrubber
+)
+
+// SetScrubPolicy applies p to the fields returned by GetLogrusFields, and so
+// to the fields of loggers returned by GetLogrusEntry and GetLogger.  A nil
+// p disables scrubbing.  If reg is not nil a counter of scrubbed fields
+// partitioned by reason (key or value), incremented each time a field is
+// scrubbed, is registered with reg.
+func SetScrubPolicy(p *ScrubPolicy, reg prometheus.Registerer) error {
+	if p == nil {
+		scrubberMut.Lock()
+		scrubber = nil
+		scrubberMut.Unlock()
+		return nil
+	}
+	s := &fieldScrubber{redactors: p.Redactors}
+	for _, k := range p.DenyKeys {
+		re, err := regexp.Compile(k)
+		if err != nil {
+			return fmt.Errorf("scrub policy: invalid deny key %q: %w", k, err)
+		}
+		s.denyKeys = append(s.denyKeys, re)
+	}
+	if reg != nil {
+		scrubbed := prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "log_fields_scrubbed_total",
+				Help: "How many log fields were scrubbed before emission, partitioned by reason.",
+			},
+			[]string{"reason"},
+		)
+		scrubbed, err := promreg.Register(reg, scrubbed)
+		if err != nil {
+			return fmt.Errorf("scrub policy metrics: %w", err)
+		}
+		s.scrubbed = scrubbed
+	}
+	scrubberMut.Lock()
+	scrubber = s
+	scrubberMut.Unlock()
+	return nil
+}
+
+func getScrubber() *fieldScrubber {
+	scrubberMut.RLock()
+	defer scrubberMut.RUnlock()
+	return scrubber
+}
+
+// scrub returns the value of a field, scrubbed according to the policy.
+func (s *fieldScrubber) scrub(key string, val interface{}) interface{} {
+	for _, re := range s.denyKeys {
+		if re.MatchString(key) {
+			s.inc(scrubKey)
+			return Redacted
+		}
+	}
+	str, ok := val.(string)
+	if !ok {
+		return val
+	}
+	scrubbed := str
+	for _, r := range s.redactors {
+		scrubbed = r(scrubbed)
+	}
+	if scrubbed != str {
+		s.inc(scrubValue)
+		return scrubbed
+	}
+	return val
+}
+
+func (s *fieldScrubber) inc(reason string) {
+	if s.scrubbed != nil {
+		s.scrubbed.WithLabelValues(reason).Inc()
+	}
+}
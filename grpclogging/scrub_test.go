@@ -0,0 +1,69 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package grpclogging
+
+import (
+	"context"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedactors(t *testing.T) {
+	require.Equal(t, "user [redacted] failed", RedactEmails("user alice@example.com failed"))
+	require.Equal(t, "auth [redacted]", RedactTokens("auth Bearer abc.def-123"))
+	require.Equal(t, "jwt [redacted]", RedactTokens("jwt eyJhbGciOi.eyJzdWIiOi.sig_1"))
+	require.Equal(t, "nothing to see", RedactTokens(RedactEmails("nothing to see")))
+}
+
+func TestSetScrubPolicy(t *testing.T) {
+	t.Cleanup(func() {
+		require.NoError(t, SetScrubPolicy(nil, nil))
+	})
+	require.Error(t, SetScrubPolicy(&ScrubPolicy{DenyKeys: []string{"("}}, nil))
+
+	ctx := NewContext(context.Background())
+	AddLogrusFields(ctx, logrus.Fields{
+		"req_id":       "r1",
+		"user":         "alice@example.com",
+		"api_key":      "k1",
+		"Access-Token": 42,
+		"count":        3,
+	})
+	// Fields are not scrubbed without a policy.
+	require.Equal(t, "alice@example.com", GetLogrusFields(ctx)["user"])
+
+	reg := prometheus.NewRegistry()
+	require.NoError(t, SetScrubPolicy(DefaultScrubPolicy(), reg))
+	require.Equal(t, logrus.Fields{
+		"req_id":       "r1",
+		"user":         Redacted,
+		"api_key":      Redacted,
+		"Access-Token": Redacted,
+		"count":        3,
+	}, GetLogrusFields(ctx))
+	// The stored fields are not modified.
+	require.Equal(t, "alice@example.com", ctxGetLogMetadataValue(ctx, "user"))
+
+	scrubbed, err := reg.Gather()
+	require.NoError(t, err)
+	require.Len(t, scrubbed, 1)
+	s := getScrubber()
+	require.Equal(t, 2.0, testutil.ToFloat64(s.scrubbed.WithLabelValues(scrubKey)))
+	require.Equal(t, 1.0, testutil.ToFloat64(s.scrubbed.WithLabelValues(scrubValue)))
+
+	// Registering the policy again reuses the metric.
+	require.NoError(t, SetScrubPolicy(&ScrubPolicy{DenyKeys: []string{"^user$"}}, reg))
+	fields := GetLogrusFields(ctx)
+	require.Equal(t, Redacted, fields["user"])
+	require.Equal(t, "k1", fields["api_key"])
+	require.Equal(t, 3.0, testutil.ToFloat64(getScrubber().scrubbed.WithLabelValues(scrubKey)))
+}
+
+func ctxGetLogMetadataValue(ctx context.Context, key string) interface{} {
+	val, _ := ctxGetLogMetadata(ctx).Load(key)
+	return val
+}
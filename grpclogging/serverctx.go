@@ -6,6 +6,7 @@ import (
 	"context"
 	"sync"
 
+	"github.com/luthersystems/svc/logging"
 	"github.com/sirupsen/logrus"
 )
 
@@ -30,15 +31,20 @@ func ctxGetLogMetadata(ctx context.Context) *sync.Map {
 	return val
 }
 
-// GetLogrusFields returns stored logging metadata.
+// GetLogrusFields returns stored logging metadata, scrubbed according to the
+// policy set with SetScrubPolicy.
 func GetLogrusFields(ctx context.Context) logrus.Fields {
 	fields := logrus.Fields{}
 	fieldMap := ctxGetLogMetadata(ctx)
 	if fieldMap == nil {
 		return fields
 	}
+	s := getScrubber()
 	fieldMap.Range(func(key, val interface{}) bool {
 		if keyStr, ok := key.(string); ok {
+			if s != nil {
+				val = s.scrub(keyStr, val)
+			}
 			fields[keyStr] = val
 		}
 		return true
@@ -46,8 +52,13 @@ func GetLogrusFields(ctx context.Context) logrus.Fields {
 	return fields
 }
 
-// GetLogrusEntry returns stored logging metadata as a logrus Entry.
+// GetLogrusEntry returns stored logging metadata as a logrus Entry.  If the
+// level for the current method has been overridden by a LevelController the
+// returned entry uses a logger with the overridden level.
 func GetLogrusEntry(ctx context.Context, base *logrus.Entry) *logrus.Entry {
+	if logger := ctxMethodLogger(ctx); logger != nil {
+		base = logrus.NewEntry(logger).WithFields(base.Data)
+	}
 	fields := GetLogrusFields(ctx)
 	if fields != nil {
 		return base.WithFields(fields)
@@ -55,6 +66,19 @@ func GetLogrusEntry(ctx context.Context, base *logrus.Entry) *logrus.Entry {
 	return base
 }
 
+// GetLogger returns stored logging metadata added to base.  Method level
+// overrides apply as in GetLogrusEntry to loggers created by
+// logging.NewLogrus.
+func GetLogger(ctx context.Context, base logging.Logger) logging.Logger {
+	if entry, ok := logging.LogrusEntry(base); ok {
+		return logging.NewLogrus(GetLogrusEntry(ctx, entry))
+	}
+	if fields := GetLogrusFields(ctx); len(fields) > 0 {
+		return base.WithFields(logging.Fields(fields))
+	}
+	return base
+}
+
 // AddLogrusField adds a log field to the supplied context for later retrieval.
 // The context must have been previously initialized with log metadata via
 // `LogrusMethodInterceptor` or `NewContext`.
@@ -0,0 +1,67 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package grpclogging
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/luthersystems/svc/promreg"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// SlowThresholds maps gRPC method prefixes (e.g. "/pkg.Service/" or
+// "/pkg.Service/Get") to the duration after which a request is slow.  The
+// longest matching prefix wins, and the prefix "*" applies to methods
+// matching no other prefix.
+type SlowThresholds map[string]time.Duration
+
+// Valid returns an error if a threshold is not positive.
+func (s SlowThresholds) Valid() error {
+	for prefix, d := range s {
+		if d <= 0 {
+			return fmt.Errorf("slow threshold %s: must be positive", prefix)
+		}
+	}
+	return nil
+}
+
+// threshold returns the slow threshold of method, or zero.
+func (s SlowThresholds) threshold(method string) time.Duration {
+	var match string
+	threshold := s["*"]
+	for prefix, d := range s {
+		if prefix == "*" || !strings.HasPrefix(method, prefix) || len(prefix) < len(match) {
+			continue
+		}
+		match = prefix
+		threshold = d
+	}
+	return threshold
+}
+
+// WithSlowThresholds logs requests which take longer than their method's
+// threshold at warning level with the field slow=true.  If reg is not nil a
+// counter of slow requests partitioned by method is registered with reg.
+func WithSlowThresholds(thresholds SlowThresholds, reg prometheus.Registerer) InterceptorOption {
+	return func(cfg *interceptorConfig) {
+		cfg.slowThresholds = thresholds
+		if reg == nil {
+			return
+		}
+		slow := prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "grpc_method_slow_total",
+				Help: "How many gRPC method calls exceeded their slow threshold, partitioned by method.",
+			},
+			[]string{"method"},
+		)
+		slow, err := promreg.Register(reg, slow)
+		if err != nil {
+			cfg.fail(fmt.Errorf("slow request metrics: %w", err))
+			return
+		}
+		cfg.slow = slow
+	}
+}
@@ -0,0 +1,75 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package grpclogging
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+)
+
+// stepTime advances by step each time it is read.
+type stepTime struct {
+	now  time.Time
+	step time.Duration
+}
+
+func (t *stepTime) Now() time.Time {
+	t.now = t.now.Add(t.step)
+	return t.now
+}
+
+func TestSlowThresholds(t *testing.T) {
+	thresholds := SlowThresholds{
+		"*":                 time.Second,
+		"/pkg.Service/":     100 * time.Millisecond,
+		"/pkg.Service/List": 500 * time.Millisecond,
+	}
+	require.NoError(t, thresholds.Valid())
+	require.Error(t, SlowThresholds{"/pkg.Service/": 0}.Valid())
+	require.Equal(t, 500*time.Millisecond, thresholds.threshold("/pkg.Service/List"))
+	require.Equal(t, 100*time.Millisecond, thresholds.threshold("/pkg.Service/Get"))
+	require.Equal(t, time.Second, thresholds.threshold("/other.Service/Get"))
+	require.Zero(t, SlowThresholds(nil).threshold("/pkg.Service/Get"))
+}
+
+func TestWithSlowThresholds(t *testing.T) {
+	logger, hook := test.NewNullLogger()
+	logger.SetLevel(logrus.DebugLevel)
+	reg := prometheus.NewRegistry()
+	clock := &stepTime{now: time.Now(), step: 200 * time.Millisecond}
+	interceptor := LogrusMethodInterceptor(logrus.NewEntry(logger), SimpleTimer(), clock,
+		WithSlowThresholds(SlowThresholds{"/pkg.Service/List": 500 * time.Millisecond, "/pkg.Service/": 100 * time.Millisecond}, reg))
+	ok := func(ctx context.Context, req interface{}) (interface{}, error) { return "ok", nil }
+
+	_, err := interceptor(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: "/pkg.Service/Get"}, ok)
+	require.NoError(t, err)
+	entry := hook.LastEntry()
+	require.Equal(t, logrus.WarnLevel, entry.Level)
+	require.Equal(t, true, entry.Data["slow"])
+	require.Equal(t, 100*time.Millisecond, entry.Data["slow_threshold"])
+
+	_, err = interceptor(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: "/pkg.Service/List"}, ok)
+	require.NoError(t, err)
+	entry = hook.LastEntry()
+	require.Equal(t, logrus.InfoLevel, entry.Level)
+	require.NotContains(t, entry.Data, "slow")
+
+	// only the slow method is counted.
+	count, err := testutil.GatherAndCount(reg, "grpc_method_slow_total")
+	require.NoError(t, err)
+	require.Equal(t, 1, count)
+	require.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+# HELP grpc_method_slow_total How many gRPC method calls exceeded their slow threshold, partitioned by method.
+# TYPE grpc_method_slow_total counter
+grpc_method_slow_total{method="/pkg.Service/Get"} 1
+`), "grpc_method_slow_total"))
+}
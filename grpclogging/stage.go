@@ -0,0 +1,122 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package grpclogging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/luthersystems/svc/promreg"
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Stage is the stage of request handling, relative to the phylum call.
+type Stage string
+
+const (
+	// StageBeforePhylum is the stage before the phylum is called.
+	StageBeforePhylum Stage = "before_phylum"
+	// StagePhylum is the stage while the phylum is called.
+	StagePhylum Stage = "phylum"
+	// StageAfterPhylum is the stage after the phylum has responded.
+	StageAfterPhylum Stage = "after_phylum"
+)
+
+// Outcome is how a request finished.
+type Outcome string
+
+const (
+	// OutcomeCompleted is a request handled before its context was done,
+	// whether or not the handler returned an error.
+	OutcomeCompleted Outcome = "completed"
+	// OutcomeCancelled is a request abandoned by its client.
+	OutcomeCancelled Outcome = "cancelled"
+	// OutcomeDeadlineExceeded is a request which ran out of time.
+	OutcomeDeadlineExceeded Outcome = "deadline_exceeded"
+)
+
+type stageCtxKey struct{}
+
+// stageTracker records the current stage of a request.
+type stageTracker struct {
+	mut   sync.Mutex
+	stage Stage
+}
+
+func (s *stageTracker) get() Stage {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	return s.stage
+}
+
+func (s *stageTracker) set(stage Stage) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	s.stage = stage
+}
+
+func newStageContext(ctx context.Context) (context.Context, *stageTracker) {
+	s := &stageTracker{stage: StageBeforePhylum}
+	return context.WithValue(ctx, stageCtxKey{}, s), s
+}
+
+// SetStage records the stage of the request handled with ctx, so requests
+// which are cancelled or exceed their deadline are attributed to the stage
+// they were in.  The context must have been initialized by
+// LogrusMethodInterceptor, otherwise SetStage does nothing.
+func SetStage(ctx context.Context, stage Stage) {
+	if s, ok := ctx.Value(stageCtxKey{}).(*stageTracker); ok {
+		s.set(stage)
+	}
+}
+
+// GetStage returns the stage of the request handled with ctx.
+func GetStage(ctx context.Context) Stage {
+	if s, ok := ctx.Value(stageCtxKey{}).(*stageTracker); ok {
+		return s.get()
+	}
+	return ""
+}
+
+// outcomeOf classifies a finished request.
+func outcomeOf(ctx context.Context, err error) Outcome {
+	switch {
+	case errors.Is(ctx.Err(), context.Canceled):
+		return OutcomeCancelled
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		return OutcomeDeadlineExceeded
+	}
+	switch status.Code(err) {
+	case codes.Canceled:
+		return OutcomeCancelled
+	case codes.DeadlineExceeded:
+		return OutcomeDeadlineExceeded
+	}
+	return OutcomeCompleted
+}
+
+// WithOutcomeMetrics registers a counter of requests partitioned by method,
+// outcome (completed, cancelled or deadline_exceeded) and the stage the
+// request was in when it finished or its context was done.  This
+// distinguishes clients abandoning requests from slow backends.
+func WithOutcomeMetrics(reg prometheus.Registerer) InterceptorOption {
+	return func(cfg *interceptorConfig) {
+		outcomes := prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "grpc_method_outcomes_total",
+				Help: "How many gRPC method calls finished, partitioned by method, outcome and stage.",
+			},
+			[]string{"method", "outcome", "stage"},
+		)
+		outcomes, err := promreg.Register(reg, outcomes)
+		if err != nil {
+			cfg.fail(fmt.Errorf("outcome metrics: %w", err))
+			return
+		}
+		cfg.outcomes = outcomes
+	}
+}
@@ -0,0 +1,58 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package grpclogging
+
+import (
+	"context"
+
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/metadata"
+)
+
+// metadataCarrier adapts incoming gRPC metadata to a propagation carrier.
+type metadataCarrier metadata.MD
+
+var _ propagation.TextMapCarrier = metadataCarrier(nil)
+
+func (c metadataCarrier) Get(key string) string {
+	if v := metadata.MD(c).Get(key); len(v) > 0 {
+		return v[0]
+	}
+	return ""
+}
+
+func (c metadataCarrier) Set(key string, value string) {
+	metadata.MD(c).Set(key, value)
+}
+
+func (c metadataCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for k := range c {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// withTraceContext extracts the W3C trace context (traceparent) of the
+// incoming metadata when ctx has no span, e.g. because the otelgrpc handler
+// is not installed.  The remote span context is attached to ctx, and its
+// trace and span IDs are added to the log fields, so that logs and
+// exemplars still correlate with the caller's trace in partially
+// instrumented environments.
+func withTraceContext(ctx context.Context, md metadata.MD) context.Context {
+	if trace.SpanContextFromContext(ctx).IsValid() || len(md) == 0 {
+		return ctx
+	}
+	ctx = propagation.TraceContext{}.Extract(ctx, metadataCarrier(md))
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		return ctx
+	}
+	AddLogrusFields(ctx, logrus.Fields{
+		"trace_id": sc.TraceID().String(),
+		"span_id":  sc.SpanID().String(),
+	})
+	return ctx
+}
@@ -0,0 +1,55 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package grpclogging
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestTraceContextFallback(t *testing.T) {
+	interceptor := LogrusMethodInterceptor(logrus.NewEntry(logrus.New()), UpperBoundTimer(time.Millisecond), RealTime())
+	info := &grpc.UnaryServerInfo{FullMethod: "/pkg.Service/Get"}
+	var fields logrus.Fields
+	var sc trace.SpanContext
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		fields = GetLogrusFields(ctx)
+		sc = trace.SpanContextFromContext(ctx)
+		return "ok", nil
+	}
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(
+		"traceparent", "00-0102030405060708090a0b0c0d0e0f10-0102030405060708-01"))
+	_, err := interceptor(ctx, nil, info, handler)
+	require.NoError(t, err)
+	require.Equal(t, "0102030405060708090a0b0c0d0e0f10", fields["trace_id"])
+	require.Equal(t, "0102030405060708", fields["span_id"])
+	require.True(t, sc.IsRemote())
+	require.True(t, sc.IsSampled())
+
+	// An existing span takes precedence.
+	traceID, _ := trace.TraceIDFromHex("a1a2a3a4a5a6a7a8a9aaabacadaeafb0")
+	spanID, _ := trace.SpanIDFromHex("b1b2b3b4b5b6b7b8")
+	ctx = trace.ContextWithSpanContext(ctx, trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: traceID,
+		SpanID:  spanID,
+	}))
+	_, err = interceptor(ctx, nil, info, handler)
+	require.NoError(t, err)
+	require.NotContains(t, fields, "trace_id")
+	require.Equal(t, traceID, sc.TraceID())
+
+	// Malformed trace contexts are ignored.
+	ctx = metadata.NewIncomingContext(context.Background(), metadata.Pairs("traceparent", "garbage"))
+	_, err = interceptor(ctx, nil, info, handler)
+	require.NoError(t, err)
+	require.NotContains(t, fields, "trace_id")
+	require.False(t, sc.IsValid())
+}
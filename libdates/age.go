@@ -0,0 +1,101 @@
+package libdates
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrFutureDate is returned by AgeAt when the date of birth is after the
+// reference date.
+var ErrFutureDate = errors.New("date of birth is after reference date")
+
+// Feb29Policy determines when the anniversary of February 29 falls in common
+// years.
+type Feb29Policy int
+
+const (
+	// Feb29OnFeb28 observes the anniversary on February 28.  This is the
+	// default.
+	Feb29OnFeb28 Feb29Policy = iota
+	// Feb29OnMar1 observes the anniversary on March 1, as required by
+	// some jurisdictions when computing the age of majority.
+	Feb29OnMar1
+)
+
+// ParseFeb29Policy parses the name of a Feb29Policy: "feb28" or "mar1".
+func ParseFeb29Policy(s string) (Feb29Policy, error) {
+	switch s {
+	case "feb28":
+		return Feb29OnFeb28, nil
+	case "mar1":
+		return Feb29OnMar1, nil
+	}
+	return 0, fmt.Errorf("invalid feb29 policy: %q", s)
+}
+
+// Option configures anniversary calculations.
+type Option func(*options)
+
+type options struct {
+	feb29 Feb29Policy
+}
+
+func newOptions(opts []Option) *options {
+	o := &options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// WithFeb29Policy sets when the anniversary of February 29 falls in common
+// years.
+func WithFeb29Policy(p Feb29Policy) Option {
+	return func(o *options) {
+		o.feb29 = p
+	}
+}
+
+// anniversary returns the day number of the anniversary of date in year.
+func (o *options) anniversary(date time.Time, year int) int64 {
+	_, m, d := date.Date()
+	if m == time.February && d == 29 && !IsLeapYear(year) {
+		if o.feb29 == Feb29OnMar1 {
+			return daysFromCivil(year, 3, 1)
+		}
+		return daysFromCivil(year, 2, 28)
+	}
+	return daysFromCivil(year, int(m), d)
+}
+
+// AgeAt returns the number of completed years between dob and asOf.  Only
+// the calendar dates of dob and asOf, in their own locations, are
+// considered.
+func AgeAt(dob, asOf time.Time, opts ...Option) (int, error) {
+	if civilDay(asOf) < civilDay(dob) {
+		return 0, ErrFutureDate
+	}
+	o := newOptions(opts)
+	age := asOf.Year() - dob.Year()
+	if civilDay(asOf) < o.anniversary(dob, asOf.Year()) {
+		age--
+	}
+	return age, nil
+}
+
+// NextAnniversary returns the first anniversary of date which falls after
+// the calendar date of after, at midnight UTC.  The date itself is not an
+// anniversary.
+func NextAnniversary(date, after time.Time, opts ...Option) time.Time {
+	o := newOptions(opts)
+	year := after.Year()
+	if year <= date.Year() {
+		year = date.Year() + 1
+	}
+	day := o.anniversary(date, year)
+	if day <= civilDay(after) {
+		day = o.anniversary(date, year+1)
+	}
+	return dayTime(day)
+}
@@ -0,0 +1,87 @@
+package libdates
+
+import "time"
+
+// Dates are handled as civil days in the proleptic Gregorian calendar,
+// counted from 1970-01-01.  The conversions follow Howard Hinnant's
+// days_from_civil and civil_from_days algorithms, which are exact for all
+// years and independent of time zones and daylight saving.
+
+// daysFromCivil returns the day number of year y, month m, day d.
+func daysFromCivil(y, m, d int) int64 {
+	yy := int64(y)
+	if m <= 2 {
+		yy--
+	}
+	era := yy
+	if era < 0 {
+		era -= 399
+	}
+	era /= 400
+	yoe := yy - era*400
+	mp := int64(m) + 9
+	if m > 2 {
+		mp = int64(m) - 3
+	}
+	doy := (153*mp+2)/5 + int64(d) - 1
+	doe := yoe*365 + yoe/4 - yoe/100 + doy
+	return era*146097 + doe - 719468
+}
+
+// civilFromDays returns the year, month and day of day number z.
+func civilFromDays(z int64) (y, m, d int) {
+	z += 719468
+	era := z
+	if era < 0 {
+		era -= 146096
+	}
+	era /= 146097
+	doe := z - era*146097
+	yoe := (doe - doe/1460 + doe/36524 - doe/146096) / 365
+	doy := doe - (365*yoe + yoe/4 - yoe/100)
+	mp := (5*doy + 2) / 153
+	d = int(doy - (153*mp+2)/5 + 1)
+	if mp < 10 {
+		m = int(mp + 3)
+	} else {
+		m = int(mp - 9)
+	}
+	y = int(yoe + era*400)
+	if m <= 2 {
+		y++
+	}
+	return y, m, d
+}
+
+// civilDay returns the day number of the calendar date of t in its own
+// location.
+func civilDay(t time.Time) int64 {
+	y, m, d := t.Date()
+	return daysFromCivil(y, int(m), d)
+}
+
+// dayTime returns midnight UTC of day number z.
+func dayTime(z int64) time.Time {
+	y, m, d := civilFromDays(z)
+	return time.Date(y, time.Month(m), d, 0, 0, 0, 0, time.UTC)
+}
+
+// IsLeapYear returns true if year is a leap year in the proleptic Gregorian
+// calendar.
+func IsLeapYear(year int) bool {
+	return year%4 == 0 && (year%100 != 0 || year%400 == 0)
+}
+
+// daysInMonth returns the number of days in month m of year y.
+func daysInMonth(y, m int) int {
+	switch m {
+	case 2:
+		if IsLeapYear(y) {
+			return 29
+		}
+		return 28
+	case 4, 6, 9, 11:
+		return 30
+	}
+	return 31
+}
@@ -0,0 +1,211 @@
+package libdates
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/luthersystems/svc/locale"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Layouts supported by ParseCivilDate.  The slash and dash layouts are day
+// first, as used in the UK.
+const (
+	LayoutISO           = "2006-01-02"
+	LayoutUK            = "02 January 2006"
+	LayoutDMYSlashShort = "02/01/06"
+	LayoutDMYSlashLong  = "02/01/2006"
+	LayoutDMYLong       = "02-01-2006"
+)
+
+// DefaultLayouts are the layouts tried by ParseCivilDate when none are
+// given.
+var DefaultLayouts = []string{
+	LayoutISO,
+	LayoutDMYSlashLong,
+	LayoutDMYSlashShort,
+	LayoutDMYLong,
+	LayoutUK,
+}
+
+// CivilDate is a calendar date without a time of day or location.  The zero
+// value is not a valid date and marshals as JSON null.
+type CivilDate struct {
+	Year  int
+	Month time.Month
+	Day   int
+}
+
+// NewCivilDate returns the date of year, month and day.  Out of range
+// values are normalized as by time.Date, so that January 32 becomes
+// February 1.
+func NewCivilDate(year int, month time.Month, day int) CivilDate {
+	m := int(month) - 1
+	year += m / 12
+	m %= 12
+	if m < 0 {
+		m += 12
+		year--
+	}
+	return civilDateOfDay(daysFromCivil(year, m+1, 1) + int64(day) - 1)
+}
+
+// CivilDateOf returns the calendar date of t in its own location.
+func CivilDateOf(t time.Time) CivilDate {
+	y, m, d := t.Date()
+	return CivilDate{Year: y, Month: m, Day: d}
+}
+
+// Today returns the current date in the time zone of the request of ctx (see
+// locale.Location), which is UTC if the request has no locale.
+func Today(ctx context.Context) CivilDate {
+	return CivilDateOf(time.Now().In(locale.Location(ctx)))
+}
+
+func civilDateOfDay(z int64) CivilDate {
+	y, m, d := civilFromDays(z)
+	return CivilDate{Year: y, Month: time.Month(m), Day: d}
+}
+
+// ParseCivilDate parses a date using the first matching layout.  If no
+// layouts are given DefaultLayouts are used.
+func ParseCivilDate(s string, layouts ...string) (CivilDate, error) {
+	if len(layouts) == 0 {
+		layouts = DefaultLayouts
+	}
+	for _, layout := range layouts {
+		t, err := time.Parse(layout, s)
+		if err == nil {
+			return CivilDateOf(t), nil
+		}
+	}
+	return CivilDate{}, fmt.Errorf("invalid date: %q", s)
+}
+
+// CivilDateFromTimestamp returns the UTC calendar date of ts.
+func CivilDateFromTimestamp(ts *timestamppb.Timestamp) CivilDate {
+	return CivilDateOf(ts.AsTime())
+}
+
+// IsZero returns true if d is the zero value.
+func (d CivilDate) IsZero() bool {
+	return d == CivilDate{}
+}
+
+// IsValid returns true if d is a date in the proleptic Gregorian calendar.
+func (d CivilDate) IsValid() bool {
+	return d.Month >= time.January && d.Month <= time.December &&
+		d.Day >= 1 && d.Day <= daysInMonth(d.Year, int(d.Month))
+}
+
+func (d CivilDate) days() int64 {
+	return daysFromCivil(d.Year, int(d.Month), d.Day)
+}
+
+// In returns midnight of d in loc.
+func (d CivilDate) In(loc *time.Location) time.Time {
+	return time.Date(d.Year, d.Month, d.Day, 0, 0, 0, 0, loc)
+}
+
+// Timestamp returns midnight UTC of d.
+func (d CivilDate) Timestamp() *timestamppb.Timestamp {
+	return timestamppb.New(d.In(time.UTC))
+}
+
+// Format formats d using a time package layout.  Layouts referring to a
+// time of day format midnight.
+func (d CivilDate) Format(layout string) string {
+	return d.In(time.UTC).Format(layout)
+}
+
+// String returns d in the ISO 8601 format YYYY-MM-DD.
+func (d CivilDate) String() string {
+	return d.Format(LayoutISO)
+}
+
+// AddDays returns the date n days after d.
+func (d CivilDate) AddDays(n int) CivilDate {
+	return civilDateOfDay(d.days() + int64(n))
+}
+
+// DaysSince returns the number of days from o to d, which is negative if d
+// is before o.
+func (d CivilDate) DaysSince(o CivilDate) int {
+	return int(d.days() - o.days())
+}
+
+// Compare returns -1 if d is before o, +1 if d is after o, and 0 if they are
+// the same date.
+func (d CivilDate) Compare(o CivilDate) int {
+	switch a, b := d.days(), o.days(); {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// Before returns true if d is before o.
+func (d CivilDate) Before(o CivilDate) bool {
+	return d.Compare(o) < 0
+}
+
+// After returns true if d is after o.
+func (d CivilDate) After(o CivilDate) bool {
+	return d.Compare(o) > 0
+}
+
+// MarshalText implements encoding.TextMarshaler using the ISO 8601 format.
+func (d CivilDate) MarshalText() ([]byte, error) {
+	if d.IsZero() {
+		return []byte{}, nil
+	}
+	if !d.IsValid() {
+		return nil, fmt.Errorf("invalid date: %d-%d-%d", d.Year, d.Month, d.Day)
+	}
+	return []byte(d.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.  Only the ISO 8601
+// format is accepted.
+func (d *CivilDate) UnmarshalText(b []byte) error {
+	if len(b) == 0 {
+		*d = CivilDate{}
+		return nil
+	}
+	parsed, err := ParseCivilDate(string(b), LayoutISO)
+	if err != nil {
+		return err
+	}
+	*d = parsed
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler.  The zero value is marshaled as
+// null.
+func (d CivilDate) MarshalJSON() ([]byte, error) {
+	if d.IsZero() {
+		return []byte("null"), nil
+	}
+	b, err := d.MarshalText()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(string(b))
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (d *CivilDate) UnmarshalJSON(b []byte) error {
+	if string(b) == "null" {
+		*d = CivilDate{}
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("civil date: %w", err)
+	}
+	return d.UnmarshalText([]byte(s))
+}
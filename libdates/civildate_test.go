@@ -0,0 +1,80 @@
+package libdates_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/luthersystems/svc/libdates"
+	"github.com/luthersystems/svc/locale"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCivilDate(t *testing.T) {
+	want := libdates.CivilDate{Year: 2021, Month: time.May, Day: 7}
+	for _, s := range []string{"2021-05-07", "07/05/2021", "07/05/21", "07-05-2021", "07 May 2021"} {
+		d, err := libdates.ParseCivilDate(s)
+		require.NoError(t, err, s)
+		require.Equal(t, want, d, s)
+	}
+	_, err := libdates.ParseCivilDate("07/05/2021", libdates.LayoutISO)
+	require.Error(t, err)
+	_, err = libdates.ParseCivilDate("2021-02-29")
+	require.Error(t, err)
+}
+
+func TestNewCivilDate(t *testing.T) {
+	require.Equal(t, libdates.CivilDate{Year: 2021, Month: time.February, Day: 1}, libdates.NewCivilDate(2021, time.January, 32))
+	require.Equal(t, libdates.CivilDate{Year: 2020, Month: time.December, Day: 31}, libdates.NewCivilDate(2021, time.January, 0))
+	require.Equal(t, libdates.CivilDate{Year: 2022, Month: time.January, Day: 15}, libdates.NewCivilDate(2021, 13, 15))
+	require.Equal(t, libdates.CivilDate{Year: 2020, Month: time.November, Day: 15}, libdates.NewCivilDate(2021, -1, 15))
+}
+
+func TestCivilDateCompare(t *testing.T) {
+	a := libdates.NewCivilDate(2020, time.February, 28)
+	b := a.AddDays(2)
+	require.Equal(t, libdates.NewCivilDate(2020, time.March, 1), b)
+	require.True(t, a.Before(b))
+	require.True(t, b.After(a))
+	require.Equal(t, 0, a.Compare(a))
+	require.Equal(t, 2, b.DaysSince(a))
+	require.Equal(t, -2, a.DaysSince(b))
+}
+
+func TestCivilDateMarshal(t *testing.T) {
+	type doc struct {
+		Date  libdates.CivilDate `json:"date"`
+		Empty libdates.CivilDate `json:"empty"`
+	}
+	b, err := json.Marshal(doc{Date: libdates.NewCivilDate(2021, time.May, 7)})
+	require.NoError(t, err)
+	require.JSONEq(t, `{"date":"2021-05-07","empty":null}`, string(b))
+
+	var got doc
+	require.NoError(t, json.Unmarshal(b, &got))
+	require.Equal(t, libdates.NewCivilDate(2021, time.May, 7), got.Date)
+	require.True(t, got.Empty.IsZero())
+
+	require.Error(t, json.Unmarshal([]byte(`{"date":"07/05/2021"}`), &got))
+	_, err = json.Marshal(libdates.CivilDate{Year: 2021, Month: time.February, Day: 30})
+	require.Error(t, err)
+}
+
+func TestCivilDateTimestamp(t *testing.T) {
+	d := libdates.NewCivilDate(2021, time.May, 7)
+	ts := d.Timestamp()
+	require.Equal(t, time.Date(2021, time.May, 7, 0, 0, 0, 0, time.UTC), ts.AsTime())
+	require.Equal(t, d, libdates.CivilDateFromTimestamp(ts))
+	require.Equal(t, "07 May 2021", d.Format(libdates.LayoutUK))
+}
+
+func TestToday(t *testing.T) {
+	ctx := context.Background()
+	require.Equal(t, libdates.CivilDateOf(time.Now().UTC()), libdates.Today(ctx))
+	// Kiritimati is UTC+14, so its date differs from UTC most of the day.
+	kiritimati, err := locale.LoadLocation("Pacific/Kiritimati")
+	require.NoError(t, err)
+	ctx = locale.NewContext(ctx, locale.Locale{Location: kiritimati})
+	require.Equal(t, libdates.CivilDateOf(time.Now().In(kiritimati)), libdates.Today(ctx))
+}
@@ -0,0 +1,29 @@
+package libdates
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestCivilDays checks the civil day conversions against the time package.
+func TestCivilDays(t *testing.T) {
+	for _, ts := range []time.Time{
+		time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(1969, 12, 31, 0, 0, 0, 0, time.UTC),
+		time.Date(2000, 2, 29, 0, 0, 0, 0, time.UTC),
+		time.Date(1600, 3, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(-1, 12, 31, 0, 0, 0, 0, time.UTC),
+		time.Date(9999, 12, 31, 0, 0, 0, 0, time.UTC),
+	} {
+		z := daysFromCivil(ts.Year(), int(ts.Month()), ts.Day())
+		require.Equal(t, ts.Unix()/86400, z, ts.String())
+		require.Equal(t, ts, dayTime(z))
+	}
+	for z := int64(-800000); z < 800000; z += 997 {
+		y, m, d := civilFromDays(z)
+		require.Equal(t, z, daysFromCivil(y, m, d))
+		require.LessOrEqual(t, d, daysInMonth(y, m))
+	}
+}
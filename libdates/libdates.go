@@ -0,0 +1,189 @@
+// Package libdates provides calendar date utilities for Go and ELPS.  Dates
+// are civil dates in the proleptic Gregorian calendar, without a time of day
+// or location.
+package libdates
+
+import (
+	"time"
+
+	"github.com/luthersystems/elps/elpsutil"
+	"github.com/luthersystems/elps/lisp"
+)
+
+// DefaultPackageName is the package name used by LoadPackage.
+const DefaultPackageName = "dates"
+
+// LoadPackage loads the package.
+func LoadPackage(env *lisp.LEnv) *lisp.LVal {
+	name := lisp.Symbol(DefaultPackageName)
+	e := env.DefinePackage(name)
+	if !e.IsNil() {
+		return e
+	}
+	e = env.InPackage(name)
+	if !e.IsNil() {
+		return e
+	}
+	for _, fn := range builtins {
+		env.AddBuiltins(true, fn)
+	}
+	return lisp.Nil()
+}
+
+var builtins = []lisp.LBuiltinDef{
+	elpsutil.Function("age-at", lisp.Formals("dob", "as-of", lisp.KeyArgSymbol, "feb29"), builtInAgeAt),
+	elpsutil.Function("next-anniversary", lisp.Formals("date", "after", lisp.KeyArgSymbol, "feb29"), builtInNextAnniversary),
+	elpsutil.Function("leap-year?", lisp.Formals("year"), builtInIsLeapYear),
+	elpsutil.Function("truncate-to-period", lisp.Formals("date", "period"), builtInTruncateToPeriod),
+	elpsutil.Function("end-of-period", lisp.Formals("date", "period"), builtInEndOfPeriod),
+	elpsutil.Function("iso-week", lisp.Formals("date"), builtInISOWeek),
+	elpsutil.Function("parse", lisp.Formals("date", lisp.VarArgSymbol, "layouts"), builtInParse),
+	elpsutil.Function("format", lisp.Formals("date", "layout"), builtInFormat),
+}
+
+// dateArg parses a YYYY-MM-DD date argument.
+func dateArg(env *lisp.LEnv, name string, v *lisp.LVal) (time.Time, *lisp.LVal) {
+	if v.Type != lisp.LString {
+		return time.Time{}, env.Errorf("%s: non-string date: %v", name, v.Type)
+	}
+	t, err := time.Parse(LayoutISO, v.Str)
+	if err != nil {
+		return time.Time{}, env.Errorf("%s: expecting date format YYYY-MM-DD, got: %v", name, err)
+	}
+	return t, nil
+}
+
+// periodArg parses a period argument.
+func periodArg(env *lisp.LEnv, v *lisp.LVal) (Period, *lisp.LVal) {
+	if v.Type != lisp.LString {
+		return "", env.Errorf("period: non-string period: %v", v.Type)
+	}
+	p, err := ParsePeriod(v.Str)
+	if err != nil {
+		return "", env.Errorf("%v", err)
+	}
+	return p, nil
+}
+
+// feb29Arg parses an optional feb29 policy argument.
+func feb29Arg(env *lisp.LEnv, v *lisp.LVal) ([]Option, *lisp.LVal) {
+	if v.IsNil() {
+		return nil, nil
+	}
+	if v.Type != lisp.LString {
+		return nil, env.Errorf("feb29: non-string policy: %v", v.Type)
+	}
+	p, err := ParseFeb29Policy(v.Str)
+	if err != nil {
+		return nil, env.Errorf("%v", err)
+	}
+	return []Option{WithFeb29Policy(p)}, nil
+}
+
+func builtInAgeAt(env *lisp.LEnv, args *lisp.LVal) *lisp.LVal {
+	dob, lerr := dateArg(env, "dob", args.Cells[0])
+	if lerr != nil {
+		return lerr
+	}
+	asOf, lerr := dateArg(env, "as-of", args.Cells[1])
+	if lerr != nil {
+		return lerr
+	}
+	opts, lerr := feb29Arg(env, args.Cells[2])
+	if lerr != nil {
+		return lerr
+	}
+	age, err := AgeAt(dob, asOf, opts...)
+	if err != nil {
+		return env.Errorf("%v", err)
+	}
+	return lisp.Int(age)
+}
+
+func builtInNextAnniversary(env *lisp.LEnv, args *lisp.LVal) *lisp.LVal {
+	date, lerr := dateArg(env, "date", args.Cells[0])
+	if lerr != nil {
+		return lerr
+	}
+	after, lerr := dateArg(env, "after", args.Cells[1])
+	if lerr != nil {
+		return lerr
+	}
+	opts, lerr := feb29Arg(env, args.Cells[2])
+	if lerr != nil {
+		return lerr
+	}
+	return lisp.String(NextAnniversary(date, after, opts...).Format(LayoutISO))
+}
+
+func builtInIsLeapYear(env *lisp.LEnv, args *lisp.LVal) *lisp.LVal {
+	year := args.Cells[0]
+	if year.Type != lisp.LInt {
+		return env.Errorf("non-integer year: %v", year.Type)
+	}
+	return lisp.Bool(IsLeapYear(year.Int))
+}
+
+func builtInTruncateToPeriod(env *lisp.LEnv, args *lisp.LVal) *lisp.LVal {
+	return periodBoundary(env, args, TruncateToPeriod)
+}
+
+func builtInEndOfPeriod(env *lisp.LEnv, args *lisp.LVal) *lisp.LVal {
+	return periodBoundary(env, args, EndOfPeriod)
+}
+
+func periodBoundary(env *lisp.LEnv, args *lisp.LVal, fn func(time.Time, Period) (time.Time, error)) *lisp.LVal {
+	date, lerr := dateArg(env, "date", args.Cells[0])
+	if lerr != nil {
+		return lerr
+	}
+	p, lerr := periodArg(env, args.Cells[1])
+	if lerr != nil {
+		return lerr
+	}
+	t, err := fn(date, p)
+	if err != nil {
+		return env.Errorf("%v", err)
+	}
+	return lisp.String(t.Format(LayoutISO))
+}
+
+func builtInISOWeek(env *lisp.LEnv, args *lisp.LVal) *lisp.LVal {
+	date, lerr := dateArg(env, "date", args.Cells[0])
+	if lerr != nil {
+		return lerr
+	}
+	year, week := ISOWeek(date)
+	return lisp.QExpr([]*lisp.LVal{lisp.Int(year), lisp.Int(week)})
+}
+
+func builtInParse(env *lisp.LEnv, args *lisp.LVal) *lisp.LVal {
+	date := args.Cells[0]
+	if date.Type != lisp.LString {
+		return env.Errorf("non-string date: %v", date.Type)
+	}
+	var layouts []string
+	for _, layout := range args.Cells[1:] {
+		if layout.Type != lisp.LString {
+			return env.Errorf("non-string layout: %v", layout.Type)
+		}
+		layouts = append(layouts, layout.Str)
+	}
+	d, err := ParseCivilDate(date.Str, layouts...)
+	if err != nil {
+		return env.Errorf("%v", err)
+	}
+	return lisp.String(d.String())
+}
+
+func builtInFormat(env *lisp.LEnv, args *lisp.LVal) *lisp.LVal {
+	date, lerr := dateArg(env, "date", args.Cells[0])
+	if lerr != nil {
+		return lerr
+	}
+	layout := args.Cells[1]
+	if layout.Type != lisp.LString {
+		return env.Errorf("non-string layout: %v", layout.Type)
+	}
+	return lisp.String(CivilDateOf(date).Format(layout.Str))
+}
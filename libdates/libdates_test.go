@@ -0,0 +1,101 @@
+package libdates_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/luthersystems/elps/elpstest"
+	"github.com/luthersystems/elps/elpsutil"
+	"github.com/luthersystems/elps/lisp/lisplib/libtesting"
+	"github.com/luthersystems/svc/libdates"
+	"github.com/stretchr/testify/require"
+)
+
+// TestPackage runs libdates lisp tests.
+func TestPackage(t *testing.T) {
+	runner := &elpstest.Runner{
+		Loader: elpsutil.LoadAll(libtesting.LoadPackage, libdates.LoadPackage),
+	}
+	runner.RunTestFile(t, "libdates_test.lisp")
+}
+
+func date(s string) time.Time {
+	t, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func TestAgeAt(t *testing.T) {
+	tests := []struct {
+		name  string
+		dob   string
+		asOf  string
+		feb29 libdates.Feb29Policy
+		age   int
+	}{
+		{"birthday", "1990-06-15", "2020-06-15", libdates.Feb29OnFeb28, 30},
+		{"day before birthday", "1990-06-15", "2020-06-14", libdates.Feb29OnFeb28, 29},
+		{"day of birth", "1990-06-15", "1990-06-15", libdates.Feb29OnFeb28, 0},
+		{"end of year", "1990-12-31", "2021-01-01", libdates.Feb29OnFeb28, 30},
+		{"leapling feb28", "2004-02-29", "2022-02-28", libdates.Feb29OnFeb28, 18},
+		{"leapling feb27", "2004-02-29", "2022-02-27", libdates.Feb29OnFeb28, 17},
+		{"leapling mar1 policy", "2004-02-29", "2022-02-28", libdates.Feb29OnMar1, 17},
+		{"leapling mar1", "2004-02-29", "2022-03-01", libdates.Feb29OnMar1, 18},
+		{"leapling leap year", "2004-02-29", "2024-02-29", libdates.Feb29OnMar1, 20},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			age, err := libdates.AgeAt(date(test.dob), date(test.asOf), libdates.WithFeb29Policy(test.feb29))
+			require.NoError(t, err)
+			require.Equal(t, test.age, age)
+		})
+	}
+}
+
+func TestAgeAtFuture(t *testing.T) {
+	_, err := libdates.AgeAt(date("2020-01-02"), date("2020-01-01"))
+	require.ErrorIs(t, err, libdates.ErrFutureDate)
+}
+
+func TestAgeAtLocation(t *testing.T) {
+	// Late evening in New York is already the next day in UTC.
+	loc, err := time.LoadLocation("America/New_York")
+	require.NoError(t, err)
+	asOf := time.Date(2020, 6, 14, 23, 0, 0, 0, loc)
+	age, err := libdates.AgeAt(date("1990-06-15"), asOf)
+	require.NoError(t, err)
+	require.Equal(t, 29, age)
+}
+
+func TestNextAnniversary(t *testing.T) {
+	tests := []struct {
+		name  string
+		date  string
+		after string
+		feb29 libdates.Feb29Policy
+		next  string
+	}{
+		{"before anniversary", "1990-06-15", "2020-06-14", libdates.Feb29OnFeb28, "2020-06-15"},
+		{"on anniversary", "1990-06-15", "2020-06-15", libdates.Feb29OnFeb28, "2021-06-15"},
+		{"before date", "1990-06-15", "1980-01-01", libdates.Feb29OnFeb28, "1991-06-15"},
+		{"on date", "1990-06-15", "1990-06-15", libdates.Feb29OnFeb28, "1991-06-15"},
+		{"leapling", "2004-02-29", "2020-02-29", libdates.Feb29OnFeb28, "2021-02-28"},
+		{"leapling mar1", "2004-02-29", "2020-02-29", libdates.Feb29OnMar1, "2021-03-01"},
+		{"leapling leap year", "2004-02-29", "2024-02-28", libdates.Feb29OnMar1, "2024-02-29"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			next := libdates.NextAnniversary(date(test.date), date(test.after), libdates.WithFeb29Policy(test.feb29))
+			require.Equal(t, date(test.next), next)
+		})
+	}
+}
+
+func TestIsLeapYear(t *testing.T) {
+	require.True(t, libdates.IsLeapYear(2000))
+	require.True(t, libdates.IsLeapYear(2024))
+	require.False(t, libdates.IsLeapYear(1900))
+	require.False(t, libdates.IsLeapYear(2023))
+}
@@ -0,0 +1,31 @@
+(use-package 'testing)
+
+(test "age-at"
+  (assert= 30 (dates:age-at "1990-06-15" "2020-06-15"))
+  (assert= 29 (dates:age-at "1990-06-15" "2020-06-14"))
+  (assert= 18 (dates:age-at "2004-02-29" "2022-02-28"))
+  (assert= 17 (dates:age-at "2004-02-29" "2022-02-28" :feb29 "mar1"))
+  (assert= 18 (dates:age-at "2004-02-29" "2022-03-01" :feb29 "mar1")))
+
+(test "next-anniversary"
+  (assert-string= "2021-02-28" (dates:next-anniversary "2004-02-29" "2020-02-29"))
+  (assert-string= "2021-03-01" (dates:next-anniversary "2004-02-29" "2020-02-29" :feb29 "mar1"))
+  (assert-string= "2024-02-29" (dates:next-anniversary "2004-02-29" "2023-12-31")))
+
+(test "leap-year?"
+  (assert (dates:leap-year? 2000))
+  (assert (not (dates:leap-year? 1900)))
+  (assert (dates:leap-year? 2024)))
+
+(test "truncate-to-period"
+  (assert-string= "2021-01-01" (dates:truncate-to-period "2021-02-15" "quarter"))
+  (assert-string= "2020-12-28" (dates:truncate-to-period "2021-01-03" "week"))
+  (assert-string= "2024-02-29" (dates:end-of-period "2024-02-03" "month")))
+
+(test "iso-week"
+  (assert-equal '(2020 53) (dates:iso-week "2021-01-03")))
+
+(test "parse-format"
+  (assert-string= "2021-05-17" (dates:parse "17/05/2021"))
+  (assert-string= "2021-05-17" (dates:parse "17 May 2021" "02 January 2006"))
+  (assert-string= "17 May 2021" (dates:format "2021-05-17" "02 January 2006")))
@@ -0,0 +1,129 @@
+package libdates
+
+import (
+	"fmt"
+	"time"
+)
+
+// Period is a calendar period used to group dates for reporting.
+type Period string
+
+const (
+	// PeriodDay is a calendar day.
+	PeriodDay Period = "day"
+	// PeriodWeek is an ISO 8601 week, starting on Monday.
+	PeriodWeek Period = "week"
+	// PeriodMonth is a calendar month.
+	PeriodMonth Period = "month"
+	// PeriodQuarter is a calendar quarter, starting in January, April, July
+	// or October.
+	PeriodQuarter Period = "quarter"
+	// PeriodYear is a calendar year.
+	PeriodYear Period = "year"
+)
+
+// ParsePeriod parses the name of a Period.
+func ParsePeriod(s string) (Period, error) {
+	switch p := Period(s); p {
+	case PeriodDay, PeriodWeek, PeriodMonth, PeriodQuarter, PeriodYear:
+		return p, nil
+	}
+	return "", fmt.Errorf("invalid period: %q", s)
+}
+
+// The functions below consider only the calendar date of their argument, in
+// its own location, and return dates at midnight UTC.  End functions return
+// the last date of the period, which is included in the period.
+
+// StartOfMonth returns the first date of the month of t.
+func StartOfMonth(t time.Time) time.Time {
+	y, m, _ := t.Date()
+	return dayTime(daysFromCivil(y, int(m), 1))
+}
+
+// EndOfMonth returns the last date of the month of t.
+func EndOfMonth(t time.Time) time.Time {
+	y, m, _ := t.Date()
+	return dayTime(daysFromCivil(y, int(m), daysInMonth(y, int(m))))
+}
+
+// quarterMonth returns the first month of the quarter of month m.
+func quarterMonth(m time.Month) int {
+	return 3*((int(m)-1)/3) + 1
+}
+
+// StartOfQuarter returns the first date of the quarter of t.
+func StartOfQuarter(t time.Time) time.Time {
+	y, m, _ := t.Date()
+	return dayTime(daysFromCivil(y, quarterMonth(m), 1))
+}
+
+// EndOfQuarter returns the last date of the quarter of t.
+func EndOfQuarter(t time.Time) time.Time {
+	y, m, _ := t.Date()
+	last := quarterMonth(m) + 2
+	return dayTime(daysFromCivil(y, last, daysInMonth(y, last)))
+}
+
+// isoWeekday returns the ISO 8601 weekday of day number z, from 0 (Monday)
+// to 6 (Sunday).  Day 0, 1970-01-01, was a Thursday.
+func isoWeekday(z int64) int64 {
+	wd := (z + 3) % 7
+	if wd < 0 {
+		wd += 7
+	}
+	return wd
+}
+
+// StartOfISOWeek returns the Monday of the ISO 8601 week of t.
+func StartOfISOWeek(t time.Time) time.Time {
+	z := civilDay(t)
+	return dayTime(z - isoWeekday(z))
+}
+
+// EndOfISOWeek returns the Sunday of the ISO 8601 week of t.
+func EndOfISOWeek(t time.Time) time.Time {
+	z := civilDay(t)
+	return dayTime(z - isoWeekday(z) + 6)
+}
+
+// ISOWeek returns the ISO 8601 year and week number of the calendar date of
+// t.  Unlike time.Time.ISOWeek the result does not depend on the time of
+// day of t.
+func ISOWeek(t time.Time) (year, week int) {
+	return dayTime(civilDay(t)).ISOWeek()
+}
+
+// TruncateToPeriod returns the first date of the period containing t.
+func TruncateToPeriod(t time.Time, p Period) (time.Time, error) {
+	switch p {
+	case PeriodDay:
+		return dayTime(civilDay(t)), nil
+	case PeriodWeek:
+		return StartOfISOWeek(t), nil
+	case PeriodMonth:
+		return StartOfMonth(t), nil
+	case PeriodQuarter:
+		return StartOfQuarter(t), nil
+	case PeriodYear:
+		return dayTime(daysFromCivil(t.Year(), 1, 1)), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid period: %q", p)
+}
+
+// EndOfPeriod returns the last date of the period containing t.
+func EndOfPeriod(t time.Time, p Period) (time.Time, error) {
+	switch p {
+	case PeriodDay:
+		return dayTime(civilDay(t)), nil
+	case PeriodWeek:
+		return EndOfISOWeek(t), nil
+	case PeriodMonth:
+		return EndOfMonth(t), nil
+	case PeriodQuarter:
+		return EndOfQuarter(t), nil
+	case PeriodYear:
+		return dayTime(daysFromCivil(t.Year(), 12, 31)), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid period: %q", p)
+}
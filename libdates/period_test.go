@@ -0,0 +1,58 @@
+package libdates_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/luthersystems/svc/libdates"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPeriods(t *testing.T) {
+	tests := []struct {
+		date   string
+		period libdates.Period
+		start  string
+		end    string
+	}{
+		{"2021-05-17", libdates.PeriodDay, "2021-05-17", "2021-05-17"},
+		{"2021-05-17", libdates.PeriodWeek, "2021-05-17", "2021-05-23"},
+		{"2021-01-03", libdates.PeriodWeek, "2020-12-28", "2021-01-03"},
+		{"2024-02-10", libdates.PeriodMonth, "2024-02-01", "2024-02-29"},
+		{"2023-02-10", libdates.PeriodMonth, "2023-02-01", "2023-02-28"},
+		{"2021-05-17", libdates.PeriodQuarter, "2021-04-01", "2021-06-30"},
+		{"2021-12-31", libdates.PeriodQuarter, "2021-10-01", "2021-12-31"},
+		{"2021-05-17", libdates.PeriodYear, "2021-01-01", "2021-12-31"},
+	}
+	for _, test := range tests {
+		t.Run(string(test.period)+"-"+test.date, func(t *testing.T) {
+			start, err := libdates.TruncateToPeriod(date(test.date), test.period)
+			require.NoError(t, err)
+			require.Equal(t, date(test.start), start)
+			end, err := libdates.EndOfPeriod(date(test.date), test.period)
+			require.NoError(t, err)
+			require.Equal(t, date(test.end), end)
+		})
+	}
+	_, err := libdates.TruncateToPeriod(date("2021-05-17"), "fortnight")
+	require.Error(t, err)
+}
+
+func TestPeriodLocation(t *testing.T) {
+	// The calendar date is taken in the location of the argument, and
+	// boundaries are returned at midnight UTC.
+	loc, err := time.LoadLocation("Asia/Tokyo")
+	require.NoError(t, err)
+	ts := time.Date(2021, 7, 1, 1, 0, 0, 0, loc)
+	require.Equal(t, date("2021-07-01"), libdates.StartOfQuarter(ts))
+	require.Equal(t, date("2021-07-01"), libdates.StartOfMonth(ts))
+}
+
+func TestISOWeek(t *testing.T) {
+	year, week := libdates.ISOWeek(date("2021-01-03"))
+	require.Equal(t, 2020, year)
+	require.Equal(t, 53, week)
+	year, week = libdates.ISOWeek(date("2024-12-30"))
+	require.Equal(t, 2025, year)
+	require.Equal(t, 1, week)
+}
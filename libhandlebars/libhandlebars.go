@@ -48,9 +48,25 @@ func LoadPackage(env *lisp.LEnv) *lisp.LVal {
 	return lisp.Nil()
 }
 
+// Globals are values available to the {{global}} helper, keyed by namespace
+// and then by key.
+type Globals map[string]map[string]string
+
+// globalsDataKey is the private data frame key holding the state of the
+// {{global}} helper for a single render.
+const globalsDataKey = "_globals"
+
 // Render renders a raymond.Template given a ctx
 func Render(tpl *raymond.Template, ctx interface{}) (string, error) {
-	result, err := tpl.Exec(ctx)
+	return RenderWithGlobals(tpl, ctx, nil)
+}
+
+// RenderWithGlobals renders a raymond.Template given a ctx, seeding the
+// {{global}} helper with globals.  Values set by the template are scoped to
+// the render and are not written back to globals, so a parsed template may be
+// rendered concurrently.
+func RenderWithGlobals(tpl *raymond.Template, ctx interface{}, globals Globals) (string, error) {
+	result, err := tpl.ExecWith(ctx, newRenderFrame(globals))
 	if err != nil {
 		return "", err
 	}
@@ -58,6 +74,19 @@ func Render(tpl *raymond.Template, ctx interface{}) (string, error) {
 	return result, nil
 }
 
+// newRenderFrame returns the private data frame for a single render.
+func newRenderFrame(globals Globals) *raymond.DataFrame {
+	state := make(map[globalKeyspace]string)
+	for ns, vals := range globals {
+		for k, v := range vals {
+			state[globalKeyspace{ns, k}] = v
+		}
+	}
+	frame := raymond.NewDataFrame()
+	frame.Set(globalsDataKey, state)
+	return frame
+}
+
 // Parse parses a template string, returning a raymond.Template
 func Parse(template string) (*raymond.Template, error) {
 	tpl, err := raymond.Parse(template)
@@ -73,6 +102,8 @@ var builtins = []lisp.LBuiltinDef{
 	elpsutil.Function("version", lisp.Formals(), builtInVersion),
 	elpsutil.Function("render", lisp.Formals("tpl", "ctx"), builtInRender),
 	elpsutil.Function("must-parse", lisp.Formals("tpl"), builtInMustParse),
+	elpsutil.Function("render-csv", lisp.Formals("columns", "rows"), builtInRenderCSV),
+	elpsutil.Function("render-xlsx", lisp.Formals("columns", "rows"), builtInRenderXLSX),
 }
 
 func builtInLibname(env *lisp.LEnv, args *lisp.LVal) *lisp.LVal {
@@ -88,6 +119,17 @@ type globalKeyspace struct {
 	ns, k string
 }
 
+// renderGlobals returns the {{global}} helper state of the current render.
+// Child data frames copy their parent's values, so the state is shared by
+// all blocks of a render.
+func renderGlobals(options *raymond.Options) map[globalKeyspace]string {
+	global, ok := options.DataFrame().Get(globalsDataKey).(map[globalKeyspace]string)
+	if !ok {
+		panic(fmt.Errorf("global: template must be rendered using libhandlebars.Render"))
+	}
+	return global
+}
+
 func builtInMustParse(env *lisp.LEnv, args *lisp.LVal) *lisp.LVal {
 	template := args.Cells[0]
 
@@ -137,7 +179,7 @@ func builtInRender(env *lisp.LEnv, args *lisp.LVal) *lisp.LVal {
 		return env.ErrorConditionf("handlebars-parse", "error parsing template: %v", err)
 	}
 	addHelpers(tpl)
-	result, err := tpl.Exec(jsonContext)
+	result, err := Render(tpl, jsonContext)
 	if err != nil {
 		return env.ErrorConditionf("handlebars-render", "error while rendering template: %v", err)
 	}
@@ -174,35 +216,35 @@ func addHelpers(tpl *raymond.Template) {
 		return result
 	})
 
-	tpl.RegisterHelper("gt", func(v1, v2 string) bool {
+	tpl.RegisterHelper("gt", func(v1, v2 interface{}) bool {
 		f1, ok1 := toFloat(v1)
 		f2, ok2 := toFloat(v2)
 
 		return ok1 && ok2 && f1 > f2
 	})
 
-	tpl.RegisterHelper("gte", func(v1, v2 string) bool {
+	tpl.RegisterHelper("gte", func(v1, v2 interface{}) bool {
 		f1, ok1 := toFloat(v1)
 		f2, ok2 := toFloat(v2)
 
 		return ok1 && ok2 && f1 >= f2
 	})
 
-	tpl.RegisterHelper("lt", func(v1, v2 string) bool {
+	tpl.RegisterHelper("lt", func(v1, v2 interface{}) bool {
 		f1, ok1 := toFloat(v1)
 		f2, ok2 := toFloat(v2)
 
 		return ok1 && ok2 && f1 < f2
 	})
 
-	tpl.RegisterHelper("lte", func(v1, v2 string) bool {
+	tpl.RegisterHelper("lte", func(v1, v2 interface{}) bool {
 		f1, ok1 := toFloat(v1)
 		f2, ok2 := toFloat(v2)
 
 		return ok1 && ok2 && f1 <= f2
 	})
 
-	tpl.RegisterHelper("times", func(v1, v2 string) float64 {
+	tpl.RegisterHelper("times", func(v1, v2 interface{}) float64 {
 		f1, ok1 := toFloat(v1)
 		f2, ok2 := toFloat(v2)
 
@@ -212,7 +254,7 @@ func addHelpers(tpl *raymond.Template) {
 		return f1 * f2
 	})
 
-	tpl.RegisterHelper("div", func(v1, v2 string) float64 {
+	tpl.RegisterHelper("div", func(v1, v2 interface{}) float64 {
 		f1, ok1 := toFloat(v1)
 		f2, ok2 := toFloat(v2)
 
@@ -222,7 +264,7 @@ func addHelpers(tpl *raymond.Template) {
 		return f1 / f2
 	})
 
-	tpl.RegisterHelper("mod", func(v1, v2 string) float64 {
+	tpl.RegisterHelper("mod", func(v1, v2 interface{}) float64 {
 		f1, ok1 := toFloat(v1)
 		f2, ok2 := toFloat(v2)
 
@@ -231,6 +273,9 @@ func addHelpers(tpl *raymond.Template) {
 		}
 		return math.Mod(f1, f2)
 	})
+
+	addNumHelpers(tpl)
+
 	tpl.RegisterHelper("date-diff-month", dateDifferenceInMonthsHelper)
 
 	tpl.RegisterHelper("is-after", dateAfterHelper)
@@ -255,7 +300,7 @@ func addHelpers(tpl *raymond.Template) {
 		return result
 	})
 
-	tpl.RegisterHelper("minus", func(v string, options *raymond.Options) float64 {
+	tpl.RegisterHelper("minus", func(v interface{}, options *raymond.Options) float64 {
 		result, _ := toFloat(v)
 		for _, v := range options.Hash() {
 			if f, ok := toFloat(v); ok {
@@ -291,9 +336,8 @@ func addHelpers(tpl *raymond.Template) {
 		return res
 	})
 
-	global := make(map[globalKeyspace]string)
-
 	tpl.RegisterHelper("global", func(ns string, options *raymond.Options) interface{} {
+		global := renderGlobals(options)
 		h := options.Hash()
 		ki, ok := h["key"]
 		if !ok {
@@ -343,11 +387,11 @@ func addHelpers(tpl *raymond.Template) {
 	})
 
 	tpl.RegisterHelper("prettyp-num-en", func(num interface{}) string {
-		f, ok := toFloat(num)
-		if !ok {
-			panic(fmt.Errorf("value passed in must be a number, got: %v", num))
+		s, err := prettyNumEN(num)
+		if err != nil {
+			panic(err)
 		}
-		return humanize.FormatFloat("#,###.##", f)
+		return s
 	})
 
 	tpl.RegisterHelper("possessive", func(name string) string {
@@ -361,49 +405,13 @@ func addHelpers(tpl *raymond.Template) {
 		return nameSenitized + "'s"
 	})
 
-	tpl.RegisterHelper("date-beautify", func(date string) string {
-		if date == "" {
-			return ""
-		}
-		d, err := parseDate(date)
-		if err != nil {
-			panic(fmt.Errorf("date-beautify: expecting date format YYYY-MM-DD, got: %v", err))
-		}
-		return d.Format(layoutUK)
-	})
+	tpl.RegisterHelper("date-beautify", dateHelper("date-beautify", layoutUK))
 
-	tpl.RegisterHelper("date-DDMMYY-slash", func(date string) string {
-		if date == "" {
-			return ""
-		}
-		d, err := parseDate(date)
-		if err != nil {
-			panic(fmt.Errorf("date-DDMMYY-slash: expecting date format YYYY-MM-DD, got: %v", err))
-		}
-		return d.Format(layoutDMYSlashShort)
-	})
+	tpl.RegisterHelper("date-DDMMYY-slash", dateHelper("date-DDMMYY-slash", layoutDMYSlashShort))
 
-	tpl.RegisterHelper("date-DDMMYYYY-slash", func(date string) string {
-		if date == "" {
-			return ""
-		}
-		d, err := parseDate(date)
-		if err != nil {
-			panic(fmt.Errorf("date-DDMMYYYY-slash: expecting date format YYYY-MM-DD, got: %v", err))
-		}
-		return d.Format(layoutDMYSlashLong)
-	})
+	tpl.RegisterHelper("date-DDMMYYYY-slash", dateHelper("date-DDMMYYYY-slash", layoutDMYSlashLong))
 
-	tpl.RegisterHelper("date-DDMMYYYY", func(date string) string {
-		if date == "" {
-			return ""
-		}
-		d, err := parseDate(date)
-		if err != nil {
-			panic(fmt.Errorf("date-DDMMYYYY: expecting date format YYYY-MM-DD, got: %v", err))
-		}
-		return d.Format(layoutDMYLong)
-	})
+	tpl.RegisterHelper("date-DDMMYYYY", dateHelper("date-DDMMYYYY", layoutDMYLong))
 
 	// Format all GB numbers national format i.e without country code
 	tpl.RegisterHelper("format-phone-gb", func(rawNum string) string {
@@ -423,6 +431,11 @@ func addHelpers(tpl *raymond.Template) {
 		return rawNum
 	})
 
+	addPhoneHelpers(tpl)
+
+	addMaskHelpers(tpl)
+	addLocaleHelpers(tpl)
+
 	tpl.RegisterHelper("escape-uri-component", func(unescapedString string) string {
 		return url.QueryEscape(unescapedString)
 	})
@@ -468,14 +481,86 @@ func toFloat(v interface{}) (float64, bool) {
 		f = float64(v)
 	case int64:
 		f = float64(v)
-	case float64, float32:
-		f, ok = v.(float64)
+	case uint:
+		f = float64(v)
+	case uint8:
+		f = float64(v)
+	case uint16:
+		f = float64(v)
+	case uint32:
+		f = float64(v)
+	case uint64:
+		f = float64(v)
+	case float32:
+		f = float64(v)
+	case float64:
+		f = v
+	case json.Number:
+		var e error
+		f, e = v.Float64()
+		if e != nil {
+			ok = false
+		}
 	default:
 		ok = false
 	}
 	return f, ok
 }
 
+// mustFloat converts a helper argument to a number, failing the render if it
+// is not a number or numeric string.
+func mustFloat(helper string, v interface{}) float64 {
+	f, ok := toFloat(v)
+	if !ok || math.IsNaN(f) {
+		panic(fmt.Errorf("%s: invalid number: %#v", helper, v))
+	}
+	return f
+}
+
+// addNumHelpers registers the num-* helpers.  Unlike gt, times, etc. which
+// evaluate to false or 0 when given an invalid number, they fail the render.
+func addNumHelpers(tpl *raymond.Template) {
+	tpl.RegisterHelper("num-gt", func(v1, v2 interface{}) bool {
+		return mustFloat("num-gt", v1) > mustFloat("num-gt", v2)
+	})
+
+	tpl.RegisterHelper("num-gte", func(v1, v2 interface{}) bool {
+		return mustFloat("num-gte", v1) >= mustFloat("num-gte", v2)
+	})
+
+	tpl.RegisterHelper("num-lt", func(v1, v2 interface{}) bool {
+		return mustFloat("num-lt", v1) < mustFloat("num-lt", v2)
+	})
+
+	tpl.RegisterHelper("num-lte", func(v1, v2 interface{}) bool {
+		return mustFloat("num-lte", v1) <= mustFloat("num-lte", v2)
+	})
+
+	tpl.RegisterHelper("num-eq", func(v1, v2 interface{}) bool {
+		return mustFloat("num-eq", v1) == mustFloat("num-eq", v2)
+	})
+
+	tpl.RegisterHelper("num-times", func(v1, v2 interface{}) float64 {
+		return mustFloat("num-times", v1) * mustFloat("num-times", v2)
+	})
+
+	tpl.RegisterHelper("num-div", func(v1, v2 interface{}) float64 {
+		d := mustFloat("num-div", v2)
+		if d == 0 {
+			panic(fmt.Errorf("num-div: division by zero"))
+		}
+		return mustFloat("num-div", v1) / d
+	})
+
+	tpl.RegisterHelper("num-mod", func(v1, v2 interface{}) float64 {
+		d := mustFloat("num-mod", v2)
+		if d == 0 {
+			panic(fmt.Errorf("num-mod: division by zero"))
+		}
+		return math.Mod(mustFloat("num-mod", v1), d)
+	})
+}
+
 func toInt(v interface{}) (int, bool) {
 	var f int
 	ok := true
@@ -517,6 +602,40 @@ func formatDate(date time.Time) string {
 	return date.Format(layoutISO)
 }
 
+// formatISODate reformats a YYYY-MM-DD date with layout, returning "" for an
+// empty date.
+func formatISODate(helper string, layout string, date string) (string, error) {
+	if date == "" {
+		return "", nil
+	}
+	d, err := parseDate(date)
+	if err != nil {
+		return "", fmt.Errorf("%s: expecting date format YYYY-MM-DD, got: %v", helper, err)
+	}
+	return d.Format(layout), nil
+}
+
+// dateHelper returns a helper reformatting YYYY-MM-DD dates with layout.
+func dateHelper(helper string, layout string) func(date string) string {
+	return func(date string) string {
+		s, err := formatISODate(helper, layout, date)
+		if err != nil {
+			panic(err)
+		}
+		return s
+	}
+}
+
+// prettyNumEN formats a number with thousands separators and at most two
+// decimals.
+func prettyNumEN(num interface{}) (string, error) {
+	f, ok := toFloat(num)
+	if !ok {
+		return "", fmt.Errorf("value passed in must be a number, got: %v", num)
+	}
+	return humanize.FormatFloat("#,###.##", f), nil
+}
+
 func dateDifferenceInMonthsHelper(startDate string, endDate string) int {
 	start, err := parseDate(startDate)
 	if err != nil {
@@ -1,6 +1,8 @@
 package libhandlebars_test
 
 import (
+	"strconv"
+	"sync"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -77,3 +79,121 @@ func TestRenderWithHelper(t *testing.T) {
 	require.NoError(t, err)
 	require.Equal(t, expected, res)
 }
+
+func TestRenderGlobalsIsolated(t *testing.T) {
+	tpl, err := libhandlebars.Parse(`{{global "ns" key="k"}}{{global "ns" key="k" val=value}}{{global "ns" key="k"}}`)
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(v string) {
+			defer wg.Done()
+			res, err := libhandlebars.Render(tpl, map[string]string{"value": v})
+			require.NoError(t, err)
+			require.Equal(t, v, res)
+		}(strconv.Itoa(i))
+	}
+	wg.Wait()
+}
+
+func TestRenderWithGlobals(t *testing.T) {
+	tpl, err := libhandlebars.Parse(`{{global "ns" key="k"}}{{#each items}}{{global "ns" key="k" val=this}}{{/each}}{{global "ns" key="k"}}`)
+	require.NoError(t, err)
+	globals := libhandlebars.Globals{"ns": {"k": "seed"}}
+	ctx := map[string]interface{}{"items": []string{"a", "b"}}
+
+	res, err := libhandlebars.RenderWithGlobals(tpl, ctx, globals)
+	require.NoError(t, err)
+	require.Equal(t, "seedb", res)
+	require.Equal(t, "seed", globals["ns"]["k"])
+
+	res, err = libhandlebars.Render(tpl, ctx)
+	require.NoError(t, err)
+	require.Equal(t, "b", res)
+}
+
+func TestNumHelpers(t *testing.T) {
+	tests := []struct {
+		name   string
+		tplStr string
+		ctx    interface{}
+		want   string
+		err    bool
+	}{
+		{
+			name:   "gt native numbers",
+			tplStr: `{{#if (gt a b)}}yes{{/if}}`,
+			ctx:    map[string]interface{}{"a": 10, "b": 9.5},
+			want:   "yes",
+		},
+		{
+			name:   "gt float32",
+			tplStr: `{{#if (gt a b)}}yes{{/if}}`,
+			ctx:    map[string]interface{}{"a": float32(2.5), "b": 2},
+			want:   "yes",
+		},
+		{
+			name:   "gt invalid is false",
+			tplStr: `{{#if (gt a 1)}}yes{{else}}no{{/if}}`,
+			ctx:    map[string]interface{}{"a": "abc"},
+			want:   "no",
+		},
+		{
+			name:   "num-gt",
+			tplStr: `{{#if (num-gt a "9")}}yes{{/if}}`,
+			ctx:    map[string]interface{}{"a": 10},
+			want:   "yes",
+		},
+		{
+			name:   "num-lte",
+			tplStr: `{{#if (num-lte a b)}}yes{{/if}}`,
+			ctx:    map[string]interface{}{"a": 1.5, "b": "1.5"},
+			want:   "yes",
+		},
+		{
+			name:   "num-times",
+			tplStr: `{{num-times a 3}}`,
+			ctx:    map[string]interface{}{"a": 1.5},
+			want:   "4.5",
+		},
+		{
+			name:   "num-mod",
+			tplStr: `{{num-mod a 4}}`,
+			ctx:    map[string]interface{}{"a": 10},
+			want:   "2",
+		},
+		{
+			name:   "num-gt invalid",
+			tplStr: `{{num-gt a 1}}`,
+			ctx:    map[string]interface{}{"a": "abc"},
+			err:    true,
+		},
+		{
+			name:   "num-lt missing",
+			tplStr: `{{num-lt a 1}}`,
+			ctx:    map[string]interface{}{},
+			err:    true,
+		},
+		{
+			name:   "num-div zero",
+			tplStr: `{{num-div a 0}}`,
+			ctx:    map[string]interface{}{"a": 1},
+			err:    true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tpl, err := libhandlebars.Parse(test.tplStr)
+			require.NoError(t, err)
+			res, err := libhandlebars.Render(tpl, test.ctx)
+			if test.err {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, test.want, res)
+		})
+	}
+}
@@ -677,6 +677,60 @@ Bad book
     "01534 726278"
     (handlebars:render """{{{format-phone-gb "1534726278"}}}""" (sorted-map "foo" "bar"))))
 
+;; format-phone tests
+
+(test "format-phone-national"
+  (assert-string=
+    "(888) 237-8289"
+    (handlebars:render """{{{format-phone "8882378289" "US"}}}""" (sorted-map "foo" "bar"))))
+
+(test "format-phone-international"
+  (assert-string=
+    "+44 7709 789111"
+    (handlebars:render """{{{format-phone num "gb" style="INTERNATIONAL"}}}""" (sorted-map "num" "07709-789-111"))))
+
+(test "format-phone-e164"
+  (assert-string=
+    "+18882378289"
+    (handlebars:render """{{{format-phone "(888) 237-8289" "US" style="E164"}}}""" (sorted-map "foo" "bar"))))
+
+(test "format-phone-country-code"
+  (assert-string=
+    "07709 789111"
+    (handlebars:render """{{{format-phone "+447709789111" ""}}}""" (sorted-map "foo" "bar"))))
+
+(test "format-phone-invalid"
+  (assert-string=
+    "numberzz"
+    (handlebars:render """{{{format-phone "numberzz" "US"}}}""" (sorted-map "foo" "bar"))))
+
+(test "format-phone-unknown-style"
+  (handler-bind ((handlebars-render (lambda (c &rest _))))
+                (handlebars:render """{{{format-phone "8882378289" "US" style="LOCAL"}}}""" (sorted-map "foo" "bar"))))
+
+(test "format-phone-unknown-region"
+  (handler-bind ((handlebars-render (lambda (c &rest _))))
+                (handlebars:render """{{{format-phone "8882378289" "XX"}}}""" (sorted-map "foo" "bar"))))
+
+;; validate-phone tests
+
+(test "validate-phone"
+  (assert-string=
+    "yes no no"
+    (handlebars:render """{{#if (validate-phone "07709789111" "GB")}}yes{{/if}} {{#if (validate-phone "07709789111" "US")}}yes{{else}}no{{/if}} {{#if (validate-phone "numberzz" "GB")}}yes{{else}}no{{/if}}""" (sorted-map "foo" "bar"))))
+
+;; phone-region tests
+
+(test "phone-region"
+  (assert-string=
+    "JE US "
+    (handlebars:render """{{phone-region "1534726278" "GB"}} {{phone-region "+18882378289" ""}} {{phone-region "numberzz" "GB"}}""" (sorted-map "foo" "bar"))))
+
+(test "phone-country-code"
+  (assert-string=
+    "44 1"
+    (handlebars:render """{{phone-country-code "07709789111" "GB"}} {{phone-country-code "+18882378289" ""}}""" (sorted-map "foo" "bar"))))
+
 ;; escape uri test - email
 
 (test
@@ -764,3 +818,14 @@ Bad book
     ))
   (assert-string= """2020-01-26""" val)
   )
+
+(test-let "render-csv"
+  ((val (handlebars:render-csv
+          (vector (sorted-map "header" "Name" "field" "name")
+                  (sorted-map "header" "Amount" "field" "amount" "format" "prettyp-num-en"))
+          (vector (sorted-map "name" "a" "amount" 1234.5)
+                  (sorted-map "name" "b" "amount" 2)))))
+  (assert-string= """Name,Amount
+a,"1,234.50"
+b,2.00
+""" val))
@@ -0,0 +1,114 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package libhandlebars
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/luthersystems/raymond"
+	"github.com/luthersystems/svc/locale"
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+	"golang.org/x/text/number"
+)
+
+// localeDataKey is the private data frame key holding the locale of a
+// render.
+const localeDataKey = "_locale"
+
+// defaultNumDecimals is the default maximum number of decimals of the
+// {{format-num}} helper.
+const defaultNumDecimals = 2
+
+// RenderContext renders a raymond.Template given data, seeding the
+// {{global}} helper with globals as RenderWithGlobals does, in the locale of
+// ctx (see locale.FromContext).  The locale is used by the {{locale}},
+// {{format-datetime}} and {{format-num}} helpers.  Without a locale they
+// format in English and UTC.
+func RenderContext(ctx context.Context, tpl *raymond.Template, data interface{}, globals Globals) (string, error) {
+	frame := newRenderFrame(globals)
+	l, _ := locale.FromContext(ctx)
+	frame.Set(localeDataKey, l)
+	return tpl.ExecWith(data, frame)
+}
+
+// renderLocale returns the locale of the current render.
+func renderLocale(options *raymond.Options) locale.Locale {
+	l, _ := options.DataFrame().Get(localeDataKey).(locale.Locale)
+	if l.Location == nil {
+		l.Location = time.UTC
+	}
+	return l
+}
+
+// formatDateTime formats an RFC3339 timestamp in loc, returning "" for an
+// empty timestamp.
+func formatDateTime(ts string, layout string, loc *time.Location) (string, error) {
+	if ts == "" {
+		return "", nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, ts)
+	if err != nil {
+		return "", fmt.Errorf("expecting RFC3339 timestamp, got: %w", err)
+	}
+	if layout == "" {
+		layout = time.RFC3339
+	}
+	return t.In(loc).Format(layout), nil
+}
+
+// formatNum formats a number with the digit grouping and decimal separator
+// of lang, and at most decimals decimals.
+func formatNum(num interface{}, lang string, decimals int) (string, error) {
+	f, ok := toFloat(num)
+	if !ok {
+		return "", fmt.Errorf("value passed in must be a number, got: %v", num)
+	}
+	tag := language.English
+	if lang != "" {
+		var err error
+		if tag, err = language.Parse(lang); err != nil {
+			return "", fmt.Errorf("invalid locale %q: %w", lang, err)
+		}
+	}
+	return message.NewPrinter(tag).Sprint(number.Decimal(f, number.MaxFractionDigits(decimals))), nil
+}
+
+// addLocaleHelpers registers the helpers formatting in the locale of the
+// render.
+func addLocaleHelpers(tpl *raymond.Template) {
+	// Return the language of the render, e.g. {{#if (eq (locale) "fr")}}.
+	tpl.RegisterHelper("locale", func(options *raymond.Options) string {
+		return renderLocale(options).Language
+	})
+
+	// Format a timestamp in the time zone of the render, e.g.
+	// {{format-datetime created_at layout="02 Jan 2006 15:04 MST"}}.
+	tpl.RegisterHelper("format-datetime", func(ts string, options *raymond.Options) string {
+		s, err := formatDateTime(ts, options.HashStr("layout"), renderLocale(options).Location)
+		if err != nil {
+			panic(fmt.Errorf("format-datetime: %w", err))
+		}
+		return s
+	})
+
+	// Format a number in the language of the render, e.g.
+	// {{format-num amount decimals=0}}.
+	tpl.RegisterHelper("format-num", func(num interface{}, options *raymond.Options) string {
+		decimals := defaultNumDecimals
+		if v := options.HashProp("decimals"); v != nil {
+			d, ok := toInt(v)
+			if !ok || d < 0 {
+				panic(fmt.Errorf("format-num: invalid decimals: %v", v))
+			}
+			decimals = d
+		}
+		s, err := formatNum(num, renderLocale(options).Language, decimals)
+		if err != nil {
+			panic(fmt.Errorf("format-num: %w", err))
+		}
+		return s
+	})
+}
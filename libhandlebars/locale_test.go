@@ -0,0 +1,67 @@
+package libhandlebars
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/luthersystems/svc/locale"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormatDateTime(t *testing.T) {
+	ny, err := locale.LoadLocation("America/New_York")
+	require.NoError(t, err)
+	s, err := formatDateTime("2024-03-01T15:04:05Z", "2006-01-02 15:04 MST", ny)
+	require.NoError(t, err)
+	require.Equal(t, "2024-03-01 10:04 EST", s)
+	s, err = formatDateTime("2024-03-01T15:04:05Z", "", time.UTC)
+	require.NoError(t, err)
+	require.Equal(t, "2024-03-01T15:04:05Z", s)
+	s, err = formatDateTime("", "", time.UTC)
+	require.NoError(t, err)
+	require.Equal(t, "", s)
+	_, err = formatDateTime("yesterday", "", time.UTC)
+	require.Error(t, err)
+}
+
+func TestFormatNum(t *testing.T) {
+	s, err := formatNum(1234567.891, "", 2)
+	require.NoError(t, err)
+	require.Equal(t, "1,234,567.89", s)
+	s, err = formatNum("1234.5", "de", 2)
+	require.NoError(t, err)
+	require.Equal(t, "1.234,5", s)
+	s, err = formatNum(1234.6, "fr", 0)
+	require.NoError(t, err)
+	require.Equal(t, "1\u00a0235", s)
+	_, err = formatNum("abc", "en", 2)
+	require.Error(t, err)
+	_, err = formatNum(1, "!!", 2)
+	require.Error(t, err)
+}
+
+func TestRenderContext(t *testing.T) {
+	tpl, err := Parse(`{{locale}}|{{format-datetime ts layout="02 Jan 2006 15:04 MST"}}|{{format-num n}}|{{format-num n decimals=0}}`)
+	require.NoError(t, err)
+	data := map[string]interface{}{
+		"ts": "2024-07-01T12:00:00Z",
+		"n":  9876.543,
+	}
+
+	res, err := RenderContext(context.Background(), tpl, data, nil)
+	require.NoError(t, err)
+	require.Equal(t, "|01 Jul 2024 12:00 UTC|9,876.54|9,877", res)
+
+	berlin, err := locale.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+	ctx := locale.NewContext(context.Background(), locale.Locale{Language: "de-DE", Location: berlin})
+	res, err = RenderContext(ctx, tpl, data, nil)
+	require.NoError(t, err)
+	require.Equal(t, "de-DE|01 Jul 2024 14:00 CEST|9.876,54|9.877", res)
+
+	// Renders without a context use the defaults.
+	res, err = Render(tpl, data)
+	require.NoError(t, err)
+	require.Equal(t, "|01 Jul 2024 12:00 UTC|9,876.54|9,877", res)
+}
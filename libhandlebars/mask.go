@@ -0,0 +1,147 @@
+package libhandlebars
+
+import (
+	"strings"
+
+	"github.com/luthersystems/raymond"
+)
+
+const (
+	// maskChar replaces masked characters.
+	maskChar = "*"
+	// defaultMaskKeep is the default number of trailing characters left
+	// unmasked.
+	defaultMaskKeep = 4
+)
+
+// normalizeIBAN returns an IBAN without spacing, in upper case, or false if
+// it is not shaped like an IBAN.
+func normalizeIBAN(iban string) (string, bool) {
+	s := strings.ToUpper(strings.Join(strings.Fields(iban), ""))
+	if len(s) < 15 || len(s) > 34 {
+		return "", false
+	}
+	for i, r := range s {
+		switch {
+		case i < 2 && (r < 'A' || r > 'Z'):
+			return "", false
+		case i >= 2 && i < 4 && (r < '0' || r > '9'):
+			return "", false
+		case (r < 'A' || r > 'Z') && (r < '0' || r > '9'):
+			return "", false
+		}
+	}
+	return s, true
+}
+
+// maskAll masks every non-space character of s, revealing only its shape.
+func maskAll(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r == ' ' {
+			return r
+		}
+		return '*'
+	}, strings.TrimSpace(s))
+}
+
+// maskIBAN masks an IBAN, keeping its country code and last keep
+// characters, in groups of four, e.g. "GB** **** **** **** **68 19".
+// Strings which are not IBANs are entirely masked.
+func maskIBAN(iban string, keep int) string {
+	s, ok := normalizeIBAN(iban)
+	if !ok {
+		return maskAll(iban)
+	}
+	if keep < 0 || keep > len(s)-4 {
+		// Always mask the account identifier.
+		keep = 0
+	}
+	masked := s[:2] + strings.Repeat(maskChar, len(s)-2-keep) + s[len(s)-keep:]
+	var b strings.Builder
+	for i := 0; i < len(masked); i += 4 {
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteString(masked[i:min(i+4, len(masked))])
+	}
+	return b.String()
+}
+
+// maskAccount masks an account number, keeping its last keep digits, e.g.
+// "****5678".  Separators are dropped.  Numbers without digits, or with
+// other characters, are entirely masked.
+func maskAccount(number string, keep int) string {
+	var digits strings.Builder
+	for _, r := range number {
+		switch {
+		case r >= '0' && r <= '9':
+			digits.WriteRune(r)
+		case r == ' ' || r == '-':
+		default:
+			return maskAll(number)
+		}
+	}
+	s := digits.String()
+	if s == "" {
+		return maskAll(number)
+	}
+	if keep < 0 || keep >= len(s) {
+		// Never reveal the whole number.
+		keep = 0
+	}
+	return strings.Repeat(maskChar, len(s)-keep) + s[len(s)-keep:]
+}
+
+// formatSortCode formats a UK sort code as "12-34-56", masking all but its
+// last two digits if mask is set.  Strings which are not sort codes are
+// returned unchanged, or entirely masked if mask is set.
+func formatSortCode(code string, mask bool) string {
+	digits := strings.NewReplacer("-", "", " ", "").Replace(strings.TrimSpace(code))
+	ok := len(digits) == 6
+	for _, r := range digits {
+		if r < '0' || r > '9' {
+			ok = false
+		}
+	}
+	if !ok {
+		if mask {
+			return maskAll(code)
+		}
+		return code
+	}
+	if mask {
+		return "**-**-" + digits[4:]
+	}
+	return digits[:2] + "-" + digits[2:4] + "-" + digits[4:]
+}
+
+// maskKeep returns the keep hash argument of a masking helper.  Invalid
+// values fall back to the default.
+func maskKeep(options *raymond.Options) int {
+	v := options.HashProp("keep")
+	if v == nil {
+		return defaultMaskKeep
+	}
+	keep, ok := toInt(v)
+	if !ok {
+		return defaultMaskKeep
+	}
+	return keep
+}
+
+// addMaskHelpers registers the financial identifier masking helpers.  They
+// never fail the render: arguments of any type are accepted, and invalid
+// input is masked entirely rather than revealed.
+func addMaskHelpers(tpl *raymond.Template) {
+	tpl.RegisterHelper("mask-iban", func(iban interface{}, options *raymond.Options) string {
+		return maskIBAN(raymond.Str(iban), maskKeep(options))
+	})
+
+	tpl.RegisterHelper("mask-account", func(number interface{}, options *raymond.Options) string {
+		return maskAccount(raymond.Str(number), maskKeep(options))
+	})
+
+	tpl.RegisterHelper("format-sort-code", func(code interface{}, options *raymond.Options) string {
+		return formatSortCode(raymond.Str(code), raymond.IsTrue(options.HashProp("mask")))
+	})
+}
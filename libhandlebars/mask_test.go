@@ -0,0 +1,45 @@
+package libhandlebars
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMaskIBAN(t *testing.T) {
+	require.Equal(t, "GB** **** **** **** **68 19", maskIBAN("GB29 NWBK 6016 1331 9268 19", 4))
+	require.Equal(t, "GB** **** **** **** **** 19", maskIBAN("gb29nwbk60161331926819", 2))
+	// The account identifier is always masked.
+	require.Equal(t, "GB** **** **** **** **** **", maskIBAN("GB29NWBK60161331926819", 100))
+	require.Equal(t, "*** ****", maskIBAN("not iban", 4))
+	require.Equal(t, "", maskIBAN("", 4))
+}
+
+func TestMaskAccount(t *testing.T) {
+	require.Equal(t, "****5678", maskAccount("12345678", 4))
+	require.Equal(t, "******78", maskAccount("1234-5678", 2))
+	require.Equal(t, "********", maskAccount("12345678", 8))
+	require.Equal(t, "********", maskAccount("12345678", -1))
+	require.Equal(t, "****", maskAccount("12ab", 4))
+}
+
+func TestFormatSortCode(t *testing.T) {
+	require.Equal(t, "12-34-56", formatSortCode("123456", false))
+	require.Equal(t, "12-34-56", formatSortCode(" 12 34 56", false))
+	require.Equal(t, "**-**-56", formatSortCode("12-34-56", true))
+	require.Equal(t, "1234", formatSortCode("1234", false))
+	require.Equal(t, "****", formatSortCode("1234", true))
+}
+
+func TestMaskHelpers(t *testing.T) {
+	tpl, err := Parse(`{{mask-iban iban}}|{{mask-account acct keep=2}}|{{mask-account num}}|{{format-sort-code sc mask=true}}|{{mask-account missing}}`)
+	require.NoError(t, err)
+	res, err := Render(tpl, map[string]interface{}{
+		"iban": "DE89370400440532013000",
+		"acct": "31926819",
+		"num":  12345678,
+		"sc":   "601613",
+	})
+	require.NoError(t, err)
+	require.Equal(t, "DE** **** **** **** **30 00|******19|****5678|**-**-13|", res)
+}
@@ -0,0 +1,114 @@
+package libhandlebars
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/luthersystems/raymond"
+	"github.com/nyaruka/phonenumbers"
+)
+
+// Phone number formats accepted by the format-phone helper.
+const (
+	// PhoneFormatNational formats numbers as dialled within their region,
+	// e.g. "07709 789111".
+	PhoneFormatNational = "NATIONAL"
+	// PhoneFormatInternational formats numbers as dialled from abroad,
+	// e.g. "+44 7709 789111".
+	PhoneFormatInternational = "INTERNATIONAL"
+	// PhoneFormatE164 formats numbers without spacing, e.g. "+447709789111".
+	PhoneFormatE164 = "E164"
+)
+
+var phoneFormats = map[string]phonenumbers.PhoneNumberFormat{
+	PhoneFormatNational:      phonenumbers.NATIONAL,
+	PhoneFormatInternational: phonenumbers.INTERNATIONAL,
+	PhoneFormatE164:          phonenumbers.E164,
+}
+
+// parsePhone parses a phone number, returning nil if it is not valid.
+// Numbers without a country code are parsed as numbers of region, an ISO
+// 3166-1 alpha-2 code which may be empty for numbers with a country code.
+func parsePhone(number string, region string) (*phonenumbers.PhoneNumber, error) {
+	region = strings.ToUpper(region)
+	if region != "" && phonenumbers.GetCountryCodeForRegion(region) == 0 {
+		return nil, fmt.Errorf("unknown region: %q", region)
+	}
+	num, err := phonenumbers.Parse(number, region)
+	if err != nil {
+		return nil, nil
+	}
+	if !phonenumbers.IsValidNumber(num) {
+		return nil, nil
+	}
+	return num, nil
+}
+
+// formatPhone formats a phone number of region in format, one of the
+// PhoneFormat constants.  Invalid numbers are returned unchanged.
+func formatPhone(number string, region string, format string) (string, error) {
+	if format == "" {
+		format = PhoneFormatNational
+	}
+	f, ok := phoneFormats[strings.ToUpper(format)]
+	if !ok {
+		return "", fmt.Errorf("unknown phone format: %q", format)
+	}
+	if number == "" {
+		return "", nil
+	}
+	num, err := parsePhone(number, region)
+	if err != nil {
+		return "", err
+	}
+	if num == nil {
+		return number, nil
+	}
+	return phonenumbers.Format(num, f), nil
+}
+
+// addPhoneHelpers registers the phone number helpers.  Unknown regions and
+// formats fail the render, while invalid numbers are tolerated.
+func addPhoneHelpers(tpl *raymond.Template) {
+	// Format numbers of any region, e.g. {{format-phone num "US" style="E164"}}
+	tpl.RegisterHelper("format-phone", func(number string, region string, options *raymond.Options) string {
+		s, err := formatPhone(number, region, options.HashStr("style"))
+		if err != nil {
+			panic(fmt.Errorf("format-phone: %w", err))
+		}
+		return s
+	})
+
+	tpl.RegisterHelper("validate-phone", func(number string, region string) bool {
+		num, err := parsePhone(number, region)
+		if err != nil {
+			panic(fmt.Errorf("validate-phone: %w", err))
+		}
+		return num != nil
+	})
+
+	// Return the region of a number, e.g. "JE" for a Jersey number.
+	tpl.RegisterHelper("phone-region", func(number string, region string) string {
+		num, err := parsePhone(number, region)
+		if err != nil {
+			panic(fmt.Errorf("phone-region: %w", err))
+		}
+		if num == nil {
+			return ""
+		}
+		return phonenumbers.GetRegionCodeForNumber(num)
+	})
+
+	// Return the country calling code of a number, e.g. "44".
+	tpl.RegisterHelper("phone-country-code", func(number string, region string) string {
+		num, err := parsePhone(number, region)
+		if err != nil {
+			panic(fmt.Errorf("phone-country-code: %w", err))
+		}
+		if num == nil {
+			return ""
+		}
+		return strconv.Itoa(int(num.GetCountryCode()))
+	})
+}
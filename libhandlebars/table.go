@@ -0,0 +1,282 @@
+package libhandlebars
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/luthersystems/elps/lisp"
+	"github.com/luthersystems/elps/lisp/lisplib/libjson"
+)
+
+// Column formats of table cells, named after the template helpers they
+// reuse.
+const (
+	// FormatText renders values as strings.
+	FormatText = ""
+	// FormatPrettyNumEN renders numbers with thousands separators, as the
+	// prettyp-num-en helper.
+	FormatPrettyNumEN = "prettyp-num-en"
+	// FormatRoundToNth renders numbers rounded to the column precision, as
+	// the round-to-nth helper.
+	FormatRoundToNth = "round-to-nth"
+	// FormatToInt renders numbers as integers, as the to-int helper.
+	FormatToInt = "to-int"
+	// FormatDateBeautify renders YYYY-MM-DD dates as 02 January 2006.
+	FormatDateBeautify = "date-beautify"
+	// FormatDateDDMMYYSlash renders YYYY-MM-DD dates as 02/01/06.
+	FormatDateDDMMYYSlash = "date-DDMMYY-slash"
+	// FormatDateDDMMYYYYSlash renders YYYY-MM-DD dates as 02/01/2006.
+	FormatDateDDMMYYYYSlash = "date-DDMMYYYY-slash"
+	// FormatDateDDMMYYYY renders YYYY-MM-DD dates as 02-01-2006.
+	FormatDateDDMMYYYY = "date-DDMMYYYY"
+)
+
+// Column defines a column of a rendered table.
+type Column struct {
+	// Header is the column header.
+	Header string `json:"header"`
+	// Field is the dot separated path of the cell value in a row object.
+	Field string `json:"field"`
+	// Format is the cell format, one of the Format constants.
+	Format string `json:"format,omitempty"`
+	// Precision is the number of decimals of FormatRoundToNth cells.
+	Precision int `json:"precision,omitempty"`
+}
+
+// cell is a formatted table cell.
+type cell struct {
+	text string
+	// number is set for numeric cells, which XLSX stores as numbers.
+	number *float64
+}
+
+// validColumns returns an error if a column has an unknown format.
+func validColumns(columns []Column) error {
+	if len(columns) == 0 {
+		return fmt.Errorf("table: no columns")
+	}
+	for _, c := range columns {
+		switch c.Format {
+		case FormatText, FormatPrettyNumEN, FormatRoundToNth, FormatToInt,
+			FormatDateBeautify, FormatDateDDMMYYSlash, FormatDateDDMMYYYYSlash, FormatDateDDMMYYYY:
+		default:
+			return fmt.Errorf("table: column %q: unknown format %q", c.Header, c.Format)
+		}
+		if c.Precision < 0 {
+			return fmt.Errorf("table: column %q: negative precision", c.Header)
+		}
+	}
+	return nil
+}
+
+// lookupField returns the value at a dot separated path in row.
+func lookupField(row interface{}, field string) interface{} {
+	v := row
+	for _, key := range strings.Split(field, ".") {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		v = m[key]
+	}
+	return v
+}
+
+// formatCell formats the value of a column in row.
+func (c Column) formatCell(row interface{}) (cell, error) {
+	v := lookupField(row, c.Field)
+	if v == nil {
+		return cell{}, nil
+	}
+	switch c.Format {
+	case FormatPrettyNumEN:
+		s, err := prettyNumEN(v)
+		if err != nil {
+			return cell{}, fmt.Errorf("column %q: %w", c.Header, err)
+		}
+		f, _ := toFloat(v)
+		return cell{text: s, number: &f}, nil
+	case FormatRoundToNth:
+		f, ok := toFloat(v)
+		if !ok {
+			return cell{}, fmt.Errorf("column %q: invalid number: %v", c.Header, v)
+		}
+		text := strconv.FormatFloat(f, 'f', c.Precision, 64)
+		rounded, _ := strconv.ParseFloat(text, 64)
+		return cell{text: text, number: &rounded}, nil
+	case FormatToInt:
+		i, ok := toInt(v)
+		if !ok {
+			return cell{}, fmt.Errorf("column %q: invalid integer: %v", c.Header, v)
+		}
+		f := float64(i)
+		return cell{text: strconv.Itoa(i), number: &f}, nil
+	case FormatDateBeautify, FormatDateDDMMYYSlash, FormatDateDDMMYYYYSlash, FormatDateDDMMYYYY:
+		s, ok := v.(string)
+		if !ok {
+			return cell{}, fmt.Errorf("column %q: invalid date: %v", c.Header, v)
+		}
+		s, err := formatISODate(c.Format, dateLayouts[c.Format], s)
+		if err != nil {
+			return cell{}, fmt.Errorf("column %q: %w", c.Header, err)
+		}
+		return cell{text: s}, nil
+	}
+	switch v := v.(type) {
+	case string:
+		return cell{text: v}, nil
+	case float64:
+		return cell{text: strconv.FormatFloat(v, 'f', -1, 64)}, nil
+	case bool:
+		return cell{text: strconv.FormatBool(v)}, nil
+	case json.Number:
+		return cell{text: v.String()}, nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return cell{}, fmt.Errorf("column %q: %w", c.Header, err)
+	}
+	return cell{text: string(b)}, nil
+}
+
+var dateLayouts = map[string]string{
+	FormatDateBeautify:      layoutUK,
+	FormatDateDDMMYYSlash:   layoutDMYSlashShort,
+	FormatDateDDMMYYYYSlash: layoutDMYSlashLong,
+	FormatDateDDMMYYYY:      layoutDMYLong,
+}
+
+// TableWriter streams rows of a table to an output format.
+type TableWriter interface {
+	// WriteRow formats and writes a row object, typically decoded from
+	// JSON.
+	WriteRow(row interface{}) error
+	// Close completes the output.  It does not close the underlying
+	// writer.
+	Close() error
+}
+
+// csvWriter writes tables as CSV.
+type csvWriter struct {
+	w       *csv.Writer
+	columns []Column
+	record  []string
+}
+
+// NewCSVWriter returns a writer streaming a CSV table with a header row to
+// w.
+func NewCSVWriter(w io.Writer, columns []Column) (TableWriter, error) {
+	if err := validColumns(columns); err != nil {
+		return nil, err
+	}
+	cw := &csvWriter{
+		w:       csv.NewWriter(w),
+		columns: columns,
+		record:  make([]string, len(columns)),
+	}
+	for i, c := range columns {
+		cw.record[i] = c.Header
+	}
+	if err := cw.w.Write(cw.record); err != nil {
+		return nil, err
+	}
+	return cw, nil
+}
+
+// WriteRow implements TableWriter.
+func (cw *csvWriter) WriteRow(row interface{}) error {
+	for i, c := range cw.columns {
+		cell, err := c.formatCell(row)
+		if err != nil {
+			return err
+		}
+		cw.record[i] = cell.text
+	}
+	return cw.w.Write(cw.record)
+}
+
+// Close implements TableWriter.
+func (cw *csvWriter) Close() error {
+	cw.w.Flush()
+	return cw.w.Error()
+}
+
+// WriteCSV writes rows as a CSV table to w.
+func WriteCSV(w io.Writer, columns []Column, rows []interface{}) error {
+	tw, err := NewCSVWriter(w, columns)
+	if err != nil {
+		return err
+	}
+	return writeRows(tw, rows)
+}
+
+// WriteXLSX writes rows as a single sheet XLSX workbook to w.
+func WriteXLSX(w io.Writer, columns []Column, rows []interface{}) error {
+	tw, err := NewXLSXWriter(w, columns)
+	if err != nil {
+		return err
+	}
+	return writeRows(tw, rows)
+}
+
+func writeRows(tw TableWriter, rows []interface{}) error {
+	for _, row := range rows {
+		if err := tw.WriteRow(row); err != nil {
+			return err
+		}
+	}
+	return tw.Close()
+}
+
+func builtInRenderCSV(env *lisp.LEnv, args *lisp.LVal) *lisp.LVal {
+	var buf bytes.Buffer
+	if lerr := renderTable(env, args, &buf, WriteCSV); lerr != nil {
+		return lerr
+	}
+	return lisp.String(buf.String())
+}
+
+func builtInRenderXLSX(env *lisp.LEnv, args *lisp.LVal) *lisp.LVal {
+	var buf bytes.Buffer
+	if lerr := renderTable(env, args, &buf, WriteXLSX); lerr != nil {
+		return lerr
+	}
+	return lisp.Bytes(buf.Bytes())
+}
+
+// renderTable decodes the column definitions and rows of a builtin call,
+// either JSON bytes or values serializable as JSON, and writes the table.
+func renderTable(env *lisp.LEnv, args *lisp.LVal, w io.Writer, write func(io.Writer, []Column, []interface{}) error) *lisp.LVal {
+	var columns []Column
+	if err := decodeLispJSON(args.Cells[0], &columns); err != nil {
+		return env.Errorf("invalid columns: %v", err)
+	}
+	var rows []interface{}
+	if err := decodeLispJSON(args.Cells[1], &rows); err != nil {
+		return env.Errorf("invalid rows: %v", err)
+	}
+	if err := write(w, columns, rows); err != nil {
+		return env.ErrorConditionf("handlebars-render", "error while rendering table: %v", err)
+	}
+	return nil
+}
+
+func decodeLispJSON(v *lisp.LVal, out interface{}) error {
+	var b []byte
+	switch v.Type {
+	case lisp.LBytes:
+		b = v.Bytes()
+	default:
+		var err error
+		b, err = libjson.DefaultSerializer().Dump(v, false)
+		if err != nil {
+			return err
+		}
+	}
+	return json.Unmarshal(b, out)
+}
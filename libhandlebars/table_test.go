@@ -0,0 +1,68 @@
+package libhandlebars_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/luthersystems/svc/libhandlebars"
+)
+
+var tableColumns = []libhandlebars.Column{
+	{Header: "Claim", Field: "id"},
+	{Header: "Holder", Field: "holder.name"},
+	{Header: "Amount", Field: "amount", Format: libhandlebars.FormatPrettyNumEN},
+	{Header: "Rate", Field: "rate", Format: libhandlebars.FormatRoundToNth, Precision: 1},
+	{Header: "Count", Field: "count", Format: libhandlebars.FormatToInt},
+	{Header: "Date", Field: "date", Format: libhandlebars.FormatDateBeautify},
+}
+
+func tableRows(t *testing.T) []interface{} {
+	var rows []interface{}
+	require.NoError(t, json.Unmarshal([]byte(`[
+		{"id": "c1", "holder": {"name": "Smith, J"}, "amount": "1234.5", "rate": 0.25, "count": 3, "date": "2024-03-01"},
+		{"id": "c2", "holder": {"name": "Doe"}, "amount": 10, "rate": "1.04", "count": "7"}
+	]`), &rows))
+	return rows
+}
+
+func TestWriteCSV(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, libhandlebars.WriteCSV(&buf, tableColumns, tableRows(t)))
+	require.Equal(t, `Claim,Holder,Amount,Rate,Count,Date
+c1,"Smith, J","1,234.50",0.2,3,01 March 2024
+c2,Doe,10.00,1.0,7,
+`, buf.String())
+
+	buf.Reset()
+	bad := []interface{}{map[string]interface{}{"amount": "x"}}
+	require.Error(t, libhandlebars.WriteCSV(&buf, tableColumns, bad))
+	require.Error(t, libhandlebars.WriteCSV(&buf, []libhandlebars.Column{{Header: "x", Format: "unknown"}}, nil))
+}
+
+func TestWriteXLSX(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, libhandlebars.WriteXLSX(&buf, tableColumns, tableRows(t)))
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.NoError(t, err)
+	parts := make(map[string]string)
+	for _, f := range zr.File {
+		r, err := f.Open()
+		require.NoError(t, err)
+		b, err := io.ReadAll(r)
+		require.NoError(t, err)
+		parts[f.Name] = string(b)
+	}
+	require.Contains(t, parts, "[Content_Types].xml")
+	require.Contains(t, parts, "xl/workbook.xml")
+	sheet := parts["xl/worksheets/sheet1.xml"]
+	require.Contains(t, sheet, `<c r="A1" s="1" t="inlineStr"><is><t>Claim</t></is></c>`)
+	require.Contains(t, sheet, `<c r="B2" t="inlineStr"><is><t xml:space="preserve">Smith, J</t></is></c>`)
+	require.Contains(t, sheet, `<c r="C2" s="3"><v>1234.5</v></c>`)
+	require.Contains(t, sheet, `<c r="E3" s="2"><v>7</v></c>`)
+	require.NotContains(t, sheet, `r="F3"`)
+}
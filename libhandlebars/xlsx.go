@@ -0,0 +1,168 @@
+package libhandlebars
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/xml"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// XLSX cell styles, indexes of cellXfs in xlsxStyles.
+const (
+	xlsxStyleDefault = 0
+	xlsxStyleHeader  = 1
+	xlsxStyleInteger = 2
+	xlsxStyleNumber  = 3
+)
+
+const xlsxContentTypes = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types"><Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/><Default Extension="xml" ContentType="application/xml"/><Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/><Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/><Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/></Types>`
+
+const xlsxRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/></Relationships>`
+
+const xlsxWorkbook = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets><sheet name="Sheet1" sheetId="1" r:id="rId1"/></sheets></workbook>`
+
+const xlsxWorkbookRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/><Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/></Relationships>`
+
+const xlsxStyles = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><fonts count="2"><font><sz val="11"/><name val="Calibri"/></font><font><b/><sz val="11"/><name val="Calibri"/></font></fonts><fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills><borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders><cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs><cellXfs count="4"><xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/><xf numFmtId="0" fontId="1" fillId="0" borderId="0" xfId="0" applyFont="1"/><xf numFmtId="1" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/><xf numFmtId="4" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/></cellXfs></styleSheet>`
+
+const xlsxSheetStart = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`
+
+const xlsxSheetEnd = `</sheetData></worksheet>`
+
+// xlsxWriter streams a single sheet workbook.  Rows are written to the
+// sheet part as they arrive, and the remaining parts on Close.
+type xlsxWriter struct {
+	zw      *zip.Writer
+	sheet   *bufio.Writer
+	columns []Column
+	row     int
+}
+
+// NewXLSXWriter returns a writer streaming a single sheet XLSX workbook with
+// a bold header row to w.  Numeric columns are stored as numbers, and
+// other cells as inline strings.
+func NewXLSXWriter(w io.Writer, columns []Column) (TableWriter, error) {
+	if err := validColumns(columns); err != nil {
+		return nil, err
+	}
+	zw := zip.NewWriter(w)
+	part, err := zw.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return nil, err
+	}
+	xw := &xlsxWriter{
+		zw:      zw,
+		sheet:   bufio.NewWriter(part),
+		columns: columns,
+	}
+	if _, err := xw.sheet.WriteString(xlsxSheetStart); err != nil {
+		return nil, err
+	}
+	headers := make([]cell, len(columns))
+	for i, c := range columns {
+		headers[i] = cell{text: c.Header}
+	}
+	if err := xw.writeCells(headers, true); err != nil {
+		return nil, err
+	}
+	return xw, nil
+}
+
+// WriteRow implements TableWriter.
+func (xw *xlsxWriter) WriteRow(row interface{}) error {
+	cells := make([]cell, len(xw.columns))
+	for i, c := range xw.columns {
+		var err error
+		cells[i], err = c.formatCell(row)
+		if err != nil {
+			return err
+		}
+	}
+	return xw.writeCells(cells, false)
+}
+
+func (xw *xlsxWriter) writeCells(cells []cell, header bool) error {
+	xw.row++
+	var b strings.Builder
+	b.WriteString(`<row r="`)
+	b.WriteString(strconv.Itoa(xw.row))
+	b.WriteString(`">`)
+	for i, c := range cells {
+		ref := xlsxColumnName(i) + strconv.Itoa(xw.row)
+		switch {
+		case header:
+			b.WriteString(`<c r="` + ref + `" s="` + strconv.Itoa(xlsxStyleHeader) + `" t="inlineStr"><is><t>`)
+			xmlEscape(&b, c.text)
+			b.WriteString(`</t></is></c>`)
+		case c.number != nil:
+			style := xlsxStyleDefault
+			switch xw.columns[i].Format {
+			case FormatToInt:
+				style = xlsxStyleInteger
+			case FormatPrettyNumEN:
+				style = xlsxStyleNumber
+			}
+			b.WriteString(`<c r="` + ref + `" s="` + strconv.Itoa(style) + `"><v>`)
+			b.WriteString(strconv.FormatFloat(*c.number, 'f', -1, 64))
+			b.WriteString(`</v></c>`)
+		case c.text != "":
+			b.WriteString(`<c r="` + ref + `" t="inlineStr"><is><t xml:space="preserve">`)
+			xmlEscape(&b, c.text)
+			b.WriteString(`</t></is></c>`)
+		}
+	}
+	b.WriteString(`</row>`)
+	_, err := xw.sheet.WriteString(b.String())
+	return err
+}
+
+// Close implements TableWriter.
+func (xw *xlsxWriter) Close() error {
+	if _, err := xw.sheet.WriteString(xlsxSheetEnd); err != nil {
+		return err
+	}
+	if err := xw.sheet.Flush(); err != nil {
+		return err
+	}
+	for _, part := range []struct {
+		name    string
+		content string
+	}{
+		{"[Content_Types].xml", xlsxContentTypes},
+		{"_rels/.rels", xlsxRels},
+		{"xl/workbook.xml", xlsxWorkbook},
+		{"xl/_rels/workbook.xml.rels", xlsxWorkbookRels},
+		{"xl/styles.xml", xlsxStyles},
+	} {
+		w, err := xw.zw.Create(part.name)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(w, part.content); err != nil {
+			return err
+		}
+	}
+	return xw.zw.Close()
+}
+
+// xlsxColumnName returns the spreadsheet name of a zero based column index,
+// e.g. A, Z, AA.
+func xlsxColumnName(i int) string {
+	name := ""
+	for i++; i > 0; i = (i - 1) / 26 {
+		name = string(rune('A'+(i-1)%26)) + name
+	}
+	return name
+}
+
+func xmlEscape(b *strings.Builder, s string) {
+	_ = xml.EscapeText(b, []byte(s))
+}
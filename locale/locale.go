@@ -0,0 +1,117 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+/*
+Package locale resolves the language and time zone of requests.  The
+resolved locale is stored in the request context by the oracle, and read back
+with FromContext, Language and Location by handlers and by renderers such as
+libhandlebars.RenderContext and libdates.Today, so responses and documents
+respect the user's locale consistently.
+*/
+package locale
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"golang.org/x/text/language"
+)
+
+// Locale is the language and time zone of a request.
+type Locale struct {
+	// Language is a BCP 47 language tag, e.g. "en-GB".
+	Language string
+	// Location is the time zone.  A nil location is UTC.
+	Location *time.Location
+}
+
+type localeCtxKey struct{}
+
+// NewContext returns a context holding l.
+func NewContext(ctx context.Context, l Locale) context.Context {
+	return context.WithValue(ctx, localeCtxKey{}, l)
+}
+
+// FromContext returns the locale stored in ctx by NewContext, if any.
+func FromContext(ctx context.Context) (Locale, bool) {
+	l, ok := ctx.Value(localeCtxKey{}).(Locale)
+	return l, ok
+}
+
+// Language returns the language stored in ctx, or an empty string.
+func Language(ctx context.Context) string {
+	l, _ := FromContext(ctx)
+	return l.Language
+}
+
+// Location returns the time zone stored in ctx, or UTC.
+func Location(ctx context.Context) *time.Location {
+	l, _ := FromContext(ctx)
+	if l.Location == nil {
+		return time.UTC
+	}
+	return l.Location
+}
+
+// Matcher matches requested languages with supported languages.
+type Matcher struct {
+	supported []language.Tag
+	matcher   language.Matcher
+}
+
+// NewMatcher returns a matcher of the supported languages, given as BCP 47
+// tags.  The first language is the default.
+func NewMatcher(supported ...string) (*Matcher, error) {
+	if len(supported) == 0 {
+		return nil, fmt.Errorf("locale: no supported languages")
+	}
+	tags := make([]language.Tag, 0, len(supported))
+	for _, s := range supported {
+		tag, err := language.Parse(s)
+		if err != nil {
+			return nil, fmt.Errorf("locale: invalid language %q: %w", s, err)
+		}
+		tags = append(tags, tag)
+	}
+	return &Matcher{supported: tags, matcher: language.NewMatcher(tags)}, nil
+}
+
+// Default returns the default language.
+func (m *Matcher) Default() string {
+	return m.supported[0].String()
+}
+
+// Match returns the supported language best matching the first preference
+// which matches any.  Each preference is a language tag or an
+// Accept-Language header value, in order of precedence.  The default
+// language is returned if no preference matches.
+func (m *Matcher) Match(prefs ...string) string {
+	for _, pref := range prefs {
+		if strings.TrimSpace(pref) == "" {
+			continue
+		}
+		tags, _, err := language.ParseAcceptLanguage(pref)
+		if err != nil || len(tags) == 0 {
+			continue
+		}
+		_, i, conf := m.matcher.Match(tags...)
+		if conf != language.No {
+			return m.supported[i].String()
+		}
+	}
+	return m.Default()
+}
+
+// LoadLocation returns the time zone with an IANA name, e.g.
+// "Europe/London".  The local time zone of the server cannot be loaded.
+func LoadLocation(name string) (*time.Location, error) {
+	if name == "" || name == "Local" {
+		return nil, fmt.Errorf("locale: invalid time zone %q", name)
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("locale: invalid time zone %q: %w", name, err)
+	}
+	return loc, nil
+}
@@ -0,0 +1,57 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package locale
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestContext(t *testing.T) {
+	ctx := context.Background()
+	_, ok := FromContext(ctx)
+	require.False(t, ok)
+	require.Equal(t, "", Language(ctx))
+	require.Equal(t, time.UTC, Location(ctx))
+
+	london, err := LoadLocation("Europe/London")
+	require.NoError(t, err)
+	ctx = NewContext(ctx, Locale{Language: "en-GB", Location: london})
+	l, ok := FromContext(ctx)
+	require.True(t, ok)
+	require.Equal(t, "en-GB", l.Language)
+	require.Equal(t, "en-GB", Language(ctx))
+	require.Equal(t, london, Location(ctx))
+}
+
+func TestMatcher(t *testing.T) {
+	_, err := NewMatcher()
+	require.Error(t, err)
+	_, err = NewMatcher("en", "not a tag!")
+	require.Error(t, err)
+
+	m, err := NewMatcher("en-GB", "fr", "de-CH")
+	require.NoError(t, err)
+	require.Equal(t, "en-GB", m.Default())
+	require.Equal(t, "en-GB", m.Match())
+	require.Equal(t, "fr", m.Match("fr-CA"))
+	require.Equal(t, "de-CH", m.Match("", "ja;q=0.9, de-CH;q=0.8, fr;q=0.5"))
+	// Earlier preferences take precedence.
+	require.Equal(t, "fr", m.Match("fr", "de-CH"))
+	// Unmatched and invalid preferences are skipped.
+	require.Equal(t, "de-CH", m.Match("ja", "!!", "de-CH"))
+	require.Equal(t, "en-GB", m.Match("ja"))
+}
+
+func TestLoadLocation(t *testing.T) {
+	loc, err := LoadLocation("America/New_York")
+	require.NoError(t, err)
+	require.Equal(t, "America/New_York", loc.String())
+	for _, name := range []string{"", "Local", "Mars/Olympus_Mons"} {
+		_, err := LoadLocation(name)
+		require.Error(t, err, name)
+	}
+}
@@ -0,0 +1,63 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+/*
+Structured logger interface with logrus and zap implementations, so that
+services standardized on either library share a single logging stack with the
+svc packages.
+*/
+package logging
+
+// Fields are structured log fields.
+type Fields map[string]interface{}
+
+// Level is a log level.
+type Level uint32
+
+// Log levels, from most to least severe.
+const (
+	ErrorLevel Level = iota
+	WarnLevel
+	InfoLevel
+	DebugLevel
+)
+
+// String returns the name of the level.
+func (l Level) String() string {
+	switch l {
+	case ErrorLevel:
+		return "error"
+	case WarnLevel:
+		return "warning"
+	case InfoLevel:
+		return "info"
+	case DebugLevel:
+		return "debug"
+	default:
+		return "unknown"
+	}
+}
+
+// Logger is a structured logger.  Implementations are immutable: the With
+// methods return a new Logger with the additional fields.
+type Logger interface {
+	// WithField returns a logger adding a field to messages.
+	WithField(key string, value interface{}) Logger
+	// WithFields returns a logger adding fields to messages.
+	WithFields(fields Fields) Logger
+	// WithError returns a logger adding err to messages, as the field
+	// "error".
+	WithError(err error) Logger
+
+	// Log logs a message at level.
+	Log(level Level, msg string)
+
+	Debug(args ...interface{})
+	Info(args ...interface{})
+	Warn(args ...interface{})
+	Error(args ...interface{})
+
+	Debugf(format string, args ...interface{})
+	Infof(format string, args ...interface{})
+	Warnf(format string, args ...interface{})
+	Errorf(format string, args ...interface{})
+}
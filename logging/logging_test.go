@@ -0,0 +1,68 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package logging
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestLogrus(t *testing.T) {
+	logger, hook := test.NewNullLogger()
+	l := NewLogrus(logrus.NewEntry(logger))
+	l.WithFields(Fields{"a": 1}).WithField("b", "x").WithError(errors.New("boom")).Warnf("hello %s", "world")
+	l.Debug("hidden")
+
+	require.Len(t, hook.AllEntries(), 1)
+	entry := hook.LastEntry()
+	require.Equal(t, logrus.WarnLevel, entry.Level)
+	require.Equal(t, "hello world", entry.Message)
+	require.Equal(t, 1, entry.Data["a"])
+	require.Equal(t, "x", entry.Data["b"])
+	require.EqualError(t, entry.Data[logrus.ErrorKey].(error), "boom")
+
+	e, ok := LogrusEntry(l)
+	require.True(t, ok)
+	require.Equal(t, logger, e.Logger)
+}
+
+func TestZap(t *testing.T) {
+	core, logs := observer.New(zapcore.InfoLevel)
+	l := NewZap(zap.New(core))
+	l.WithFields(Fields{"b": "x", "a": 1}).WithError(errors.New("boom")).Log(WarnLevel, "hello")
+	l.Infof("count %d", 2)
+	l.Debug("hidden")
+
+	entries := logs.AllUntimed()
+	require.Len(t, entries, 2)
+	require.Equal(t, zapcore.WarnLevel, entries[0].Level)
+	require.Equal(t, "hello", entries[0].Message)
+	require.Equal(t, map[string]interface{}{"a": int64(1), "b": "x", "error": "boom"}, entries[0].ContextMap())
+	require.Equal(t, "count 2", entries[1].Message)
+
+	_, ok := LogrusEntry(l)
+	require.False(t, ok)
+}
+
+func TestToLogrus(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	entry := ToLogrus(NewZap(zap.New(core)), logrus.InfoLevel)
+	entry.WithField("req_id", "r1").Errorf("failed %d", 3)
+	entry.Debug("hidden")
+
+	entries := logs.AllUntimed()
+	require.Len(t, entries, 1)
+	require.Equal(t, zapcore.ErrorLevel, entries[0].Level)
+	require.Equal(t, "failed 3", entries[0].Message)
+	require.Equal(t, map[string]interface{}{"req_id": "r1"}, entries[0].ContextMap())
+
+	logrusEntry := logrus.NewEntry(logrus.New())
+	require.Equal(t, logrusEntry, ToLogrus(NewLogrus(logrusEntry), logrus.InfoLevel))
+}
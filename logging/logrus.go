@@ -0,0 +1,131 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package logging
+
+import (
+	"io"
+
+	"github.com/sirupsen/logrus"
+)
+
+// logrusLogger implements Logger with a logrus entry.
+type logrusLogger struct {
+	entry *logrus.Entry
+}
+
+// NewLogrus returns a Logger writing to entry.
+func NewLogrus(entry *logrus.Entry) Logger {
+	return &logrusLogger{entry: entry}
+}
+
+// LogrusEntry returns the logrus entry of a Logger created by NewLogrus.
+func LogrusEntry(l Logger) (*logrus.Entry, bool) {
+	ll, ok := l.(*logrusLogger)
+	if !ok {
+		return nil, false
+	}
+	return ll.entry, true
+}
+
+func (l *logrusLogger) WithField(key string, value interface{}) Logger {
+	return &logrusLogger{entry: l.entry.WithField(key, value)}
+}
+
+func (l *logrusLogger) WithFields(fields Fields) Logger {
+	return &logrusLogger{entry: l.entry.WithFields(logrus.Fields(fields))}
+}
+
+func (l *logrusLogger) WithError(err error) Logger {
+	return &logrusLogger{entry: l.entry.WithError(err)}
+}
+
+func (l *logrusLogger) Log(level Level, msg string) {
+	l.entry.Log(logrusLevel(level), msg)
+}
+
+func (l *logrusLogger) Debug(args ...interface{}) { l.entry.Debug(args...) }
+func (l *logrusLogger) Info(args ...interface{})  { l.entry.Info(args...) }
+func (l *logrusLogger) Warn(args ...interface{})  { l.entry.Warn(args...) }
+func (l *logrusLogger) Error(args ...interface{}) { l.entry.Error(args...) }
+
+func (l *logrusLogger) Debugf(format string, args ...interface{}) { l.entry.Debugf(format, args...) }
+func (l *logrusLogger) Infof(format string, args ...interface{})  { l.entry.Infof(format, args...) }
+func (l *logrusLogger) Warnf(format string, args ...interface{})  { l.entry.Warnf(format, args...) }
+func (l *logrusLogger) Errorf(format string, args ...interface{}) { l.entry.Errorf(format, args...) }
+
+// logrusLevel returns the logrus level of level.
+func logrusLevel(level Level) logrus.Level {
+	switch level {
+	case ErrorLevel:
+		return logrus.ErrorLevel
+	case WarnLevel:
+		return logrus.WarnLevel
+	case InfoLevel:
+		return logrus.InfoLevel
+	default:
+		return logrus.DebugLevel
+	}
+}
+
+// levelOfLogrus returns the level of a logrus level.  Panic and fatal
+// messages are logged as errors, and trace messages as debug.
+func levelOfLogrus(level logrus.Level) Level {
+	switch level {
+	case logrus.PanicLevel, logrus.FatalLevel, logrus.ErrorLevel:
+		return ErrorLevel
+	case logrus.WarnLevel:
+		return WarnLevel
+	case logrus.InfoLevel:
+		return InfoLevel
+	default:
+		return DebugLevel
+	}
+}
+
+// Hook is a logrus hook forwarding entries to a Logger.
+type Hook struct {
+	logger Logger
+}
+
+// NewHook returns a logrus hook forwarding entries to l.
+func NewHook(l Logger) *Hook {
+	return &Hook{logger: l}
+}
+
+// Levels implements logrus.Hook.
+func (h *Hook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+// Fire implements logrus.Hook.
+func (h *Hook) Fire(entry *logrus.Entry) error {
+	l := h.logger
+	if len(entry.Data) > 0 {
+		l = l.WithFields(Fields(entry.Data))
+	}
+	l.Log(levelOfLogrus(entry.Level), entry.Message)
+	return nil
+}
+
+// discardFormatter formats nothing, as entries of bridged loggers are only
+// written by their hook.
+type discardFormatter struct{}
+
+func (discardFormatter) Format(*logrus.Entry) ([]byte, error) {
+	return nil, nil
+}
+
+// ToLogrus returns a logrus entry for packages which log with logrus.  Loggers
+// created by NewLogrus return their entry.  Otherwise entries at level or
+// above are forwarded to l by a Hook, and not written elsewhere.
+func ToLogrus(l Logger, level logrus.Level) *logrus.Entry {
+	if entry, ok := LogrusEntry(l); ok {
+		return entry
+	}
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	logger.SetFormatter(discardFormatter{})
+	logger.SetLevel(level)
+	logger.AddHook(NewHook(l))
+	return logrus.NewEntry(logger)
+}
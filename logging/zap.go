@@ -0,0 +1,73 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package logging
+
+import (
+	"sort"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// zapLogger implements Logger with a zap logger.
+type zapLogger struct {
+	logger *zap.SugaredLogger
+}
+
+// NewZap returns a Logger writing to logger.
+func NewZap(logger *zap.Logger) Logger {
+	return &zapLogger{logger: logger.Sugar()}
+}
+
+func (l *zapLogger) WithField(key string, value interface{}) Logger {
+	return &zapLogger{logger: l.logger.With(zap.Any(key, value))}
+}
+
+// WithFields adds fields in key order, as zap writes fields in the order
+// they are added.
+func (l *zapLogger) WithFields(fields Fields) Logger {
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	args := make([]interface{}, 0, len(fields))
+	for _, k := range keys {
+		args = append(args, zap.Any(k, fields[k]))
+	}
+	return &zapLogger{logger: l.logger.With(args...)}
+}
+
+func (l *zapLogger) WithError(err error) Logger {
+	return &zapLogger{logger: l.logger.With(zap.Error(err))}
+}
+
+func (l *zapLogger) Log(level Level, msg string) {
+	if ce := l.logger.Desugar().Check(zapLevel(level), msg); ce != nil {
+		ce.Write()
+	}
+}
+
+func (l *zapLogger) Debug(args ...interface{}) { l.logger.Debug(args...) }
+func (l *zapLogger) Info(args ...interface{})  { l.logger.Info(args...) }
+func (l *zapLogger) Warn(args ...interface{})  { l.logger.Warn(args...) }
+func (l *zapLogger) Error(args ...interface{}) { l.logger.Error(args...) }
+
+func (l *zapLogger) Debugf(format string, args ...interface{}) { l.logger.Debugf(format, args...) }
+func (l *zapLogger) Infof(format string, args ...interface{})  { l.logger.Infof(format, args...) }
+func (l *zapLogger) Warnf(format string, args ...interface{})  { l.logger.Warnf(format, args...) }
+func (l *zapLogger) Errorf(format string, args ...interface{}) { l.logger.Errorf(format, args...) }
+
+// zapLevel returns the zap level of level.
+func zapLevel(level Level) zapcore.Level {
+	switch level {
+	case ErrorLevel:
+		return zapcore.ErrorLevel
+	case WarnLevel:
+		return zapcore.WarnLevel
+	case InfoLevel:
+		return zapcore.InfoLevel
+	default:
+		return zapcore.DebugLevel
+	}
+}
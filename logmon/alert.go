@@ -0,0 +1,262 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package logmon
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultAlertDedupWindow = 5 * time.Minute
+	defaultAlertRateLimit   = 10
+	defaultAlertTimeout     = 5 * time.Second
+	alertQueueSize          = 100
+)
+
+// Alert is a log entry forwarded to an alerting webhook.
+type Alert struct {
+	// Text is a one line summary, which chat webhooks (Slack, Teams)
+	// display as the message.
+	Text      string    `json:"text"`
+	Service   string    `json:"service,omitempty"`
+	Level     string    `json:"level"`
+	Message   string    `json:"message"`
+	ReqID     string    `json:"req_id,omitempty"`
+	Error     string    `json:"error,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+	// Suppressed is the number of identical alerts suppressed since the
+	// previous alert was sent.
+	Suppressed int `json:"suppressed,omitempty"`
+}
+
+// AlertFormatter encodes the request body of an alert.
+type AlertFormatter func(a *Alert) ([]byte, error)
+
+// AlertOption configures an AlertHook.
+type AlertOption func(*AlertHook)
+
+// WithAlertService sets the service name included in alerts.
+func WithAlertService(name string) AlertOption {
+	return func(h *AlertHook) {
+		h.service = name
+	}
+}
+
+// WithAlertHTTPClient sets the client sending alerts.
+func WithAlertHTTPClient(c *http.Client) AlertOption {
+	return func(h *AlertHook) {
+		h.client = c
+	}
+}
+
+// WithAlertDedupWindow sets the window within which alerts with the same
+// level and message are suppressed.  Defaults to 5 minutes.
+func WithAlertDedupWindow(d time.Duration) AlertOption {
+	return func(h *AlertHook) {
+		h.dedupWindow = d
+	}
+}
+
+// WithAlertRateLimit sets the maximum number of alerts sent per minute.
+// Further alerts are dropped.  Defaults to 10.
+func WithAlertRateLimit(perMinute int) AlertOption {
+	return func(h *AlertHook) {
+		h.rateLimit = perMinute
+	}
+}
+
+// WithAlertFormatter sets the encoding of alerts, e.g. to send PagerDuty
+// events.  Alerts are encoded as JSON by default, which Slack and Teams
+// webhooks accept.
+func WithAlertFormatter(f AlertFormatter) AlertOption {
+	return func(h *AlertHook) {
+		h.format = f
+	}
+}
+
+// AlertHook is a logrus hook forwarding Error, Fatal and Panic entries to an
+// alerting webhook.  Alerts are sent asynchronously, except for Fatal
+// entries which are sent before the process exits.  Repeated alerts are
+// deduplicated and rate limited, and alerts are dropped rather than
+// blocking logging if the webhook is slow.  Close stops the hook.
+type AlertHook struct {
+	url         string
+	service     string
+	client      *http.Client
+	dedupWindow time.Duration
+	rateLimit   int
+	format      AlertFormatter
+	now         func() time.Time
+
+	queue chan *Alert
+	done  chan struct{}
+	once  sync.Once
+
+	mut         sync.Mutex
+	closed      bool
+	sent        map[string]time.Time
+	suppressed  map[string]int
+	windowStart time.Time
+	windowCount int
+}
+
+// NewAlertHook returns a hook sending alerts to the webhook url.
+func NewAlertHook(url string, opts ...AlertOption) *AlertHook {
+	h := &AlertHook{
+		url:         url,
+		client:      &http.Client{Timeout: defaultAlertTimeout},
+		dedupWindow: defaultAlertDedupWindow,
+		rateLimit:   defaultAlertRateLimit,
+		format:      func(a *Alert) ([]byte, error) { return json.Marshal(a) },
+		now:         time.Now,
+		queue:       make(chan *Alert, alertQueueSize),
+		done:        make(chan struct{}),
+		sent:        make(map[string]time.Time),
+		suppressed:  make(map[string]int),
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	go h.run()
+	return h
+}
+
+// Levels implements logrus.Hook.
+func (h *AlertHook) Levels() []log.Level {
+	return []log.Level{log.PanicLevel, log.FatalLevel, log.ErrorLevel}
+}
+
+// Fire implements logrus.Hook.
+func (h *AlertHook) Fire(e *log.Entry) error {
+	h.mut.Lock()
+	a := h.alert(e)
+	if a != nil && e.Level != log.FatalLevel {
+		select {
+		case h.queue <- a:
+		default:
+		}
+	}
+	h.mut.Unlock()
+	if a != nil && e.Level == log.FatalLevel {
+		// The process exits once the hook returns.
+		h.send(a)
+	}
+	return nil
+}
+
+// alert returns the alert of e, or nil if it is suppressed.  The caller
+// must hold mut.
+func (h *AlertHook) alert(e *log.Entry) *Alert {
+	if h.closed {
+		return nil
+	}
+	now := h.now()
+	key := e.Level.String() + "\x00" + e.Message
+	if last, ok := h.sent[key]; ok && now.Sub(last) < h.dedupWindow {
+		h.suppressed[key]++
+		return nil
+	}
+	if now.Sub(h.windowStart) >= time.Minute {
+		h.windowStart, h.windowCount = now, 0
+	}
+	if h.rateLimit > 0 && h.windowCount >= h.rateLimit {
+		return nil
+	}
+	h.windowCount++
+	h.sent[key] = now
+	for k, t := range h.sent {
+		if now.Sub(t) >= h.dedupWindow {
+			delete(h.sent, k)
+		}
+	}
+	a := &Alert{
+		Service:    h.service,
+		Level:      e.Level.String(),
+		Message:    e.Message,
+		Timestamp:  e.Time,
+		Suppressed: h.suppressed[key],
+	}
+	delete(h.suppressed, key)
+	if reqID, ok := e.Data["req_id"]; ok {
+		a.ReqID = fmt.Sprint(reqID)
+	}
+	if err, ok := e.Data[log.ErrorKey].(error); ok {
+		a.Error = err.Error()
+	}
+	a.Text = alertText(a)
+	return a
+}
+
+// alertText summarizes an alert on one line.
+func alertText(a *Alert) string {
+	var b bytes.Buffer
+	if a.Service != "" {
+		fmt.Fprintf(&b, "[%s] ", a.Service)
+	}
+	fmt.Fprintf(&b, "%s: %s", a.Level, a.Message)
+	if a.Error != "" {
+		fmt.Fprintf(&b, ": %s", a.Error)
+	}
+	if a.ReqID != "" {
+		fmt.Fprintf(&b, " (req_id=%s)", a.ReqID)
+	}
+	if a.Suppressed > 0 {
+		fmt.Fprintf(&b, " [%d similar suppressed]", a.Suppressed)
+	}
+	return b.String()
+}
+
+func (h *AlertHook) run() {
+	defer close(h.done)
+	for a := range h.queue {
+		h.send(a)
+	}
+}
+
+// send posts an alert.  Errors are dropped, as logging them would raise
+// further alerts.
+func (h *AlertHook) send(a *Alert) {
+	body, err := h.format(a)
+	if err != nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), defaultAlertTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.url, bytes.NewReader(body))
+	if err != nil {
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+}
+
+// Close stops accepting alerts and waits for queued alerts to be sent, or
+// until ctx is done.
+func (h *AlertHook) Close(ctx context.Context) error {
+	h.once.Do(func() {
+		h.mut.Lock()
+		h.closed = true
+		close(h.queue)
+		h.mut.Unlock()
+	})
+	select {
+	case <-h.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
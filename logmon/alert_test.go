@@ -0,0 +1,64 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package logmon
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAlertHook(t *testing.T) {
+	var mut sync.Mutex
+	var alerts []*Alert
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		a := &Alert{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(a))
+		mut.Lock()
+		defer mut.Unlock()
+		alerts = append(alerts, a)
+	}))
+	defer srv.Close()
+
+	now := time.Now()
+	hook := NewAlertHook(srv.URL, WithAlertService("oracle"), WithAlertRateLimit(3), WithAlertDedupWindow(time.Minute))
+	hook.now = func() time.Time { return now }
+	logger := log.New()
+	logger.AddHook(hook)
+
+	logger.WithField("req_id", "req-1").WithError(errors.New("timeout")).Error("phylum call failed")
+	logger.WithField("req_id", "req-2").Error("phylum call failed")
+	logger.Warn("not forwarded")
+	logger.Error("second")
+	logger.Error("third")
+	// rate limited.
+	logger.Error("fourth")
+
+	// after the dedup window, the suppressed count is reported.
+	now = now.Add(2 * time.Minute)
+	logger.Error("phylum call failed")
+
+	require.NoError(t, hook.Close(context.Background()))
+	logger.Error("after close")
+
+	mut.Lock()
+	defer mut.Unlock()
+	require.Len(t, alerts, 4)
+	require.Equal(t, "oracle", alerts[0].Service)
+	require.Equal(t, "error", alerts[0].Level)
+	require.Equal(t, "req-1", alerts[0].ReqID)
+	require.Equal(t, "timeout", alerts[0].Error)
+	require.Equal(t, "[oracle] error: phylum call failed: timeout (req_id=req-1)", alerts[0].Text)
+	require.Equal(t, "second", alerts[1].Message)
+	require.Equal(t, "third", alerts[2].Message)
+	require.Equal(t, "phylum call failed", alerts[3].Message)
+	require.Equal(t, 1, alerts[3].Suppressed)
+}
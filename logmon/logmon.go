@@ -8,14 +8,26 @@ https://blog.mafr.de/2019/03/03/monitoring-log-statements-in-go/
 package logmon
 
 import (
+	"github.com/luthersystems/svc/promreg"
 	"github.com/prometheus/client_golang/prometheus"
-	"github.com/prometheus/client_golang/prometheus/promauto"
 	log "github.com/sirupsen/logrus"
 )
 
-// NewPrometheusHook creates prometheus metrics.
+// NewPrometheusHook creates prometheus metrics registered with the default
+// prometheus registerer.  Metrics registered by a previous hook are reused.
 func NewPrometheusHook() *PrometheusHook {
-	levelCounter := promauto.NewCounterVec(
+	h, err := NewPrometheusHookWithRegisterer(prometheus.DefaultRegisterer)
+	if err != nil {
+		panic(err)
+	}
+	return h
+}
+
+// NewPrometheusHookWithRegisterer creates prometheus metrics registered with
+// reg.  Metrics already registered with reg are reused.  A nil reg disables
+// registration.
+func NewPrometheusHookWithRegisterer(reg prometheus.Registerer) (*PrometheusHook, error) {
+	levelCounter := prometheus.NewCounterVec(
 		prometheus.CounterOpts{
 			Name: "log_statements_total",
 			Help: "Number of log statements, differentiated by log level.",
@@ -23,7 +35,7 @@ func NewPrometheusHook() *PrometheusHook {
 		[]string{"level"},
 	)
 
-	msgCounter := promauto.NewCounterVec(
+	msgCounter := prometheus.NewCounterVec(
 		prometheus.CounterOpts{
 			Name: "log_statements_message",
 			Help: "Number of log statements, differentiated by log level and message.",
@@ -31,10 +43,18 @@ func NewPrometheusHook() *PrometheusHook {
 		[]string{"level", "message"},
 	)
 
+	var err error
+	if levelCounter, err = promreg.Register(reg, levelCounter); err != nil {
+		return nil, err
+	}
+	if msgCounter, err = promreg.Register(reg, msgCounter); err != nil {
+		return nil, err
+	}
+
 	return &PrometheusHook{
 		lcounter: levelCounter,
 		mcounter: msgCounter,
-	}
+	}, nil
 }
 
 // PrometheusHook tracks log metrics.
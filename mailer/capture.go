@@ -0,0 +1,153 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package mailer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/luthersystems/svc/docstore"
+)
+
+// Mailer sends emails.
+type Mailer interface {
+	// Send sends an html email to a recipient.
+	Send(ctx context.Context, content string, email string, subject string) error
+}
+
+var _ Mailer = &SES{}
+var _ Mailer = &CaptureMailer{}
+
+// Config configures a mailer.
+type Config struct {
+	// Region is the AWS region of SES.
+	Region string
+	// Sender is the source address of emails.
+	Sender string
+	// DryRun captures messages with a CaptureMailer instead of sending
+	// them with SES.
+	DryRun bool
+	// CaptureStore optionally stores messages captured in dry-run mode.
+	CaptureStore docstore.Putter
+	// CapturePrefix prefixes the keys of messages in CaptureStore.
+	CapturePrefix string
+}
+
+// New returns a mailer for the configuration.
+func New(cfg Config) (Mailer, error) {
+	if cfg.DryRun {
+		var opts []CaptureOption
+		if cfg.CaptureStore != nil {
+			opts = append(opts, WithCaptureStore(cfg.CaptureStore, cfg.CapturePrefix))
+		}
+		return NewCaptureMailer(cfg.Sender, opts...), nil
+	}
+	return NewSES(cfg.Region, cfg.Sender)
+}
+
+// Message is a captured email.
+type Message struct {
+	Sender  string    `json:"sender"`
+	To      string    `json:"to"`
+	Subject string    `json:"subject"`
+	Content string    `json:"content"`
+	SentAt  time.Time `json:"sent_at"`
+}
+
+// CaptureOption configures a CaptureMailer.
+type CaptureOption func(*CaptureMailer)
+
+// WithCaptureStore writes captured messages as JSON documents to store,
+// under keys with the given prefix, e.g. "mailer/".
+func WithCaptureStore(store docstore.Putter, prefix string) CaptureOption {
+	return func(m *CaptureMailer) {
+		m.store = store
+		m.prefix = prefix
+	}
+}
+
+// CaptureMailer records emails instead of sending them, for tests and
+// staging environments.  It is safe for concurrent use.
+type CaptureMailer struct {
+	sender   string
+	store    docstore.Putter
+	prefix   string
+	mut      sync.Mutex
+	messages []Message
+	seq      int
+}
+
+// NewCaptureMailer returns a mailer which captures messages in memory.
+func NewCaptureMailer(sender string, opts ...CaptureOption) *CaptureMailer {
+	m := &CaptureMailer{sender: sender}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+// Send captures an email.
+func (m *CaptureMailer) Send(ctx context.Context, content string, email string, subject string) error {
+	msg := Message{
+		Sender:  m.sender,
+		To:      email,
+		Subject: subject,
+		Content: content,
+		SentAt:  time.Now().UTC(),
+	}
+	m.mut.Lock()
+	m.messages = append(m.messages, msg)
+	m.seq++
+	seq := m.seq
+	m.mut.Unlock()
+	if m.store == nil {
+		return nil
+	}
+	b, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("capture mailer: %w", err)
+	}
+	key := fmt.Sprintf("%s%d-%d.json", m.prefix, msg.SentAt.UnixNano(), seq)
+	if err := m.store.Put(ctx, key, b); err != nil {
+		return fmt.Errorf("capture mailer: %w", err)
+	}
+	return nil
+}
+
+// Messages returns the captured messages in the order they were sent.
+func (m *CaptureMailer) Messages() []Message {
+	m.mut.Lock()
+	defer m.mut.Unlock()
+	return append([]Message(nil), m.messages...)
+}
+
+// SentTo returns the captured messages sent to email.
+func (m *CaptureMailer) SentTo(email string) []Message {
+	var sent []Message
+	for _, msg := range m.Messages() {
+		if msg.To == email {
+			sent = append(sent, msg)
+		}
+	}
+	return sent
+}
+
+// Last returns the most recently captured message.
+func (m *CaptureMailer) Last() (Message, bool) {
+	m.mut.Lock()
+	defer m.mut.Unlock()
+	if len(m.messages) == 0 {
+		return Message{}, false
+	}
+	return m.messages[len(m.messages)-1], true
+}
+
+// Reset discards the captured messages.
+func (m *CaptureMailer) Reset() {
+	m.mut.Lock()
+	defer m.mut.Unlock()
+	m.messages = nil
+}
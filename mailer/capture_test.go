@@ -0,0 +1,37 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package mailer
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type memStore map[string][]byte
+
+func (m memStore) Put(ctx context.Context, key string, body []byte) error {
+	m[key] = body
+	return nil
+}
+
+func TestCaptureStore(t *testing.T) {
+	store := memStore{}
+	m := NewCaptureMailer(EmailSender, WithCaptureStore(store, "mailer/"))
+	ctx := context.Background()
+	require.NoError(t, m.Send(ctx, "<p>one</p>", "a@example.com", "One"))
+	require.NoError(t, m.Send(ctx, "<p>two</p>", "b@example.com", "Two"))
+	require.Len(t, m.Messages(), 2)
+	require.Len(t, store, 2)
+	for key, b := range store {
+		require.Regexp(t, `^mailer/\d+-\d\.json$`, key)
+		var msg Message
+		require.NoError(t, json.Unmarshal(b, &msg))
+		require.Equal(t, EmailSender, msg.Sender)
+	}
+	m.Reset()
+	_, ok := m.Last()
+	require.False(t, ok)
+}
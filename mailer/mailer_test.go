@@ -7,6 +7,8 @@ import (
 	"os"
 	"testing"
 	"time"
+
+	"github.com/stretchr/testify/require"
 )
 
 const (
@@ -59,3 +61,20 @@ func TestSend(t *testing.T) {
 	}
 	t.Logf("Sent email to: %s", recipient)
 }
+
+// TestSendDryRun sends an email with the CaptureMailer of a dry run config.
+func TestSendDryRun(t *testing.T) {
+	mailer, err := New(Config{Region: SESRegion, Sender: EmailSender, DryRun: true})
+	require.NoError(t, err)
+	ctx, done := context.WithTimeout(context.Background(), reqTimeout)
+	defer done()
+	require.NoError(t, mailer.Send(ctx, HTMLTemplateText, DefaultSuccessEmail, SubjectTemplateText))
+	capture := mailer.(*CaptureMailer)
+	msg, ok := capture.Last()
+	require.True(t, ok)
+	require.Equal(t, EmailSender, msg.Sender)
+	require.Equal(t, SubjectTemplateText, msg.Subject)
+	require.Equal(t, HTMLTemplateText, msg.Content)
+	require.Len(t, capture.SentTo(DefaultSuccessEmail), 1)
+	require.Empty(t, capture.SentTo("other@example.com"))
+}
@@ -0,0 +1,97 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package midware
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Predicate matches requests.
+type Predicate func(r *http.Request) bool
+
+// When returns middleware which applies mw only to requests matching pred.
+// Other requests are served by the next handler directly, bypassing mw.  The
+// middleware mw is wrapped once, when the returned middleware is wrapped.
+func When(pred Predicate, mw Middleware) Middleware {
+	return &conditional{label: "when", pred: pred, mw: mw}
+}
+
+// Unless returns middleware which applies mw to all requests except those
+// matching pred.
+func Unless(pred Predicate, mw Middleware) Middleware {
+	return &conditional{label: "unless", pred: Not(pred), mw: mw}
+}
+
+type conditional struct {
+	label string
+	pred  Predicate
+	mw    Middleware
+}
+
+// Wrap implements the Middleware interface.
+func (c *conditional) Wrap(next http.Handler) http.Handler {
+	wrapped := c.mw.Wrap(next)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if c.pred(r) {
+			wrapped.ServeHTTP(w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Name implements the Namer interface.
+func (c *conditional) Name() string {
+	return c.label + "(" + middlewareName(c.mw) + ")"
+}
+
+// Unwrap returns the conditional middleware.
+func (c *conditional) Unwrap() Middleware {
+	return c.mw
+}
+
+// Not returns a predicate matching requests which pred does not match.
+func Not(pred Predicate) Predicate {
+	return func(r *http.Request) bool {
+		return !pred(r)
+	}
+}
+
+// AnyOf returns a predicate matching requests matched by any of preds.
+func AnyOf(preds ...Predicate) Predicate {
+	return func(r *http.Request) bool {
+		for _, pred := range preds {
+			if pred(r) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// PathPrefix returns a predicate matching requests whose path has any of the
+// given prefixes.
+func PathPrefix(prefixes ...string) Predicate {
+	return func(r *http.Request) bool {
+		for _, prefix := range prefixes {
+			if strings.HasPrefix(r.URL.Path, prefix) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Methods returns a predicate matching requests with any of the given
+// methods.
+func Methods(methods ...string) Predicate {
+	return func(r *http.Request) bool {
+		for _, method := range methods {
+			if strings.EqualFold(r.Method, method) {
+				return true
+			}
+		}
+		return false
+	}
+}
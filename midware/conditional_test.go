@@ -0,0 +1,51 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package midware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWhen(t *testing.T) {
+	mark := Func(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-Marked", "1")
+			next.ServeHTTP(w, r)
+		})
+	})
+	api := PathPrefix("/v1/", "/v2/")
+	writes := Methods(http.MethodPost, http.MethodPut)
+	c := Chain{
+		When(api, mark),
+	}
+	u := Chain{
+		Unless(AnyOf(api, writes), mark),
+	}
+	h := c.Wrap(staticBytes([]byte("hello")))
+	uh := u.Wrap(staticBytes([]byte("hello")))
+	for _, tc := range []struct {
+		method string
+		path   string
+		when   bool
+		unless bool
+	}{
+		{http.MethodGet, "/v1/foo", true, false},
+		{http.MethodGet, "/v2/", true, false},
+		{http.MethodGet, "/health", false, true},
+		{http.MethodPost, "/health", false, false},
+		{"put", "/other", false, false},
+	} {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(tc.method, tc.path, nil))
+		assert.Equal(t, "hello", w.Body.String())
+		assert.Equal(t, tc.when, w.Header().Get("X-Marked") == "1", "when %s %s", tc.method, tc.path)
+
+		w = httptest.NewRecorder()
+		uh.ServeHTTP(w, httptest.NewRequest(tc.method, tc.path, nil))
+		assert.Equal(t, tc.unless, w.Header().Get("X-Marked") == "1", "unless %s %s", tc.method, tc.path)
+	}
+}
@@ -0,0 +1,126 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package midware
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// CookiePolicy is middleware which rewrites the attributes of all cookies set
+// by inner handlers (via the Set-Cookie response header).  CookiePolicy allows
+// deployment specific cookie attributes to be configured in one place instead
+// of in every handler which emits cookies.
+//
+// Cookies are parsed and re-rendered using net/http, so any cookie attributes
+// net/http does not understand are dropped.
+type CookiePolicy struct {
+	// Secure forces the Secure attribute on all cookies, unless the request
+	// is addressed to localhost.  The host is determined by RequestHost, so
+	// TrustedProxies should precede CookiePolicy behind a proxy.
+	Secure bool
+	// SameSite overrides the SameSite attribute of all cookies, if non-zero.
+	SameSite http.SameSite
+	// Domain overrides the Domain attribute of all cookies, if non-empty.
+	Domain string
+}
+
+// Wrap implements the Middleware interface.
+func (p *CookiePolicy) Wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		secure := p.Secure && !isLocalhost(RequestHost(r))
+		hw := &headerHookWriter{
+			ResponseWriter: w,
+			hook: func(h http.Header) {
+				p.rewrite(h, secure)
+			},
+		}
+		next.ServeHTTP(hw, r)
+		hw.finish()
+	})
+}
+
+// Name implements the Namer interface.
+func (p *CookiePolicy) Name() string {
+	return "cookie-policy"
+}
+
+func (p *CookiePolicy) rewrite(h http.Header, secure bool) {
+	raw := h.Values("Set-Cookie")
+	if len(raw) == 0 {
+		return
+	}
+	cookies := (&http.Response{Header: http.Header{"Set-Cookie": raw}}).Cookies()
+	h.Del("Set-Cookie")
+	for _, c := range cookies {
+		if secure {
+			c.Secure = true
+		}
+		if p.SameSite != 0 {
+			c.SameSite = p.SameSite
+		}
+		if p.Domain != "" {
+			c.Domain = p.Domain
+		}
+		if v := c.String(); v != "" {
+			h.Add("Set-Cookie", v)
+		}
+	}
+}
+
+// isLocalhost returns true if host (which may include a port) refers to the
+// local machine.
+func isLocalhost(host string) bool {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// headerHookWriter calls hook exactly once, immediately before the response
+// headers are written.  This lets middleware modify headers set by inner
+// handlers.
+type headerHookWriter struct {
+	http.ResponseWriter
+	hook    func(http.Header)
+	written bool
+}
+
+func (w *headerHookWriter) WriteHeader(code int) {
+	if !w.written {
+		w.written = true
+		w.hook(w.Header())
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *headerHookWriter) Write(b []byte) (int, error) {
+	if !w.written {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// finish calls the hook if the inner handler never wrote a response, in which
+// case net/http writes the headers after the handler returns.
+func (w *headerHookWriter) finish() {
+	if !w.written {
+		w.written = true
+		w.hook(w.Header())
+	}
+}
+
+// Flush implements http.Flusher.
+func (w *headerHookWriter) Flush() {
+	if !w.written {
+		w.WriteHeader(http.StatusOK)
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
@@ -0,0 +1,59 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package midware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCookiePolicy(t *testing.T) {
+	inner := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "a", Value: "1", Path: "/"})
+		http.SetCookie(w, &http.Cookie{Name: "b", Value: "2", Domain: "other.com"})
+		w.WriteHeader(http.StatusOK)
+	})
+	policy := &CookiePolicy{
+		Secure:   true,
+		SameSite: http.SameSiteLaxMode,
+		Domain:   "example.com",
+	}
+	h := policy.Wrap(inner)
+
+	req := httptest.NewRequest(http.MethodGet, "http://api.example.com/", nil)
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	cookies := rr.Result().Cookies()
+	require.Len(t, cookies, 2)
+	for _, c := range cookies {
+		assert.True(t, c.Secure)
+		assert.Equal(t, http.SameSiteLaxMode, c.SameSite)
+		assert.Equal(t, "example.com", c.Domain)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "http://localhost:8080/", nil)
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	cookies = rr.Result().Cookies()
+	require.Len(t, cookies, 2)
+	for _, c := range cookies {
+		assert.False(t, c.Secure)
+	}
+}
+
+func TestCookiePolicy_noWrite(t *testing.T) {
+	inner := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "a", Value: "1"})
+	})
+	h := (&CookiePolicy{Secure: true}).Wrap(inner)
+	req := httptest.NewRequest(http.MethodGet, "http://api.example.com/", nil)
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	cookies := rr.Result().Cookies()
+	require.Len(t, cookies, 1)
+	assert.True(t, cookies[0].Secure)
+}
@@ -0,0 +1,304 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package midware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// IdempotencyKeyHeader is the default request header identifying
+	// retries of a request to Dedupe.
+	IdempotencyKeyHeader = "Idempotency-Key"
+	// IdempotentReplayedHeader is set to "true" on responses replayed by
+	// Dedupe.
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+
+	// DefaultDedupeWindow is the default time for which Dedupe replays a
+	// response.
+	DefaultDedupeWindow = 5 * time.Minute
+	// DefaultDedupeMaxSize is the default maximum size of a response body
+	// which Dedupe captures for replay.
+	DefaultDedupeMaxSize = 1 << 20
+	// DefaultDedupeMaxEntries is the default maximum number of responses
+	// Dedupe holds.
+	DefaultDedupeMaxEntries = 10000
+)
+
+// Dedupe is middleware which protects handlers from double submission by
+// replaying the response to a request when it is retried.  Retries are
+// requests from the same caller with the same method, path and idempotency
+// key header, received within Window of the original request.  Only POST,
+// PUT, PATCH and DELETE requests with the header are deduplicated.
+//
+// Dedupe may run before requests are authenticated, so the caller is
+// identified by its credentials, see Key, to avoid replaying the response
+// to one caller to another.  Set-Cookie headers are never replayed.
+//
+// A retry whose body differs from the original request's is rejected with
+// 422 Unprocessable Entity, and a retry received while the original request
+// is being served with 409 Conflict.  Server error responses (5xx) and
+// responses larger than MaxSize are not captured, so their retries are
+// served again.  Responses are held in memory by each Dedupe, so retries
+// are only deduplicated when they reach the same process.
+type Dedupe struct {
+	// Header is the idempotency key header.  If empty IdempotencyKeyHeader
+	// is used.
+	Header string
+	// Window is the time for which responses are replayed.  If zero
+	// DefaultDedupeWindow is used.
+	Window time.Duration
+	// MaxSize is the maximum response body size to capture.  If zero
+	// DefaultDedupeMaxSize is used.
+	MaxSize int
+	// MaxEntries is the maximum number of responses held.  Requests are not
+	// deduplicated while the cache is full.  If zero DefaultDedupeMaxEntries
+	// is used.
+	MaxEntries int
+	// Key identifies the caller of a request.  Requests are only
+	// deduplicated with requests of the same caller.  If nil
+	// DedupeCallerKey is used.
+	Key func(*http.Request) string
+
+	// now is the clock, overridden in tests.
+	now func() time.Time
+}
+
+// dedupeEntry is the captured response to a request.
+type dedupeEntry struct {
+	bodyHash []byte
+	expires  time.Time
+	// done is closed once the response is captured.
+	done   chan struct{}
+	code   int
+	header http.Header
+	body   []byte
+}
+
+// dedupeCache holds captured responses by request.
+type dedupeCache struct {
+	mut     sync.Mutex
+	entries map[string]*dedupeEntry
+}
+
+// Wrap implements the Middleware interface.
+func (m *Dedupe) Wrap(next http.Handler) http.Handler {
+	header := m.Header
+	if header == "" {
+		header = IdempotencyKeyHeader
+	}
+	window := m.Window
+	if window <= 0 {
+		window = DefaultDedupeWindow
+	}
+	maxSize := m.MaxSize
+	if maxSize <= 0 {
+		maxSize = DefaultDedupeMaxSize
+	}
+	maxEntries := m.MaxEntries
+	if maxEntries <= 0 {
+		maxEntries = DefaultDedupeMaxEntries
+	}
+	callerKey := m.Key
+	if callerKey == nil {
+		callerKey = DedupeCallerKey
+	}
+	now := m.now
+	if now == nil {
+		now = time.Now
+	}
+	cache := &dedupeCache{entries: make(map[string]*dedupeEntry)}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		idemKey := r.Header.Get(header)
+		if idemKey == "" || !dedupeMethod(r.Method) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		key := r.Method + " " + r.URL.Path + " " + idemKey + " " + callerKey(r)
+		entry, created, ok := cache.start(key, now(), window, maxEntries)
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if !created {
+			replayDedupe(w, r, entry)
+			return
+		}
+		h := sha256.New()
+		r.Body = &hashingBody{ReadCloser: r.Body, hash: h}
+		cw := &captureWriter{ResponseWriter: w, max: maxSize}
+		defer func() {
+			// Hash any body the handler left unread, so retries are compared
+			// with the whole request.
+			_, _ = io.Copy(io.Discard, r.Body)
+			cache.finish(key, entry, cw, h.Sum(nil))
+		}()
+		next.ServeHTTP(cw, r)
+	})
+}
+
+// Name implements the Namer interface.
+func (m *Dedupe) Name() string {
+	return NameDedupe
+}
+
+// DedupeCallerKey identifies the caller of a request by a hash of its
+// Authorization and Cookie headers.  Requests without credentials share a
+// caller.
+func DedupeCallerKey(r *http.Request) string {
+	h := sha256.New()
+	for _, name := range []string{"Authorization", "Cookie"} {
+		for _, v := range r.Header.Values(name) {
+			h.Write([]byte(name))
+			h.Write([]byte{0})
+			h.Write([]byte(v))
+			h.Write([]byte{0})
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func dedupeMethod(method string) bool {
+	switch method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// start returns the entry of key, creating it if the request is not a retry.
+// It returns false if the cache is full.
+func (c *dedupeCache) start(key string, now time.Time, window time.Duration, maxEntries int) (*dedupeEntry, bool, bool) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	if entry, ok := c.entries[key]; ok && now.Before(entry.expires) {
+		return entry, false, true
+	}
+	if len(c.entries) >= maxEntries {
+		for k, entry := range c.entries {
+			if !now.Before(entry.expires) {
+				delete(c.entries, k)
+			}
+		}
+		if len(c.entries) >= maxEntries {
+			return nil, false, false
+		}
+	}
+	entry := &dedupeEntry{expires: now.Add(window), done: make(chan struct{})}
+	c.entries[key] = entry
+	return entry, true, true
+}
+
+// finish records the response captured by w, or forgets the request if the
+// response cannot be replayed.
+func (c *dedupeCache) finish(key string, entry *dedupeEntry, w *captureWriter, bodyHash []byte) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	code := w.code
+	if code == 0 {
+		code = http.StatusOK
+	}
+	if w.overflow || code >= 500 {
+		if c.entries[key] == entry {
+			delete(c.entries, key)
+		}
+	} else {
+		entry.code = code
+		entry.header = w.Header().Clone()
+		// Cookies set for the original request are not replayed.
+		entry.header.Del("Set-Cookie")
+		entry.body = w.buf.Bytes()
+		entry.bodyHash = bodyHash
+	}
+	close(entry.done)
+}
+
+// replayDedupe serves a retry from the entry of the original request.
+func replayDedupe(w http.ResponseWriter, r *http.Request, entry *dedupeEntry) {
+	select {
+	case <-entry.done:
+	default:
+		http.Error(w, "request in progress", http.StatusConflict)
+		return
+	}
+	if entry.bodyHash == nil {
+		// The original response could not be captured and the entry was
+		// forgotten after this retry found it.
+		http.Error(w, "request in progress", http.StatusConflict)
+		return
+	}
+	h := sha256.New()
+	if _, err := io.Copy(h, r.Body); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	if !bytes.Equal(h.Sum(nil), entry.bodyHash) {
+		http.Error(w, "idempotency key reused with a different request", http.StatusUnprocessableEntity)
+		return
+	}
+	for k, v := range entry.header {
+		w.Header()[k] = append([]string(nil), v...)
+	}
+	w.Header().Set(IdempotentReplayedHeader, "true")
+	w.WriteHeader(entry.code)
+	_, _ = w.Write(entry.body)
+}
+
+// hashingBody hashes a request body as it is read.
+type hashingBody struct {
+	io.ReadCloser
+	hash hash.Hash
+}
+
+func (b *hashingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.hash.Write(p[:n])
+	return n, err
+}
+
+// captureWriter writes a response through while capturing it, until it
+// exceeds max bytes.
+type captureWriter struct {
+	http.ResponseWriter
+	max      int
+	code     int
+	buf      bytes.Buffer
+	overflow bool
+}
+
+func (w *captureWriter) WriteHeader(code int) {
+	if w.code != 0 {
+		return
+	}
+	w.code = code
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *captureWriter) Write(b []byte) (int, error) {
+	if w.code == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	if !w.overflow {
+		if w.buf.Len()+len(b) > w.max {
+			w.overflow = true
+			w.buf.Reset()
+		} else {
+			w.buf.Write(b)
+		}
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush implements http.Flusher.
+func (w *captureWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
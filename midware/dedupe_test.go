@@ -0,0 +1,154 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package midware
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDedupe(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	calls := 0
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		b, _ := io.ReadAll(r.Body)
+		if string(b) == "fail" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, `{"call":%d}`, calls)
+	})
+	h := (&Dedupe{Window: time.Minute, now: func() time.Time { return now }}).Wrap(handler)
+	do := func(method, path, key, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, path, strings.NewReader(body))
+		if key != "" {
+			r.Header.Set(IdempotencyKeyHeader, key)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	w := do(http.MethodPost, "/upload", "k1", "data")
+	require.Equal(t, http.StatusCreated, w.Code)
+	require.Equal(t, `{"call":1}`, w.Body.String())
+
+	// Retries are replayed.
+	w = do(http.MethodPost, "/upload", "k1", "data")
+	require.Equal(t, http.StatusCreated, w.Code)
+	require.Equal(t, `{"call":1}`, w.Body.String())
+	require.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	require.Equal(t, "true", w.Header().Get(IdempotentReplayedHeader))
+	require.Equal(t, 1, calls)
+
+	// The key is reused with a different body.
+	w = do(http.MethodPost, "/upload", "k1", "other")
+	require.Equal(t, http.StatusUnprocessableEntity, w.Code)
+
+	// Other paths, keys and methods, and requests without a key, are not
+	// retries.
+	require.Equal(t, `{"call":2}`, do(http.MethodPost, "/other", "k1", "data").Body.String())
+	require.Equal(t, `{"call":3}`, do(http.MethodPost, "/upload", "k2", "data").Body.String())
+	require.Equal(t, `{"call":4}`, do(http.MethodPut, "/upload", "k1", "data").Body.String())
+	require.Equal(t, `{"call":5}`, do(http.MethodPost, "/upload", "", "data").Body.String())
+	require.Equal(t, `{"call":6}`, do(http.MethodGet, "/upload", "k1", "").Body.String())
+
+	// Server errors are not replayed.
+	require.Equal(t, http.StatusInternalServerError, do(http.MethodPost, "/fail", "k3", "fail").Code)
+	require.Equal(t, http.StatusInternalServerError, do(http.MethodPost, "/fail", "k3", "fail").Code)
+	require.Equal(t, 8, calls)
+
+	// Responses expire after the window.
+	now = now.Add(time.Minute)
+	require.Equal(t, `{"call":9}`, do(http.MethodPost, "/upload", "k1", "data").Body.String())
+}
+
+func TestDedupeCallers(t *testing.T) {
+	calls := 0
+	h := (&Dedupe{}).Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: fmt.Sprint(calls)})
+		fmt.Fprintf(w, "%s %d", r.Header.Get("Authorization"), calls)
+	}))
+	do := func(auth string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/pay", strings.NewReader("data"))
+		r.Header.Set(IdempotencyKeyHeader, "k1")
+		r.Header.Set("Authorization", auth)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	w := do("Bearer alice")
+	require.Equal(t, "Bearer alice 1", w.Body.String())
+	require.NotEmpty(t, w.Header().Get("Set-Cookie"))
+
+	// Another caller reusing the key is not served the first response.
+	w = do("Bearer bob")
+	require.Equal(t, "Bearer bob 2", w.Body.String())
+
+	// Retries are replayed without cookies.
+	w = do("Bearer alice")
+	require.Equal(t, "Bearer alice 1", w.Body.String())
+	require.Equal(t, "true", w.Header().Get(IdempotentReplayedHeader))
+	require.Empty(t, w.Header().Values("Set-Cookie"))
+	require.Equal(t, 2, calls)
+
+	r := httptest.NewRequest(http.MethodPost, "/", nil)
+	anonymous := DedupeCallerKey(r)
+	r.Header.Set("Cookie", "session=1")
+	require.NotEqual(t, anonymous, DedupeCallerKey(r))
+}
+
+func TestDedupeInProgress(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	h := (&Dedupe{MaxEntries: 1}).Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			close(started)
+			<-release
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	do := func(path string) int {
+		r := httptest.NewRequest(http.MethodPost, path, nil)
+		r.Header.Set(IdempotencyKeyHeader, "k")
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w.Code
+	}
+	done := make(chan int)
+	go func() { done <- do("/slow") }()
+	<-started
+	require.Equal(t, http.StatusConflict, do("/slow"))
+	// The cache is full, so other requests are not deduplicated.
+	require.Equal(t, http.StatusNoContent, do("/fast"))
+	close(release)
+	require.Equal(t, http.StatusNoContent, <-done)
+	require.Equal(t, http.StatusNoContent, do("/slow"))
+}
+
+func TestDedupeMaxSize(t *testing.T) {
+	calls := 0
+	h := (&Dedupe{MaxSize: 4}).Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		_, _ = w.Write([]byte("large response"))
+	}))
+	for i := 0; i < 2; i++ {
+		r := httptest.NewRequest(http.MethodPost, "/", nil)
+		r.Header.Set(IdempotencyKeyHeader, "k")
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		require.Equal(t, "large response", w.Body.String())
+	}
+	require.Equal(t, 2, calls)
+}
@@ -0,0 +1,159 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package midware
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"runtime"
+	"strings"
+)
+
+// Well-known middleware names used by DefaultOrderRules.  Middleware defined
+// outside of this package may use them with Named.
+const (
+	NameTraceHeaders   = "trace-headers"
+	NameServerHeader   = "server-header"
+	NameTrustedProxies = "trusted-proxies"
+	NameNormalizePath  = "normalize-path"
+	NamePathOverrides  = "path-overrides"
+	NameGuard          = "guard"
+	NameRequestLog     = "request-log"
+	NameCompression    = "compression"
+	NameStreaming      = "streaming"
+	NameETag           = "etag"
+	NameDedupe         = "dedupe"
+)
+
+// Namer is implemented by middleware which describe themselves in
+// Chain.Describe and Chain.Validate.
+type Namer interface {
+	Name() string
+}
+
+// unwrapper is implemented by middleware wrapping another middleware, whose
+// position in a chain is validated as if it was the inner middleware.
+type unwrapper interface {
+	Unwrap() Middleware
+}
+
+// Named returns mw with a name.
+func Named(name string, mw Middleware) Middleware {
+	return &named{name: name, mw: mw}
+}
+
+type named struct {
+	name string
+	mw   Middleware
+}
+
+// Wrap implements the Middleware interface.
+func (n *named) Wrap(next http.Handler) http.Handler {
+	return n.mw.Wrap(next)
+}
+
+// Name implements the Namer interface.
+func (n *named) Name() string {
+	return n.name
+}
+
+// middlewareName returns the name of mw: its Name, or its type or function
+// name when it does not implement Namer.
+func middlewareName(mw Middleware) string {
+	switch m := mw.(type) {
+	case Namer:
+		return m.Name()
+	case Func:
+		if fn := runtime.FuncForPC(reflect.ValueOf(m).Pointer()); fn != nil {
+			return fn.Name()
+		}
+		return "func"
+	case nil:
+		return "<nil>"
+	default:
+		return fmt.Sprintf("%T", mw)
+	}
+}
+
+// flatten returns the middleware of c, expanding nested chains.
+func (c Chain) flatten() []Middleware {
+	var flat []Middleware
+	for _, mw := range c {
+		if nested, ok := mw.(Chain); ok {
+			flat = append(flat, nested.flatten()...)
+			continue
+		}
+		flat = append(flat, mw)
+	}
+	return flat
+}
+
+// Describe returns the names of the middleware of c, from the outermost to
+// the innermost, expanding nested chains.
+func (c Chain) Describe() []string {
+	flat := c.flatten()
+	names := make([]string, len(flat))
+	for i, mw := range flat {
+		names[i] = middlewareName(mw)
+	}
+	return names
+}
+
+// OrderRule requires the middleware named Before to precede (wrap) the
+// middleware named After, when a chain contains both.
+type OrderRule struct {
+	Before string
+	After  string
+	// Reason explains the rule in validation errors.
+	Reason string
+}
+
+// DefaultOrderRules are the orderings checked by Chain.Validate.
+var DefaultOrderRules = []OrderRule{
+	{Before: NameTraceHeaders, After: NameRequestLog, Reason: "requests are logged without their request ID"},
+	{Before: NameTraceHeaders, After: NameGuard, Reason: "rejected requests have no request ID"},
+	{Before: NameTrustedProxies, After: NameGuard, Reason: "the guard sees the proxy address and scheme"},
+	{Before: NameNormalizePath, After: NamePathOverrides, Reason: "overridden paths are matched before normalization"},
+	{Before: NameCompression, After: NameStreaming, Reason: "compression buffers streamed responses"},
+	{Before: NameDedupe, After: NamePathOverrides, Reason: "overridden paths are not deduplicated"},
+}
+
+// Validate returns an error if the middleware of c are in a known-bad order,
+// according to DefaultOrderRules and rules.  Middleware are identified by
+// name, and middleware applied conditionally, with When or Unless, by the
+// name of the conditional middleware.  Validate is intended to be called at
+// startup, to catch misconfigured chains.
+func (c Chain) Validate(rules ...OrderRule) error {
+	positions := make(map[string][]int)
+	for i, mw := range c.flatten() {
+		for {
+			u, ok := mw.(unwrapper)
+			if !ok {
+				break
+			}
+			mw = u.Unwrap()
+		}
+		name := middlewareName(mw)
+		positions[name] = append(positions[name], i)
+	}
+	var errs []string
+	for _, rule := range append(append([]OrderRule(nil), DefaultOrderRules...), rules...) {
+		before, after := positions[rule.Before], positions[rule.After]
+		if len(before) == 0 || len(after) == 0 {
+			continue
+		}
+		// Every occurrence of Before must precede every occurrence of After.
+		if before[len(before)-1] > after[0] {
+			msg := fmt.Sprintf("%s must precede %s", rule.Before, rule.After)
+			if rule.Reason != "" {
+				msg += ": " + rule.Reason
+			}
+			errs = append(errs, msg)
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("midware: invalid chain: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package midware
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func passthrough(next http.Handler) http.Handler {
+	return next
+}
+
+func TestChainDescribe(t *testing.T) {
+	proxies, err := NewTrustedProxies("10.0.0.0/8")
+	require.NoError(t, err)
+	c := Chain{
+		TraceHeaders("", true),
+		proxies,
+		Chain{
+			NormalizePath{},
+			When(PathPrefix("/v1/"), &Guard{}),
+		},
+		Named(NameRequestLog, Func(passthrough)),
+		Func(passthrough),
+		PathOverrides{},
+	}
+	assert.Equal(t, []string{
+		NameTraceHeaders,
+		NameTrustedProxies,
+		NameNormalizePath,
+		"when(guard)",
+		NameRequestLog,
+		"github.com/luthersystems/svc/midware.passthrough",
+		NamePathOverrides,
+	}, c.Describe())
+	require.NoError(t, c.Validate())
+}
+
+func TestChainValidate(t *testing.T) {
+	log := Named(NameRequestLog, Func(passthrough))
+	err := Chain{log, TraceHeaders("", true)}.Validate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "trace-headers must precede request-log")
+
+	// Conditional middleware are validated by their inner middleware.
+	err = Chain{Unless(Methods(http.MethodGet), &Guard{}), TraceHeaders("", true)}.Validate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "trace-headers must precede guard")
+
+	err = Chain{PathOverrides{}, Chain{NormalizePath{}}}.Validate()
+	require.Error(t, err)
+
+	stream := Named(NameStreaming, Func(passthrough))
+	gzip := Named(NameCompression, Func(passthrough))
+	require.Error(t, Chain{stream, gzip}.Validate())
+	require.NoError(t, Chain{gzip, stream}.Validate())
+
+	// Custom rules.
+	auth := Named("auth", Func(passthrough))
+	audit := Named("audit", Func(passthrough))
+	require.NoError(t, Chain{audit, auth}.Validate())
+	require.Error(t, Chain{audit, auth}.Validate(OrderRule{Before: "auth", After: "audit"}))
+}
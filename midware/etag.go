@@ -0,0 +1,161 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package midware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// DefaultETagMaxSize is the default maximum size of a response body which
+// ETag will buffer.
+const DefaultETagMaxSize = 1 << 20
+
+// ETag is middleware which computes a strong ETag for successful JSON
+// responses to GET requests and responds 304 Not Modified to requests with a
+// matching If-None-Match header.  The response body is still produced by the
+// inner handler, so ETag saves bandwidth rather than computation.
+//
+// Responses are buffered in memory in order to compute their ETag.  Responses
+// larger than MaxSize, responses which are flushed, and responses which
+// already have an ETag header are passed through unmodified.
+type ETag struct {
+	// PathPrefixes restricts the middleware to request paths with one of the
+	// given prefixes.  If empty all paths are eligible.
+	PathPrefixes []string
+	// MaxSize is the maximum response body size to buffer.  If zero
+	// DefaultETagMaxSize is used.
+	MaxSize int
+}
+
+// Wrap implements the Middleware interface.
+func (m *ETag) Wrap(next http.Handler) http.Handler {
+	maxSize := m.MaxSize
+	if maxSize <= 0 {
+		maxSize = DefaultETagMaxSize
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet || !m.matchPath(r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		ew := &etagWriter{ResponseWriter: w, max: maxSize}
+		next.ServeHTTP(ew, r)
+		ew.finish(r)
+	})
+}
+
+// Name implements the Namer interface.
+func (m *ETag) Name() string {
+	return NameETag
+}
+
+func (m *ETag) matchPath(p string) bool {
+	if len(m.PathPrefixes) == 0 {
+		return true
+	}
+	for _, prefix := range m.PathPrefixes {
+		if strings.HasPrefix(p, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// etagWriter buffers a response until it is complete, or until it is
+// determined that the response is not eligible for an ETag.
+type etagWriter struct {
+	http.ResponseWriter
+	max         int
+	code        int
+	buf         bytes.Buffer
+	passthrough bool
+}
+
+func (w *etagWriter) WriteHeader(code int) {
+	if w.code != 0 {
+		return
+	}
+	w.code = code
+	if code != http.StatusOK || !isJSON(w.Header()) || w.Header().Get("ETag") != "" {
+		w.passthrough = true
+		w.ResponseWriter.WriteHeader(code)
+	}
+}
+
+func (w *etagWriter) Write(b []byte) (int, error) {
+	if w.code == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.passthrough {
+		return w.ResponseWriter.Write(b)
+	}
+	if w.buf.Len()+len(b) > w.max {
+		if err := w.startPassthrough(); err != nil {
+			return 0, err
+		}
+		return w.ResponseWriter.Write(b)
+	}
+	return w.buf.Write(b)
+}
+
+// Flush implements http.Flusher.  Flushed responses are never given an ETag.
+func (w *etagWriter) Flush() {
+	if w.code == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	if !w.passthrough {
+		if err := w.startPassthrough(); err != nil {
+			return
+		}
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// startPassthrough writes any buffered response and disables buffering.
+func (w *etagWriter) startPassthrough() error {
+	w.passthrough = true
+	w.ResponseWriter.WriteHeader(w.code)
+	_, err := w.ResponseWriter.Write(w.buf.Bytes())
+	w.buf.Reset()
+	return err
+}
+
+func (w *etagWriter) finish(r *http.Request) {
+	if w.passthrough || w.code == 0 {
+		return
+	}
+	sum := sha256.Sum256(w.buf.Bytes())
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+	w.Header().Set("ETag", etag)
+	if etagMatch(r.Header.Get("If-None-Match"), etag) {
+		w.Header().Del("Content-Length")
+		w.Header().Del("Content-Type")
+		w.ResponseWriter.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.ResponseWriter.WriteHeader(w.code)
+	_, _ = w.ResponseWriter.Write(w.buf.Bytes())
+}
+
+// etagMatch implements the weak comparison used for If-None-Match (RFC 9110).
+func etagMatch(header string, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+func isJSON(h http.Header) bool {
+	mType, _, err := mime.ParseMediaType(h.Get("Content-Type"))
+	return err == nil && mType == "application/json"
+}
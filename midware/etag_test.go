@@ -0,0 +1,52 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package midware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestETag(t *testing.T) {
+	json := &staticHandler{
+		header: http.Header{"Content-Type": []string{"application/json"}},
+		body:   []byte(`{"hello":"world"}`),
+	}
+	h := (&ETag{PathPrefixes: []string{"/v1/"}}).Wrap(json)
+	testServer(t, h, func(t *testing.T, server *httptest.Server) {
+		resp := testResponseHeaders(t, server, "GET", "/v1/foo", nil, nil)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		etag := resp.Header.Get("ETag")
+		require.NotEmpty(t, etag)
+		assert.Equal(t, []byte(`{"hello":"world"}`), testRequest(t, server, "GET", "/v1/foo", nil, nil))
+
+		resp = testResponseHeaders(t, server, "GET", "/v1/foo", http.Header{"If-None-Match": []string{etag}}, nil)
+		assert.Equal(t, http.StatusNotModified, resp.StatusCode)
+		assert.Equal(t, etag, resp.Header.Get("ETag"))
+
+		resp = testResponseHeaders(t, server, "GET", "/v1/foo", http.Header{"If-None-Match": []string{`"other"`}}, nil)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		resp = testResponseHeaders(t, server, "GET", "/other", nil, nil)
+		assert.Empty(t, resp.Header.Get("ETag"))
+		resp = testResponseHeaders(t, server, "POST", "/v1/foo", nil, nil)
+		assert.Empty(t, resp.Header.Get("ETag"))
+	})
+
+	h = (&ETag{MaxSize: 4}).Wrap(json)
+	testServer(t, h, func(t *testing.T, server *httptest.Server) {
+		resp := testResponseHeaders(t, server, "GET", "/v1/foo", nil, nil)
+		assert.Empty(t, resp.Header.Get("ETag"))
+		assert.Equal(t, []byte(`{"hello":"world"}`), testRequest(t, server, "GET", "/v1/foo", nil, nil))
+	})
+
+	h = (&ETag{}).Wrap(basicHandler)
+	testServer(t, h, func(t *testing.T, server *httptest.Server) {
+		resp := testResponseHeaders(t, server, "GET", "/", nil, nil)
+		assert.Empty(t, resp.Header.Get("ETag"))
+	})
+}
@@ -0,0 +1,154 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package midware
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// DefaultGuardRealm is the realm of Guard challenges when Realm is empty.
+const DefaultGuardRealm = "restricted"
+
+// ErrGuardForbidden is returned by a Guard token validator to reject a
+// valid token which is not permitted to access the path.  The request is
+// rejected with 403 Forbidden instead of 401 Unauthorized.
+var ErrGuardForbidden = errors.New("forbidden")
+
+// Guard is middleware which requires requests to present static basic auth
+// credentials or a bearer token, without involving the JWT stack.  It is
+// intended to protect individual internal endpoints, such as a swagger
+// document or a static admin UI, typically by wrapping PathOverrides
+// entries:
+//
+//	guard := &midware.Guard{Users: map[string]string{"ops": password}}
+//	midware.PathOverrides{
+//		"/swagger.json": guard.Wrap(swaggerHandler),
+//	}
+//
+// Rejected requests receive an exception-formatted JSON response with
+// status 401 Unauthorized and a WWW-Authenticate challenge, or 403
+// Forbidden if the token validator returns ErrGuardForbidden.  A Guard
+// without credentials or a validator rejects all requests.
+type Guard struct {
+	// Realm is the realm of WWW-Authenticate challenges.  Defaults to
+	// DefaultGuardRealm.
+	Realm string
+	// Users maps basic auth user names to their passwords.
+	Users map[string]string
+	// Tokens are accepted bearer tokens.
+	Tokens []string
+	// ValidateToken optionally validates bearer tokens which are not one of
+	// the Tokens.  It returns nil to accept the token.
+	ValidateToken func(r *http.Request, token string) error
+}
+
+// Wrap implements the Middleware interface.
+func (g *Guard) Wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch err := g.authorize(r); {
+		case err == nil:
+			next.ServeHTTP(w, r)
+		case errors.Is(err, ErrGuardForbidden):
+			writeGuardException(w, r, http.StatusForbidden, "forbidden")
+		default:
+			g.challenge(w.Header())
+			writeGuardException(w, r, http.StatusUnauthorized, "unauthenticated")
+		}
+	})
+}
+
+// Name implements the Namer interface.
+func (g *Guard) Name() string {
+	return NameGuard
+}
+
+// authorize checks the credentials of a request.
+func (g *Guard) authorize(r *http.Request) error {
+	if user, password, ok := r.BasicAuth(); ok {
+		want, found := g.Users[user]
+		// Compare hashes in constant time, so that neither the password
+		// nor its length leak through timing.
+		if found && secureEqual(password, want) {
+			return nil
+		}
+		return fmt.Errorf("invalid credentials")
+	}
+	token, ok := bearerToken(r)
+	if !ok {
+		return fmt.Errorf("missing credentials")
+	}
+	for _, t := range g.Tokens {
+		if secureEqual(token, t) {
+			return nil
+		}
+	}
+	if g.ValidateToken != nil {
+		return g.ValidateToken(r, token)
+	}
+	return fmt.Errorf("invalid token")
+}
+
+// challenge sets the WWW-Authenticate challenges of the accepted schemes.
+func (g *Guard) challenge(h http.Header) {
+	realm := g.Realm
+	if realm == "" {
+		realm = DefaultGuardRealm
+	}
+	if len(g.Users) > 0 {
+		h.Add("WWW-Authenticate", fmt.Sprintf("Basic realm=%q", realm))
+	}
+	if len(g.Tokens) > 0 || g.ValidateToken != nil {
+		h.Add("WWW-Authenticate", fmt.Sprintf("Bearer realm=%q", realm))
+	}
+}
+
+// bearerToken returns the bearer token of the Authorization header.
+func bearerToken(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
+
+// secureEqual compares secrets in constant time.
+func secureEqual(a string, b string) bool {
+	ha := sha256.Sum256([]byte(a))
+	hb := sha256.Sum256([]byte(b))
+	return subtle.ConstantTimeCompare(ha[:], hb[:]) == 1
+}
+
+// guardException mirrors the JSON form of a security exception response.
+type guardException struct {
+	Exception struct {
+		ID          string `json:"id"`
+		Type        string `json:"type"`
+		Timestamp   string `json:"timestamp"`
+		Description string `json:"description"`
+	} `json:"exception"`
+}
+
+// writeGuardException writes a security exception response.
+func writeGuardException(w http.ResponseWriter, r *http.Request, code int, description string) {
+	var resp guardException
+	resp.Exception.ID = r.Header.Get(DefaultTraceHeader)
+	resp.Exception.Type = "SECURITY_VIOLATION"
+	resp.Exception.Timestamp = time.Now().Format(time.RFC3339)
+	resp.Exception.Description = description
+	b, err := json.Marshal(resp)
+	if err != nil {
+		http.Error(w, description, code)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_, _ = w.Write(b)
+}
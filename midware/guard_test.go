@@ -0,0 +1,79 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package midware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGuard(t *testing.T) {
+	guard := &Guard{
+		Users:  map[string]string{"ops": "s3cret"},
+		Tokens: []string{"static-token"},
+		ValidateToken: func(r *http.Request, token string) error {
+			if token == "reader" {
+				return ErrGuardForbidden
+			}
+			if token == "admin" {
+				return nil
+			}
+			return assert.AnError
+		},
+	}
+	h := PathOverrides{
+		"/swagger.json": guard.Wrap(staticBytes([]byte("{}"))),
+	}.Wrap(staticBytes([]byte("app")))
+
+	serve := func(path string, setAuth func(r *http.Request)) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, path, nil)
+		r.Header.Set(DefaultTraceHeader, "req-1")
+		if setAuth != nil {
+			setAuth(r)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+	bearer := func(token string) func(r *http.Request) {
+		return func(r *http.Request) { r.Header.Set("Authorization", "Bearer "+token) }
+	}
+
+	// Other paths are not guarded.
+	assert.Equal(t, http.StatusOK, serve("/v1/foo", nil).Code)
+
+	w := serve("/swagger.json", nil)
+	require.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Equal(t, []string{`Basic realm="restricted"`, `Bearer realm="restricted"`}, w.Header().Values("WWW-Authenticate"))
+	var resp guardException
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, "req-1", resp.Exception.ID)
+	assert.Equal(t, "SECURITY_VIOLATION", resp.Exception.Type)
+	assert.Equal(t, "unauthenticated", resp.Exception.Description)
+
+	assert.Equal(t, http.StatusOK, serve("/swagger.json", func(r *http.Request) { r.SetBasicAuth("ops", "s3cret") }).Code)
+	assert.Equal(t, http.StatusUnauthorized, serve("/swagger.json", func(r *http.Request) { r.SetBasicAuth("ops", "wrong") }).Code)
+	assert.Equal(t, http.StatusOK, serve("/swagger.json", bearer("static-token")).Code)
+	assert.Equal(t, http.StatusOK, serve("/swagger.json", bearer("admin")).Code)
+	assert.Equal(t, http.StatusUnauthorized, serve("/swagger.json", bearer("other")).Code)
+
+	w = serve("/swagger.json", bearer("reader"))
+	require.Equal(t, http.StatusForbidden, w.Code)
+	assert.Empty(t, w.Header().Get("WWW-Authenticate"))
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, "forbidden", resp.Exception.Description)
+}
+
+func TestGuardEmpty(t *testing.T) {
+	h := (&Guard{}).Wrap(staticBytes([]byte("{}")))
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Authorization", "Bearer anything")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
@@ -38,6 +38,11 @@ func (m PathOverrides) Wrap(next http.Handler) http.Handler {
 	return &pathOverridesHandler{m, next}
 }
 
+// Name implements the Namer interface.
+func (m PathOverrides) Name() string {
+	return NamePathOverrides
+}
+
 type pathOverridesHandler struct {
 	m    PathOverrides
 	next http.Handler
@@ -74,9 +79,9 @@ func ServerResponseHeader(primary string, secondary ...func() string) Middleware
 	if primary == "" {
 		panic("http server header primary component is invalid")
 	}
-	return Func(func(next http.Handler) http.Handler {
+	return Named(NameServerHeader, Func(func(next http.Handler) http.Handler {
 		return &serverListHandler{p: primary, s: secondary, next: next}
-	})
+	}))
 }
 
 type serverListHandler struct {
@@ -158,13 +163,13 @@ func TraceHeaders(header string, allow bool) Middleware {
 	if header == "" {
 		header = DefaultTraceHeader
 	}
-	return Func(func(next http.Handler) http.Handler {
+	return Named(NameTraceHeaders, Func(func(next http.Handler) http.Handler {
 		return &traceRequestHeader{
 			header: header,
 			allow:  allow,
 			next:   next,
 		}
-	})
+	}))
 }
 
 type traceRequestHeader struct {
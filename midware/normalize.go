@@ -0,0 +1,111 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package midware
+
+import (
+	"net/http"
+	"strings"
+)
+
+// TrailingSlash determines how NormalizePath treats trailing slashes.
+type TrailingSlash int
+
+const (
+	// TrailingSlashPreserve leaves trailing slashes unchanged.
+	TrailingSlashPreserve TrailingSlash = iota
+	// TrailingSlashStrip removes trailing slashes.
+	TrailingSlashStrip
+	// TrailingSlashEnforce adds a trailing slash to paths without one.
+	TrailingSlashEnforce
+)
+
+// NormalizePath is middleware which normalizes request URL paths before they
+// are matched by inner handlers, such as PathOverrides.  Duplicate slashes
+// are always collapsed, so "/static//app.js" becomes "/static/app.js".
+// Trailing slashes and case are normalized as configured.  The root path "/"
+// is never modified.
+//
+// By default the request is rewritten and served by the inner handler.  If
+// Redirect is true, requests for paths which are not normalized are instead
+// redirected to the normalized path with 308 Permanent Redirect, which
+// preserves the request method and body.
+type NormalizePath struct {
+	// TrailingSlash determines how trailing slashes are normalized.
+	TrailingSlash TrailingSlash
+	// LowerCase converts paths to lower case.  Only enable this if no
+	// handler serves paths with case-sensitive segments, such as IDs.
+	LowerCase bool
+	// Redirect redirects clients to the normalized path instead of
+	// rewriting the request.
+	Redirect bool
+}
+
+// Normalize returns the normalized form of a URL path.
+func (m NormalizePath) Normalize(p string) string {
+	if p == "" || p == "/" {
+		return p
+	}
+	var b strings.Builder
+	b.Grow(len(p) + 1)
+	for i := 0; i < len(p); i++ {
+		if p[i] == '/' && i > 0 && p[i-1] == '/' {
+			continue
+		}
+		b.WriteByte(p[i])
+	}
+	n := b.String()
+	switch m.TrailingSlash {
+	case TrailingSlashStrip:
+		if len(n) > 1 {
+			n = strings.TrimSuffix(n, "/")
+		}
+	case TrailingSlashEnforce:
+		if !strings.HasSuffix(n, "/") {
+			n += "/"
+		}
+	}
+	if m.LowerCase {
+		n = strings.ToLower(n)
+	}
+	return n
+}
+
+// Wrap implements the Middleware interface.
+func (m NormalizePath) Wrap(next http.Handler) http.Handler {
+	return &normalizePathHandler{m: m, next: next}
+}
+
+// Name implements the Namer interface.
+func (m NormalizePath) Name() string {
+	return NameNormalizePath
+}
+
+type normalizePathHandler struct {
+	m    NormalizePath
+	next http.Handler
+}
+
+func (h *normalizePathHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p := h.m.Normalize(r.URL.Path)
+	if p == r.URL.Path {
+		h.next.ServeHTTP(w, r)
+		return
+	}
+	u := *r.URL
+	u.Path = p
+	u.RawPath = ""
+	if h.m.Redirect {
+		// Only the path and query are used so the redirect stays on the
+		// host the client requested.
+		target := u.EscapedPath()
+		if u.RawQuery != "" {
+			target += "?" + u.RawQuery
+		}
+		http.Redirect(w, r, target, http.StatusPermanentRedirect)
+		return
+	}
+	r2 := r.Clone(r.Context())
+	r2.URL = &u
+	r2.RequestURI = u.RequestURI()
+	h.next.ServeHTTP(w, r2)
+}
@@ -0,0 +1,67 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package midware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalizePathNormalize(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		m    NormalizePath
+		in   string
+		out  string
+	}{
+		{"root", NormalizePath{TrailingSlash: TrailingSlashStrip}, "/", "/"},
+		{"duplicate", NormalizePath{}, "/static//app.js", "/static/app.js"},
+		{"leading duplicate", NormalizePath{}, "//static///app.js", "/static/app.js"},
+		{"preserve", NormalizePath{}, "/static/", "/static/"},
+		{"strip", NormalizePath{TrailingSlash: TrailingSlashStrip}, "/static//", "/static"},
+		{"enforce", NormalizePath{TrailingSlash: TrailingSlashEnforce}, "/static", "/static/"},
+		{"lower", NormalizePath{LowerCase: true}, "/Static/App.js", "/static/app.js"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.out, test.m.Normalize(test.in))
+		})
+	}
+}
+
+func TestNormalizePath(t *testing.T) {
+	var served string
+	inner := PathOverrides{
+		"/static/app.js": http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			served = r.URL.Path
+		}),
+	}.Wrap(http.NotFoundHandler())
+
+	t.Run("rewrite", func(t *testing.T) {
+		h := NormalizePath{LowerCase: true}.Wrap(inner)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/Static//app.js", nil))
+		require.Equal(t, http.StatusOK, w.Code)
+		require.Equal(t, "/static/app.js", served)
+	})
+
+	t.Run("redirect", func(t *testing.T) {
+		h := NormalizePath{TrailingSlash: TrailingSlashStrip, Redirect: true}.Wrap(inner)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/static//app.js/?v=1", nil))
+		require.Equal(t, http.StatusPermanentRedirect, w.Code)
+		require.Equal(t, "/static/app.js?v=1", w.Header().Get("Location"))
+	})
+
+	t.Run("unchanged", func(t *testing.T) {
+		served = ""
+		h := NormalizePath{Redirect: true}.Wrap(inner)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/static/app.js", nil))
+		require.Equal(t, http.StatusOK, w.Code)
+		require.Equal(t, "/static/app.js", served)
+	})
+}
@@ -0,0 +1,97 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package midware
+
+import (
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultNoticeHeader is the response header holding the notice message.
+const DefaultNoticeHeader = "X-Service-Notice"
+
+// Notice is an operational notice communicated to API consumers, such as
+// planned downtime or the deprecation of an API.
+type Notice struct {
+	// Message is a human readable notice, e.g. "planned maintenance
+	// 2024-06-01 02:00-04:00 UTC".
+	Message string `json:"message,omitempty"`
+	// Sunset is the time after which the API is expected to become
+	// unavailable (RFC 8594).
+	Sunset time.Time `json:"sunset"`
+	// Deprecation is the time at which the API was, or will be, deprecated
+	// (RFC 9745).
+	Deprecation time.Time `json:"deprecation"`
+	// Link is an optional URL with details about the notice.
+	Link string `json:"link,omitempty"`
+}
+
+// ServiceNotice is middleware which injects the headers of the current
+// notice into all responses.  The notice may be changed at any time with
+// Set, so consumers can be informed of planned downtime without code changes.
+// The zero value has no notice and is ready to use.
+type ServiceNotice struct {
+	notice atomic.Pointer[Notice]
+}
+
+// Set replaces the current notice.  A nil notice stops injecting headers.
+func (s *ServiceNotice) Set(n *Notice) {
+	if n != nil {
+		n = &Notice{
+			Message:     n.Message,
+			Sunset:      n.Sunset,
+			Deprecation: n.Deprecation,
+			Link:        n.Link,
+		}
+	}
+	s.notice.Store(n)
+}
+
+// Get returns the current notice, or nil if there is none.
+func (s *ServiceNotice) Get() *Notice {
+	n := s.notice.Load()
+	if n == nil {
+		return nil
+	}
+	cp := *n
+	return &cp
+}
+
+// Wrap implements the Middleware interface.
+func (s *ServiceNotice) Wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if n := s.notice.Load(); n != nil {
+			n.setHeaders(w.Header())
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Name implements the Namer interface.
+func (s *ServiceNotice) Name() string {
+	return "service-notice"
+}
+
+// setHeaders sets the response headers of the notice.
+func (n *Notice) setHeaders(h http.Header) {
+	if n.Message != "" {
+		h.Set(DefaultNoticeHeader, n.Message)
+	}
+	if !n.Sunset.IsZero() {
+		h.Set("Sunset", n.Sunset.UTC().Format(http.TimeFormat))
+	}
+	if !n.Deprecation.IsZero() {
+		h.Set("Deprecation", fmt.Sprintf("@%d", n.Deprecation.Unix()))
+	}
+	if n.Link != "" {
+		rel := "service-notice"
+		if !n.Sunset.IsZero() {
+			rel = "sunset"
+		} else if !n.Deprecation.IsZero() {
+			rel = "deprecation"
+		}
+		h.Add("Link", fmt.Sprintf("<%s>; rel=%q", n.Link, rel))
+	}
+}
@@ -0,0 +1,45 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package midware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestServiceNotice(t *testing.T) {
+	var s ServiceNotice
+	h := s.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	serve := func() http.Header {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/x", nil))
+		return w.Header()
+	}
+
+	require.Nil(t, s.Get())
+	require.Empty(t, serve().Get(DefaultNoticeHeader))
+
+	sunset := time.Date(2024, 6, 1, 2, 0, 0, 0, time.UTC)
+	s.Set(&Notice{
+		Message:     "planned maintenance",
+		Sunset:      sunset,
+		Deprecation: sunset.Add(-24 * time.Hour),
+		Link:        "https://status.example.com",
+	})
+	headers := serve()
+	require.Equal(t, "planned maintenance", headers.Get(DefaultNoticeHeader))
+	require.Equal(t, "Sat, 01 Jun 2024 02:00:00 GMT", headers.Get("Sunset"))
+	require.Equal(t, "@1717120800", headers.Get("Deprecation"))
+	require.Equal(t, `<https://status.example.com>; rel="sunset"`, headers.Get("Link"))
+	require.Equal(t, "planned maintenance", s.Get().Message)
+
+	s.Set(nil)
+	headers = serve()
+	require.Empty(t, headers.Get(DefaultNoticeHeader))
+	require.Empty(t, headers.Get("Sunset"))
+}
@@ -0,0 +1,163 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package midware
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// TrustedProxies is middleware which reconstructs the scheme and host used by
+// clients from the X-Forwarded-Proto and X-Forwarded-Host headers set by
+// trusted reverse proxies, such as load balancers which terminate TLS.  The
+// headers of requests from other remote addresses are ignored, since clients
+// may set them to arbitrary values.
+//
+// The reconstructed values are stored in the request context and may be
+// retrieved with RequestScheme, RequestHost and AbsoluteURL.  Whether the
+// request was forwarded by a trusted proxy is retrieved with
+// FromTrustedProxy.
+type TrustedProxies struct {
+	networks []*net.IPNet
+}
+
+// NewTrustedProxies returns TrustedProxies middleware trusting the given IP
+// addresses and CIDR networks, e.g. "10.0.0.0/8".
+func NewTrustedProxies(trusted ...string) (*TrustedProxies, error) {
+	p := &TrustedProxies{}
+	for _, s := range trusted {
+		if !strings.Contains(s, "/") {
+			ip := net.ParseIP(s)
+			if ip == nil {
+				return nil, fmt.Errorf("trusted proxy: invalid address %q", s)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			p.networks = append(p.networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, n, err := net.ParseCIDR(s)
+		if err != nil {
+			return nil, fmt.Errorf("trusted proxy: %w", err)
+		}
+		p.networks = append(p.networks, n)
+	}
+	return p, nil
+}
+
+// trusted returns true if remoteAddr, in host:port form, is a trusted proxy.
+func (p *TrustedProxies) trusted(remoteAddr string) bool {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	for _, n := range p.networks {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+type forwardedKey struct{}
+
+// forwarded is the client facing scheme and host of a request.
+type forwarded struct {
+	scheme  string
+	host    string
+	trusted bool
+}
+
+// firstValue returns the first element of a comma separated header, which is
+// set by the proxy closest to the client.
+func firstValue(h http.Header, name string) string {
+	v, _, _ := strings.Cut(h.Get(name), ",")
+	return strings.TrimSpace(v)
+}
+
+// Wrap implements the Middleware interface.
+func (p *TrustedProxies) Wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fwd := forwarded{scheme: directScheme(r), host: r.Host}
+		if p.trusted(r.RemoteAddr) {
+			fwd.trusted = true
+			if proto := strings.ToLower(firstValue(r.Header, "X-Forwarded-Proto")); proto == "http" || proto == "https" {
+				fwd.scheme = proto
+			}
+			if host := firstValue(r.Header, "X-Forwarded-Host"); host != "" {
+				fwd.host = host
+			}
+		}
+		ctx := context.WithValue(r.Context(), forwardedKey{}, fwd)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// Name implements the Namer interface.
+func (p *TrustedProxies) Name() string {
+	return NameTrustedProxies
+}
+
+func directScheme(r *http.Request) string {
+	if r.TLS != nil {
+		return "https"
+	}
+	return "http"
+}
+
+// RequestScheme returns the scheme, "http" or "https", used by the client.
+// Without TrustedProxies middleware the scheme of the direct connection is
+// returned.
+func RequestScheme(r *http.Request) string {
+	if fwd, ok := r.Context().Value(forwardedKey{}).(forwarded); ok {
+		return fwd.scheme
+	}
+	return directScheme(r)
+}
+
+// RequestHost returns the host, possibly including a port, addressed by the
+// client.  Without TrustedProxies middleware the Host header is returned.
+func RequestHost(r *http.Request) string {
+	if fwd, ok := r.Context().Value(forwardedKey{}).(forwarded); ok {
+		return fwd.host
+	}
+	return r.Host
+}
+
+// FromTrustedProxy returns true if the request was made by a trusted proxy.
+// Without TrustedProxies middleware false is returned.
+func FromTrustedProxy(r *http.Request) bool {
+	fwd, _ := r.Context().Value(forwardedKey{}).(forwarded)
+	return fwd.trusted
+}
+
+// IsSecure returns true if the client used https.
+func IsSecure(r *http.Request) bool {
+	return RequestScheme(r) == "https"
+}
+
+// AbsoluteURL resolves ref, e.g. "/login?next=%2F", against the URL used by
+// the client to make request r.
+func AbsoluteURL(r *http.Request, ref string) (string, error) {
+	u, err := url.Parse(ref)
+	if err != nil {
+		return "", err
+	}
+	base := &url.URL{
+		Scheme:   RequestScheme(r),
+		Host:     RequestHost(r),
+		Path:     r.URL.Path,
+		RawQuery: r.URL.RawQuery,
+	}
+	return base.ResolveReference(u).String(), nil
+}
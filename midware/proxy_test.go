@@ -0,0 +1,73 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package midware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrustedProxies(t *testing.T) {
+	p, err := NewTrustedProxies("10.0.0.0/8", "192.168.1.1", "::1")
+	require.NoError(t, err)
+
+	var scheme, host, abs string
+	var trusted bool
+	h := p.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		trusted = FromTrustedProxy(r)
+		scheme = RequestScheme(r)
+		host = RequestHost(r)
+		abs, err = AbsoluteURL(r, "/login?next=%2F")
+		require.NoError(t, err)
+	}))
+
+	for _, test := range []struct {
+		name       string
+		remoteAddr string
+		proto      string
+		fwdHost    string
+		scheme     string
+		host       string
+	}{
+		{"trusted cidr", "10.1.2.3:1234", "https", "app.example.com", "https", "app.example.com"},
+		{"trusted ip", "192.168.1.1:1234", "HTTPS, http", "app.example.com, internal", "https", "app.example.com"},
+		{"trusted ipv6", "[::1]:1234", "https", "", "https", "oracle:8080"},
+		{"untrusted", "203.0.113.9:1234", "https", "evil.example.com", "http", "oracle:8080"},
+		{"invalid proto", "10.1.2.3:1234", "gopher", "", "http", "oracle:8080"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "http://oracle:8080/v1/x", nil)
+			r.RemoteAddr = test.remoteAddr
+			if test.proto != "" {
+				r.Header.Set("X-Forwarded-Proto", test.proto)
+			}
+			if test.fwdHost != "" {
+				r.Header.Set("X-Forwarded-Host", test.fwdHost)
+			}
+			h.ServeHTTP(httptest.NewRecorder(), r)
+			assert.Equal(t, test.scheme, scheme)
+			assert.Equal(t, test.host, host)
+			assert.Equal(t, test.scheme+"://"+test.host+"/login?next=%2F", abs)
+			assert.Equal(t, test.name != "untrusted", trusted)
+		})
+	}
+}
+
+func TestTrustedProxiesInvalid(t *testing.T) {
+	for _, s := range []string{"10.0.0.0/33", "not-an-ip", ""} {
+		_, err := NewTrustedProxies(s)
+		require.Error(t, err, s)
+	}
+}
+
+func TestRequestSchemeDirect(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "https://api.example.com/", nil)
+	require.Equal(t, "https", RequestScheme(r))
+	require.True(t, IsSecure(r))
+	require.Equal(t, "api.example.com", RequestHost(r))
+	require.False(t, FromTrustedProxy(r))
+}
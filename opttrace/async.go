@@ -0,0 +1,103 @@
+package opttrace
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// BaggageTenant is the baggage key of the tenant a request is made on
+	// behalf of.
+	BaggageTenant = "tenant"
+	// BaggageRequestID is the baggage key of the request ID.
+	BaggageRequestID = "req_id"
+
+	baggageAttributePrefix = "baggage."
+)
+
+// Origin is the trace context of a request which triggered asynchronous
+// work, such as a background goroutine or a queued task.  Spans started
+// from an Origin are linked to the request span and carry its baggage.
+type Origin struct {
+	spanContext trace.SpanContext
+	baggage     baggage.Baggage
+}
+
+// CaptureOrigin captures the span context and baggage of ctx, so they may
+// outlive the request.
+func CaptureOrigin(ctx context.Context) Origin {
+	return Origin{
+		spanContext: trace.SpanContextFromContext(ctx),
+		baggage:     baggage.FromContext(ctx),
+	}
+}
+
+// SpanContext returns the captured span context.
+func (o Origin) SpanContext() trace.SpanContext {
+	return o.spanContext
+}
+
+// Context returns a context carrying the origin baggage, derived from ctx.
+func (o Origin) Context(ctx context.Context) context.Context {
+	if o.baggage.Len() == 0 {
+		return ctx
+	}
+	return baggage.ContextWithBaggage(ctx, o.baggage)
+}
+
+// LinkedSpan starts a span in a new trace, linked to the origin span, and
+// returns a context carrying the span and the origin baggage.  Baggage
+// members are recorded as span attributes prefixed with "baggage.".  Use
+// LinkedSpan for work which continues after the request completes, where a
+// child span would misrepresent the request duration.  The returned span
+// must be ended to avoid leaking resources.
+func (t Tracer) LinkedSpan(ctx context.Context, origin Origin, spanName string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	opts = append([]trace.SpanStartOption{trace.WithNewRoot()}, opts...)
+	if origin.spanContext.IsValid() {
+		opts = append(opts, trace.WithLinks(trace.Link{SpanContext: origin.spanContext}))
+	}
+	var attrs []attribute.KeyValue
+	for _, m := range origin.baggage.Members() {
+		attrs = append(attrs, attribute.String(baggageAttributePrefix+m.Key(), m.Value()))
+	}
+	if len(attrs) > 0 {
+		opts = append(opts, trace.WithAttributes(attrs...))
+	}
+	return t.Span(origin.Context(ctx), spanName, opts...)
+}
+
+// Go runs fn in a new goroutine within a span linked to the span of ctx.
+// The goroutine context carries the baggage of ctx but is not cancelled
+// with it.
+func (t Tracer) Go(ctx context.Context, spanName string, fn func(ctx context.Context)) {
+	origin := CaptureOrigin(ctx)
+	go func() {
+		ctx, span := t.LinkedSpan(context.WithoutCancel(ctx), origin, spanName)
+		defer span.End()
+		fn(ctx)
+	}()
+}
+
+// WithBaggage returns a context with the baggage member key=value added,
+// propagated to downstream services by the baggage propagator.
+func WithBaggage(ctx context.Context, key string, value string) (context.Context, error) {
+	m, err := baggage.NewMemberRaw(key, value)
+	if err != nil {
+		return ctx, fmt.Errorf("baggage %s: %w", key, err)
+	}
+	b, err := baggage.FromContext(ctx).SetMember(m)
+	if err != nil {
+		return ctx, fmt.Errorf("baggage %s: %w", key, err)
+	}
+	return baggage.ContextWithBaggage(ctx, b), nil
+}
+
+// BaggageValue returns the value of the baggage member key, or the empty
+// string.
+func BaggageValue(ctx context.Context, key string) string {
+	return baggage.FromContext(ctx).Member(key).Value()
+}
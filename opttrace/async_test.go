@@ -0,0 +1,51 @@
+package opttrace
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestLinkedSpan(t *testing.T) {
+	exp := tracetest.NewInMemoryExporter()
+	tr, err := New(context.Background(), "test", WithExporter(exp), WithSyncExport())
+	require.NoError(t, err)
+
+	ctx, err := WithBaggage(context.Background(), BaggageTenant, "acme")
+	require.NoError(t, err)
+	ctx, err = WithBaggage(ctx, BaggageRequestID, "req-1")
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(ctx)
+	ctx, req := tr.Span(ctx, "request")
+	origin := CaptureOrigin(ctx)
+
+	done := make(chan struct{})
+	tr.Go(ctx, "background", func(ctx context.Context) {
+		defer close(done)
+		<-time.After(10 * time.Millisecond)
+		require.NoError(t, ctx.Err())
+		require.Equal(t, "acme", BaggageValue(ctx, BaggageTenant))
+	})
+	// The request completes before the background work.
+	req.End()
+	cancel()
+	<-done
+
+	_, task := tr.LinkedSpan(context.Background(), origin, "task")
+	task.End()
+
+	require.Eventually(t, func() bool { return len(exp.GetSpans()) == 3 }, time.Second, time.Millisecond)
+	spans := exp.GetSpans().Snapshots()
+	for _, s := range spans[1:] {
+		require.NotEqual(t, origin.SpanContext().TraceID(), s.SpanContext().TraceID(), s.Name())
+		require.Len(t, s.Links(), 1)
+		require.Equal(t, origin.SpanContext(), s.Links()[0].SpanContext)
+		require.Contains(t, s.Attributes(), attribute.String("baggage.tenant", "acme"))
+		require.Contains(t, s.Attributes(), attribute.String("baggage.req_id", "req-1"))
+	}
+	require.Equal(t, "", BaggageValue(context.Background(), BaggageTenant))
+}
@@ -0,0 +1,115 @@
+package opttrace
+
+import (
+	"context"
+	"strings"
+
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	b3SingleHeader   = "b3"
+	b3TraceIDHeader  = "x-b3-traceid"
+	b3SpanIDHeader   = "x-b3-spanid"
+	b3SampledHeader  = "x-b3-sampled"
+	b3FlagsHeader    = "x-b3-flags"
+	b3ParentIDHeader = "x-b3-parentspanid"
+)
+
+// b3Propagator propagates trace context using the Zipkin B3 headers
+// (https://github.com/openzipkin/b3-propagation).  Both the single and
+// multiple header encodings are extracted, single determines which encoding
+// is injected.
+type b3Propagator struct {
+	single bool
+}
+
+var _ propagation.TextMapPropagator = b3Propagator{}
+
+// Inject implements propagation.TextMapPropagator.
+func (p b3Propagator) Inject(ctx context.Context, carrier propagation.TextMapCarrier) {
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		return
+	}
+	sampled := "0"
+	if sc.IsSampled() {
+		sampled = "1"
+	}
+	if p.single {
+		carrier.Set(b3SingleHeader, sc.TraceID().String()+"-"+sc.SpanID().String()+"-"+sampled)
+		return
+	}
+	carrier.Set(b3TraceIDHeader, sc.TraceID().String())
+	carrier.Set(b3SpanIDHeader, sc.SpanID().String())
+	carrier.Set(b3SampledHeader, sampled)
+}
+
+// Extract implements propagation.TextMapPropagator.
+func (p b3Propagator) Extract(ctx context.Context, carrier propagation.TextMapCarrier) context.Context {
+	var sc trace.SpanContext
+	if h := carrier.Get(b3SingleHeader); h != "" {
+		sc = b3ExtractSingle(h)
+	} else {
+		sampled := carrier.Get(b3SampledHeader)
+		if carrier.Get(b3FlagsHeader) == "1" {
+			sampled = "d"
+		}
+		sc = b3SpanContext(carrier.Get(b3TraceIDHeader), carrier.Get(b3SpanIDHeader), sampled)
+	}
+	if !sc.IsValid() {
+		return ctx
+	}
+	return trace.ContextWithRemoteSpanContext(ctx, sc)
+}
+
+// Fields implements propagation.TextMapPropagator.
+func (p b3Propagator) Fields() []string {
+	if p.single {
+		return []string{b3SingleHeader}
+	}
+	return []string{b3TraceIDHeader, b3SpanIDHeader, b3SampledHeader, b3FlagsHeader, b3ParentIDHeader}
+}
+
+// b3ExtractSingle parses a header in the format
+// {TraceId}-{SpanId}-{SamplingState}-{ParentSpanId}, where the last two
+// fields are optional.
+func b3ExtractSingle(h string) trace.SpanContext {
+	parts := strings.Split(h, "-")
+	if len(parts) < 2 || len(parts) > 4 {
+		// a bare sampling decision carries no span context
+		return trace.SpanContext{}
+	}
+	var sampled string
+	if len(parts) > 2 {
+		sampled = parts[2]
+	}
+	return b3SpanContext(parts[0], parts[1], sampled)
+}
+
+func b3SpanContext(traceID, spanID, sampled string) trace.SpanContext {
+	if len(traceID) == 16 {
+		// 64-bit trace IDs are left-padded to 128 bits
+		traceID = strings.Repeat("0", 16) + traceID
+	}
+	tid, err := trace.TraceIDFromHex(traceID)
+	if err != nil {
+		return trace.SpanContext{}
+	}
+	sid, err := trace.SpanIDFromHex(spanID)
+	if err != nil {
+		return trace.SpanContext{}
+	}
+	var flags trace.TraceFlags
+	switch strings.ToLower(sampled) {
+	case "1", "true", "d":
+		flags = trace.FlagsSampled
+	}
+	return trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    tid,
+		SpanID:     sid,
+		TraceFlags: flags,
+		Remote:     true,
+	})
+}
@@ -0,0 +1,41 @@
+package opttrace
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestB3RoundTrip(t *testing.T) {
+	sc := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{0x4b, 0xf9, 0x2f, 0x35, 0x77, 0xb3, 0x4d, 0xa6, 0xa3, 0xce, 0x92, 0x9d, 0x0e, 0x0e, 0x47, 0x36},
+		SpanID:     trace.SpanID{0x00, 0xf0, 0x67, 0xaa, 0x0b, 0xa9, 0x02, 0xb7},
+		TraceFlags: trace.FlagsSampled,
+	})
+	ctx := trace.ContextWithSpanContext(context.Background(), sc)
+	for _, p := range []b3Propagator{{single: true}, {}} {
+		carrier := propagation.MapCarrier{}
+		p.Inject(ctx, carrier)
+		got := trace.SpanContextFromContext(p.Extract(context.Background(), carrier))
+		require.True(t, got.IsValid())
+		require.Equal(t, sc.TraceID(), got.TraceID())
+		require.Equal(t, sc.SpanID(), got.SpanID())
+		require.True(t, got.IsSampled())
+	}
+}
+
+func TestB3Extract(t *testing.T) {
+	p := b3Propagator{}
+	carrier := propagation.MapCarrier{b3SingleHeader: "a3ce929d0e0e4736-00f067aa0ba902b7-d"}
+	got := trace.SpanContextFromContext(p.Extract(context.Background(), carrier))
+	require.True(t, got.IsValid())
+	require.Equal(t, "0000000000000000a3ce929d0e0e4736", got.TraceID().String())
+	require.True(t, got.IsSampled())
+
+	carrier = propagation.MapCarrier{b3SingleHeader: "0"}
+	got = trace.SpanContextFromContext(p.Extract(context.Background(), carrier))
+	require.False(t, got.IsValid())
+}
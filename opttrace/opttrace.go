@@ -9,6 +9,7 @@ import (
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/propagation"
 	"go.opentelemetry.io/otel/sdk/resource"
 	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
@@ -23,7 +24,8 @@ var noopTracerProvider = noop.NewTracerProvider()
 // Tracer provides a tracing interface that generates traces only if configured
 // with a trace exporter
 type Tracer struct {
-	exportTP *sdktrace.TracerProvider
+	exportTP   *sdktrace.TracerProvider
+	propagator propagation.TextMapPropagator
 }
 
 // Option provides Tracer configuration options
@@ -35,6 +37,7 @@ type config struct {
 	syncExport      bool
 	batchOpts       []sdktrace.BatchSpanProcessorOption
 	exporter        sdktrace.SpanExporter
+	propagator      propagation.TextMapPropagator
 }
 
 // WithOTLPExporter configured an OTLP trace exporter
@@ -80,6 +83,35 @@ func WithExporter(exp sdktrace.SpanExporter) Option {
 	}
 }
 
+// WithPropagators sets the propagation formats used to extract and inject
+// trace context across process boundaries.  If more than one propagator is
+// given then a composite propagator is used, which extracts any of the formats
+// and injects all of them.  The propagators are installed globally by
+// SetGlobalTracer.
+func WithPropagators(propagators ...Propagator) Option {
+	return func(c *config) error {
+		ps := make([]propagation.TextMapPropagator, 0, len(propagators))
+		for _, p := range propagators {
+			tmp, err := p.textMapPropagator()
+			if err != nil {
+				return err
+			}
+			ps = append(ps, tmp)
+		}
+		c.propagator = propagation.NewCompositeTextMapPropagator(ps...)
+		return nil
+	}
+}
+
+// WithTextMapPropagator sets a custom propagator, installed globally by
+// SetGlobalTracer.
+func WithTextMapPropagator(p propagation.TextMapPropagator) Option {
+	return func(c *config) error {
+		c.propagator = p
+		return nil
+	}
+}
+
 // New creates a Tracer that will create spans if configured with an exporter.
 // If not, the Span method will use a no-op tracing provider. When enabled, the
 // spans will have the supplied service name.  The context is used to initialize
@@ -96,7 +128,7 @@ func New(ctx context.Context, serviceName string, opts ...Option) (*Tracer, erro
 	exp := c.exporter
 	if exp == nil {
 		if c.otlpEndpointURI == "" {
-			return &Tracer{}, nil
+			return &Tracer{propagator: c.propagator}, nil
 		}
 		exp, err = otlpExporter(ctx, c.otlpEndpointURI)
 		if err != nil {
@@ -127,7 +159,8 @@ func New(ctx context.Context, serviceName string, opts ...Option) (*Tracer, erro
 			sdktrace.WithBatcher(exp, c.batchOpts...))
 	}
 	return &Tracer{
-		exportTP: sdktrace.NewTracerProvider(tpOpts...),
+		exportTP:   sdktrace.NewTracerProvider(tpOpts...),
+		propagator: c.propagator,
 	}, nil
 }
 
@@ -162,9 +195,14 @@ func (t Tracer) Shutdown(ctx context.Context) error {
 	return nil
 }
 
-// SetGlobalTracer sets the global tracer provider to this tracer instance
+// SetGlobalTracer sets the global tracer provider to this tracer instance.
+// If the tracer was configured with propagators they are set as the global
+// text map propagator.
 func (t Tracer) SetGlobalTracer() {
 	if t.exportTP != nil {
 		otel.SetTracerProvider(t.exportTP)
 	}
+	if t.propagator != nil {
+		otel.SetTextMapPropagator(t.propagator)
+	}
 }
@@ -0,0 +1,51 @@
+package opttrace
+
+import (
+	"fmt"
+	"net/http"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// Propagator names a trace context propagation format.
+type Propagator string
+
+const (
+	// PropagatorW3C is the W3C trace context format (traceparent and
+	// tracestate headers).
+	PropagatorW3C Propagator = "w3c"
+	// PropagatorBaggage is the W3C baggage format.
+	PropagatorBaggage Propagator = "baggage"
+	// PropagatorB3 is the Zipkin B3 single header format.
+	PropagatorB3 Propagator = "b3"
+	// PropagatorB3Multi is the Zipkin B3 multiple header format (X-B3-*
+	// headers), used by older Jaeger and Zipkin deployments.
+	PropagatorB3Multi Propagator = "b3multi"
+)
+
+func (p Propagator) textMapPropagator() (propagation.TextMapPropagator, error) {
+	switch p {
+	case PropagatorW3C:
+		return propagation.TraceContext{}, nil
+	case PropagatorBaggage:
+		return propagation.Baggage{}, nil
+	case PropagatorB3:
+		return b3Propagator{single: true}, nil
+	case PropagatorB3Multi:
+		return b3Propagator{}, nil
+	default:
+		return nil, fmt.Errorf("unknown propagator: %q", p)
+	}
+}
+
+// ExtractHTTP is HTTP middleware that extracts trace context from incoming
+// request headers, using the global propagator, so that spans created while
+// serving the request join the caller's trace.  ExtractHTTP may be used in a
+// midware.Chain by wrapping it with midware.Func.
+func ExtractHTTP(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
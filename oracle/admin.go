@@ -0,0 +1,247 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package oracle
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	healthcheck "buf.build/gen/go/luthersystems/protos/protocolbuffers/go/healthcheck/v1"
+	"github.com/luthersystems/svc/midware"
+	"github.com/luthersystems/svc/svcerr"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// adminPathPrefix prefixes all endpoints served by the admin server.
+	adminPathPrefix = "/admin/"
+)
+
+// validAdmin validates the admin server configuration.
+func (c *Config) validAdmin() error {
+	if c.AdminListenAddress == "" {
+		return nil
+	}
+	if c.AdminListenAddress == c.ListenAddress {
+		return fmt.Errorf("admin listen address must differ from listen address")
+	}
+	if c.AdminToken == "" && c.AdminClientCAFile == "" {
+		return fmt.Errorf("admin server requires an admin token or client CA")
+	}
+	if c.AdminClientCAFile != "" && (c.AdminTLSCertFile == "" || c.AdminTLSKeyFile == "") {
+		return fmt.Errorf("admin client CA requires an admin TLS certificate and key")
+	}
+	return nil
+}
+
+// adminAuth rejects requests which do not present the admin token.  Client
+// certificates are verified by the TLS listener when mTLS is configured.
+func (orc *Oracle) adminAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if orc.cfg.AdminToken != "" {
+			token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if subtle.ConstantTimeCompare([]byte(token), []byte(orc.cfg.AdminToken)) != 1 {
+				ex := svcerr.SecurityException(r.Context(), "unauthenticated")
+				if err := writeExceptionHTTP(w, http.StatusUnauthorized, ex); err != nil {
+					orc.log(r.Context()).WithError(err).Errorf("admin response error")
+				}
+				return
+			}
+		}
+		orc.log(r.Context()).WithFields(logrus.Fields{
+			"audit_event": "admin",
+			"path":        r.URL.Path,
+			"method":      r.Method,
+		}).Infof("admin request")
+		next.ServeHTTP(w, r)
+	})
+}
+
+// adminHandler returns the handler for the admin server.
+func (orc *Oracle) adminHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle(adminPathPrefix+"maintenance", orc.adminMaintenanceHandler())
+	mux.Handle(adminPathPrefix+"notice", orc.adminNoticeHandler())
+	mux.Handle(adminPathPrefix+"configz", orc.configzHandler())
+	mux.Handle(adminPathPrefix+"schema", orc.schemazHandler())
+	if orc.levels != nil {
+		mux.Handle(adminPathPrefix+"loglevel", orc.levels.Handler())
+	}
+	mux.Handle(adminPathPrefix+"phylum", orc.adminPhylumHandler())
+	if orc.cfg.EmulateCC {
+		mux.Handle(adminPathPrefix+"snapshot", orc.adminSnapshotHandler())
+	}
+	return orc.adminAuth(mux)
+}
+
+func (orc *Oracle) writeAdminJSON(w http.ResponseWriter, r *http.Request, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		orc.log(r.Context()).WithError(err).Errorf("admin response error")
+	}
+}
+
+func (orc *Oracle) writeAdminError(w http.ResponseWriter, r *http.Request, code int, msg string) {
+	ex := svcerr.BusinessException(r.Context(), msg)
+	if err := writeExceptionHTTP(w, code, ex); err != nil {
+		orc.log(r.Context()).WithError(err).Errorf("admin response error")
+	}
+}
+
+// SetMaintenance enables or disables maintenance mode.  In maintenance mode
+// the oracle responds to all requests other than health checks with 503.
+func (orc *Oracle) SetMaintenance(enabled bool) {
+	orc.maintenance.Store(enabled)
+}
+
+// Maintenance returns true if maintenance mode is enabled.
+func (orc *Oracle) Maintenance() bool {
+	return orc.maintenance.Load()
+}
+
+// maintenanceMiddleware rejects requests while in maintenance mode.
+func (orc *Oracle) maintenanceMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if orc.Maintenance() && r.URL.Path != healthCheckPath {
+			ex := svcerr.ServiceException(r.Context(), "service under maintenance")
+			if err := writeExceptionHTTP(w, http.StatusServiceUnavailable, ex); err != nil {
+				orc.log(r.Context()).WithError(err).Errorf("maintenance response error")
+			}
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (orc *Oracle) adminMaintenanceHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			enabled, err := strconv.ParseBool(r.URL.Query().Get("enabled"))
+			if err != nil {
+				orc.writeAdminError(w, r, http.StatusBadRequest, "invalid enabled parameter")
+				return
+			}
+			orc.SetMaintenance(enabled)
+			orc.log(r.Context()).WithField("maintenance", enabled).Warnf("maintenance mode changed")
+		}
+		orc.writeAdminJSON(w, r, map[string]bool{"enabled": orc.Maintenance()})
+	})
+}
+
+// SetServiceNotice sets the operational notice, such as planned downtime,
+// whose headers are added to all responses.  A nil notice removes them.
+func (orc *Oracle) SetServiceNotice(n *midware.Notice) {
+	orc.notice.Set(n)
+}
+
+// ServiceNotice returns the current operational notice, or nil.
+func (orc *Oracle) ServiceNotice() *midware.Notice {
+	return orc.notice.Get()
+}
+
+func (orc *Oracle) adminNoticeHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPost:
+			n := &midware.Notice{}
+			if err := json.NewDecoder(r.Body).Decode(n); err != nil {
+				orc.writeAdminError(w, r, http.StatusBadRequest, "invalid notice")
+				return
+			}
+			orc.SetServiceNotice(n)
+			orc.log(r.Context()).WithField("notice", n.Message).Warnf("service notice changed")
+		case http.MethodDelete:
+			orc.SetServiceNotice(nil)
+			orc.log(r.Context()).Warnf("service notice removed")
+		}
+		n := orc.ServiceNotice()
+		if n == nil {
+			n = &midware.Notice{}
+		}
+		orc.writeAdminJSON(w, r, n)
+	})
+}
+
+func (orc *Oracle) adminPhylumHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+		defer cancel()
+		resp := &healthcheck.GetHealthCheckResponse{Reports: orc.phylumHealthCheck(ctx)}
+		if err := writeProtoHTTP(w, http.StatusOK, resp); err != nil {
+			orc.log(ctx).WithError(err).Errorf("admin response error")
+		}
+	})
+}
+
+func (orc *Oracle) adminSnapshotHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var snapshot bytes.Buffer
+		if err := orc.phylum.MockSnapshot(&snapshot); err != nil {
+			orc.log(r.Context()).WithError(err).Errorf("admin snapshot")
+			orc.writeAdminError(w, r, http.StatusInternalServerError, "snapshot failed")
+			return
+		}
+		w.Header().Set("Content-Type", "application/octet-stream")
+		if _, err := w.Write(snapshot.Bytes()); err != nil {
+			orc.log(r.Context()).WithError(err).Errorf("admin response error")
+		}
+	})
+}
+
+// adminTLSConfig returns the TLS config for the admin server, or nil if TLS is
+// not configured.
+func (orc *Oracle) adminTLSConfig() (*tls.Config, error) {
+	if orc.cfg.AdminTLSCertFile == "" {
+		return nil, nil
+	}
+	cert, err := tls.LoadX509KeyPair(orc.cfg.AdminTLSCertFile, orc.cfg.AdminTLSKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("admin tls: %w", err)
+	}
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+	if orc.cfg.AdminClientCAFile != "" {
+		caPEM, err := os.ReadFile(filepath.Clean(orc.cfg.AdminClientCAFile))
+		if err != nil {
+			return nil, fmt.Errorf("admin client ca: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caPEM) {
+			return nil, fmt.Errorf("admin client ca: no certificates found")
+		}
+		tlsConfig.ClientCAs = pool
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return tlsConfig, nil
+}
+
+// adminServer returns the admin server, or nil if it is not configured.
+func (orc *Oracle) adminServer() (*http.Server, error) {
+	if orc.cfg.AdminListenAddress == "" {
+		return nil, nil
+	}
+	tlsConfig, err := orc.adminTLSConfig()
+	if err != nil {
+		return nil, err
+	}
+	return &http.Server{
+		Addr:              orc.cfg.AdminListenAddress,
+		Handler:           orc.adminHandler(),
+		TLSConfig:         tlsConfig,
+		WriteTimeout:      30 * time.Second,
+		ReadHeaderTimeout: 2 * time.Second,
+	}, nil
+}
@@ -0,0 +1,80 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package oracle
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdminMaintenance(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.AdminListenAddress = ":8081"
+	cfg.AdminToken = "secret"
+	require.NoError(t, cfg.Valid())
+	orc := newTestOracle(t, cfg)
+	admin := orc.adminHandler()
+
+	req := httptest.NewRequest(http.MethodPost, "/admin/maintenance?enabled=true", nil)
+	rr := httptest.NewRecorder()
+	admin.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusUnauthorized, rr.Code)
+	require.False(t, orc.Maintenance())
+
+	req.Header.Set("Authorization", "Bearer secret")
+	rr = httptest.NewRecorder()
+	admin.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.True(t, orc.Maintenance())
+
+	app := orc.maintenanceMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	rr = httptest.NewRecorder()
+	app.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/v1/foo", nil))
+	require.Equal(t, http.StatusServiceUnavailable, rr.Code)
+	rr = httptest.NewRecorder()
+	app.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, healthCheckPath, nil))
+	require.Equal(t, http.StatusOK, rr.Code)
+}
+
+func TestAdminConfigValid(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.AdminListenAddress = ":8081"
+	require.Error(t, cfg.Valid())
+	cfg.AdminClientCAFile = "ca.pem"
+	require.Error(t, cfg.Valid())
+	cfg.AdminTLSCertFile = "cert.pem"
+	cfg.AdminTLSKeyFile = "key.pem"
+	require.NoError(t, cfg.Valid())
+}
+
+func TestAdminNotice(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.AdminListenAddress = ":8081"
+	cfg.AdminToken = "secret"
+	orc := newTestOracle(t, cfg)
+	admin := orc.adminHandler()
+
+	req := httptest.NewRequest(http.MethodPost, "/admin/notice", strings.NewReader(`{"message":"planned maintenance","sunset":"2024-06-01T02:00:00Z"}`))
+	req.Header.Set("Authorization", "Bearer secret")
+	rr := httptest.NewRecorder()
+	admin.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Equal(t, "planned maintenance", orc.ServiceNotice().Message)
+
+	app := orc.notice.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	rr = httptest.NewRecorder()
+	app.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/v1/foo", nil))
+	require.Equal(t, "planned maintenance", rr.Header().Get("X-Service-Notice"))
+	require.Equal(t, "Sat, 01 Jun 2024 02:00:00 GMT", rr.Header().Get("Sunset"))
+
+	req = httptest.NewRequest(http.MethodDelete, "/admin/notice", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	rr = httptest.NewRecorder()
+	admin.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Nil(t, orc.ServiceNotice())
+}
@@ -0,0 +1,88 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package oracle
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/luthersystems/svc/logmon"
+	"github.com/sirupsen/logrus"
+)
+
+// alertCloseTimeout is the time allowed to send queued alerts at shutdown.
+const alertCloseTimeout = 5 * time.Second
+
+// AlertWebhook forwards error logs to an alerting webhook, such as a Slack
+// or Teams incoming webhook.
+type AlertWebhook struct {
+	// URL is the webhook URL.  Alerting is disabled if the URL is empty.
+	URL string `yaml:"url" secret:"true"`
+	// DedupWindow is the window within which identical errors are only
+	// alerted once.  Defaults to 5 minutes.
+	DedupWindow time.Duration `yaml:"dedup-window"`
+	// RateLimit is the maximum number of alerts sent per minute.  Defaults
+	// to 10.
+	RateLimit int `yaml:"rate-limit"`
+}
+
+// valid validates the alert webhook configuration.
+func (w AlertWebhook) valid() error {
+	if w.URL == "" {
+		return nil
+	}
+	u, err := url.Parse(w.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("alert webhook: invalid url")
+	}
+	if w.DedupWindow < 0 {
+		return fmt.Errorf("alert webhook: negative dedup window")
+	}
+	if w.RateLimit < 0 {
+		return fmt.Errorf("alert webhook: negative rate limit")
+	}
+	return nil
+}
+
+// startAlerts installs the alert hook on the oracle logger.
+func (orc *Oracle) startAlerts() {
+	w := orc.cfg.AlertWebhook
+	if w.URL == "" {
+		return
+	}
+	opts := []logmon.AlertOption{logmon.WithAlertService(orc.cfg.ServiceName)}
+	if w.DedupWindow > 0 {
+		opts = append(opts, logmon.WithAlertDedupWindow(w.DedupWindow))
+	}
+	if w.RateLimit > 0 {
+		opts = append(opts, logmon.WithAlertRateLimit(w.RateLimit))
+	}
+	orc.alertHook = logmon.NewAlertHook(w.URL, opts...)
+	orc.logBase.Logger.AddHook(orc.alertHook)
+}
+
+// stopAlerts removes the alert hook from the oracle logger and sends
+// queued alerts.
+func (orc *Oracle) stopAlerts() {
+	if orc.alertHook == nil {
+		return
+	}
+	logger := orc.logBase.Logger
+	hooks := make(logrus.LevelHooks)
+	for level, levelHooks := range logger.Hooks {
+		for _, h := range levelHooks {
+			if h != orc.alertHook {
+				hooks[level] = append(hooks[level], h)
+			}
+		}
+	}
+	logger.ReplaceHooks(hooks)
+	ctx, cancel := context.WithTimeout(context.Background(), alertCloseTimeout)
+	defer cancel()
+	if err := orc.alertHook.Close(ctx); err != nil {
+		orc.logBase.WithError(err).Warn("failed to send queued alerts")
+	}
+	orc.alertHook = nil
+}
@@ -0,0 +1,56 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package oracle
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAlertWebhookValid(t *testing.T) {
+	require.NoError(t, AlertWebhook{}.valid())
+	require.NoError(t, AlertWebhook{URL: "https://hooks.slack.com/services/T/B/X"}.valid())
+	require.Error(t, AlertWebhook{URL: "hooks.slack.com"}.valid())
+	require.Error(t, AlertWebhook{URL: "https://hooks.slack.com", RateLimit: -1}.valid())
+	require.Error(t, AlertWebhook{URL: "https://hooks.slack.com", DedupWindow: -1}.valid())
+
+	cfg := DefaultConfig()
+	cfg.AlertWebhook.URL = "https://hooks.slack.com/services/T/B/X"
+	b, err := cfg.MaskedYAML()
+	require.NoError(t, err)
+	require.NotContains(t, string(b), "hooks.slack.com")
+}
+
+func TestAlerts(t *testing.T) {
+	var mut sync.Mutex
+	var bodies []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		mut.Lock()
+		defer mut.Unlock()
+		bodies = append(bodies, string(b))
+	}))
+	defer srv.Close()
+
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	cfg := DefaultConfig()
+	cfg.AlertWebhook.URL = srv.URL
+	orc := newTestOracle(t, cfg, withLogBase(logrus.NewEntry(logger)))
+	orc.logBase.WithField("req_id", "req-1").Error("phylum call failed")
+	orc.stopAlerts()
+	orc.logBase.Error("after stop")
+	require.Empty(t, logger.Hooks[logrus.ErrorLevel])
+
+	mut.Lock()
+	defer mut.Unlock()
+	require.Len(t, bodies, 1)
+	require.True(t, strings.Contains(bodies[0], `"text":"[oracle] error: phylum call failed (req_id=req-1)"`), bodies[0])
+}
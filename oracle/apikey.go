@@ -0,0 +1,144 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package oracle
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/luthersystems/svc/svcerr"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// APIKeyAuthMethod is the value of the "auth_method" claim of users
+	// authenticated with an API key.
+	APIKeyAuthMethod = "api_key"
+)
+
+// ErrAPIKeyNotFound is returned by an APIKeyStore for unknown keys.
+var ErrAPIKeyNotFound = errors.New("api key not found")
+
+// APIKey authenticates a machine-to-machine client.
+type APIKey struct {
+	// Key is the secret presented by the client in the API key header.
+	Key string `yaml:"key" secret:"true"`
+	// Subject is the "sub" claim of the client.
+	Subject string `yaml:"subject"`
+	// Roles is the "roles" claim of the client.
+	Roles []string `yaml:"roles"`
+}
+
+func (k APIKey) valid() error {
+	if k.Key == "" {
+		return fmt.Errorf("api key %s: missing key", k.Subject)
+	}
+	if k.Subject == "" {
+		return fmt.Errorf("api key: missing subject")
+	}
+	return nil
+}
+
+// claims returns the synthetic claims of the key.
+func (k APIKey) claims() map[string]interface{} {
+	roles := make([]interface{}, len(k.Roles))
+	for i, r := range k.Roles {
+		roles[i] = r
+	}
+	return map[string]interface{}{
+		"sub":         k.Subject,
+		"roles":       roles,
+		"auth_method": APIKeyAuthMethod,
+	}
+}
+
+// APIKeyStore looks up API keys, e.g. in a database.
+type APIKeyStore interface {
+	// LookupAPIKey returns the API key with the given secret, or
+	// ErrAPIKeyNotFound.
+	LookupAPIKey(ctx context.Context, key string) (*APIKey, error)
+}
+
+// SetAPIKeyStore configures a store of API keys, consulted for keys which
+// are not configured statically in APIKeys.
+func (c *Config) SetAPIKeyStore(store APIKeyStore) {
+	if c == nil {
+		return
+	}
+	c.apiKeyStore = store
+}
+
+// apiKeysEnabled returns true if API key authentication is configured.
+func (c *Config) apiKeysEnabled() bool {
+	return c.APIKeyHeader != "" && (len(c.APIKeys) > 0 || c.apiKeyStore != nil)
+}
+
+// validAPIKeys validates the API key configuration.
+func (c *Config) validAPIKeys() error {
+	if c.APIKeyHeader == "" && (len(c.APIKeys) > 0 || c.apiKeyStore != nil) {
+		return fmt.Errorf("api keys: missing api key header")
+	}
+	seen := make(map[string]bool)
+	for _, k := range c.APIKeys {
+		if err := k.valid(); err != nil {
+			return err
+		}
+		if seen[k.Key] {
+			return fmt.Errorf("api key %s: duplicate key", k.Subject)
+		}
+		seen[k.Key] = true
+	}
+	return nil
+}
+
+// apiKeyIndex returns the static API keys indexed by the hash of their
+// secret, so lookups do not compare secrets directly.
+func apiKeyIndex(keys []APIKey) map[[sha256.Size]byte]APIKey {
+	index := make(map[[sha256.Size]byte]APIKey, len(keys))
+	for _, k := range keys {
+		index[sha256.Sum256([]byte(k.Key))] = k
+	}
+	return index
+}
+
+// requestAPIKey returns the API key presented with the request, if any.
+func (orc *Oracle) requestAPIKey(ctx context.Context) (string, bool) {
+	if !orc.cfg.apiKeysEnabled() {
+		return "", false
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	vals := md.Get(strings.ToLower(orc.cfg.APIKeyHeader))
+	if len(vals) == 0 || vals[0] == "" {
+		return "", false
+	}
+	return vals[0], true
+}
+
+// apiKeyClaims returns the synthetic claims of a client authenticated with
+// an API key.  Unknown keys fail with a SecurityException.
+func (orc *Oracle) apiKeyClaims(ctx context.Context, key string) (map[string]interface{}, error) {
+	if k, ok := orc.apiKeys[sha256.Sum256([]byte(key))]; ok {
+		return k.claims(), nil
+	}
+	if orc.cfg.apiKeyStore != nil {
+		k, err := orc.cfg.apiKeyStore.LookupAPIKey(ctx, key)
+		if err == nil && k != nil {
+			return k.claims(), nil
+		}
+		if err != nil && !errors.Is(err, ErrAPIKeyNotFound) {
+			return nil, fmt.Errorf("api key lookup: %w", err)
+		}
+	}
+	orc.log(ctx).Infof("unknown api key")
+	st, err := status.New(codes.Unauthenticated, "unauthenticated").
+		WithDetails(svcerr.SecurityException(ctx, "unauthenticated"))
+	if err != nil {
+		return nil, fmt.Errorf("api key: %w", err)
+	}
+	return nil, st.Err()
+}
@@ -0,0 +1,76 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package oracle
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+type apiKeyStoreFunc func(ctx context.Context, key string) (*APIKey, error)
+
+func (f apiKeyStoreFunc) LookupAPIKey(ctx context.Context, key string) (*APIKey, error) {
+	return f(ctx, key)
+}
+
+func TestAPIKeyClaims(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.APIKeyHeader = "X-API-Key"
+	cfg.APIKeys = []APIKey{{Key: "static-secret", Subject: "batch", Roles: []string{"reader"}}}
+	cfg.SetAPIKeyStore(apiKeyStoreFunc(func(ctx context.Context, key string) (*APIKey, error) {
+		if key == "stored-secret" {
+			return &APIKey{Key: key, Subject: "partner"}, nil
+		}
+		return nil, ErrAPIKeyNotFound
+	}))
+	cfg.SetClaimsGetter(func(ctx context.Context) (map[string]interface{}, error) {
+		return map[string]interface{}{"sub": "alice"}, nil
+	})
+	// API key claims are not subject to the JWT schema.
+	cfg.ClaimsSchema.Audiences = []string{"app"}
+	require.NoError(t, cfg.Valid())
+	require.Contains(t, (newTestOracle(t, cfg)).gatewayForwardedHeaders(), "X-API-Key")
+	orc := newTestOracle(t, cfg)
+
+	withKey := func(key string) context.Context {
+		return metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-api-key", key))
+	}
+
+	claims, err := orc.GetClaims(withKey("static-secret"))
+	require.NoError(t, err)
+	require.Equal(t, "batch", claims["sub"])
+	require.Equal(t, []interface{}{"reader"}, claims["roles"])
+	require.Equal(t, APIKeyAuthMethod, claims["auth_method"])
+
+	claims, err = orc.GetClaims(withKey("stored-secret"))
+	require.NoError(t, err)
+	require.Equal(t, "partner", claims["sub"])
+
+	_, err = orc.GetClaims(withKey("wrong"))
+	require.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	// Requests without a key use the cookie/JWT path.
+	orc.cfg.ClaimsSchema = ClaimsSchema{}
+	claims, err = orc.GetClaims(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, "alice", claims["sub"])
+}
+
+func TestAPIKeyConfigInvalid(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.APIKeys = []APIKey{{Key: "secret", Subject: "batch"}}
+	require.Error(t, cfg.Valid())
+	cfg.APIKeyHeader = "X-API-Key"
+	require.NoError(t, cfg.Valid())
+	cfg.APIKeys = append(cfg.APIKeys, APIKey{Key: "secret", Subject: "other"})
+	require.Error(t, cfg.Valid())
+
+	masked := cfg.Masked()
+	require.Equal(t, maskedValue, masked.APIKeys[0].Key)
+	require.Equal(t, "secret", cfg.APIKeys[0].Key)
+}
@@ -0,0 +1,139 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package oracle
+
+import (
+	"context"
+	"sync"
+
+	"github.com/luthersystems/shiroclient-sdk-go/shiroclient"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"google.golang.org/protobuf/proto"
+)
+
+// defaultBatchConcurrency is the default number of concurrent calls made by
+// CallBatch.
+const defaultBatchConcurrency = 4
+
+// BatchCall is a phylum call made by CallBatch.  Resp is populated with the
+// phylum response when the call succeeds.
+type BatchCall struct {
+	// Method is the phylum method name.
+	Method string
+	// Req is the request message.
+	Req proto.Message
+	// Resp is the response message populated by the call.
+	Resp proto.Message
+	// Config holds additional configs for the call.
+	Config []shiroclient.Config
+}
+
+// BatchResult is the result of a call made by CallBatch.
+type BatchResult struct {
+	// Method is the phylum method name.
+	Method string
+	// Resp is the populated response message, or nil if the call failed.
+	Resp proto.Message
+	// Err is the error returned by the call.
+	Err error
+}
+
+// BatchResults are the results of CallBatch, in the order of the calls.
+type BatchResults []BatchResult
+
+// Failed returns the results of the calls that failed.
+func (r BatchResults) Failed() []BatchResult {
+	var failed []BatchResult
+	for _, res := range r {
+		if res.Err != nil {
+			failed = append(failed, res)
+		}
+	}
+	return failed
+}
+
+// Err returns the error of the first call that failed, or nil if all calls
+// succeeded.  The error is returned unwrapped so its status is preserved.
+func (r BatchResults) Err() error {
+	for _, res := range r {
+		if res.Err != nil {
+			return res.Err
+		}
+	}
+	return nil
+}
+
+// BatchOption configures CallBatch.
+type BatchOption func(*batchConfig)
+
+type batchConfig struct {
+	concurrency int
+}
+
+// WithBatchConcurrency sets the maximum number of concurrent phylum calls.
+// Defaults to 4.
+func WithBatchConcurrency(n int) BatchOption {
+	return func(cfg *batchConfig) {
+		if n > 0 {
+			cfg.concurrency = n
+		}
+	}
+}
+
+// CallBatch makes independent phylum calls concurrently, with bounded
+// parallelism, and returns all of their results.  A failed call does not
+// cancel the others; use the returned results to handle partial failures.
+// Calls which have not started when ctx is done fail with its error.  The
+// calls share a parent "CallBatch" span.
+func CallBatch(s *Oracle, ctx context.Context, calls []BatchCall, opts ...BatchOption) BatchResults {
+	cfg := &batchConfig{concurrency: defaultBatchConcurrency}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	ctx, span := s.tracer.Span(ctx, "CallBatch")
+	defer span.End()
+	span.SetAttributes(attribute.Int("app.batch.size", len(calls)))
+	results := callBatch(ctx, calls, cfg.concurrency, func(ctx context.Context, call BatchCall) error {
+		_, err := Call(s, ctx, call.Method, call.Req, call.Resp, call.Config...)
+		return err
+	})
+	if failed := len(results.Failed()); failed > 0 {
+		span.SetAttributes(attribute.Int("app.batch.failed", failed))
+		span.SetStatus(codes.Error, "batch call failed")
+	}
+	return results
+}
+
+// callBatch runs calls with at most concurrency running at once.  Once ctx
+// is done, calls which have not started fail with the context error.
+func callBatch(ctx context.Context, calls []BatchCall, concurrency int, call func(context.Context, BatchCall) error) BatchResults {
+	results := make(BatchResults, len(calls))
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, c := range calls {
+		results[i].Method = c.Method
+		if ctx.Err() != nil {
+			results[i].Err = ctx.Err()
+			continue
+		}
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			results[i].Err = ctx.Err()
+			continue
+		}
+		wg.Add(1)
+		go func(i int, c BatchCall) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			if err := call(ctx, c); err != nil {
+				results[i].Err = err
+				return
+			}
+			results[i].Resp = c.Resp
+		}(i, c)
+	}
+	wg.Wait()
+	return results
+}
@@ -0,0 +1,84 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package oracle
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func TestCallBatch(t *testing.T) {
+	errFail := errors.New("fail")
+	var calls []BatchCall
+	for _, method := range []string{"a", "b", "fail", "c", "d"} {
+		calls = append(calls, BatchCall{
+			Method: method,
+			Req:    &structpb.Struct{},
+			Resp:   &structpb.Struct{},
+		})
+	}
+
+	var running, maxRunning int32
+	results := callBatch(context.Background(), calls, 2, func(ctx context.Context, call BatchCall) error {
+		n := atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+		for {
+			m := atomic.LoadInt32(&maxRunning)
+			if n <= m || atomic.CompareAndSwapInt32(&maxRunning, m, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		if call.Method == "fail" {
+			return errFail
+		}
+		call.Resp.(*structpb.Struct).Fields = map[string]*structpb.Value{
+			"method": structpb.NewStringValue(call.Method),
+		}
+		return nil
+	})
+
+	require.LessOrEqual(t, maxRunning, int32(2))
+	require.Len(t, results, 5)
+	for i, res := range results {
+		require.Equal(t, calls[i].Method, res.Method)
+		if res.Method == "fail" {
+			require.Nil(t, res.Resp)
+			continue
+		}
+		require.NoError(t, res.Err)
+		require.Equal(t, res.Method, res.Resp.(*structpb.Struct).Fields["method"].GetStringValue())
+	}
+	require.Len(t, results.Failed(), 1)
+	require.ErrorIs(t, results.Err(), errFail)
+}
+
+func TestCallBatchCancel(t *testing.T) {
+	calls := []BatchCall{
+		{Method: "a", Resp: &structpb.Struct{}},
+		{Method: "b", Resp: &structpb.Struct{}},
+		{Method: "c", Resp: &structpb.Struct{}},
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var started []string
+	results := callBatch(ctx, calls, 1, func(ctx context.Context, call BatchCall) error {
+		started = append(started, call.Method)
+		// The first call holds the only slot until the batch is cancelled.
+		cancel()
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	require.Equal(t, []string{"a"}, started)
+	for i, res := range results {
+		require.Equal(t, calls[i].Method, res.Method)
+		require.ErrorIs(t, res.Err, context.Canceled)
+		require.Nil(t, res.Resp)
+	}
+}
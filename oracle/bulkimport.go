@@ -0,0 +1,305 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package oracle
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	common "buf.build/gen/go/luthersystems/protos/protocolbuffers/go/common/v1"
+	"github.com/luthersystems/svc/svcerr"
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	// defaultBulkImportBatchSize is the default number of lines called
+	// before results are streamed back.
+	defaultBulkImportBatchSize = 100
+
+	// defaultBulkImportMaxLineBytes is the default maximum size of a line.
+	defaultBulkImportMaxLineBytes = 1 << 20
+
+	// Results of bulk import lines.
+	bulkImportOK      = "ok"
+	bulkImportInvalid = "invalid"
+	bulkImportFailed  = "failed"
+)
+
+var (
+	bulkImportLinesTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "bulk_import_lines_total",
+			Help: "How many bulk import lines were processed, partitioned by path and result.",
+		},
+		[]string{"path", "result"},
+	)
+	bulkImportsRunning = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "bulk_imports_running",
+			Help: "Bulk imports in progress, partitioned by path.",
+		},
+		[]string{"path"},
+	)
+)
+
+// BulkImportValidator validates a request decoded from a bulk import line
+// before it is sent to the phylum.  The error is reported as a business
+// exception for the line.
+type BulkImportValidator func(ctx context.Context, req proto.Message) error
+
+// BulkImportOption configures a bulk import endpoint.
+type BulkImportOption func(*bulkImport)
+
+// WithBulkImportConcurrency sets the maximum number of concurrent phylum
+// calls.  Defaults to 4.
+func WithBulkImportConcurrency(n int) BulkImportOption {
+	return func(b *bulkImport) {
+		if n > 0 {
+			b.concurrency = n
+		}
+	}
+}
+
+// WithBulkImportBatchSize sets the number of lines called concurrently
+// before their results are streamed back.  Defaults to 100.
+func WithBulkImportBatchSize(n int) BulkImportOption {
+	return func(b *bulkImport) {
+		if n > 0 {
+			b.batchSize = n
+		}
+	}
+}
+
+// WithBulkImportMaxLineBytes sets the maximum size of a line.  Longer lines
+// abort the import.  Defaults to 1MiB.
+func WithBulkImportMaxLineBytes(n int) BulkImportOption {
+	return func(b *bulkImport) {
+		if n > 0 {
+			b.maxLineBytes = n
+		}
+	}
+}
+
+// WithBulkImportValidator validates each decoded request with fn.
+func WithBulkImportValidator(fn BulkImportValidator) BulkImportOption {
+	return func(b *bulkImport) {
+		b.validate = fn
+	}
+}
+
+type bulkImport struct {
+	method       string
+	newReq       func() proto.Message
+	newResp      func() proto.Message
+	concurrency  int
+	batchSize    int
+	maxLineBytes int
+	validate     BulkImportValidator
+	// call makes a phylum call, overridden by tests.
+	call func(ctx context.Context, call BatchCall) error
+}
+
+// AddBulkImportPath serves a bulk import endpoint at path.  Clients POST
+// newline-delimited JSON, each line a request message created by newReq,
+// and each valid line is sent to the phylum method.  Calls are made in
+// batches with bounded concurrency, and a result line is streamed back for
+// each request line, in order:
+//
+//	{"line":1,"response":{...}}
+//	{"line":2,"exception":{"type":"BUSINESS","description":"..."}}
+//
+// followed by a summary line:
+//
+//	{"summary":{"total":2,"succeeded":1,"failed":1}}
+//
+// Lines which are not valid requests are reported without calling the
+// phylum.  Empty lines are skipped.
+func (c *Config) AddBulkImportPath(path string, method string, newReq func() proto.Message, newResp func() proto.Message, opts ...BulkImportOption) {
+	if c == nil {
+		return
+	}
+	b := &bulkImport{
+		method:       method,
+		newReq:       newReq,
+		newResp:      newResp,
+		concurrency:  defaultBatchConcurrency,
+		batchSize:    defaultBulkImportBatchSize,
+		maxLineBytes: defaultBulkImportMaxLineBytes,
+	}
+	for _, opt := range opts {
+		opt(b)
+	}
+	if c.bulkImports == nil {
+		c.bulkImports = make(map[string]*bulkImport)
+	}
+	c.bulkImports[path] = b
+}
+
+// bulkImportResult is the result of a bulk import line.
+type bulkImportResult struct {
+	line     int
+	resp     proto.Message
+	except   *common.Exception
+	category string
+}
+
+// bulkImportSummary is the final line of a bulk import response.
+type bulkImportSummary struct {
+	Total     int    `json:"total"`
+	Succeeded int    `json:"succeeded"`
+	Failed    int    `json:"failed"`
+	Error     string `json:"error,omitempty"`
+}
+
+// marshal renders the result line.
+func (res *bulkImportResult) marshal() ([]byte, error) {
+	line := struct {
+		Line      int             `json:"line"`
+		Response  json.RawMessage `json:"response,omitempty"`
+		Exception json.RawMessage `json:"exception,omitempty"`
+	}{Line: res.line}
+	var err error
+	if res.except != nil {
+		line.Exception, err = protojson.Marshal(res.except)
+	} else {
+		line.Response, err = protojson.Marshal(res.resp)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(line)
+}
+
+func (orc *Oracle) bulkImportHandler(path string, b *bulkImport) http.Handler {
+	call := b.call
+	if call == nil {
+		call = func(ctx context.Context, c BatchCall) error {
+			_, err := Call(orc, ctx, c.Method, c.Req, c.Resp, c.Config...)
+			return err
+		}
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := orc.httpMetadataContext(r)
+		if r.Method != http.MethodPost {
+			ex := svcerr.BusinessException(ctx, "method not allowed")
+			if err := writeExceptionHTTP(w, http.StatusMethodNotAllowed, ex); err != nil {
+				orc.log(ctx).WithError(err).Errorf("bulk import response error")
+			}
+			return
+		}
+		bulkImportsRunning.WithLabelValues(path).Inc()
+		defer bulkImportsRunning.WithLabelValues(path).Dec()
+
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.Header().Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+		flusher, _ := w.(http.Flusher)
+		var summary bulkImportSummary
+		writeLine := func(b []byte) {
+			if _, err := w.Write(append(b, '\n')); err != nil {
+				orc.log(ctx).WithError(err).Debugf("bulk import write")
+			}
+		}
+
+		// Results are written in order once the lines of their batch are
+		// called.
+		var pending, batch []*bulkImportResult
+		flush := func() {
+			calls := make([]BatchCall, len(batch))
+			for i, res := range batch {
+				calls[i] = BatchCall{Method: b.method, Req: res.resp, Resp: b.newResp()}
+			}
+			for i, result := range callBatch(ctx, calls, b.concurrency, call) {
+				res := batch[i]
+				if result.Err != nil {
+					res.except, res.category = bulkImportException(ctx, result.Err), bulkImportFailed
+					continue
+				}
+				res.resp, res.category = result.Resp, bulkImportOK
+			}
+			for _, res := range pending {
+				data, err := res.marshal()
+				if err != nil {
+					orc.log(ctx).WithError(err).Errorf("bulk import marshal")
+					res.except, res.category = svcerr.UnexpectedException(ctx, "Internal server error"), bulkImportFailed
+					data, _ = res.marshal()
+				}
+				summary.Total++
+				if res.category == bulkImportOK {
+					summary.Succeeded++
+				} else {
+					summary.Failed++
+				}
+				bulkImportLinesTotal.WithLabelValues(path, res.category).Inc()
+				writeLine(data)
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+			pending, batch = nil, nil
+		}
+
+		scanner := bufio.NewScanner(r.Body)
+		// The scanner allows tokens up to the larger of the buffer capacity
+		// and the maximum, so the buffer must not exceed the maximum.
+		scanner.Buffer(make([]byte, 0, min(64*1024, b.maxLineBytes)), b.maxLineBytes)
+		line := 0
+		for ctx.Err() == nil && scanner.Scan() {
+			line++
+			data := scanner.Bytes()
+			if len(data) == 0 {
+				continue
+			}
+			res := &bulkImportResult{line: line, resp: b.newReq()}
+			pending = append(pending, res)
+			err := protojson.Unmarshal(data, res.resp)
+			if err != nil {
+				err = fmt.Errorf("invalid request: %w", err)
+			} else if b.validate != nil {
+				err = b.validate(ctx, res.resp)
+			}
+			if err != nil {
+				res.except, res.category = invalidLineException(ctx, err), bulkImportInvalid
+			} else {
+				batch = append(batch, res)
+			}
+			// Pending results are bounded even if most lines are invalid.
+			if len(batch) >= b.batchSize || len(pending) >= b.batchSize {
+				flush()
+			}
+		}
+		flush()
+		if err := scanner.Err(); err != nil {
+			orc.log(ctx).WithError(err).Warnf("bulk import read")
+			summary.Error = fmt.Sprintf("line %d: %v", line+1, err)
+		}
+		data, err := json.Marshal(struct {
+			Summary bulkImportSummary `json:"summary"`
+		}{summary})
+		if err != nil {
+			orc.log(ctx).WithError(err).Errorf("bulk import marshal")
+			return
+		}
+		writeLine(data)
+	})
+}
+
+// invalidLineException returns the exception reported for a line which is
+// not a valid request.
+func invalidLineException(ctx context.Context, err error) *common.Exception {
+	return svcerr.BusinessException(ctx, err.Error())
+}
+
+// bulkImportException returns the exception reported for a failed phylum
+// call.  Errors without an exception are not presented to clients.
+func bulkImportException(ctx context.Context, err error) *common.Exception {
+	if ex := svcerr.DownstreamException(err); ex != nil {
+		return ex
+	}
+	return svcerr.UnexpectedException(ctx, "Internal server error")
+}
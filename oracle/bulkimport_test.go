@@ -0,0 +1,156 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package oracle
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	healthcheck "buf.build/gen/go/luthersystems/protos/protocolbuffers/go/healthcheck/v1"
+	"github.com/luthersystems/svc/svcerr"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestBulkImport(t *testing.T) {
+	cfg := DefaultConfig()
+	newReport := func() proto.Message { return &healthcheck.HealthCheckReport{} }
+	cfg.AddBulkImportPath("/v1/import", "import_report", newReport, newReport,
+		WithBulkImportBatchSize(2),
+		WithBulkImportValidator(func(ctx context.Context, req proto.Message) error {
+			if req.(*healthcheck.HealthCheckReport).GetServiceName() == "" {
+				return fmt.Errorf("missing service name")
+			}
+			return nil
+		}))
+	b := cfg.bulkImports["/v1/import"]
+	require.NotNil(t, b)
+	var calls int32
+	b.call = func(ctx context.Context, c BatchCall) error {
+		atomic.AddInt32(&calls, 1)
+		req := c.Req.(*healthcheck.HealthCheckReport)
+		if req.GetStatus() == "DOWN" {
+			return svcerr.WrapException(svcerr.BusinessException(ctx, "service down"), nil)
+		}
+		if req.GetStatus() == "" {
+			return errors.New("connection refused")
+		}
+		c.Resp.(*healthcheck.HealthCheckReport).ServiceName = req.GetServiceName()
+		c.Resp.(*healthcheck.HealthCheckReport).Status = "IMPORTED"
+		return nil
+	}
+	orc := newTestOracle(t, cfg)
+	handler := orc.bulkImportHandler("/v1/import", b)
+
+	body := strings.Join([]string{
+		`{"service_name":"a","status":"UP"}`,
+		`{"service_name":`,
+		``,
+		`{"service_name":"b","status":"DOWN"}`,
+		`{"status":"UP"}`,
+		`{"service_name":"c"}`,
+		`{"service_name":"d","status":"UP"}`,
+	}, "\n")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/v1/import", strings.NewReader(body)))
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Equal(t, "application/x-ndjson", rr.Header().Get("Content-Type"))
+	require.Equal(t, int32(4), atomic.LoadInt32(&calls))
+
+	type result struct {
+		Line     int `json:"line"`
+		Response *struct {
+			ServiceName string `json:"serviceName"`
+			Status      string `json:"status"`
+		} `json:"response"`
+		Exception *struct {
+			Type        string `json:"type"`
+			Description string `json:"description"`
+		} `json:"exception"`
+		Summary *bulkImportSummary `json:"summary"`
+	}
+	lines := strings.Split(strings.TrimSpace(rr.Body.String()), "\n")
+	require.Len(t, lines, 7)
+	var results []result
+	for _, line := range lines {
+		var r result
+		require.NoError(t, json.Unmarshal([]byte(line), &r), line)
+		results = append(results, r)
+	}
+
+	require.Equal(t, 1, results[0].Line)
+	require.NotNil(t, results[0].Response)
+	require.Equal(t, "a", results[0].Response.ServiceName)
+	require.Equal(t, "IMPORTED", results[0].Response.Status)
+
+	require.Equal(t, 2, results[1].Line)
+	require.Equal(t, "BUSINESS", results[1].Exception.Type)
+	require.Contains(t, results[1].Exception.Description, "invalid request")
+
+	require.Equal(t, 4, results[2].Line)
+	require.Equal(t, "service down", results[2].Exception.Description)
+
+	require.Equal(t, 5, results[3].Line)
+	require.Equal(t, "missing service name", results[3].Exception.Description)
+
+	// Errors without an exception are not revealed.
+	require.Equal(t, 6, results[4].Line)
+	require.Equal(t, "UNEXPECTED", results[4].Exception.Type)
+	require.NotContains(t, results[4].Exception.Description, "connection refused")
+
+	require.Equal(t, 7, results[5].Line)
+	require.Equal(t, "d", results[5].Response.ServiceName)
+
+	require.Equal(t, &bulkImportSummary{Total: 6, Succeeded: 2, Failed: 4}, results[6].Summary)
+}
+
+func TestBulkImportInvalidLinesFlushed(t *testing.T) {
+	cfg := DefaultConfig()
+	newReport := func() proto.Message { return &healthcheck.HealthCheckReport{} }
+	cfg.AddBulkImportPath("/v1/import", "import_report", newReport, newReport, WithBulkImportBatchSize(2))
+	b := cfg.bulkImports["/v1/import"]
+	rr := httptest.NewRecorder()
+	b.call = func(ctx context.Context, c BatchCall) error {
+		// The results of earlier invalid lines were written before the
+		// batch was called.
+		require.Equal(t, 4, strings.Count(rr.Body.String(), "\n"))
+		return nil
+	}
+	orc := newTestOracle(t, cfg)
+
+	body := strings.Repeat("{\n", 4) + `{"service_name":"a"}`
+	orc.bulkImportHandler("/v1/import", b).ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/v1/import", strings.NewReader(body)))
+	require.Contains(t, rr.Body.String(), `"summary":{"total":5,"succeeded":1,"failed":4}`)
+}
+
+func TestBulkImportLineTooLong(t *testing.T) {
+	cfg := DefaultConfig()
+	newReport := func() proto.Message { return &healthcheck.HealthCheckReport{} }
+	cfg.AddBulkImportPath("/v1/import", "import_report", newReport, newReport, WithBulkImportMaxLineBytes(64))
+	b := cfg.bulkImports["/v1/import"]
+	b.call = func(ctx context.Context, c BatchCall) error { return nil }
+	orc := newTestOracle(t, cfg)
+
+	body := `{"service_name":"a"}` + "\n" + `{"service_name":"` + strings.Repeat("x", 100) + `"}`
+	rr := httptest.NewRecorder()
+	orc.bulkImportHandler("/v1/import", b).ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/v1/import", strings.NewReader(body)))
+	lines := strings.Split(strings.TrimSpace(rr.Body.String()), "\n")
+	require.Len(t, lines, 2)
+	var last struct {
+		Summary bulkImportSummary `json:"summary"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(lines[1]), &last))
+	require.Equal(t, 1, last.Summary.Succeeded)
+	require.Contains(t, last.Summary.Error, "line 2")
+
+	rr = httptest.NewRecorder()
+	orc.bulkImportHandler("/v1/import", b).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/v1/import", nil))
+	require.Equal(t, http.StatusMethodNotAllowed, rr.Code)
+}
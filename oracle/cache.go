@@ -0,0 +1,172 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package oracle
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CachePolicy configures the caching headers of successful GET responses.
+type CachePolicy struct {
+	// MaxAge is the time a response may be cached by clients and shared
+	// caches.  Expires is set accordingly.
+	MaxAge time.Duration `yaml:"max-age"`
+	// SharedMaxAge overrides MaxAge for shared caches, such as CDNs.
+	SharedMaxAge time.Duration `yaml:"shared-max-age"`
+	// StaleWhileRevalidate is the time a stale response may be served
+	// while it is revalidated in the background.
+	StaleWhileRevalidate time.Duration `yaml:"stale-while-revalidate"`
+	// Public allows shared caches to store responses to authenticated
+	// requests.  Otherwise responses are private.
+	Public bool `yaml:"public"`
+	// Immutable indicates the response will not change while fresh.
+	Immutable bool `yaml:"immutable"`
+	// NoStore prevents responses from being cached.
+	NoStore bool `yaml:"no-store"`
+}
+
+func (p CachePolicy) valid() error {
+	if p.MaxAge < 0 || p.SharedMaxAge < 0 || p.StaleWhileRevalidate < 0 {
+		return fmt.Errorf("negative duration")
+	}
+	if p.NoStore && (p.MaxAge > 0 || p.SharedMaxAge > 0 || p.StaleWhileRevalidate > 0 || p.Public || p.Immutable) {
+		return fmt.Errorf("no-store cannot be combined with other directives")
+	}
+	return nil
+}
+
+// cacheControl returns the Cache-Control header value of the policy.
+func (p CachePolicy) cacheControl() string {
+	if p.NoStore {
+		return "no-store"
+	}
+	directives := []string{"private"}
+	if p.Public {
+		directives[0] = "public"
+	}
+	directives = append(directives, "max-age="+seconds(p.MaxAge))
+	if p.SharedMaxAge > 0 {
+		directives = append(directives, "s-maxage="+seconds(p.SharedMaxAge))
+	}
+	if p.StaleWhileRevalidate > 0 {
+		directives = append(directives, "stale-while-revalidate="+seconds(p.StaleWhileRevalidate))
+	}
+	if p.Immutable {
+		directives = append(directives, "immutable")
+	}
+	return strings.Join(directives, ", ")
+}
+
+func seconds(d time.Duration) string {
+	return strconv.FormatInt(int64(d/time.Second), 10)
+}
+
+// CacheControl sets caching policies for GET routes served by the gateway
+// and path overrides, keyed by route pattern.  Patterns use path.Match
+// syntax, e.g. "/v1/products/*".  When several patterns match a request
+// path the longest pattern is used.  Responses which already set
+// Cache-Control are not modified.
+func (c *Config) CacheControl(policies map[string]CachePolicy) {
+	if c == nil {
+		return
+	}
+	if c.CachePolicies == nil {
+		c.CachePolicies = make(map[string]CachePolicy)
+	}
+	for pattern, p := range policies {
+		c.CachePolicies[pattern] = p
+	}
+}
+
+// validCachePolicies validates the cache policy configuration.
+func (c *Config) validCachePolicies() error {
+	for pattern, p := range c.CachePolicies {
+		if !strings.HasPrefix(pattern, "/") {
+			return fmt.Errorf("cache policy %s: pattern must be absolute", pattern)
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("cache policy %s: %w", pattern, err)
+		}
+		if err := p.valid(); err != nil {
+			return fmt.Errorf("cache policy %s: %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+// cachePolicy returns the policy of the longest pattern matching p.
+func (orc *Oracle) cachePolicy(p string) (CachePolicy, bool) {
+	var policy CachePolicy
+	best := -1
+	for pattern, pol := range orc.cfg.CachePolicies {
+		if len(pattern) <= best {
+			continue
+		}
+		if ok, _ := path.Match(pattern, p); ok {
+			policy, best = pol, len(pattern)
+		}
+	}
+	return policy, best >= 0
+}
+
+// cacheMiddleware sets caching headers on successful GET responses to routes
+// with a configured cache policy.
+func (orc *Oracle) cacheMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			next.ServeHTTP(w, r)
+			return
+		}
+		policy, ok := orc.cachePolicy(r.URL.Path)
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		next.ServeHTTP(&cacheHeaderWriter{ResponseWriter: w, policy: policy, now: time.Now}, r)
+	})
+}
+
+// cacheHeaderWriter sets caching headers on 200 and 304 responses, unless
+// the handler set its own.
+type cacheHeaderWriter struct {
+	http.ResponseWriter
+	policy      CachePolicy
+	now         func() time.Time
+	wroteHeader bool
+}
+
+func (w *cacheHeaderWriter) WriteHeader(code int) {
+	if !w.wroteHeader && (code == http.StatusOK || code == http.StatusNotModified) {
+		h := w.Header()
+		if h.Get("Cache-Control") == "" {
+			h.Set("Cache-Control", w.policy.cacheControl())
+			if !w.policy.NoStore {
+				h.Set("Expires", w.now().Add(w.policy.MaxAge).UTC().Format(http.TimeFormat))
+			}
+		}
+	}
+	w.wroteHeader = true
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *cacheHeaderWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush implements http.Flusher.
+func (w *cacheHeaderWriter) Flush() {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
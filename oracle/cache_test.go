@@ -0,0 +1,69 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package oracle
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCacheControl(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.CacheControl(map[string]CachePolicy{
+		"/v1/products/*":        {MaxAge: time.Minute, Public: true},
+		"/v1/products/featured": {MaxAge: 10 * time.Second, SharedMaxAge: time.Minute, StaleWhileRevalidate: 30 * time.Second},
+		"/v1/account":           {NoStore: true},
+	})
+	require.NoError(t, cfg.validCachePolicies())
+	orc := newTestOracle(t, cfg)
+
+	h := orc.cacheMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/products/missing":
+			w.WriteHeader(http.StatusNotFound)
+		case "/v1/products/custom":
+			w.Header().Set("Cache-Control", "no-cache")
+		}
+		_, _ = w.Write([]byte("{}"))
+	}))
+	for _, test := range []struct {
+		method string
+		path   string
+		want   string
+	}{
+		{http.MethodGet, "/v1/products/1", "public, max-age=60"},
+		{http.MethodGet, "/v1/products/featured", "private, max-age=10, s-maxage=60, stale-while-revalidate=30"},
+		{http.MethodGet, "/v1/account", "no-store"},
+		{http.MethodGet, "/v1/products/missing", ""},
+		{http.MethodGet, "/v1/products/custom", "no-cache"},
+		{http.MethodGet, "/v1/orders", ""},
+		{http.MethodPost, "/v1/products/1", ""},
+	} {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(test.method, test.path, nil))
+		require.Equal(t, test.want, w.Header().Get("Cache-Control"), test.path)
+	}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/products/1", nil))
+	expires, err := http.ParseTime(w.Header().Get("Expires"))
+	require.NoError(t, err)
+	require.WithinDuration(t, time.Now().Add(time.Minute), expires, 2*time.Second)
+}
+
+func TestCachePolicyInvalid(t *testing.T) {
+	for pattern, p := range map[string]CachePolicy{
+		"v1/relative": {MaxAge: time.Minute},
+		"/v1/[":       {MaxAge: time.Minute},
+		"/v1/neg":     {MaxAge: -time.Second},
+		"/v1/nostore": {NoStore: true, MaxAge: time.Minute},
+	} {
+		cfg := DefaultConfig()
+		cfg.CacheControl(map[string]CachePolicy{pattern: p})
+		require.Error(t, cfg.validCachePolicies(), pattern)
+	}
+}
@@ -0,0 +1,142 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package oracle
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/luthersystems/svc/svcerr"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ClaimsSchema describes claims required of every user.  Requests whose
+// claims do not match fail GetClaims with a SecurityException, so handlers
+// need not repeat audience and issuer checks.
+type ClaimsSchema struct {
+	// Audiences are the accepted audiences.  The "aud" claim must contain
+	// at least one of them.
+	Audiences []string `yaml:"audiences"`
+	// Issuers are the accepted values of the "iss" claim.
+	Issuers []string `yaml:"issuers"`
+	// RequiredClaims are claims which must be present and non-empty.
+	RequiredClaims []string `yaml:"required-claims"`
+}
+
+func (s ClaimsSchema) valid() error {
+	for _, v := range s.Audiences {
+		if v == "" {
+			return fmt.Errorf("claims schema: empty audience")
+		}
+	}
+	for _, v := range s.Issuers {
+		if v == "" {
+			return fmt.Errorf("claims schema: empty issuer")
+		}
+	}
+	for _, v := range s.RequiredClaims {
+		if v == "" {
+			return fmt.Errorf("claims schema: empty required claim")
+		}
+	}
+	return nil
+}
+
+// claimStrings returns the string values of a claim which may be a string
+// or a list of strings, such as "aud".
+func claimStrings(claim interface{}) []string {
+	switch v := claim.(type) {
+	case string:
+		return []string{v}
+	case []string:
+		return v
+	case []interface{}:
+		var vals []string
+		for _, e := range v {
+			if s, ok := e.(string); ok {
+				vals = append(vals, s)
+			}
+		}
+		return vals
+	}
+	return nil
+}
+
+func containsAny(vals []string, accepted []string) bool {
+	for _, v := range vals {
+		for _, a := range accepted {
+			if v == a {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// check returns an error describing the first mismatch of claims.
+func (s ClaimsSchema) check(claims map[string]interface{}) error {
+	if len(s.Audiences) > 0 && !containsAny(claimStrings(claims["aud"]), s.Audiences) {
+		return fmt.Errorf("invalid audience")
+	}
+	if len(s.Issuers) > 0 {
+		iss, _ := claims["iss"].(string)
+		if !containsAny([]string{iss}, s.Issuers) {
+			return fmt.Errorf("invalid issuer: %q", iss)
+		}
+	}
+	for _, name := range s.RequiredClaims {
+		if v, ok := claims[name]; !ok || v == nil || v == "" {
+			return fmt.Errorf("missing claim: %s", name)
+		}
+	}
+	return nil
+}
+
+// verifiedClaims gets the user claims and checks them against the schema.
+// API key claims are synthetic and are not checked.
+// Mismatches are logged and returned to callers as a SecurityException,
+// without the reason.
+func (orc *Oracle) verifiedClaims(ctx context.Context) (map[string]interface{}, error) {
+	if key, ok := orc.requestAPIKey(ctx); ok {
+		return orc.apiKeyClaims(ctx, key)
+	}
+	claims, err := orc.cfg.claimsGetter(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := orc.cfg.ClaimsSchema.check(claims); err != nil {
+		orc.log(ctx).WithError(err).Infof("claims schema mismatch")
+		st, detailsErr := status.New(codes.PermissionDenied, "unauthorized").
+			WithDetails(svcerr.SecurityException(ctx, "unauthorized"))
+		if detailsErr != nil {
+			return nil, fmt.Errorf("claims: %w", err)
+		}
+		return nil, st.Err()
+	}
+	return claims, nil
+}
+
+type claimsCacheKey struct{}
+
+// claimsCache holds the claims of a single request.
+type claimsCache struct {
+	once   sync.Once
+	claims map[string]interface{}
+	err    error
+}
+
+// withClaimsCache returns a context in which GetClaims verifies claims at
+// most once.
+func withClaimsCache(ctx context.Context) context.Context {
+	return context.WithValue(ctx, claimsCacheKey{}, &claimsCache{})
+}
+
+// claimsCacheInterceptor caches claims for the duration of each request.
+func (orc *Oracle) claimsCacheInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		return handler(withClaimsCache(ctx), req)
+	}
+}
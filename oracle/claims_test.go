@@ -0,0 +1,99 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package oracle
+
+import (
+	"context"
+	"testing"
+
+	common "buf.build/gen/go/luthersystems/protos/protocolbuffers/go/common/v1"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestGetClaimsCached(t *testing.T) {
+	cfg := DefaultConfig()
+	calls := 0
+	cfg.SetClaimsGetter(func(ctx context.Context) (map[string]interface{}, error) {
+		calls++
+		return map[string]interface{}{"sub": "alice"}, nil
+	})
+	orc := newTestOracle(t, cfg)
+
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		for i := 0; i < 3; i++ {
+			claims, err := orc.GetClaims(ctx)
+			require.NoError(t, err)
+			require.Equal(t, "alice", claims["sub"])
+		}
+		return nil, nil
+	}
+	_, err := orc.claimsCacheInterceptor()(context.Background(), nil, &grpc.UnaryServerInfo{}, handler)
+	require.NoError(t, err)
+	require.Equal(t, 1, calls)
+
+	// Without a request cache claims are obtained on every call.
+	_, err = orc.GetClaims(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, 2, calls)
+}
+
+func TestClaimsSchema(t *testing.T) {
+	schema := ClaimsSchema{
+		Audiences:      []string{"app"},
+		Issuers:        []string{"https://idp.example.com"},
+		RequiredClaims: []string{"org_id"},
+	}
+	valid := map[string]interface{}{
+		"aud":    []interface{}{"other", "app"},
+		"iss":    "https://idp.example.com",
+		"org_id": "org1",
+	}
+	for _, tc := range []struct {
+		name    string
+		mutate  func(map[string]interface{})
+		wantErr bool
+	}{
+		{"valid", func(map[string]interface{}) {}, false},
+		{"string audience", func(c map[string]interface{}) { c["aud"] = "app" }, false},
+		{"wrong audience", func(c map[string]interface{}) { c["aud"] = "other" }, true},
+		{"missing audience", func(c map[string]interface{}) { delete(c, "aud") }, true},
+		{"wrong issuer", func(c map[string]interface{}) { c["iss"] = "https://evil.example.com" }, true},
+		{"missing claim", func(c map[string]interface{}) { delete(c, "org_id") }, true},
+		{"empty claim", func(c map[string]interface{}) { c["org_id"] = "" }, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			claims := make(map[string]interface{})
+			for k, v := range valid {
+				claims[k] = v
+			}
+			tc.mutate(claims)
+			cfg := DefaultConfig()
+			cfg.ClaimsSchema = schema
+			cfg.SetClaimsGetter(func(ctx context.Context) (map[string]interface{}, error) {
+				return claims, nil
+			})
+			orc := newTestOracle(t, cfg)
+			_, err := orc.GetClaims(context.Background())
+			if !tc.wantErr {
+				require.NoError(t, err)
+				return
+			}
+			st, ok := status.FromError(err)
+			require.True(t, ok)
+			require.Equal(t, codes.PermissionDenied, st.Code())
+			require.Len(t, st.Details(), 1)
+			require.Equal(t, common.Exception_SECURITY_VIOLATION, st.Details()[0].(*common.Exception).GetType())
+		})
+	}
+}
+
+func TestClaimsSchemaInvalid(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.ClaimsSchema.RequiredClaims = []string{"org_id"}
+	require.NoError(t, cfg.Valid())
+	cfg.ClaimsSchema.RequiredClaims = []string{""}
+	require.Error(t, cfg.Valid())
+}
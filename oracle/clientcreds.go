@@ -0,0 +1,240 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package oracle
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc/credentials"
+)
+
+const (
+	defaultTokenTimeout = 10 * time.Second
+	// tokenExpiryDelta is the time before expiry at which tokens are
+	// refreshed.
+	tokenExpiryDelta = 30 * time.Second
+)
+
+var clientCredentialsRefreshFailures = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "client_credentials_refresh_failures_total",
+		Help: "How many client credentials token requests failed, partitioned by token URL.",
+	},
+	[]string{"token_url"},
+)
+
+// ClientCredentials configures acquisition of OAuth2 client credentials
+// tokens, used to authenticate outbound calls made by the oracle.
+type ClientCredentials struct {
+	// TokenURL is the token endpoint of the IDP.  Client credentials are
+	// disabled if it is empty.
+	TokenURL string `yaml:"token-url"`
+	// ClientID identifies the oracle to the IDP.
+	ClientID string `yaml:"client-id"`
+	// ClientSecret authenticates the oracle to the IDP.
+	ClientSecret string `yaml:"client-secret" secret:"true"`
+	// Scopes are the requested scopes.
+	Scopes []string `yaml:"scopes"`
+	// Audience is the requested audience, for IDPs which require one.
+	Audience string `yaml:"audience"`
+}
+
+func (cc ClientCredentials) enabled() bool {
+	return cc.TokenURL != ""
+}
+
+func (cc ClientCredentials) valid() error {
+	if !cc.enabled() {
+		return nil
+	}
+	u, err := url.Parse(cc.TokenURL)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return fmt.Errorf("client credentials: invalid token url %q", cc.TokenURL)
+	}
+	if cc.ClientID == "" {
+		return fmt.Errorf("client credentials: missing client id")
+	}
+	if cc.ClientSecret == "" {
+		return fmt.Errorf("client credentials: missing client secret")
+	}
+	return nil
+}
+
+// TokenSource obtains client credentials tokens, caching them until shortly
+// before they expire.  It is safe for concurrent use.
+type TokenSource struct {
+	cc     ClientCredentials
+	client *http.Client
+	now    func() time.Time
+
+	mut     sync.Mutex
+	token   string
+	expires time.Time
+}
+
+// TokenSourceOption configures a TokenSource.
+type TokenSourceOption func(*TokenSource)
+
+// WithTokenHTTPClient sets the client used to call the token endpoint.
+func WithTokenHTTPClient(c *http.Client) TokenSourceOption {
+	return func(s *TokenSource) {
+		s.client = c
+	}
+}
+
+// NewTokenSource returns a TokenSource for the client credentials.
+func NewTokenSource(cc ClientCredentials, opts ...TokenSourceOption) *TokenSource {
+	s := &TokenSource{
+		cc:     cc,
+		client: &http.Client{Timeout: defaultTokenTimeout},
+		now:    time.Now,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// tokenResponse is an OAuth2 token endpoint response.
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int64  `json:"expires_in"`
+}
+
+// Token returns a valid access token, requesting a new token if the cached
+// token is about to expire.  If a refresh fails the cached token is returned
+// while it remains valid.
+func (s *TokenSource) Token(ctx context.Context) (string, error) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	now := s.now()
+	if s.token != "" && now.Add(tokenExpiryDelta).Before(s.expires) {
+		return s.token, nil
+	}
+	token, expires, err := s.fetch(ctx, now)
+	if err != nil {
+		clientCredentialsRefreshFailures.WithLabelValues(s.cc.TokenURL).Inc()
+		if s.token != "" && now.Before(s.expires) {
+			return s.token, nil
+		}
+		return "", err
+	}
+	s.token, s.expires = token, expires
+	return s.token, nil
+}
+
+func (s *TokenSource) fetch(ctx context.Context, now time.Time) (string, time.Time, error) {
+	form := url.Values{"grant_type": {"client_credentials"}}
+	if len(s.cc.Scopes) > 0 {
+		form.Set("scope", strings.Join(s.cc.Scopes, " "))
+	}
+	if s.cc.Audience != "" {
+		form.Set("audience", s.cc.Audience)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.cc.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("client credentials: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(s.cc.ClientID), url.QueryEscape(s.cc.ClientSecret))
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("client credentials: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("client credentials: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", time.Time{}, fmt.Errorf("client credentials: unexpected status: %d", resp.StatusCode)
+	}
+	var tr tokenResponse
+	if err := json.Unmarshal(body, &tr); err != nil {
+		return "", time.Time{}, fmt.Errorf("client credentials: %w", err)
+	}
+	if tr.AccessToken == "" {
+		return "", time.Time{}, fmt.Errorf("client credentials: missing access token")
+	}
+	if tr.TokenType != "" && !strings.EqualFold(tr.TokenType, "bearer") {
+		return "", time.Time{}, fmt.Errorf("client credentials: unsupported token type %q", tr.TokenType)
+	}
+	return tr.AccessToken, now.Add(time.Duration(tr.ExpiresIn) * time.Second), nil
+}
+
+// authorize sets the Authorization header of an outbound request.
+func (s *TokenSource) authorize(req *http.Request) error {
+	token, err := s.Token(req.Context())
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	return nil
+}
+
+// Transport returns an http.RoundTripper which authorizes requests with a
+// bearer token before sending them with base.  A nil base uses
+// http.DefaultTransport.
+func (s *TokenSource) Transport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &tokenTransport{source: s, base: base}
+}
+
+type tokenTransport struct {
+	source *TokenSource
+	base   http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *tokenTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// RoundTrippers must not modify the request.
+	req = req.Clone(req.Context())
+	if err := t.source.authorize(req); err != nil {
+		return nil, err
+	}
+	return t.base.RoundTrip(req)
+}
+
+// PerRPCCredentials returns grpc credentials which send a bearer token as
+// authorization metadata, e.g. with grpc.WithPerRPCCredentials.
+func (s *TokenSource) PerRPCCredentials() credentials.PerRPCCredentials {
+	return tokenCredentials{source: s}
+}
+
+type tokenCredentials struct {
+	source *TokenSource
+}
+
+// GetRequestMetadata implements credentials.PerRPCCredentials.
+func (c tokenCredentials) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	token, err := c.source.Token(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]string{"authorization": "Bearer " + token}, nil
+}
+
+// RequireTransportSecurity implements credentials.PerRPCCredentials.
+func (c tokenCredentials) RequireTransportSecurity() bool {
+	return true
+}
+
+// ClientCredentials returns the token source of the configured client
+// credentials, for authenticating calls to partner APIs, or nil if client
+// credentials are not configured.
+func (orc *Oracle) ClientCredentials() *TokenSource {
+	return orc.clientCredentials
+}
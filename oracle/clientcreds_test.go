@@ -0,0 +1,125 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package oracle
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+func tokenServer(t *testing.T, fail *atomic.Bool) (*httptest.Server, *atomic.Int32) {
+	var issued atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fail.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		id, secret, ok := r.BasicAuth()
+		require.True(t, ok)
+		require.Equal(t, "oracle", id)
+		require.Equal(t, "s3cret", secret)
+		require.NoError(t, r.ParseForm())
+		require.Equal(t, "client_credentials", r.PostForm.Get("grant_type"))
+		require.Equal(t, "read write", r.PostForm.Get("scope"))
+		n := issued.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"access_token":"token-%d","token_type":"Bearer","expires_in":60}`, n)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &issued
+}
+
+func TestTokenSource(t *testing.T) {
+	var fail atomic.Bool
+	srv, issued := tokenServer(t, &fail)
+	cc := ClientCredentials{TokenURL: srv.URL, ClientID: "oracle", ClientSecret: "s3cret", Scopes: []string{"read", "write"}}
+	require.NoError(t, cc.valid())
+	s := NewTokenSource(cc)
+	now := time.Now()
+	s.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	token, err := s.Token(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "token-1", token)
+	token, err = s.Token(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "token-1", token)
+	require.EqualValues(t, 1, issued.Load())
+
+	// Tokens are refreshed shortly before they expire.
+	now = now.Add(45 * time.Second)
+	token, err = s.Token(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "token-2", token)
+
+	// Failed refreshes fall back to the cached token while it is valid.
+	failures := testutil.ToFloat64(clientCredentialsRefreshFailures.WithLabelValues(srv.URL))
+	fail.Store(true)
+	now = now.Add(45 * time.Second)
+	token, err = s.Token(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "token-2", token)
+	now = now.Add(time.Minute)
+	_, err = s.Token(ctx)
+	require.Error(t, err)
+	require.Equal(t, failures+2, testutil.ToFloat64(clientCredentialsRefreshFailures.WithLabelValues(srv.URL)))
+}
+
+func TestTokenSourceTransport(t *testing.T) {
+	var fail atomic.Bool
+	tokens, _ := tokenServer(t, &fail)
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "Bearer token-1", r.Header.Get("Authorization"))
+	}))
+	defer api.Close()
+
+	s := NewTokenSource(ClientCredentials{TokenURL: tokens.URL, ClientID: "oracle", ClientSecret: "s3cret", Scopes: []string{"read", "write"}})
+	client := &http.Client{Transport: s.Transport(nil)}
+	req, err := http.NewRequest(http.MethodGet, api.URL, nil)
+	require.NoError(t, err)
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Empty(t, req.Header.Get("Authorization"))
+
+	md, err := s.PerRPCCredentials().GetRequestMetadata(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, "Bearer token-1", md["authorization"])
+}
+
+func TestWebhookClientCredentials(t *testing.T) {
+	var fail atomic.Bool
+	tokens, _ := tokenServer(t, &fail)
+	received := make(chan string, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- r.Header.Get("Authorization")
+	}))
+	defer srv.Close()
+
+	cfg := DefaultConfig()
+	cfg.Webhooks = []WebhookEndpoint{{URL: srv.URL, Secret: "secret", ClientCredentials: true}}
+	require.Error(t, cfg.validWebhooks())
+	cfg.ClientCredentials = ClientCredentials{TokenURL: tokens.URL, ClientID: "oracle", ClientSecret: "s3cret", Scopes: []string{"read", "write"}}
+	require.NoError(t, cfg.validWebhooks())
+
+	d := newWebhookDispatcher(cfg, logrus.NewEntry(logrus.New()), NewTokenSource(cfg.ClientCredentials))
+	defer d.close()
+	require.NoError(t, d.enqueue("claim.updated", map[string]string{"id": "1"}))
+	select {
+	case auth := <-received:
+		require.Equal(t, "Bearer token-1", auth)
+	case <-time.After(5 * time.Second):
+		t.Fatal("webhook not delivered")
+	}
+}
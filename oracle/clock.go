@@ -0,0 +1,74 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package oracle
+
+import (
+	"crypto/rand"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	mathrand "math/rand"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Now returns the current time.  Test oracles created WithFixedClock return
+// the fixed time instead, so handlers which timestamp data with Now produce
+// reproducible responses.
+func (orc *Oracle) Now() time.Time {
+	if orc.clock != nil {
+		return orc.clock()
+	}
+	return time.Now()
+}
+
+// NewID returns a new random UUID.  Test oracles created
+// WithDeterministicIDs return a reproducible sequence of UUIDs instead.
+func (orc *Oracle) NewID() string {
+	if orc.ids != nil {
+		return orc.ids.next()
+	}
+	return uuid.New().String()
+}
+
+// timestamp returns the current time formatted for health reports.
+func (orc *Oracle) timestamp() string {
+	return orc.Now().Format(timestampFormat)
+}
+
+// seededIDs generates a reproducible sequence of UUIDs.
+type seededIDs struct {
+	mut sync.Mutex
+	r   io.Reader
+}
+
+func newSeededIDs(seed int64) *seededIDs {
+	return &seededIDs{r: mathrand.New(mathrand.NewSource(seed))}
+}
+
+func (s *seededIDs) next() string {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	id, err := uuid.NewRandomFromReader(s.r)
+	if err != nil {
+		// math/rand readers never fail.
+		panic(err)
+	}
+	return id.String()
+}
+
+// grpcSocketPath returns the path of the unix socket of the internal gRPC
+// server.  The path is random unless fixed by a test oracle.
+func (orc *Oracle) grpcSocketPath() string {
+	if orc.grpcSocket != "" {
+		return orc.grpcSocket
+	}
+	nBig, err := rand.Int(rand.Reader, big.NewInt(math.MaxInt32))
+	if err != nil {
+		panic(err)
+	}
+	return fmt.Sprintf("/tmp/oracle.grpc.%d.sock", nBig.Int64())
+}
@@ -0,0 +1,76 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package oracle
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	healthcheck "buf.build/gen/go/luthersystems/protos/protocolbuffers/go/healthcheck/v1"
+	grpcmiddleware "github.com/grpc-ecosystem/go-grpc-middleware"
+	"github.com/luthersystems/svc/grpclogging"
+	"github.com/luthersystems/svc/svcerr"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+)
+
+func TestDeterministicMode(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	newOrc := func() *Oracle {
+		cfg := &testCfg{}
+		WithDeterministic(now, 42)(cfg)
+		require.True(t, cfg.socketPath)
+		orc := newTestOracle(t, DefaultConfig())
+		orc.clock = cfg.clock
+		orc.ids = newSeededIDs(*cfg.seed)
+		return orc
+	}
+	a, b := newOrc(), newOrc()
+	require.Equal(t, now, a.Now())
+	ids := []string{a.NewID(), a.NewID()}
+	require.NotEqual(t, ids[0], ids[1])
+	require.Equal(t, ids, []string{b.NewID(), b.NewID()})
+
+	ctx := a.TestContext(context.Background())
+	ex := svcerr.BusinessException(ctx, "invalid")
+	require.Equal(t, "2024-03-01T12:00:00Z", ex.GetTimestamp())
+	require.Len(t, ex.GetId(), 36)
+
+	// Health reports are timestamped with the oracle clock.
+	a.stopping.Store(true)
+	w := httptest.NewRecorder()
+	a.healthCheckHandler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, healthCheckPath, nil))
+	require.Contains(t, w.Body.String(), "2024-03-01T12:00:00Z")
+
+	// Without test options the oracle is not deterministic.
+	orc := newTestOracle(t, DefaultConfig())
+	require.NotEqual(t, orc.NewID(), orc.NewID())
+	require.WithinDuration(t, time.Now(), orc.Now(), time.Minute)
+	require.True(t, strings.HasPrefix(orc.grpcSocketPath(), "/tmp/oracle.grpc."))
+	orc.grpcSocket = "/tmp/fixed.sock"
+	require.Equal(t, "/tmp/fixed.sock", orc.grpcSocketPath())
+}
+
+func TestDeterministicInterceptors(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	orc := newTestOracle(t, DefaultConfig())
+	orc.clock = func() time.Time { return now }
+	orc.ids = newSeededIDs(42)
+	want := newSeededIDs(42).next()
+
+	var reqID, timestamp string
+	chain := grpcmiddleware.ChainUnaryServer(orc.unaryInterceptors()...)
+	_, err := chain(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: "/pkg.Service/Get"},
+		func(ctx context.Context, req interface{}) (interface{}, error) {
+			reqID = grpclogging.ReqID(ctx)
+			timestamp = svcerr.BusinessException(ctx, "invalid").GetTimestamp()
+			return &healthcheck.GetHealthCheckResponse{}, nil
+		})
+	require.NoError(t, err)
+	require.Equal(t, want, reqID)
+	require.Equal(t, "2024-03-01T12:00:00Z", timestamp)
+}
@@ -0,0 +1,232 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package oracle
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/encoding"
+	grpcgzip "google.golang.org/grpc/encoding/gzip"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	// CompressionGzip compresses messages with gzip.
+	CompressionGzip = grpcgzip.Name
+	// CompressionZstd compresses messages with zstd.
+	CompressionZstd = "zstd"
+
+	defaultCompressionMinSize = 1024
+)
+
+// init registers the zstd compressor, which gRPC only allows during
+// initialization.  The registry is global: importing the oracle makes every
+// gRPC server in the process accept zstd compressed messages, and every gRPC
+// client advertise zstd in grpc-accept-encoding, whether or not
+// Compression.GRPC is "zstd".  Messages are only sent compressed when a call
+// selects the compressor.
+func init() {
+	encoding.RegisterCompressor(&zstdCompressor{})
+}
+
+// Compression configures compression of large payloads flowing through the
+// oracle.
+type Compression struct {
+	// GRPC is the algorithm, "gzip" or "zstd", compressing messages between
+	// the grpc-gateway and the oracle grpc server.  Empty disables
+	// compression.  The zstd compressor is registered with gRPC for the
+	// whole process regardless, see init.
+	GRPC string `yaml:"grpc"`
+	// Phylum gzip compresses the bodies of phylum gateway requests.  The
+	// shiroclient gateway must accept gzip encoded request bodies.
+	Phylum bool `yaml:"phylum"`
+	// MinSize is the size in bytes from which messages and request bodies
+	// are compressed.  Defaults to 1024.
+	MinSize int `yaml:"min-size"`
+}
+
+// valid validates the compression configuration.
+func (c Compression) valid() error {
+	switch c.GRPC {
+	case "", CompressionGzip, CompressionZstd:
+	default:
+		return fmt.Errorf("compression: unsupported grpc algorithm %q", c.GRPC)
+	}
+	if c.MinSize < 0 {
+		return fmt.Errorf("compression: negative min size")
+	}
+	return nil
+}
+
+// minSize returns the size from which payloads are compressed.
+func (c Compression) minSize() int {
+	if c.MinSize == 0 {
+		return defaultCompressionMinSize
+	}
+	return c.MinSize
+}
+
+// compress returns true if a message should be compressed.
+func (c Compression) compress(msg interface{}) bool {
+	m, ok := msg.(proto.Message)
+	return c.GRPC != "" && ok && proto.Size(m) >= c.minSize()
+}
+
+// compressionClientInterceptor compresses large requests sent by the
+// grpc-gateway to the oracle grpc server.
+func (orc *Oracle) compressionClientInterceptor() grpc.UnaryClientInterceptor {
+	c := orc.cfg.Compression
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if c.compress(req) {
+			opts = append(opts, grpc.UseCompressor(c.GRPC))
+		}
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
+
+// compressionServerInterceptor compresses large responses sent by the
+// oracle grpc server to the grpc-gateway.
+func (orc *Oracle) compressionServerInterceptor() grpc.UnaryServerInterceptor {
+	c := orc.cfg.Compression
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		resp, err := handler(ctx, req)
+		if err == nil && c.compress(resp) {
+			if err := grpc.SetSendCompressor(ctx, c.GRPC); err != nil {
+				orc.log(ctx).WithError(err).Debugf("response compression unavailable")
+			}
+		}
+		return resp, err
+	}
+}
+
+// phylumHTTPClient returns the client used for phylum gateway calls, or nil
+// to use the shiroclient default.
+func (orc *Oracle) phylumHTTPClient() *http.Client {
+	if !orc.cfg.Compression.Phylum {
+		return nil
+	}
+	return &http.Client{
+		Transport: &gzipRequestTransport{
+			next:    http.DefaultTransport,
+			minSize: orc.cfg.Compression.minSize(),
+		},
+	}
+}
+
+// gzipRequestTransport gzip compresses large request bodies.
+type gzipRequestTransport struct {
+	next    http.RoundTripper
+	minSize int
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *gzipRequestTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	if r.Body == nil || r.Body == http.NoBody || r.ContentLength < int64(t.minSize) || r.Header.Get("Content-Encoding") != "" {
+		return t.next.RoundTrip(r)
+	}
+	body, err := io.ReadAll(r.Body)
+	r.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	var b bytes.Buffer
+	zw := gzip.NewWriter(&b)
+	if _, err := zw.Write(body); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	compressed := b.Bytes()
+	r = r.Clone(r.Context())
+	r.Header.Set("Content-Encoding", "gzip")
+	r.ContentLength = int64(len(compressed))
+	r.Body = io.NopCloser(bytes.NewReader(compressed))
+	r.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(compressed)), nil
+	}
+	return t.next.RoundTrip(r)
+}
+
+// zstdCompressor implements the grpc zstd encoding, reusing encoders and
+// decoders.
+type zstdCompressor struct {
+	encoders sync.Pool
+	decoders sync.Pool
+}
+
+// Name implements encoding.Compressor.
+func (c *zstdCompressor) Name() string {
+	return CompressionZstd
+}
+
+// Compress implements encoding.Compressor.
+func (c *zstdCompressor) Compress(w io.Writer) (io.WriteCloser, error) {
+	enc, ok := c.encoders.Get().(*zstd.Encoder)
+	if !ok {
+		var err error
+		enc, err = zstd.NewWriter(w, zstd.WithEncoderConcurrency(1))
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		enc.Reset(w)
+	}
+	return &zstdWriter{Encoder: enc, pool: &c.encoders}, nil
+}
+
+// Decompress implements encoding.Compressor.
+func (c *zstdCompressor) Decompress(r io.Reader) (io.Reader, error) {
+	dec, ok := c.decoders.Get().(*zstd.Decoder)
+	if !ok {
+		var err error
+		dec, err = zstd.NewReader(r, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, err
+		}
+	} else if err := dec.Reset(r); err != nil {
+		c.decoders.Put(dec)
+		return nil, err
+	}
+	return &zstdReader{Decoder: dec, pool: &c.decoders}, nil
+}
+
+// zstdWriter returns its encoder to the pool when closed.
+type zstdWriter struct {
+	*zstd.Encoder
+	pool *sync.Pool
+}
+
+// Close implements io.Closer.
+func (w *zstdWriter) Close() error {
+	err := w.Encoder.Close()
+	w.pool.Put(w.Encoder)
+	return err
+}
+
+// zstdReader returns its decoder to the pool once fully read.
+type zstdReader struct {
+	*zstd.Decoder
+	pool *sync.Pool
+}
+
+// Read implements io.Reader.
+func (r *zstdReader) Read(p []byte) (int, error) {
+	if r.Decoder == nil {
+		return 0, io.EOF
+	}
+	n, err := r.Decoder.Read(p)
+	if err == io.EOF {
+		r.pool.Put(r.Decoder)
+		r.Decoder = nil
+	}
+	return n, err
+}
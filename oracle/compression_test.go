@@ -0,0 +1,83 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package oracle
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/encoding"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func TestZstdCompressor(t *testing.T) {
+	c := encoding.GetCompressor(CompressionZstd)
+	require.NotNil(t, c)
+	payload := []byte(strings.Repeat("document payload ", 1000))
+	for i := 0; i < 3; i++ {
+		var b bytes.Buffer
+		w, err := c.Compress(&b)
+		require.NoError(t, err)
+		_, err = w.Write(payload)
+		require.NoError(t, err)
+		require.NoError(t, w.Close())
+		require.Less(t, b.Len(), len(payload))
+
+		r, err := c.Decompress(&b)
+		require.NoError(t, err)
+		got, err := io.ReadAll(r)
+		require.NoError(t, err)
+		require.Equal(t, payload, got)
+	}
+}
+
+func TestCompressionThreshold(t *testing.T) {
+	c := Compression{GRPC: CompressionGzip, MinSize: 64}
+	require.NoError(t, c.valid())
+	small := &structpb.Struct{}
+	large, err := structpb.NewStruct(map[string]interface{}{"doc": strings.Repeat("x", 100)})
+	require.NoError(t, err)
+	require.False(t, c.compress(small))
+	require.True(t, c.compress(large))
+	require.False(t, Compression{MinSize: 64}.compress(large))
+
+	require.Error(t, Compression{GRPC: "brotli"}.valid())
+	require.Error(t, Compression{MinSize: -1}.valid())
+}
+
+func TestGzipRequestTransport(t *testing.T) {
+	var contentEncoding string
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contentEncoding = r.Header.Get("Content-Encoding")
+		var rd io.Reader = r.Body
+		if contentEncoding == "gzip" {
+			zr, err := gzip.NewReader(r.Body)
+			require.NoError(t, err)
+			rd = zr
+		}
+		body, _ = io.ReadAll(rd)
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: &gzipRequestTransport{next: http.DefaultTransport, minSize: 16}}
+	for _, test := range []struct {
+		body     string
+		encoding string
+	}{
+		{"small", ""},
+		{strings.Repeat("large ", 10), "gzip"},
+	} {
+		resp, err := client.Post(server.URL, "application/json", strings.NewReader(test.body))
+		require.NoError(t, err)
+		resp.Body.Close()
+		require.Equal(t, test.encoding, contentEncoding)
+		require.Equal(t, test.body, string(body))
+	}
+}
@@ -0,0 +1,188 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package oracle
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/luthersystems/svc/txctx"
+	"google.golang.org/grpc"
+)
+
+// BlockHeightSource returns the current block height of the phylum's ledger.
+type BlockHeightSource func(ctx context.Context) (uint64, error)
+
+// SetBlockHeightSource configures the source of the ledger block height used
+// for conditional requests.  By default the oracle uses the highest commit
+// block it has observed in the responses to its phylum calls (see Call),
+// which is only accurate when a single oracle replica writes to the ledger.
+func (c *Config) SetBlockHeightSource(fn BlockHeightSource) {
+	if c == nil {
+		return
+	}
+	c.blockHeightSource = fn
+}
+
+// commitBlockNumField is the gateway response field holding the number of
+// the block containing a committed transaction.
+const commitBlockNumField = "$commit_block_num"
+
+// commitBlockInterceptor records the highest commit block observed in the
+// transaction details of handled requests, which may also be set by
+// handlers calling the phylum without Call.
+func (orc *Oracle) commitBlockInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		resp, err := handler(ctx, req)
+		orc.observeCommitBlock(txctx.Get(ctx).CommitBlockNum)
+		return resp, err
+	}
+}
+
+// observeCommitBlock raises the highest commit block observed to blockNum.
+func (orc *Oracle) observeCommitBlock(blockNum uint64) {
+	for {
+		last := orc.lastCommitBlock.Load()
+		if blockNum <= last || orc.lastCommitBlock.CompareAndSwap(last, blockNum) {
+			return
+		}
+	}
+}
+
+// recordTx records the committed transaction of a raw gateway response in
+// the transaction details of ctx, and observes its block.
+func (orc *Oracle) recordTx(ctx context.Context, raw interface{}) {
+	if txID := commitTxID(raw); txID != "" {
+		txctx.SetTransactionID(ctx, txID)
+	}
+	if blockNum := commitBlockNum(raw); blockNum != 0 {
+		txctx.SetCommitBlockNum(ctx, blockNum)
+		orc.observeCommitBlock(blockNum)
+	}
+}
+
+// commitBlockNum returns the commit block number of a raw gateway response,
+// or zero.
+func commitBlockNum(raw interface{}) uint64 {
+	res, ok := raw.(map[string]interface{})
+	if !ok {
+		return 0
+	}
+	switch v := res[commitBlockNumField].(type) {
+	case float64:
+		if v > 0 {
+			return uint64(v)
+		}
+	case json.Number:
+		n, _ := strconv.ParseUint(v.String(), 10, 64)
+		return n
+	case string:
+		n, _ := strconv.ParseUint(v, 10, 64)
+		return n
+	}
+	return 0
+}
+
+// blockHeight returns the current ledger block height, or zero if it is
+// unknown.
+func (orc *Oracle) blockHeight(ctx context.Context) uint64 {
+	if orc.cfg.blockHeightSource == nil {
+		return orc.lastCommitBlock.Load()
+	}
+	height, err := orc.cfg.blockHeightSource(ctx)
+	if err != nil {
+		orc.log(ctx).WithError(err).Warnf("block height unavailable")
+		return 0
+	}
+	return height
+}
+
+// isConditionalPath returns true if conditional requests are enabled for p.
+func (orc *Oracle) isConditionalPath(p string) bool {
+	for _, prefix := range orc.cfg.ConditionalPathPrefixes {
+		if strings.HasPrefix(p, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// blockETag derives an ETag for a request from the ledger block height.  The
+// caller's credentials are included because responses may differ by user.
+func (orc *Oracle) blockETag(r *http.Request, height uint64) string {
+	h := sha256.New()
+	for _, s := range []string{
+		orc.getLastPhylumVersion(),
+		r.URL.Path,
+		r.URL.RawQuery,
+		r.Header.Get("Authorization"),
+	} {
+		h.Write([]byte(s))
+		h.Write([]byte{0})
+	}
+	if orc.cfg.AuthCookieName != "" {
+		if c, err := r.Cookie(orc.cfg.AuthCookieName); err == nil {
+			h.Write([]byte(c.Value))
+		}
+	}
+	return fmt.Sprintf(`"b%d-%s"`, height, hex.EncodeToString(h.Sum(nil)[:8]))
+}
+
+// conditionalMiddleware answers GET requests with a matching If-None-Match
+// header with 304 Not Modified, without invoking the phylum, as long as no
+// block has been committed since the ETag was issued.
+func (orc *Oracle) conditionalMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet || !orc.isConditionalPath(r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		height := orc.blockHeight(r.Context())
+		if height == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+		etag := orc.blockETag(r, height)
+		for _, candidate := range strings.Split(r.Header.Get("If-None-Match"), ",") {
+			if strings.TrimSpace(candidate) == etag {
+				w.Header().Set("ETag", etag)
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+		}
+		w.Header().Set("ETag", etag)
+		next.ServeHTTP(&etagStatusWriter{ResponseWriter: w}, r)
+	})
+}
+
+// etagStatusWriter removes the ETag header from unsuccessful responses.
+type etagStatusWriter struct {
+	http.ResponseWriter
+	wroteHeader bool
+}
+
+func (w *etagStatusWriter) WriteHeader(code int) {
+	if !w.wroteHeader && code != http.StatusOK {
+		w.Header().Del("ETag")
+	}
+	w.wroteHeader = true
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *etagStatusWriter) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush implements http.Flusher.
+func (w *etagStatusWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
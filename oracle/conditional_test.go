@@ -0,0 +1,83 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package oracle
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	healthcheck "buf.build/gen/go/luthersystems/protos/protocolbuffers/go/healthcheck/v1"
+	"github.com/luthersystems/shiroclient-sdk-go/shiroclient"
+	"github.com/luthersystems/shiroclient-sdk-go/shiroclient/phylum"
+	"github.com/luthersystems/svc/txctx"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+// committingPhylum returns a phylum call which commits transactions in
+// the given blocks, one per call.
+func committingPhylum(blocks ...float64) func(context.Context, *phylum.Client, string, proto.Message, proto.Message, *interface{}, ...shiroclient.Config) (proto.Message, error) {
+	return func(ctx context.Context, ph *phylum.Client, methodName string, req proto.Message, resp proto.Message, raw *interface{}, configs ...shiroclient.Config) (proto.Message, error) {
+		block := blocks[0]
+		blocks = blocks[1:]
+		*raw = map[string]interface{}{
+			"result":            map[string]interface{}{},
+			commitTxIDField:     fmt.Sprintf("tx-%v", block),
+			commitBlockNumField: block,
+		}
+		return resp, nil
+	}
+}
+
+func TestCallRecordsTx(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.ConditionalPathPrefixes = []string{"/v1/reports"}
+	orc := newTestOracle(t, cfg)
+	orc.callPhylum = committingPhylum(42, 43)
+
+	app := orc.conditionalMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("reports"))
+	}))
+	get := func(ifNoneMatch string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/v1/reports", nil)
+		if ifNoneMatch != "" {
+			r.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		app.ServeHTTP(rr, r)
+		return rr
+	}
+	// Before any commit the ledger height is unknown.
+	require.Empty(t, get("").Header().Get("ETag"))
+
+	ctx := txctx.NewContext(context.Background())
+	_, err := Call(orc, ctx, "create_report", &healthcheck.HealthCheckReport{}, &healthcheck.HealthCheckReport{})
+	require.NoError(t, err)
+	require.Equal(t, txctx.Details{TransactionID: "tx-42", CommitBlockNum: 42}, txctx.Get(ctx))
+
+	rr := get("")
+	require.Equal(t, http.StatusOK, rr.Code)
+	etag := rr.Header().Get("ETag")
+	require.NotEmpty(t, etag)
+	require.Equal(t, http.StatusNotModified, get(etag).Code)
+
+	// A later commit invalidates the ETag.
+	_, err = Call(orc, context.Background(), "create_report", &healthcheck.HealthCheckReport{}, &healthcheck.HealthCheckReport{})
+	require.NoError(t, err)
+	rr = get(etag)
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.NotEqual(t, etag, rr.Header().Get("ETag"))
+}
+
+func TestCommitBlockNum(t *testing.T) {
+	require.Zero(t, commitBlockNum(nil))
+	require.Zero(t, commitBlockNum(map[string]interface{}{}))
+	require.Equal(t, uint64(7), commitBlockNum(map[string]interface{}{commitBlockNumField: float64(7)}))
+	require.Equal(t, uint64(8), commitBlockNum(map[string]interface{}{commitBlockNumField: json.Number("8")}))
+	require.Equal(t, uint64(9), commitBlockNum(map[string]interface{}{commitBlockNumField: "9"}))
+	require.Zero(t, commitBlockNum(map[string]interface{}{commitBlockNumField: float64(-1)}))
+}
@@ -0,0 +1,137 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package oracle
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// configzPath is used to serve the effective oracle configuration on the
+	// metrics server.
+	// IMPORTANT: this should not be accessible externally
+	configzPath = "/configz"
+
+	// maskedValue replaces sensitive configuration values.
+	maskedValue = "********"
+)
+
+// Masked returns a copy of the config with sensitive values masked.  String
+// fields tagged with `secret:"true"` are replaced entirely, and passwords
+// embedded in URL fields are removed.  Nested structs and slices of structs
+// are masked likewise.
+func (c *Config) Masked() *Config {
+	masked := *c
+	maskStruct(reflect.ValueOf(&masked).Elem())
+	return &masked
+}
+
+// maskStruct masks the exported fields of the addressable struct v.  Slices
+// are copied before masking so the original config is not modified.
+func maskStruct(v reflect.Value) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := v.Field(i)
+		if !t.Field(i).IsExported() {
+			continue
+		}
+		switch f.Kind() {
+		case reflect.String:
+			if f.String() == "" {
+				continue
+			}
+			if t.Field(i).Tag.Get("secret") == "true" {
+				f.SetString(maskedValue)
+				continue
+			}
+			f.SetString(maskURLPassword(f.String()))
+		case reflect.Struct:
+			maskStruct(f)
+		case reflect.Slice:
+			if f.Len() == 0 || f.Type().Elem().Kind() != reflect.Struct {
+				continue
+			}
+			cp := reflect.MakeSlice(f.Type(), f.Len(), f.Len())
+			reflect.Copy(cp, f)
+			for j := 0; j < cp.Len(); j++ {
+				maskStruct(cp.Index(j))
+			}
+			f.Set(cp)
+		}
+	}
+}
+
+// maskURLPassword masks the password in s if s is a URL with user info.
+func maskURLPassword(s string) string {
+	u, err := url.Parse(s)
+	if err != nil || u.User == nil {
+		return s
+	}
+	if _, ok := u.User.Password(); !ok {
+		return s
+	}
+	u.User = url.UserPassword(u.User.Username(), maskedValue)
+	return u.String()
+}
+
+// MaskedYAML renders the config as YAML with sensitive values masked.
+func (c *Config) MaskedYAML() ([]byte, error) {
+	b, err := yaml.Marshal(c.Masked())
+	if err != nil {
+		return nil, fmt.Errorf("config yaml: %w", err)
+	}
+	return b, nil
+}
+
+// Warnings returns cross-field consistency problems with a config that are
+// not severe enough to be rejected by Valid.
+func (c *Config) Warnings() []string {
+	var warnings []string
+	if c.EmulateCC && c.GatewayEndpoint != "" {
+		warnings = append(warnings, "gateway endpoint is ignored when emulating chaincode")
+	}
+	if c.LogoutPath != "" && c.AuthCookieName == "" && c.DepTxCookieName == "" {
+		warnings = append(warnings, "logout endpoint enabled without any cookies to clear")
+	}
+	if c.CookieInsecure && c.CookieDomain != "" {
+		warnings = append(warnings, "insecure cookies configured for a cookie domain")
+	}
+	return warnings
+}
+
+// logConfig logs the effective configuration and any config warnings.
+func (orc *Oracle) logConfig() {
+	b, err := orc.cfg.MaskedYAML()
+	if err != nil {
+		orc.logBase.WithError(err).Warnf("unable to render config")
+	} else {
+		orc.logBase.WithField("config_yaml", string(b)).Infof("oracle config")
+	}
+	if len(orc.cfg.configSources) > 0 {
+		orc.logBase.WithField("config_sources", orc.cfg.configSources.String()).Infof("oracle config sources")
+	}
+	for _, w := range orc.cfg.Warnings() {
+		orc.logBase.WithField("config_warning", w).Warnf("oracle config warning")
+	}
+}
+
+// configzHandler serves the effective configuration as YAML.
+func (orc *Oracle) configzHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, err := orc.cfg.MaskedYAML()
+		if err != nil {
+			orc.log(r.Context()).WithError(err).Errorf("configz")
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/yaml")
+		if _, err := w.Write(b); err != nil {
+			orc.log(r.Context()).WithError(err).Errorf("configz response error")
+		}
+	})
+}
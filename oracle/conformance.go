@@ -0,0 +1,303 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package oracle
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strconv"
+	"testing"
+
+	common "buf.build/gen/go/luthersystems/protos/protocolbuffers/go/common/v1"
+	grpcmiddleware "github.com/grpc-ecosystem/go-grpc-middleware"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"github.com/luthersystems/svc/svcerr"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+)
+
+// exceptionField is the response field holding exceptions, by convention.
+const exceptionField = "exception"
+
+// CheckExceptionConventions verifies that every unary gRPC method of the
+// services registered by grpcConfig obeys the svcerr exception convention.
+// Each method is called through the oracle interceptors with an empty
+// request, and its handler is replaced with handlers which return raw
+// errors, luther errors, gRPC errors and responses with exceptions.  Every
+// error must carry a single exception detail, render as an exception
+// response through the gateway error handler, and increment the exception
+// metric.  Run it against a test oracle so convention drift is caught
+// before deploys:
+//
+//	orc, stop := oracle.NewTestOracle(t, cfg)
+//	defer stop()
+//	oracle.CheckExceptionConventions(t, orc, svc)
+func CheckExceptionConventions(t *testing.T, orc *Oracle, grpcConfig GrpcGatewayConfig) {
+	t.Helper()
+	interceptors := orc.unaryInterceptors()
+	server := grpc.NewServer(grpc.UnaryInterceptor(grpcmiddleware.ChainUnaryServer(interceptors...)))
+	grpcConfig.RegisterServiceServer(server)
+	lis := bufconn.Listen(1 << 20)
+	go func() {
+		_ = server.Serve(lis)
+	}()
+	defer server.Stop()
+	conn, err := grpc.NewClient("passthrough:///conformance",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer conn.Close()
+
+	checker := newConventionChecker(orc)
+	chain := grpcmiddleware.ChainUnaryServer(interceptors...)
+	services := server.GetServiceInfo()
+	names := make([]string, 0, len(services))
+	for name := range services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, m := range services[name].Methods {
+			if m.IsClientStream || m.IsServerStream {
+				continue
+			}
+			method := fmt.Sprintf("/%s/%s", name, m.Name)
+			t.Run(method, func(t *testing.T) {
+				md, err := methodDescriptor(name, m.Name)
+				if err != nil {
+					t.Skipf("no descriptor: %v", err)
+				}
+				in, err := protoregistry.GlobalTypes.FindMessageByName(md.Input().FullName())
+				require.NoError(t, err, "request type")
+				out, err := protoregistry.GlobalTypes.FindMessageByName(md.Output().FullName())
+				require.NoError(t, err, "response type")
+
+				t.Run("empty request", func(t *testing.T) {
+					resp := out.New().Interface()
+					err := conn.Invoke(context.Background(), method, in.New().Interface(), resp)
+					if err == nil {
+						return
+					}
+					require.NoError(t, checker.check(method, err))
+				})
+
+				// A typed nil response, as returned by generated handlers
+				// with an error.
+				nilResp := reflect.Zero(reflect.TypeOf(out.New().Interface())).Interface()
+				withException := out.New()
+				if fd := withException.Descriptor().Fields().ByName(exceptionField); fd != nil {
+					withException.Set(fd, protoreflect.ValueOfMessage(
+						svcerr.BusinessException(context.Background(), "conformance").ProtoReflect()))
+				}
+				for _, h := range []struct {
+					name string
+					resp interface{}
+					err  error
+				}{
+					{"raw error", nilResp, errors.New("conformance")},
+					{"business error", nilResp, svcerr.NewBusinessError("conformance")},
+					{"security error", nilResp, svcerr.NewSecurityError("conformance")},
+					{"grpc error", nilResp, status.Error(codes.NotFound, "conformance")},
+					{"response exception", withException.Interface(), nil},
+				} {
+					t.Run(h.name, func(t *testing.T) {
+						_, err := chain(context.Background(), in.New().Interface(),
+							&grpc.UnaryServerInfo{FullMethod: method},
+							func(ctx context.Context, req interface{}) (interface{}, error) {
+								return h.resp, h.err
+							})
+						require.Error(t, err)
+						require.NoError(t, checker.check(method, err))
+					})
+				}
+
+				t.Run("nil exception", func(t *testing.T) {
+					resp, err := chain(context.Background(), in.New().Interface(),
+						&grpc.UnaryServerInfo{FullMethod: method},
+						func(ctx context.Context, req interface{}) (interface{}, error) {
+							return out.New().Interface(), nil
+						})
+					require.NoError(t, err, "successful response must not be an error")
+					require.NotNil(t, resp)
+				})
+			})
+		}
+	}
+}
+
+// methodDescriptor returns the descriptor of a registered method.
+func methodDescriptor(service string, method string) (protoreflect.MethodDescriptor, error) {
+	d, err := protoregistry.GlobalFiles.FindDescriptorByName(protoreflect.FullName(service))
+	if err != nil {
+		return nil, err
+	}
+	sd, ok := d.(protoreflect.ServiceDescriptor)
+	if !ok {
+		return nil, fmt.Errorf("%s is not a service", service)
+	}
+	md := sd.Methods().ByName(protoreflect.Name(method))
+	if md == nil {
+		return nil, fmt.Errorf("missing method %s", method)
+	}
+	return md, nil
+}
+
+// conventionChecker checks errors against the svcerr convention.
+type conventionChecker struct {
+	orc *Oracle
+	reg *prometheus.Registry
+}
+
+func newConventionChecker(orc *Oracle) *conventionChecker {
+	// The svcerr collectors are gathered from a private registry, as they
+	// may already be registered elsewhere.
+	reg := prometheus.NewRegistry()
+	for _, c := range svcerr.Collectors() {
+		_ = reg.Register(c)
+	}
+	return &conventionChecker{orc: orc, reg: reg}
+}
+
+// check returns an error if err, returned by method, does not obey the
+// exception convention.
+func (c *conventionChecker) check(method string, err error) error {
+	stat, ok := status.FromError(err)
+	if !ok {
+		return fmt.Errorf("not a gRPC status error: %v", err)
+	}
+	if stat.Code() == codes.OK {
+		return fmt.Errorf("error with OK status")
+	}
+	if len(stat.Details()) != 1 {
+		return fmt.Errorf("error with %d details, want 1", len(stat.Details()))
+	}
+	detail := stat.Details()[0]
+	except, err := detailException(detail)
+	if err != nil {
+		return err
+	}
+	if _, ok := common.Exception_Type_name[int32(except.GetType())]; !ok || except.GetType() == common.Exception_INVALID_TYPE {
+		return fmt.Errorf("invalid exception type %v", except.GetType())
+	}
+	if except.GetDescription() == "" {
+		return fmt.Errorf("exception without description")
+	}
+	if except.GetTimestamp() == "" {
+		return fmt.Errorf("exception without timestamp")
+	}
+	return c.checkHTTP(method, stat.Err(), except, detail)
+}
+
+// detailException returns the exception of a status detail, which is either
+// an exception or a response with an exception.
+func detailException(detail interface{}) (*common.Exception, error) {
+	switch d := detail.(type) {
+	case *common.Exception:
+		return d, nil
+	case error:
+		return nil, fmt.Errorf("undecodable detail: %w", d)
+	case proto.Message:
+		msg := d.ProtoReflect()
+		fd := msg.Descriptor().Fields().ByName(exceptionField)
+		if fd == nil || fd.Message() == nil || fd.Message().FullName() != (&common.Exception{}).ProtoReflect().Descriptor().FullName() {
+			return nil, fmt.Errorf("detail %s has no exception", msg.Descriptor().FullName())
+		}
+		except := &common.Exception{}
+		b, err := proto.Marshal(msg.Get(fd).Message().Interface())
+		if err == nil {
+			err = proto.Unmarshal(b, except)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("detail exception: %w", err)
+		}
+		if except.GetType() == common.Exception_INVALID_TYPE {
+			return nil, fmt.Errorf("detail %s has no exception", msg.Descriptor().FullName())
+		}
+		return except, nil
+	default:
+		return nil, fmt.Errorf("unexpected detail %T", detail)
+	}
+}
+
+// checkHTTP renders err through the gateway error handler and checks the
+// response.
+func (c *conventionChecker) checkHTTP(method string, err error, except *common.Exception, detail interface{}) error {
+	mux := c.orc.grpcGatewayMux()
+	r := httptest.NewRequest(http.MethodPost, "/conformance", nil)
+	ctx, aerr := runtime.AnnotateContext(r.Context(), mux, r, method)
+	if aerr != nil {
+		return aerr
+	}
+	_, isException := detail.(*common.Exception)
+	before := c.exceptionCount(except.GetType(), method)
+	w := httptest.NewRecorder()
+	_, marshaler := runtime.MarshalerForRequest(mux, r)
+	c.orc.errorHandler()(ctx, mux, marshaler, w, r, err)
+	if w.Code < http.StatusBadRequest {
+		return fmt.Errorf("HTTP status %d for error", w.Code)
+	}
+	var body map[string]json.RawMessage
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		return fmt.Errorf("invalid JSON response: %w", err)
+	}
+	var exception struct {
+		Type        string `json:"type"`
+		Description string `json:"description"`
+	}
+	if err := json.Unmarshal(body[exceptionField], &exception); err != nil || exception.Type == "" || exception.Description == "" {
+		return fmt.Errorf("response has no exception: %s", w.Body.String())
+	}
+	if exception.Type != except.GetType().String() {
+		return fmt.Errorf("response exception type %s, want %s", exception.Type, except.GetType())
+	}
+	if isException {
+		if after := c.exceptionCount(except.GetType(), method); after != before+1 {
+			return fmt.Errorf("exception metric incremented by %v, want 1", after-before)
+		}
+	}
+	return nil
+}
+
+// exceptionCount returns the number of exceptions of type t counted for
+// method, over all status codes.
+func (c *conventionChecker) exceptionCount(t common.Exception_Type, method string) float64 {
+	mfs, err := c.reg.Gather()
+	if err != nil {
+		return 0
+	}
+	var n float64
+	for _, mf := range mfs {
+		if mf.GetName() != "exception_total" {
+			continue
+		}
+		for _, m := range mf.GetMetric() {
+			labels := make(map[string]string)
+			for _, l := range m.GetLabel() {
+				labels[l.GetName()] = l.GetValue()
+			}
+			if labels["type"] == t.String() && labels["method"] == method {
+				if _, err := strconv.Atoi(labels["code"]); err == nil {
+					n += m.GetCounter().GetValue()
+				}
+			}
+		}
+	}
+	return n
+}
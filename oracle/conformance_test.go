@@ -0,0 +1,113 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package oracle
+
+import (
+	"context"
+	"testing"
+
+	healthcheck "buf.build/gen/go/luthersystems/protos/protocolbuffers/go/healthcheck/v1"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"github.com/luthersystems/svc/svcerr"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+const conformanceService = "oracle.test.ConformanceService"
+
+// conformanceConfig registers a service whose methods follow the exception
+// convention.
+type conformanceConfig struct{}
+
+func (conformanceConfig) RegisterServiceServer(s *grpc.Server) {
+	handler := func(name string, fn func(context.Context, *healthcheck.GetHealthCheckRequest) (*healthcheck.GetHealthCheckResponse, error)) grpc.MethodDesc {
+		return grpc.MethodDesc{
+			MethodName: name,
+			Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+				in := &healthcheck.GetHealthCheckRequest{}
+				if err := dec(in); err != nil {
+					return nil, err
+				}
+				info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/" + conformanceService + "/" + name}
+				return interceptor(ctx, in, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+					return fn(ctx, req.(*healthcheck.GetHealthCheckRequest))
+				})
+			},
+		}
+	}
+	s.RegisterService(&grpc.ServiceDesc{
+		ServiceName: conformanceService,
+		HandlerType: (*interface{})(nil),
+		Methods: []grpc.MethodDesc{
+			handler("Get", func(ctx context.Context, req *healthcheck.GetHealthCheckRequest) (*healthcheck.GetHealthCheckResponse, error) {
+				return &healthcheck.GetHealthCheckResponse{}, nil
+			}),
+			handler("Fail", func(ctx context.Context, req *healthcheck.GetHealthCheckRequest) (*healthcheck.GetHealthCheckResponse, error) {
+				return nil, svcerr.NewBusinessError("missing field")
+			}),
+			handler("Raise", func(ctx context.Context, req *healthcheck.GetHealthCheckRequest) (*healthcheck.GetHealthCheckResponse, error) {
+				return &healthcheck.GetHealthCheckResponse{Exception: svcerr.ServiceException(ctx, "unavailable")}, nil
+			}),
+		},
+	}, struct{}{})
+}
+
+func (conformanceConfig) RegisterServiceClient(ctx context.Context, conn *grpc.ClientConn, mux *runtime.ServeMux) error {
+	return nil
+}
+
+func registerConformanceService(t *testing.T) {
+	if _, err := protoregistry.GlobalFiles.FindDescriptorByName(conformanceService); err == nil {
+		return
+	}
+	req := (&healthcheck.GetHealthCheckRequest{}).ProtoReflect().Descriptor()
+	resp := (&healthcheck.GetHealthCheckResponse{}).ProtoReflect().Descriptor()
+	method := func(name string) *descriptorpb.MethodDescriptorProto {
+		return &descriptorpb.MethodDescriptorProto{
+			Name:       proto.String(name),
+			InputType:  proto.String("." + string(req.FullName())),
+			OutputType: proto.String("." + string(resp.FullName())),
+		}
+	}
+	fd, err := protodesc.NewFile(&descriptorpb.FileDescriptorProto{
+		Name:       proto.String("oracle/conformance_test.proto"),
+		Package:    proto.String("oracle.test"),
+		Syntax:     proto.String("proto3"),
+		Dependency: []string{req.ParentFile().Path()},
+		Service: []*descriptorpb.ServiceDescriptorProto{{
+			Name:   proto.String("ConformanceService"),
+			Method: []*descriptorpb.MethodDescriptorProto{method("Get"), method("Fail"), method("Raise")},
+		}},
+	}, protoregistry.GlobalFiles)
+	require.NoError(t, err)
+	require.NoError(t, protoregistry.GlobalFiles.RegisterFile(fd))
+}
+
+func TestCheckExceptionConventions(t *testing.T) {
+	registerConformanceService(t)
+	CheckExceptionConventions(t, newTestOracle(t, DefaultConfig()), conformanceConfig{})
+}
+
+func TestConventionChecker(t *testing.T) {
+	checker := newConventionChecker(newTestOracle(t, DefaultConfig()))
+	const method = "/" + conformanceService + "/Get"
+
+	// Errors without details violate the convention.
+	require.Error(t, checker.check(method, status.Error(codes.NotFound, "missing")))
+	require.Error(t, checker.check(method, context.Canceled))
+
+	stat, err := status.New(codes.InvalidArgument, "invalid").WithDetails(svcerr.BusinessException(context.Background(), "invalid"))
+	require.NoError(t, err)
+	require.NoError(t, checker.check(method, stat.Err()))
+
+	// Exceptions must be typed.
+	stat, err = status.New(codes.InvalidArgument, "invalid").WithDetails(&healthcheck.GetHealthCheckResponse{})
+	require.NoError(t, err)
+	require.Error(t, checker.check(method, stat.Err()))
+}
@@ -0,0 +1,345 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package oracle
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"github.com/luthersystems/svc/svcerr"
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// csvColumnsParam is the query parameter selecting the exported columns.
+const csvColumnsParam = "columns"
+
+var (
+	csvExportRowsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "csv_export_rows_total",
+			Help: "How many CSV export rows were written, partitioned by path.",
+		},
+		[]string{"path"},
+	)
+	csvExportsRunning = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "csv_exports_running",
+			Help: "CSV exports in progress, partitioned by path.",
+		},
+		[]string{"path"},
+	)
+)
+
+// CSVPager fetches the page of rows following pageToken, which is empty for
+// the first page, for the export requested by r.  It returns the next page
+// token, or an empty token after the last page.
+type CSVPager func(ctx context.Context, orc *Oracle, r *http.Request, pageToken string) (rows []proto.Message, nextPageToken string, err error)
+
+// PhylumCSVPager returns a pager calling a paged phylum list method.  newReq
+// creates the request for a page from the HTTP request, typically copying
+// filters from its query parameters, and page extracts the rows and next
+// page token from the response.
+func PhylumCSVPager[K proto.Message, R proto.Message](methodName string, newReq func(r *http.Request, pageToken string) (K, error), newResp func() R, page func(R) ([]proto.Message, string)) CSVPager {
+	return func(ctx context.Context, orc *Oracle, r *http.Request, pageToken string) ([]proto.Message, string, error) {
+		req, err := newReq(r, pageToken)
+		if err != nil {
+			return nil, "", err
+		}
+		resp, err := Call(orc, ctx, methodName, req, newResp())
+		if err != nil {
+			return nil, "", err
+		}
+		rows, next := page(resp)
+		return rows, next, nil
+	}
+}
+
+// CSVColumn is a column of a CSV export.
+type CSVColumn struct {
+	// Header is the column header.  Defaults to Field.
+	Header string
+	// Field is the dot-separated path of the exported row field, using
+	// proto field names, e.g. "customer.name".
+	Field string
+}
+
+// CSVAuthorizer authorizes a CSV export given the claims of the requesting
+// user.
+type CSVAuthorizer func(ctx context.Context, claims map[string]interface{}) error
+
+// CSVExportOption configures a CSV export endpoint.
+type CSVExportOption func(*csvExport)
+
+// WithCSVColumns sets the exported columns, in order.  Clients may select a
+// subset with the columns query parameter, a comma-separated list of
+// fields.  Defaults to the top-level fields of the rows, in which case any
+// field path may be selected.
+func WithCSVColumns(cols ...CSVColumn) CSVExportOption {
+	return func(e *csvExport) {
+		e.columns = cols
+	}
+}
+
+// WithCSVAuthorizer requires exports to present claims which are accepted by
+// fn.  The claims are obtained using the configured ClaimsGetter.
+func WithCSVAuthorizer(fn CSVAuthorizer) CSVExportOption {
+	return func(e *csvExport) {
+		e.authorize = fn
+	}
+}
+
+// WithCSVFilename sets the file name suggested to clients saving the export.
+func WithCSVFilename(name string) CSVExportOption {
+	return func(e *csvExport) {
+		e.filename = name
+	}
+}
+
+type csvExport struct {
+	pager     CSVPager
+	columns   []CSVColumn
+	authorize CSVAuthorizer
+	filename  string
+}
+
+// AddCSVExportPath serves a text/csv export at path, streaming the rows
+// fetched page by page by pager.  Each page is flushed to the client before
+// the next is fetched, so at most one page is held in memory and slow
+// clients slow down paging rather than accumulating rows.
+//
+// Errors fetching the first page are returned as exceptions.  Later errors
+// can no longer change the response status and truncate the export; they are
+// logged.  String cells starting with a spreadsheet formula character are
+// prefixed with a single quote.
+func (c *Config) AddCSVExportPath(path string, pager CSVPager, opts ...CSVExportOption) {
+	if c == nil {
+		return
+	}
+	e := &csvExport{pager: pager}
+	for _, opt := range opts {
+		opt(e)
+	}
+	if c.csvExports == nil {
+		c.csvExports = make(map[string]*csvExport)
+	}
+	c.csvExports[path] = e
+}
+
+// selectColumns returns the columns requested by the columns query
+// parameter, or all configured columns.
+func (e *csvExport) selectColumns(r *http.Request) ([]CSVColumn, error) {
+	param := r.URL.Query().Get(csvColumnsParam)
+	if param == "" {
+		return e.columns, nil
+	}
+	var cols []CSVColumn
+	for _, field := range strings.Split(param, ",") {
+		field = strings.TrimSpace(field)
+		col, ok := CSVColumn{Field: field}, len(e.columns) == 0
+		for _, c := range e.columns {
+			if c.Field == field {
+				col, ok = c, true
+			}
+		}
+		if !ok || field == "" {
+			return nil, fmt.Errorf("unknown column %q", field)
+		}
+		cols = append(cols, col)
+	}
+	return cols, nil
+}
+
+// defaultCSVColumns returns a column for each top-level field of row.
+func defaultCSVColumns(row proto.Message) []CSVColumn {
+	fields := row.ProtoReflect().Descriptor().Fields()
+	cols := make([]CSVColumn, 0, fields.Len())
+	for i := 0; i < fields.Len(); i++ {
+		cols = append(cols, CSVColumn{Field: string(fields.Get(i).Name())})
+	}
+	return cols
+}
+
+// csvRecord renders the cells of row for cols.
+func csvRecord(row proto.Message, cols []CSVColumn) ([]string, error) {
+	b, err := protojson.MarshalOptions{UseProtoNames: true}.Marshal(row)
+	if err != nil {
+		return nil, err
+	}
+	var data map[string]interface{}
+	if err := json.Unmarshal(b, &data); err != nil {
+		return nil, err
+	}
+	record := make([]string, len(cols))
+	for i, col := range cols {
+		var v interface{} = data
+		for _, name := range strings.Split(col.Field, ".") {
+			m, ok := v.(map[string]interface{})
+			if !ok {
+				v = nil
+				break
+			}
+			v = m[name]
+		}
+		record[i], err = csvCell(v)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return record, nil
+}
+
+// csvCell renders a JSON value as a CSV cell.  Lists and messages are
+// rendered as JSON.
+func csvCell(v interface{}) (string, error) {
+	switch v := v.(type) {
+	case nil:
+		return "", nil
+	case string:
+		if v != "" && strings.ContainsRune("=+-@\t\r", rune(v[0])) {
+			// Prevent spreadsheets from evaluating the cell as a formula.
+			return "'" + v, nil
+		}
+		return v, nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	default:
+		b, err := json.Marshal(v)
+		return string(b), err
+	}
+}
+
+// csvExportError writes the exception response for an error fetching the
+// first page of an export.  Errors without an exception are not presented to
+// clients.
+func (orc *Oracle) csvExportError(ctx context.Context, w http.ResponseWriter, err error) {
+	code := http.StatusInternalServerError
+	ex := svcerr.DownstreamException(err)
+	if ex != nil {
+		// Map the exception type to its status as the grpc-gateway would.
+		code = runtime.HTTPStatusFromCode(status.Code(svcerr.WrapException(ex, nil)))
+	} else {
+		orc.log(ctx).WithError(err).Errorf("csv export failed")
+		ex = svcerr.UnexpectedException(ctx, "Internal server error")
+	}
+	if err := writeExceptionHTTP(w, code, ex); err != nil {
+		orc.log(ctx).WithError(err).Errorf("csv export response error")
+	}
+}
+
+func (orc *Oracle) csvExportHandler(path string, e *csvExport) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := orc.httpMetadataContext(r)
+		writeException := func(code int, reason error) {
+			ex := svcerr.BusinessException(ctx, reason.Error())
+			if code == http.StatusUnauthorized {
+				ex = svcerr.SecurityException(ctx, reason.Error())
+			}
+			if err := writeExceptionHTTP(w, code, ex); err != nil {
+				orc.log(ctx).WithError(err).Errorf("csv export response error")
+			}
+		}
+		if r.Method != http.MethodGet {
+			writeException(http.StatusMethodNotAllowed, errors.New("method not allowed"))
+			return
+		}
+		if e.authorize != nil {
+			claims, err := orc.GetClaims(ctx)
+			if err == nil {
+				err = e.authorize(ctx, claims)
+			}
+			if err != nil {
+				orc.log(ctx).WithError(err).Infof("csv export unauthorized")
+				writeException(http.StatusUnauthorized, errors.New("unauthorized"))
+				return
+			}
+		}
+		cols, err := e.selectColumns(r)
+		if err != nil {
+			writeException(http.StatusBadRequest, err)
+			return
+		}
+
+		csvExportsRunning.WithLabelValues(path).Inc()
+		defer csvExportsRunning.WithLabelValues(path).Dec()
+
+		rows, next, err := e.pager(ctx, orc, r, "")
+		if err != nil {
+			orc.csvExportError(ctx, w, err)
+			return
+		}
+		if cols == nil && len(rows) > 0 {
+			cols = defaultCSVColumns(rows[0])
+		}
+
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("X-Accel-Buffering", "no")
+		if e.filename != "" {
+			w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": e.filename}))
+		}
+		w.WriteHeader(http.StatusOK)
+		flusher, _ := w.(http.Flusher)
+		cw := csv.NewWriter(w)
+		header := make([]string, len(cols))
+		for i, col := range cols {
+			header[i] = col.Header
+			if header[i] == "" {
+				header[i] = col.Field
+			}
+		}
+		if err := cw.Write(header); err != nil {
+			orc.log(ctx).WithError(err).Debugf("csv export write")
+			return
+		}
+		for {
+			for _, row := range rows {
+				record, err := csvRecord(row, cols)
+				if err != nil {
+					orc.log(ctx).WithError(err).Errorf("csv export marshal")
+					return
+				}
+				if err := cw.Write(record); err != nil {
+					orc.log(ctx).WithError(err).Debugf("csv export write")
+					return
+				}
+			}
+			cw.Flush()
+			if err := cw.Error(); err != nil {
+				orc.log(ctx).WithError(err).Debugf("csv export write")
+				return
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+			csvExportRowsTotal.WithLabelValues(path).Add(float64(len(rows)))
+			if next == "" {
+				return
+			}
+			if ctx.Err() != nil {
+				orc.log(ctx).WithError(ctx.Err()).Debugf("csv export canceled")
+				return
+			}
+			token := next
+			rows, next, err = e.pager(ctx, orc, r, token)
+			if err == nil && next == token {
+				err = fmt.Errorf("page token %q repeated", token)
+			}
+			if err != nil {
+				orc.log(ctx).WithError(err).Errorf("csv export truncated")
+				return
+			}
+		}
+	})
+}
@@ -0,0 +1,155 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package oracle
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	healthcheck "buf.build/gen/go/luthersystems/protos/protocolbuffers/go/healthcheck/v1"
+	"github.com/luthersystems/svc/svcerr"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+// testCSVPager pages through reports, two at a time.
+func testCSVPager(reports []*healthcheck.HealthCheckReport) CSVPager {
+	return func(ctx context.Context, orc *Oracle, r *http.Request, pageToken string) ([]proto.Message, string, error) {
+		if fail, ok := r.URL.Query()["fail"]; ok && fail[0] == pageToken {
+			return nil, "", svcerr.NewBusinessError("invalid filter")
+		}
+		start := 0
+		if pageToken != "" {
+			start = int(pageToken[0] - '0')
+		}
+		end := min(start+2, len(reports))
+		var rows []proto.Message
+		for _, report := range reports[start:end] {
+			rows = append(rows, report)
+		}
+		next := ""
+		if end < len(reports) {
+			next = string(rune('0' + end))
+		}
+		return rows, next, nil
+	}
+}
+
+func TestCSVExport(t *testing.T) {
+	reports := []*healthcheck.HealthCheckReport{
+		{ServiceName: "api", ServiceVersion: "1.0", Status: "UP"},
+		{ServiceName: "db, primary", Status: "UP"},
+		{ServiceName: `"cache"`, Status: "DOWN"},
+		{ServiceName: "=HYPERLINK(\"x\")", Status: "UP"},
+		{ServiceName: "multi\nline"},
+	}
+	cfg := DefaultConfig()
+	cfg.AddCSVExportPath("/v1/export.csv", testCSVPager(reports),
+		WithCSVColumns(
+			CSVColumn{Header: "Service", Field: "service_name"},
+			CSVColumn{Field: "status"},
+			CSVColumn{Header: "Version", Field: "service_version"},
+		),
+		WithCSVFilename("reports.csv"))
+	orc := newTestOracle(t, cfg)
+	handler := orc.csvExportHandler("/v1/export.csv", cfg.csvExports["/v1/export.csv"])
+	get := func(target string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, target, nil))
+		return rr
+	}
+
+	rr := get("/v1/export.csv")
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Equal(t, "text/csv; charset=utf-8", rr.Header().Get("Content-Type"))
+	require.Equal(t, `attachment; filename=reports.csv`, rr.Header().Get("Content-Disposition"))
+	records, err := csv.NewReader(strings.NewReader(rr.Body.String())).ReadAll()
+	require.NoError(t, err)
+	require.Equal(t, [][]string{
+		{"Service", "status", "Version"},
+		{"api", "UP", "1.0"},
+		{"db, primary", "UP", ""},
+		{`"cache"`, "DOWN", ""},
+		{"'=HYPERLINK(\"x\")", "UP", ""},
+		{"multi\nline", "", ""},
+	}, records)
+
+	rr = get("/v1/export.csv?columns=status,service_name")
+	require.Equal(t, http.StatusOK, rr.Code)
+	records, err = csv.NewReader(strings.NewReader(rr.Body.String())).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, records, 6)
+	require.Equal(t, []string{"status", "Service"}, records[0])
+	require.Equal(t, []string{"UP", "api"}, records[1])
+
+	require.Equal(t, http.StatusBadRequest, get("/v1/export.csv?columns=secret").Code)
+	require.Equal(t, http.StatusBadRequest, get("/v1/export.csv?fail=").Code)
+
+	// Errors after the first page truncate the export.
+	rr = get("/v1/export.csv?fail=2")
+	require.Equal(t, http.StatusOK, rr.Code)
+	records, err = csv.NewReader(strings.NewReader(rr.Body.String())).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, records, 3)
+}
+
+func TestCSVExportDefaultColumns(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.AddCSVExportPath("/v1/export.csv", testCSVPager([]*healthcheck.HealthCheckReport{
+		{ServiceName: "api", Status: "UP"},
+	}))
+	orc := newTestOracle(t, cfg)
+	rr := httptest.NewRecorder()
+	orc.csvExportHandler("/v1/export.csv", cfg.csvExports["/v1/export.csv"]).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/v1/export.csv?columns=status", nil))
+	require.Equal(t, "status\nUP\n", rr.Body.String())
+
+	rr = httptest.NewRecorder()
+	orc.csvExportHandler("/v1/export.csv", cfg.csvExports["/v1/export.csv"]).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/v1/export.csv", nil))
+	records, err := csv.NewReader(strings.NewReader(rr.Body.String())).ReadAll()
+	require.NoError(t, err)
+	require.Contains(t, records[0], "service_name")
+	require.Contains(t, records[0], "status")
+}
+
+func TestCSVExportAuthorizer(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.SetClaimsGetter(func(ctx context.Context) (map[string]interface{}, error) {
+		return map[string]interface{}{"role": "viewer"}, nil
+	})
+	cfg.AddCSVExportPath("/v1/export.csv", testCSVPager(nil),
+		WithCSVAuthorizer(func(ctx context.Context, claims map[string]interface{}) error {
+			if claims["role"] != "admin" {
+				return errors.New("admin required")
+			}
+			return nil
+		}))
+	orc := newTestOracle(t, cfg)
+	rr := httptest.NewRecorder()
+	orc.csvExportHandler("/v1/export.csv", cfg.csvExports["/v1/export.csv"]).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/v1/export.csv", nil))
+	require.Equal(t, http.StatusUnauthorized, rr.Code)
+	require.NotContains(t, rr.Body.String(), "admin required")
+}
+
+func TestCSVCell(t *testing.T) {
+	for _, tt := range []struct {
+		v    interface{}
+		cell string
+	}{
+		{nil, ""},
+		{"-1+1", "'-1+1"},
+		{"@SUM(A1)", "'@SUM(A1)"},
+		{float64(12.5), "12.5"},
+		{float64(1e21), "1000000000000000000000"},
+		{true, "true"},
+		{[]interface{}{"a", float64(1)}, `["a",1]`},
+	} {
+		cell, err := csvCell(tt.v)
+		require.NoError(t, err)
+		require.Equal(t, tt.cell, cell)
+	}
+}
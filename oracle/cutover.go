@@ -0,0 +1,231 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package oracle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"net/http"
+	"slices"
+	"strings"
+
+	healthcheck "buf.build/gen/go/luthersystems/protos/protocolbuffers/go/healthcheck/v1"
+	"github.com/luthersystems/shiroclient-sdk-go/shiroclient/phylum"
+	"github.com/luthersystems/svc/grpclogging"
+	"github.com/luthersystems/svc/midware"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	// AttrPhylumTarget is the span attribute naming the phylum, "primary"
+	// or "candidate", which served the phylum calls of a request.
+	AttrPhylumTarget = attribute.Key("app.phylum.target")
+
+	phylumTargetPrimary   = "primary"
+	phylumTargetCandidate = "candidate"
+
+	defaultCutoverTenantClaim = "tenant"
+)
+
+var phylumCallsTotal = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "phylum_calls_total",
+		Help: "How many phylum calls were made, partitioned by target phylum (primary or candidate).",
+	},
+	[]string{"target"},
+)
+
+// PhylumCutover routes a share of phylum calls to a candidate phylum, served
+// by a separate shiroclient gateway, for blue/green rollouts of new phylum
+// versions.  A call is routed to the candidate if any of the rules match,
+// all other calls are routed to the primary phylum at GatewayEndpoint.
+type PhylumCutover struct {
+	// CandidateEndpoint is the shiroclient gateway of the candidate phylum.
+	// Cutover is disabled if the endpoint is empty.
+	CandidateEndpoint string `yaml:"candidate-endpoint"`
+	// Header routes requests carrying the request header to the
+	// candidate, e.g. "X-Phylum-Candidate".  Clients may set arbitrary
+	// headers, so the header is only honoured on requests made by
+	// TrustedProxies and is removed from other requests.
+	Header string `yaml:"header"`
+	// HeaderValue is the header value routing requests to the candidate.
+	// If empty any non-empty value routes requests to the candidate.
+	HeaderValue string `yaml:"header-value"`
+	// Percentage is the percentage of requests, between 0 and 100, routed
+	// to the candidate.  Requests are assigned by request ID, so all calls
+	// of a request are routed to the same phylum.
+	Percentage float64 `yaml:"percentage"`
+	// Tenants routes requests of the tenants to the candidate.  The tenant
+	// of a request is the TenantClaim claim of the user, see GetClaims.
+	Tenants []string `yaml:"tenants"`
+	// TenantClaim is the user claim holding the tenant of a request.
+	// Defaults to "tenant".
+	TenantClaim string `yaml:"tenant-claim"`
+}
+
+func (c PhylumCutover) tenantClaim() string {
+	if c.TenantClaim == "" {
+		return defaultCutoverTenantClaim
+	}
+	return c.TenantClaim
+}
+
+// enabled returns true if a candidate phylum is configured.
+func (c PhylumCutover) enabled() bool {
+	return c.CandidateEndpoint != ""
+}
+
+// valid validates the cutover configuration.
+func (c PhylumCutover) valid() error {
+	if !c.enabled() {
+		if c.Header != "" || c.Percentage != 0 || len(c.Tenants) > 0 || c.TenantClaim != "" {
+			return fmt.Errorf("phylum cutover: missing candidate endpoint")
+		}
+		return nil
+	}
+	if c.Percentage < 0 || c.Percentage > 100 {
+		return fmt.Errorf("phylum cutover: percentage must be between 0 and 100")
+	}
+	if c.HeaderValue != "" && c.Header == "" {
+		return fmt.Errorf("phylum cutover: header value without header")
+	}
+	if strings.ContainsAny(c.Header, " \t\r\n:") {
+		return fmt.Errorf("phylum cutover: invalid header %q", c.Header)
+	}
+	for _, t := range c.Tenants {
+		if t == "" {
+			return fmt.Errorf("phylum cutover: empty tenant")
+		}
+	}
+	return nil
+}
+
+// validPhylumCutover validates the cutover configuration.
+func (c *Config) validPhylumCutover() error {
+	if err := c.PhylumCutover.valid(); err != nil {
+		return err
+	}
+	if c.PhylumCutover.enabled() && c.EmulateCC {
+		return fmt.Errorf("phylum cutover: not supported with emulated chaincode")
+	}
+	return nil
+}
+
+// cutoverHeaders returns the request headers used to route phylum calls.
+func (c *Config) cutoverHeaders() []string {
+	if !c.PhylumCutover.enabled() || c.PhylumCutover.Header == "" {
+		return nil
+	}
+	return []string{c.PhylumCutover.Header}
+}
+
+// withCandidatePhylum connects to the candidate shiroclient gateway.
+func withCandidatePhylum(gatewayEndpoint string) option {
+	return func(orc *Oracle) error {
+		ph, err := phylum.New(gatewayEndpoint, orc.logBase)
+		if err != nil {
+			return fmt.Errorf("unable to initialize candidate phylum: %w", err)
+		}
+		ph.GetLogMetadata = grpclogging.GetLogrusFields
+		orc.candidate = ph
+		return nil
+	}
+}
+
+// routeCandidate returns true if the phylum calls of the request must be
+// routed to the candidate phylum.
+func (orc *Oracle) routeCandidate(ctx context.Context) bool {
+	c := orc.cfg.PhylumCutover
+	if orc.candidate == nil {
+		return false
+	}
+	if c.Header != "" {
+		md, _ := metadata.FromIncomingContext(ctx)
+		vals := md.Get(strings.ToLower(c.Header))
+		if len(vals) > 0 && vals[0] != "" && (c.HeaderValue == "" || vals[0] == c.HeaderValue) {
+			return true
+		}
+	}
+	if len(c.Tenants) > 0 {
+		claims, err := orc.GetClaims(ctx)
+		if err != nil && !errors.Is(err, ErrClaimsNotConfigured) {
+			orc.log(ctx).WithError(err).Debugf("cutover claims unavailable")
+		}
+		tenant, _ := claims[c.tenantClaim()].(string)
+		if tenant != "" && slices.Contains(c.Tenants, tenant) {
+			return true
+		}
+	}
+	return c.Percentage > 0 && requestBucket(ctx) < c.Percentage
+}
+
+// cutoverMiddleware removes the cutover header from requests not made by a
+// trusted proxy, so clients cannot route their requests to the candidate.
+func (orc *Oracle) cutoverMiddleware(next http.Handler) http.Handler {
+	header := orc.cfg.PhylumCutover.Header
+	if orc.candidate == nil || header == "" {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(header) != "" && !midware.FromTrustedProxy(r) {
+			r = r.Clone(r.Context())
+			r.Header.Del(header)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requestBucket returns a number in [0, 100) derived from the request ID,
+// or a random number for requests without an ID.
+func requestBucket(ctx context.Context) float64 {
+	reqID := grpclogging.ReqID(ctx)
+	if reqID == "" {
+		return rand.Float64() * 100
+	}
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(reqID))
+	return float64(h.Sum32()%10000) / 100
+}
+
+// routePhylum returns the phylum serving a call and whether it is the
+// candidate.  The target is recorded on the request span and log.
+func (orc *Oracle) routePhylum(ctx context.Context) (*phylum.Client, bool) {
+	if orc.candidate == nil {
+		return orc.phylum, false
+	}
+	ph, candidate, target := orc.phylum, false, phylumTargetPrimary
+	if orc.routeCandidate(ctx) {
+		ph, candidate, target = orc.candidate, true, phylumTargetCandidate
+	}
+	trace.SpanFromContext(ctx).SetAttributes(AttrPhylumTarget.String(target))
+	grpclogging.AddLogrusFields(ctx, logrus.Fields{"phylum_target": target})
+	phylumCallsTotal.WithLabelValues(target).Inc()
+	return ph, candidate
+}
+
+// candidateHealthReporter reports the health of the candidate phylum.
+func (orc *Oracle) candidateHealthReporter() HealthReporter {
+	return func(ctx context.Context) *healthcheck.HealthCheckReport {
+		resp, err := orc.candidate.GetHealthCheck(ctx, []string{"phylum"}, orc.txConfigs(ctx)...)
+		if err != nil {
+			return nil
+		}
+		report := &healthcheck.HealthCheckReport{Status: "UP"}
+		for _, r := range resp.GetReports() {
+			if strings.EqualFold(r.GetServiceName(), orc.cfg.PhylumServiceName) {
+				report.ServiceVersion = r.GetServiceVersion()
+			}
+			if !strings.EqualFold(r.GetStatus(), "UP") {
+				report.Status = "DOWN"
+			}
+		}
+		return report
+	}
+}
@@ -0,0 +1,116 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package oracle
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/luthersystems/svc/grpclogging"
+	"github.com/luthersystems/svc/opttrace"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestPhylumCutoverValid(t *testing.T) {
+	require.NoError(t, PhylumCutover{}.valid())
+	require.Error(t, PhylumCutover{Percentage: 10}.valid())
+	require.NoError(t, PhylumCutover{CandidateEndpoint: "http://candidate:8082", Percentage: 10}.valid())
+	require.Error(t, PhylumCutover{CandidateEndpoint: "http://candidate:8082", Percentage: 101}.valid())
+	require.Error(t, PhylumCutover{CandidateEndpoint: "http://candidate:8082", HeaderValue: "1"}.valid())
+	require.Error(t, PhylumCutover{CandidateEndpoint: "http://candidate:8082", Header: "X Candidate"}.valid())
+	require.Error(t, PhylumCutover{CandidateEndpoint: "http://candidate:8082", Tenants: []string{""}}.valid())
+
+	cfg := DefaultConfig()
+	cfg.PhylumCutover = PhylumCutover{CandidateEndpoint: "http://candidate:8082", Header: "X-Phylum-Candidate"}
+	require.NoError(t, cfg.Valid())
+	require.Contains(t, cfg.requiredForwardedHeaders(), "X-Phylum-Candidate")
+	cfg.EmulateCC = true
+	require.Error(t, cfg.Valid())
+}
+
+func testCutoverOracle(t *testing.T, cutover PhylumCutover) *Oracle {
+	cfg := DefaultConfig()
+	cfg.PhylumCutover = cutover
+	cfg.SetClaimsGetter(func(ctx context.Context) (map[string]interface{}, error) {
+		tenant, _ := ctx.Value(testTenantKey{}).(string)
+		return map[string]interface{}{"tenant": tenant}, nil
+	})
+	return newTestOracle(t, cfg)
+}
+
+type testTenantKey struct{}
+
+func TestRouteCandidate(t *testing.T) {
+	ctx := context.Background()
+	orc := newTestOracle(t, DefaultConfig())
+	require.False(t, orc.routeCandidate(ctx))
+
+	orc = testCutoverOracle(t, PhylumCutover{
+		CandidateEndpoint: "http://candidate:8082",
+		Header:            "X-Phylum-Candidate",
+		HeaderValue:       "true",
+		Tenants:           []string{"acme"},
+	})
+	require.False(t, orc.routeCandidate(ctx))
+	require.True(t, orc.routeCandidate(metadata.NewIncomingContext(ctx, metadata.Pairs("x-phylum-candidate", "true"))))
+	require.False(t, orc.routeCandidate(metadata.NewIncomingContext(ctx, metadata.Pairs("x-phylum-candidate", "false"))))
+
+	require.True(t, orc.routeCandidate(context.WithValue(ctx, testTenantKey{}, "acme")))
+	require.False(t, orc.routeCandidate(context.WithValue(ctx, testTenantKey{}, "globex")))
+	// Tenant baggage set by clients is ignored.
+	tenantCtx, err := opttrace.WithBaggage(ctx, opttrace.BaggageTenant, "acme")
+	require.NoError(t, err)
+	require.False(t, orc.routeCandidate(tenantCtx))
+
+	ph, candidate := orc.routePhylum(ctx)
+	require.False(t, candidate)
+	require.Equal(t, orc.phylum, ph)
+	ph, candidate = orc.routePhylum(metadata.NewIncomingContext(ctx, metadata.Pairs("x-phylum-candidate", "true")))
+	require.True(t, candidate)
+	require.Equal(t, orc.candidate, ph)
+}
+
+func TestCutoverMiddleware(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.TrustedProxies = []string{"10.0.0.0/8"}
+	cfg.PhylumCutover = PhylumCutover{CandidateEndpoint: "http://candidate:8082", Header: "X-Phylum-Candidate"}
+	orc := newTestOracle(t, cfg)
+	var got string
+	h := orc.trustedProxies().Wrap(orc.cutoverMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Get("X-Phylum-Candidate")
+	})))
+	for addr, want := range map[string]string{
+		"10.1.2.3:1234":    "true",
+		"203.0.113.9:1234": "",
+	} {
+		r := httptest.NewRequest(http.MethodGet, "/v1/x", nil)
+		r.RemoteAddr = addr
+		r.Header.Set("X-Phylum-Candidate", "true")
+		h.ServeHTTP(httptest.NewRecorder(), r)
+		require.Equal(t, want, got, addr)
+	}
+}
+
+func TestRouteCandidatePercentage(t *testing.T) {
+	orc := testCutoverOracle(t, PhylumCutover{
+		CandidateEndpoint: "http://candidate:8082",
+		Percentage:        25,
+	})
+	var routed int
+	for i := 0; i < 1000; i++ {
+		ctx := grpclogging.NewContext(context.Background())
+		grpclogging.AddLogrusFields(ctx, logrus.Fields{"req_id": fmt.Sprintf("req-%d", i)})
+		first := orc.routeCandidate(ctx)
+		// all calls of a request are routed to the same phylum.
+		require.Equal(t, first, orc.routeCandidate(ctx))
+		if first {
+			routed++
+		}
+	}
+	require.InDelta(t, 250, routed, 60)
+}
@@ -0,0 +1,266 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package oracle
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+)
+
+// HeaderDirection controls which way a forwarded header is passed through the
+// grpc-gateway.
+type HeaderDirection string
+
+const (
+	// HeaderDirectionRequest forwards an HTTP request header to the oracle
+	// grpc server as request metadata.
+	HeaderDirectionRequest HeaderDirection = "request"
+	// HeaderDirectionResponse forwards grpc header metadata set by the oracle
+	// to the HTTP response as a header of the same name.
+	HeaderDirectionResponse HeaderDirection = "response"
+	// HeaderDirectionBoth forwards a header in both directions.
+	HeaderDirectionBoth HeaderDirection = "both"
+)
+
+// hopByHopHeaders are connection specific headers (RFC 9110 section 7.6.1)
+// which must not be forwarded.
+var hopByHopHeaders = map[string]bool{
+	"Connection":          true,
+	"Keep-Alive":          true,
+	"Proxy-Authenticate":  true,
+	"Proxy-Authorization": true,
+	"Proxy-Connection":    true,
+	"Te":                  true,
+	"Trailer":             true,
+	"Transfer-Encoding":   true,
+	"Upgrade":             true,
+}
+
+// ForwardedHeader is an additional header forwarded by the grpc-gateway.
+type ForwardedHeader struct {
+	// Name is the header name.
+	Name string `yaml:"name"`
+	// Direction is the direction the header is forwarded.  If empty the
+	// header is forwarded from requests only.
+	Direction HeaderDirection `yaml:"direction"`
+}
+
+func (h ForwardedHeader) valid() error {
+	if h.Name == "" || strings.ContainsAny(h.Name, " \t\r\n:") {
+		return fmt.Errorf("forwarded header: invalid name %q", h.Name)
+	}
+	if hopByHopHeaders[http.CanonicalHeaderKey(h.Name)] {
+		return fmt.Errorf("forwarded header %s: hop-by-hop header", h.Name)
+	}
+	switch h.Direction {
+	case "", HeaderDirectionRequest, HeaderDirectionResponse, HeaderDirectionBoth:
+	default:
+		return fmt.Errorf("forwarded header %s: invalid direction %q", h.Name, h.Direction)
+	}
+	return nil
+}
+
+func (h ForwardedHeader) request() bool {
+	return h.Direction != HeaderDirectionResponse
+}
+
+func (h ForwardedHeader) response() bool {
+	return h.Direction == HeaderDirectionResponse || h.Direction == HeaderDirectionBoth
+}
+
+// ForwardHeaderOption configures a forwarded header.
+type ForwardHeaderOption func(*ForwardedHeader)
+
+// WithHeaderDirection sets the direction a header is forwarded.
+func WithHeaderDirection(d HeaderDirection) ForwardHeaderOption {
+	return func(h *ForwardedHeader) {
+		h.Direction = d
+	}
+}
+
+// ForwardHeader configures the grpc-gateway to forward the named header, in
+// addition to the headers the oracle always forwards.  The header is
+// validated by Valid.
+func (c *Config) ForwardHeader(name string, opts ...ForwardHeaderOption) {
+	if c == nil {
+		return
+	}
+	h := ForwardedHeader{Name: name, Direction: HeaderDirectionRequest}
+	for _, opt := range opts {
+		opt(&h)
+	}
+	c.ForwardedHeaders = append(c.ForwardedHeaders, h)
+}
+
+// validForwardedHeaders validates the forwarded header configuration.
+func (c *Config) validForwardedHeaders() error {
+	for _, h := range c.ForwardedHeaders {
+		if err := h.valid(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// forwardedRequestHeaders returns the configured headers forwarded from
+// requests.
+func (c *Config) forwardedRequestHeaders() []string {
+	var headers []string
+	for _, h := range c.ForwardedHeaders {
+		if h.request() {
+			headers = append(headers, h.Name)
+		}
+	}
+	return headers
+}
+
+// outgoingHeaderMatcher maps grpc header metadata to HTTP response headers.
+// Configured response headers are passed through as is, other metadata is
+// prefixed as by the grpc-gateway default.
+func (orc *Oracle) outgoingHeaderMatcher(key string) (string, bool) {
+	for _, h := range orc.cfg.ForwardedHeaders {
+		if h.response() && strings.EqualFold(key, h.Name) {
+			return http.CanonicalHeaderKey(h.Name), true
+		}
+	}
+	return runtime.MetadataHeaderPrefix + key, true
+}
+
+// HeaderForwardingPolicy restricts, renames and limits the size of request
+// headers forwarded by the grpc-gateway as grpc metadata.  Headers the oracle
+// depends on, the request ID, API key and transient field headers, are always
+// forwarded under their own name.
+type HeaderForwardingPolicy struct {
+	// Allow, if not empty, restricts the other forwarded headers to those
+	// listed.
+	Allow []string `yaml:"allow"`
+	// Deny lists headers which are never forwarded, e.g. "Cookie".
+	Deny []string `yaml:"deny"`
+	// Rename maps header names to the metadata keys they are forwarded as.
+	Rename map[string]string `yaml:"rename"`
+	// MaxSize is the maximum total size in bytes of the values of a
+	// forwarded header.  Larger headers are dropped, to protect the grpc
+	// metadata size budget.  Zero means no limit.
+	MaxSize int `yaml:"max-size"`
+	// MaxSizes overrides MaxSize for individual headers.
+	MaxSizes map[string]int `yaml:"max-sizes"`
+}
+
+// containsHeader returns true if headers contains name, ignoring case.
+func containsHeader(headers []string, name string) bool {
+	for _, h := range headers {
+		if strings.EqualFold(h, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// allowed returns true if the policy permits forwarding the header.
+func (p HeaderForwardingPolicy) allowed(name string) bool {
+	if containsHeader(p.Deny, name) {
+		return false
+	}
+	return len(p.Allow) == 0 || containsHeader(p.Allow, name)
+}
+
+// metadataKey returns the metadata key a header is forwarded as.
+func (p HeaderForwardingPolicy) metadataKey(name string) string {
+	for h, key := range p.Rename {
+		if strings.EqualFold(h, name) {
+			return key
+		}
+	}
+	return name
+}
+
+// maxSize returns the size limit of a header, or zero.
+func (p HeaderForwardingPolicy) maxSize(name string) int {
+	for h, size := range p.MaxSizes {
+		if strings.EqualFold(h, name) {
+			return size
+		}
+	}
+	return p.MaxSize
+}
+
+// oversize returns true if the values of a header exceed its size limit.
+func (p HeaderForwardingPolicy) oversize(name string, vals []string) bool {
+	limit := p.maxSize(name)
+	if limit <= 0 {
+		return false
+	}
+	size := 0
+	for _, v := range vals {
+		size += len(v)
+	}
+	return size > limit
+}
+
+// validHeaderForwarding validates the header forwarding policy.
+func (c *Config) validHeaderForwarding() error {
+	p := c.HeaderForwarding
+	if p.MaxSize < 0 {
+		return fmt.Errorf("header forwarding: negative max size")
+	}
+	for h, size := range p.MaxSizes {
+		if size < 0 {
+			return fmt.Errorf("header forwarding %s: negative max size", h)
+		}
+	}
+	required := c.requiredForwardedHeaders()
+	for h, key := range p.Rename {
+		if key == "" || strings.ContainsAny(key, " \t\r\n:") {
+			return fmt.Errorf("header forwarding %s: invalid metadata key %q", h, key)
+		}
+		if containsHeader(required, h) {
+			return fmt.Errorf("header forwarding %s: required header cannot be renamed", h)
+		}
+	}
+	for _, h := range p.Deny {
+		if containsHeader(required, h) {
+			return fmt.Errorf("header forwarding %s: required header cannot be denied", h)
+		}
+	}
+	return nil
+}
+
+// requiredForwardedHeaders returns the headers the oracle depends on, which
+// are forwarded regardless of the header forwarding policy.
+func (c *Config) requiredForwardedHeaders() []string {
+	headers := []string{c.RequestIDHeader}
+	if c.apiKeysEnabled() {
+		headers = append(headers, c.APIKeyHeader)
+	}
+	headers = append(headers, c.cutoverHeaders()...)
+	headers = append(headers, c.localeHeaders()...)
+	return append(headers, c.transientHeaders()...)
+}
+
+// limitForwardedHeaders drops request headers which exceed their forwarding
+// size limit before the request reaches the grpc-gateway.
+func (orc *Oracle) limitForwardedHeaders(next http.Handler) http.Handler {
+	policy := orc.cfg.HeaderForwarding
+	if policy.MaxSize == 0 && len(policy.MaxSizes) == 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var dropped []string
+		for _, h := range orc.gatewayForwardedHeaders() {
+			if vals := r.Header.Values(h); policy.oversize(h, vals) {
+				dropped = append(dropped, h)
+			}
+		}
+		if len(dropped) > 0 {
+			r = r.Clone(r.Context())
+			for _, h := range dropped {
+				r.Header.Del(h)
+			}
+			orc.log(r.Context()).WithField("headers", dropped).Warnf("dropped oversize forwarded headers")
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,109 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package oracle
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestForwardHeader(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.ForwardHeader("X-Tenant-ID")
+	cfg.ForwardHeader("X-Rate-Limit-Remaining", WithHeaderDirection(HeaderDirectionResponse))
+	cfg.ForwardHeader("X-Correlation", WithHeaderDirection(HeaderDirectionBoth))
+	require.NoError(t, cfg.validForwardedHeaders())
+
+	orc := newTestOracle(t, cfg)
+	for _, h := range []string{"x-tenant-id", "X-Correlation"} {
+		_, ok := orc.incomingHeaderMatcher(h)
+		require.True(t, ok, h)
+	}
+	_, ok := orc.incomingHeaderMatcher("X-Rate-Limit-Remaining")
+	require.False(t, ok)
+
+	name, ok := orc.outgoingHeaderMatcher("x-rate-limit-remaining")
+	require.True(t, ok)
+	require.Equal(t, "X-Rate-Limit-Remaining", name)
+	name, ok = orc.outgoingHeaderMatcher("x-tenant-id")
+	require.True(t, ok)
+	require.Equal(t, "Grpc-Metadata-x-tenant-id", name)
+}
+
+func TestForwardHeaderInvalid(t *testing.T) {
+	for _, h := range []ForwardedHeader{
+		{Name: ""},
+		{Name: "X Bad"},
+		{Name: "connection"},
+		{Name: "Transfer-Encoding"},
+		{Name: "X-Ok", Direction: "sideways"},
+	} {
+		cfg := DefaultConfig()
+		cfg.ForwardedHeaders = []ForwardedHeader{h}
+		require.Error(t, cfg.validForwardedHeaders(), h.Name)
+	}
+}
+
+func TestHeaderForwardingPolicy(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.ForwardHeader("X-Tenant-ID")
+	cfg.HeaderForwarding = HeaderForwardingPolicy{
+		Deny:     []string{"cookie"},
+		Rename:   map[string]string{"X-Tenant-ID": "tenant"},
+		MaxSize:  16,
+		MaxSizes: map[string]int{"User-Agent": 4},
+	}
+	require.NoError(t, cfg.Valid())
+	orc := newTestOracle(t, cfg)
+
+	_, ok := orc.incomingHeaderMatcher("Cookie")
+	require.False(t, ok)
+	key, ok := orc.incomingHeaderMatcher("X-Tenant-ID")
+	require.True(t, ok)
+	require.Equal(t, "tenant", key)
+	key, ok = orc.incomingHeaderMatcher("X-Request-ID")
+	require.True(t, ok)
+	require.Equal(t, "X-Request-ID", key)
+
+	var got http.Header
+	h := orc.limitForwardedHeaders(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header
+	}))
+	r := httptest.NewRequest(http.MethodGet, "/v1/x", nil)
+	r.Header.Set("User-Agent", "curl/8.0")
+	r.Header.Set("Referer", "https://example.com/a/very/long/path")
+	r.Header.Set("X-Tenant-ID", "t1")
+	h.ServeHTTP(httptest.NewRecorder(), r)
+	require.Empty(t, got.Get("User-Agent"))
+	require.Empty(t, got.Get("Referer"))
+	require.Equal(t, "t1", got.Get("X-Tenant-ID"))
+
+	md, _ := metadata.FromIncomingContext(orc.httpMetadataContext(r))
+	require.Equal(t, []string{"t1"}, md.Get("tenant"))
+	require.Empty(t, md.Get("user-agent"))
+
+	cfg.HeaderForwarding.Allow = []string{"X-Tenant-ID"}
+	orc = newTestOracle(t, cfg)
+	_, ok = orc.incomingHeaderMatcher("Referer")
+	require.False(t, ok)
+	_, ok = orc.incomingHeaderMatcher("X-Tenant-ID")
+	require.True(t, ok)
+}
+
+func TestHeaderForwardingPolicyInvalid(t *testing.T) {
+	for name, p := range map[string]HeaderForwardingPolicy{
+		"deny request id":   {Deny: []string{"x-request-id"}},
+		"rename request id": {Rename: map[string]string{"X-Request-ID": "rid"}},
+		"empty key":         {Rename: map[string]string{"X-Tenant-ID": ""}},
+		"negative size":     {MaxSize: -1},
+		"negative sizes":    {MaxSizes: map[string]int{"Cookie": -1}},
+	} {
+		cfg := DefaultConfig()
+		cfg.HeaderForwarding = p
+		require.Error(t, cfg.validHeaderForwarding(), name)
+	}
+}
@@ -0,0 +1,49 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package oracle
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// ForwardResponseHook is called by the grpc-gateway with each successful
+// response message before it is written, and may set headers and cookies on
+// w or mutate resp.  The response body must not be written.  If the hook
+// returns an error the request fails and the error is handled like an error
+// returned by the oracle service.
+type ForwardResponseHook func(ctx context.Context, w http.ResponseWriter, resp proto.Message) error
+
+// AddForwardResponseHook adds a hook called on successful gateway responses.
+// Hooks are called in the order they are added.  The first hook to return an
+// error or panic fails the request, and later hooks are not called.
+func (c *Config) AddForwardResponseHook(fn ForwardResponseHook) {
+	if c == nil {
+		return
+	}
+	c.forwardResponseHooks = append(c.forwardResponseHooks, fn)
+}
+
+// forwardResponse calls the configured forward response hooks in order.
+func (orc *Oracle) forwardResponse(ctx context.Context, w http.ResponseWriter, resp proto.Message) error {
+	for i, hook := range orc.cfg.forwardResponseHooks {
+		if err := callForwardResponseHook(ctx, i, hook, w, resp); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// callForwardResponseHook calls hook, converting a panic into an error.
+func callForwardResponseHook(ctx context.Context, i int, hook ForwardResponseHook, w http.ResponseWriter, resp proto.Message) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("forward response hook %d panic: %v\n%s", i, r, debug.Stack())
+		}
+	}()
+	return hook(ctx, w, resp)
+}
@@ -0,0 +1,64 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package oracle
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestForwardResponseHooks(t *testing.T) {
+	cfg := DefaultConfig()
+	var calls []int
+	cfg.AddForwardResponseHook(func(ctx context.Context, w http.ResponseWriter, resp proto.Message) error {
+		calls = append(calls, 1)
+		w.Header().Set("X-Hook", "1")
+		resp.(*wrapperspb.StringValue).Value = "changed"
+		return nil
+	})
+	cfg.AddForwardResponseHook(func(ctx context.Context, w http.ResponseWriter, resp proto.Message) error {
+		calls = append(calls, 2)
+		return nil
+	})
+	orc := newTestOracle(t, cfg)
+
+	w := httptest.NewRecorder()
+	resp := wrapperspb.String("original")
+	require.NoError(t, orc.forwardResponse(context.Background(), w, resp))
+	require.Equal(t, []int{1, 2}, calls)
+	require.Equal(t, "1", w.Header().Get("X-Hook"))
+	require.Equal(t, "changed", resp.GetValue())
+}
+
+func TestForwardResponseHookFailure(t *testing.T) {
+	errHook := errors.New("hook failed")
+	for name, hook := range map[string]ForwardResponseHook{
+		"error": func(ctx context.Context, w http.ResponseWriter, resp proto.Message) error {
+			return errHook
+		},
+		"panic": func(ctx context.Context, w http.ResponseWriter, resp proto.Message) error {
+			panic("boom")
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			cfg := DefaultConfig()
+			cfg.AddForwardResponseHook(hook)
+			called := false
+			cfg.AddForwardResponseHook(func(ctx context.Context, w http.ResponseWriter, resp proto.Message) error {
+				called = true
+				return nil
+			})
+			orc := newTestOracle(t, cfg)
+			err := orc.forwardResponse(context.Background(), httptest.NewRecorder(), wrapperspb.String(""))
+			require.Error(t, err)
+			require.False(t, called)
+		})
+	}
+}
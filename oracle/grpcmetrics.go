@@ -0,0 +1,88 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package oracle
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	grpc_prometheus "github.com/grpc-ecosystem/go-grpc-prometheus"
+	"github.com/luthersystems/svc/promreg"
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc"
+)
+
+var (
+	// grpc_prometheus metrics are global, so histograms may only be enabled
+	// once per process.
+	grpcClientHistogramOnce sync.Once
+	grpcServerHistogramOnce sync.Once
+)
+
+// defaultGRPCHistogramBuckets are the default buckets of grpc_prometheus
+// handling time histograms.
+func defaultGRPCHistogramBuckets() []float64 {
+	return prometheus.ExponentialBuckets(0.05, 1.25, 25)
+}
+
+// validGRPCMetrics validates the grpc metrics configuration.
+func (c *Config) validGRPCMetrics() error {
+	if len(c.GRPCHistogramBuckets) > 0 && !sort.Float64sAreSorted(c.GRPCHistogramBuckets) {
+		return fmt.Errorf("grpc histogram buckets must be sorted")
+	}
+	return nil
+}
+
+func (c *Config) grpcHistogramBuckets() []float64 {
+	if len(c.GRPCHistogramBuckets) > 0 {
+		return c.GRPCHistogramBuckets
+	}
+	return defaultGRPCHistogramBuckets()
+}
+
+// enableGRPCMetrics enables grpc_prometheus handling time histograms on the
+// gateway client and, if configured, on the oracle grpc server.  Only the
+// buckets of the first oracle started in a process take effect.
+func (orc *Oracle) enableGRPCMetrics() {
+	buckets := grpc_prometheus.WithHistogramBuckets(orc.cfg.grpcHistogramBuckets())
+	grpcClientHistogramOnce.Do(func() {
+		// Provider per endpoint histograms (at expense of memory/performance).
+		grpc_prometheus.EnableClientHandlingTimeHistogram(buckets)
+	})
+	if orc.cfg.GRPCServerMetrics {
+		grpcServerHistogramOnce.Do(func() {
+			grpc_prometheus.EnableHandlingTimeHistogram(buckets)
+		})
+	}
+	// grpc_prometheus registers its metrics with the default registerer.
+	if orc.cfg.MetricsRegisterer != nil {
+		reg := orc.cfg.metricsRegisterer()
+		collectors := []prometheus.Collector{grpc_prometheus.DefaultClientMetrics}
+		if orc.cfg.GRPCServerMetrics {
+			collectors = append(collectors, grpc_prometheus.DefaultServerMetrics)
+		}
+		for _, c := range collectors {
+			if _, err := promreg.Register(reg, c); err != nil {
+				orc.logBase.WithError(err).Warnf("unable to register grpc metrics")
+			}
+		}
+	}
+}
+
+// grpcServerInterceptors returns the grpc_prometheus server interceptors, if
+// configured.
+func (orc *Oracle) grpcServerInterceptors() []grpc.UnaryServerInterceptor {
+	if !orc.cfg.GRPCServerMetrics {
+		return nil
+	}
+	return []grpc.UnaryServerInterceptor{grpc_prometheus.UnaryServerInterceptor}
+}
+
+// registerGRPCServerMetrics initializes server metrics for all methods of
+// grpcServer, so they are reported before the first call.
+func (orc *Oracle) registerGRPCServerMetrics(grpcServer *grpc.Server) {
+	if orc.cfg.GRPCServerMetrics {
+		grpc_prometheus.Register(grpcServer)
+	}
+}
@@ -0,0 +1,53 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package oracle
+
+import (
+	"fmt"
+
+	grpcmiddleware "github.com/grpc-ecosystem/go-grpc-middleware"
+	grpc_prometheus "github.com/grpc-ecosystem/go-grpc-prometheus"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// validGRPCServer validates the gRPC server configuration.
+func (c *Config) validGRPCServer() error {
+	if c.GRPCMaxMessageSize < 0 {
+		return fmt.Errorf("invalid grpc max message size")
+	}
+	return nil
+}
+
+// grpcServerOptions returns the options of the internal gRPC server.  The
+// configured GRPCServerOptions are applied last, so they override the
+// limits set here; see GRPCServerOptions for interceptors.
+func (orc *Oracle) grpcServerOptions() []grpc.ServerOption {
+	opts := []grpc.ServerOption{
+		grpc.StatsHandler(otelgrpc.NewServerHandler()),
+		grpc.UnaryInterceptor(grpcmiddleware.ChainUnaryServer(orc.unaryInterceptors()...)),
+	}
+	if size := orc.cfg.GRPCMaxMessageSize; size > 0 {
+		opts = append(opts, grpc.MaxRecvMsgSize(size), grpc.MaxSendMsgSize(size))
+	}
+	return append(opts, orc.cfg.GRPCServerOptions...)
+}
+
+// grpcDialOptions returns the options of the gateway client connection to
+// the internal gRPC server.  The client limits match the server, so large
+// messages are accepted on both ends.
+func (orc *Oracle) grpcDialOptions() []grpc.DialOption {
+	opts := []grpc.DialOption{
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithUnaryInterceptor(grpcmiddleware.ChainUnaryClient(
+			grpc_prometheus.UnaryClientInterceptor,
+			orc.compressionClientInterceptor())),
+	}
+	if size := orc.cfg.GRPCMaxMessageSize; size > 0 {
+		opts = append(opts, grpc.WithDefaultCallOptions(
+			grpc.MaxCallRecvMsgSize(size),
+			grpc.MaxCallSendMsgSize(size)))
+	}
+	return opts
+}
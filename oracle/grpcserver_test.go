@@ -0,0 +1,37 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package oracle
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/keepalive"
+)
+
+func TestGRPCServerOptions(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.GRPCMaxMessageSize = -1
+	require.Error(t, cfg.validGRPCServer())
+
+	cfg = DefaultConfig()
+	cfg.MetricsRegisterer = prometheus.NewRegistry()
+	orc := newTestOracle(t, cfg)
+	defaultServer := len(orc.grpcServerOptions())
+	defaultDial := len(orc.grpcDialOptions())
+
+	cfg.GRPCMaxMessageSize = 64 << 20
+	policy := grpc.KeepaliveEnforcementPolicy(keepalive.EnforcementPolicy{MinTime: time.Minute})
+	cfg.GRPCServerOptions = []grpc.ServerOption{policy}
+	require.NoError(t, cfg.validGRPCServer())
+	orc = newTestOracle(t, cfg)
+	opts := orc.grpcServerOptions()
+	// The size limits apply to both directions, and configured options are
+	// applied last.
+	require.Len(t, opts, defaultServer+3)
+	require.Equal(t, policy, opts[len(opts)-1])
+	require.Len(t, orc.grpcDialOptions(), defaultDial+1)
+}
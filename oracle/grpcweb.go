@@ -0,0 +1,244 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package oracle
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	// protobufContentType is the content type of binary protobuf gateway
+	// requests and responses.
+	protobufContentType = "application/x-protobuf"
+
+	grpcWebContentType     = "application/grpc-web"
+	grpcWebTextContentType = "application/grpc-web-text"
+
+	grpcWebMaxMessageLen = 4 << 20
+	grpcWebFrameData     = 0x00
+	grpcWebFrameTrailer  = 0x80
+)
+
+var errGRPCWebCompressed = errors.New("compressed messages are not supported")
+
+// rawCodec passes through encoded protobuf messages, so the grpc-web handler
+// can proxy any method without knowing its message types.
+type rawCodec struct{}
+
+func (rawCodec) Marshal(v interface{}) ([]byte, error) {
+	b, ok := v.(*[]byte)
+	if !ok {
+		return nil, fmt.Errorf("raw codec: unexpected type %T", v)
+	}
+	return *b, nil
+}
+
+func (rawCodec) Unmarshal(data []byte, v interface{}) error {
+	b, ok := v.(*[]byte)
+	if !ok {
+		return fmt.Errorf("raw codec: unexpected type %T", v)
+	}
+	*b = append([]byte(nil), data...)
+	return nil
+}
+
+func (rawCodec) Name() string {
+	return "proto"
+}
+
+// protobufMarshalerOptions registers the binary protobuf marshaler, when
+// enabled.
+func (orc *Oracle) protobufMarshalerOptions() []runtime.ServeMuxOption {
+	if !orc.cfg.GatewayProtobuf {
+		return nil
+	}
+	return []runtime.ServeMuxOption{
+		runtime.WithMarshalerOption(protobufContentType, &runtime.ProtoMarshaller{}),
+	}
+}
+
+func isGRPCWebRequest(r *http.Request) bool {
+	return r.Method == http.MethodPost && strings.HasPrefix(r.Header.Get("Content-Type"), grpcWebContentType)
+}
+
+// grpcWebMiddleware serves unary grpc-web requests by proxying them to the
+// oracle grpc server over conn.  Other requests are passed to next.
+func (orc *Oracle) grpcWebMiddleware(conn grpc.ClientConnInterface) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !isGRPCWebRequest(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			orc.serveGRPCWeb(conn, w, r)
+		})
+	}
+}
+
+// readGRPCWebFrame reads a single uncompressed data frame.
+func readGRPCWebFrame(r io.Reader) ([]byte, error) {
+	var hdr [5]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return nil, fmt.Errorf("read frame header: %w", err)
+	}
+	if hdr[0] != grpcWebFrameData {
+		return nil, errGRPCWebCompressed
+	}
+	n := binary.BigEndian.Uint32(hdr[1:])
+	if n > grpcWebMaxMessageLen {
+		return nil, fmt.Errorf("message too large: %d", n)
+	}
+	msg := make([]byte, n)
+	if _, err := io.ReadFull(r, msg); err != nil {
+		return nil, fmt.Errorf("read frame: %w", err)
+	}
+	return msg, nil
+}
+
+func writeGRPCWebFrame(buf *bytes.Buffer, flag byte, b []byte) {
+	var hdr [5]byte
+	hdr[0] = flag
+	binary.BigEndian.PutUint32(hdr[1:], uint32(len(b)))
+	buf.Write(hdr[:])
+	buf.Write(b)
+}
+
+// grpcPercentEncode encodes a grpc-message as required by the grpc protocol.
+func grpcPercentEncode(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c < 0x20 || c > 0x7e || c == '%' {
+			fmt.Fprintf(&b, "%%%02X", c)
+			continue
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
+
+// grpcTimeout parses a grpc-timeout header value.
+func grpcTimeout(v string) (time.Duration, bool) {
+	if len(v) < 2 {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(v[:len(v)-1], 10, 64)
+	if err != nil || n < 0 {
+		return 0, false
+	}
+	units := map[byte]time.Duration{
+		'H': time.Hour,
+		'M': time.Minute,
+		'S': time.Second,
+		'm': time.Millisecond,
+		'u': time.Microsecond,
+		'n': time.Nanosecond,
+	}
+	unit, ok := units[v[len(v)-1]]
+	if !ok {
+		return 0, false
+	}
+	return time.Duration(n) * unit, true
+}
+
+// grpcWebContext returns the context of a grpc-web call, carrying forwarded
+// headers as outgoing metadata as the grpc-gateway would.
+func (orc *Oracle) grpcWebContext(r *http.Request) (context.Context, context.CancelFunc) {
+	md := metadata.MD{}
+	for k, vals := range r.Header {
+		if key, ok := orc.incomingHeaderMatcher(k); ok {
+			md.Append(strings.ToLower(key), vals...)
+		}
+	}
+	ctx := metadata.NewOutgoingContext(r.Context(), md)
+	if d, ok := grpcTimeout(r.Header.Get("Grpc-Timeout")); ok {
+		return context.WithTimeout(ctx, d)
+	}
+	return context.WithCancel(ctx)
+}
+
+func (orc *Oracle) serveGRPCWeb(conn grpc.ClientConnInterface, w http.ResponseWriter, r *http.Request) {
+	ct := r.Header.Get("Content-Type")
+	text := strings.HasPrefix(ct, grpcWebTextContentType)
+	codec := strings.TrimPrefix(strings.TrimPrefix(ct, grpcWebTextContentType), grpcWebContentType)
+	var body io.Reader = io.LimitReader(r.Body, grpcWebMaxMessageLen+5)
+	if text {
+		body = base64.NewDecoder(base64.StdEncoding, body)
+	}
+
+	ctx, cancel := orc.grpcWebContext(r)
+	defer cancel()
+
+	var header, trailer metadata.MD
+	var resp []byte
+	req, err := readGRPCWebFrame(body)
+	if codec != "" && codec != "+proto" {
+		err = status.Errorf(codes.Unimplemented, "unsupported content type: %s", ct)
+	} else if errors.Is(err, errGRPCWebCompressed) {
+		err = status.Error(codes.Unimplemented, err.Error())
+	} else if err != nil {
+		err = status.Error(codes.InvalidArgument, err.Error())
+	} else {
+		err = conn.Invoke(ctx, r.URL.Path, &req, &resp,
+			grpc.ForceCodec(rawCodec{}), grpc.Header(&header), grpc.Trailer(&trailer))
+	}
+
+	var buf bytes.Buffer
+	if err == nil {
+		writeGRPCWebFrame(&buf, grpcWebFrameData, resp)
+	}
+	st := status.Convert(err)
+	var trailers strings.Builder
+	fmt.Fprintf(&trailers, "grpc-status: %d\r\n", st.Code())
+	if st.Message() != "" {
+		fmt.Fprintf(&trailers, "grpc-message: %s\r\n", grpcPercentEncode(st.Message()))
+	}
+	if len(st.Details()) > 0 {
+		if b, err := proto.Marshal(st.Proto()); err == nil {
+			fmt.Fprintf(&trailers, "grpc-status-details-bin: %s\r\n", base64.RawStdEncoding.EncodeToString(b))
+		}
+	}
+	for k, vals := range trailer {
+		for _, v := range vals {
+			fmt.Fprintf(&trailers, "%s: %s\r\n", strings.ToLower(k), v)
+		}
+	}
+	writeGRPCWebFrame(&buf, grpcWebFrameTrailer, []byte(trailers.String()))
+
+	for k, vals := range header {
+		if k == "content-type" {
+			continue
+		}
+		for _, v := range vals {
+			w.Header().Add(k, v)
+		}
+	}
+	out := buf.Bytes()
+	if text {
+		w.Header().Set("Content-Type", grpcWebTextContentType+"+proto")
+		out = []byte(base64.StdEncoding.EncodeToString(out))
+	} else {
+		w.Header().Set("Content-Type", grpcWebContentType+"+proto")
+	}
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write(out); err != nil {
+		orc.log(r.Context()).WithError(err).Errorf("grpc-web response error")
+	}
+}
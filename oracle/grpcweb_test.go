@@ -0,0 +1,102 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package oracle
+
+import (
+	"bytes"
+	"encoding/base64"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/protobuf/proto"
+)
+
+func grpcWebRequest(t *testing.T, msg proto.Message) []byte {
+	b, err := proto.Marshal(msg)
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	writeGRPCWebFrame(&buf, grpcWebFrameData, b)
+	return buf.Bytes()
+}
+
+func TestGRPCWeb(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	srv := grpc.NewServer()
+	hs := health.NewServer()
+	hs.SetServingStatus("oracle", healthpb.HealthCheckResponse_SERVING)
+	healthpb.RegisterHealthServer(srv, hs)
+	go func() { _ = srv.Serve(lis) }()
+	defer srv.Stop()
+	conn, err := grpc.NewClient(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer conn.Close()
+
+	orc := newTestOracle(t, DefaultConfig())
+	h := orc.grpcWebMiddleware(conn)(http.NotFoundHandler())
+	path := "/grpc.health.v1.Health/Check"
+
+	t.Run("binary", func(t *testing.T) {
+		body := grpcWebRequest(t, &healthpb.HealthCheckRequest{Service: "oracle"})
+		r := httptest.NewRequest(http.MethodPost, path, bytes.NewReader(body))
+		r.Header.Set("Content-Type", "application/grpc-web+proto")
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		require.Equal(t, http.StatusOK, w.Code)
+		require.Equal(t, "application/grpc-web+proto", w.Header().Get("Content-Type"))
+
+		msg, err := readGRPCWebFrame(w.Body)
+		require.NoError(t, err)
+		resp := &healthpb.HealthCheckResponse{}
+		require.NoError(t, proto.Unmarshal(msg, resp))
+		require.Equal(t, healthpb.HealthCheckResponse_SERVING, resp.GetStatus())
+		require.Equal(t, byte(grpcWebFrameTrailer), w.Body.Bytes()[0])
+		require.Contains(t, w.Body.String(), "grpc-status: 0\r\n")
+	})
+
+	t.Run("text error", func(t *testing.T) {
+		body := grpcWebRequest(t, &healthpb.HealthCheckRequest{Service: "unknown"})
+		r := httptest.NewRequest(http.MethodPost, path, strings.NewReader(base64.StdEncoding.EncodeToString(body)))
+		r.Header.Set("Content-Type", "application/grpc-web-text")
+		r.Header.Set("Grpc-Timeout", "5S")
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		require.Equal(t, http.StatusOK, w.Code)
+		require.Equal(t, "application/grpc-web-text+proto", w.Header().Get("Content-Type"))
+		out, err := base64.StdEncoding.DecodeString(w.Body.String())
+		require.NoError(t, err)
+		require.Equal(t, byte(grpcWebFrameTrailer), out[0])
+		require.Contains(t, string(out), "grpc-status: 5\r\n")
+	})
+
+	t.Run("passthrough", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodPost, path, nil)
+		r.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		require.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
+
+func TestGRPCTimeout(t *testing.T) {
+	d, ok := grpcTimeout("250m")
+	require.True(t, ok)
+	require.Equal(t, 250*time.Millisecond, d)
+	_, ok = grpcTimeout("10x")
+	require.False(t, ok)
+	_, ok = grpcTimeout("S")
+	require.False(t, ok)
+}
+
+func TestGRPCPercentEncode(t *testing.T) {
+	require.Equal(t, "not found: 100%25%0A", grpcPercentEncode("not found: 100%\n"))
+}
@@ -0,0 +1,153 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package oracle
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	healthcheck "buf.build/gen/go/luthersystems/protos/protocolbuffers/go/healthcheck/v1"
+	"github.com/luthersystems/svc/docstore"
+)
+
+// defaultHealthReporterTimeout is the default time allowed for a health
+// reporter to respond.
+const defaultHealthReporterTimeout = 5 * time.Second
+
+// HealthReporter reports the health of a dependency, such as a database,
+// docstore, mailer or IDP.  The report Status should be "UP" when the
+// dependency is healthy.  A nil report is treated as "DOWN".
+type HealthReporter func(ctx context.Context) *healthcheck.HealthCheckReport
+
+// HealthReporterOption configures a health reporter.
+type HealthReporterOption func(*healthReporter)
+
+// WithHealthReporterTimeout sets the time allowed for a health reporter to
+// respond before its dependency is reported "DOWN".  Defaults to 5 seconds.
+func WithHealthReporterTimeout(d time.Duration) HealthReporterOption {
+	return func(r *healthReporter) {
+		r.timeout = d
+	}
+}
+
+// informational reports the health of a dependency without affecting the
+// health of the oracle.
+func informational() HealthReporterOption {
+	return func(r *healthReporter) {
+		r.informational = true
+	}
+}
+
+type healthReporter struct {
+	name          string
+	fn            HealthReporter
+	timeout       time.Duration
+	informational bool
+}
+
+// AddHealthReporter adds a dependency health check to the oracle health
+// check.  Reporters are called in parallel and their reports are included in
+// the health check response, in the order they were added.
+func (orc *Oracle) AddHealthReporter(name string, fn HealthReporter, opts ...HealthReporterOption) {
+	r := &healthReporter{name: name, fn: fn, timeout: defaultHealthReporterTimeout}
+	for _, opt := range opts {
+		opt(r)
+	}
+	orc.healthMut.Lock()
+	defer orc.healthMut.Unlock()
+	orc.healthReporters = append(orc.healthReporters, r)
+}
+
+// PingHealthReporter returns a HealthReporter which reports a docstore "UP"
+// when it responds to Ping.
+func PingHealthReporter(store docstore.Pinger) HealthReporter {
+	return func(ctx context.Context) *healthcheck.HealthCheckReport {
+		if err := store.Ping(ctx); err != nil {
+			return nil
+		}
+		return &healthcheck.HealthCheckReport{Status: "UP"}
+	}
+}
+
+// downReport returns a report for a dependency which could not be checked.
+func (r *healthReporter) downReport(timestamp string) *healthcheck.HealthCheckReport {
+	return &healthcheck.HealthCheckReport{
+		ServiceName: r.name,
+		Timestamp:   timestamp,
+		Status:      "DOWN",
+	}
+}
+
+// report calls the reporter, returning a "DOWN" report if it times out or
+// panics.
+func (orc *Oracle) report(ctx context.Context, r *healthReporter) *healthcheck.HealthCheckReport {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	reports := make(chan *healthcheck.HealthCheckReport, 1)
+	go func() {
+		defer func() {
+			if p := recover(); p != nil {
+				orc.log(ctx).WithError(fmt.Errorf("panic: %v", p)).WithField("health_reporter", r.name).Errorf("health reporter panic")
+				reports <- nil
+			}
+		}()
+		reports <- r.fn(ctx)
+	}()
+	select {
+	case report := <-reports:
+		if report == nil {
+			return r.downReport(orc.timestamp())
+		}
+		if report.GetServiceName() == "" {
+			report.ServiceName = r.name
+		}
+		if report.GetTimestamp() == "" {
+			report.Timestamp = orc.timestamp()
+		}
+		return report
+	case <-ctx.Done():
+		orc.log(ctx).WithField("health_reporter", r.name).Warnf("health reporter timeout")
+		return r.downReport(orc.timestamp())
+	}
+}
+
+// reportHealth runs the health reporters in parallel.
+func (orc *Oracle) reportHealth(ctx context.Context) []*healthcheck.HealthCheckReport {
+	orc.healthMut.RLock()
+	reporters := orc.healthReporters
+	orc.healthMut.RUnlock()
+
+	reports := make([]*healthcheck.HealthCheckReport, len(reporters))
+	var wg sync.WaitGroup
+	for i, r := range reporters {
+		wg.Add(1)
+		go func(i int, r *healthReporter) {
+			defer wg.Done()
+			reports[i] = orc.report(ctx, r)
+		}(i, r)
+	}
+	wg.Wait()
+	return reports
+}
+
+// healthy returns true if all reports, other than those of informational
+// reporters, are "UP".
+func (orc *Oracle) healthy(reports []*healthcheck.HealthCheckReport) bool {
+	orc.healthMut.RLock()
+	skip := make(map[string]bool)
+	for _, r := range orc.healthReporters {
+		if r.informational {
+			skip[r.name] = true
+		}
+	}
+	orc.healthMut.RUnlock()
+	for _, report := range reports {
+		if !skip[report.GetServiceName()] && !strings.EqualFold(report.GetStatus(), "UP") {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,79 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package oracle
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	healthcheck "buf.build/gen/go/luthersystems/protos/protocolbuffers/go/healthcheck/v1"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHealthReporters(t *testing.T) {
+	orc := newTestOracle(t, DefaultConfig())
+	orc.AddHealthReporter("db", func(ctx context.Context) *healthcheck.HealthCheckReport {
+		return &healthcheck.HealthCheckReport{Status: "UP", ServiceVersion: "15"}
+	})
+	orc.AddHealthReporter("slow", func(ctx context.Context) *healthcheck.HealthCheckReport {
+		<-ctx.Done()
+		time.Sleep(time.Second)
+		return &healthcheck.HealthCheckReport{Status: "UP"}
+	}, WithHealthReporterTimeout(50*time.Millisecond))
+	orc.AddHealthReporter("nil", func(ctx context.Context) *healthcheck.HealthCheckReport {
+		return nil
+	})
+	orc.AddHealthReporter("panic", func(ctx context.Context) *healthcheck.HealthCheckReport {
+		panic("boom")
+	})
+
+	start := time.Now()
+	reports := orc.reportHealth(context.Background())
+	require.Less(t, time.Since(start), time.Second)
+	require.Len(t, reports, 4)
+	for i, want := range []struct {
+		name   string
+		status string
+	}{
+		{"db", "UP"},
+		{"slow", "DOWN"},
+		{"nil", "DOWN"},
+		{"panic", "DOWN"},
+	} {
+		require.Equal(t, want.name, reports[i].GetServiceName())
+		require.Equal(t, want.status, reports[i].GetStatus(), want.name)
+		require.NotEmpty(t, reports[i].GetTimestamp())
+	}
+	require.Equal(t, "15", reports[0].GetServiceVersion())
+	require.False(t, orc.healthy(reports))
+}
+
+func TestInformationalHealthReporter(t *testing.T) {
+	orc := newTestOracle(t, DefaultConfig())
+	orc.AddHealthReporter("db", func(ctx context.Context) *healthcheck.HealthCheckReport {
+		return &healthcheck.HealthCheckReport{Status: "UP"}
+	})
+	orc.AddHealthReporter("candidate", func(ctx context.Context) *healthcheck.HealthCheckReport {
+		return nil
+	}, informational())
+	reports := orc.reportHealth(context.Background())
+	require.Len(t, reports, 2)
+	require.Equal(t, "DOWN", reports[1].GetStatus())
+	require.True(t, orc.healthy(reports))
+}
+
+type pingFunc func(ctx context.Context) error
+
+func (f pingFunc) Ping(ctx context.Context) error {
+	return f(ctx)
+}
+
+func TestPingHealthReporter(t *testing.T) {
+	ctx := context.Background()
+	up := PingHealthReporter(pingFunc(func(ctx context.Context) error { return nil }))
+	require.Equal(t, "UP", up(ctx).GetStatus())
+	down := PingHealthReporter(pingFunc(func(ctx context.Context) error { return fmt.Errorf("unreachable") }))
+	require.Nil(t, down(ctx))
+}
@@ -0,0 +1,205 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package oracle
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/luthersystems/svc/grpclogging"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+)
+
+const (
+	defaultHTTPClientTimeout = 30 * time.Second
+	defaultHTTPClientRetries = 2
+	defaultHTTPClientBackoff = 200 * time.Millisecond
+)
+
+var (
+	outboundRequestsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "outbound_http_requests_total",
+			Help: "How many outbound HTTP requests were made, partitioned by destination, method, and status code.",
+		},
+		[]string{"destination", "method", "code"},
+	)
+	outboundRequestDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "outbound_http_request_duration_seconds",
+			Help:    "Duration of outbound HTTP requests, partitioned by destination and method.",
+			Buckets: prometheus.DefBuckets,
+		},
+		[]string{"destination", "method"},
+	)
+)
+
+// HTTPClientOption configures a client created by HTTPClient.
+type HTTPClientOption func(*httpClientConfig)
+
+type httpClientConfig struct {
+	timeout   time.Duration
+	retries   int
+	backoff   time.Duration
+	transport http.RoundTripper
+}
+
+// WithHTTPTimeout sets the overall timeout of requests, including retries.
+// Defaults to 30 seconds.
+func WithHTTPTimeout(d time.Duration) HTTPClientOption {
+	return func(cfg *httpClientConfig) {
+		cfg.timeout = d
+	}
+}
+
+// WithHTTPRetries sets how many times idempotent requests are retried after
+// a connection error or a 502, 503, or 504 response.  Defaults to 2.
+func WithHTTPRetries(n int) HTTPClientOption {
+	return func(cfg *httpClientConfig) {
+		cfg.retries = n
+	}
+}
+
+// WithHTTPRetryBackoff sets the delay before the first retry, which doubles
+// with each subsequent retry.  Defaults to 200ms.
+func WithHTTPRetryBackoff(d time.Duration) HTTPClientOption {
+	return func(cfg *httpClientConfig) {
+		cfg.backoff = d
+	}
+}
+
+// WithHTTPTransport sets the transport which sends requests.  Defaults to
+// http.DefaultTransport.
+func WithHTTPTransport(rt http.RoundTripper) HTTPClientOption {
+	return func(cfg *httpClientConfig) {
+		cfg.transport = rt
+	}
+}
+
+// HTTPClient returns a client for calls to the named downstream destination,
+// e.g. a KYC vendor.  Requests are traced, carry the request ID of their
+// context in the configured request ID header, and are counted and timed
+// per destination.  Idempotent requests are retried on transient failures.
+func (orc *Oracle) HTTPClient(name string, opts ...HTTPClientOption) *http.Client {
+	cfg := &httpClientConfig{
+		timeout:   defaultHTTPClientTimeout,
+		retries:   defaultHTTPClientRetries,
+		backoff:   defaultHTTPClientBackoff,
+		transport: http.DefaultTransport,
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	var rt http.RoundTripper = otelhttp.NewTransport(cfg.transport,
+		otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+			return fmt.Sprintf("HTTP %s %s", r.Method, name)
+		}))
+	rt = &metricsTransport{next: rt, destination: name}
+	rt = &requestIDTransport{next: rt, header: orc.cfg.RequestIDHeader}
+	if cfg.retries > 0 {
+		rt = &retryTransport{next: rt, retries: cfg.retries, backoff: cfg.backoff}
+	}
+	return &http.Client{
+		Transport: rt,
+		Timeout:   cfg.timeout,
+	}
+}
+
+// requestIDTransport propagates the request ID of the request context.
+type requestIDTransport struct {
+	next   http.RoundTripper
+	header string
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *requestIDTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	reqID := grpclogging.ReqID(r.Context())
+	if t.header == "" || reqID == "" || r.Header.Get(t.header) != "" {
+		return t.next.RoundTrip(r)
+	}
+	r = r.Clone(r.Context())
+	r.Header.Set(t.header, reqID)
+	return t.next.RoundTrip(r)
+}
+
+// metricsTransport records outbound request metrics.
+type metricsTransport struct {
+	next        http.RoundTripper
+	destination string
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *metricsTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := t.next.RoundTrip(r)
+	grpclogging.ObserveWithExemplar(r.Context(), outboundRequestDuration.WithLabelValues(t.destination, r.Method), time.Since(start).Seconds())
+	code := "error"
+	if err == nil {
+		code = strconv.Itoa(resp.StatusCode)
+	}
+	outboundRequestsTotal.WithLabelValues(t.destination, r.Method, code).Inc()
+	return resp, err
+}
+
+// retryTransport retries idempotent requests on transient failures.
+type retryTransport struct {
+	next    http.RoundTripper
+	retries int
+	backoff time.Duration
+}
+
+// idempotent returns true if r may safely be sent more than once.
+func idempotent(r *http.Request) bool {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+	default:
+		return false
+	}
+	return r.Body == nil || r.Body == http.NoBody || r.GetBody != nil
+}
+
+// retryable returns true if a response indicates a transient failure.
+func retryable(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	switch resp.StatusCode {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *retryTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	if !idempotent(r) {
+		return t.next.RoundTrip(r)
+	}
+	backoff := t.backoff
+	for attempt := 0; ; attempt++ {
+		resp, err := t.next.RoundTrip(r)
+		if attempt == t.retries || !retryable(resp, err) {
+			return resp, err
+		}
+		if resp != nil {
+			resp.Body.Close()
+		}
+		select {
+		case <-r.Context().Done():
+			return nil, r.Context().Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		if r.GetBody != nil {
+			body, err := r.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			r = r.Clone(r.Context())
+			r.Body = body
+		}
+	}
+}
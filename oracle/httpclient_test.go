@@ -0,0 +1,75 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package oracle
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/luthersystems/svc/grpclogging"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTPClient(t *testing.T) {
+	var attempts int32
+	var gotReqID, gotBody string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotReqID = r.Header.Get("X-Request-ID")
+		b, _ := io.ReadAll(r.Body)
+		gotBody = string(b)
+		if atomic.AddInt32(&attempts, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	orc := newTestOracle(t, DefaultConfig())
+	client := orc.HTTPClient("test", WithHTTPRetryBackoff(time.Millisecond))
+
+	ctx := grpclogging.NewContext(context.Background())
+	grpclogging.AddLogrusField(ctx, "req_id", "req-1")
+
+	t.Run("retry idempotent", func(t *testing.T) {
+		atomic.StoreInt32(&attempts, 0)
+		req, err := http.NewRequestWithContext(ctx, http.MethodPut, server.URL, strings.NewReader("body"))
+		require.NoError(t, err)
+		resp, err := client.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.EqualValues(t, 3, atomic.LoadInt32(&attempts))
+		require.Equal(t, "req-1", gotReqID)
+		require.Equal(t, "body", gotBody)
+	})
+
+	t.Run("no retry", func(t *testing.T) {
+		atomic.StoreInt32(&attempts, 0)
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, server.URL, strings.NewReader("body"))
+		require.NoError(t, err)
+		resp, err := client.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		require.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+		require.EqualValues(t, 1, atomic.LoadInt32(&attempts))
+	})
+
+	t.Run("retries exhausted", func(t *testing.T) {
+		atomic.StoreInt32(&attempts, 0)
+		client := orc.HTTPClient("test", WithHTTPRetries(1), WithHTTPRetryBackoff(time.Millisecond))
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+		require.NoError(t, err)
+		resp, err := client.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		require.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+		require.EqualValues(t, 2, atomic.LoadInt32(&attempts))
+	})
+}
@@ -17,9 +17,8 @@ import (
 	"fmt"
 	"io"
 	"net/http"
-	"strings"
-	"time"
 
+	common "buf.build/gen/go/luthersystems/protos/protocolbuffers/go/common/v1"
 	healthcheck "buf.build/gen/go/luthersystems/protos/protocolbuffers/go/healthcheck/v1"
 	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
 	"github.com/grpc-ecosystem/grpc-gateway/v2/utilities"
@@ -43,8 +42,70 @@ func (orc *Oracle) addServerHeader() midware.Middleware {
 		})
 }
 
+// trustedProxies reconstructs the client facing scheme and host of requests
+// forwarded by the configured trusted proxies.
+func (orc *Oracle) trustedProxies() midware.Middleware {
+	p, err := midware.NewTrustedProxies(orc.cfg.TrustedProxies...)
+	if err != nil {
+		// Unreachable, the proxies are validated by Config.Valid.
+		orc.logBase.WithError(err).Errorf("invalid trusted proxies")
+		p, _ = midware.NewTrustedProxies()
+	}
+	return p
+}
+
+// PathNormalization configures normalization of request paths before they
+// are routed.  Duplicate slashes are always collapsed when enabled.
+type PathNormalization struct {
+	// Enabled turns on path normalization.
+	Enabled bool `yaml:"enabled"`
+	// TrailingSlash is "strip" to remove trailing slashes, "enforce" to add
+	// them, or empty to leave them unchanged.
+	TrailingSlash string `yaml:"trailing-slash"`
+	// LowerCase converts paths to lower case.
+	LowerCase bool `yaml:"lower-case"`
+	// Redirect redirects clients to the normalized path instead of
+	// rewriting the request.
+	Redirect bool `yaml:"redirect"`
+}
+
+// trailingSlash returns the configured trailing slash mode.
+func (n PathNormalization) trailingSlash() (midware.TrailingSlash, error) {
+	switch n.TrailingSlash {
+	case "":
+		return midware.TrailingSlashPreserve, nil
+	case "strip":
+		return midware.TrailingSlashStrip, nil
+	case "enforce":
+		return midware.TrailingSlashEnforce, nil
+	default:
+		return 0, fmt.Errorf("invalid path normalization trailing slash: %q", n.TrailingSlash)
+	}
+}
+
+// valid validates the path normalization configuration.
+func (n PathNormalization) valid() error {
+	_, err := n.trailingSlash()
+	return err
+}
+
+// normalizePath normalizes request paths as configured, so they match path
+// overrides and gateway routes.
+func (orc *Oracle) normalizePath() midware.Middleware {
+	if !orc.cfg.PathNormalization.Enabled {
+		return midware.Func(func(next http.Handler) http.Handler { return next })
+	}
+	// The trailing slash mode is validated by Config.Valid.
+	trailingSlash, _ := orc.cfg.PathNormalization.trailingSlash()
+	return midware.NormalizePath{
+		TrailingSlash: trailingSlash,
+		LowerCase:     orc.cfg.PathNormalization.LowerCase,
+		Redirect:      orc.cfg.PathNormalization.Redirect,
+	}
+}
+
 // healthCheckHandler intercepts the healthcheck endpoint to return 503 on
-// error.
+// error, and while the oracle is stopping.
 func (orc *Oracle) healthCheckHandler() http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
@@ -54,6 +115,22 @@ func (orc *Oracle) healthCheckHandler() http.Handler {
 				orc.log(ctx).WithError(err).Errorf("health handler response error")
 			}
 		}
+		downResponse := func() *healthcheck.GetHealthCheckResponse {
+			return &healthcheck.GetHealthCheckResponse{
+				Reports: []*healthcheck.HealthCheckReport{
+					{
+						ServiceName:    orc.cfg.ServiceName,
+						ServiceVersion: orc.cfg.Version,
+						Timestamp:      orc.timestamp(),
+						Status:         "DOWN",
+					},
+				},
+			}
+		}
+		if orc.Stopping() {
+			sendResponse(downResponse(), http.StatusServiceUnavailable)
+			return
+		}
 		exceptionf := func(format string, v ...interface{}) *healthcheck.GetHealthCheckResponse {
 			ex := svcerr.BusinessException(ctx, fmt.Sprintf(format, v...))
 			return &healthcheck.GetHealthCheckResponse{Exception: ex}
@@ -82,26 +159,14 @@ func (orc *Oracle) healthCheckHandler() http.Handler {
 			default:
 				orc.log(ctx).WithError(err).Errorf("missing processor client healthcheck response")
 			}
-			resp = &healthcheck.GetHealthCheckResponse{
-				Reports: []*healthcheck.HealthCheckReport{
-					{
-						ServiceName:    orc.cfg.ServiceName,
-						ServiceVersion: orc.cfg.Version,
-						Timestamp:      time.Now().Format(timestampFormat),
-						Status:         "DOWN",
-					},
-				},
-			}
-			sendResponse(resp, http.StatusServiceUnavailable)
+			sendResponse(downResponse(), http.StatusServiceUnavailable)
 			return
 		}
 
-		for _, report := range resp.GetReports() {
-			// NOTE: we assume resp is empty on error from above health check call
-			if !strings.EqualFold(report.GetStatus(), "UP") {
-				sendResponse(resp, http.StatusServiceUnavailable)
-				return
-			}
+		// NOTE: we assume resp is empty on error from above health check call
+		if !orc.healthy(resp.GetReports()) {
+			sendResponse(resp, http.StatusServiceUnavailable)
+			return
 		}
 		sendResponse(resp, http.StatusOK)
 	})
@@ -122,3 +187,9 @@ func writeProtoHTTP(w http.ResponseWriter, code int, msg proto.Message) error {
 	}
 	return nil
 }
+
+// writeExceptionHTTP writes an exception response for requests served outside
+// of the grpc-gateway.
+func writeExceptionHTTP(w http.ResponseWriter, code int, ex *common.Exception) error {
+	return writeProtoHTTP(w, code, &common.ExceptionResponse{Exception: ex})
+}
@@ -0,0 +1,67 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package oracle
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/luthersystems/svc/midware"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPathNormalization(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.PathNormalization = PathNormalization{Enabled: true, TrailingSlash: "strip"}
+	require.NoError(t, cfg.Valid())
+	orc := newTestOracle(t, cfg)
+
+	var path string
+	h := orc.normalizePath().Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+	}))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/static//app.js/", nil))
+	require.Equal(t, "/static/app.js", path)
+
+	orc.cfg.PathNormalization.Enabled = false
+	h = orc.normalizePath().Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+	}))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/static//app.js/", nil))
+	require.Equal(t, "/static//app.js/", path)
+
+	cfg.PathNormalization.TrailingSlash = "remove"
+	require.Error(t, cfg.Valid())
+}
+
+func TestGRPCGatewayValid(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.PathNormalization = PathNormalization{Enabled: true}
+	cfg.TrustedProxies = []string{"10.0.0.0/8"}
+	orc := newTestOracle(t, cfg)
+	_, h, err := orc.grpcGateway(nil, nil)
+	require.NoError(t, err)
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, healthCheckPath, nil))
+	require.NotEqual(t, http.StatusNotFound, rr.Code)
+}
+
+func TestGRPCGatewayDedupe(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.LogoutPath = "/v1/logout"
+	cfg.DedupePathPrefixes = []string{"/v1/logout"}
+	orc := newTestOracle(t, cfg)
+	_, h, err := orc.grpcGateway(nil, nil)
+	require.NoError(t, err)
+	var replayed []string
+	for i := 0; i < 2; i++ {
+		r := httptest.NewRequest(http.MethodPost, "/v1/logout", nil)
+		r.Header.Set(midware.IdempotencyKeyHeader, "k")
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, r)
+		require.Equal(t, http.StatusOK, rr.Code)
+		replayed = append(replayed, rr.Header().Get(midware.IdempotentReplayedHeader))
+	}
+	require.Equal(t, []string{"", "true"}, replayed)
+}
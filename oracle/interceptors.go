@@ -0,0 +1,228 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package oracle
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/luthersystems/svc/grpclogging"
+	"github.com/luthersystems/svc/logging"
+	"github.com/luthersystems/svc/svcerr"
+	"github.com/luthersystems/svc/txctx"
+	"google.golang.org/grpc"
+)
+
+// Names of the built-in unary interceptors, in the order they are chained.
+const (
+	// InterceptorGRPCMetrics records grpc_prometheus server metrics, when
+	// GRPCServerMetrics is set.
+	InterceptorGRPCMetrics = "grpc-metrics"
+	// InterceptorLogging logs method calls and initializes log fields,
+	// including the request ID generated by NewID, and timestamps
+	// exceptions with Now.
+	InterceptorLogging = "logging"
+	// InterceptorTxCtx initializes the transaction context.
+	InterceptorTxCtx = "txctx"
+	// InterceptorClaimsCache caches claims for the duration of a request.
+	InterceptorClaimsCache = "claims-cache"
+	// InterceptorLocale resolves the locale of requests, when Locale is
+	// configured.
+	InterceptorLocale = "locale"
+	// InterceptorCommitBlock applies the minimum commit block of requests.
+	InterceptorCommitBlock = "commit-block"
+	// InterceptorErrors coerces errors into conventional exceptions.
+	InterceptorErrors = "svcerr"
+	// InterceptorCompression compresses large responses.
+	InterceptorCompression = "compression"
+)
+
+var builtinInterceptors = []string{
+	InterceptorGRPCMetrics,
+	InterceptorLogging,
+	InterceptorTxCtx,
+	InterceptorClaimsCache,
+	InterceptorLocale,
+	InterceptorCommitBlock,
+	InterceptorErrors,
+	InterceptorCompression,
+}
+
+// NamedUnaryInterceptor is a unary interceptor of the oracle gRPC server.
+type NamedUnaryInterceptor struct {
+	// Name is the name of a built-in interceptor, or empty for interceptors
+	// added by the application.
+	Name string
+	// Interceptor intercepts calls.
+	Interceptor grpc.UnaryServerInterceptor
+}
+
+// UnaryInterceptorChain rewrites the chain of unary interceptors installed
+// in the oracle gRPC server.  The first interceptor is the outermost.
+type UnaryInterceptorChain func(chain []NamedUnaryInterceptor) []NamedUnaryInterceptor
+
+// unaryInterceptorHook is an application interceptor positioned relative to
+// a built-in interceptor.
+type unaryInterceptorHook struct {
+	// name is the built-in interceptor, or empty for the start or end of the
+	// chain.
+	name        string
+	after       bool
+	interceptor grpc.UnaryServerInterceptor
+}
+
+// AddUnaryInterceptorBefore installs an interceptor immediately before
+// (outside of) the named built-in interceptor, e.g. InterceptorErrors to
+// authorize requests with conventional errors.  An empty name installs the
+// interceptor at the start of the chain.
+func (c *Config) AddUnaryInterceptorBefore(name string, i grpc.UnaryServerInterceptor) {
+	if c == nil {
+		return
+	}
+	c.unaryInterceptorHooks = append(c.unaryInterceptorHooks, unaryInterceptorHook{name: name, interceptor: i})
+}
+
+// AddUnaryInterceptorAfter installs an interceptor immediately after
+// (inside of) the named built-in interceptor, e.g. InterceptorErrors to
+// validate requests before they reach the handler.  An empty name installs
+// the interceptor at the end of the chain.
+func (c *Config) AddUnaryInterceptorAfter(name string, i grpc.UnaryServerInterceptor) {
+	if c == nil {
+		return
+	}
+	c.unaryInterceptorHooks = append(c.unaryInterceptorHooks, unaryInterceptorHook{name: name, after: true, interceptor: i})
+}
+
+// SetUnaryInterceptorChain overrides the chain of unary interceptors.  The
+// function is given the chain, after interceptors were added and disabled,
+// and returns the chain to install.
+func (c *Config) SetUnaryInterceptorChain(fn UnaryInterceptorChain) {
+	if c == nil {
+		return
+	}
+	c.unaryInterceptorChain = fn
+}
+
+// validInterceptors validates the interceptor configuration.
+func (c *Config) validInterceptors() error {
+	for _, name := range c.DisabledInterceptors {
+		if !isBuiltinInterceptor(name) {
+			return fmt.Errorf("disabled interceptors: unknown interceptor %q", name)
+		}
+	}
+	for _, h := range c.unaryInterceptorHooks {
+		if h.interceptor == nil {
+			return fmt.Errorf("unary interceptor: missing interceptor")
+		}
+		if h.name != "" && !isBuiltinInterceptor(h.name) {
+			return fmt.Errorf("unary interceptor: unknown interceptor %q", h.name)
+		}
+	}
+	return nil
+}
+
+func isBuiltinInterceptor(name string) bool {
+	for _, b := range builtinInterceptors {
+		if name == b {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *Config) interceptorDisabled(name string) bool {
+	for _, d := range c.DisabledInterceptors {
+		if d == name {
+			return true
+		}
+	}
+	return false
+}
+
+// newLogInterceptor returns the interceptor logging method calls.  Request
+// IDs and exception timestamps come from the oracle, so test oracles with
+// deterministic IDs and a fixed clock produce reproducible responses.
+func (orc *Oracle) newLogInterceptor() (grpc.UnaryServerInterceptor, error) {
+	logOpts := []grpclogging.InterceptorOption{
+		grpclogging.WithLevelController(orc.levels),
+		grpclogging.WithRequestIDs(orc.NewID),
+		grpclogging.WithOutcomeMetrics(orc.cfg.metricsRegisterer()),
+		grpclogging.WithSlowThresholds(orc.cfg.SlowRequestThresholds, orc.cfg.metricsRegisterer()),
+	}
+	if orc.cfg.MetricsExemplars {
+		logOpts = append(logOpts, grpclogging.WithMetrics(orc.cfg.metricsRegisterer()))
+	}
+	log, err := grpclogging.NewMethodInterceptor(
+		logging.NewLogrus(orc.logBase),
+		grpclogging.UpperBoundTimer(time.Millisecond),
+		grpclogging.RealTime(),
+		logOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		return log(svcerr.WithClock(ctx, orc.Now), req, info, handler)
+	}, nil
+}
+
+// builtinUnaryInterceptors returns the built-in interceptors, in order.
+func (orc *Oracle) builtinUnaryInterceptors() []NamedUnaryInterceptor {
+	var chain []NamedUnaryInterceptor
+	for _, i := range orc.grpcServerInterceptors() {
+		chain = append(chain, NamedUnaryInterceptor{Name: InterceptorGRPCMetrics, Interceptor: i})
+	}
+	return append(chain,
+		NamedUnaryInterceptor{Name: InterceptorLogging, Interceptor: orc.logInterceptor},
+		NamedUnaryInterceptor{Name: InterceptorTxCtx, Interceptor: txctx.UnaryServerInterceptor()},
+		NamedUnaryInterceptor{Name: InterceptorClaimsCache, Interceptor: orc.claimsCacheInterceptor()},
+		NamedUnaryInterceptor{Name: InterceptorLocale, Interceptor: orc.localeInterceptor()},
+		NamedUnaryInterceptor{Name: InterceptorCommitBlock, Interceptor: orc.commitBlockInterceptor()},
+		NamedUnaryInterceptor{Name: InterceptorErrors, Interceptor: svcerr.AppErrorUnaryInterceptor(orc.log)},
+		NamedUnaryInterceptor{Name: InterceptorCompression, Interceptor: orc.compressionServerInterceptor()},
+	)
+}
+
+// unaryInterceptors returns the chain of unary interceptors installed in
+// the gRPC server.
+func (orc *Oracle) unaryInterceptors() []grpc.UnaryServerInterceptor {
+	chain := orc.interceptorChain(orc.builtinUnaryInterceptors())
+	interceptors := make([]grpc.UnaryServerInterceptor, 0, len(chain))
+	for _, i := range chain {
+		if i.Interceptor != nil {
+			interceptors = append(interceptors, i.Interceptor)
+		}
+	}
+	return interceptors
+}
+
+// interceptorChain positions the configured interceptors in the built-in
+// chain, removes disabled interceptors, and applies the chain override.
+// Interceptors positioned relative to a built-in interceptor which is not
+// installed are positioned where it would be.
+func (orc *Oracle) interceptorChain(builtins []NamedUnaryInterceptor) []NamedUnaryInterceptor {
+	hooks := func(name string, after bool) []NamedUnaryInterceptor {
+		var chain []NamedUnaryInterceptor
+		for _, h := range orc.cfg.unaryInterceptorHooks {
+			if h.name == name && h.after == after {
+				chain = append(chain, NamedUnaryInterceptor{Interceptor: h.interceptor})
+			}
+		}
+		return chain
+	}
+	chain := hooks("", false)
+	for _, name := range builtinInterceptors {
+		chain = append(chain, hooks(name, false)...)
+		for _, b := range builtins {
+			if b.Name == name && !orc.cfg.interceptorDisabled(name) {
+				chain = append(chain, b)
+			}
+		}
+		chain = append(chain, hooks(name, true)...)
+	}
+	chain = append(chain, hooks("", true)...)
+	if orc.cfg.unaryInterceptorChain != nil {
+		chain = orc.cfg.unaryInterceptorChain(chain)
+	}
+	return chain
+}
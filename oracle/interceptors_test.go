@@ -0,0 +1,132 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package oracle
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	healthcheck "buf.build/gen/go/luthersystems/protos/protocolbuffers/go/healthcheck/v1"
+	grpcmiddleware "github.com/grpc-ecosystem/go-grpc-middleware"
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+)
+
+func TestInterceptorChain(t *testing.T) {
+	var calls []string
+	record := func(name string) grpc.UnaryServerInterceptor {
+		return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			calls = append(calls, name)
+			return handler(ctx, req)
+		}
+	}
+	var builtins []NamedUnaryInterceptor
+	for _, name := range builtinInterceptors {
+		builtins = append(builtins, NamedUnaryInterceptor{Name: name, Interceptor: record(name)})
+	}
+	run := func(cfg *Config) []string {
+		require.NoError(t, cfg.validInterceptors())
+		orc := newTestOracle(t, cfg)
+		var interceptors []grpc.UnaryServerInterceptor
+		for _, i := range orc.interceptorChain(builtins) {
+			interceptors = append(interceptors, i.Interceptor)
+		}
+		calls = nil
+		_, err := grpcmiddleware.ChainUnaryServer(interceptors...)(context.Background(), nil, &grpc.UnaryServerInfo{},
+			func(ctx context.Context, req interface{}) (interface{}, error) {
+				calls = append(calls, "handler")
+				return "ok", nil
+			})
+		require.NoError(t, err)
+		return calls
+	}
+
+	require.Equal(t, append(append([]string(nil), builtinInterceptors...), "handler"), run(DefaultConfig()))
+
+	cfg := DefaultConfig()
+	cfg.DisabledInterceptors = []string{InterceptorClaimsCache, InterceptorGRPCMetrics}
+	cfg.AddUnaryInterceptorBefore(InterceptorErrors, record("authz"))
+	cfg.AddUnaryInterceptorAfter(InterceptorErrors, record("validate"))
+	cfg.AddUnaryInterceptorBefore("", record("first"))
+	cfg.AddUnaryInterceptorAfter("", record("last"))
+	// Disabled interceptors keep the position of their hooks.
+	cfg.AddUnaryInterceptorAfter(InterceptorClaimsCache, record("claims"))
+	require.Equal(t, []string{
+		"first",
+		InterceptorLogging,
+		InterceptorTxCtx,
+		"claims",
+		InterceptorLocale,
+		InterceptorCommitBlock,
+		"authz",
+		InterceptorErrors,
+		"validate",
+		InterceptorCompression,
+		"last",
+		"handler",
+	}, run(cfg))
+
+	cfg.SetUnaryInterceptorChain(func(chain []NamedUnaryInterceptor) []NamedUnaryInterceptor {
+		var out []NamedUnaryInterceptor
+		for _, i := range chain {
+			if i.Name != "" {
+				out = append(out, i)
+			}
+		}
+		return out
+	})
+	require.Equal(t, []string{
+		InterceptorLogging,
+		InterceptorTxCtx,
+		InterceptorLocale,
+		InterceptorCommitBlock,
+		InterceptorErrors,
+		InterceptorCompression,
+		"handler",
+	}, run(cfg))
+}
+
+func TestInterceptorConfigInvalid(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.DisabledInterceptors = []string{"bogus"}
+	require.Error(t, cfg.validInterceptors())
+
+	cfg = DefaultConfig()
+	cfg.AddUnaryInterceptorBefore("bogus", func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		return handler(ctx, req)
+	})
+	require.Error(t, cfg.validInterceptors())
+
+	cfg = DefaultConfig()
+	cfg.AddUnaryInterceptorAfter(InterceptorErrors, nil)
+	require.Error(t, cfg.validInterceptors())
+}
+
+func TestTxAnnotation(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	hook := test.NewLocal(logger)
+	orc := newTestOracle(t, DefaultConfig(), withLogBase(logrus.NewEntry(logger)))
+	orc.callPhylum = committingPhylum(42)
+
+	chain := grpcmiddleware.ChainUnaryServer(orc.unaryInterceptors()...)
+	_, err := chain(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: "/pkg.Service/Create"},
+		func(ctx context.Context, req interface{}) (interface{}, error) {
+			return Call(orc, ctx, "create_report", &healthcheck.GetHealthCheckRequest{}, &healthcheck.GetHealthCheckResponse{})
+		})
+	require.NoError(t, err)
+
+	var called *logrus.Entry
+	for _, e := range hook.AllEntries() {
+		if e.Message == "RPC method called" {
+			called = e
+		}
+	}
+	require.NotNil(t, called)
+	require.Equal(t, "tx-42", called.Data["tx_id"])
+	require.Equal(t, "42", called.Data["commit_block_num"])
+	require.Equal(t, uint64(42), orc.lastCommitBlock.Load())
+}
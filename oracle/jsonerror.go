@@ -0,0 +1,120 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package oracle
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// maxExpectedEnumValues is the maximum number of enum values listed in
+// errors.
+const maxExpectedEnumValues = 10
+
+var (
+	// protojsonEnumPattern matches protojson errors for invalid enum values.
+	protojsonEnumPattern = regexp.MustCompile(`^invalid value for enum type: (.*)$`)
+	// protojsonWellKnownPattern matches protojson errors for invalid or out
+	// of range timestamps and durations.
+	protojsonWellKnownPattern = regexp.MustCompile(`^(?:invalid )?google\.protobuf\.(Timestamp|Duration) value (?:out of range: )?(.*)$`)
+	// jsonIndexPattern matches the array indices of field paths.
+	jsonIndexPattern = regexp.MustCompile(`\[\d+\]`)
+)
+
+// wellKnownFormats describes the JSON format of well-known types.
+var wellKnownFormats = map[string]string{
+	"Timestamp": `expected an RFC 3339 timestamp, e.g. "2024-01-31T15:04:05Z"`,
+	"Duration":  `expected a number of seconds with an "s" suffix, e.g. "1.5s"`,
+}
+
+// unmarshalError returns the error presented to the client for a request
+// whose field could not be unmarshaled.  Common protojson failures, which
+// do not name the field, are rewritten to explain the expected format.
+func unmarshalError(ctx context.Context, field string, reason string) error {
+	if pretty, ok := prettyUnmarshalReason(rpcMethod(ctx), field, reason); ok {
+		reason = pretty
+	}
+	if field == "" {
+		return status.Errorf(codes.InvalidArgument, "invalid request: %s", reason)
+	}
+	return status.Errorf(codes.InvalidArgument, "invalid request field %q: %s", field, reason)
+}
+
+// prettyUnmarshalReason rewrites a protojson error reason for a field of the
+// request of method.  It returns false if the reason is not a known failure.
+func prettyUnmarshalReason(method string, field string, reason string) (string, bool) {
+	if m := protojsonEnumPattern.FindStringSubmatch(reason); m != nil {
+		msg := fmt.Sprintf("invalid enum value %s", m[1])
+		if values := requestEnumValues(method, field); len(values) > 0 {
+			msg += ", expected one of " + strings.Join(values, ", ")
+		}
+		return msg, true
+	}
+	if m := protojsonWellKnownPattern.FindStringSubmatch(reason); m != nil {
+		return fmt.Sprintf("invalid %s %s, %s", strings.ToLower(m[1]), m[2], wellKnownFormats[m[1]]), true
+	}
+	return "", false
+}
+
+// requestEnumValues returns the value names of the enum field at a path,
+// such as "items[2].status", of the request of method.  It returns nil if
+// the field is not an enum.
+func requestEnumValues(method string, field string) []string {
+	service, name, ok := strings.Cut(strings.TrimPrefix(method, "/"), "/")
+	if !ok || field == "" {
+		return nil
+	}
+	md, err := methodDescriptor(service, name)
+	if err != nil {
+		return nil
+	}
+	fd := fieldByPath(md.Input(), field)
+	if fd == nil || fd.Enum() == nil {
+		return nil
+	}
+	var names []string
+	values := fd.Enum().Values()
+	for i := 0; i < values.Len(); i++ {
+		if len(names) == maxExpectedEnumValues {
+			names = append(names, "...")
+			break
+		}
+		names = append(names, string(values.Get(i).Name()))
+	}
+	return names
+}
+
+// fieldByPath returns the field at a JSON path of msg.  Map fields resolve to
+// their value field, and path elements may be JSON or proto field names.
+func fieldByPath(msg protoreflect.MessageDescriptor, path string) protoreflect.FieldDescriptor {
+	var fd protoreflect.FieldDescriptor
+	inMap := false
+	for _, name := range strings.Split(jsonIndexPattern.ReplaceAllString(path, ""), ".") {
+		if inMap {
+			// name is a map key.
+			fd, inMap = fd.MapValue(), false
+		} else {
+			if msg == nil {
+				return nil
+			}
+			fields := msg.Fields()
+			if fd = fields.ByJSONName(name); fd == nil {
+				if fd = fields.ByName(protoreflect.Name(name)); fd == nil {
+					return nil
+				}
+			}
+			inMap = fd.IsMap()
+		}
+		msg = fd.Message()
+	}
+	if inMap {
+		return fd.MapValue()
+	}
+	return fd
+}
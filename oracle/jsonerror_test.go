@@ -0,0 +1,134 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package oracle
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const jsonErrorMethod = "/oracle.jsontest.OrderService/CreateOrder"
+
+// jsonErrorRequest registers a service whose request has enum and timestamp
+// fields, and returns the request descriptor.
+func jsonErrorRequest(t *testing.T) protoreflect.MessageDescriptor {
+	const name = "oracle.jsontest.CreateOrderRequest"
+	if d, err := protoregistry.GlobalFiles.FindDescriptorByName(name); err == nil {
+		return d.(protoreflect.MessageDescriptor)
+	}
+	ts := (&timestamppb.Timestamp{}).ProtoReflect().Descriptor()
+	fd, err := protodesc.NewFile(&descriptorpb.FileDescriptorProto{
+		Name:       proto.String("oracle/jsonerror_test.proto"),
+		Package:    proto.String("oracle.jsontest"),
+		Syntax:     proto.String("proto3"),
+		Dependency: []string{ts.ParentFile().Path()},
+		EnumType: []*descriptorpb.EnumDescriptorProto{{
+			Name: proto.String("OrderStatus"),
+			Value: []*descriptorpb.EnumValueDescriptorProto{
+				{Name: proto.String("ORDER_STATUS_UNSPECIFIED"), Number: proto.Int32(0)},
+				{Name: proto.String("ORDER_STATUS_OPEN"), Number: proto.Int32(1)},
+			},
+		}},
+		MessageType: []*descriptorpb.DescriptorProto{
+			{
+				Name: proto.String("Order"),
+				Field: []*descriptorpb.FieldDescriptorProto{{
+					Name:     proto.String("order_status"),
+					JsonName: proto.String("orderStatus"),
+					Number:   proto.Int32(1),
+					Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+					Type:     descriptorpb.FieldDescriptorProto_TYPE_ENUM.Enum(),
+					TypeName: proto.String(".oracle.jsontest.OrderStatus"),
+				}},
+			},
+			{
+				Name: proto.String("CreateOrderRequest"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					{
+						Name:     proto.String("order"),
+						JsonName: proto.String("order"),
+						Number:   proto.Int32(1),
+						Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+						Type:     descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum(),
+						TypeName: proto.String(".oracle.jsontest.Order"),
+					},
+					{
+						Name:     proto.String("created_at"),
+						JsonName: proto.String("createdAt"),
+						Number:   proto.Int32(2),
+						Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+						Type:     descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum(),
+						TypeName: proto.String("." + string(ts.FullName())),
+					},
+				},
+			},
+		},
+		Service: []*descriptorpb.ServiceDescriptorProto{{
+			Name: proto.String("OrderService"),
+			Method: []*descriptorpb.MethodDescriptorProto{{
+				Name:       proto.String("CreateOrder"),
+				InputType:  proto.String(".oracle.jsontest.CreateOrderRequest"),
+				OutputType: proto.String(".oracle.jsontest.CreateOrderRequest"),
+			}},
+		}},
+	}, protoregistry.GlobalFiles)
+	require.NoError(t, err)
+	require.NoError(t, protoregistry.GlobalFiles.RegisterFile(fd))
+	return fd.Messages().ByName("CreateOrderRequest")
+}
+
+func TestPrettyUnmarshalError(t *testing.T) {
+	md := jsonErrorRequest(t)
+	orc := newTestOracle(t, DefaultConfig())
+
+	// reject returns the error presented for a payload rejected by the
+	// gateway unmarshaler.
+	reject := func(method string, body string) string {
+		var rejected error
+		app := orc.rejectedPayloadMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			data, err := io.ReadAll(r.Body)
+			require.NoError(t, err)
+			err = protojson.Unmarshal(data, dynamicpb.NewMessage(md))
+			require.Error(t, err)
+			ctx, aerr := runtime.AnnotateContext(r.Context(), runtime.NewServeMux(), r, method)
+			require.NoError(t, aerr)
+			rejected = orc.rejectPayload(ctx, r, status.Error(codes.InvalidArgument, err.Error()))
+		}))
+		app.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/v1/orders", strings.NewReader(body)))
+		require.Error(t, rejected)
+		require.Equal(t, codes.InvalidArgument, status.Code(rejected))
+		return status.Convert(rejected).Message()
+	}
+
+	require.Equal(t, `invalid request field "order.orderStatus": invalid enum value "CLOSED", expected one of ORDER_STATUS_UNSPECIFIED, ORDER_STATUS_OPEN`,
+		reject(jsonErrorMethod, `{"order": {"orderStatus": "CLOSED"}}`))
+	require.Equal(t, `invalid request field "createdAt": invalid timestamp "yesterday", expected an RFC 3339 timestamp, e.g. "2024-01-31T15:04:05Z"`,
+		reject(jsonErrorMethod, `{"createdAt": "yesterday"}`))
+
+	// Unknown methods omit the expected values.
+	require.Equal(t, `invalid request field "order.orderStatus": invalid enum value "CLOSED"`,
+		reject("/oracle.jsontest.OrderService/Unknown", `{"order": {"orderStatus": "CLOSED"}}`))
+
+	// Other errors are not rewritten.
+	_, ok := prettyUnmarshalReason(jsonErrorMethod, "order", `invalid value for int64 type: "x"`)
+	require.False(t, ok)
+	reason, ok := prettyUnmarshalReason(jsonErrorMethod, "", `google.protobuf.Duration value out of range: "1e20s"`)
+	require.True(t, ok)
+	require.Equal(t, `invalid duration "1e20s", expected a number of seconds with an "s" suffix, e.g. "1.5s"`, reason)
+}
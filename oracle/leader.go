@@ -0,0 +1,244 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package oracle
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/luthersystems/svc/docstore"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// leaderLeaseKey is the document key of the leader lease.
+	leaderLeaseKey = "leader/lease.json"
+
+	defaultLeaderLease          = 15 * time.Second
+	defaultLeaderReleaseTimeout = 5 * time.Second
+)
+
+var (
+	leaderTransitionsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "leader_election_transitions_total",
+			Help: "How many times this replica acquired or lost leadership, partitioned by transition.",
+		},
+		[]string{"transition"},
+	)
+	leaderIsLeader = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "leader_election_is_leader",
+			Help: "Whether this replica is the leader (1) or not (0).",
+		},
+	)
+)
+
+// SetLeaderElection elects a leader among the oracle replicas sharing store,
+// which holds a lease renewed by the leader.  Singleton periodic tasks only
+// run on the leader; see AddPeriodicTask and IsLeader.  The lease lasts
+// LeaderLease, and replicas' clocks are assumed to agree to within a third
+// of it.
+func (c *Config) SetLeaderElection(store docstore.ConditionalStore) {
+	if c == nil {
+		return
+	}
+	c.leaderStore = store
+}
+
+// validLeaderElection validates the leader election configuration.
+func (c *Config) validLeaderElection() error {
+	if c.LeaderLease < 0 {
+		return fmt.Errorf("negative leader lease")
+	}
+	return nil
+}
+
+// leaderLease is the document held by the leader.
+type leaderLease struct {
+	Holder    string    `json:"holder"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// leaderElector acquires and renews the leader lease.
+type leaderElector struct {
+	store docstore.ConditionalStore
+	id    string
+	lease time.Duration
+	now   func() time.Time
+	log   *logrus.Entry
+
+	mut sync.Mutex
+	// etag is the entity tag of the lease last written.
+	etag string
+	// validUntil is the time until which the replica is the leader, short
+	// of the lease expiry to allow for clock skew.
+	validUntil time.Time
+	// wasLeader records leadership for transition metrics.
+	wasLeader bool
+}
+
+func newLeaderElector(cfg *Config, id string, now func() time.Time, log *logrus.Entry) *leaderElector {
+	lease := cfg.LeaderLease
+	if lease == 0 {
+		lease = defaultLeaderLease
+	}
+	if host, err := os.Hostname(); err == nil {
+		id = host + "-" + id
+	}
+	return &leaderElector{
+		store: cfg.leaderStore,
+		id:    id,
+		lease: lease,
+		now:   now,
+		log:   log.WithField("leader_id", id),
+	}
+}
+
+// renewInterval is the time between attempts to acquire or renew the lease.
+func (e *leaderElector) renewInterval() time.Duration {
+	return e.lease / 3
+}
+
+func (e *leaderElector) isLeader() bool {
+	e.mut.Lock()
+	defer e.mut.Unlock()
+	return e.now().Before(e.validUntil)
+}
+
+// tryAcquire acquires the lease if it is free or expired, or renews it if
+// the replica holds it.
+func (e *leaderElector) tryAcquire(ctx context.Context) error {
+	now := e.now()
+	body, etag, err := e.store.GetWithETag(ctx, leaderLeaseKey)
+	cond := docstore.Precondition{IfMatch: etag}
+	switch {
+	case errors.Is(err, docstore.ErrRequestNotFound):
+		cond = docstore.IfNotExists
+	case err != nil:
+		return fmt.Errorf("leader lease get: %w", err)
+	default:
+		var l leaderLease
+		if err := json.Unmarshal(body, &l); err != nil {
+			return fmt.Errorf("leader lease: %w", err)
+		}
+		if l.Holder != e.id && now.Before(l.ExpiresAt) {
+			return nil
+		}
+	}
+	b, err := json.Marshal(leaderLease{Holder: e.id, ExpiresAt: now.Add(e.lease)})
+	if err != nil {
+		return err
+	}
+	etag, err = e.store.PutIf(ctx, leaderLeaseKey, b, cond)
+	if errors.Is(err, docstore.ErrPreconditionFailed) {
+		// Another replica acquired the lease concurrently.
+		e.setValidUntil("", time.Time{})
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("leader lease put: %w", err)
+	}
+	e.setValidUntil(etag, now.Add(e.lease-e.renewInterval()))
+	return nil
+}
+
+func (e *leaderElector) setValidUntil(etag string, t time.Time) {
+	e.mut.Lock()
+	defer e.mut.Unlock()
+	e.etag = etag
+	e.validUntil = t
+}
+
+// release expires the lease held by the replica, so another replica can
+// take over without waiting for it to expire.
+func (e *leaderElector) release(ctx context.Context) error {
+	e.mut.Lock()
+	etag, leader := e.etag, e.now().Before(e.validUntil)
+	e.etag, e.validUntil = "", time.Time{}
+	e.mut.Unlock()
+	if !leader {
+		return nil
+	}
+	b, err := json.Marshal(leaderLease{Holder: e.id, ExpiresAt: e.now()})
+	if err != nil {
+		return err
+	}
+	_, err = e.store.PutIf(ctx, leaderLeaseKey, b, docstore.Precondition{IfMatch: etag})
+	if err != nil && !errors.Is(err, docstore.ErrPreconditionFailed) {
+		return fmt.Errorf("leader lease release: %w", err)
+	}
+	return nil
+}
+
+// observe records leadership transitions.
+func (e *leaderElector) observe() {
+	leader := e.isLeader()
+	e.mut.Lock()
+	changed := leader != e.wasLeader
+	e.wasLeader = leader
+	e.mut.Unlock()
+	if !changed {
+		return
+	}
+	if leader {
+		leaderTransitionsTotal.WithLabelValues("acquired").Inc()
+		leaderIsLeader.Set(1)
+		e.log.Infof("leadership acquired")
+		return
+	}
+	leaderTransitionsTotal.WithLabelValues("lost").Inc()
+	leaderIsLeader.Set(0)
+	e.log.Warnf("leadership lost")
+}
+
+// run acquires and renews the lease until ctx is done, then releases it.
+func (e *leaderElector) run(ctx context.Context) {
+	ticker := time.NewTicker(e.renewInterval())
+	defer ticker.Stop()
+	for {
+		if err := e.tryAcquire(ctx); err != nil && ctx.Err() == nil {
+			e.log.WithError(err).Warnf("leader election failed")
+		}
+		e.observe()
+		select {
+		case <-ctx.Done():
+			releaseCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), defaultLeaderReleaseTimeout)
+			if err := e.release(releaseCtx); err != nil {
+				e.log.WithError(err).Warnf("leader release failed")
+			}
+			cancel()
+			e.observe()
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// runLeaderElection takes part in leader election while ctx is not done,
+// if configured.  The returned channel is closed once the lease has been
+// released.
+func (orc *Oracle) runLeaderElection(ctx context.Context) <-chan struct{} {
+	done := make(chan struct{})
+	if orc.leader == nil {
+		close(done)
+		return done
+	}
+	go func() {
+		defer close(done)
+		orc.leader.run(ctx)
+	}()
+	return done
+}
+
+// IsLeader returns true if this replica is the elected leader.  It returns
+// false if leader election is not configured; see SetLeaderElection.
+func (orc *Oracle) IsLeader() bool {
+	return orc.leader != nil && orc.leader.isLeader()
+}
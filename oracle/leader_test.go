@@ -0,0 +1,135 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package oracle
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/luthersystems/svc/docstore"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+// memLeases is a ConditionalStore whose entity tags are versions.
+type memLeases struct {
+	mut      sync.Mutex
+	docs     map[string][]byte
+	versions map[string]int
+}
+
+func newMemLeases() *memLeases {
+	return &memLeases{docs: map[string][]byte{}, versions: map[string]int{}}
+}
+
+func (m *memLeases) GetWithETag(ctx context.Context, key string) ([]byte, string, error) {
+	m.mut.Lock()
+	defer m.mut.Unlock()
+	b, ok := m.docs[key]
+	if !ok {
+		return nil, "", docstore.ErrRequestNotFound
+	}
+	return b, strconv.Itoa(m.versions[key]), nil
+}
+
+func (m *memLeases) PutIf(ctx context.Context, key string, body []byte, cond docstore.Precondition) (string, error) {
+	m.mut.Lock()
+	defer m.mut.Unlock()
+	_, exists := m.docs[key]
+	if (cond == docstore.IfNotExists && exists) || (cond.IfMatch != "" && cond.IfMatch != strconv.Itoa(m.versions[key])) {
+		return "", docstore.ErrPreconditionFailed
+	}
+	m.docs[key] = body
+	m.versions[key]++
+	return strconv.Itoa(m.versions[key]), nil
+}
+
+func TestLeaderElection(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+	cfg := DefaultConfig()
+	cfg.SetLeaderElection(newMemLeases())
+	cfg.LeaderLease = 30 * time.Second
+	log := logrus.NewEntry(logrus.New())
+	a := newLeaderElector(cfg, "a", clock, log)
+	b := newLeaderElector(cfg, "b", clock, log)
+	ctx := context.Background()
+	acquired := testutil.ToFloat64(leaderTransitionsTotal.WithLabelValues("acquired"))
+
+	require.NoError(t, a.tryAcquire(ctx))
+	require.NoError(t, b.tryAcquire(ctx))
+	a.observe()
+	require.True(t, a.isLeader())
+	require.False(t, b.isLeader())
+	require.Equal(t, acquired+1, testutil.ToFloat64(leaderTransitionsTotal.WithLabelValues("acquired")))
+
+	// The leader renews its lease.
+	now = now.Add(a.renewInterval())
+	require.NoError(t, a.tryAcquire(ctx))
+	require.NoError(t, b.tryAcquire(ctx))
+	require.True(t, a.isLeader())
+	require.False(t, b.isLeader())
+
+	// A leader which fails to renew steps down before its lease expires,
+	// and another replica takes over once it has.
+	now = now.Add(cfg.LeaderLease - a.renewInterval())
+	require.False(t, a.isLeader())
+	require.NoError(t, b.tryAcquire(ctx))
+	require.False(t, b.isLeader())
+	now = now.Add(a.renewInterval())
+	require.NoError(t, b.tryAcquire(ctx))
+	require.True(t, b.isLeader())
+	require.NoError(t, a.tryAcquire(ctx))
+	require.False(t, a.isLeader())
+	lost := testutil.ToFloat64(leaderTransitionsTotal.WithLabelValues("lost"))
+	a.observe()
+	require.Equal(t, lost+1, testutil.ToFloat64(leaderTransitionsTotal.WithLabelValues("lost")))
+
+	// A released lease is acquired without waiting for it to expire.
+	require.NoError(t, b.release(ctx))
+	require.False(t, b.isLeader())
+	require.NoError(t, a.tryAcquire(ctx))
+	require.True(t, a.isLeader())
+}
+
+func TestPeriodicTasks(t *testing.T) {
+	var runs []string
+	run := func(ctx context.Context) error {
+		runs = append(runs, "run")
+		return nil
+	}
+	cfg := DefaultConfig()
+	cfg.AddPeriodicTask(PeriodicTask{Name: "cleanup", Schedule: "@hourly", Run: run, Singleton: true})
+	require.ErrorContains(t, cfg.Valid(), "singleton requires leader election")
+	cfg.SetLeaderElection(newMemLeases())
+	require.NoError(t, cfg.Valid())
+	cfg.AddPeriodicTask(PeriodicTask{Name: "cleanup", Schedule: "@hourly", Run: run})
+	require.ErrorContains(t, cfg.Valid(), "duplicate name")
+
+	cfg = DefaultConfig()
+	cfg.SetLeaderElection(newMemLeases())
+	singleton := PeriodicTask{Name: "singleton", Schedule: "@hourly", Run: run, Singleton: true}
+	errFail := errors.New("fail")
+	failing := PeriodicTask{Name: "failing", Schedule: "@hourly", Run: func(ctx context.Context) error { return errFail }}
+	cfg.AddPeriodicTask(singleton)
+	cfg.AddPeriodicTask(failing)
+	orc := newTestOracle(t, cfg)
+	ctx := context.Background()
+
+	// Singleton tasks are skipped unless the replica is the leader.
+	require.NoError(t, orc.runPeriodicTask(ctx, singleton, time.Now()))
+	require.Empty(t, runs)
+	require.Equal(t, float64(1), testutil.ToFloat64(periodicTaskRunsTotal.WithLabelValues("singleton", "skipped")))
+	require.NoError(t, orc.leader.tryAcquire(ctx))
+	require.True(t, orc.IsLeader())
+	require.NoError(t, orc.runPeriodicTask(ctx, singleton, time.Now()))
+	require.Equal(t, []string{"run"}, runs)
+
+	require.ErrorIs(t, orc.runPeriodicTask(ctx, failing, time.Now()), errFail)
+	require.Equal(t, float64(1), testutil.ToFloat64(periodicTaskRunsTotal.WithLabelValues("failing", "failure")))
+}
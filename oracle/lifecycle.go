@@ -0,0 +1,84 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package oracle
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+// Run starts the gateway and stops it gracefully when the process receives
+// SIGTERM or SIGINT, as sent by systemd and kubernetes.  On the first signal
+// the health check immediately reports the oracle as DOWN so that load
+// balancers stop routing new requests to it.  After the configured
+// PreStopDelay the context of StartGateway is cancelled, and in-flight
+// requests are allowed to finish within the ShutdownTimeout.  A second
+// signal skips the remaining delay.
+//
+// Run returns when the gateway stops, or when ctx is done.
+func (orc *Oracle) Run(ctx context.Context, grpcConfig GrpcGatewayConfig) error {
+	sigs := make(chan os.Signal, 2)
+	signal.Notify(sigs, syscall.SIGTERM, syscall.SIGINT)
+	defer signal.Stop(sigs)
+	return orc.runUntilSignal(ctx, sigs, func(ctx context.Context) error {
+		return orc.StartGateway(ctx, grpcConfig)
+	})
+}
+
+// runUntilSignal runs start until it returns, stopping it gracefully once
+// a signal is received.
+func (orc *Oracle) runUntilSignal(ctx context.Context, sigs <-chan os.Signal, start func(context.Context) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	done := make(chan error, 1)
+	go func() {
+		done <- start(ctx)
+	}()
+	select {
+	case err := <-done:
+		return err
+	case sig := <-sigs:
+		orc.stopping.Store(true)
+		orc.log(ctx).WithField("signal", sig.String()).Infof("oracle stopping")
+	}
+	if delay := orc.cfg.PreStopDelay; delay > 0 {
+		orc.log(ctx).WithField("pre_stop_delay", delay.String()).Infof("waiting for load balancers to drain")
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-sigs:
+			orc.log(ctx).Warnf("signal received during pre-stop delay, stopping now")
+		case err := <-done:
+			return err
+		}
+	}
+	cancel()
+	return <-done
+}
+
+// Stopping returns true once the oracle received a signal to stop.
+func (orc *Oracle) Stopping() bool {
+	return orc.stopping.Load()
+}
+
+// stopGRPCServer stops s gracefully, waiting for pending RPCs to finish until
+// ctx is done, after which remaining RPCs and streams are cancelled.
+func stopGRPCServer(ctx context.Context, s *grpc.Server) {
+	stopped := make(chan struct{})
+	go func() {
+		s.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		s.Stop()
+		<-stopped
+	}
+}
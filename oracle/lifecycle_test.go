@@ -0,0 +1,113 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package oracle
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func TestRunUntilSignal(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.PreStopDelay = 100 * time.Millisecond
+	orc := newTestOracle(t, cfg)
+
+	sigs := make(chan os.Signal, 1)
+	started := make(chan struct{})
+	var cancelled time.Time
+	done := make(chan error, 1)
+	go func() {
+		done <- orc.runUntilSignal(context.Background(), sigs, func(ctx context.Context) error {
+			close(started)
+			<-ctx.Done()
+			cancelled = time.Now()
+			return nil
+		})
+	}()
+	<-started
+	require.False(t, orc.Stopping())
+
+	signalled := time.Now()
+	sigs <- syscall.SIGTERM
+	require.Eventually(t, orc.Stopping, time.Second, time.Millisecond)
+
+	// The oracle is reported DOWN while it drains.
+	w := httptest.NewRecorder()
+	orc.healthCheckHandler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, healthCheckPath, nil))
+	require.Equal(t, http.StatusServiceUnavailable, w.Code)
+	require.Contains(t, w.Body.String(), "DOWN")
+
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("oracle did not stop")
+	}
+	require.GreaterOrEqual(t, cancelled.Sub(signalled), cfg.PreStopDelay)
+}
+
+func TestRunUntilSignalSecondSignal(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.PreStopDelay = time.Hour
+	orc := newTestOracle(t, cfg)
+
+	sigs := make(chan os.Signal, 2)
+	sigs <- syscall.SIGTERM
+	sigs <- syscall.SIGINT
+	err := orc.runUntilSignal(context.Background(), sigs, func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	require.ErrorIs(t, err, context.Canceled)
+	require.True(t, orc.Stopping())
+}
+
+func TestStopGRPCServerTimeout(t *testing.T) {
+	server := grpc.NewServer()
+	healthpb.RegisterHealthServer(server, health.NewServer())
+	lis := bufconn.Listen(1 << 20)
+	go func() {
+		_ = server.Serve(lis)
+	}()
+	conn, err := grpc.NewClient("passthrough:///health",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer conn.Close()
+
+	// A watch stream never finishes, so the server cannot stop gracefully.
+	stream, err := healthpb.NewHealthClient(conn).Watch(context.Background(), &healthpb.HealthCheckRequest{})
+	require.NoError(t, err)
+	_, err = stream.Recv()
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	stopped := make(chan struct{})
+	go func() {
+		stopGRPCServer(ctx, server)
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("grpc server did not stop")
+	}
+	_, err = stream.Recv()
+	require.Error(t, err)
+}
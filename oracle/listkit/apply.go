@@ -0,0 +1,96 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package listkit
+
+import (
+	"fmt"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// Field names of phylum query requests set by Apply, in order of
+// preference.
+var (
+	applyLimitFields  = []protoreflect.Name{"page_size", "limit"}
+	applyOffsetFields = []protoreflect.Name{"offset"}
+	applyCursorFields = []protoreflect.Name{"bookmark", "cursor"}
+	applyOrderFields  = []protoreflect.Name{"order_by"}
+	applyFilterFields = []protoreflect.Name{"filter"}
+)
+
+// Apply sets the conventional query fields of a phylum request message from
+// q: the page size is set on page_size or limit, the offset on offset, the
+// cursor on bookmark or cursor, and the canonical order and filters on
+// order_by and filter.  Fields which the message does not define are
+// skipped, but an error is returned if the query cannot be represented, for
+// instance because it is ordered and the message has no order_by field.
+func (q *Query) Apply(req proto.Message) error {
+	msg := req.ProtoReflect()
+	fields := msg.Descriptor().Fields()
+	if fd := findField(fields, applyLimitFields); fd != nil {
+		if err := setInt(msg, fd, int64(q.PageSize)); err != nil {
+			return err
+		}
+	}
+	if fd := findField(fields, applyOffsetFields); fd != nil {
+		if err := setInt(msg, fd, q.Offset); err != nil {
+			return err
+		}
+	} else if q.Offset > 0 && q.Cursor == "" {
+		return fmt.Errorf("listkit: %s has no offset field", msg.Descriptor().FullName())
+	}
+	if q.Cursor != "" {
+		if err := setString(msg, findField(fields, applyCursorFields), "cursor", q.Cursor); err != nil {
+			return err
+		}
+	}
+	if len(q.OrderBy) > 0 {
+		if err := setString(msg, findField(fields, applyOrderFields), "order_by", q.OrderByString()); err != nil {
+			return err
+		}
+	}
+	if len(q.Filters) > 0 {
+		if err := setString(msg, findField(fields, applyFilterFields), "filter", q.FilterString()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// findField returns the first singular field of fields with one of names.
+func findField(fields protoreflect.FieldDescriptors, names []protoreflect.Name) protoreflect.FieldDescriptor {
+	for _, name := range names {
+		if fd := fields.ByName(name); fd != nil && fd.Cardinality() != protoreflect.Repeated {
+			return fd
+		}
+	}
+	return nil
+}
+
+func setInt(msg protoreflect.Message, fd protoreflect.FieldDescriptor, v int64) error {
+	switch fd.Kind() {
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		msg.Set(fd, protoreflect.ValueOfInt32(int32(v)))
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		msg.Set(fd, protoreflect.ValueOfInt64(v))
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		msg.Set(fd, protoreflect.ValueOfUint32(uint32(v)))
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		msg.Set(fd, protoreflect.ValueOfUint64(uint64(v)))
+	default:
+		return fmt.Errorf("listkit: field %s is not an integer", fd.FullName())
+	}
+	return nil
+}
+
+func setString(msg protoreflect.Message, fd protoreflect.FieldDescriptor, what string, v string) error {
+	if fd == nil {
+		return fmt.Errorf("listkit: %s has no %s field", msg.Descriptor().FullName(), what)
+	}
+	if fd.Kind() != protoreflect.StringKind {
+		return fmt.Errorf("listkit: field %s is not a string", fd.FullName())
+	}
+	msg.Set(fd, protoreflect.ValueOfString(v))
+	return nil
+}
@@ -0,0 +1,317 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+// Package listkit standardizes the pagination, filtering and sorting of list
+// endpoints.  A Lister parses the conventional page_size, page_token,
+// order_by and filter request fields (AIP-132 and a subset of AIP-160),
+// validates them against the fields a list endpoint supports, and encodes
+// opaque, HMAC-signed page tokens.  Parsed queries are applied to phylum
+// query requests with Apply, so that list endpoints behave consistently
+// across services.
+//
+// Invalid requests result in *svcerr.BusinessError errors, which may be
+// returned by handlers as is.
+package listkit
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/luthersystems/svc/svcerr"
+)
+
+const (
+	// DefaultPageSize is the page size of requests without one, unless
+	// configured.
+	DefaultPageSize = 50
+
+	// DefaultMaxPageSize is the maximum page size, unless configured.
+	DefaultMaxPageSize = 1000
+)
+
+// Config configures a Lister.
+type Config struct {
+	// Secret is the key signing page tokens.  It is required.
+	Secret []byte
+	// DefaultPageSize is the page size of requests without one.  Defaults
+	// to DefaultPageSize.
+	DefaultPageSize int32
+	// MaxPageSize is the maximum page size.  Larger page sizes are clamped.
+	// Defaults to DefaultMaxPageSize.
+	MaxPageSize int32
+	// SortFields are the fields which results may be ordered by.
+	SortFields []string
+	// DefaultOrder is the order of requests without order_by.
+	DefaultOrder []Order
+	// FilterFields are the fields which results may be filtered by.
+	FilterFields []string
+	// TokenTTL is the time after which page tokens expire.  Tokens do not
+	// expire if it is zero.
+	TokenTTL time.Duration
+}
+
+// Lister parses and validates list requests.
+type Lister struct {
+	cfg     Config
+	sorts   map[string]bool
+	filters map[string]bool
+	now     func() time.Time
+}
+
+// NewLister returns a lister.
+func NewLister(cfg Config) (*Lister, error) {
+	if len(cfg.Secret) == 0 {
+		return nil, fmt.Errorf("listkit: missing secret")
+	}
+	if cfg.DefaultPageSize < 0 || cfg.MaxPageSize < 0 {
+		return nil, fmt.Errorf("listkit: negative page size")
+	}
+	if cfg.MaxPageSize == 0 {
+		cfg.MaxPageSize = DefaultMaxPageSize
+	}
+	if cfg.DefaultPageSize == 0 {
+		cfg.DefaultPageSize = min(DefaultPageSize, cfg.MaxPageSize)
+	}
+	if cfg.DefaultPageSize > cfg.MaxPageSize {
+		return nil, fmt.Errorf("listkit: default page size exceeds max page size")
+	}
+	if cfg.TokenTTL < 0 {
+		return nil, fmt.Errorf("listkit: negative token ttl")
+	}
+	l := &Lister{
+		cfg:     cfg,
+		sorts:   fieldSet(cfg.SortFields),
+		filters: fieldSet(cfg.FilterFields),
+		now:     time.Now,
+	}
+	for _, o := range cfg.DefaultOrder {
+		if !l.sorts[o.Field] {
+			return nil, fmt.Errorf("listkit: default order field %q is not a sort field", o.Field)
+		}
+	}
+	return l, nil
+}
+
+func fieldSet(fields []string) map[string]bool {
+	set := make(map[string]bool, len(fields))
+	for _, f := range fields {
+		set[f] = true
+	}
+	return set
+}
+
+// Params are the conventional fields of list request messages.  Generated
+// proto messages with page_size, page_token, order_by and filter fields
+// implement it.
+type Params interface {
+	GetPageSize() int32
+	GetPageToken() string
+	GetOrderBy() string
+	GetFilter() string
+}
+
+// Order is a sort order.
+type Order struct {
+	// Field is the sorted field.
+	Field string
+	// Desc sorts in descending order.
+	Desc bool
+}
+
+// Operator is a filter comparison operator.
+type Operator string
+
+// Filter operators.
+const (
+	OpEqual        Operator = "="
+	OpNotEqual     Operator = "!="
+	OpLess         Operator = "<"
+	OpLessEqual    Operator = "<="
+	OpGreater      Operator = ">"
+	OpGreaterEqual Operator = ">="
+	// OpHas matches fields containing the value, such as repeated fields.
+	OpHas Operator = ":"
+)
+
+// Filter is a filter restriction.  Restrictions of a query are combined
+// with AND.
+type Filter struct {
+	Field string
+	Op    Operator
+	Value string
+}
+
+// Query is a parsed list request.
+type Query struct {
+	// PageSize is the number of results requested.
+	PageSize int32
+	// Offset is the number of results skipped.
+	Offset int64
+	// Cursor is the opaque position returned by the phylum for the previous
+	// page, if any.  It takes precedence over Offset.
+	Cursor string
+	// OrderBy is the order of results.
+	OrderBy []Order
+	// Filters restrict results.
+	Filters []Filter
+}
+
+// Parse parses the list fields of a request message.
+func (l *Lister) Parse(p Params) (*Query, error) {
+	return l.parse(p.GetPageSize(), p.GetPageToken(), p.GetOrderBy(), p.GetFilter())
+}
+
+// ParseValues parses the page_size, page_token, order_by and filter query
+// parameters of a request URL.
+func (l *Lister) ParseValues(v url.Values) (*Query, error) {
+	var size int32
+	if s := v.Get("page_size"); s != "" {
+		n, err := strconv.ParseInt(s, 10, 32)
+		if err != nil {
+			return nil, svcerr.NewBusinessError("invalid page_size")
+		}
+		size = int32(n)
+	}
+	return l.parse(size, v.Get("page_token"), v.Get("order_by"), v.Get("filter"))
+}
+
+func (l *Lister) parse(size int32, token string, orderBy string, filter string) (*Query, error) {
+	if size < 0 {
+		return nil, svcerr.NewBusinessError("invalid page_size: must not be negative")
+	}
+	q := &Query{PageSize: size}
+	if q.PageSize == 0 {
+		q.PageSize = l.cfg.DefaultPageSize
+	}
+	q.PageSize = min(q.PageSize, l.cfg.MaxPageSize)
+	var err error
+	if q.OrderBy, err = l.parseOrderBy(orderBy); err != nil {
+		return nil, err
+	}
+	if q.Filters, err = l.parseFilter(filter); err != nil {
+		return nil, err
+	}
+	if token != "" {
+		t, err := l.decodeToken(token)
+		if err != nil {
+			return nil, err
+		}
+		if t.Query != q.hash() {
+			return nil, svcerr.NewBusinessError("invalid page_token: order_by and filter must not change between pages")
+		}
+		q.Offset = t.Offset
+		q.Cursor = t.Cursor
+	}
+	return q, nil
+}
+
+// parseOrderBy parses an order_by field, e.g. "create_time desc, name".
+func (l *Lister) parseOrderBy(s string) ([]Order, error) {
+	if strings.TrimSpace(s) == "" {
+		return append([]Order(nil), l.cfg.DefaultOrder...), nil
+	}
+	var orders []Order
+	seen := make(map[string]bool)
+	for _, part := range strings.Split(s, ",") {
+		words := strings.Fields(part)
+		if len(words) == 0 || len(words) > 2 {
+			return nil, svcerr.NewBusinessError(fmt.Sprintf("invalid order_by: %q", strings.TrimSpace(part)))
+		}
+		o := Order{Field: words[0]}
+		if len(words) == 2 {
+			switch strings.ToLower(words[1]) {
+			case "asc":
+			case "desc":
+				o.Desc = true
+			default:
+				return nil, svcerr.NewBusinessError(fmt.Sprintf("invalid order_by direction: %q", words[1]))
+			}
+		}
+		if !l.sorts[o.Field] {
+			return nil, svcerr.NewBusinessError(fmt.Sprintf("invalid order_by: cannot sort by %q", o.Field))
+		}
+		if seen[o.Field] {
+			return nil, svcerr.NewBusinessError(fmt.Sprintf("invalid order_by: duplicate field %q", o.Field))
+		}
+		seen[o.Field] = true
+		orders = append(orders, o)
+	}
+	return orders, nil
+}
+
+// filterPattern matches a filter restriction, e.g. `state = "ACTIVE"`.
+var filterPattern = regexp.MustCompile(`^\s*([A-Za-z_][A-Za-z0-9_.]*)\s*(<=|>=|!=|=|<|>|:)\s*(.*?)\s*$`)
+
+// parseFilter parses a filter field of restrictions combined with AND, e.g.
+// `state = "ACTIVE" AND amount >= 100`.
+func (l *Lister) parseFilter(s string) ([]Filter, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+	var filters []Filter
+	for _, part := range splitAnd(s) {
+		m := filterPattern.FindStringSubmatch(part)
+		if m == nil || m[3] == "" {
+			return nil, svcerr.NewBusinessError(fmt.Sprintf("invalid filter: %q", strings.TrimSpace(part)))
+		}
+		f := Filter{Field: m[1], Op: Operator(m[2]), Value: m[3]}
+		if strings.HasPrefix(f.Value, `"`) {
+			v, err := strconv.Unquote(f.Value)
+			if err != nil {
+				return nil, svcerr.NewBusinessError(fmt.Sprintf("invalid filter value: %s", f.Value))
+			}
+			f.Value = v
+		}
+		if !l.filters[f.Field] {
+			return nil, svcerr.NewBusinessError(fmt.Sprintf("invalid filter: cannot filter by %q", f.Field))
+		}
+		filters = append(filters, f)
+	}
+	return filters, nil
+}
+
+// splitAnd splits a filter on AND operators outside of quoted values.
+func splitAnd(s string) []string {
+	var parts []string
+	start := 0
+	quoted := false
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && quoted:
+			i++
+		case s[i] == '"':
+			quoted = !quoted
+		case !quoted && strings.HasPrefix(s[i:], " AND "):
+			parts = append(parts, s[start:i])
+			start = i + len(" AND ")
+			i = start - 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// OrderByString returns the canonical form of the order of q, e.g.
+// "create_time desc,name".
+func (q *Query) OrderByString() string {
+	parts := make([]string, len(q.OrderBy))
+	for i, o := range q.OrderBy {
+		parts[i] = o.Field
+		if o.Desc {
+			parts[i] += " desc"
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+// FilterString returns the canonical form of the filters of q, e.g.
+// `state="ACTIVE" AND amount>="100"`.
+func (q *Query) FilterString() string {
+	parts := make([]string, len(q.Filters))
+	for i, f := range q.Filters {
+		parts[i] = f.Field + string(f.Op) + strconv.Quote(f.Value)
+	}
+	return strings.Join(parts, " AND ")
+}
@@ -0,0 +1,202 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package listkit
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/luthersystems/svc/svcerr"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+type params struct {
+	size    int32
+	token   string
+	orderBy string
+	filter  string
+}
+
+func (p params) GetPageSize() int32   { return p.size }
+func (p params) GetPageToken() string { return p.token }
+func (p params) GetOrderBy() string   { return p.orderBy }
+func (p params) GetFilter() string    { return p.filter }
+
+func newTestLister(t *testing.T) *Lister {
+	l, err := NewLister(Config{
+		Secret:       []byte("secret"),
+		MaxPageSize:  100,
+		SortFields:   []string{"create_time", "name"},
+		DefaultOrder: []Order{{Field: "create_time", Desc: true}},
+		FilterFields: []string{"state", "amount"},
+		TokenTTL:     time.Hour,
+	})
+	require.NoError(t, err)
+	return l
+}
+
+func requireBusinessError(t *testing.T, err error) {
+	t.Helper()
+	var be *svcerr.BusinessError
+	require.ErrorAs(t, err, &be)
+}
+
+func TestNewLister(t *testing.T) {
+	_, err := NewLister(Config{})
+	require.Error(t, err)
+	_, err = NewLister(Config{Secret: []byte("s"), DefaultPageSize: 10, MaxPageSize: 5})
+	require.Error(t, err)
+	_, err = NewLister(Config{Secret: []byte("s"), DefaultOrder: []Order{{Field: "name"}}})
+	require.Error(t, err)
+	l, err := NewLister(Config{Secret: []byte("s"), MaxPageSize: 20})
+	require.NoError(t, err)
+	q, err := l.Parse(params{})
+	require.NoError(t, err)
+	require.Equal(t, int32(20), q.PageSize)
+}
+
+func TestParse(t *testing.T) {
+	l := newTestLister(t)
+
+	q, err := l.Parse(params{})
+	require.NoError(t, err)
+	require.Equal(t, int32(DefaultPageSize), q.PageSize)
+	require.Equal(t, "create_time desc", q.OrderByString())
+	require.Empty(t, q.Filters)
+
+	q, err = l.Parse(params{size: 500, orderBy: "name, create_time DESC", filter: `state = "ACTIVE AND PENDING" AND amount >= 100`})
+	require.NoError(t, err)
+	require.Equal(t, int32(100), q.PageSize)
+	require.Equal(t, []Order{{Field: "name"}, {Field: "create_time", Desc: true}}, q.OrderBy)
+	require.Equal(t, []Filter{
+		{Field: "state", Op: OpEqual, Value: "ACTIVE AND PENDING"},
+		{Field: "amount", Op: OpGreaterEqual, Value: "100"},
+	}, q.Filters)
+	require.Equal(t, `state="ACTIVE AND PENDING" AND amount>="100"`, q.FilterString())
+
+	for _, p := range []params{
+		{size: -1},
+		{orderBy: "owner"},
+		{orderBy: "name sideways"},
+		{orderBy: "name, name desc"},
+		{filter: "owner = bob"},
+		{filter: "state ="},
+		{filter: `state = "unterminated`},
+		{token: "garbage"},
+	} {
+		_, err := l.Parse(p)
+		requireBusinessError(t, err)
+	}
+
+	q, err = l.ParseValues(url.Values{"page_size": {"10"}, "order_by": {"name"}})
+	require.NoError(t, err)
+	require.Equal(t, int32(10), q.PageSize)
+	_, err = l.ParseValues(url.Values{"page_size": {"ten"}})
+	requireBusinessError(t, err)
+}
+
+func TestPageToken(t *testing.T) {
+	l := newTestLister(t)
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	l.now = func() time.Time { return now }
+
+	first := params{size: 10, filter: `state = "ACTIVE"`}
+	q, err := l.Parse(first)
+	require.NoError(t, err)
+
+	// The last page has no next page.
+	token, err := l.NextPageToken(q, 5, "")
+	require.NoError(t, err)
+	require.Empty(t, token)
+
+	token, err = l.NextPageToken(q, 10, "")
+	require.NoError(t, err)
+	require.NotEmpty(t, token)
+	next := first
+	next.token = token
+	q2, err := l.Parse(next)
+	require.NoError(t, err)
+	require.Equal(t, int64(10), q2.Offset)
+
+	token, err = l.NextPageToken(q2, 3, "bookmark-1")
+	require.NoError(t, err)
+	next.token = token
+	q3, err := l.Parse(next)
+	require.NoError(t, err)
+	require.Equal(t, "bookmark-1", q3.Cursor)
+
+	// Tokens are only valid for the same query.
+	changed := next
+	changed.filter = `state = "CLOSED"`
+	_, err = l.Parse(changed)
+	requireBusinessError(t, err)
+
+	// Tampered tokens are rejected.
+	tampered := next
+	tampered.token = "x" + token
+	_, err = l.Parse(tampered)
+	requireBusinessError(t, err)
+
+	// Tokens expire.
+	now = now.Add(2 * time.Hour)
+	_, err = l.Parse(next)
+	requireBusinessError(t, err)
+}
+
+func newQueryRequest(t *testing.T) *dynamicpb.Message {
+	field := func(name string, number int32, typ descriptorpb.FieldDescriptorProto_Type) *descriptorpb.FieldDescriptorProto {
+		return &descriptorpb.FieldDescriptorProto{
+			Name:     proto.String(name),
+			JsonName: proto.String(name),
+			Number:   proto.Int32(number),
+			Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+			Type:     typ.Enum(),
+		}
+	}
+	fdp := &descriptorpb.FileDescriptorProto{
+		Name:    proto.String("listkit/listkit_test.proto"),
+		Package: proto.String("listkit.test"),
+		Syntax:  proto.String("proto3"),
+		MessageType: []*descriptorpb.DescriptorProto{{
+			Name: proto.String("QueryRequest"),
+			Field: []*descriptorpb.FieldDescriptorProto{
+				field("limit", 1, descriptorpb.FieldDescriptorProto_TYPE_UINT32),
+				field("offset", 2, descriptorpb.FieldDescriptorProto_TYPE_INT64),
+				field("bookmark", 3, descriptorpb.FieldDescriptorProto_TYPE_STRING),
+				field("order_by", 4, descriptorpb.FieldDescriptorProto_TYPE_STRING),
+			},
+		}},
+	}
+	fd, err := protodesc.NewFile(fdp, protoregistry.GlobalFiles)
+	require.NoError(t, err)
+	return dynamicpb.NewMessage(fd.Messages().ByName(protoreflect.Name("QueryRequest")))
+}
+
+func TestApply(t *testing.T) {
+	req := newQueryRequest(t)
+	get := func(name string) interface{} {
+		return req.Get(req.Descriptor().Fields().ByName(protoreflect.Name(name))).Interface()
+	}
+	q := &Query{
+		PageSize: 25,
+		Offset:   50,
+		Cursor:   "bm",
+		OrderBy:  []Order{{Field: "name", Desc: true}},
+	}
+	require.NoError(t, q.Apply(req))
+	require.Equal(t, uint32(25), get("limit"))
+	require.Equal(t, int64(50), get("offset"))
+	require.Equal(t, "bm", get("bookmark"))
+	require.Equal(t, "name desc", get("order_by"))
+
+	// The request cannot be filtered.
+	q.Filters = []Filter{{Field: "state", Op: OpEqual, Value: "ACTIVE"}}
+	require.Error(t, q.Apply(newQueryRequest(t)))
+}
@@ -0,0 +1,94 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package listkit
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+
+	"github.com/luthersystems/svc/svcerr"
+)
+
+// pageToken is the signed content of a page token.
+type pageToken struct {
+	// Offset is the number of results skipped.
+	Offset int64 `json:"o,omitempty"`
+	// Cursor is the phylum position of the next page.
+	Cursor string `json:"c,omitempty"`
+	// Query is the hash of the order and filters of the query, which must
+	// not change between pages.
+	Query string `json:"q"`
+	// Expires is the unix time after which the token is invalid.
+	Expires int64 `json:"e,omitempty"`
+}
+
+// hash returns a short hash of the order and filters of q.
+func (q *Query) hash() string {
+	h := sha256.Sum256([]byte(q.OrderByString() + "\n" + q.FilterString()))
+	return hex.EncodeToString(h[:8])
+}
+
+// NextPageToken returns the page token of the page following q, which
+// returned n results.  If the phylum returned a cursor (bookmark) for the
+// next page it is used, otherwise the next page starts at the next offset.
+// An empty token is returned on the last page, when no cursor is returned
+// and fewer than PageSize results were returned.
+func (l *Lister) NextPageToken(q *Query, n int, cursor string) (string, error) {
+	t := pageToken{Query: q.hash()}
+	switch {
+	case cursor != "":
+		t.Cursor = cursor
+	case n >= int(q.PageSize) && n > 0:
+		t.Offset = q.Offset + int64(n)
+	default:
+		return "", nil
+	}
+	if l.cfg.TokenTTL > 0 {
+		t.Expires = l.now().Add(l.cfg.TokenTTL).Unix()
+	}
+	return l.encodeToken(&t)
+}
+
+func (l *Lister) encodeToken(t *pageToken) (string, error) {
+	b, err := json.Marshal(t)
+	if err != nil {
+		return "", err
+	}
+	enc := base64.RawURLEncoding
+	return enc.EncodeToString(b) + "." + enc.EncodeToString(l.sign(b)), nil
+}
+
+func (l *Lister) decodeToken(s string) (*pageToken, error) {
+	invalid := svcerr.NewBusinessError("invalid page_token")
+	payload, sig, ok := strings.Cut(s, ".")
+	if !ok {
+		return nil, invalid
+	}
+	enc := base64.RawURLEncoding
+	b, err := enc.DecodeString(payload)
+	if err != nil {
+		return nil, invalid
+	}
+	mac, err := enc.DecodeString(sig)
+	if err != nil || !hmac.Equal(mac, l.sign(b)) {
+		return nil, invalid
+	}
+	t := &pageToken{}
+	if err := json.Unmarshal(b, t); err != nil || t.Offset < 0 {
+		return nil, invalid
+	}
+	if t.Expires > 0 && l.now().Unix() > t.Expires {
+		return nil, svcerr.NewBusinessError("expired page_token")
+	}
+	return t, nil
+}
+
+func (l *Lister) sign(b []byte) []byte {
+	mac := hmac.New(sha256.New, l.cfg.Secret)
+	mac.Write(b)
+	return mac.Sum(nil)
+}
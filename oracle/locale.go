@@ -0,0 +1,259 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package oracle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/luthersystems/svc/locale"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	// LocaleHeader is the request header explicitly selecting the language
+	// of a request, e.g. "fr-CA".
+	LocaleHeader = "X-Locale"
+	// TimeZoneHeader is the request header selecting the time zone of a
+	// request, e.g. "Europe/London".
+	TimeZoneHeader = "Time-Zone"
+
+	acceptLanguageHeader = "Accept-Language"
+
+	defaultLocaleQueryParam   = "locale"
+	defaultTimeZoneQueryParam = "tz"
+	// The default claims are the standard OpenID Connect claims.
+	defaultLocaleClaim   = "locale"
+	defaultTimeZoneClaim = "zoneinfo"
+
+	// maxCachedLocations bounds the time zones cached by name.  It exceeds
+	// the number of IANA time zones, so the cache is only reset when
+	// clients send many invalid names.
+	maxCachedLocations = 1024
+)
+
+// LocaleConfig configures the resolution of the language and time zone of
+// requests.  The language is the supported language best matching, in order
+// of precedence, the query parameter or LocaleHeader, the claim of the user,
+// and the Accept-Language header.  The time zone is, in order of precedence,
+// the query parameter or TimeZoneHeader, the claim of the user, and the
+// default time zone.  The resolved locale is stored in the request context,
+// see locale.FromContext.
+type LocaleConfig struct {
+	// Languages are the supported languages, as BCP 47 tags.  The first is
+	// the default.  Locales are not resolved if empty.
+	Languages []string `yaml:"languages"`
+	// TimeZone is the default time zone, e.g. "Europe/London".  Defaults to
+	// UTC.
+	TimeZone string `yaml:"time-zone"`
+	// QueryParam is the query parameter selecting the language of HTTP
+	// requests.  Defaults to "locale".
+	QueryParam string `yaml:"query-param"`
+	// TimeZoneQueryParam is the query parameter selecting the time zone of
+	// HTTP requests.  Defaults to "tz".
+	TimeZoneQueryParam string `yaml:"time-zone-query-param"`
+	// Claim is the user claim holding the preferred language.  Defaults to
+	// "locale".
+	Claim string `yaml:"claim"`
+	// TimeZoneClaim is the user claim holding the time zone.  Defaults to
+	// "zoneinfo".
+	TimeZoneClaim string `yaml:"time-zone-claim"`
+}
+
+// enabled returns true if locales are resolved.
+func (c LocaleConfig) enabled() bool {
+	return len(c.Languages) > 0
+}
+
+func (c LocaleConfig) queryParam() string {
+	if c.QueryParam == "" {
+		return defaultLocaleQueryParam
+	}
+	return c.QueryParam
+}
+
+func (c LocaleConfig) timeZoneQueryParam() string {
+	if c.TimeZoneQueryParam == "" {
+		return defaultTimeZoneQueryParam
+	}
+	return c.TimeZoneQueryParam
+}
+
+func (c LocaleConfig) claim() string {
+	if c.Claim == "" {
+		return defaultLocaleClaim
+	}
+	return c.Claim
+}
+
+func (c LocaleConfig) timeZoneClaim() string {
+	if c.TimeZoneClaim == "" {
+		return defaultTimeZoneClaim
+	}
+	return c.TimeZoneClaim
+}
+
+// validLocale validates the locale configuration.
+func (c *Config) validLocale() error {
+	l := c.Locale
+	if !l.enabled() {
+		if l.TimeZone != "" || l.QueryParam != "" || l.TimeZoneQueryParam != "" || l.Claim != "" || l.TimeZoneClaim != "" {
+			return fmt.Errorf("locale: missing languages")
+		}
+		return nil
+	}
+	if _, err := newLocaleResolver(l); err != nil {
+		return err
+	}
+	if l.queryParam() == l.timeZoneQueryParam() {
+		return fmt.Errorf("locale: language and time zone query parameters must differ")
+	}
+	return nil
+}
+
+// localeHeaders returns the request headers used to resolve locales.
+func (c *Config) localeHeaders() []string {
+	if !c.Locale.enabled() {
+		return nil
+	}
+	return []string{acceptLanguageHeader, LocaleHeader, TimeZoneHeader}
+}
+
+// localeResolver resolves the locale of requests.
+type localeResolver struct {
+	cfg      LocaleConfig
+	matcher  *locale.Matcher
+	location *time.Location
+
+	mut sync.Mutex
+	// locations caches time zones by name, nil if the name is invalid.
+	locations map[string]*time.Location
+}
+
+// newLocaleResolver returns the locale resolver of a valid config, or nil if
+// locales are not resolved.
+func newLocaleResolver(cfg LocaleConfig) (*localeResolver, error) {
+	if !cfg.enabled() {
+		return nil, nil
+	}
+	m, err := locale.NewMatcher(cfg.Languages...)
+	if err != nil {
+		return nil, err
+	}
+	loc := time.UTC
+	if cfg.TimeZone != "" {
+		if loc, err = locale.LoadLocation(cfg.TimeZone); err != nil {
+			return nil, err
+		}
+	}
+	return &localeResolver{cfg: cfg, matcher: m, location: loc, locations: make(map[string]*time.Location)}, nil
+}
+
+// loadLocation returns the time zone with a name supplied by a client, or
+// nil if the name is invalid.
+func (r *localeResolver) loadLocation(name string) *time.Location {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+	if loc, ok := r.locations[name]; ok {
+		return loc
+	}
+	loc, _ := locale.LoadLocation(name)
+	if len(r.locations) >= maxCachedLocations {
+		clear(r.locations)
+	}
+	r.locations[name] = loc
+	return loc
+}
+
+// resolve returns the locale of a request given its explicit language and
+// time zone, the claims of the user, if any, and its Accept-Language
+// header.
+func (r *localeResolver) resolve(lang string, tz string, claims map[string]interface{}, acceptLanguage string) locale.Locale {
+	claimLang, _ := claims[r.cfg.claim()].(string)
+	claimTZ, _ := claims[r.cfg.timeZoneClaim()].(string)
+	l := locale.Locale{
+		Language: r.matcher.Match(lang, claimLang, acceptLanguage),
+		Location: r.location,
+	}
+	for _, name := range []string{tz, claimTZ} {
+		if name == "" {
+			continue
+		}
+		if loc := r.loadLocation(name); loc != nil {
+			l.Location = loc
+			break
+		}
+	}
+	return l
+}
+
+// defaultLocale returns the default locale.
+func (r *localeResolver) defaultLocale() locale.Locale {
+	return locale.Locale{Language: r.matcher.Default(), Location: r.location}
+}
+
+// withDefaultLocale returns ctx holding the default locale, for work which is
+// not done on behalf of a user, e.g. reports.
+func (orc *Oracle) withDefaultLocale(ctx context.Context) context.Context {
+	if orc.locales == nil {
+		return ctx
+	}
+	return locale.NewContext(ctx, orc.locales.defaultLocale())
+}
+
+// localeInterceptor stores the locale of requests in their context.  The
+// claims of the user are cached by the claims cache interceptor.
+func (orc *Oracle) localeInterceptor() grpc.UnaryServerInterceptor {
+	if orc.locales == nil {
+		return nil
+	}
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		md, _ := metadata.FromIncomingContext(ctx)
+		get := func(h string) string {
+			vals := md.Get(strings.ToLower(h))
+			if len(vals) == 0 {
+				return ""
+			}
+			return vals[0]
+		}
+		claims, err := orc.GetClaims(ctx)
+		if err != nil && !errors.Is(err, ErrClaimsNotConfigured) {
+			orc.log(ctx).WithError(err).Debugf("locale claims unavailable")
+		}
+		l := orc.locales.resolve(get(LocaleHeader), get(TimeZoneHeader), claims, strings.Join(md.Get(strings.ToLower(acceptLanguageHeader)), ","))
+		return handler(locale.NewContext(ctx, l), req)
+	}
+}
+
+// localeMiddleware forwards the locale query parameters of HTTP requests as
+// headers, and stores the locale of requests in their context for handlers
+// served outside of the grpc-gateway.  User claims are not available to the
+// middleware.
+func (orc *Oracle) localeMiddleware(next http.Handler) http.Handler {
+	if orc.locales == nil {
+		return next
+	}
+	cfg := orc.locales.cfg
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		lang, tz := query.Get(cfg.queryParam()), query.Get(cfg.timeZoneQueryParam())
+		if lang != "" || tz != "" {
+			r = r.Clone(r.Context())
+			if lang != "" {
+				r.Header.Set(LocaleHeader, lang)
+			}
+			if tz != "" {
+				r.Header.Set(TimeZoneHeader, tz)
+			}
+		}
+		l := orc.locales.resolve(r.Header.Get(LocaleHeader), r.Header.Get(TimeZoneHeader), nil, strings.Join(r.Header.Values(acceptLanguageHeader), ","))
+		w.Header().Add("Vary", strings.Join(orc.cfg.localeHeaders(), ", "))
+		next.ServeHTTP(w, r.WithContext(locale.NewContext(r.Context(), l)))
+	})
+}
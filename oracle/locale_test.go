@@ -0,0 +1,136 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package oracle
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/luthersystems/svc/locale"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestLocaleConfigInvalid(t *testing.T) {
+	for name, l := range map[string]LocaleConfig{
+		"no languages":     {TimeZone: "Europe/London"},
+		"invalid language": {Languages: []string{"en", "not a tag!"}},
+		"invalid zone":     {Languages: []string{"en"}, TimeZone: "Mars/Olympus_Mons"},
+		"local zone":       {Languages: []string{"en"}, TimeZone: "Local"},
+		"same params":      {Languages: []string{"en"}, QueryParam: "tz"},
+	} {
+		cfg := DefaultConfig()
+		cfg.Locale = l
+		require.Error(t, cfg.validLocale(), name)
+	}
+	cfg := DefaultConfig()
+	require.NoError(t, cfg.validLocale())
+	require.Empty(t, cfg.localeHeaders())
+	cfg.Locale = LocaleConfig{Languages: []string{"en-GB", "fr"}, TimeZone: "Europe/London"}
+	require.NoError(t, cfg.validLocale())
+	require.Subset(t, cfg.requiredForwardedHeaders(), []string{"Accept-Language", LocaleHeader, TimeZoneHeader})
+}
+
+func TestLocaleInterceptor(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Locale = LocaleConfig{Languages: []string{"en-GB", "fr", "de"}, TimeZone: "Europe/London"}
+	claims := map[string]interface{}{}
+	cfg.SetClaimsGetter(func(ctx context.Context) (map[string]interface{}, error) {
+		return claims, nil
+	})
+	orc := newTestOracle(t, cfg)
+	intercept := orc.localeInterceptor()
+	require.NotNil(t, intercept)
+
+	run := func(md ...string) locale.Locale {
+		ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(md...))
+		resp, err := intercept(ctx, nil, &grpc.UnaryServerInfo{}, func(ctx context.Context, req interface{}) (interface{}, error) {
+			l, ok := locale.FromContext(ctx)
+			require.True(t, ok)
+			return l, nil
+		})
+		require.NoError(t, err)
+		return resp.(locale.Locale)
+	}
+
+	l := run()
+	require.Equal(t, "en-GB", l.Language)
+	require.Equal(t, "Europe/London", l.Location.String())
+
+	l = run("accept-language", "ja, fr-CA;q=0.8")
+	require.Equal(t, "fr", l.Language)
+
+	claims["locale"] = "de"
+	claims["zoneinfo"] = "Europe/Berlin"
+	l = run("accept-language", "fr")
+	require.Equal(t, "de", l.Language)
+	require.Equal(t, "Europe/Berlin", l.Location.String())
+
+	// Explicit selections take precedence over claims, and invalid values
+	// are ignored.
+	l = run("accept-language", "fr", "x-locale", "en", "time-zone", "America/New_York")
+	require.Equal(t, "en-GB", l.Language)
+	require.Equal(t, "America/New_York", l.Location.String())
+	l = run("x-locale", "ja", "time-zone", "Nowhere/Special")
+	require.Equal(t, "de", l.Language)
+	require.Equal(t, "Europe/Berlin", l.Location.String())
+
+	require.Nil(t, newTestOracle(t, DefaultConfig()).localeInterceptor())
+}
+
+func TestLocaleResolverCache(t *testing.T) {
+	r, err := newLocaleResolver(LocaleConfig{Languages: []string{"en"}})
+	require.NoError(t, err)
+	loc := r.loadLocation("Europe/Paris")
+	require.Equal(t, "Europe/Paris", loc.String())
+	require.Same(t, loc, r.loadLocation("Europe/Paris"))
+	require.Nil(t, r.loadLocation("Nowhere/Special"))
+	require.Nil(t, r.loadLocation("Local"))
+	for i := 0; i < 2*maxCachedLocations; i++ {
+		r.loadLocation(fmt.Sprintf("Nowhere/%d", i))
+	}
+	require.LessOrEqual(t, len(r.locations), maxCachedLocations)
+}
+
+func TestLocaleMiddleware(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Locale = LocaleConfig{Languages: []string{"en", "fr"}}
+	orc := newTestOracle(t, cfg)
+	var got *http.Request
+	h := orc.localeMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/docs?locale=fr&tz=Asia/Tokyo", nil)
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	require.Equal(t, "fr", got.Header.Get(LocaleHeader))
+	require.Equal(t, "Asia/Tokyo", got.Header.Get(TimeZoneHeader))
+	require.Equal(t, "fr", locale.Language(got.Context()))
+	require.Equal(t, "Asia/Tokyo", locale.Location(got.Context()).String())
+	require.Contains(t, rr.Header().Get("Vary"), "Accept-Language")
+	// The original request is not modified.
+	require.Empty(t, req.Header.Get(LocaleHeader))
+
+	req = httptest.NewRequest(http.MethodGet, "/v1/docs", nil)
+	req.Header.Set("Accept-Language", "fr-FR")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	require.Equal(t, "fr", locale.Language(got.Context()))
+	require.Equal(t, "UTC", locale.Location(got.Context()).String())
+}
+
+func TestWithDefaultLocale(t *testing.T) {
+	ctx := newTestOracle(t, DefaultConfig()).withDefaultLocale(context.Background())
+	_, ok := locale.FromContext(ctx)
+	require.False(t, ok)
+
+	cfg := DefaultConfig()
+	cfg.Locale = LocaleConfig{Languages: []string{"fr"}, TimeZone: "Europe/Paris"}
+	ctx = newTestOracle(t, cfg).withDefaultLocale(context.Background())
+	require.Equal(t, "fr", locale.Language(ctx))
+	require.Equal(t, "Europe/Paris", locale.Location(ctx).String())
+}
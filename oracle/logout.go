@@ -0,0 +1,106 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package oracle
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	jwtgo "github.com/golang-jwt/jwt/v4"
+	"github.com/luthersystems/svc/svcerr"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// defaultAuthCookieName is the default name of the auth token cookie.
+	defaultAuthCookieName = "authorization"
+
+	// defaultDepTxCookieName is the default name of the dependent transaction
+	// cookie.
+	defaultDepTxCookieName = "dep-tx"
+)
+
+// LogoutNotifier is called after a user's cookies have been invalidated.  The
+// request is the original logout request and may be used to extract the
+// user's token in order to notify the IDP.  Errors are logged but do not
+// prevent the logout from completing.
+type LogoutNotifier func(ctx context.Context, r *http.Request) error
+
+// ClearCookie instructs the client to delete the named cookie.  The cookie
+// attributes must match those used when the cookie was set, otherwise
+// browsers will treat it as a different cookie and retain the original.
+func ClearCookie(w http.ResponseWriter, name string, path string, domain string, secure bool) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     name,
+		Value:    "",
+		Path:     path,
+		Domain:   domain,
+		Expires:  time.Unix(0, 0),
+		MaxAge:   -1, // renders Max-Age=0
+		Secure:   secure,
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+	})
+}
+
+// clearSessionCookies invalidates the cookies managed by the oracle.
+func (orc *Oracle) clearSessionCookies(w http.ResponseWriter) {
+	for _, name := range []string{orc.cfg.AuthCookieName, orc.cfg.DepTxCookieName} {
+		if name == "" {
+			continue
+		}
+		ClearCookie(w, name, "/", orc.cfg.CookieDomain, !orc.cfg.CookieInsecure)
+	}
+}
+
+// logoutSubject returns the (unverified) subject of the auth token, for
+// auditing purposes only.
+func (orc *Oracle) logoutSubject(r *http.Request) string {
+	if orc.cfg.AuthCookieName == "" {
+		return ""
+	}
+	cookie, err := r.Cookie(orc.cfg.AuthCookieName)
+	if err != nil {
+		return ""
+	}
+	claims := &jwtgo.RegisteredClaims{}
+	parser := &jwtgo.Parser{}
+	// Don't log, just omit invalid tokens
+	if _, _, err := parser.ParseUnverified(cookie.Value, claims); err != nil {
+		return ""
+	}
+	return claims.Subject
+}
+
+// logoutHandler clears the session cookies, optionally notifies the IDP, and
+// records an audit event.
+func (orc *Oracle) logoutHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			ex := svcerr.BusinessException(ctx, "method not allowed")
+			if err := writeExceptionHTTP(w, http.StatusMethodNotAllowed, ex); err != nil {
+				orc.log(ctx).WithError(err).Errorf("logout handler response error")
+			}
+			return
+		}
+		subject := orc.logoutSubject(r)
+		orc.clearSessionCookies(w)
+		if orc.cfg.logoutNotifier != nil {
+			if err := orc.cfg.logoutNotifier(ctx, r); err != nil {
+				orc.log(ctx).WithError(err).Warnf("logout notify failed")
+			}
+		}
+		orc.log(ctx).WithFields(logrus.Fields{
+			"audit_event": "logout",
+			"subject":     subject,
+		}).Infof("user logout")
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		if _, err := w.Write([]byte("{}")); err != nil {
+			orc.log(ctx).WithError(err).Errorf("logout handler response error")
+		}
+	})
+}
@@ -0,0 +1,47 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package oracle
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLogoutHandler(t *testing.T) {
+	notified := false
+	cfg := DefaultConfig()
+	cfg.CookieDomain = "example.com"
+	cfg.SetLogoutNotifier(func(ctx context.Context, r *http.Request) error {
+		notified = true
+		return nil
+	})
+	orc := newTestOracle(t, cfg)
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/logout", nil)
+	req.AddCookie(&http.Cookie{Name: defaultAuthCookieName, Value: "token"})
+	rr := httptest.NewRecorder()
+	orc.logoutHandler().ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.True(t, notified)
+
+	cookies := rr.Result().Cookies()
+	require.Len(t, cookies, 2)
+	for _, c := range cookies {
+		require.Contains(t, []string{defaultAuthCookieName, defaultDepTxCookieName}, c.Name)
+		require.Equal(t, "", c.Value)
+		require.Equal(t, -1, c.MaxAge)
+		require.Equal(t, "example.com", c.Domain)
+		require.True(t, c.Secure)
+		require.True(t, c.HttpOnly)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/v1/logout", nil)
+	rr = httptest.NewRecorder()
+	orc.logoutHandler().ServeHTTP(rr, req)
+	require.Equal(t, http.StatusMethodNotAllowed, rr.Code)
+	require.Empty(t, rr.Result().Cookies())
+}
@@ -0,0 +1,65 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package oracle
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// AddMarshaler registers a grpc-gateway marshaler for a content type, such
+// as "application/x-ndjson".  The marshaler is selected by the Content-Type
+// and Accept headers of requests.  Other content types use the JSON
+// marshaler configured by the JSON* options.
+func (c *Config) AddMarshaler(mime string, m runtime.Marshaler) {
+	if c == nil {
+		return
+	}
+	if c.marshalers == nil {
+		c.marshalers = make(map[string]runtime.Marshaler)
+	}
+	c.marshalers[mime] = m
+}
+
+// validMarshalers validates the marshaler configuration.
+func (c *Config) validMarshalers() error {
+	for mime, m := range c.marshalers {
+		if mime == "" || strings.ContainsAny(mime, " \t\r\n") {
+			return fmt.Errorf("marshaler: invalid content type %q", mime)
+		}
+		if m == nil {
+			return fmt.Errorf("marshaler %s: missing marshaler", mime)
+		}
+	}
+	return nil
+}
+
+// jsonMarshaler returns the default gateway marshaler.
+func (orc *Oracle) jsonMarshaler() *runtime.JSONPb {
+	return &runtime.JSONPb{
+		MarshalOptions: protojson.MarshalOptions{
+			UseProtoNames:     true,
+			EmitUnpopulated:   orc.cfg.JSONEmitUnpopulated,
+			EmitDefaultValues: orc.cfg.JSONEmitDefaultValues,
+			UseEnumNumbers:    orc.cfg.JSONUseEnumNumbers,
+		},
+		UnmarshalOptions: protojson.UnmarshalOptions{
+			DiscardUnknown: false,
+		},
+	}
+}
+
+// marshalerOptions returns the gateway marshaler registry options.
+func (orc *Oracle) marshalerOptions() []runtime.ServeMuxOption {
+	opts := []runtime.ServeMuxOption{
+		runtime.WithMarshalerOption(runtime.MIMEWildcard, orc.jsonMarshaler()),
+	}
+	opts = append(opts, orc.protobufMarshalerOptions()...)
+	for mime, m := range orc.cfg.marshalers {
+		opts = append(opts, runtime.WithMarshalerOption(mime, m))
+	}
+	return opts
+}
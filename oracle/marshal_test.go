@@ -0,0 +1,51 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package oracle
+
+import (
+	"testing"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/typepb"
+)
+
+func TestJSONMarshaler(t *testing.T) {
+	cfg := DefaultConfig()
+	orc := newTestOracle(t, cfg)
+	b, err := orc.jsonMarshaler().Marshal(&typepb.Field{})
+	require.NoError(t, err)
+	require.JSONEq(t, `{}`, string(b))
+
+	cfg.JSONEmitUnpopulated = true
+	cfg.JSONUseEnumNumbers = true
+	orc = newTestOracle(t, cfg)
+	b, err = orc.jsonMarshaler().Marshal(&typepb.Field{Kind: typepb.Field_TYPE_STRING})
+	require.NoError(t, err)
+	require.Contains(t, string(b), `"kind":9`)
+	require.Contains(t, string(b), `"json_name":""`)
+}
+
+func TestAddMarshaler(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.AddMarshaler("application/x-ndjson", &runtime.JSONPb{})
+	require.NoError(t, cfg.validMarshalers())
+	orc := newTestOracle(t, cfg)
+	require.Len(t, orc.marshalerOptions(), 2)
+
+	cfg.AddMarshaler("bad type", &runtime.JSONPb{})
+	require.Error(t, cfg.validMarshalers())
+
+	cfg = DefaultConfig()
+	cfg.AddMarshaler("application/x-ndjson", nil)
+	require.Error(t, cfg.validMarshalers())
+}
+
+func TestGatewayProtobuf(t *testing.T) {
+	cfg := DefaultConfig()
+	orc := newTestOracle(t, cfg)
+	require.Empty(t, orc.protobufMarshalerOptions())
+	cfg.GatewayProtobuf = true
+	orc = newTestOracle(t, cfg)
+	require.Len(t, orc.marshalerOptions(), 2)
+}
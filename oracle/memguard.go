@@ -0,0 +1,334 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package oracle
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"runtime/metrics"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/luthersystems/svc/grpclogging"
+	"github.com/luthersystems/svc/svcerr"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	defaultMemoryGuardShedRatio = 0.9
+	defaultMemoryGuardInterval  = time.Second
+
+	// memoryGuardDecodeFactor estimates the memory needed to decode a
+	// request body relative to its size.
+	memoryGuardDecodeFactor = 4
+
+	// memoryGuardOffenders is the number of in-flight requests logged when
+	// load shedding starts.
+	memoryGuardOffenders = 5
+
+	// memoryGuardRetryAfter is the Retry-After header of shed requests, in
+	// seconds.
+	memoryGuardRetryAfter = "5"
+)
+
+var (
+	memoryUsageBytes = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "memory_guard_usage_bytes",
+		Help: "Process memory usage (RSS, or Go runtime memory where RSS is unavailable) sampled by the memory guard.",
+	})
+	memoryLimitBytes = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "memory_guard_limit_bytes",
+		Help: "Memory limit enforced by the memory guard.",
+	})
+	memoryShedding = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "memory_guard_shedding",
+		Help: "Whether the memory guard is shedding load (1) or not (0).",
+	})
+	memoryShedTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "memory_guard_shed_requests_total",
+			Help: "How many requests were rejected by the memory guard, partitioned by reason.",
+		},
+		[]string{"reason"},
+	)
+)
+
+// MemoryGuard protects the oracle from being killed for exceeding its
+// memory limit.  A watchdog samples the process memory and, once usage
+// reaches ShedRatio of the limit, requests to routes other than the
+// CriticalPaths are rejected with 503 until usage drops.  Requests with
+// bodies too large to decode within the remaining memory are rejected as
+// well; bodies of unknown length, e.g. chunked, fail to read once they
+// exceed the remaining memory.  Memory is sampled without stopping the
+// world, so the guard is safe to run alongside profiling.
+type MemoryGuard struct {
+	// Limit is the memory limit in bytes, typically the container memory
+	// limit.  The guard is disabled if the limit is zero.
+	Limit int64 `yaml:"limit"`
+	// ShedRatio is the fraction of the limit from which load is shed.
+	// Defaults to 0.9.
+	ShedRatio float64 `yaml:"shed-ratio"`
+	// Interval is the time between memory samples.  Defaults to 1 second.
+	Interval time.Duration `yaml:"interval"`
+	// CriticalPaths are path prefixes which are never shed.  The health
+	// check is always critical.
+	CriticalPaths []string `yaml:"critical-paths"`
+}
+
+// valid validates the memory guard configuration.
+func (g MemoryGuard) valid() error {
+	if g.Limit < 0 {
+		return fmt.Errorf("memory guard: negative limit")
+	}
+	if g.ShedRatio < 0 || g.ShedRatio > 1 {
+		return fmt.Errorf("memory guard: shed ratio must be between 0 and 1")
+	}
+	if g.Interval < 0 {
+		return fmt.Errorf("memory guard: negative interval")
+	}
+	for _, p := range g.CriticalPaths {
+		if !strings.HasPrefix(p, "/") {
+			return fmt.Errorf("memory guard: critical path %q must be absolute", p)
+		}
+	}
+	return nil
+}
+
+// memoryGuard is the running memory guard.
+type memoryGuard struct {
+	cfg         MemoryGuard
+	threshold   int64
+	reqIDHeader string
+	log         *logrus.Entry
+	sample      func() int64
+
+	usage    atomic.Int64
+	shedding atomic.Bool
+
+	mut      sync.Mutex
+	nextID   uint64
+	inflight map[uint64]*inflightRequest
+}
+
+// inflightRequest is a request being served, tracked to report the
+// requests most likely responsible for high memory usage.
+type inflightRequest struct {
+	reqID         string
+	method        string
+	path          string
+	contentLength int64
+	start         time.Time
+}
+
+func newMemoryGuard(cfg MemoryGuard, reqIDHeader string, log *logrus.Entry) *memoryGuard {
+	ratio := cfg.ShedRatio
+	if ratio == 0 {
+		ratio = defaultMemoryGuardShedRatio
+	}
+	if cfg.Interval == 0 {
+		cfg.Interval = defaultMemoryGuardInterval
+	}
+	memoryLimitBytes.Set(float64(cfg.Limit))
+	return &memoryGuard{
+		cfg:         cfg,
+		threshold:   int64(float64(cfg.Limit) * ratio),
+		reqIDHeader: reqIDHeader,
+		log:         log,
+		sample:      memoryUsage,
+		inflight:    make(map[uint64]*inflightRequest),
+	}
+}
+
+// run samples memory usage until ctx is done.
+func (g *memoryGuard) run(ctx context.Context) {
+	ticker := time.NewTicker(g.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		g.check()
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// check samples memory usage and starts or stops shedding load.
+func (g *memoryGuard) check() {
+	usage := g.sample()
+	g.usage.Store(usage)
+	memoryUsageBytes.Set(float64(usage))
+	shed := usage >= g.threshold
+	if g.shedding.Swap(shed) == shed {
+		return
+	}
+	if !shed {
+		memoryShedding.Set(0)
+		g.log.WithField("memory_usage", usage).Infof("memory guard: load shedding stopped")
+		return
+	}
+	memoryShedding.Set(1)
+	g.log.WithFields(logrus.Fields{
+		"memory_usage": usage,
+		"memory_limit": g.cfg.Limit,
+	}).Warnf("memory guard: load shedding started")
+	for _, r := range g.offenders() {
+		g.log.WithFields(logrus.Fields{
+			"req_id":         r.reqID,
+			"method":         r.method,
+			"path":           r.path,
+			"content_length": r.contentLength,
+			"req_dur":        time.Since(r.start).String(),
+		}).Warnf("memory guard: in-flight request")
+	}
+}
+
+// offenders returns the in-flight requests with the largest bodies, then
+// the longest running.
+func (g *memoryGuard) offenders() []*inflightRequest {
+	g.mut.Lock()
+	reqs := make([]*inflightRequest, 0, len(g.inflight))
+	for _, r := range g.inflight {
+		reqs = append(reqs, r)
+	}
+	g.mut.Unlock()
+	sort.Slice(reqs, func(i, j int) bool {
+		if reqs[i].contentLength != reqs[j].contentLength {
+			return reqs[i].contentLength > reqs[j].contentLength
+		}
+		return reqs[i].start.Before(reqs[j].start)
+	})
+	if len(reqs) > memoryGuardOffenders {
+		reqs = reqs[:memoryGuardOffenders]
+	}
+	return reqs
+}
+
+// critical returns true if requests to path are never shed.
+func (g *memoryGuard) critical(path string) bool {
+	if path == healthCheckPath {
+		return true
+	}
+	for _, p := range g.cfg.CriticalPaths {
+		if strings.HasPrefix(path, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// shedReason returns why a request must be rejected, or "".
+func (g *memoryGuard) shedReason(r *http.Request) string {
+	if g.critical(r.URL.Path) {
+		return ""
+	}
+	if g.shedding.Load() {
+		return "memory_pressure"
+	}
+	if r.ContentLength > 0 && g.usage.Load()+r.ContentLength*memoryGuardDecodeFactor > g.cfg.Limit {
+		return "payload_size"
+	}
+	if r.ContentLength < 0 && g.bodyLimit() <= 0 {
+		return "payload_size"
+	}
+	return ""
+}
+
+// bodyLimit returns the size of the largest request body which can be
+// decoded within the remaining memory.
+func (g *memoryGuard) bodyLimit() int64 {
+	return (g.cfg.Limit - g.usage.Load()) / memoryGuardDecodeFactor
+}
+
+// track records an in-flight request, returning a function removing it.
+func (g *memoryGuard) track(r *http.Request) func() {
+	req := &inflightRequest{
+		reqID:         r.Header.Get(g.reqIDHeader),
+		method:        r.Method,
+		path:          r.URL.Path,
+		contentLength: r.ContentLength,
+		start:         time.Now(),
+	}
+	g.mut.Lock()
+	g.nextID++
+	id := g.nextID
+	g.inflight[id] = req
+	g.mut.Unlock()
+	return func() {
+		g.mut.Lock()
+		delete(g.inflight, id)
+		g.mut.Unlock()
+	}
+}
+
+// memoryGuardMiddleware sheds load while memory is short.
+func (orc *Oracle) memoryGuardMiddleware(next http.Handler) http.Handler {
+	g := orc.memGuard
+	if g == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if reason := g.shedReason(r); reason != "" {
+			memoryShedTotal.WithLabelValues(reason).Inc()
+			grpclogging.AddLogrusFields(r.Context(), logrus.Fields{"memory_guard": reason})
+			w.Header().Set("Retry-After", memoryGuardRetryAfter)
+			ex := svcerr.ServiceException(r.Context(), "service overloaded")
+			if err := writeExceptionHTTP(w, http.StatusServiceUnavailable, ex); err != nil {
+				orc.log(r.Context()).WithError(err).Errorf("memory guard response error")
+			}
+			return
+		}
+		if r.ContentLength < 0 {
+			// Bodies of unknown length, e.g. chunked, are limited to the
+			// remaining memory as they are read.
+			r.Body = http.MaxBytesReader(w, r.Body, g.bodyLimit())
+		}
+		defer g.track(r)()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// memoryUsage returns the resident set size of the process, or the memory
+// obtained from the OS by the Go runtime where RSS is unavailable.
+func memoryUsage() int64 {
+	if rss, ok := residentSetSize(); ok {
+		return rss
+	}
+	samples := []metrics.Sample{
+		{Name: "/memory/classes/total:bytes"},
+		{Name: "/memory/classes/heap/released:bytes"},
+	}
+	metrics.Read(samples)
+	var usage int64
+	if samples[0].Value.Kind() == metrics.KindUint64 {
+		usage = int64(samples[0].Value.Uint64())
+	}
+	if samples[1].Value.Kind() == metrics.KindUint64 {
+		usage -= int64(samples[1].Value.Uint64())
+	}
+	return usage
+}
+
+// residentSetSize reads the RSS of the process from /proc on Linux.
+func residentSetSize() (int64, bool) {
+	b, err := os.ReadFile("/proc/self/statm")
+	if err != nil {
+		return 0, false
+	}
+	fields := strings.Fields(string(b))
+	if len(fields) < 2 {
+		return 0, false
+	}
+	pages, err := strconv.ParseInt(fields[1], 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return pages * int64(os.Getpagesize()), true
+}
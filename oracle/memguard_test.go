@@ -0,0 +1,112 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package oracle
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryGuardValid(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.MemoryGuard.Limit = -1
+	require.Error(t, cfg.Valid())
+	cfg.MemoryGuard.Limit = 1 << 30
+	cfg.MemoryGuard.ShedRatio = 1.5
+	require.Error(t, cfg.Valid())
+	cfg.MemoryGuard.ShedRatio = 0.8
+	cfg.MemoryGuard.CriticalPaths = []string{"v1/critical"}
+	require.Error(t, cfg.Valid())
+	cfg.MemoryGuard.CriticalPaths = []string{"/v1/critical"}
+	require.NoError(t, cfg.Valid())
+}
+
+func TestMemoryGuardMiddleware(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.MemoryGuard = MemoryGuard{Limit: 1000, CriticalPaths: []string{"/v1/critical"}}
+	orc := newTestOracle(t, cfg)
+	usage := int64(100)
+	orc.memGuard.sample = func() int64 { return usage }
+
+	app := orc.memoryGuardMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	serve := func(path string, body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		app.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, path, strings.NewReader(body)))
+		return rr
+	}
+
+	orc.memGuard.check()
+	require.Equal(t, http.StatusOK, serve("/v1/foo", "{}").Code)
+
+	// A body which cannot be decoded within the remaining memory is
+	// rejected.
+	rr := serve("/v1/foo", strings.Repeat("x", 300))
+	require.Equal(t, http.StatusServiceUnavailable, rr.Code)
+	require.NotEmpty(t, rr.Header().Get("Retry-After"))
+
+	// Non-critical routes are shed near the limit.
+	usage = 950
+	orc.memGuard.check()
+	require.Equal(t, http.StatusServiceUnavailable, serve("/v1/foo", "{}").Code)
+	require.Equal(t, http.StatusOK, serve("/v1/critical/bar", "{}").Code)
+	require.Equal(t, http.StatusOK, serve(healthCheckPath, "").Code)
+
+	usage = 100
+	orc.memGuard.check()
+	require.Equal(t, http.StatusOK, serve("/v1/foo", "{}").Code)
+}
+
+func TestMemoryGuardChunkedBody(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.MemoryGuard = MemoryGuard{Limit: 1000}
+	orc := newTestOracle(t, cfg)
+	orc.memGuard.sample = func() int64 { return 100 }
+	orc.memGuard.check()
+
+	var readErr error
+	app := orc.memoryGuardMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, readErr = io.ReadAll(r.Body)
+	}))
+	serve := func(body string) {
+		r := httptest.NewRequest(http.MethodPost, "/v1/foo", strings.NewReader(body))
+		r.ContentLength = -1
+		app.ServeHTTP(httptest.NewRecorder(), r)
+	}
+	serve("{}")
+	require.NoError(t, readErr)
+	// Chunked bodies are limited to the remaining memory as they are read.
+	serve(strings.Repeat("x", 300))
+	var maxErr *http.MaxBytesError
+	require.ErrorAs(t, readErr, &maxErr)
+}
+
+func TestMemoryGuardOffenders(t *testing.T) {
+	g := newMemoryGuard(MemoryGuard{Limit: 1000}, "X-Req", logrus.NewEntry(logrus.New()))
+	var done []func()
+	for _, n := range []int{10, 500, 0, 50, 20, 30, 40} {
+		done = append(done, g.track(httptest.NewRequest(http.MethodPost, "/v1/foo", strings.NewReader(strings.Repeat("x", n)))))
+	}
+	r := httptest.NewRequest(http.MethodPost, "/v1/foo", strings.NewReader(strings.Repeat("x", 600)))
+	r.Header.Set("X-Req", "req-1")
+	done = append(done, g.track(r))
+	offenders := g.offenders()
+	require.Len(t, offenders, memoryGuardOffenders)
+	require.Equal(t, int64(600), offenders[0].contentLength)
+	require.Equal(t, "req-1", offenders[0].reqID)
+	require.Equal(t, int64(500), offenders[1].contentLength)
+	require.Equal(t, int64(50), offenders[2].contentLength)
+	for _, f := range done {
+		f()
+	}
+	require.Empty(t, g.offenders())
+}
+
+func TestMemoryUsage(t *testing.T) {
+	require.Positive(t, memoryUsage())
+}
@@ -0,0 +1,115 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package oracle
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+
+	"github.com/luthersystems/svc/logmon"
+	"github.com/luthersystems/svc/oracle/tasks"
+	"github.com/luthersystems/svc/promreg"
+	"github.com/luthersystems/svc/svcerr"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/sirupsen/logrus"
+)
+
+// logHookOnce guards installation of the log metrics hook on the standard
+// logger, which is shared by all oracles in a process.
+var logHookOnce sync.Once
+
+// metricsRegisterer returns the registerer for oracle metrics, applying the
+// configured metrics prefix.
+func (c *Config) metricsRegisterer() prometheus.Registerer {
+	reg := c.MetricsRegisterer
+	if reg == nil {
+		reg = prometheus.DefaultRegisterer
+	}
+	if c.MetricsPrefix != "" {
+		reg = prometheus.WrapRegistererWithPrefix(c.MetricsPrefix+"_", reg)
+	}
+	return reg
+}
+
+// metricsHandler serves the metrics gathered by the configured registerer,
+// if it is also a gatherer (e.g. a *prometheus.Registry), and otherwise the
+// default prometheus registry.  With MetricsExemplars the OpenMetrics format,
+// which carries exemplars, is served to scrapers which accept it.
+func (c *Config) metricsHandler() http.Handler {
+	opts := promhttp.HandlerOpts{EnableOpenMetrics: c.MetricsExemplars}
+	if g, ok := c.MetricsRegisterer.(prometheus.Gatherer); ok {
+		return promhttp.HandlerFor(g, opts)
+	}
+	return promhttp.InstrumentMetricHandler(prometheus.DefaultRegisterer,
+		promhttp.HandlerFor(prometheus.DefaultGatherer, opts))
+}
+
+// metricsFeature is a group of collectors registered only when the
+// feature populating them is enabled.
+type metricsFeature struct {
+	enabled    bool
+	collectors []prometheus.Collector
+}
+
+// metricsFeatures returns the collectors of each oracle feature.
+func (c *Config) metricsFeatures() []metricsFeature {
+	return []metricsFeature{
+		{true, []prometheus.Collector{
+			versionTotal,
+			backgroundTaskDuration,
+			backgroundTasksRunning,
+			outboundRequestsTotal,
+			outboundRequestDuration,
+		}},
+		{len(c.ssePaths) > 0, []prometheus.Collector{sseConnections, sseEventsTotal}},
+		{len(c.Webhooks) > 0, []prometheus.Collector{webhookDeliveriesTotal, webhookDeliveryDuration}},
+		{c.ClientCredentials.enabled(), []prometheus.Collector{clientCredentialsRefreshFailures}},
+		{c.PhylumCutover.enabled(), []prometheus.Collector{phylumCallsTotal}},
+		{c.MemoryGuard.Limit > 0, []prometheus.Collector{
+			memoryUsageBytes,
+			memoryLimitBytes,
+			memoryShedding,
+			memoryShedTotal,
+		}},
+		{len(c.reports) > 0, []prometheus.Collector{reportRunsTotal, reportDuration, reportLastSuccess}},
+		{len(c.periodicTasks) > 0, []prometheus.Collector{periodicTaskRunsTotal, periodicTaskDuration}},
+		{c.leaderStore != nil, []prometheus.Collector{leaderTransitionsTotal, leaderIsLeader}},
+		{c.operationStore != nil, []prometheus.Collector{operationsTotal, operationsRunning}},
+		{c.MetricsExemplars, []prometheus.Collector{httpRequestDuration}},
+		{len(c.bulkImports) > 0, []prometheus.Collector{bulkImportLinesTotal, bulkImportsRunning}},
+		{len(c.csvExports) > 0, []prometheus.Collector{csvExportRowsTotal, csvExportsRunning}},
+		{c.tasksEnabled(), tasks.Collectors()},
+	}
+}
+
+// registerMetrics registers the metrics of enabled oracle features, and
+// svcerr and log metrics.
+func (orc *Oracle) registerMetrics() error {
+	reg := orc.cfg.metricsRegisterer()
+	for _, f := range orc.cfg.metricsFeatures() {
+		if !f.enabled {
+			continue
+		}
+		for _, c := range f.collectors {
+			if _, err := promreg.Register(reg, c); err != nil {
+				return err
+			}
+		}
+	}
+	if err := svcerr.RegisterMetrics(reg); err != nil && !errors.Is(err, svcerr.ErrMetricsRegistered) {
+		return err
+	}
+	var hookErr error
+	logHookOnce.Do(func() {
+		// Expose log severity counts to prometheus.
+		hook, err := logmon.NewPrometheusHookWithRegisterer(reg)
+		if err != nil {
+			hookErr = err
+			return
+		}
+		logrus.AddHook(hook)
+	})
+	return hookErr
+}
@@ -0,0 +1,47 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package oracle
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetricsRegisterer(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	cfg := DefaultConfig()
+	cfg.MetricsRegisterer = reg
+	cfg.MetricsPrefix = "myapp"
+	orc := newTestOracle(t, cfg)
+	require.NoError(t, orc.registerMetrics())
+	// Registering again, e.g. for a second oracle, is allowed.
+	require.NoError(t, orc.registerMetrics())
+
+	versionTotal.WithLabelValues("oracle", "v1", "phylum", "v2").Inc()
+	rr := httptest.NewRecorder()
+	orc.cfg.metricsHandler().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, metricsPath, nil))
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Contains(t, rr.Body.String(), "myapp_version_total")
+}
+
+func TestMetricsFeatures(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	cfg := DefaultConfig()
+	cfg.MetricsRegisterer = reg
+	newTestOracle(t, cfg)
+	// Collectors of disabled features are not registered.
+	require.True(t, reg.Unregister(versionTotal))
+	require.False(t, reg.Unregister(webhookDeliveriesTotal))
+	require.False(t, reg.Unregister(memoryUsageBytes))
+
+	reg = prometheus.NewRegistry()
+	cfg = DefaultConfig()
+	cfg.MetricsRegisterer = reg
+	cfg.MemoryGuard.Limit = 1 << 30
+	newTestOracle(t, cfg)
+	require.True(t, reg.Unregister(memoryUsageBytes))
+}
@@ -0,0 +1,386 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package oracle
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	common "buf.build/gen/go/luthersystems/protos/protocolbuffers/go/common/v1"
+)
+
+const (
+	// openAPIPath is used to serve the OpenAPI document generated from the
+	// registered services.
+	openAPIPath = "/openapi.json"
+
+	openAPIVersion = "3.0.3"
+	// openAPISchemaRef prefixes references to component schemas.
+	openAPISchemaRef = "#/components/schemas/"
+)
+
+// OpenAPIOptions configures the OpenAPI document generated by
+// GenerateOpenAPI.
+type OpenAPIOptions struct {
+	// Title is the title of the API, e.g. the service name.
+	Title string
+	// Version is the version of the API.
+	Version string
+	// EnumNumbers describes enum fields as integers, for gateways
+	// configured with JSONUseEnumNumbers.
+	EnumNumbers bool
+}
+
+// GenerateOpenAPI returns an OpenAPI v3 JSON document describing the HTTP
+// bindings of the methods of gRPC services, identified by their full names,
+// from the global proto registry.  Methods without google.api.http
+// annotations, and client streaming methods, are not described.  Fields are
+// named by their proto names, as the gateway marshals them.
+func GenerateOpenAPI(opts OpenAPIOptions, services ...string) ([]byte, error) {
+	schema, err := DescribeServices(services...)
+	if err != nil {
+		return nil, err
+	}
+	schema.addMessage((&common.ExceptionResponse{}).ProtoReflect().Descriptor())
+	doc := &openAPIDocument{
+		OpenAPI: openAPIVersion,
+		Info:    openAPIInfo{Title: opts.Title, Version: opts.Version},
+		Paths:   make(map[string]map[string]*openAPIOperation),
+		Components: openAPIComponents{
+			Schemas: make(map[string]*openAPISchema),
+		},
+	}
+	g := &openAPIGenerator{schema: schema, enumNumbers: opts.EnumNumbers}
+	for _, m := range schema.Methods {
+		if m.ClientStreaming {
+			continue
+		}
+		for i, b := range m.HTTP {
+			path, params := openAPIPathTemplate(b.Path)
+			op := g.operation(m, b, params)
+			if i > 0 {
+				op.OperationID = fmt.Sprintf("%s_%d", op.OperationID, i)
+			}
+			if doc.Paths[path] == nil {
+				doc.Paths[path] = make(map[string]*openAPIOperation)
+			}
+			doc.Paths[path][strings.ToLower(b.Method)] = op
+		}
+	}
+	for name, ms := range schema.Messages {
+		if _, ok := openAPIWellKnown[name]; ok {
+			continue
+		}
+		doc.Components.Schemas[name] = g.messageSchema(ms)
+	}
+	return json.Marshal(doc)
+}
+
+type openAPIDocument struct {
+	OpenAPI    string                                  `json:"openapi"`
+	Info       openAPIInfo                             `json:"info"`
+	Paths      map[string]map[string]*openAPIOperation `json:"paths"`
+	Components openAPIComponents                       `json:"components"`
+}
+
+type openAPIInfo struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+type openAPIComponents struct {
+	Schemas map[string]*openAPISchema `json:"schemas"`
+}
+
+type openAPIOperation struct {
+	OperationID string                      `json:"operationId"`
+	Tags        []string                    `json:"tags"`
+	Parameters  []*openAPIParameter         `json:"parameters,omitempty"`
+	RequestBody *openAPIRequestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*openAPIResponse `json:"responses"`
+}
+
+type openAPIParameter struct {
+	Name     string         `json:"name"`
+	In       string         `json:"in"`
+	Required bool           `json:"required,omitempty"`
+	Schema   *openAPISchema `json:"schema"`
+}
+
+type openAPIRequestBody struct {
+	Required bool                        `json:"required"`
+	Content  map[string]openAPIMediaType `json:"content"`
+}
+
+type openAPIResponse struct {
+	Description string                      `json:"description"`
+	Content     map[string]openAPIMediaType `json:"content,omitempty"`
+}
+
+type openAPIMediaType struct {
+	Schema *openAPISchema `json:"schema"`
+}
+
+type openAPISchema struct {
+	Ref                  string                    `json:"$ref,omitempty"`
+	Type                 string                    `json:"type,omitempty"`
+	Format               string                    `json:"format,omitempty"`
+	Enum                 []string                  `json:"enum,omitempty"`
+	Items                *openAPISchema            `json:"items,omitempty"`
+	Properties           map[string]*openAPISchema `json:"properties,omitempty"`
+	AdditionalProperties *openAPISchema            `json:"additionalProperties,omitempty"`
+}
+
+// openAPIWellKnown are the schemas of well-known types, which protojson
+// marshals as JSON scalars and values rather than objects.
+var openAPIWellKnown = map[string]*openAPISchema{
+	"google.protobuf.Timestamp":   {Type: "string", Format: "date-time"},
+	"google.protobuf.Duration":    {Type: "string"},
+	"google.protobuf.FieldMask":   {Type: "string"},
+	"google.protobuf.Struct":      {Type: "object"},
+	"google.protobuf.Value":       {},
+	"google.protobuf.ListValue":   {Type: "array", Items: &openAPISchema{}},
+	"google.protobuf.Any":         {Type: "object"},
+	"google.protobuf.Empty":       {Type: "object"},
+	"google.protobuf.DoubleValue": {Type: "number", Format: "double"},
+	"google.protobuf.FloatValue":  {Type: "number", Format: "float"},
+	"google.protobuf.Int64Value":  {Type: "string", Format: "int64"},
+	"google.protobuf.UInt64Value": {Type: "string", Format: "uint64"},
+	"google.protobuf.Int32Value":  {Type: "integer", Format: "int32"},
+	"google.protobuf.UInt32Value": {Type: "integer", Format: "int64"},
+	"google.protobuf.BoolValue":   {Type: "boolean"},
+	"google.protobuf.StringValue": {Type: "string"},
+	"google.protobuf.BytesValue":  {Type: "string", Format: "byte"},
+}
+
+// openAPIScalars are the schemas of scalar field kinds, as marshaled by
+// protojson.
+var openAPIScalars = map[string]*openAPISchema{
+	"bool":     {Type: "boolean"},
+	"string":   {Type: "string"},
+	"bytes":    {Type: "string", Format: "byte"},
+	"int32":    {Type: "integer", Format: "int32"},
+	"sint32":   {Type: "integer", Format: "int32"},
+	"sfixed32": {Type: "integer", Format: "int32"},
+	"uint32":   {Type: "integer", Format: "int64"},
+	"fixed32":  {Type: "integer", Format: "int64"},
+	"int64":    {Type: "string", Format: "int64"},
+	"sint64":   {Type: "string", Format: "int64"},
+	"sfixed64": {Type: "string", Format: "int64"},
+	"uint64":   {Type: "string", Format: "uint64"},
+	"fixed64":  {Type: "string", Format: "uint64"},
+	"float":    {Type: "number", Format: "float"},
+	"double":   {Type: "number", Format: "double"},
+}
+
+// openAPIGenerator converts a schema into OpenAPI operations and schemas.
+type openAPIGenerator struct {
+	schema      *Schema
+	enumNumbers bool
+}
+
+// operation returns the operation of an HTTP binding of a method, whose
+// path template has the params variables.
+func (g *openAPIGenerator) operation(m *MethodSchema, b *HTTPBinding, params []string) *openAPIOperation {
+	service, method := splitMethodName(m.Method)
+	service = service[strings.LastIndex(service, ".")+1:]
+	exception := (&common.ExceptionResponse{}).ProtoReflect().Descriptor().FullName()
+	op := &openAPIOperation{
+		OperationID: service + "_" + method,
+		Tags:        []string{service},
+		Responses: map[string]*openAPIResponse{
+			"default": {
+				Description: "An exception response.",
+				Content:     openAPIJSON(g.refSchema(string(exception))),
+			},
+		},
+	}
+	response := g.refSchema(m.Response)
+	if m.ServerStreaming {
+		// The gateway streams newline delimited results.
+		op.Responses["200"] = &openAPIResponse{
+			Description: "A stream of results.",
+			Content: openAPIJSON(&openAPISchema{
+				Type:       "object",
+				Properties: map[string]*openAPISchema{"result": response},
+			}),
+		}
+	} else {
+		op.Responses["200"] = &openAPIResponse{
+			Description: "A successful response.",
+			Content:     openAPIJSON(response),
+		}
+	}
+	bound := make(map[string]bool)
+	for _, p := range params {
+		bound[strings.SplitN(p, ".", 2)[0]] = true
+		op.Parameters = append(op.Parameters, &openAPIParameter{
+			Name:     p,
+			In:       "path",
+			Required: true,
+			Schema:   g.pathSchema(m.Request, p),
+		})
+	}
+	switch b.Body {
+	case "*":
+		op.RequestBody = &openAPIRequestBody{Required: true, Content: openAPIJSON(g.refSchema(m.Request))}
+		return op
+	case "":
+	default:
+		bound[b.Body] = true
+		if f := g.field(m.Request, b.Body); f != nil {
+			op.RequestBody = &openAPIRequestBody{Required: true, Content: openAPIJSON(g.fieldSchema(f))}
+		}
+	}
+	// Other scalar fields are bound to query parameters.
+	if req, ok := g.schema.Messages[m.Request]; ok {
+		for _, f := range req.Fields {
+			if bound[f.Name] || f.MapKey != "" || (f.Message != "" && openAPIWellKnown[f.Message] == nil) {
+				continue
+			}
+			op.Parameters = append(op.Parameters, &openAPIParameter{
+				Name:   f.Name,
+				In:     "query",
+				Schema: g.fieldSchema(f),
+			})
+		}
+	}
+	return op
+}
+
+// splitMethodName splits a full gRPC method name into its service and
+// method names.
+func splitMethodName(fullMethod string) (string, string) {
+	service, method, _ := strings.Cut(strings.TrimPrefix(fullMethod, "/"), "/")
+	return service, method
+}
+
+func openAPIJSON(s *openAPISchema) map[string]openAPIMediaType {
+	return map[string]openAPIMediaType{"application/json": {Schema: s}}
+}
+
+// openAPIPathTemplate converts an HTTP rule path template into an OpenAPI
+// path, and returns its variables.  Variable patterns are dropped, e.g.
+// "/v1/{name=shelves/*}" becomes "/v1/{name}".
+func openAPIPathTemplate(template string) (string, []string) {
+	var path strings.Builder
+	var params []string
+	for {
+		start := strings.IndexByte(template, '{')
+		end := strings.IndexByte(template, '}')
+		if start < 0 || end < start {
+			path.WriteString(template)
+			return path.String(), params
+		}
+		name, _, _ := strings.Cut(template[start+1:end], "=")
+		params = append(params, name)
+		path.WriteString(template[:start])
+		path.WriteString("{" + name + "}")
+		template = template[end+1:]
+	}
+}
+
+// field returns the field of a message at a dotted path of proto field
+// names, or nil if there is no such field.
+func (g *openAPIGenerator) field(message string, path string) *FieldSchema {
+	var f *FieldSchema
+	for _, name := range strings.Split(path, ".") {
+		ms, ok := g.schema.Messages[message]
+		if !ok {
+			return nil
+		}
+		f = nil
+		for _, candidate := range ms.Fields {
+			if candidate.Name == name {
+				f = candidate
+				break
+			}
+		}
+		if f == nil {
+			return nil
+		}
+		message = f.Message
+	}
+	return f
+}
+
+// pathSchema returns the schema of a path parameter.
+func (g *openAPIGenerator) pathSchema(message string, path string) *openAPISchema {
+	if f := g.field(message, path); f != nil {
+		return g.fieldSchema(f)
+	}
+	return &openAPISchema{Type: "string"}
+}
+
+// refSchema returns the schema of a message, which references its
+// component schema unless it is a well-known type.
+func (g *openAPIGenerator) refSchema(message string) *openAPISchema {
+	if s, ok := openAPIWellKnown[message]; ok {
+		return s
+	}
+	return &openAPISchema{Ref: openAPISchemaRef + message}
+}
+
+// messageSchema returns the component schema of a message.
+func (g *openAPIGenerator) messageSchema(ms *MessageSchema) *openAPISchema {
+	s := &openAPISchema{Type: "object", Properties: make(map[string]*openAPISchema)}
+	for _, f := range ms.Fields {
+		s.Properties[f.Name] = g.fieldSchema(f)
+	}
+	return s
+}
+
+// fieldSchema returns the schema of a field.
+func (g *openAPIGenerator) fieldSchema(f *FieldSchema) *openAPISchema {
+	var value *openAPISchema
+	switch {
+	case f.Message != "":
+		value = g.refSchema(f.Message)
+	case f.Type == "enum" && g.enumNumbers:
+		value = &openAPISchema{Type: "integer", Format: "int32"}
+	case f.Type == "enum":
+		value = &openAPISchema{Type: "string", Enum: f.Enum}
+	case openAPIScalars[f.Type] != nil:
+		value = openAPIScalars[f.Type]
+	default:
+		value = &openAPISchema{}
+	}
+	switch {
+	case f.MapKey != "":
+		return &openAPISchema{Type: "object", AdditionalProperties: value}
+	case f.Repeated:
+		return &openAPISchema{Type: "array", Items: value}
+	}
+	return value
+}
+
+// openAPIHandler serves the OpenAPI document of the registered services,
+// generated on the first request.
+func (orc *Oracle) openAPIHandler() http.Handler {
+	var (
+		once sync.Once
+		doc  []byte
+		err  error
+	)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		once.Do(func() {
+			doc, err = GenerateOpenAPI(OpenAPIOptions{
+				Title:       orc.cfg.ServiceName,
+				Version:     orc.cfg.Version,
+				EnumNumbers: orc.cfg.JSONUseEnumNumbers,
+			}, orc.services...)
+		})
+		if err != nil {
+			orc.log(r.Context()).WithError(err).Errorf("openapi")
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if _, err := w.Write(doc); err != nil {
+			orc.log(r.Context()).WithError(err).Errorf("openapi response error")
+		}
+	})
+}
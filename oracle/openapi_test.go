@@ -0,0 +1,172 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package oracle
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/api/annotations"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const openAPITestService = "oracle.openapitest.ShelfService"
+
+// openAPIService registers a service with HTTP annotations.
+func openAPIService(t *testing.T) {
+	if _, err := protoregistry.GlobalFiles.FindDescriptorByName(openAPITestService); err == nil {
+		return
+	}
+	ts := (&timestamppb.Timestamp{}).ProtoReflect().Descriptor()
+	httpRule := func(rule *annotations.HttpRule) *descriptorpb.MethodOptions {
+		opts := &descriptorpb.MethodOptions{}
+		proto.SetExtension(opts, annotations.E_Http, rule)
+		return opts
+	}
+	field := func(name string, number int32, typ descriptorpb.FieldDescriptorProto_Type, typeName string) *descriptorpb.FieldDescriptorProto {
+		f := &descriptorpb.FieldDescriptorProto{
+			Name:   proto.String(name),
+			Number: proto.Int32(number),
+			Label:  descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+			Type:   typ.Enum(),
+		}
+		if typeName != "" {
+			f.TypeName = proto.String(typeName)
+		}
+		return f
+	}
+	tags := field("tags", 4, descriptorpb.FieldDescriptorProto_TYPE_STRING, "")
+	tags.Label = descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum()
+	fd, err := protodesc.NewFile(&descriptorpb.FileDescriptorProto{
+		Name:       proto.String("oracle/openapi_test.proto"),
+		Package:    proto.String("oracle.openapitest"),
+		Syntax:     proto.String("proto3"),
+		Dependency: []string{ts.ParentFile().Path()},
+		MessageType: []*descriptorpb.DescriptorProto{
+			{
+				Name: proto.String("Shelf"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					field("name", 1, descriptorpb.FieldDescriptorProto_TYPE_STRING, ""),
+					field("count", 2, descriptorpb.FieldDescriptorProto_TYPE_INT64, ""),
+					field("updated_at", 3, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, "."+string(ts.FullName())),
+					tags,
+				},
+			},
+			{
+				Name: proto.String("GetShelfRequest"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					field("name", 1, descriptorpb.FieldDescriptorProto_TYPE_STRING, ""),
+					field("view", 2, descriptorpb.FieldDescriptorProto_TYPE_INT32, ""),
+				},
+			},
+			{
+				Name: proto.String("UpdateShelfRequest"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					field("shelf", 1, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, ".oracle.openapitest.Shelf"),
+					field("validate_only", 2, descriptorpb.FieldDescriptorProto_TYPE_BOOL, ""),
+				},
+			},
+		},
+		Service: []*descriptorpb.ServiceDescriptorProto{{
+			Name: proto.String("ShelfService"),
+			Method: []*descriptorpb.MethodDescriptorProto{
+				{
+					Name:       proto.String("GetShelf"),
+					InputType:  proto.String(".oracle.openapitest.GetShelfRequest"),
+					OutputType: proto.String(".oracle.openapitest.Shelf"),
+					Options: httpRule(&annotations.HttpRule{
+						Pattern: &annotations.HttpRule_Get{Get: "/v1/{name=shelves/*}"},
+					}),
+				},
+				{
+					Name:       proto.String("UpdateShelf"),
+					InputType:  proto.String(".oracle.openapitest.UpdateShelfRequest"),
+					OutputType: proto.String(".oracle.openapitest.Shelf"),
+					Options: httpRule(&annotations.HttpRule{
+						Pattern: &annotations.HttpRule_Patch{Patch: "/v1/{shelf.name}"},
+						Body:    "shelf",
+						AdditionalBindings: []*annotations.HttpRule{{
+							Pattern: &annotations.HttpRule_Put{Put: "/v1/shelves"},
+							Body:    "*",
+						}},
+					}),
+				},
+				{
+					// Methods without HTTP annotations are not described.
+					Name:       proto.String("DeleteShelf"),
+					InputType:  proto.String(".oracle.openapitest.GetShelfRequest"),
+					OutputType: proto.String(".oracle.openapitest.Shelf"),
+				},
+			},
+		}},
+	}, protoregistry.GlobalFiles)
+	require.NoError(t, err)
+	require.NoError(t, protoregistry.GlobalFiles.RegisterFile(fd))
+}
+
+func TestGenerateOpenAPI(t *testing.T) {
+	openAPIService(t)
+	b, err := GenerateOpenAPI(OpenAPIOptions{Title: "shelves", Version: "v1.0.0"}, openAPITestService)
+	require.NoError(t, err)
+	var doc openAPIDocument
+	require.NoError(t, json.Unmarshal(b, &doc))
+	require.Equal(t, "3.0.3", doc.OpenAPI)
+	require.Equal(t, openAPIInfo{Title: "shelves", Version: "v1.0.0"}, doc.Info)
+	require.Len(t, doc.Paths, 3)
+
+	get := doc.Paths["/v1/{name}"]["get"]
+	require.NotNil(t, get)
+	require.Equal(t, "ShelfService_GetShelf", get.OperationID)
+	require.Equal(t, []string{"ShelfService"}, get.Tags)
+	require.Nil(t, get.RequestBody)
+	require.Equal(t, []*openAPIParameter{
+		{Name: "name", In: "path", Required: true, Schema: &openAPISchema{Type: "string"}},
+		{Name: "view", In: "query", Schema: &openAPISchema{Type: "integer", Format: "int32"}},
+	}, get.Parameters)
+	require.Equal(t, "#/components/schemas/oracle.openapitest.Shelf", get.Responses["200"].Content["application/json"].Schema.Ref)
+	require.Equal(t, "#/components/schemas/common.v1.ExceptionResponse", get.Responses["default"].Content["application/json"].Schema.Ref)
+
+	patch := doc.Paths["/v1/{shelf.name}"]["patch"]
+	require.NotNil(t, patch)
+	require.Equal(t, "#/components/schemas/oracle.openapitest.Shelf", patch.RequestBody.Content["application/json"].Schema.Ref)
+	require.Len(t, patch.Parameters, 2)
+	require.Equal(t, "validate_only", patch.Parameters[1].Name)
+
+	put := doc.Paths["/v1/shelves"]["put"]
+	require.NotNil(t, put)
+	require.Equal(t, "ShelfService_UpdateShelf_1", put.OperationID)
+	require.Equal(t, "#/components/schemas/oracle.openapitest.UpdateShelfRequest", put.RequestBody.Content["application/json"].Schema.Ref)
+	require.Empty(t, put.Parameters)
+
+	shelf := doc.Components.Schemas["oracle.openapitest.Shelf"]
+	require.NotNil(t, shelf)
+	require.Equal(t, &openAPISchema{Type: "string", Format: "int64"}, shelf.Properties["count"])
+	require.Equal(t, &openAPISchema{Type: "string", Format: "date-time"}, shelf.Properties["updated_at"])
+	require.Equal(t, &openAPISchema{Type: "array", Items: &openAPISchema{Type: "string"}}, shelf.Properties["tags"])
+	require.Contains(t, doc.Components.Schemas, "common.v1.Exception")
+	require.NotContains(t, doc.Components.Schemas, "google.protobuf.Timestamp")
+}
+
+func TestOpenAPIHandler(t *testing.T) {
+	openAPIService(t)
+	cfg := DefaultConfig()
+	cfg.ServeOpenAPI = true
+	orc := newTestOracle(t, cfg)
+	orc.services = []string{openAPITestService}
+	_, h, err := orc.grpcGateway(nil, nil)
+	require.NoError(t, err)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, openAPIPath, nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	var doc openAPIDocument
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &doc))
+	require.Contains(t, doc.Paths, "/v1/shelves")
+}
@@ -0,0 +1,306 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package oracle
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	common "buf.build/gen/go/luthersystems/protos/protocolbuffers/go/common/v1"
+	"github.com/luthersystems/svc/docstore"
+	"github.com/luthersystems/svc/svcerr"
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	// operationsPath serves the status of operations at
+	// operationsPath/{id}.
+	operationsPath = "/v1/operations"
+	// operationsDir is the key prefix of operation documents.
+	operationsDir = "operations"
+
+	defaultOperationTTL = 7 * 24 * time.Hour
+)
+
+// ErrOperationsDisabled is returned by StartOperation when no operation
+// store is configured.
+var ErrOperationsDisabled = errors.New("operation store not configured")
+
+// OperationState is the state of a long-running operation.
+type OperationState string
+
+const (
+	// OperationRunning operations have not finished.
+	OperationRunning OperationState = "running"
+	// OperationSucceeded operations have a result.
+	OperationSucceeded OperationState = "succeeded"
+	// OperationFailed operations have an exception.
+	OperationFailed OperationState = "failed"
+)
+
+var (
+	operationsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "operations_total",
+			Help: "How many long-running operations finished, partitioned by name and state.",
+		},
+		[]string{"name", "state"},
+	)
+	operationsRunning = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "operations_running",
+			Help: "Long-running operations in progress, partitioned by name.",
+		},
+		[]string{"name"},
+	)
+)
+
+// Operation is the status of a long-running operation, served at
+// /v1/operations/{id}.
+type Operation struct {
+	ID    string         `json:"id"`
+	Name  string         `json:"name"`
+	State OperationState `json:"state"`
+	// Progress is the completion percentage reported by the operation.
+	Progress int `json:"progress"`
+	// Message describes the progress of the operation.
+	Message string `json:"message,omitempty"`
+	// Result is the JSON result of a succeeded operation.
+	Result json.RawMessage `json:"result,omitempty"`
+	// Exception is the exception of a failed operation.
+	Exception json.RawMessage `json:"exception,omitempty"`
+	CreatedAt time.Time       `json:"created_at"`
+	UpdatedAt time.Time       `json:"updated_at"`
+}
+
+// OperationFunc performs the work of a long-running operation, reporting
+// progress with op.  The result is marshaled as JSON, with protojson for
+// proto messages.
+type OperationFunc func(ctx context.Context, op *OperationProgress) (interface{}, error)
+
+// SetOperationStore enables long-running operations, whose status is kept
+// in store.  Operations are started with StartOperation, and their status
+// is served at /v1/operations/{id}.  Operation documents expire after
+// OperationTTL if store implements docstore.TTLPutter.
+func (c *Config) SetOperationStore(store docstore.DocStore) {
+	if c == nil {
+		return
+	}
+	c.operationStore = store
+}
+
+// validOperations validates the operation configuration.
+func (c *Config) validOperations() error {
+	if c.OperationTTL < 0 {
+		return fmt.Errorf("negative operation ttl")
+	}
+	return nil
+}
+
+// OperationProgress reports the progress of a running operation.
+type OperationProgress struct {
+	orc *Oracle
+	mut sync.Mutex
+	op  Operation
+}
+
+// ID returns the operation ID.
+func (p *OperationProgress) ID() string {
+	return p.op.ID
+}
+
+// Update records the completion percentage of the operation and a message
+// describing its progress.
+func (p *OperationProgress) Update(ctx context.Context, percent int, message string) error {
+	p.mut.Lock()
+	defer p.mut.Unlock()
+	p.op.Progress = min(max(percent, 0), 100)
+	p.op.Message = message
+	return p.orc.putOperation(ctx, &p.op)
+}
+
+// finish records the outcome of the operation.
+func (p *OperationProgress) finish(ctx context.Context, result interface{}, err error) error {
+	p.mut.Lock()
+	defer p.mut.Unlock()
+	if err == nil {
+		p.op.Result, err = marshalOperationResult(result)
+	}
+	if err != nil {
+		p.op.State = OperationFailed
+		p.op.Exception, err = protojson.Marshal(operationException(ctx, err))
+		if err != nil {
+			return err
+		}
+	} else {
+		p.op.State = OperationSucceeded
+		p.op.Progress = 100
+	}
+	return p.orc.putOperation(ctx, &p.op)
+}
+
+func marshalOperationResult(result interface{}) (json.RawMessage, error) {
+	if result == nil {
+		return nil, nil
+	}
+	if msg, ok := result.(proto.Message); ok {
+		return protojson.MarshalOptions{UseProtoNames: true}.Marshal(msg)
+	}
+	return json.Marshal(result)
+}
+
+// operationException returns the exception reported for a failed
+// operation.  Errors other than phylum exceptions are masked.
+func operationException(ctx context.Context, err error) *common.Exception {
+	if ex := svcerr.DownstreamException(err); ex != nil {
+		return ex
+	}
+	var ge interface{ GetException() *common.Exception }
+	if errors.As(err, &ge) && ge.GetException() != nil {
+		return ge.GetException()
+	}
+	return svcerr.UnexpectedException(ctx, "Internal server error")
+}
+
+func operationKey(id string) string {
+	return fmt.Sprintf("%s/%s.json", operationsDir, id)
+}
+
+func (orc *Oracle) putOperation(ctx context.Context, op *Operation) error {
+	op.UpdatedAt = orc.Now()
+	b, err := json.Marshal(op)
+	if err != nil {
+		return fmt.Errorf("operation marshal: %w", err)
+	}
+	store := orc.cfg.operationStore
+	if ttlStore, ok := store.(docstore.TTLPutter); ok {
+		ttl := orc.cfg.OperationTTL
+		if ttl == 0 {
+			ttl = defaultOperationTTL
+		}
+		err = ttlStore.PutWithTTL(ctx, operationKey(op.ID), b, ttl)
+	} else {
+		err = store.Put(ctx, operationKey(op.ID), b)
+	}
+	if err != nil {
+		return fmt.Errorf("operation store: %w", err)
+	}
+	return nil
+}
+
+// StartOperation runs fn in the background as a long-running operation, and
+// returns its ID, for handlers to return to the client instead of blocking
+// the request.  Clients poll /v1/operations/{id} for the operation's
+// progress and outcome.  The operation runs on the background worker pool;
+// see Go.  An operation interrupted by the oracle stopping abruptly remains
+// running, which clients detect from its updated_at time.
+func (orc *Oracle) StartOperation(ctx context.Context, name string, fn OperationFunc) (string, error) {
+	if orc.cfg.operationStore == nil {
+		return "", ErrOperationsDisabled
+	}
+	now := orc.Now()
+	p := &OperationProgress{
+		orc: orc,
+		op: Operation{
+			ID:        orc.NewID(),
+			Name:      name,
+			State:     OperationRunning,
+			CreatedAt: now,
+		},
+	}
+	if err := orc.putOperation(ctx, &p.op); err != nil {
+		return "", err
+	}
+	operationsRunning.WithLabelValues(name).Inc()
+	err := orc.Go(ctx, "operation "+name, func(ctx context.Context) {
+		defer operationsRunning.WithLabelValues(name).Dec()
+		log := orc.log(ctx).WithField("operation_id", p.op.ID)
+		var (
+			result interface{}
+			err    error
+		)
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					err = fmt.Errorf("panic: %v", r)
+				}
+			}()
+			result, err = fn(ctx, p)
+		}()
+		if err != nil {
+			log.WithError(err).Warnf("operation failed")
+		}
+		if err := p.finish(ctx, result, err); err != nil {
+			log.WithError(err).Errorf("operation status not stored")
+		}
+		operationsTotal.WithLabelValues(name, string(p.op.State)).Inc()
+	})
+	if err != nil {
+		operationsRunning.WithLabelValues(name).Dec()
+		// The operation never started; record it as failed for clients
+		// which learn of it regardless.
+		if err := p.finish(context.WithoutCancel(ctx), nil, err); err != nil {
+			orc.log(ctx).WithError(err).Errorf("operation status not stored")
+		}
+		return "", err
+	}
+	return p.op.ID, nil
+}
+
+// GetOperation returns the status of an operation.  It returns
+// docstore.ErrRequestNotFound for unknown or expired operations.
+func (orc *Oracle) GetOperation(ctx context.Context, id string) (*Operation, error) {
+	if orc.cfg.operationStore == nil {
+		return nil, ErrOperationsDisabled
+	}
+	if id == "" || strings.Contains(id, "/") || docstore.ValidKey(operationKey(id)) != nil {
+		return nil, docstore.ErrRequestNotFound
+	}
+	b, err := orc.cfg.operationStore.Get(ctx, operationKey(id))
+	if err != nil {
+		return nil, err
+	}
+	op := &Operation{}
+	if err := json.Unmarshal(b, op); err != nil {
+		return nil, fmt.Errorf("operation unmarshal: %w", err)
+	}
+	return op, nil
+}
+
+// operationHandler serves the status of the operation with the id path
+// parameter.
+func (orc *Oracle) operationHandler(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	ctx := r.Context()
+	op, err := orc.GetOperation(ctx, params["id"])
+	if err != nil {
+		code, ex := http.StatusNotFound, svcerr.BusinessException(ctx, "operation not found")
+		if !errors.Is(err, docstore.ErrRequestNotFound) {
+			orc.log(ctx).WithError(err).Errorf("get operation")
+			code, ex = http.StatusServiceUnavailable, svcerr.InfrastructureException(ctx, "operation status unavailable")
+		}
+		if err := writeExceptionHTTP(w, code, ex); err != nil {
+			orc.log(ctx).WithError(err).Errorf("operation handler response error")
+		}
+		return
+	}
+	b, err := json.Marshal(op)
+	if err != nil {
+		orc.log(ctx).WithError(err).Errorf("operation marshal")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write(b); err != nil {
+		orc.log(ctx).WithError(err).Errorf("operation handler response error")
+	}
+}
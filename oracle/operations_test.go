@@ -0,0 +1,132 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package oracle
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/luthersystems/svc/docstore"
+	"github.com/stretchr/testify/require"
+)
+
+// memDocs is an in-memory DocStore.
+type memDocs struct {
+	mut  sync.Mutex
+	docs map[string][]byte
+}
+
+func (m *memDocs) Get(ctx context.Context, key string) ([]byte, error) {
+	m.mut.Lock()
+	defer m.mut.Unlock()
+	b, ok := m.docs[key]
+	if !ok {
+		return nil, docstore.ErrRequestNotFound
+	}
+	return b, nil
+}
+
+func (m *memDocs) Put(ctx context.Context, key string, body []byte) error {
+	m.mut.Lock()
+	defer m.mut.Unlock()
+	m.docs[key] = body
+	return nil
+}
+
+func (m *memDocs) Delete(ctx context.Context, key string) error {
+	m.mut.Lock()
+	defer m.mut.Unlock()
+	delete(m.docs, key)
+	return nil
+}
+
+// waitOperation polls the operation until it finishes.
+func waitOperation(t *testing.T, orc *Oracle, id string) *Operation {
+	var op *Operation
+	require.Eventually(t, func() bool {
+		var err error
+		op, err = orc.GetOperation(context.Background(), id)
+		require.NoError(t, err)
+		return op.State != OperationRunning
+	}, 5*time.Second, 10*time.Millisecond)
+	return op
+}
+
+func TestOperations(t *testing.T) {
+	cfg := DefaultConfig()
+	orc := newTestOracle(t, cfg)
+	ctx := context.Background()
+	_, err := orc.StartOperation(ctx, "disabled", nil)
+	require.ErrorIs(t, err, ErrOperationsDisabled)
+
+	cfg = DefaultConfig()
+	cfg.SetOperationStore(&memDocs{docs: map[string][]byte{}})
+	orc = newTestOracle(t, cfg)
+	release := make(chan struct{})
+	id, err := orc.StartOperation(ctx, "export", func(ctx context.Context, op *OperationProgress) (interface{}, error) {
+		if err := op.Update(ctx, 50, "halfway"); err != nil {
+			return nil, err
+		}
+		<-release
+		return map[string]string{"url": "https://example.com/export"}, nil
+	})
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool {
+		op, err := orc.GetOperation(ctx, id)
+		require.NoError(t, err)
+		return op.Progress == 50
+	}, 5*time.Second, 10*time.Millisecond)
+	close(release)
+	op := waitOperation(t, orc, id)
+	require.Equal(t, OperationSucceeded, op.State)
+	require.Equal(t, "export", op.Name)
+	require.Equal(t, 100, op.Progress)
+	require.JSONEq(t, `{"url":"https://example.com/export"}`, string(op.Result))
+	require.Empty(t, op.Exception)
+
+	// Internal errors are masked.
+	id, err = orc.StartOperation(ctx, "fail", func(ctx context.Context, op *OperationProgress) (interface{}, error) {
+		return nil, errors.New("secret detail")
+	})
+	require.NoError(t, err)
+	op = waitOperation(t, orc, id)
+	require.Equal(t, OperationFailed, op.State)
+	require.Empty(t, op.Result)
+	require.Contains(t, string(op.Exception), "Internal server error")
+	require.NotContains(t, string(op.Exception), "secret detail")
+
+	_, err = orc.GetOperation(ctx, "unknown")
+	require.ErrorIs(t, err, docstore.ErrRequestNotFound)
+}
+
+func TestOperationHandler(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.SetOperationStore(&memDocs{docs: map[string][]byte{}})
+	orc := newTestOracle(t, cfg)
+	id, err := orc.StartOperation(context.Background(), "noop", func(ctx context.Context, op *OperationProgress) (interface{}, error) {
+		return nil, nil
+	})
+	require.NoError(t, err)
+	waitOperation(t, orc, id)
+	_, h, err := orc.grpcGateway(nil, nil)
+	require.NoError(t, err)
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/v1/operations/"+id, nil))
+	require.Equal(t, http.StatusOK, rr.Code)
+	var op Operation
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &op))
+	require.Equal(t, id, op.ID)
+	require.Equal(t, OperationSucceeded, op.State)
+
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/v1/operations/unknown", nil))
+	require.Equal(t, http.StatusNotFound, rr.Code)
+}
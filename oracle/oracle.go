@@ -2,10 +2,14 @@
 
 // Package oracle is a framework that provides a REST/JSON API defined using a
 // GRPC spec, that communicates with the phylum.
+//
+// Importing the package registers a "zstd" compressor with the global gRPC
+// compressor registry, see Compression.
 package oracle
 
 import (
 	"context"
+	"crypto/sha256"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -13,14 +17,26 @@ import (
 	"net/http"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	healthcheck "buf.build/gen/go/luthersystems/protos/protocolbuffers/go/healthcheck/v1"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
 	"github.com/luthersystems/shiroclient-sdk-go/shiroclient"
 	"github.com/luthersystems/shiroclient-sdk-go/shiroclient/phylum"
+	"github.com/luthersystems/svc/docstore"
 	"github.com/luthersystems/svc/grpclogging"
+	"github.com/luthersystems/svc/logging"
+	"github.com/luthersystems/svc/logmon"
+	"github.com/luthersystems/svc/mailer"
+	"github.com/luthersystems/svc/midware"
 	"github.com/luthersystems/svc/opttrace"
+	"github.com/luthersystems/svc/oracle/tasks"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
 	"google.golang.org/protobuf/proto"
 )
 
@@ -42,6 +58,10 @@ const (
 
 	// metricsAddr is the http addr the prometheus server listens on.
 	metricsAddr = ":9600"
+
+	// defaultShutdownTimeout is the default time allowed for in-flight
+	// requests to finish at shutdown.
+	defaultShutdownTimeout = 15 * time.Second
 )
 
 // DefaultConfig returns a default config.
@@ -51,12 +71,15 @@ func DefaultConfig() *Config {
 		EmulateCC: false,
 		// IMPORTANT: Phylum bootstrap expects ListenAddress on :8080 for
 		// FakeAuth IDP. Only change this if you know what you're doing!
-		ListenAddress:     ":8080",
-		PhylumPath:        "./phylum",
-		PhylumServiceName: "phylum",
-		ServiceName:       "oracle",
-		RequestIDHeader:   "X-Request-ID",
-		Version:           "v0.0.1",
+		ListenAddress:       ":8080",
+		PhylumPath:          "./phylum",
+		PhylumServiceName:   "phylum",
+		ServiceName:         "oracle",
+		RequestIDHeader:     "X-Request-ID",
+		Version:             "v0.0.1",
+		AuthCookieName:      defaultAuthCookieName,
+		DepTxCookieName:     defaultDepTxCookieName,
+		PhylumConfigMethods: defaultPhylumConfigMethods(),
 	}
 }
 
@@ -65,6 +88,61 @@ type Config struct {
 	// swaggerHandler configures an endpoint to serve the
 	// swagger API.
 	swaggerHandler http.Handler
+	// logger optionally receives the oracle logs instead of the standard
+	// logrus logger.
+	logger logging.Logger
+	// configSources optionally records where config values were supplied.
+	configSources ConfigSources
+	// logoutNotifier is optionally called when a user logs out.
+	logoutNotifier LogoutNotifier
+	// phylumConfigValidator optionally validates phylum configs.
+	phylumConfigValidator PhylumConfigValidator
+	// blockHeightSource optionally provides the ledger block height.
+	blockHeightSource BlockHeightSource
+	// claimsGetter optionally provides verified user claims.
+	claimsGetter ClaimsGetter
+	// apiKeyStore optionally looks up API keys.
+	apiKeyStore APIKeyStore
+	// ssePaths are server-sent event endpoints by path.
+	ssePaths map[string]*sseEndpoint
+	// webhookDeadLetter optionally stores undeliverable webhooks.
+	webhookDeadLetter docstore.Putter
+	// inboundWebhooks are third-party webhook endpoints by path.
+	inboundWebhooks map[string]*inboundWebhook
+	// bulkImports are NDJSON bulk import endpoints by path.
+	bulkImports map[string]*bulkImport
+	// csvExports are CSV export endpoints by path.
+	csvExports map[string]*csvExport
+	// taskHandlers handle queued tasks by type.
+	taskHandlers []taskHandler
+	// reports are generated on schedule.
+	reports []Report
+	// reportMailer emails report recipients.
+	reportMailer mailer.Mailer
+	// periodicTasks run on schedule.
+	periodicTasks []PeriodicTask
+	// leaderStore optionally holds the leader lease of replicas.
+	leaderStore docstore.ConditionalStore
+	// operationStore optionally holds the status of long-running
+	// operations.
+	operationStore docstore.DocStore
+	// startupChecks are additional dependencies checked at startup.
+	startupChecks []startupCheck
+	// forwardResponseHooks are called on successful gateway responses.
+	forwardResponseHooks []ForwardResponseHook
+	// marshalers are additional gateway marshalers by content type.
+	marshalers map[string]runtime.Marshaler
+	// rejectedPayloadRecorder optionally records rejected payloads.
+	rejectedPayloadRecorder RejectedPayloadRecorder
+	// unaryInterceptorHooks are application interceptors positioned in the
+	// built-in chain.
+	unaryInterceptorHooks []unaryInterceptorHook
+	// unaryInterceptorChain optionally overrides the interceptor chain.
+	unaryInterceptorChain UnaryInterceptorChain
+	// secretResolvers resolve secret references by scheme.
+	secretResolvers map[string]SecretResolver
+	// secretRotationHooks are called when secrets change on refresh.
+	secretRotationHooks []SecretRotationHook
 	// ListenAddress is an address the oracle HTTP listens on.
 	ListenAddress string `yaml:"listen-address"`
 	// PhylumPath is the the path for the business logic.
@@ -80,11 +158,223 @@ type Config struct {
 	// Version is the oracle version.
 	Version string `yaml:"version"`
 	// TraceOpts are tracing options.
-	TraceOpts []opttrace.Option
+	TraceOpts []opttrace.Option `yaml:"-"`
 	// Verbose increases logging.
 	Verbose bool `yaml:"verbose"`
 	// EmulateCC emulates chaincode in memory (for testing).
 	EmulateCC bool `yaml:"emulate-cc"`
+	// LogoutPath is the path that serves the built-in logout endpoint, e.g.
+	// "/v1/logout".  The endpoint shadows any gateway route at the same
+	// path.  The endpoint is disabled if the path is empty, the default.
+	LogoutPath string `yaml:"logout-path"`
+	// AuthCookieName is the name of the cookie holding the auth token.
+	AuthCookieName string `yaml:"auth-cookie-name"`
+	// DepTxCookieName is the name of the cookie holding the dependent
+	// transaction ID.
+	DepTxCookieName string `yaml:"dep-tx-cookie-name"`
+	// CookieDomain is the Domain attribute used for cookies emitted by the
+	// oracle.
+	CookieDomain string `yaml:"cookie-domain"`
+	// CookieInsecure disables the Secure attribute on cookies emitted by the
+	// oracle (for local development).
+	CookieInsecure bool `yaml:"cookie-insecure"`
+	// DisableTracePropagation disables propagation of the trace context to
+	// the shiroclient gateway on phylum calls.
+	DisableTracePropagation bool `yaml:"disable-trace-propagation"`
+	// ServeConfigz serves the effective config (with secrets masked) on the
+	// metrics server.
+	ServeConfigz bool `yaml:"serve-configz"`
+	// ServeSchemaz serves the request and response schemas of the
+	// registered gRPC methods, as JSON, on the metrics server.
+	ServeSchemaz bool `yaml:"serve-schemaz"`
+	// ServeOpenAPI serves an OpenAPI v3 document generated from the
+	// registered gRPC services and their HTTP annotations at /openapi.json
+	// on the listen address.
+	ServeOpenAPI bool `yaml:"serve-openapi"`
+	// PhylumConfigMethods names the phylum endpoints used to manage the
+	// phylum's bootstrap configuration.
+	PhylumConfigMethods PhylumConfigMethods `yaml:"phylum-config-methods"`
+	// AdminListenAddress is an address the admin HTTP server listens on.
+	// The admin server is disabled if the address is empty.
+	AdminListenAddress string `yaml:"admin-listen-address"`
+	// AdminToken is a bearer token required to access the admin server.
+	AdminToken string `yaml:"admin-token" secret:"true"`
+	// AdminTLSCertFile is the path to the admin server TLS certificate.
+	AdminTLSCertFile string `yaml:"admin-tls-cert-file"`
+	// AdminTLSKeyFile is the path to the admin server TLS key.
+	AdminTLSKeyFile string `yaml:"admin-tls-key-file"`
+	// AdminClientCAFile is the path to a CA bundle used to verify admin
+	// client certificates (mTLS).
+	AdminClientCAFile string `yaml:"admin-client-ca-file"`
+	// ConditionalPathPrefixes enables conditional GET requests (ETag and
+	// If-None-Match) derived from the ledger block height for request
+	// paths with one of the given prefixes.
+	ConditionalPathPrefixes []string `yaml:"conditional-path-prefixes"`
+	// DedupePathPrefixes protects request paths with one of the given
+	// prefixes, e.g. uploads served by path overrides, from double
+	// submission by replaying the response to requests retried with the
+	// same Idempotency-Key header.  See midware.Dedupe.
+	DedupePathPrefixes []string `yaml:"dedupe-path-prefixes"`
+	// DedupeWindow is the time for which responses to deduplicated paths
+	// are replayed.  Defaults to 5 minutes.
+	DedupeWindow time.Duration `yaml:"dedupe-window"`
+	// TransientFields maps request headers and user claims to transient
+	// data fields passed to the phylum on every call.
+	TransientFields []TransientField `yaml:"transient-fields"`
+	// ForwardedHeaders are additional headers forwarded by the grpc-gateway.
+	// Use ForwardHeader to add headers.
+	ForwardedHeaders []ForwardedHeader `yaml:"forwarded-headers"`
+	// HeaderForwarding restricts, renames and limits the size of the request
+	// headers forwarded by the grpc-gateway.
+	HeaderForwarding HeaderForwardingPolicy `yaml:"header-forwarding"`
+	// Webhooks are endpoints receiving events queued with EnqueueWebhook.
+	Webhooks []WebhookEndpoint `yaml:"webhooks"`
+	// WebhookMaxAttempts is the number of delivery attempts made before a
+	// webhook is dead lettered.  Defaults to 5.
+	WebhookMaxAttempts int `yaml:"webhook-max-attempts"`
+	// GRPCServerMetrics installs grpc_prometheus server interceptors on the
+	// oracle grpc server, in addition to the gateway client metrics.
+	GRPCServerMetrics bool `yaml:"grpc-server-metrics"`
+	// GRPCHistogramBuckets are the buckets of grpc_prometheus handling time
+	// histograms, in seconds.  Defaults to exponential buckets from 50ms.
+	GRPCHistogramBuckets []float64 `yaml:"grpc-histogram-buckets"`
+	// MetricsRegisterer registers oracle metrics.  If it is also a
+	// prometheus.Gatherer, such as a *prometheus.Registry, the metrics
+	// server serves its metrics.  Defaults to the default prometheus
+	// registerer.
+	MetricsRegisterer prometheus.Registerer `yaml:"-"`
+	// MetricsPrefix prefixes the names of oracle metrics registered with
+	// MetricsRegisterer, separated by an underscore.
+	MetricsPrefix string `yaml:"metrics-prefix"`
+	// MetricsExemplars records request latency histograms at the HTTP and
+	// gRPC layers whose observations carry the trace ID of sampled requests
+	// as exemplars, and serves metrics in the OpenMetrics format, which
+	// carries exemplars, to scrapers which accept it.
+	MetricsExemplars bool `yaml:"metrics-exemplars"`
+	// StartupMaxWait is the maximum time to wait at startup for the
+	// gateway, JWKS endpoint, and registered dependencies to be reachable
+	// before accepting traffic.  If zero dependencies are not checked.
+	StartupMaxWait time.Duration `yaml:"startup-max-wait"`
+	// StartupFailFast fails startup on the first unreachable dependency,
+	// instead of retrying with backoff.
+	StartupFailFast bool `yaml:"startup-fail-fast"`
+	// JWKSEndpoint is the URL of the JWKS used to verify user tokens.  It is
+	// checked at startup when set.
+	JWKSEndpoint string `yaml:"jwks-endpoint"`
+	// JSONEmitUnpopulated emits unpopulated fields, including zero values
+	// and empty lists, in gateway JSON responses.
+	JSONEmitUnpopulated bool `yaml:"json-emit-unpopulated"`
+	// JSONEmitDefaultValues emits primitive fields with default values in
+	// gateway JSON responses, but not unset messages or oneofs.
+	JSONEmitDefaultValues bool `yaml:"json-emit-default-values"`
+	// JSONUseEnumNumbers emits enum values as numbers instead of names in
+	// gateway JSON responses.
+	JSONUseEnumNumbers bool `yaml:"json-use-enum-numbers"`
+	// GatewayProtobuf serves binary protobuf (application/x-protobuf)
+	// requests and responses through the gateway, selected by the
+	// Content-Type and Accept headers.
+	GatewayProtobuf bool `yaml:"gateway-protobuf"`
+	// GRPCWeb serves unary grpc-web requests on the listen address,
+	// alongside the gateway.
+	GRPCWeb bool `yaml:"grpc-web"`
+	// CachePolicies are caching policies of GET routes by route pattern.
+	// Use CacheControl to add policies.  Other responses are not cached.
+	CachePolicies map[string]CachePolicy `yaml:"cache-policies"`
+	// TrustedProxies are the IP addresses and CIDR networks of reverse
+	// proxies, such as load balancers, whose X-Forwarded-Proto and
+	// X-Forwarded-Host headers are trusted.
+	TrustedProxies []string `yaml:"trusted-proxies"`
+	// ClaimsSchema describes the claims required of every user, checked by
+	// GetClaims.
+	ClaimsSchema ClaimsSchema `yaml:"claims-schema"`
+	// APIKeyHeader is the request header holding API keys of
+	// machine-to-machine clients, e.g. "X-API-Key".  API key authentication
+	// is disabled if the header is empty.
+	APIKeyHeader string `yaml:"api-key-header"`
+	// APIKeys are statically configured API keys.  Use SetAPIKeyStore to
+	// look up other keys.
+	APIKeys []APIKey `yaml:"api-keys"`
+	// ClientCredentials configures OAuth2 client credentials tokens sent
+	// with outbound calls, such as webhooks.
+	ClientCredentials ClientCredentials `yaml:"client-credentials"`
+	// BackgroundWorkers is the maximum number of background tasks started
+	// with Go which run concurrently.  Defaults to 16.
+	BackgroundWorkers int `yaml:"background-workers"`
+	// BackgroundDrainTimeout is the time allowed for background tasks to
+	// finish at shutdown, before their context is cancelled.  Defaults to
+	// 30 seconds.
+	BackgroundDrainTimeout time.Duration `yaml:"background-drain-timeout"`
+	// ReadOnlyMethods are phylum methods which must never write to the
+	// ledger.  Calls to these methods are handled as if made with a
+	// WithReadOnly context, and are the only methods a WithReadOnly context
+	// may call.
+	ReadOnlyMethods []string `yaml:"read-only-methods"`
+	// ReadOnlyEndpoints optionally names the gateway endpoints, such as
+	// read replicas, which serve read-only phylum calls.
+	ReadOnlyEndpoints []string `yaml:"read-only-endpoints"`
+	// PathNormalization normalizes request paths before they are routed.
+	PathNormalization PathNormalization `yaml:"path-normalization"`
+	// Compression configures compression of large gRPC messages and phylum
+	// gateway requests.
+	Compression Compression `yaml:"compression"`
+	// ShutdownTimeout is the time allowed for in-flight requests to finish
+	// when the context of StartGateway is cancelled.  Defaults to 15
+	// seconds.
+	ShutdownTimeout time.Duration `yaml:"shutdown-timeout"`
+	// PreStopDelay is the time Run waits after a stop signal, while the
+	// health check reports the oracle as DOWN, before it stops accepting
+	// requests.  It lets load balancers drain the oracle during rollouts
+	// and should exceed the readiness probe period.
+	PreStopDelay time.Duration `yaml:"pre-stop-delay"`
+	// PhylumCutover routes a share of phylum calls to a candidate phylum,
+	// for blue/green phylum rollouts.
+	PhylumCutover PhylumCutover `yaml:"phylum-cutover"`
+	// SlowRequestThresholds maps gRPC method prefixes to the duration
+	// after which requests are logged as slow, at warning level, and
+	// counted.  The prefix "*" applies to all other methods.
+	SlowRequestThresholds grpclogging.SlowThresholds `yaml:"slow-request-thresholds"`
+	// AlertWebhook forwards error logs to an alerting webhook while the
+	// oracle runs.
+	AlertWebhook AlertWebhook `yaml:"alert-webhook"`
+	// MemoryGuard sheds load when the process nears its memory limit.
+	MemoryGuard MemoryGuard `yaml:"memory-guard"`
+	// TaskQueue configures a queue of asynchronous tasks.
+	TaskQueue TaskQueue `yaml:"task-queue"`
+	// RejectedPayloads logs request payloads rejected by the gateway.
+	RejectedPayloads RejectedPayloads `yaml:"rejected-payloads"`
+	// DisabledInterceptors names built-in gRPC interceptors which are not
+	// installed, e.g. "claims-cache".  Disabling "logging" or "svcerr"
+	// breaks logging and error conventions relied upon by other
+	// components.
+	DisabledInterceptors []string `yaml:"disabled-interceptors"`
+	// GRPCMaxMessageSize is the maximum size in bytes of messages received
+	// and sent by the internal gRPC server and the gateway.  Defaults to
+	// the gRPC default of 4 MiB.
+	GRPCMaxMessageSize int `yaml:"grpc-max-message-size"`
+	// GRPCServerOptions are additional options of the internal gRPC
+	// server, e.g. keepalive enforcement.  They are applied after the
+	// options set by the oracle, so they override its limits.  The oracle
+	// installs the unary interceptor: grpc.UnaryInterceptor must not be
+	// given, as gRPC panics when it is set twice.  Use
+	// AddUnaryInterceptorBefore and AddUnaryInterceptorAfter, or
+	// grpc.ChainUnaryInterceptor to run interceptors inside the oracle's
+	// chain.
+	GRPCServerOptions []grpc.ServerOption `yaml:"-"`
+	// SecretRefreshInterval is the time between refreshes of the secret
+	// references resolved by the oracle, while it runs.  Secrets are not
+	// refreshed if zero.  See AddSecretResolver.
+	SecretRefreshInterval time.Duration `yaml:"secret-refresh-interval"`
+	// LeaderLease is the duration of the lease held by the leader elected
+	// among replicas, renewed every third of it.  Defaults to 15s.  See
+	// SetLeaderElection.
+	LeaderLease time.Duration `yaml:"leader-lease"`
+	// OperationTTL is the time for which the status of long-running
+	// operations is kept, when the operation store supports expiry.
+	// Defaults to 7 days.  See SetOperationStore.
+	OperationTTL time.Duration `yaml:"operation-ttl"`
+	// Locale configures the resolution of the language and time zone of
+	// requests.
+	Locale LocaleConfig `yaml:"locale"`
 }
 
 // SetSwaggerHandler configures an endpoint to serve the swagger API.
@@ -95,6 +385,35 @@ func (c *Config) SetSwaggerHandler(h http.Handler) {
 	c.swaggerHandler = h
 }
 
+// SetLogger configures the logger receiving the oracle logs, e.g. a zap
+// logger created by logging.NewZap, instead of the standard logrus logger.
+// Log levels, including method overrides, are still controlled by the
+// oracle.
+func (c *Config) SetLogger(l logging.Logger) {
+	if c == nil {
+		return
+	}
+	c.logger = l
+}
+
+// SetLogoutNotifier configures a function that is called when a user logs
+// out, e.g. to revoke the session with the IDP.
+func (c *Config) SetLogoutNotifier(fn LogoutNotifier) {
+	if c == nil {
+		return
+	}
+	c.logoutNotifier = fn
+}
+
+// SetPhylumConfigValidator configures a function that validates phylum
+// configuration before it is set.
+func (c *Config) SetPhylumConfigValidator(fn PhylumConfigValidator) {
+	if c == nil {
+		return
+	}
+	c.phylumConfigValidator = fn
+}
+
 // SetOTLPEndpoint is a helper to set the OTLP trace endpoint.
 func (c *Config) SetOTLPEndpoint(endpoint string) {
 	if c == nil || endpoint == "" {
@@ -126,9 +445,125 @@ func (c *Config) Valid() error {
 	if c.Version == "" {
 		return fmt.Errorf("missing version")
 	}
+	if c.LogoutPath != "" && !strings.HasPrefix(c.LogoutPath, "/") {
+		return fmt.Errorf("logout path must be absolute")
+	}
+	if err := c.validAdmin(); err != nil {
+		return err
+	}
+	for _, f := range c.TransientFields {
+		if err := f.valid(); err != nil {
+			return err
+		}
+	}
+	if err := c.validForwardedHeaders(); err != nil {
+		return err
+	}
+	if err := c.validHeaderForwarding(); err != nil {
+		return err
+	}
+	if err := c.validWebhooks(); err != nil {
+		return err
+	}
+	if err := c.validSecrets(); err != nil {
+		return err
+	}
+	if err := c.validInboundWebhooks(); err != nil {
+		return err
+	}
+	if err := c.validGRPCMetrics(); err != nil {
+		return err
+	}
+	if err := c.validStartup(); err != nil {
+		return err
+	}
+	if err := c.validMarshalers(); err != nil {
+		return err
+	}
+	if err := c.validCachePolicies(); err != nil {
+		return err
+	}
+	if _, err := midware.NewTrustedProxies(c.TrustedProxies...); err != nil {
+		return err
+	}
+	if err := c.ClaimsSchema.valid(); err != nil {
+		return err
+	}
+	if err := c.validAPIKeys(); err != nil {
+		return err
+	}
+	if err := c.ClientCredentials.valid(); err != nil {
+		return err
+	}
+	if err := c.validBackgroundWorkers(); err != nil {
+		return err
+	}
+	if err := c.PathNormalization.valid(); err != nil {
+		return err
+	}
+	if err := c.Compression.valid(); err != nil {
+		return err
+	}
+	if c.ShutdownTimeout < 0 {
+		return fmt.Errorf("invalid shutdown timeout")
+	}
+	if c.PreStopDelay < 0 {
+		return fmt.Errorf("invalid pre-stop delay")
+	}
+	if err := c.validPhylumCutover(); err != nil {
+		return err
+	}
+	if err := c.SlowRequestThresholds.Valid(); err != nil {
+		return err
+	}
+	if err := c.AlertWebhook.valid(); err != nil {
+		return err
+	}
+	if err := c.MemoryGuard.valid(); err != nil {
+		return err
+	}
+	if err := c.TaskQueue.valid(); err != nil {
+		return err
+	}
+	if err := c.validReports(); err != nil {
+		return err
+	}
+	if c.DedupeWindow < 0 {
+		return fmt.Errorf("negative dedupe window")
+	}
+	if err := c.validLeaderElection(); err != nil {
+		return err
+	}
+	if err := c.validPeriodicTasks(); err != nil {
+		return err
+	}
+	if err := c.validOperations(); err != nil {
+		return err
+	}
+	if err := c.validLocale(); err != nil {
+		return err
+	}
+	if err := c.RejectedPayloads.valid(); err != nil {
+		return err
+	}
+	if err := c.validInterceptors(); err != nil {
+		return err
+	}
+	if err := c.validGRPCServer(); err != nil {
+		return err
+	}
 	return nil
 }
 
+// shutdownTimeout returns the time allowed for in-flight requests to finish
+// at shutdown.
+func (c *Config) shutdownTimeout() time.Duration {
+	if c.ShutdownTimeout == 0 {
+		return defaultShutdownTimeout
+	}
+	return c.ShutdownTimeout
+}
+
 type oracleState int
 
 const (
@@ -145,15 +580,46 @@ type Oracle struct {
 	// log provides logging.
 	logBase *logrus.Entry
 
+	// levels controls log levels at runtime.
+	levels *grpclogging.LevelController
+	// logInterceptor logs method calls of the gRPC server.
+	logInterceptor grpc.UnaryServerInterceptor
+
 	// phylum interacts with phylum.
 	phylum *phylum.Client
 
+	// candidate optionally interacts with the candidate phylum of a
+	// cutover.
+	candidate *phylum.Client
+
+	// alertHook optionally forwards error logs to an alerting webhook.
+	alertHook *logmon.AlertHook
+
+	// memGuard optionally sheds load when memory is short.
+	memGuard *memoryGuard
+
+	// secrets caches resolved secret references.
+	secrets *secretCache
+
+	// leader optionally elects a leader among replicas.
+	leader *leaderElector
+
+	// taskQueue, taskClient and taskRunner queue asynchronous tasks, when
+	// configured.
+	taskQueue  tasks.Queue
+	taskClient *tasks.Client
+	taskRunner *tasks.Runner
+
 	// Optional application tracing provider
 	tracer *opttrace.Tracer
 
 	// txConfigs generates default transaction configs
 	txConfigs func(context.Context, ...shiroclient.Config) []shiroclient.Config
 
+	// callPhylum calls a phylum method, capturing the raw gateway
+	// response.  It is replaced in tests.
+	callPhylum func(ctx context.Context, ph *phylum.Client, methodName string, req proto.Message, resp proto.Message, raw *interface{}, configs ...shiroclient.Config) (proto.Message, error)
+
 	cachedPhylumVersion string
 
 	cfg Config
@@ -165,6 +631,54 @@ type Oracle struct {
 
 	// phylumVersionMut guards cachedPhylumVersion.
 	phylumVersionMut sync.RWMutex
+
+	// maintenance is true when the oracle is in maintenance mode.
+	maintenance atomic.Bool
+
+	// stopping is true once Run received a signal to stop.
+	stopping atomic.Bool
+
+	// clock optionally fixes the time returned by Now.
+	clock func() time.Time
+
+	// ids optionally generates the IDs returned by NewID.
+	ids *seededIDs
+
+	// grpcSocket optionally fixes the path of the gRPC server socket.
+	grpcSocket string
+
+	// services are the names of the registered gRPC services.
+	services []string
+
+	// notice injects operational notice headers into responses.
+	notice midware.ServiceNotice
+
+	// lastCommitBlock is the highest commit block observed.
+	lastCommitBlock atomic.Uint64
+
+	// webhooks delivers outbound webhooks, if configured.
+	webhooks *webhookDispatcher
+
+	// apiKeys are the static API keys indexed by hash.
+	apiKeys map[[sha256.Size]byte]APIKey
+
+	// clientCredentials obtains tokens for outbound calls, if configured.
+	clientCredentials *TokenSource
+
+	// workers runs background tasks started with Go.
+	workers *workerPool
+
+	// phylumClient sends phylum gateway requests, if not the default.
+	phylumClient *http.Client
+
+	// healthReporters are dependency health checks.
+	healthReporters []*healthReporter
+
+	// healthMut guards healthReporters.
+	healthMut sync.RWMutex
+
+	// locales resolves the locale of requests, if configured.
+	locales *localeResolver
 }
 
 // option provides additional configuration to the oracle. Primarily for
@@ -234,17 +748,44 @@ func newOracle(config *Config, opts ...option) (*Oracle, error) {
 	if err != nil {
 		return nil, fmt.Errorf("invalid config: %w", err)
 	}
+	secrets := newSecretCache(config)
+	cfg := *config
+	resolveCtx, cancel := context.WithTimeout(context.Background(), defaultSecretResolveTimeout)
+	err = secrets.resolveSecrets(resolveCtx, &cfg)
+	cancel()
+	if err != nil {
+		return nil, err
+	}
+	locales, err := newLocaleResolver(cfg.Locale)
+	if err != nil {
+		return nil, err
+	}
 	oracle := &Oracle{
-		cfg:            *config,
+		cfg:            cfg,
 		swaggerHandler: config.swaggerHandler,
+		apiKeys:        apiKeyIndex(cfg.APIKeys),
+		workers:        newWorkerPool(&cfg),
+		secrets:        secrets,
+		locales:        locales,
 	}
 	oracle.logBase = logrus.StandardLogger().WithFields(nil)
+	if config.logger != nil {
+		oracle.logBase = logging.ToLogrus(config.logger, logrus.StandardLogger().GetLevel())
+	}
 	for _, opt := range opts {
 		err := opt(oracle)
 		if err != nil {
 			return nil, err
 		}
 	}
+	if err := oracle.registerMetrics(); err != nil {
+		return nil, fmt.Errorf("register metrics: %w", err)
+	}
+	oracle.levels = grpclogging.NewLevelController(oracle.logBase.Logger)
+	oracle.logInterceptor, err = oracle.newLogInterceptor()
+	if err != nil {
+		return nil, fmt.Errorf("logging interceptor: %w", err)
+	}
 	if oracle.phylum == nil {
 		if oracle.cfg.GatewayEndpoint == "" {
 			oracle.cfg.GatewayEndpoint = fmt.Sprintf("http://shiroclient_gw_%s:8082", oracle.cfg.PhylumServiceName)
@@ -254,13 +795,43 @@ func newOracle(config *Config, opts ...option) (*Oracle, error) {
 			return nil, err
 		}
 	}
-	oracle.txConfigs = txConfigs()
+	if oracle.cfg.PhylumCutover.enabled() && oracle.candidate == nil {
+		err := withCandidatePhylum(oracle.cfg.PhylumCutover.CandidateEndpoint)(oracle)
+		if err != nil {
+			return nil, err
+		}
+	}
+	oracle.phylumClient = oracle.phylumHTTPClient()
+	oracle.txConfigs = txConfigs(oracle)
+	oracle.callPhylum = callPhylum
+	if oracle.candidate != nil {
+		// The candidate is reported, but does not serve production traffic
+		// unless routed to, so its outages do not take the oracle down.
+		oracle.AddHealthReporter(oracle.cfg.PhylumServiceName+"-candidate", oracle.candidateHealthReporter(), informational())
+	}
+	if oracle.cfg.ClientCredentials.enabled() {
+		oracle.clientCredentials = NewTokenSource(oracle.cfg.ClientCredentials)
+	}
+	if len(oracle.cfg.Webhooks) > 0 {
+		oracle.webhooks = newWebhookDispatcher(&oracle.cfg, oracle.logBase, oracle.clientCredentials)
+		oracle.webhooks.client = oracle.HTTPClient("webhook", WithHTTPTimeout(defaultWebhookTimeout))
+	}
 	t, err := opttrace.New(context.Background(), "oracle", oracle.cfg.TraceOpts...)
 	if err != nil {
 		return nil, err
 	}
 	t.SetGlobalTracer()
 	oracle.tracer = t
+	oracle.startAlerts()
+	if oracle.cfg.MemoryGuard.Limit > 0 {
+		oracle.memGuard = newMemoryGuard(oracle.cfg.MemoryGuard, oracle.cfg.RequestIDHeader, oracle.logBase)
+	}
+	if oracle.cfg.leaderStore != nil {
+		oracle.leader = newLeaderElector(&oracle.cfg, oracle.NewID(), oracle.Now, oracle.logBase)
+	}
+	if err := oracle.initTasks(context.Background()); err != nil {
+		return nil, err
+	}
 
 	return oracle, nil
 }
@@ -269,7 +840,7 @@ func (orc *Oracle) log(ctx context.Context) *logrus.Entry {
 	return grpclogging.GetLogrusEntry(ctx, orc.logBase)
 }
 
-func txConfigs() func(context.Context, ...shiroclient.Config) []shiroclient.Config {
+func txConfigs(orc *Oracle) func(context.Context, ...shiroclient.Config) []shiroclient.Config {
 	return func(ctx context.Context, extend ...shiroclient.Config) []shiroclient.Config {
 		fields := grpclogging.GetLogrusFields(ctx)
 		configs := []shiroclient.Config{
@@ -279,11 +850,34 @@ func txConfigs() func(context.Context, ...shiroclient.Config) []shiroclient.Conf
 			logrus.WithField("req_id", fields["req_id"]).Debugf("setting request id")
 			configs = append(configs, shiroclient.WithID(fmt.Sprint(fields["req_id"])))
 		}
+		if !orc.cfg.DisableTracePropagation {
+			configs = append(configs, traceConfigs(ctx)...)
+		}
+		if orc.phylumClient != nil {
+			configs = append(configs, shiroclient.WithHTTPClient(orc.phylumClient))
+		}
+		configs = append(configs, orc.transientConfigs(ctx)...)
 		configs = append(configs, extend...)
 		return configs
 	}
 }
 
+// traceConfigs propagates the W3C trace context (traceparent/tracestate) of
+// the current span to the shiroclient gateway, so that gateway and chaincode
+// spans join the oracle's distributed trace.
+func traceConfigs(ctx context.Context) []shiroclient.Config {
+	if !trace.SpanContextFromContext(ctx).IsValid() {
+		return nil
+	}
+	carrier := propagation.MapCarrier{}
+	propagation.TraceContext{}.Inject(ctx, carrier)
+	configs := make([]shiroclient.Config, 0, len(carrier))
+	for _, k := range carrier.Keys() {
+		configs = append(configs, shiroclient.WithHeader(k, carrier.Get(k)))
+	}
+	return configs
+}
+
 // setPhylumVersion sets the last seen phylum version and is concurrency safe.
 func (orc *Oracle) setPhylumVersion(version string) {
 	orc.phylumVersionMut.Lock()
@@ -308,7 +902,7 @@ func (orc *Oracle) phylumHealthCheck(ctx context.Context) []*healthcheck.HealthC
 		return []*healthcheck.HealthCheckReport{{
 			ServiceName:    orc.cfg.PhylumServiceName,
 			ServiceVersion: "",
-			Timestamp:      time.Now().Format(timestampFormat),
+			Timestamp:      orc.timestamp(),
 			Status:         "DOWN",
 		}}
 	}
@@ -329,13 +923,13 @@ func (orc *Oracle) GetHealthCheck(ctx context.Context, req *healthcheck.GetHealt
 	healthy := true
 	var reports []*healthcheck.HealthCheckReport
 	if !req.GetHttpOnly() {
+		dependencies := make(chan []*healthcheck.HealthCheckReport, 1)
+		go func() {
+			dependencies <- orc.reportHealth(ctx)
+		}()
 		reports = orc.phylumHealthCheck(ctx)
-		for _, report := range reports {
-			if !strings.EqualFold(report.GetStatus(), "UP") {
-				healthy = false
-				break
-			}
-		}
+		reports = append(reports, <-dependencies...)
+		healthy = orc.healthy(reports)
 	}
 	if orc.getLastPhylumVersion() == "" && !orc.cfg.EmulateCC {
 		orc.log(ctx).Warnf("missing phylum version")
@@ -344,7 +938,7 @@ func (orc *Oracle) GetHealthCheck(ctx context.Context, req *healthcheck.GetHealt
 	reports = append(reports, &healthcheck.HealthCheckReport{
 		ServiceName:    orc.cfg.ServiceName,
 		ServiceVersion: orc.cfg.Version,
-		Timestamp:      time.Now().Format(timestampFormat),
+		Timestamp:      orc.timestamp(),
 		Status:         "UP",
 	})
 	resp := &healthcheck.GetHealthCheckResponse{
@@ -371,12 +965,73 @@ func (orc *Oracle) close() error {
 	}
 	orc.state = oracleStateStopped
 
+	if orc.workers != nil {
+		orc.workers.close(orc.logBase)
+	}
+	if orc.webhooks != nil {
+		orc.webhooks.close()
+	}
+	orc.closeTasks()
+	orc.stopAlerts()
+	if orc.candidate != nil {
+		if err := orc.candidate.Close(); err != nil {
+			orc.logBase.WithError(err).Warn("failed to close candidate phylum")
+		}
+	}
 	return orc.phylum.Close()
 }
 
-// Call calls the phylum.
+// callPhylum calls a phylum method with the gateway client.
+func callPhylum(ctx context.Context, ph *phylum.Client, methodName string, req proto.Message, resp proto.Message, raw *interface{}, configs ...shiroclient.Config) (proto.Message, error) {
+	configs = append(configs, shiroclient.WithResponse(raw))
+	return phylum.Call(ph, ctx, methodName, req, resp, configs...)
+}
+
+// Call calls the phylum.  The request stage is recorded for cancellation
+// and deadline metrics.  The ID and block of a committed transaction are
+// recorded in the transaction details of ctx (see txctx), and the block is
+// the default ledger height of conditional requests.
+//
+// Calls made with a WithReadOnly context, or to one of the configured
+// ReadOnlyMethods, are read-only: they are routed to the ReadOnlyEndpoints
+// and marked on the request span and log.  The gateway does not support
+// simulation-only calls, so a WithReadOnly context may only call the
+// ReadOnlyMethods; calls to any other method are refused before they reach
+// the phylum.  A read-only method which nonetheless commits a transaction
+// has already written, but an error is returned so the caller never treats
+// the write as a successful query.
+//
+// With a PhylumCutover configured, calls matching its rules are made to the
+// candidate phylum instead.  Read-only calls to the candidate are not routed
+// to the ReadOnlyEndpoints, which serve the primary phylum.
 func Call[K proto.Message, R proto.Message](s *Oracle, ctx context.Context, methodName string, req K, resp R, config ...shiroclient.Config) (R, error) {
 	configs := s.txConfigs(ctx)
 	configs = append(configs, config...)
-	return phylum.Call(s.phylum, ctx, methodName, req, resp, configs...)
+	ph, candidate := s.routePhylum(ctx)
+	if err := s.allowReadOnly(ctx, methodName); err != nil {
+		var empty R
+		return empty, err
+	}
+	readOnly := s.readOnly(ctx, methodName)
+	if readOnly {
+		configs = append(configs, s.readOnlyConfigs(ctx, !candidate)...)
+	}
+	grpclogging.SetStage(ctx, grpclogging.StagePhylum)
+	defer grpclogging.SetStage(ctx, grpclogging.StageAfterPhylum)
+	var raw interface{}
+	out, err := s.callPhylum(ctx, ph, methodName, req, resp, &raw, configs...)
+	if r, ok := out.(R); ok {
+		resp = r
+	}
+	if err != nil {
+		return resp, err
+	}
+	s.recordTx(ctx, raw)
+	if readOnly {
+		if err := s.checkReadOnly(ctx, methodName, raw); err != nil {
+			var empty R
+			return empty, err
+		}
+	}
+	return resp, nil
 }
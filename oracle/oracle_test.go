@@ -0,0 +1,47 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package oracle
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestOracle constructs an oracle for unit tests with newOracle, so the
+// wiring of cfg is exercised as in production.  The phylum client is
+// created but never connected, metrics are registered to a fresh registry
+// unless cfg has a registerer, and logs are written to the test log unless
+// another log base is given.  The oracle is closed when the test ends.
+func newTestOracle(t *testing.T, cfg *Config, opts ...option) *Oracle {
+	t.Helper()
+	cfg.Verbose = testing.Verbose()
+	if cfg.MetricsRegisterer == nil {
+		cfg.MetricsRegisterer = prometheus.NewRegistry()
+	}
+	logger := logrus.New()
+	logger.SetOutput(newTestWriter(t))
+	opts = append([]option{withLogBase(logger.WithFields(nil))}, opts...)
+	orc, err := newOracle(cfg, opts...)
+	require.NoError(t, err)
+	orc.state = oracleStateTesting
+	t.Cleanup(func() {
+		require.NoError(t, orc.close())
+	})
+	return orc
+}
+
+func TestNewTestOracle(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.APIKeyHeader = "X-API-Key"
+	cfg.APIKeys = []APIKey{{Subject: "ci", Key: "secret"}}
+	cfg.BackgroundWorkers = 2
+	orc := newTestOracle(t, cfg)
+	require.NotNil(t, orc.phylum)
+	require.NotNil(t, orc.tracer)
+	require.NotNil(t, orc.workers)
+	require.NotNil(t, orc.levels)
+	require.Len(t, orc.apiKeys, 1)
+}
@@ -0,0 +1,200 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package oraclecli
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/luthersystems/svc/oracle"
+)
+
+// configField is a top-level config field which can be set from a string,
+// e.g. "listen-address".
+type configField struct {
+	// key is the YAML key of the field.
+	key   string
+	index int
+	typ   reflect.Type
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// configFields returns the config fields which can be set from flags and
+// environment variables: strings, bools, numbers, durations and string
+// lists.
+func configFields() []configField {
+	t := reflect.TypeOf(oracle.Config{})
+	var fields []configField
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		key, _, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+		if !f.IsExported() || key == "" || key == "-" || !settable(f.Type) {
+			continue
+		}
+		fields = append(fields, configField{key: key, index: i, typ: f.Type})
+	}
+	return fields
+}
+
+func settable(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.String, reflect.Bool, reflect.Int, reflect.Int64, reflect.Float64:
+		return true
+	case reflect.Slice:
+		return t.Elem().Kind() == reflect.String
+	}
+	return false
+}
+
+// envVar returns the environment variable of the field, e.g.
+// ORACLE_LISTEN_ADDRESS.
+func (f configField) envVar(prefix string) string {
+	return prefix + "_" + strings.ToUpper(strings.ReplaceAll(f.key, "-", "_"))
+}
+
+// set parses s into the field of cfg.  String lists are comma separated.
+func (f configField) set(cfg *oracle.Config, s string) error {
+	v := reflect.ValueOf(cfg).Elem().Field(f.index)
+	switch {
+	case f.typ == durationType:
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return fmt.Errorf("%s: %w", f.key, err)
+		}
+		v.SetInt(int64(d))
+	case f.typ.Kind() == reflect.String:
+		v.SetString(s)
+	case f.typ.Kind() == reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return fmt.Errorf("%s: %w", f.key, err)
+		}
+		v.SetBool(b)
+	case f.typ.Kind() == reflect.Int || f.typ.Kind() == reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return fmt.Errorf("%s: %w", f.key, err)
+		}
+		v.SetInt(n)
+	case f.typ.Kind() == reflect.Float64:
+		n, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return fmt.Errorf("%s: %w", f.key, err)
+		}
+		v.SetFloat(n)
+	case f.typ.Kind() == reflect.Slice:
+		var vals []string
+		for _, val := range strings.Split(s, ",") {
+			if val = strings.TrimSpace(val); val != "" {
+				vals = append(vals, val)
+			}
+		}
+		v.Set(reflect.ValueOf(vals))
+	}
+	return nil
+}
+
+// flagValue records the value of a config flag, which is applied after the
+// config file and environment.
+type flagValue struct {
+	isBool bool
+	value  *string
+}
+
+// String implements flag.Value.
+func (v flagValue) String() string {
+	if v.value == nil {
+		return ""
+	}
+	return *v.value
+}
+
+// Set implements flag.Value.
+func (v flagValue) Set(s string) error {
+	*v.value = s
+	return nil
+}
+
+// IsBoolFlag allows bool fields to be set with -flag.
+func (v flagValue) IsBoolFlag() bool {
+	return v.isBool
+}
+
+// loadConfig parses the config flags of fs from args and returns the
+// config.  Settings are applied in order of increasing precedence: the
+// default config, the YAML config file, the overlay file of the selected
+// profile, environment variables, then flags.  The source of each setting is
+// recorded in the config.
+func (a *App) loadConfig(fs *flag.FlagSet, args []string) (*oracle.Config, error) {
+	prefix := a.envPrefix()
+	configFile := fs.String("config", os.Getenv(prefix+"_CONFIG"), "YAML config `file` (env "+prefix+"_CONFIG)")
+	profile := fs.String("profile", os.Getenv(prefix+"_PROFILE"), "config `profile`, e.g. prod, overriding the config file with <file>.<profile>.yaml (env "+prefix+"_PROFILE)")
+	fields := configFields()
+	flagValues := make(map[string]*string, len(fields))
+	for _, f := range fields {
+		flagValues[f.key] = new(string)
+		fs.Var(flagValue{isBool: f.typ.Kind() == reflect.Bool, value: flagValues[f.key]}, f.key,
+			fmt.Sprintf("sets %s (env %s)", f.key, f.envVar(prefix)))
+	}
+	if err := parseFlags(fs, args); err != nil {
+		return nil, err
+	}
+
+	cfg := oracle.DefaultConfig()
+	if a.DefaultConfig != nil {
+		cfg = a.DefaultConfig()
+	}
+	if a.Version != "" {
+		cfg.Version = a.Version
+	}
+	sources := oracle.ConfigSources{}
+	if *configFile != "" {
+		files := []string{*configFile}
+		if *profile != "" {
+			files = append(files, oracle.ProfileFile(*configFile, *profile))
+		}
+		var err error
+		sources, err = oracle.LoadConfigFiles(cfg, files...)
+		if err != nil {
+			return nil, err
+		}
+	} else if *profile != "" {
+		return nil, fmt.Errorf("profile %s: missing config file", *profile)
+	}
+	for _, f := range fields {
+		if s, ok := os.LookupEnv(f.envVar(prefix)); ok {
+			if err := f.set(cfg, s); err != nil {
+				return nil, fmt.Errorf("env %s: %w", f.envVar(prefix), err)
+			}
+			sources[f.key] = "env " + f.envVar(prefix)
+		}
+	}
+	var flagErr error
+	fs.Visit(func(fl *flag.Flag) {
+		for _, f := range fields {
+			if f.key == fl.Name && flagErr == nil {
+				flagErr = f.set(cfg, *flagValues[f.key])
+				sources[f.key] = "flag -" + f.key
+			}
+		}
+	})
+	if flagErr != nil {
+		return nil, fmt.Errorf("flag %w", flagErr)
+	}
+	cfg.SetConfigSources(sources)
+	if a.Configure != nil {
+		if err := a.Configure(cfg); err != nil {
+			return nil, fmt.Errorf("configure: %w", err)
+		}
+	}
+	if err := cfg.Valid(); err != nil {
+		return nil, fmt.Errorf("config: %w", err)
+	}
+	return cfg, nil
+}
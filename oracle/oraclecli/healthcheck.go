@@ -0,0 +1,68 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package oraclecli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"time"
+)
+
+const (
+	// healthCheckPath is the health check endpoint of the oracle.
+	// IMPORTANT: this must be kept in sync with the oracle package.
+	healthCheckPath = "/v1/health_check"
+
+	defaultHealthCheckTimeout = 5 * time.Second
+)
+
+// healthcheck checks the health endpoint of a running oracle, e.g. as a
+// container health check.  It fails unless the oracle reports that it and
+// its dependencies are up.
+func (a *App) healthcheck(ctx context.Context, args []string) error {
+	fs := a.flagSet("healthcheck")
+	url := fs.String("url", "", "health check `url` (default derived from the listen address)")
+	timeout := fs.Duration("timeout", defaultHealthCheckTimeout, "request timeout")
+	cfg, err := a.loadConfig(fs, args)
+	if err != nil {
+		return err
+	}
+	if *url == "" {
+		*url = localURL(cfg.ListenAddress) + healthCheckPath
+	}
+	ctx, cancel := context.WithTimeout(ctx, *timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, *url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("read response: %w", err)
+	}
+	fmt.Fprintf(a.stdout(), "%s\n", body)
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unhealthy: status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// localURL returns the URL of a listen address on the local host.
+func localURL(listenAddress string) string {
+	host, port, err := net.SplitHostPort(listenAddress)
+	if err != nil {
+		return "http://" + listenAddress
+	}
+	if ip := net.ParseIP(host); host == "" || ip != nil && ip.IsUnspecified() {
+		host = "localhost"
+	}
+	return "http://" + net.JoinHostPort(host, port)
+}
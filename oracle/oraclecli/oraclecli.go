@@ -0,0 +1,220 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+// Package oraclecli provides the main function of oracle executables.  An
+// App loads the oracle config from defaults, a YAML file and the overlay of
+// the selected profile, environment variables and flags, runs the oracle
+// until it is signalled to stop, and provides version and healthcheck
+// subcommands:
+//
+//	func main() {
+//		app := &oraclecli.App{
+//			Version: version,
+//			Service: func(orc *oracle.Oracle) (oracle.GrpcGatewayConfig, error) {
+//				return newServer(orc), nil
+//			},
+//		}
+//		app.Main()
+//	}
+package oraclecli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+
+	"github.com/luthersystems/svc/oracle"
+)
+
+const (
+	// DefaultEnvPrefix prefixes the environment variables overriding config
+	// fields.
+	DefaultEnvPrefix = "ORACLE"
+
+	exitOK    = 0
+	exitError = 1
+	exitUsage = 2
+)
+
+// ServiceFunc creates the service of an oracle, which registers its gRPC
+// server and gateway handlers.
+type ServiceFunc func(orc *oracle.Oracle) (oracle.GrpcGatewayConfig, error)
+
+// App is an oracle executable.
+type App struct {
+	// Name is the executable name used in usage messages.  Defaults to the
+	// base name of os.Args[0].
+	Name string
+	// Version is the version reported by the version subcommand, and the
+	// default config Version.  Defaults to the module version of the
+	// executable.
+	Version string
+	// EnvPrefix prefixes the environment variables overriding config
+	// fields, e.g. ORACLE_LISTEN_ADDRESS.  Defaults to DefaultEnvPrefix.
+	EnvPrefix string
+	// DefaultConfig returns the config before the config file, environment
+	// and flags are applied.  Defaults to oracle.DefaultConfig.
+	DefaultConfig func() *oracle.Config
+	// Configure optionally customizes the loaded config, e.g. to set a
+	// swagger handler, before the oracle is created.
+	Configure func(cfg *oracle.Config) error
+	// Service creates the oracle's service.
+	Service ServiceFunc
+	// Stdout and Stderr default to os.Stdout and os.Stderr.
+	Stdout io.Writer
+	Stderr io.Writer
+}
+
+// Main runs the subcommand named by the command line arguments and exits
+// the process.
+func (a *App) Main() {
+	os.Exit(a.Run(context.Background(), os.Args[1:]))
+}
+
+// Run runs the subcommand named by args and returns the exit status.  The
+// run subcommand is used if args do not name a subcommand.
+func (a *App) Run(ctx context.Context, args []string) int {
+	cmd := "run"
+	if len(args) > 0 && len(args[0]) > 0 && args[0][0] != '-' {
+		cmd, args = args[0], args[1:]
+	}
+	var err error
+	switch cmd {
+	case "run":
+		err = a.run(ctx, args)
+	case "version":
+		err = a.version(args)
+	case "healthcheck":
+		err = a.healthcheck(ctx, args)
+	case "help":
+		a.usage()
+		return exitOK
+	default:
+		fmt.Fprintf(a.stderr(), "%s: unknown command %q\n", a.name(), cmd)
+		a.usage()
+		return exitUsage
+	}
+	switch {
+	case err == nil:
+		return exitOK
+	case errors.Is(err, flag.ErrHelp):
+		return exitOK
+	case errors.Is(err, errUsage):
+		return exitUsage
+	default:
+		fmt.Fprintf(a.stderr(), "%s %s: %v\n", a.name(), cmd, err)
+		return exitError
+	}
+}
+
+// errUsage is returned for invalid command lines, which were already
+// reported by the flag set.
+var errUsage = errors.New("usage")
+
+// run runs the oracle until ctx is done or the process is signalled.
+func (a *App) run(ctx context.Context, args []string) error {
+	fs := a.flagSet("run")
+	cfg, err := a.loadConfig(fs, args)
+	if err != nil {
+		return err
+	}
+	if a.Service == nil {
+		return fmt.Errorf("missing service")
+	}
+	orc, err := oracle.NewOracle(cfg)
+	if err != nil {
+		return err
+	}
+	svc, err := a.Service(orc)
+	if err != nil {
+		return fmt.Errorf("service: %w", err)
+	}
+	return orc.Run(ctx, svc)
+}
+
+// version prints the executable version.
+func (a *App) version(args []string) error {
+	fs := a.flagSet("version")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	fmt.Fprintf(a.stdout(), "%s %s\n", a.name(), a.appVersion())
+	return nil
+}
+
+func (a *App) usage() {
+	fmt.Fprintf(a.stderr(), `Usage: %[1]s [command] [flags]
+
+Commands:
+  run          run the oracle (default)
+  version      print the version
+  healthcheck  check the health of a running oracle
+  help         print this message
+
+Run "%[1]s <command> -h" for the flags of a command.
+`, a.name())
+}
+
+// flagSet returns a flag set for a subcommand.
+func (a *App) flagSet(cmd string) *flag.FlagSet {
+	fs := flag.NewFlagSet(a.name()+" "+cmd, flag.ContinueOnError)
+	fs.SetOutput(a.stderr())
+	return fs
+}
+
+// parseFlags parses args, returning errUsage for invalid flags.
+func parseFlags(fs *flag.FlagSet, args []string) error {
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return err
+		}
+		return errUsage
+	}
+	if fs.NArg() > 0 {
+		fmt.Fprintf(fs.Output(), "unexpected arguments: %v\n", fs.Args())
+		return errUsage
+	}
+	return nil
+}
+
+func (a *App) name() string {
+	if a.Name != "" {
+		return a.Name
+	}
+	return filepath.Base(os.Args[0])
+}
+
+func (a *App) appVersion() string {
+	if a.Version != "" {
+		return a.Version
+	}
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" {
+		return info.Main.Version
+	}
+	return "(devel)"
+}
+
+func (a *App) envPrefix() string {
+	if a.EnvPrefix != "" {
+		return a.EnvPrefix
+	}
+	return DefaultEnvPrefix
+}
+
+func (a *App) stdout() io.Writer {
+	if a.Stdout != nil {
+		return a.Stdout
+	}
+	return os.Stdout
+}
+
+func (a *App) stderr() io.Writer {
+	if a.Stderr != nil {
+		return a.Stderr
+	}
+	return os.Stderr
+}
@@ -0,0 +1,159 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package oraclecli
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/luthersystems/svc/oracle"
+	"github.com/stretchr/testify/require"
+)
+
+func testApp() (*App, *bytes.Buffer, *bytes.Buffer) {
+	var stdout, stderr bytes.Buffer
+	return &App{
+		Name:    "test-oracle",
+		Version: "v1.2.3",
+		Stdout:  &stdout,
+		Stderr:  &stderr,
+	}, &stdout, &stderr
+}
+
+func TestRunCommands(t *testing.T) {
+	ctx := context.Background()
+	app, stdout, stderr := testApp()
+	require.Equal(t, exitOK, app.Run(ctx, []string{"version"}))
+	require.Equal(t, "test-oracle v1.2.3\n", stdout.String())
+
+	require.Equal(t, exitUsage, app.Run(ctx, []string{"bogus"}))
+	require.Contains(t, stderr.String(), `unknown command "bogus"`)
+
+	require.Equal(t, exitUsage, app.Run(ctx, []string{"version", "extra"}))
+	require.Equal(t, exitOK, app.Run(ctx, []string{"help"}))
+	require.Equal(t, exitOK, app.Run(ctx, []string{"run", "-h"}))
+
+	stderr.Reset()
+	require.Equal(t, exitError, app.Run(ctx, []string{"-service-name", ""}))
+	require.Contains(t, stderr.String(), "missing service name")
+
+	stderr.Reset()
+	require.Equal(t, exitError, app.Run(ctx, nil))
+	require.Contains(t, stderr.String(), "missing service")
+}
+
+func TestLoadConfig(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "oracle.yaml")
+	require.NoError(t, os.WriteFile(file, []byte(`
+service-name: file-oracle
+listen-address: ":9090"
+phylum-service-name: file-phylum
+startup-max-wait: 10s
+`), 0o600))
+	t.Setenv("TEST_CONFIG", file)
+	t.Setenv("TEST_LISTEN_ADDRESS", ":7070")
+	t.Setenv("TEST_TRUSTED_PROXIES", "10.0.0.0/8, 192.168.0.1")
+
+	app, _, _ := testApp()
+	app.EnvPrefix = "TEST"
+	var configured bool
+	app.Configure = func(cfg *oracle.Config) error {
+		configured = true
+		return nil
+	}
+	fs := app.flagSet("run")
+	cfg, err := app.loadConfig(fs, []string{"-service-name", "flag-oracle", "-cookie-insecure", "-shutdown-timeout", "1m"})
+	require.NoError(t, err)
+	require.True(t, configured)
+	require.Equal(t, "flag-oracle", cfg.ServiceName)
+	require.Equal(t, ":7070", cfg.ListenAddress)
+	require.Equal(t, "file-phylum", cfg.PhylumServiceName)
+	require.Equal(t, 10*time.Second, cfg.StartupMaxWait)
+	require.Equal(t, time.Minute, cfg.ShutdownTimeout)
+	require.Equal(t, []string{"10.0.0.0/8", "192.168.0.1"}, cfg.TrustedProxies)
+	require.True(t, cfg.CookieInsecure)
+	require.Equal(t, "v1.2.3", cfg.Version)
+
+	_, err = app.loadConfig(app.flagSet("run"), []string{"-shutdown-timeout", "soon"})
+	require.ErrorContains(t, err, "shutdown-timeout")
+
+	t.Setenv("TEST_VERBOSE", "maybe")
+	_, err = app.loadConfig(app.flagSet("run"), nil)
+	require.ErrorContains(t, err, "TEST_VERBOSE")
+
+	_, err = app.loadConfig(app.flagSet("run"), []string{"-config", filepath.Join(dir, "missing.yaml")})
+	require.ErrorContains(t, err, "config file")
+
+	_, err = app.loadConfig(app.flagSet("run"), []string{"-config", "", "-profile", "prod"})
+	require.ErrorContains(t, err, "missing config file")
+
+	_, err = app.loadConfig(app.flagSet("run"), []string{"-bogus"})
+	require.ErrorIs(t, err, errUsage)
+	_, err = app.loadConfig(app.flagSet("run"), []string{"-help"})
+	require.ErrorIs(t, err, flag.ErrHelp)
+}
+
+func TestLoadConfigProfile(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "oracle.yaml")
+	require.NoError(t, os.WriteFile(file, []byte(`
+service-name: base-oracle
+listen-address: ":8080"
+phylum-service-name: base-phylum
+`), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "oracle.prod.yaml"), []byte(`
+listen-address: ":9090"
+`), 0o600))
+	t.Setenv("TEST_CONFIG", file)
+	t.Setenv("TEST_PROFILE", "prod")
+	t.Setenv("TEST_PHYLUM_SERVICE_NAME", "env-phylum")
+
+	app, _, _ := testApp()
+	app.EnvPrefix = "TEST"
+	cfg, err := app.loadConfig(app.flagSet("run"), []string{"-service-name", "flag-oracle"})
+	require.NoError(t, err)
+	require.Equal(t, ":9090", cfg.ListenAddress)
+	require.Equal(t, "env-phylum", cfg.PhylumServiceName)
+	require.Equal(t, "flag-oracle", cfg.ServiceName)
+	require.Equal(t, oracle.ConfigSources{
+		"service-name":        "flag -service-name",
+		"listen-address":      filepath.Join(dir, "oracle.prod.yaml"),
+		"phylum-service-name": "env TEST_PHYLUM_SERVICE_NAME",
+	}, cfg.Sources())
+
+	_, err = app.loadConfig(app.flagSet("run"), []string{"-profile", "staging"})
+	require.ErrorContains(t, err, "oracle.staging.yaml")
+}
+
+func TestHealthcheck(t *testing.T) {
+	healthy := true
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, healthCheckPath, r.URL.Path)
+		if !healthy {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		_, _ = w.Write([]byte(`{"reports":[]}`))
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	app, stdout, stderr := testApp()
+	require.Equal(t, exitOK, app.Run(ctx, []string{"healthcheck", "-url", srv.URL + healthCheckPath}))
+	require.Contains(t, stdout.String(), "reports")
+
+	healthy = false
+	require.Equal(t, exitError, app.Run(ctx, []string{"healthcheck", "-url", srv.URL + healthCheckPath}))
+	require.Contains(t, stderr.String(), "status 503")
+
+	require.Equal(t, "http://localhost:8080", localURL(":8080"))
+	require.Equal(t, "http://localhost:8080", localURL("0.0.0.0:8080"))
+	require.Equal(t, "http://127.0.0.1:8080", localURL("127.0.0.1:8080"))
+}
@@ -0,0 +1,124 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package oracleclient
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	grpccodes "google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+var _ grpc.ClientConnInterface = (*Conn)(nil)
+
+// Conn calls an oracle's gRPC server.  It implements
+// grpc.ClientConnInterface, so generated clients are created from it, e.g.
+//
+//	client := pb.NewAccountServiceClient(oracleclient.NewConn(cc))
+//
+// Unary calls are retried while the server is unavailable, i.e. the call
+// fails with codes.Unavailable before reaching the oracle's handlers.
+type Conn struct {
+	cc     grpc.ClientConnInterface
+	cfg    *config
+	tracer trace.Tracer
+}
+
+// NewConn returns a Conn making calls on cc.
+func NewConn(cc grpc.ClientConnInterface, opts ...Option) *Conn {
+	cfg := newConfig(opts)
+	return &Conn{
+		cc:     cc,
+		cfg:    cfg,
+		tracer: cfg.tracer(),
+	}
+}
+
+// Invoke implements grpc.ClientConnInterface.
+func (c *Conn) Invoke(ctx context.Context, method string, args interface{}, reply interface{}, opts ...grpc.CallOption) error {
+	ctx, span := c.tracer.Start(ctx, method, trace.WithSpanKind(trace.SpanKindClient))
+	defer span.End()
+	ctx, err := c.outgoingContext(ctx)
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+	err = c.cfg.retry(ctx, func() (bool, error) {
+		err := c.cc.Invoke(ctx, method, args, reply, opts...)
+		return unavailable(err), err
+	})
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return Error(err)
+	}
+	return nil
+}
+
+// NewStream implements grpc.ClientConnInterface.  Streams are
+// authenticated and traced, but neither retried nor converted.
+func (c *Conn) NewStream(ctx context.Context, desc *grpc.StreamDesc, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	ctx, err := c.outgoingContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return c.cc.NewStream(ctx, desc, method, opts...)
+}
+
+// outgoingContext adds the auth token and trace context to the outgoing
+// metadata of ctx.
+func (c *Conn) outgoingContext(ctx context.Context) (context.Context, error) {
+	md, _ := metadata.FromOutgoingContext(ctx)
+	md = md.Copy()
+	token, err := c.cfg.token(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("oracleclient: auth token: %w", err)
+	}
+	if token != "" {
+		if c.cfg.authCookie != "" {
+			md.Append("cookie", fmt.Sprintf("%s=%s", c.cfg.authCookie, token))
+		} else {
+			md.Set("authorization", "Bearer "+token)
+		}
+	}
+	otel.GetTextMapPropagator().Inject(ctx, metadataCarrier(md))
+	return metadata.NewOutgoingContext(ctx, md), nil
+}
+
+// unavailable returns true if a call failed because the server was
+// unavailable, rather than with a SERVICE_NOT_AVAILABLE exception.
+func unavailable(err error) bool {
+	stat, ok := status.FromError(err)
+	return ok && stat.Code() == grpccodes.Unavailable && len(stat.Details()) == 0
+}
+
+// metadataCarrier adapts metadata for trace context propagation.
+type metadataCarrier metadata.MD
+
+// Get implements propagation.TextMapCarrier.
+func (c metadataCarrier) Get(key string) string {
+	vals := metadata.MD(c).Get(key)
+	if len(vals) == 0 {
+		return ""
+	}
+	return vals[0]
+}
+
+// Set implements propagation.TextMapCarrier.
+func (c metadataCarrier) Set(key string, value string) {
+	metadata.MD(c).Set(key, value)
+}
+
+// Keys implements propagation.TextMapCarrier.
+func (c metadataCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for k := range c {
+		keys = append(keys, k)
+	}
+	return keys
+}
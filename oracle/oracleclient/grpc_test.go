@@ -0,0 +1,101 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package oracleclient
+
+import (
+	"context"
+	"testing"
+
+	common "buf.build/gen/go/luthersystems/protos/protocolbuffers/go/common/v1"
+	"github.com/luthersystems/svc/svcerr"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// fakeConn returns the queued errors from Invoke, recording the outgoing
+// metadata.
+type fakeConn struct {
+	errs  []error
+	calls int
+	md    metadata.MD
+}
+
+func (c *fakeConn) Invoke(ctx context.Context, method string, args interface{}, reply interface{}, opts ...grpc.CallOption) error {
+	c.md, _ = metadata.FromOutgoingContext(ctx)
+	c.calls++
+	if len(c.errs) == 0 {
+		return nil
+	}
+	err := c.errs[0]
+	c.errs = c.errs[1:]
+	return err
+}
+
+func (c *fakeConn) NewStream(ctx context.Context, desc *grpc.StreamDesc, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	c.md, _ = metadata.FromOutgoingContext(ctx)
+	return nil, nil
+}
+
+func TestConnException(t *testing.T) {
+	except := svcerr.BusinessException(context.Background(), "insufficient funds")
+	except.Id = "downstream-id"
+	stat, err := status.New(codes.InvalidArgument, "insufficient funds").WithDetails(except)
+	require.NoError(t, err)
+	fake := &fakeConn{errs: []error{stat.Err()}}
+	conn := NewConn(fake, WithTokenSource(TokenFunc(func(ctx context.Context) (string, error) {
+		return "token", nil
+	})))
+	err = conn.Invoke(context.Background(), "/test.Service/Method", nil, nil)
+	var eb *svcerr.BusinessError
+	require.ErrorAs(t, err, &eb)
+	require.Equal(t, "downstream-id", eb.GetId())
+	require.Equal(t, 1, fake.calls)
+	require.Equal(t, []string{"Bearer token"}, fake.md.Get("authorization"))
+
+	// business exceptions may carry the response as payload.
+	stat, err = status.New(codes.InvalidArgument, "insufficient funds").WithDetails(&common.ExceptionResponse{Exception: except})
+	require.NoError(t, err)
+	require.ErrorAs(t, Error(stat.Err()), &eb)
+
+	plain := status.Error(codes.NotFound, "not found")
+	require.Equal(t, plain, Error(plain))
+}
+
+func TestConnRetry(t *testing.T) {
+	ctx := context.Background()
+	unavailable := status.Error(codes.Unavailable, "connection refused")
+	fake := &fakeConn{errs: []error{unavailable, unavailable}}
+	conn := NewConn(fake, WithRetryBackoff(0), WithAuthCookie("authorization"), WithTokenSource(TokenFunc(func(ctx context.Context) (string, error) {
+		return "token", nil
+	})))
+	require.NoError(t, conn.Invoke(ctx, "/test.Service/Method", nil, nil))
+	require.Equal(t, 3, fake.calls)
+	require.Equal(t, []string{"authorization=token"}, fake.md.Get("cookie"))
+	require.Empty(t, fake.md.Get("authorization"))
+
+	fake = &fakeConn{errs: []error{unavailable, unavailable, unavailable}}
+	conn = NewConn(fake, WithRetryBackoff(0))
+	require.Equal(t, unavailable, conn.Invoke(ctx, "/test.Service/Method", nil, nil))
+	require.Equal(t, 3, fake.calls)
+
+	// SERVICE_NOT_AVAILABLE exceptions were returned by the oracle, and are
+	// not retried.
+	stat, err := status.New(codes.Unavailable, "kyc unavailable").WithDetails(svcerr.ServiceException(ctx, "kyc unavailable"))
+	require.NoError(t, err)
+	fake = &fakeConn{errs: []error{stat.Err()}}
+	conn = NewConn(fake, WithRetryBackoff(0))
+	var ev *svcerr.ServiceError
+	require.ErrorAs(t, conn.Invoke(ctx, "/test.Service/Method", nil, nil), &ev)
+	require.Equal(t, 1, fake.calls)
+
+	// outgoing metadata is preserved.
+	fake = &fakeConn{}
+	conn = NewConn(fake)
+	ctx = metadata.AppendToOutgoingContext(ctx, "x-request-id", "req-1")
+	_, err = conn.NewStream(ctx, &grpc.StreamDesc{}, "/test.Service/Stream")
+	require.NoError(t, err)
+	require.Equal(t, []string{"req-1"}, fake.md.Get("x-request-id"))
+}
@@ -0,0 +1,185 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package oracleclient
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/luthersystems/svc/svcerr"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// maxErrorBody is the size of response bodies included in errors which do
+// not carry an exception.
+const maxErrorBody = 512
+
+// Client calls an oracle's REST/JSON gateway.  Messages are encoded as JSON
+// using proto field names, like the gateway.  Idempotent requests are
+// retried on connection errors and 502, 503, and 504 responses without an
+// exception.
+type Client struct {
+	base       *url.URL
+	httpClient *http.Client
+	cfg        *config
+}
+
+// WithHTTPClient sets the client sending requests, e.g. one created by
+// Oracle.HTTPClient.  Its transport is wrapped to trace requests.  Defaults
+// to http.DefaultClient.
+func WithHTTPClient(c *http.Client) Option {
+	return func(cfg *config) {
+		cfg.httpClient = c
+	}
+}
+
+// NewClient returns a Client calling the gateway at baseURL, e.g.
+// "http://accounts-oracle:8080".
+func NewClient(baseURL string, opts ...Option) (*Client, error) {
+	base, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("oracleclient: base url: %w", err)
+	}
+	if base.Scheme != "http" && base.Scheme != "https" {
+		return nil, fmt.Errorf("oracleclient: base url: unsupported scheme %q", base.Scheme)
+	}
+	cfg := newConfig(opts)
+	httpClient := http.DefaultClient
+	if cfg.httpClient != nil {
+		httpClient = cfg.httpClient
+	}
+	transport := httpClient.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	traced := *httpClient
+	traced.Transport = otelhttp.NewTransport(transport, otelhttp.WithTracerProvider(cfg.tracerProvider))
+	return &Client{
+		base:       base,
+		httpClient: &traced,
+		cfg:        cfg,
+	}, nil
+}
+
+// Do calls the gateway endpoint at path, e.g. "/v1/accounts/123", relative to
+// the base URL.  A non-nil req is sent as the request body, and a
+// successful response body is decoded into resp, if non-nil.  Exception
+// responses are returned as the raw Luther error of their exception, e.g.
+// *svcerr.BusinessError.
+func (c *Client) Do(ctx context.Context, method string, path string, req proto.Message, resp proto.Message) error {
+	rel, err := url.Parse(path)
+	if err != nil {
+		return fmt.Errorf("oracleclient: path: %w", err)
+	}
+	u := *c.base
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/" + strings.TrimPrefix(rel.Path, "/")
+	u.RawPath = ""
+	u.RawQuery = rel.RawQuery
+	var body []byte
+	if req != nil {
+		body, err = protojson.MarshalOptions{UseProtoNames: true}.Marshal(req)
+		if err != nil {
+			return fmt.Errorf("oracleclient: marshal request: %w", err)
+		}
+	}
+	token, err := c.cfg.token(ctx)
+	if err != nil {
+		return fmt.Errorf("oracleclient: auth token: %w", err)
+	}
+	var respBody []byte
+	var statusCode int
+	err = c.cfg.retry(ctx, func() (bool, error) {
+		r, err := c.newRequest(ctx, method, &u, body, token)
+		if err != nil {
+			return false, err
+		}
+		httpResp, err := c.httpClient.Do(r)
+		if err != nil {
+			return idempotent(method), fmt.Errorf("oracleclient: %s %s: %w", method, path, err)
+		}
+		defer httpResp.Body.Close()
+		statusCode = httpResp.StatusCode
+		respBody, err = io.ReadAll(httpResp.Body)
+		if err != nil {
+			return idempotent(method), fmt.Errorf("oracleclient: %s %s: read response: %w", method, path, err)
+		}
+		if statusCode < 200 || statusCode > 299 {
+			err := responseError(method, path, statusCode, respBody)
+			return idempotent(method) && transientStatus(statusCode) && !isException(err), err
+		}
+		return false, nil
+	})
+	if err != nil {
+		return err
+	}
+	if resp == nil || len(respBody) == 0 {
+		return nil
+	}
+	if err := (protojson.UnmarshalOptions{DiscardUnknown: true}).Unmarshal(respBody, resp); err != nil {
+		return fmt.Errorf("oracleclient: %s %s: unmarshal response: %w", method, path, err)
+	}
+	return nil
+}
+
+// newRequest returns an authenticated request.
+func (c *Client) newRequest(ctx context.Context, method string, u *url.URL, body []byte, token string) (*http.Request, error) {
+	var r io.Reader
+	if body != nil {
+		r = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), r)
+	if err != nil {
+		return nil, fmt.Errorf("oracleclient: new request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		if c.cfg.authCookie != "" {
+			req.AddCookie(&http.Cookie{Name: c.cfg.authCookie, Value: token})
+		} else {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+	}
+	return req, nil
+}
+
+// responseError returns the error of a failed call, converting exception
+// responses into raw Luther errors.
+func responseError(method string, path string, code int, body []byte) error {
+	if except, err := svcerr.ParseExceptionResponse(body); err == nil {
+		return svcerr.ExceptionError(except)
+	}
+	if len(body) > maxErrorBody {
+		body = body[:maxErrorBody]
+	}
+	return fmt.Errorf("oracleclient: %s %s: status %d: %s", method, path, code, bytes.TrimSpace(body))
+}
+
+// idempotent returns true if requests with the method may safely be sent
+// more than once.
+func idempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// transientStatus returns true if a response status indicates a transient
+// failure.
+func transientStatus(code int) bool {
+	switch code {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,80 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package oracleclient
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	common "buf.build/gen/go/luthersystems/protos/protocolbuffers/go/common/v1"
+	"github.com/luthersystems/svc/svcerr"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+func TestClient(t *testing.T) {
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		switch r.URL.Path {
+		case "/api/v1/echo":
+			require.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+			require.Equal(t, "1", r.URL.Query().Get("page"))
+			b, err := io.ReadAll(r.Body)
+			require.NoError(t, err)
+			_, _ = w.Write(b)
+		case "/api/v1/business":
+			w.WriteHeader(http.StatusBadRequest)
+			b, _ := protojson.Marshal(&common.ExceptionResponse{Exception: &common.Exception{
+				Id:          "downstream-id",
+				Type:        common.Exception_BUSINESS,
+				Description: "insufficient funds",
+			}})
+			_, _ = w.Write(b)
+		case "/api/v1/unavailable":
+			w.WriteHeader(http.StatusServiceUnavailable)
+			_, _ = w.Write([]byte("upstream connect error"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	client, err := NewClient(srv.URL+"/api", WithRetryBackoff(0), WithTokenSource(TokenFunc(func(ctx context.Context) (string, error) {
+		return "token", nil
+	})))
+	require.NoError(t, err)
+
+	req := &common.Exception{Id: "id", Description: "echo"}
+	resp := &common.Exception{}
+	require.NoError(t, client.Do(ctx, http.MethodPost, "/v1/echo?page=1", req, resp))
+	require.Equal(t, "echo", resp.GetDescription())
+
+	err = client.Do(ctx, http.MethodPost, "/v1/business", req, nil)
+	var eb *svcerr.BusinessError
+	require.ErrorAs(t, err, &eb)
+	require.Equal(t, "downstream-id", eb.GetId())
+
+	calls = 0
+	err = client.Do(ctx, http.MethodPost, "/v1/unavailable", req, nil)
+	require.ErrorContains(t, err, "status 503: upstream connect error")
+	require.Equal(t, 1, calls)
+	calls = 0
+	err = client.Do(ctx, http.MethodGet, "/v1/unavailable", nil, nil)
+	require.Error(t, err)
+	require.Equal(t, 3, calls)
+
+	client, err = NewClient(srv.URL+"/api/", WithTokenSource(TokenFunc(func(ctx context.Context) (string, error) {
+		return "", errors.New("no token")
+	})))
+	require.NoError(t, err)
+	require.ErrorContains(t, client.Do(ctx, http.MethodGet, "v1/echo", nil, nil), "no token")
+
+	_, err = NewClient("accounts-oracle:8080")
+	require.Error(t, err)
+}
@@ -0,0 +1,184 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+// Package oracleclient calls the APIs of other oracles built on this module,
+// either over gRPC or through their REST/JSON gateway.  Clients inject
+// authentication, retry transient failures, propagate the trace context,
+// and convert exception responses back into svcerr errors, so callers need
+// not parse exception payloads.
+//
+// Exceptions returned by the called oracle are returned as the raw Luther
+// error of their type, e.g. *svcerr.BusinessError, carrying the original
+// exception.  Use svcerr.WrapException to present a different exception to
+// clients while logging the downstream exception as its cause.
+package oracleclient
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	common "buf.build/gen/go/luthersystems/protos/protocolbuffers/go/common/v1"
+	"github.com/luthersystems/svc/svcerr"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	defaultRetries = 2
+	defaultBackoff = 200 * time.Millisecond
+
+	tracerName = "github.com/luthersystems/svc/oracle/oracleclient"
+)
+
+// TokenSource provides auth tokens for calls, e.g. *oracle.TokenSource.
+type TokenSource interface {
+	Token(ctx context.Context) (string, error)
+}
+
+// TokenFunc is a function implementing TokenSource.  It may be used to
+// forward the token of the request being served.
+type TokenFunc func(ctx context.Context) (string, error)
+
+// Token implements TokenSource.
+func (f TokenFunc) Token(ctx context.Context) (string, error) {
+	return f(ctx)
+}
+
+// Option configures a client.
+type Option func(*config)
+
+type config struct {
+	tokens         TokenSource
+	authCookie     string
+	retries        int
+	backoff        time.Duration
+	tracerProvider trace.TracerProvider
+	httpClient     *http.Client
+}
+
+func newConfig(opts []Option) *config {
+	cfg := &config{
+		retries: defaultRetries,
+		backoff: defaultBackoff,
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	if cfg.tracerProvider == nil {
+		cfg.tracerProvider = otel.GetTracerProvider()
+	}
+	return cfg
+}
+
+// WithTokenSource authenticates calls with tokens from the source.  Tokens
+// are sent as bearer tokens unless WithAuthCookie is used.
+func WithTokenSource(s TokenSource) Option {
+	return func(cfg *config) {
+		cfg.tokens = s
+	}
+}
+
+// WithAuthCookie sends tokens in the named cookie instead of the
+// Authorization header, e.g. "authorization" for oracles using the default
+// auth cookie.
+func WithAuthCookie(name string) Option {
+	return func(cfg *config) {
+		cfg.authCookie = name
+	}
+}
+
+// WithRetries sets how many times calls are retried after a transient
+// failure.  Defaults to 2.
+func WithRetries(n int) Option {
+	return func(cfg *config) {
+		cfg.retries = n
+	}
+}
+
+// WithRetryBackoff sets the delay before the first retry, which doubles with
+// each subsequent retry.  Defaults to 200ms.
+func WithRetryBackoff(d time.Duration) Option {
+	return func(cfg *config) {
+		cfg.backoff = d
+	}
+}
+
+// WithTracerProvider sets the provider of the tracer creating client spans.
+// Defaults to the global provider.
+func WithTracerProvider(tp trace.TracerProvider) Option {
+	return func(cfg *config) {
+		cfg.tracerProvider = tp
+	}
+}
+
+// tracer returns the tracer creating client spans.
+func (cfg *config) tracer() trace.Tracer {
+	return cfg.tracerProvider.Tracer(tracerName)
+}
+
+// token returns the auth token of a call, or "" if calls are not
+// authenticated.
+func (cfg *config) token(ctx context.Context) (string, error) {
+	if cfg.tokens == nil {
+		return "", nil
+	}
+	return cfg.tokens.Token(ctx)
+}
+
+// retry calls fn until it succeeds, returns an error which is not
+// retryable, or the retries are exhausted.
+func (cfg *config) retry(ctx context.Context, fn func() (retryable bool, err error)) error {
+	backoff := cfg.backoff
+	for attempt := 0; ; attempt++ {
+		retryable, err := fn()
+		if err == nil || !retryable || attempt >= cfg.retries {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// exceptionHolder is implemented by exceptions and by responses carrying an
+// exception, such as the business exception payload of a failed call.
+type exceptionHolder interface {
+	GetException() *common.Exception
+}
+
+// Error converts an error returned by an oracle's gRPC server into the raw
+// Luther error of its exception, e.g. *svcerr.BusinessError.  Errors which
+// carry no exception are returned unchanged.  Clients in this package
+// convert errors already, Error is useful for calls made on other
+// connections.
+func Error(err error) error {
+	if err == nil {
+		return nil
+	}
+	stat, ok := status.FromError(err)
+	if !ok {
+		return err
+	}
+	for _, detail := range stat.Details() {
+		switch d := detail.(type) {
+		case *common.Exception:
+			return svcerr.ExceptionError(d)
+		case exceptionHolder:
+			if d.GetException() != nil {
+				return svcerr.ExceptionError(d.GetException())
+			}
+		}
+	}
+	return err
+}
+
+// isException returns true if err was converted from an exception.
+func isException(err error) bool {
+	var h exceptionHolder
+	return errors.As(err, &h)
+}
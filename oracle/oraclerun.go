@@ -2,29 +2,17 @@ package oracle
 
 import (
 	"context"
-	"crypto/rand"
 	"fmt"
-	"math"
-	"math/big"
 	"net"
 	"net/http"
 	"strings"
 	"time"
 
-	grpcmiddleware "github.com/grpc-ecosystem/go-grpc-middleware"
-	grpc_prometheus "github.com/grpc-ecosystem/go-grpc-prometheus"
 	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
-	"github.com/luthersystems/svc/grpclogging"
-	"github.com/luthersystems/svc/logmon"
 	"github.com/luthersystems/svc/midware"
-	"github.com/luthersystems/svc/svcerr"
 	"github.com/prometheus/client_golang/prometheus"
-	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/sirupsen/logrus"
-	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials/insecure"
-	"google.golang.org/protobuf/encoding/protojson"
 )
 
 var versionTotal = prometheus.NewCounterVec(
@@ -35,30 +23,24 @@ var versionTotal = prometheus.NewCounterVec(
 	[]string{"oracle_name", "oracle_version", "phylum_name", "phylum_version"},
 )
 
-func init() {
-	// Provider per endpoint histograms (at expense of memory/performance).
-	grpc_prometheus.EnableClientHandlingTimeHistogram(
-		grpc_prometheus.WithHistogramBuckets(prometheus.ExponentialBuckets(0.05, 1.25, 25)),
-	)
-
-	// Expose log severity counts to prometheus.
-	logrus.AddHook(logmon.NewPrometheusHook())
-
-	prometheus.MustRegister(versionTotal)
-}
-
 // gatewayForwardedHeaders are HTTP headers which the grpc-gateway will encode
 // as grpc request metadata and forward to the oracle grpc server.  Forwarded
-// headers may be used for authentication flows, request tracing, etc.
+// headers may be used for authentication flows, request tracing, etc.  The
+// headers are filtered by the HeaderForwarding policy.
 func (orc *Oracle) gatewayForwardedHeaders() []string {
-	return []string{
+	var headers []string
+	for _, h := range append([]string{
 		"Cookie",
 		"X-Forwarded-For",
 		"User-Agent",
 		"X-Forwarded-User-Agent",
 		"Referer",
-		orc.cfg.RequestIDHeader,
+	}, orc.cfg.forwardedRequestHeaders()...) {
+		if orc.cfg.HeaderForwarding.allowed(h) {
+			headers = append(headers, h)
+		}
 	}
+	return append(headers, orc.cfg.requiredForwardedHeaders()...)
 }
 
 func (orc *Oracle) incomingHeaderMatcher(h string) (string, bool) {
@@ -66,7 +48,7 @@ func (orc *Oracle) incomingHeaderMatcher(h string) (string, bool) {
 
 	for i := range headers {
 		if strings.EqualFold(h, headers[i]) {
-			return h, true
+			return orc.cfg.HeaderForwarding.metadataKey(h), true
 		}
 	}
 	return "", false
@@ -74,42 +56,94 @@ func (orc *Oracle) incomingHeaderMatcher(h string) (string, bool) {
 
 func (orc *Oracle) grpcGatewayMux() *runtime.ServeMux {
 	opts := []runtime.ServeMuxOption{
-		runtime.WithErrorHandler(svcerr.ErrIntercept(orc.log)),
+		runtime.WithErrorHandler(orc.errorHandler()),
 		runtime.WithIncomingHeaderMatcher(orc.incomingHeaderMatcher),
-		runtime.WithMarshalerOption(runtime.MIMEWildcard, &runtime.JSONPb{
-			MarshalOptions: protojson.MarshalOptions{
-				UseProtoNames: true,
-			},
-			UnmarshalOptions: protojson.UnmarshalOptions{
-				DiscardUnknown: false,
-			},
-		}),
+		runtime.WithOutgoingHeaderMatcher(orc.outgoingHeaderMatcher),
+	}
+	opts = append(opts, orc.marshalerOptions()...)
+	if len(orc.cfg.forwardResponseHooks) > 0 {
+		opts = append(opts, runtime.WithForwardResponseOption(orc.forwardResponse))
 	}
 
 	return runtime.NewServeMux(opts...)
 }
 
-func (orc *Oracle) grpcGateway(swaggerHandler http.Handler) (*runtime.ServeMux, http.Handler) {
+// grpcGateway returns the gateway mux and the HTTP handler serving it, or
+// an error if the middleware chain is in a known-bad order.
+func (orc *Oracle) grpcGateway(swaggerHandler http.Handler, grpcConn grpc.ClientConnInterface) (*runtime.ServeMux, http.Handler, error) {
 	jsonapi := orc.grpcGatewayMux()
+	if orc.cfg.operationStore != nil {
+		if err := jsonapi.HandlePath(http.MethodGet, operationsPath+"/{id}", orc.operationHandler); err != nil {
+			return nil, nil, fmt.Errorf("operations: %w", err)
+		}
+	}
 	pathOverides := midware.PathOverrides{
 		healthCheckPath: orc.healthCheckHandler(),
 	}
 	if swaggerHandler != nil {
 		pathOverides[swaggerPath] = swaggerHandler
 	}
+	if orc.cfg.ServeOpenAPI {
+		pathOverides[openAPIPath] = orc.openAPIHandler()
+	}
+	if orc.cfg.LogoutPath != "" {
+		pathOverides[orc.cfg.LogoutPath] = orc.logoutHandler()
+	}
+	for path, e := range orc.cfg.ssePaths {
+		pathOverides[path] = orc.sseHandler(path, e)
+	}
+	for path, w := range orc.cfg.inboundWebhooks {
+		pathOverides[path] = orc.inboundWebhookHandler(w)
+	}
+	for path, b := range orc.cfg.bulkImports {
+		pathOverides[path] = orc.bulkImportHandler(path, b)
+	}
+	for path, e := range orc.cfg.csvExports {
+		pathOverides[path] = orc.csvExportHandler(path, e)
+	}
 	middleware := midware.Chain{
 		// The trace header middleware appears early in the chain
 		// because of how important it is that they happen for essentially all
 		// requests.
 		midware.TraceHeaders(orc.cfg.RequestIDHeader, true),
+		midware.Func(orc.requestDurationMiddleware),
+		orc.trustedProxies(),
+		midware.Func(orc.cutoverMiddleware),
 		orc.addServerHeader(),
+		// Paths are normalized before any middleware matches them.
+		orc.normalizePath(),
+		midware.Func(orc.localeMiddleware),
+		// Notices precede maintenance so 503 responses also carry them.
+		&orc.notice,
+		midware.Func(orc.memoryGuardMiddleware),
+		midware.Func(orc.maintenanceMiddleware),
+		// The cache middleware wraps the conditional middleware so that
+		// 304 responses also carry caching headers.
+		midware.Func(orc.cacheMiddleware),
+		midware.Func(orc.conditionalMiddleware),
+	}
+	if orc.cfg.GRPCWeb {
+		middleware = append(middleware, midware.Func(orc.grpcWebMiddleware(grpcConn)))
+	}
+	if len(orc.cfg.DedupePathPrefixes) > 0 {
+		middleware = append(middleware, midware.When(
+			midware.PathPrefix(orc.cfg.DedupePathPrefixes...),
+			&midware.Dedupe{Window: orc.cfg.DedupeWindow}))
+	}
+	middleware = append(middleware,
 		// PathOverrides and other middleware that may serve requests or have
 		// potential failure states should appear below here so they may rely
 		// on the presence of the generic utility middleware above.
 		pathOverides,
-	}
+	)
 
-	return jsonapi, middleware.Wrap(jsonapi)
+	if err := middleware.Validate(); err != nil {
+		return nil, nil, fmt.Errorf("middleware: %w", err)
+	}
+	// Request bodies are only captured for the gateway, not for overridden
+	// paths.
+	gateway := orc.rejectedPayloadMiddleware(jsonapi)
+	return jsonapi, middleware.Wrap(orc.limitForwardedHeaders(gateway)), nil
 }
 
 // GrpcGatewayConfig configures the grpc gateway used by the oracle.
@@ -155,25 +189,17 @@ func (orc *Oracle) StartGateway(ctx context.Context, grpcConfig GrpcGatewayConfi
 		"phylum_name":      orc.cfg.PhylumServiceName,
 		"listen_address":   orc.cfg.ListenAddress,
 	}).Infof("starting oracle")
-
-	nBig, err := rand.Int(rand.Reader, big.NewInt(math.MaxInt32))
-	if err != nil {
-		panic(err)
-	}
+	orc.logConfig()
 
 	// Start a grpc server listening on the unix socket at grpcAddr
-	grpcAddr := fmt.Sprintf("/tmp/oracle.grpc.%d.sock", nBig.Int64())
+	grpcAddr := orc.grpcSocketPath()
 
-	grpcServer := grpc.NewServer(
-		grpc.StatsHandler(otelgrpc.NewServerHandler()),
-		grpc.UnaryInterceptor(grpcmiddleware.ChainUnaryServer(
-			grpclogging.LogrusMethodInterceptor(
-				orc.logBase,
-				grpclogging.UpperBoundTimer(time.Millisecond),
-				grpclogging.RealTime()),
-			svcerr.AppErrorUnaryInterceptor(orc.log))))
+	orc.enableGRPCMetrics()
+	grpcServer := grpc.NewServer(orc.grpcServerOptions()...)
 
 	grpcConfig.RegisterServiceServer(grpcServer)
+	orc.registerGRPCServerMetrics(grpcServer)
+	orc.setServices(grpcServer)
 
 	orc.stateMut.Unlock()
 
@@ -192,19 +218,30 @@ func (orc *Oracle) StartGateway(ctx context.Context, grpcConfig GrpcGatewayConfi
 	}()
 
 	// Create a grpc client which connects to grpcAddr
-	grpcConn, err := grpc.NewClient("unix://"+grpcAddr,
-		grpc.WithTransportCredentials(insecure.NewCredentials()),
-		grpc.WithUnaryInterceptor(grpcmiddleware.ChainUnaryClient(
-			grpc_prometheus.UnaryClientInterceptor)))
+	grpcConn, err := grpc.NewClient("unix://"+grpcAddr, orc.grpcDialOptions()...)
 	if err != nil {
 		return fmt.Errorf("grpc dial: %w", err)
 	}
 
-	mux, httpHandler := orc.grpcGateway(orc.swaggerHandler)
+	mux, httpHandler, err := orc.grpcGateway(orc.swaggerHandler, grpcConn)
+	if err != nil {
+		return err
+	}
 	if err := grpcConfig.RegisterServiceClient(ctx, grpcConn, mux); err != nil {
 		return fmt.Errorf("register service client: %w", err)
 	}
 
+	if err := orc.waitForDependencies(ctx); err != nil {
+		return err
+	}
+
+	// The admin server is configured before any server starts listening,
+	// so a configuration error leaves no server running.
+	adminServer, err := orc.adminServer()
+	if err != nil {
+		return err
+	}
+
 	go func() {
 		orc.log(ctx).Infof("init healthcheck")
 		hctx, hcancel := context.WithDeadline(ctx, time.Now().Add(10*time.Second))
@@ -212,32 +249,82 @@ func (orc *Oracle) StartGateway(ctx context.Context, grpcConfig GrpcGatewayConfi
 		orc.phylumHealthCheck(hctx)
 	}()
 
+	if orc.memGuard != nil {
+		go orc.memGuard.run(ctx)
+	}
+	go orc.runSecretRefresh(ctx)
+	tasksDone := orc.runTasks(ctx)
+	reportsDone := orc.runReports(ctx)
+	leaderDone := orc.runLeaderElection(ctx)
+	periodicDone := orc.runPeriodicTasks(ctx)
+
+	server := &http.Server{
+		Addr:              orc.cfg.ListenAddress,
+		Handler:           httpHandler,
+		ReadHeaderTimeout: 3 * time.Second,
+	}
 	go func() {
 		orc.log(ctx).Infof("oracle listen")
-		server := &http.Server{
-			Addr:              orc.cfg.ListenAddress,
-			Handler:           httpHandler,
-			ReadHeaderTimeout: 3 * time.Second,
-		}
 		trySendError(errServe, server.ListenAndServe())
 	}()
 
+	metricsServer := &http.Server{
+		Addr:              metricsAddr,
+		WriteTimeout:      10 * time.Second,
+		ReadHeaderTimeout: 2 * time.Second,
+	}
 	go func() {
 		// metrics server
 		h := http.NewServeMux()
-		h.Handle(metricsPath, promhttp.Handler())
-		s := &http.Server{
-			Addr:              metricsAddr,
-			WriteTimeout:      10 * time.Second,
-			ReadHeaderTimeout: 2 * time.Second,
-			Handler:           h,
+		h.Handle(metricsPath, orc.cfg.metricsHandler())
+		if orc.cfg.ServeConfigz {
+			h.Handle(configzPath, orc.configzHandler())
 		}
+		if orc.cfg.ServeSchemaz {
+			h.Handle(schemazPath, orc.schemazHandler())
+		}
+		metricsServer.Handler = h
 		orc.log(ctx).Infof("prometheus listen")
-		trySendError(errServe, s.ListenAndServe())
+		trySendError(errServe, metricsServer.ListenAndServe())
 	}()
 
+	if adminServer != nil {
+		go func() {
+			orc.log(ctx).Infof("admin listen")
+			if adminServer.TLSConfig != nil {
+				trySendError(errServe, adminServer.ListenAndServeTLS("", ""))
+				return
+			}
+			trySendError(errServe, adminServer.ListenAndServe())
+		}()
+	}
+
 	// Both methods grpcServer.Start and http.ListenAndServe will block
 	// forever.  An error in either the grpc server or the http server will
 	// appear in the errServe channel and halt the process.
-	return <-errServe
+	select {
+	case err := <-errServe:
+		return err
+	case <-ctx.Done():
+	}
+
+	// The context was cancelled, e.g. on SIGTERM.  In-flight requests are
+	// allowed to finish before the servers stop.
+	orc.log(ctx).Infof("oracle shutdown")
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.WithoutCancel(ctx), orc.cfg.shutdownTimeout())
+	defer shutdownCancel()
+	err = server.Shutdown(shutdownCtx)
+	stopGRPCServer(shutdownCtx, grpcServer)
+	<-tasksDone
+	<-reportsDone
+	<-periodicDone
+	<-leaderDone
+	if adminServer != nil {
+		_ = adminServer.Close()
+	}
+	_ = metricsServer.Close()
+	if err != nil {
+		return fmt.Errorf("shutdown: %w", err)
+	}
+	return nil
 }
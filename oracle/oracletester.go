@@ -4,9 +4,14 @@ package oracle
 
 import (
 	"bytes"
+	"context"
 	"fmt"
+	"path/filepath"
 	"testing"
+	"time"
 
+	"github.com/luthersystems/svc/grpclogging"
+	"github.com/luthersystems/svc/svcerr"
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/require"
 )
@@ -49,7 +54,10 @@ func (orc *Oracle) Snapshot(t *testing.T) []byte {
 }
 
 type testCfg struct {
-	snapshot []byte
+	snapshot   []byte
+	clock      func() time.Time
+	seed       *int64
+	socketPath bool
 }
 
 // TestOpt configures a test oracle.
@@ -63,6 +71,57 @@ func WithSnapshot(b []byte) TestOpt {
 	}
 }
 
+// WithFixedClock fixes the time returned by Now, and the timestamps of
+// health reports and of exceptions created with a TestContext.
+func WithFixedClock(now time.Time) TestOpt {
+	return WithClock(func() time.Time { return now })
+}
+
+// WithClock sets the clock of the test oracle, e.g. an emulated clock
+// advanced by the test.
+func WithClock(now func() time.Time) TestOpt {
+	return func(cfg *testCfg) {
+		cfg.clock = now
+	}
+}
+
+// WithDeterministicIDs makes NewID return a reproducible sequence of UUIDs
+// generated from seed.
+func WithDeterministicIDs(seed int64) TestOpt {
+	return func(cfg *testCfg) {
+		cfg.seed = &seed
+	}
+}
+
+// WithFixedSocketPath places the unix socket of the internal gRPC server
+// at a fixed path in a temporary directory of the test, instead of a
+// random path.
+func WithFixedSocketPath() TestOpt {
+	return func(cfg *testCfg) {
+		cfg.socketPath = true
+	}
+}
+
+// WithDeterministic enables all the deterministic test options, so that
+// golden tests and response snapshots are stable: the clock is fixed at
+// now, IDs are generated from seed, and the socket path is fixed.
+func WithDeterministic(now time.Time, seed int64) TestOpt {
+	return func(cfg *testCfg) {
+		WithFixedClock(now)(cfg)
+		WithDeterministicIDs(seed)(cfg)
+		WithFixedSocketPath()(cfg)
+	}
+}
+
+// TestContext returns a context to call the handlers of a test oracle
+// with.  It carries a request ID generated by NewID, which identifies
+// exceptions and logs, and the oracle clock, which timestamps exceptions.
+func (orc *Oracle) TestContext(ctx context.Context) context.Context {
+	ctx = grpclogging.NewContext(ctx)
+	grpclogging.AddLogrusField(ctx, "req_id", orc.NewID())
+	return svcerr.WithClock(ctx, orc.Now)
+}
+
 // NewTestOracle is used to create an oracle for testing.
 func NewTestOracle(t *testing.T, cfg *Config, testOpts ...TestOpt) (*Oracle, func()) {
 	cfg.Verbose = testing.Verbose()
@@ -90,10 +149,17 @@ func NewTestOracle(t *testing.T, cfg *Config, testOpts ...TestOpt) (*Oracle, fun
 	}
 
 	server, err := newOracle(cfg, orcOpts...)
-	server.state = oracleStateTesting
 	if err != nil {
 		t.Fatal(err)
 	}
+	server.state = oracleStateTesting
+	server.clock = testCfg.clock
+	if testCfg.seed != nil {
+		server.ids = newSeededIDs(*testCfg.seed)
+	}
+	if testCfg.socketPath {
+		server.grpcSocket = filepath.Join(t.TempDir(), "oracle.grpc.sock")
+	}
 
 	if cfg.Verbose {
 		logger.SetLevel(logrus.DebugLevel)
@@ -0,0 +1,85 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package oracle
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// Layout of text PDFs, in points on US letter pages.
+const (
+	pdfPageWidth    = 612
+	pdfPageHeight   = 792
+	pdfMargin       = 50
+	pdfFontSize     = 9
+	pdfLeading      = 11
+	pdfLinesPerPage = (pdfPageHeight - 2*pdfMargin) / pdfLeading
+)
+
+// textPDF renders preformatted text as a PDF document in a monospaced
+// font, paginating as needed.  Characters outside Latin-1 are replaced.
+func textPDF(text string) []byte {
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	var pages [][]string
+	for len(lines) > 0 {
+		n := min(len(lines), pdfLinesPerPage)
+		pages = append(pages, lines[:n])
+		lines = lines[n:]
+	}
+
+	var buf bytes.Buffer
+	var offsets []int
+	obj := func(body string) {
+		offsets = append(offsets, buf.Len())
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+	buf.WriteString("%PDF-1.4\n")
+	// Objects 1-3 are the catalog, page tree and font, followed by a page
+	// and its content stream for each page.
+	obj("<< /Type /Catalog /Pages 2 0 R >>")
+	kids := make([]string, len(pages))
+	for i := range pages {
+		kids[i] = fmt.Sprintf("%d 0 R", 4+2*i)
+	}
+	obj(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(pages)))
+	obj("<< /Type /Font /Subtype /Type1 /BaseFont /Courier /Encoding /WinAnsiEncoding >>")
+	for i, page := range pages {
+		obj(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %d %d] /Resources << /Font << /F1 3 0 R >> >> /Contents %d 0 R >>",
+			pdfPageWidth, pdfPageHeight, 5+2*i))
+		var content bytes.Buffer
+		fmt.Fprintf(&content, "BT\n/F1 %d Tf\n%d TL\n%d %d Td\n", pdfFontSize, pdfLeading, pdfMargin, pdfPageHeight-pdfMargin-pdfFontSize)
+		for _, line := range page {
+			fmt.Fprintf(&content, "(%s) '\n", pdfString(line))
+		}
+		content.WriteString("ET")
+		obj(fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", content.Len(), content.String()))
+	}
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+	return buf.Bytes()
+}
+
+// pdfString escapes a line for a PDF string literal.
+func pdfString(line string) string {
+	var b strings.Builder
+	for _, r := range line {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r == '\t':
+			b.WriteString("    ")
+		case r < 0x20 || r > 0xff:
+			b.WriteByte('?')
+		default:
+			b.WriteByte(byte(r))
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,147 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package oracle
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/luthersystems/svc/grpclogging"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+)
+
+const defaultPeriodicTaskTimeout = 10 * time.Minute
+
+var (
+	periodicTaskRunsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "periodic_task_runs_total",
+			Help: "How many periodic task runs, partitioned by task and result.",
+		},
+		[]string{"task", "result"},
+	)
+	periodicTaskDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "periodic_task_duration_seconds",
+			Help:    "Duration of periodic task runs, partitioned by task.",
+			Buckets: prometheus.ExponentialBuckets(0.1, 2, 12),
+		},
+		[]string{"task"},
+	)
+)
+
+// PeriodicTask runs on a schedule while the oracle runs.  Failed runs are
+// logged at error level.
+type PeriodicTask struct {
+	// Name identifies the task in logs and metrics.
+	Name string
+	// Schedule is a five field cron expression evaluated in UTC, a
+	// shorthand such as @daily, or "@every <duration>".
+	Schedule string
+	// Run runs the task.
+	Run func(ctx context.Context) error
+	// Singleton runs the task on the elected leader only, rather than on
+	// every replica.  It requires SetLeaderElection.
+	Singleton bool
+	// Timeout bounds a run.  Defaults to 10 minutes.
+	Timeout time.Duration
+}
+
+// AddPeriodicTask schedules a task while the oracle runs.
+func (c *Config) AddPeriodicTask(t PeriodicTask) {
+	if c == nil {
+		return
+	}
+	c.periodicTasks = append(c.periodicTasks, t)
+}
+
+// validPeriodicTasks validates the periodic task configuration.
+func (c *Config) validPeriodicTasks() error {
+	names := make(map[string]bool, len(c.periodicTasks))
+	for _, t := range c.periodicTasks {
+		if t.Name == "" {
+			return fmt.Errorf("periodic task: missing name")
+		}
+		if names[t.Name] {
+			return fmt.Errorf("periodic task %s: duplicate name", t.Name)
+		}
+		names[t.Name] = true
+		if _, err := parseSchedule(t.Schedule); err != nil {
+			return fmt.Errorf("periodic task %s: %w", t.Name, err)
+		}
+		if t.Run == nil {
+			return fmt.Errorf("periodic task %s: missing run", t.Name)
+		}
+		if t.Singleton && c.leaderStore == nil {
+			return fmt.Errorf("periodic task %s: singleton requires leader election", t.Name)
+		}
+	}
+	return nil
+}
+
+// runPeriodicTasks runs the configured periodic tasks on schedule until ctx
+// is done.  The returned channel is closed once running tasks have stopped.
+func (orc *Oracle) runPeriodicTasks(ctx context.Context) <-chan struct{} {
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	for _, t := range orc.cfg.periodicTasks {
+		sched, err := parseSchedule(t.Schedule)
+		if err != nil {
+			// The config was validated.
+			orc.log(ctx).WithError(err).Errorf("periodic task invalid")
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ok := runSchedule(ctx, sched, func(run time.Time) {
+				// Errors are logged and counted.
+				_ = orc.runPeriodicTask(ctx, t, run)
+			})
+			if !ok {
+				orc.log(ctx).WithField("periodic_task", t.Name).Warnf("periodic task schedule has no future runs")
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	return done
+}
+
+// runPeriodicTask runs the task scheduled at run, unless it is a singleton
+// and the replica is not the leader.
+func (orc *Oracle) runPeriodicTask(ctx context.Context, t PeriodicTask, run time.Time) (err error) {
+	if t.Singleton && !orc.IsLeader() {
+		periodicTaskRunsTotal.WithLabelValues(t.Name, "skipped").Inc()
+		return nil
+	}
+	timeout := t.Timeout
+	if timeout == 0 {
+		timeout = defaultPeriodicTaskTimeout
+	}
+	ctx, cancel := context.WithTimeout(grpclogging.NewContext(ctx), timeout)
+	defer cancel()
+	grpclogging.AddLogrusFields(ctx, logrus.Fields{
+		"periodic_task":     t.Name,
+		"periodic_task_run": run.UTC().Format(time.RFC3339),
+	})
+	ctx, span := orc.tracer.Span(ctx, "periodic task "+t.Name)
+	defer span.End()
+	start := time.Now()
+	defer func() {
+		result := "success"
+		if err != nil {
+			result = "failure"
+			span.RecordError(err)
+			orc.log(ctx).WithError(err).Errorf("periodic task failed")
+		}
+		periodicTaskRunsTotal.WithLabelValues(t.Name, result).Inc()
+		periodicTaskDuration.WithLabelValues(t.Name).Observe(time.Since(start).Seconds())
+	}()
+	return t.Run(ctx)
+}
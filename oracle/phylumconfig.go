@@ -0,0 +1,158 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package oracle
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// PhylumConfigMethods names the phylum endpoints used to manage the phylum's
+// bootstrap configuration.  The endpoints must implement the following
+// contract (shown as JSON):
+//
+//	Get:     {}                 => {"config": {...}, "version": 3}
+//	Set:     {"config": {...}}  => {"version": 4}
+//	History: {"limit": 10}      => {"versions": [{"version": 3, "config": {...}}, ...]}
+type PhylumConfigMethods struct {
+	// Get reads the current configuration.
+	Get string `yaml:"get"`
+	// Set replaces the current configuration.
+	Set string `yaml:"set"`
+	// History lists previous configuration versions, most recent first.
+	History string `yaml:"history"`
+}
+
+// defaultPhylumConfigMethods returns the default phylum config endpoints.
+func defaultPhylumConfigMethods() PhylumConfigMethods {
+	return PhylumConfigMethods{
+		Get:     "get_phylum_config",
+		Set:     "set_phylum_config",
+		History: "get_phylum_config_history",
+	}
+}
+
+// PhylumConfigValidator validates a phylum configuration (JSON) before it is
+// sent to the phylum, e.g. against a JSON schema.
+type PhylumConfigValidator func(configJSON []byte) error
+
+// PhylumConfigVersion is a historical version of the phylum configuration.
+type PhylumConfigVersion struct {
+	// Version identifies the configuration.
+	Version int64 `json:"version"`
+	// Config is the configuration JSON.
+	Config json.RawMessage `json:"config"`
+}
+
+// ErrPhylumConfigVersionNotFound is returned when rolling back to a version
+// that is not present in the configuration history.
+var ErrPhylumConfigVersionNotFound = errors.New("phylum config version not found")
+
+// callPhylumConfig calls a phylum config endpoint with JSON request and
+// response bodies.
+func (orc *Oracle) callPhylumConfig(ctx context.Context, method string, req interface{}, resp interface{}) error {
+	if method == "" {
+		return fmt.Errorf("phylum config method not configured")
+	}
+	reqJSON, err := json.Marshal(req)
+	if err != nil {
+		return fmt.Errorf("phylum config request: %w", err)
+	}
+	reqMsg := &structpb.Struct{}
+	if err := protojson.Unmarshal(reqJSON, reqMsg); err != nil {
+		return fmt.Errorf("phylum config request: %w", err)
+	}
+	respMsg, err := Call(orc, ctx, method, reqMsg, &structpb.Struct{})
+	if err != nil {
+		return fmt.Errorf("phylum config %s: %w", method, err)
+	}
+	respJSON, err := protojson.Marshal(respMsg)
+	if err != nil {
+		return fmt.Errorf("phylum config response: %w", err)
+	}
+	if err := json.Unmarshal(respJSON, resp); err != nil {
+		return fmt.Errorf("phylum config response: %w", err)
+	}
+	return nil
+}
+
+// GetPhylumConfigJSON returns the current phylum configuration as JSON.
+func (orc *Oracle) GetPhylumConfigJSON(ctx context.Context) ([]byte, error) {
+	var resp PhylumConfigVersion
+	err := orc.callPhylumConfig(ctx, orc.cfg.PhylumConfigMethods.Get, struct{}{}, &resp)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Config, nil
+}
+
+// ValidatePhylumConfig checks that configJSON is a JSON object accepted by the
+// configured PhylumConfigValidator, if any.
+func (orc *Oracle) ValidatePhylumConfig(configJSON []byte) error {
+	var obj map[string]interface{}
+	if err := json.Unmarshal(configJSON, &obj); err != nil {
+		return fmt.Errorf("phylum config must be a JSON object: %w", err)
+	}
+	if orc.cfg.phylumConfigValidator != nil {
+		if err := orc.cfg.phylumConfigValidator(configJSON); err != nil {
+			return fmt.Errorf("invalid phylum config: %w", err)
+		}
+	}
+	return nil
+}
+
+// SetPhylumConfig validates and replaces the phylum configuration, returning
+// the new configuration version.
+func (orc *Oracle) SetPhylumConfig(ctx context.Context, configJSON []byte) (int64, error) {
+	if err := orc.ValidatePhylumConfig(configJSON); err != nil {
+		return 0, err
+	}
+	req := struct {
+		Config json.RawMessage `json:"config"`
+	}{Config: configJSON}
+	var resp PhylumConfigVersion
+	err := orc.callPhylumConfig(ctx, orc.cfg.PhylumConfigMethods.Set, req, &resp)
+	if err != nil {
+		return 0, err
+	}
+	orc.log(ctx).WithField("phylum_config_version", resp.Version).Infof("phylum config updated")
+	return resp.Version, nil
+}
+
+// GetPhylumConfigHistory returns up to limit previous phylum configuration
+// versions, most recent first.
+func (orc *Oracle) GetPhylumConfigHistory(ctx context.Context, limit int) ([]*PhylumConfigVersion, error) {
+	req := struct {
+		Limit int `json:"limit"`
+	}{Limit: limit}
+	var resp struct {
+		Versions []*PhylumConfigVersion `json:"versions"`
+	}
+	err := orc.callPhylumConfig(ctx, orc.cfg.PhylumConfigMethods.History, req, &resp)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Versions, nil
+}
+
+// RollbackPhylumConfig restores a previous phylum configuration version.  The
+// restored configuration is validated and stored as a new version, which is
+// returned.
+func (orc *Oracle) RollbackPhylumConfig(ctx context.Context, version int64) (int64, error) {
+	history, err := orc.GetPhylumConfigHistory(ctx, 0)
+	if err != nil {
+		return 0, err
+	}
+	for _, v := range history {
+		if v.Version == version {
+			orc.log(ctx).WithField("phylum_config_version", version).Infof("phylum config rollback")
+			return orc.SetPhylumConfig(ctx, v.Config)
+		}
+	}
+	return 0, fmt.Errorf("%w: %d", ErrPhylumConfigVersionNotFound, version)
+}
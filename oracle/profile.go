@@ -0,0 +1,151 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package oracle
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ConfigSources records where each value of a config was supplied, by
+// dot-separated YAML path, e.g. "memory-guard.limit".  Lists are recorded
+// as a whole.  Values which are not recorded are defaults.
+type ConfigSources map[string]string
+
+// Paths returns the recorded paths, sorted.
+func (s ConfigSources) Paths() []string {
+	paths := make([]string, 0, len(s))
+	for p := range s {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// String renders the sources one per line, e.g.
+// "listen-address: oracle.prod.yaml".
+func (s ConfigSources) String() string {
+	var b strings.Builder
+	for _, p := range s.Paths() {
+		fmt.Fprintf(&b, "%s: %s\n", p, s[p])
+	}
+	return b.String()
+}
+
+// set records source for path and the paths below it, replacing previous
+// sources of path and the paths below it.
+func (s ConfigSources) set(path string, node *yaml.Node, source string) {
+	for p := range s {
+		if p == path || strings.HasPrefix(p, path+".") {
+			delete(s, p)
+		}
+	}
+	if node.Kind != yaml.MappingNode || len(node.Content) == 0 {
+		s[path] = source
+		return
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		s.set(path+"."+node.Content[i].Value, node.Content[i+1], source)
+	}
+}
+
+// ProfileFile returns the overlay config file of a profile for a base config
+// file, in the same directory, e.g. "config/oracle.prod.yaml" for the base
+// "config/oracle.yaml" and the profile "prod".
+func ProfileFile(base string, profile string) string {
+	ext := filepath.Ext(base)
+	return strings.TrimSuffix(base, ext) + "." + profile + ext
+}
+
+// LoadConfigFiles decodes YAML config files into cfg.  Later files, e.g.
+// per-profile overlays of a base file, override earlier ones: mappings are
+// merged key by key, recursively, and other values, including lists,
+// replace the previous value.  The returned sources record the file which
+// supplied each value.
+func LoadConfigFiles(cfg *Config, files ...string) (ConfigSources, error) {
+	sources := ConfigSources{}
+	var merged *yaml.Node
+	for _, file := range files {
+		b, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("config file: %w", err)
+		}
+		var doc yaml.Node
+		if err := yaml.Unmarshal(b, &doc); err != nil {
+			return nil, fmt.Errorf("config file %s: %w", file, err)
+		}
+		if len(doc.Content) == 0 {
+			// Empty file.
+			continue
+		}
+		node := doc.Content[0]
+		if node.Kind != yaml.MappingNode {
+			return nil, fmt.Errorf("config file %s: line %d: not a mapping", file, node.Line)
+		}
+		if merged == nil {
+			merged = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		}
+		mergeConfigNode(merged, node, "", file, sources)
+	}
+	if merged == nil {
+		return sources, nil
+	}
+	if err := merged.Decode(cfg); err != nil {
+		return nil, fmt.Errorf("config files %s: %w", strings.Join(files, ", "), err)
+	}
+	return sources, nil
+}
+
+// mergeConfigNode merges the mapping overlay into the mapping base,
+// recording the sources of the values supplied by overlay.
+func mergeConfigNode(base *yaml.Node, overlay *yaml.Node, path string, source string, sources ConfigSources) {
+	for i := 0; i+1 < len(overlay.Content); i += 2 {
+		key, value := overlay.Content[i], overlay.Content[i+1]
+		keyPath := key.Value
+		if path != "" {
+			keyPath = path + "." + key.Value
+		}
+		j := mappingIndex(base, key.Value)
+		if j < 0 {
+			base.Content = append(base.Content, key, value)
+			sources.set(keyPath, value, source)
+			continue
+		}
+		if prev := base.Content[j+1]; prev.Kind == yaml.MappingNode && value.Kind == yaml.MappingNode {
+			mergeConfigNode(prev, value, keyPath, source, sources)
+			continue
+		}
+		base.Content[j+1] = value
+		sources.set(keyPath, value, source)
+	}
+}
+
+// mappingIndex returns the index of key in the content of a mapping node,
+// or -1.
+func mappingIndex(m *yaml.Node, key string) int {
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// SetConfigSources records where the config values were supplied, which is
+// logged with the effective config when the oracle starts.
+func (c *Config) SetConfigSources(sources ConfigSources) {
+	if c == nil {
+		return
+	}
+	c.configSources = sources
+}
+
+// Sources returns where the config values were supplied, if recorded.
+func (c *Config) Sources() ConfigSources {
+	return c.configSources
+}
@@ -0,0 +1,83 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package oracle
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestProfileFile(t *testing.T) {
+	require.Equal(t, "config/oracle.prod.yaml", ProfileFile("config/oracle.yaml", "prod"))
+	require.Equal(t, "oracle.dev", ProfileFile("oracle", "dev"))
+}
+
+func TestLoadConfigFiles(t *testing.T) {
+	dir := t.TempDir()
+	base := filepath.Join(dir, "oracle.yaml")
+	prod := filepath.Join(dir, "oracle.prod.yaml")
+	require.NoError(t, os.WriteFile(base, []byte(`
+service-name: base-oracle
+listen-address: ":8080"
+trusted-proxies: [10.0.0.0/8, 192.168.0.1]
+memory-guard:
+  limit: 1000
+  shed-ratio: 0.9
+  critical-paths: [/v1/health]
+slow-request-thresholds:
+  "*": 1s
+`), 0o600))
+	require.NoError(t, os.WriteFile(prod, []byte(`
+listen-address: ":9090"
+trusted-proxies: [172.16.0.0/12]
+memory-guard:
+  limit: 2000
+slow-request-thresholds:
+  /pkg.Service/: 100ms
+`), 0o600))
+
+	cfg := DefaultConfig()
+	sources, err := LoadConfigFiles(cfg, base, prod)
+	require.NoError(t, err)
+	require.Equal(t, "base-oracle", cfg.ServiceName)
+	require.Equal(t, ":9090", cfg.ListenAddress)
+	// Lists are replaced.
+	require.Equal(t, []string{"172.16.0.0/12"}, cfg.TrustedProxies)
+	// Maps are merged.
+	require.Equal(t, int64(2000), cfg.MemoryGuard.Limit)
+	require.Equal(t, 0.9, cfg.MemoryGuard.ShedRatio)
+	require.Equal(t, []string{"/v1/health"}, cfg.MemoryGuard.CriticalPaths)
+	require.Equal(t, time.Second, cfg.SlowRequestThresholds["*"])
+	require.Equal(t, 100*time.Millisecond, cfg.SlowRequestThresholds["/pkg.Service/"])
+
+	require.Equal(t, ConfigSources{
+		"service-name":                          base,
+		"listen-address":                        prod,
+		"trusted-proxies":                       prod,
+		"memory-guard.limit":                    prod,
+		"memory-guard.shed-ratio":               base,
+		"memory-guard.critical-paths":           base,
+		"slow-request-thresholds.*":             base,
+		"slow-request-thresholds./pkg.Service/": prod,
+	}, sources)
+	require.Equal(t, "listen-address: "+prod+"\n", ConfigSources{"listen-address": prod}.String())
+
+	empty := filepath.Join(dir, "oracle.empty.yaml")
+	require.NoError(t, os.WriteFile(empty, nil, 0o600))
+	cfg = DefaultConfig()
+	_, err = LoadConfigFiles(cfg, base, empty)
+	require.NoError(t, err)
+	require.Equal(t, ":8080", cfg.ListenAddress)
+
+	_, err = LoadConfigFiles(DefaultConfig(), base, filepath.Join(dir, "missing.yaml"))
+	require.ErrorContains(t, err, "config file")
+
+	list := filepath.Join(dir, "oracle.list.yaml")
+	require.NoError(t, os.WriteFile(list, []byte("- a\n"), 0o600))
+	_, err = LoadConfigFiles(DefaultConfig(), list)
+	require.ErrorContains(t, err, "not a mapping")
+}
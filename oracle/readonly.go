@@ -0,0 +1,95 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package oracle
+
+import (
+	"context"
+	"slices"
+
+	"github.com/luthersystems/shiroclient-sdk-go/shiroclient"
+	"github.com/luthersystems/svc/grpclogging"
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// AttrPhylumReadOnly marks spans of requests which made read-only phylum
+// calls.
+const AttrPhylumReadOnly = attribute.Key("app.phylum.read_only")
+
+// commitTxIDField is the gateway response field holding the ID of a
+// committed transaction.
+const commitTxIDField = "$commit_tx_id"
+
+type readOnlyKey struct{}
+
+// WithReadOnly marks phylum calls made with the returned context as
+// read-only.  Only the configured ReadOnlyMethods may be called with the
+// returned context.  See Call.
+func WithReadOnly(ctx context.Context) context.Context {
+	return context.WithValue(ctx, readOnlyKey{}, true)
+}
+
+// IsReadOnly returns true if the context was marked read-only by
+// WithReadOnly.
+func IsReadOnly(ctx context.Context) bool {
+	readOnly, _ := ctx.Value(readOnlyKey{}).(bool)
+	return readOnly
+}
+
+// readOnly returns true if a call to the phylum method must not write to
+// the ledger.
+func (orc *Oracle) readOnly(ctx context.Context, methodName string) bool {
+	return IsReadOnly(ctx) || slices.Contains(orc.cfg.ReadOnlyMethods, methodName)
+}
+
+// allowReadOnly returns an error if a call to the phylum method is made with
+// a WithReadOnly context but the method is not one of the ReadOnlyMethods.
+// The gateway cannot simulate a call without committing it, so only methods
+// declared read-only may be called where writes are forbidden.
+func (orc *Oracle) allowReadOnly(ctx context.Context, methodName string) error {
+	if !IsReadOnly(ctx) || slices.Contains(orc.cfg.ReadOnlyMethods, methodName) {
+		return nil
+	}
+	orc.log(ctx).WithField("phylum_method", methodName).
+		Errorf("read-only context calls phylum method not declared read-only")
+	return status.Error(codes.Internal, "read-only context calls phylum method not declared read-only")
+}
+
+// readOnlyConfigs returns the configs of a read-only phylum call.  The call
+// is routed to the ReadOnlyEndpoints if replicas is true.
+func (orc *Oracle) readOnlyConfigs(ctx context.Context, replicas bool) []shiroclient.Config {
+	trace.SpanFromContext(ctx).SetAttributes(AttrPhylumReadOnly.Bool(true))
+	grpclogging.AddLogrusFields(ctx, logrus.Fields{"read_only": true})
+	if replicas && len(orc.cfg.ReadOnlyEndpoints) > 0 {
+		return []shiroclient.Config{shiroclient.WithTargetEndpoints(orc.cfg.ReadOnlyEndpoints)}
+	}
+	return nil
+}
+
+// checkReadOnly returns an error if the raw gateway response of a read-only
+// call reports a committed transaction, i.e. a method declared read-only
+// wrote to the ledger.
+func (orc *Oracle) checkReadOnly(ctx context.Context, methodName string, raw interface{}) error {
+	txID := commitTxID(raw)
+	if txID == "" {
+		return nil
+	}
+	orc.log(ctx).WithFields(logrus.Fields{
+		"phylum_method": methodName,
+		"tx_id":         txID,
+	}).Errorf("read-only phylum call committed a transaction")
+	return status.Error(codes.Internal, "read-only phylum call committed a transaction")
+}
+
+// commitTxID returns the committed transaction ID of a raw gateway response.
+func commitTxID(raw interface{}) string {
+	res, ok := raw.(map[string]interface{})
+	if !ok {
+		return ""
+	}
+	txID, _ := res[commitTxIDField].(string)
+	return txID
+}
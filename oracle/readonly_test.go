@@ -0,0 +1,81 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package oracle
+
+import (
+	"context"
+	"testing"
+
+	healthcheck "buf.build/gen/go/luthersystems/protos/protocolbuffers/go/healthcheck/v1"
+	"github.com/luthersystems/shiroclient-sdk-go/shiroclient"
+	"github.com/luthersystems/shiroclient-sdk-go/shiroclient/phylum"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestReadOnly(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.ReadOnlyMethods = []string{"get_account"}
+	orc := newTestOracle(t, cfg)
+
+	ctx := context.Background()
+	require.False(t, IsReadOnly(ctx))
+	require.False(t, orc.readOnly(ctx, "create_account"))
+	require.True(t, orc.readOnly(ctx, "get_account"))
+
+	ctx = WithReadOnly(ctx)
+	require.True(t, IsReadOnly(ctx))
+	require.True(t, orc.readOnly(ctx, "create_account"))
+}
+
+func TestReadOnlyConfigs(t *testing.T) {
+	cfg := DefaultConfig()
+	orc := newTestOracle(t, cfg)
+	require.Empty(t, orc.readOnlyConfigs(context.Background(), true))
+
+	orc.cfg.ReadOnlyEndpoints = []string{"replica"}
+	require.Len(t, orc.readOnlyConfigs(context.Background(), true), 1)
+	require.Empty(t, orc.readOnlyConfigs(context.Background(), false))
+}
+
+func TestCheckReadOnly(t *testing.T) {
+	orc := newTestOracle(t, DefaultConfig())
+	ctx := context.Background()
+
+	require.NoError(t, orc.checkReadOnly(ctx, "get_account", nil))
+	require.NoError(t, orc.checkReadOnly(ctx, "get_account", map[string]interface{}{
+		"result": map[string]interface{}{},
+	}))
+
+	err := orc.checkReadOnly(ctx, "get_account", map[string]interface{}{
+		"result":        map[string]interface{}{},
+		"$commit_tx_id": "tx1",
+	})
+	require.Error(t, err)
+	require.Equal(t, codes.Internal, status.Code(err))
+}
+
+func TestCallReadOnly(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.ReadOnlyMethods = []string{"get_report"}
+	orc := newTestOracle(t, cfg)
+	var called []string
+	commit := committingPhylum(1, 2)
+	orc.callPhylum = func(ctx context.Context, ph *phylum.Client, methodName string, req proto.Message, resp proto.Message, raw *interface{}, configs ...shiroclient.Config) (proto.Message, error) {
+		called = append(called, methodName)
+		return commit(ctx, ph, methodName, req, resp, raw, configs...)
+	}
+	ctx := WithReadOnly(context.Background())
+
+	// Methods not declared read-only are refused before the call.
+	_, err := Call(orc, ctx, "create_report", &healthcheck.HealthCheckReport{}, &healthcheck.HealthCheckReport{})
+	require.Equal(t, codes.Internal, status.Code(err))
+	require.Empty(t, called)
+
+	// Declared read-only methods which commit are reported.
+	_, err = Call(orc, ctx, "get_report", &healthcheck.HealthCheckReport{}, &healthcheck.HealthCheckReport{})
+	require.Equal(t, codes.Internal, status.Code(err))
+	require.Equal(t, []string{"get_report"}, called)
+}
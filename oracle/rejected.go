@@ -0,0 +1,389 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package oracle
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"github.com/luthersystems/svc/svcerr"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	defaultRejectedPayloadMaxBytes = 4096
+
+	// rejectedPayloadCaptureLimit is the maximum size of a request body
+	// captured to log why it was rejected.  Payloads are redacted before
+	// they are truncated, so they are captured whole.
+	rejectedPayloadCaptureLimit = 1 << 20
+
+	// rejectedPayloadRedacted replaces redacted values of rejected
+	// payloads.
+	rejectedPayloadRedacted = "REDACTED"
+)
+
+// defaultRejectedPayloadRedactedFields are JSON fields always redacted from
+// rejected payloads.
+var defaultRejectedPayloadRedactedFields = []string{"password", "secret", "token"}
+
+// protojsonErrorPattern matches the errors of protojson.Unmarshal, which the
+// grpc-gateway returns as InvalidArgument status messages.  protojson
+// randomly separates its prefix with a non-breaking space.
+var protojsonErrorPattern = regexp.MustCompile(`^proto:[\s\x{00a0}]+([^(]*)\(line (\d+):(\d+)\): (.*)$`)
+
+// RejectedPayloads logs the JSON request bodies which the gateway rejects
+// because they cannot be unmarshaled into the request message, with the
+// precise unmarshaling error and the path of the offending field.  The
+// client receives a business exception naming the field whether or not
+// payloads are logged.
+type RejectedPayloads struct {
+	// Log enables logging of rejected payloads.
+	Log bool `yaml:"log"`
+	// MaxBytes is the maximum size of a logged payload.  Larger payloads
+	// are truncated.  Defaults to 4096.  If payloads are not logged, only
+	// the first MaxBytes of request bodies are kept to locate the offending
+	// field.
+	MaxBytes int `yaml:"max-bytes"`
+	// RedactedFields are the names of JSON fields, at any depth, whose
+	// values are redacted from logged payloads.  Names are case
+	// insensitive.  Fields named password, secret or token are always
+	// redacted.
+	RedactedFields []string `yaml:"redacted-fields"`
+}
+
+// valid validates the rejected payload configuration.
+func (p RejectedPayloads) valid() error {
+	if p.MaxBytes < 0 {
+		return fmt.Errorf("rejected payloads: negative max bytes")
+	}
+	for _, f := range p.RedactedFields {
+		if f == "" {
+			return fmt.Errorf("rejected payloads: empty redacted field")
+		}
+	}
+	return nil
+}
+
+// maxBytes returns the maximum size of a logged payload.
+func (p RejectedPayloads) maxBytes() int {
+	if p.MaxBytes == 0 {
+		return defaultRejectedPayloadMaxBytes
+	}
+	return p.MaxBytes
+}
+
+// redacted returns the lower case names of redacted fields.
+func (p RejectedPayloads) redacted() map[string]bool {
+	fields := make(map[string]bool, len(defaultRejectedPayloadRedactedFields)+len(p.RedactedFields))
+	for _, f := range defaultRejectedPayloadRedactedFields {
+		fields[f] = true
+	}
+	for _, f := range p.RedactedFields {
+		fields[strings.ToLower(f)] = true
+	}
+	return fields
+}
+
+// RejectedPayload is a request payload rejected by the gateway.
+type RejectedPayload struct {
+	// Method is the gRPC method of the request.
+	Method string `json:"method"`
+	// Path is the request path.
+	Path string `json:"path"`
+	// Field is the path of the offending field, e.g. "items[2].amount",
+	// or empty if the payload itself is malformed.
+	Field string `json:"field,omitempty"`
+	// Error is the unmarshaling error.
+	Error string `json:"error"`
+	// Payload is the redacted payload, truncated to MaxBytes.  It is empty
+	// if the payload is not valid JSON and cannot be redacted.
+	Payload string `json:"payload,omitempty"`
+	// Size is the size of the payload in bytes.
+	Size int `json:"size"`
+	// Truncated is true if Payload was truncated.
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// RejectedPayloadRecorder records rejected payloads, e.g. to a request
+// archive.  It must not retain ctx.
+type RejectedPayloadRecorder func(ctx context.Context, p *RejectedPayload)
+
+// SetRejectedPayloadRecorder configures a function called with each payload
+// rejected by the gateway, in addition to logging it.  It is only called
+// when RejectedPayloads.Log is set.
+func (c *Config) SetRejectedPayloadRecorder(fn RejectedPayloadRecorder) {
+	if c == nil {
+		return
+	}
+	c.rejectedPayloadRecorder = fn
+}
+
+type capturedBodyKey struct{}
+
+// capturedBody captures the start of a request body, up to limit bytes, as
+// it is read.
+type capturedBody struct {
+	io.ReadCloser
+	buf   bytes.Buffer
+	size  int
+	limit int
+}
+
+// Read implements io.Reader.
+func (b *capturedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.size += n
+	if room := b.limit - b.buf.Len(); room > 0 {
+		b.buf.Write(p[:min(n, room)])
+	}
+	return n, err
+}
+
+// rejectedPayloadMiddleware captures the JSON request bodies of the
+// grpc-gateway so that rejected payloads can be explained and logged.  Only
+// the first MaxBytes of a body are captured unless payloads are logged.
+func (orc *Oracle) rejectedPayloadMiddleware(next http.Handler) http.Handler {
+	limit := rejectedPayloadCaptureLimit
+	if !orc.cfg.RejectedPayloads.Log {
+		limit = orc.cfg.RejectedPayloads.maxBytes()
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Body == nil || r.Body == http.NoBody || isProtobufRequest(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		body := &capturedBody{ReadCloser: r.Body, limit: limit}
+		r.Body = body
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), capturedBodyKey{}, body)))
+	})
+}
+
+// isProtobufRequest returns true if the body of r is binary protobuf, see
+// Config.GatewayProtobuf.
+func isProtobufRequest(r *http.Request) bool {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return mediaType == protobufContentType
+}
+
+// errorHandler returns the gateway error handler.
+func (orc *Oracle) errorHandler() svcerr.HTTPErrorHandler {
+	intercept := svcerr.ErrIntercept(orc.log)
+	return func(ctx context.Context, mux *runtime.ServeMux, marshaler runtime.Marshaler, w http.ResponseWriter, r *http.Request, err error) {
+		if rejected := orc.rejectPayload(ctx, r, err); rejected != nil {
+			err = rejected
+		}
+		intercept(ctx, mux, marshaler, w, r, err)
+	}
+}
+
+// rejectPayload explains the rejection of a request payload by the gateway
+// unmarshaler, logging and recording the payload if configured, and returns
+// the error presented to the client.  It returns nil if err is not an
+// unmarshaling error.
+func (orc *Oracle) rejectPayload(ctx context.Context, r *http.Request, err error) error {
+	body, ok := ctx.Value(capturedBodyKey{}).(*capturedBody)
+	if !ok {
+		return nil
+	}
+	stat, ok := status.FromError(err)
+	if !ok || stat.Code() != codes.InvalidArgument || len(stat.Details()) > 0 {
+		return nil
+	}
+	m := protojsonErrorPattern.FindStringSubmatch(stat.Message())
+	if m == nil {
+		return nil
+	}
+	reason := m[4]
+	if kind := strings.TrimSpace(m[1]); kind != "" {
+		reason = kind + ": " + reason
+	}
+	line, _ := strconv.Atoi(m[2])
+	col, _ := strconv.Atoi(m[3])
+	data := body.buf.Bytes()
+	complete := body.size == len(data)
+	p := &RejectedPayload{
+		Method: rpcMethod(ctx),
+		Path:   r.URL.Path,
+		Error:  reason,
+		Size:   body.size,
+	}
+	// The field is unknown if the error is past the captured payload.
+	if offset := jsonOffset(data, line, col); offset < len(data) || complete {
+		p.Field = jsonFieldPath(data, offset)
+	}
+	cfg := orc.cfg.RejectedPayloads
+	if !cfg.Log {
+		return unmarshalError(ctx, p.Field, reason)
+	}
+	if complete {
+		p.Payload, p.Truncated = redactJSON(data, cfg.redacted(), cfg.maxBytes())
+	}
+	orc.log(ctx).WithFields(logrus.Fields{
+		"rejected_method":    p.Method,
+		"rejected_field":     p.Field,
+		"rejected_error":     p.Error,
+		"rejected_payload":   p.Payload,
+		"rejected_size":      p.Size,
+		"rejected_truncated": p.Truncated,
+	}).Warnf("rejected request payload")
+	if orc.cfg.rejectedPayloadRecorder != nil {
+		orc.cfg.rejectedPayloadRecorder(ctx, p)
+	}
+	return unmarshalError(ctx, p.Field, reason)
+}
+
+// rpcMethod returns the gRPC method served by the gateway, or "unknown".
+func rpcMethod(ctx context.Context) string {
+	method, ok := runtime.RPCMethod(ctx)
+	if !ok || method == "" {
+		return "unknown"
+	}
+	return method
+}
+
+// jsonOffset returns the byte offset in data of a 1-based line and rune
+// column, as reported by protojson.
+func jsonOffset(data []byte, line int, col int) int {
+	offset := 0
+	for ; line > 1; line-- {
+		i := bytes.IndexByte(data[offset:], '\n')
+		if i < 0 {
+			return len(data)
+		}
+		offset += i + 1
+	}
+	for ; col > 1 && offset < len(data); col-- {
+		_, n := utf8.DecodeRune(data[offset:])
+		offset += n
+	}
+	return offset
+}
+
+// jsonPathFrame is an object or array enclosing a JSON token.
+type jsonPathFrame struct {
+	object bool
+	// key is the key of the current object member, when inValue.
+	key     string
+	inValue bool
+	// index is the index of the current array element.
+	index int
+}
+
+// jsonFieldPath returns the path of the JSON token at offset in data, such
+// as "items[2].amount".  Object keys are part of the path of their member.
+func jsonFieldPath(data []byte, offset int) string {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var stack []*jsonPathFrame
+	valueDone := func() {
+		if len(stack) == 0 {
+			return
+		}
+		top := stack[len(stack)-1]
+		top.inValue = false
+		if !top.object {
+			top.index++
+		}
+	}
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return jsonPath(stack)
+		}
+		past := dec.InputOffset() > int64(offset)
+		d, delim := tok.(json.Delim)
+		if delim && (d == '}' || d == ']') {
+			if past {
+				return jsonPath(stack)
+			}
+			stack = stack[:len(stack)-1]
+			valueDone()
+			continue
+		}
+		if n := len(stack); n > 0 && stack[n-1].object && !stack[n-1].inValue {
+			stack[n-1].key, _ = tok.(string)
+			stack[n-1].inValue = true
+			if past {
+				return jsonPath(stack)
+			}
+			continue
+		}
+		if past {
+			return jsonPath(stack)
+		}
+		if delim {
+			stack = append(stack, &jsonPathFrame{object: d == '{'})
+			continue
+		}
+		valueDone()
+	}
+}
+
+// jsonPath formats the path of the current token of stack.
+func jsonPath(stack []*jsonPathFrame) string {
+	var b strings.Builder
+	for _, f := range stack {
+		switch {
+		case f.object && f.inValue:
+			if b.Len() > 0 {
+				b.WriteByte('.')
+			}
+			b.WriteString(f.key)
+		case !f.object:
+			fmt.Fprintf(&b, "[%d]", f.index)
+		}
+	}
+	return b.String()
+}
+
+// redactJSON returns data with the values of redacted fields replaced,
+// truncated to limit bytes.  It returns an empty string if data is not valid
+// JSON, as it cannot be redacted.
+func redactJSON(data []byte, redacted map[string]bool, limit int) (string, bool) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return "", false
+	}
+	b, err := json.Marshal(redactJSONValue(v, redacted))
+	if err != nil {
+		return "", false
+	}
+	if len(b) > limit {
+		return string(b[:limit]), true
+	}
+	return string(b), false
+}
+
+// redactJSONValue redacts the fields of a decoded JSON value.
+func redactJSONValue(v interface{}, redacted map[string]bool) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, child := range v {
+			if redacted[strings.ToLower(k)] {
+				v[k] = rejectedPayloadRedacted
+				continue
+			}
+			v[k] = redactJSONValue(child, redacted)
+		}
+	case []interface{}:
+		for i, child := range v {
+			v[i] = redactJSONValue(child, redacted)
+		}
+	}
+	return v
+}
@@ -0,0 +1,137 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package oracle
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestJSONFieldPath(t *testing.T) {
+	for _, tc := range []struct {
+		data  string
+		token string
+		path  string
+	}{
+		{`{"name": "a", "age": "x"}`, `"x"`, "age"},
+		{`{"name": "a", "bogus": 1}`, `"bogus"`, "bogus"},
+		{`{"items": [{"n": 1}, {"n": "x"}]}`, `"x"`, "items[1].n"},
+		{`{"a": {"b": [1, 2, true]}}`, `true`, "a.b[2]"},
+		{`{"a": 1, "b": `, ``, "b"},
+	} {
+		offset := len(tc.data)
+		if tc.token != "" {
+			offset = strings.Index(tc.data, tc.token)
+		}
+		require.Equal(t, tc.path, jsonFieldPath([]byte(tc.data), offset), tc.data)
+	}
+}
+
+func TestJSONOffset(t *testing.T) {
+	data := []byte("{\n  \"é\": 1,\n  \"x\": 2\n}")
+	require.Equal(t, strings.Index(string(data), `"x"`), jsonOffset(data, 3, 3))
+	require.Equal(t, strings.Index(string(data), `1`), jsonOffset(data, 2, 8))
+}
+
+func TestRedactJSON(t *testing.T) {
+	redacted := RejectedPayloads{RedactedFields: []string{"SSN"}}.redacted()
+	s, truncated := redactJSON([]byte(`{"user": {"ssn": "123", "Password": "p"}, "n": 1}`), redacted, 100)
+	require.False(t, truncated)
+	require.Equal(t, `{"n":1,"user":{"Password":"REDACTED","ssn":"REDACTED"}}`, s)
+
+	s, truncated = redactJSON([]byte(`{"n": 12345}`), redacted, 5)
+	require.True(t, truncated)
+	require.Equal(t, `{"n":`, s)
+
+	s, _ = redactJSON([]byte(`{"password": "p"`), redacted, 100)
+	require.Empty(t, s)
+}
+
+func TestRejectPayload(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.RejectedPayloads.Log = true
+	var recorded *RejectedPayload
+	cfg.SetRejectedPayloadRecorder(func(ctx context.Context, p *RejectedPayload) {
+		recorded = p
+	})
+	orc := newTestOracle(t, cfg)
+
+	body := `{"items": [{"amount": "x", "token": "t"}]}`
+	var rejected error
+	app := orc.rejectedPayloadMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		col := strings.Index(body, `"x"`) + 1
+		err = status.Errorf(codes.InvalidArgument, "proto: (line 1:%d): invalid value for int64 field amount: \"x\"", col)
+		rejected = orc.rejectPayload(r.Context(), r, err)
+
+		// Errors raised by the service are not rejections.
+		require.Nil(t, orc.rejectPayload(r.Context(), r, status.Error(codes.InvalidArgument, "bad")))
+	}))
+	app.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/v1/items", strings.NewReader(body)))
+
+	require.Error(t, rejected)
+	stat, ok := status.FromError(rejected)
+	require.True(t, ok)
+	require.Equal(t, codes.InvalidArgument, stat.Code())
+	require.Equal(t, `invalid request field "items[0].amount": invalid value for int64 field amount: "x"`, stat.Message())
+
+	require.NotNil(t, recorded)
+	require.Equal(t, "/v1/items", recorded.Path)
+	require.Equal(t, "items[0].amount", recorded.Field)
+	require.Equal(t, len(body), recorded.Size)
+	require.Equal(t, `{"items":[{"amount":"x","token":"REDACTED"}]}`, recorded.Payload)
+}
+
+func TestRejectPayloadNotLogged(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.RejectedPayloads.MaxBytes = 16
+	orc := newTestOracle(t, cfg)
+
+	reject := func(body string, contentType string) (captured bool, rejected error) {
+		app := orc.rejectedPayloadMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, err := io.ReadAll(r.Body)
+			require.NoError(t, err)
+			col := strings.Index(body, `"x"`) + 1
+			err = status.Errorf(codes.InvalidArgument, "proto: (line 1:%d): invalid value for int64 field amount: \"x\"", col)
+			_, captured = r.Context().Value(capturedBodyKey{}).(*capturedBody)
+			rejected = orc.rejectPayload(r.Context(), r, err)
+		}))
+		r := httptest.NewRequest(http.MethodPost, "/v1/items", strings.NewReader(body))
+		r.Header.Set("Content-Type", contentType)
+		app.ServeHTTP(httptest.NewRecorder(), r)
+		return captured, rejected
+	}
+
+	captured, rejected := reject(`{"amount": "x"}`, "application/json")
+	require.True(t, captured)
+	require.Contains(t, status.Convert(rejected).Message(), `"amount"`)
+
+	// Only MaxBytes are captured, so the field past them is unknown.
+	captured, rejected = reject(`{"name": "abcdefghijklmnop", "amount": "x"}`, "")
+	require.True(t, captured)
+	require.NotContains(t, status.Convert(rejected).Message(), `"name"`)
+	require.Contains(t, status.Convert(rejected).Message(), `invalid value for int64 field amount`)
+
+	captured, _ = reject(`{"amount": "x"}`, "application/x-protobuf")
+	require.False(t, captured)
+}
+
+func TestRejectedPayloadsValid(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.RejectedPayloads.MaxBytes = -1
+	require.Error(t, cfg.Valid())
+	cfg.RejectedPayloads.MaxBytes = 0
+	cfg.RejectedPayloads.RedactedFields = []string{""}
+	require.Error(t, cfg.Valid())
+	cfg.RejectedPayloads.RedactedFields = []string{"ssn"}
+	require.NoError(t, cfg.Valid())
+}
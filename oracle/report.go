@@ -0,0 +1,328 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package oracle
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"html"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/luthersystems/raymond"
+	"github.com/luthersystems/svc/docstore"
+	"github.com/luthersystems/svc/grpclogging"
+	"github.com/luthersystems/svc/libhandlebars"
+	"github.com/luthersystems/svc/mailer"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// ReportFormat is the output format of a report.
+type ReportFormat string
+
+const (
+	// ReportCSV stores the rendered template, which must be valid CSV.
+	ReportCSV ReportFormat = "csv"
+	// ReportPDF stores the rendered template as preformatted text in a PDF
+	// document.
+	ReportPDF ReportFormat = "pdf"
+)
+
+// reportKeyTimeLayout formats the run time in report document keys.
+const reportKeyTimeLayout = "20060102T150405Z"
+
+var (
+	reportRunsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "report_runs_total",
+			Help: "How many scheduled report runs, partitioned by report and result.",
+		},
+		[]string{"report", "result"},
+	)
+	reportDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "report_duration_seconds",
+			Help:    "Duration of scheduled report runs, partitioned by report.",
+			Buckets: prometheus.ExponentialBuckets(0.1, 2, 12),
+		},
+		[]string{"report"},
+	)
+	reportLastSuccess = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "report_last_success_timestamp_seconds",
+			Help: "Unix time of the last successful run of a scheduled report.",
+		},
+		[]string{"report"},
+	)
+)
+
+// ReportQuery queries the data of a report run at the scheduled time run.
+// The result is rendered by the report template, after conversion to JSON
+// values.
+type ReportQuery func(ctx context.Context, orc *Oracle, run time.Time) (interface{}, error)
+
+// PhylumReportQuery returns a report query calling a phylum method with the
+// request returned by newReq.  The template renders the response fields by
+// their proto names.
+func PhylumReportQuery[K proto.Message, R proto.Message](methodName string, newReq func(run time.Time) K, newResp func() R) ReportQuery {
+	return func(ctx context.Context, orc *Oracle, run time.Time) (interface{}, error) {
+		resp, err := Call(orc, ctx, methodName, newReq(run), newResp())
+		if err != nil {
+			return nil, err
+		}
+		b, err := protojson.MarshalOptions{UseProtoNames: true, EmitUnpopulated: true}.Marshal(resp)
+		if err != nil {
+			return nil, err
+		}
+		var data interface{}
+		if err := json.Unmarshal(b, &data); err != nil {
+			return nil, err
+		}
+		return data, nil
+	}
+}
+
+// Report is generated on a schedule by querying data, rendering it with a
+// handlebars template, storing the document, and notifying recipients by
+// email.  Failed runs are logged at error level, and so forwarded by the
+// AlertWebhook when configured.
+type Report struct {
+	// Name identifies the report in document keys, logs and metrics.
+	Name string
+	// Schedule is a five field cron expression evaluated in UTC, a
+	// shorthand such as @daily, or "@every <duration>".
+	Schedule string
+	// Query queries the report data.
+	Query ReportQuery
+	// Template is a handlebars template rendering the data, available as
+	// {{data}}, alongside {{report}} and {{run_time}}.
+	Template string
+	// Format is the output format.
+	Format ReportFormat
+	// Store receives the report documents.
+	Store docstore.Putter
+	// KeyPrefix prefixes the keys of report documents, which are
+	// <prefix>/<name>/<run time>.<format>.
+	KeyPrefix string
+	// Recipients are emailed when a report is generated, using the mailer
+	// set with SetReportMailer.
+	Recipients []string
+	// Subject is the subject of emails.  Defaults to the report name.
+	Subject string
+	// Timeout bounds a run.  Defaults to 10 minutes.
+	Timeout time.Duration
+}
+
+const defaultReportTimeout = 10 * time.Minute
+
+// AddReport schedules a report while the oracle runs.
+func (c *Config) AddReport(r Report) {
+	if c == nil {
+		return
+	}
+	c.reports = append(c.reports, r)
+}
+
+// SetReportMailer configures the mailer emailing report recipients.
+func (c *Config) SetReportMailer(m mailer.Mailer) {
+	if c == nil {
+		return
+	}
+	c.reportMailer = m
+}
+
+// validReports validates the report configuration.
+func (c *Config) validReports() error {
+	names := make(map[string]bool, len(c.reports))
+	for _, r := range c.reports {
+		if _, err := r.compile(); err != nil {
+			return err
+		}
+		if names[r.Name] {
+			return fmt.Errorf("report %s: duplicate name", r.Name)
+		}
+		names[r.Name] = true
+		if len(r.Recipients) > 0 && c.reportMailer == nil {
+			return fmt.Errorf("report %s: recipients require a report mailer", r.Name)
+		}
+	}
+	return nil
+}
+
+// scheduledReport is a report ready to run.
+type scheduledReport struct {
+	Report
+	schedule schedule
+	tpl      *raymond.Template
+}
+
+// compile validates the report, parsing its schedule and template.
+func (r Report) compile() (*scheduledReport, error) {
+	if err := docstore.ValidKey(r.Name); err != nil || strings.Contains(r.Name, "/") {
+		return nil, fmt.Errorf("report %q: invalid name", r.Name)
+	}
+	sched, err := parseSchedule(r.Schedule)
+	if err != nil {
+		return nil, fmt.Errorf("report %s: %w", r.Name, err)
+	}
+	tpl, err := libhandlebars.Parse(r.Template)
+	if err != nil {
+		return nil, fmt.Errorf("report %s: template: %w", r.Name, err)
+	}
+	switch r.Format {
+	case ReportCSV, ReportPDF:
+	default:
+		return nil, fmt.Errorf("report %s: unsupported format %q", r.Name, r.Format)
+	}
+	if r.Query == nil {
+		return nil, fmt.Errorf("report %s: missing query", r.Name)
+	}
+	if r.Store == nil {
+		return nil, fmt.Errorf("report %s: missing store", r.Name)
+	}
+	return &scheduledReport{Report: r, schedule: sched, tpl: tpl}, nil
+}
+
+// key returns the document key of the report run at run.
+func (r *scheduledReport) key(run time.Time) string {
+	key := fmt.Sprintf("%s/%s.%s", r.Name, run.UTC().Format(reportKeyTimeLayout), r.Format)
+	if r.KeyPrefix == "" {
+		return key
+	}
+	return strings.TrimSuffix(r.KeyPrefix, "/") + "/" + key
+}
+
+// render renders the report document.
+func (r *scheduledReport) render(ctx context.Context, data interface{}, run time.Time) ([]byte, error) {
+	out, err := libhandlebars.RenderContext(ctx, r.tpl, map[string]interface{}{
+		"report":   r.Name,
+		"run_time": run.UTC().Format(time.RFC3339),
+		"data":     data,
+	}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("render: %w", err)
+	}
+	switch r.Format {
+	case ReportCSV:
+		if _, err := csv.NewReader(strings.NewReader(out)).ReadAll(); err != nil {
+			return nil, fmt.Errorf("render: invalid csv: %w", err)
+		}
+		return []byte(out), nil
+	case ReportPDF:
+		return textPDF(out), nil
+	}
+	return nil, fmt.Errorf("render: unsupported format %q", r.Format)
+}
+
+// runReports runs the configured reports on schedule until ctx is done.
+// The returned channel is closed once running reports have stopped.
+func (orc *Oracle) runReports(ctx context.Context) <-chan struct{} {
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	for _, r := range orc.cfg.reports {
+		sr, err := r.compile()
+		if err != nil {
+			// The config was validated.
+			orc.log(ctx).WithError(err).Errorf("report invalid")
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			orc.scheduleReport(ctx, sr)
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	return done
+}
+
+// scheduleReport runs a report at each scheduled time until ctx is done.
+func (orc *Oracle) scheduleReport(ctx context.Context, r *scheduledReport) {
+	ok := runSchedule(ctx, r.schedule, func(run time.Time) {
+		// Errors are logged and counted.
+		_ = orc.runReport(ctx, r, run)
+	})
+	if !ok {
+		orc.log(ctx).WithField("report", r.Name).Warnf("report schedule has no future runs")
+	}
+}
+
+// runReport generates the report scheduled at run.
+func (orc *Oracle) runReport(ctx context.Context, r *scheduledReport, run time.Time) (err error) {
+	timeout := r.Timeout
+	if timeout == 0 {
+		timeout = defaultReportTimeout
+	}
+	ctx, cancel := context.WithTimeout(grpclogging.NewContext(ctx), timeout)
+	defer cancel()
+	grpclogging.AddLogrusFields(ctx, logrus.Fields{
+		"report":     r.Name,
+		"report_run": run.UTC().Format(time.RFC3339),
+	})
+	ctx, span := orc.tracer.Span(ctx, "report "+r.Name)
+	defer span.End()
+	start := time.Now()
+	defer func() {
+		result := "success"
+		if err != nil {
+			result = "failure"
+			span.RecordError(err)
+			orc.log(ctx).WithError(err).Errorf("report failed")
+		} else {
+			reportLastSuccess.WithLabelValues(r.Name).SetToCurrentTime()
+		}
+		reportRunsTotal.WithLabelValues(r.Name, result).Inc()
+		reportDuration.WithLabelValues(r.Name).Observe(time.Since(start).Seconds())
+	}()
+
+	data, err := r.Query(ctx, orc, run)
+	if err != nil {
+		return fmt.Errorf("query: %w", err)
+	}
+	// Reports are rendered in the default locale.
+	doc, err := r.render(orc.withDefaultLocale(ctx), data, run)
+	if err != nil {
+		return err
+	}
+	key := r.key(run)
+	if err := r.Store.Put(ctx, key, doc); err != nil {
+		return fmt.Errorf("store: %w", err)
+	}
+	orc.log(ctx).WithField("report_key", key).Infof("report generated")
+	return orc.mailReport(ctx, r, run, key)
+}
+
+// mailReport notifies the recipients of a generated report.
+func (orc *Oracle) mailReport(ctx context.Context, r *scheduledReport, run time.Time, key string) error {
+	if len(r.Recipients) == 0 {
+		return nil
+	}
+	subject := r.Subject
+	if subject == "" {
+		subject = r.Name
+	}
+	var content bytes.Buffer
+	fmt.Fprintf(&content, "<p>Report %s for %s has been generated.</p><p>Document: %s</p>",
+		html.EscapeString(r.Name), html.EscapeString(run.UTC().Format(time.RFC3339)), html.EscapeString(key))
+	var failed []string
+	for _, to := range r.Recipients {
+		if err := orc.cfg.reportMailer.Send(ctx, content.String(), to, subject); err != nil {
+			orc.log(ctx).WithError(err).WithField("recipient", to).Warnf("report email failed")
+			failed = append(failed, to)
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("email: failed recipients: %s", strings.Join(failed, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package oracle
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/luthersystems/svc/mailer"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+type reportStore map[string][]byte
+
+func (s reportStore) Put(ctx context.Context, key string, body []byte) error {
+	s[key] = body
+	return nil
+}
+
+func claimsQuery(ctx context.Context, orc *Oracle, run time.Time) (interface{}, error) {
+	return map[string]interface{}{
+		"claims": []interface{}{
+			map[string]interface{}{"id": "c1", "amount": "10"},
+			map[string]interface{}{"id": "c2", "amount": "20"},
+		},
+	}, nil
+}
+
+const claimsTemplate = `id,amount
+{{#each data.claims}}{{id}},{{amount}}
+{{/each}}`
+
+func TestReportValid(t *testing.T) {
+	report := Report{
+		Name:     "claims",
+		Schedule: "@daily",
+		Query:    claimsQuery,
+		Template: claimsTemplate,
+		Format:   ReportCSV,
+		Store:    reportStore{},
+	}
+	cfg := DefaultConfig()
+	cfg.AddReport(report)
+	require.NoError(t, cfg.Valid())
+
+	for _, modify := range []func(r *Report){
+		func(r *Report) { r.Name = "a/b" },
+		func(r *Report) { r.Schedule = "daily" },
+		func(r *Report) { r.Template = "{{#each}}" },
+		func(r *Report) { r.Format = "xlsx" },
+		func(r *Report) { r.Store = nil },
+		func(r *Report) { r.Query = nil },
+		func(r *Report) { r.Recipients = []string{"ops@example.com"} },
+	} {
+		r := report
+		modify(&r)
+		cfg := DefaultConfig()
+		cfg.AddReport(r)
+		require.Error(t, cfg.Valid())
+	}
+
+	cfg.AddReport(report)
+	require.Error(t, cfg.Valid(), "duplicate name")
+}
+
+func TestRunReport(t *testing.T) {
+	store := reportStore{}
+	mail := mailer.NewCaptureMailer("reports@example.com")
+	cfg := DefaultConfig()
+	cfg.SetReportMailer(mail)
+	cfg.AddReport(Report{
+		Name:       "claims",
+		Schedule:   "@daily",
+		Query:      claimsQuery,
+		Template:   claimsTemplate,
+		Format:     ReportCSV,
+		Store:      store,
+		KeyPrefix:  "reports/",
+		Recipients: []string{"ops@example.com"},
+	})
+	require.NoError(t, cfg.Valid())
+	orc := newTestOracle(t, cfg)
+	r, err := cfg.reports[0].compile()
+	require.NoError(t, err)
+
+	run := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	require.NoError(t, orc.runReport(context.Background(), r, run))
+	require.Equal(t, "id,amount\nc1,10\nc2,20\n", string(store["reports/claims/20240301T000000Z.csv"]))
+	msg, ok := mail.Last()
+	require.True(t, ok)
+	require.Equal(t, "claims", msg.Subject)
+	require.Contains(t, msg.Content, "reports/claims/20240301T000000Z.csv")
+	require.Equal(t, float64(1), testutil.ToFloat64(reportRunsTotal.WithLabelValues("claims", "success")))
+
+	r.Format = ReportPDF
+	require.NoError(t, orc.runReport(context.Background(), r, run))
+	doc := store["reports/claims/20240301T000000Z.pdf"]
+	require.True(t, bytes.HasPrefix(doc, []byte("%PDF-")))
+	require.Contains(t, string(doc), "(c2,20) '")
+
+	r.Query = func(ctx context.Context, orc *Oracle, run time.Time) (interface{}, error) {
+		return nil, errors.New("phylum unavailable")
+	}
+	require.Error(t, orc.runReport(context.Background(), r, run))
+	require.Equal(t, float64(1), testutil.ToFloat64(reportRunsTotal.WithLabelValues("claims", "failure")))
+}
+
+func TestTextPDFPages(t *testing.T) {
+	text := bytes.Repeat([]byte("line (with) \\ escapes\n"), pdfLinesPerPage+1)
+	doc := string(textPDF(string(text)))
+	require.Contains(t, doc, "/Count 2")
+	require.Contains(t, doc, `(line \(with\) \\ escapes) '`)
+}
@@ -0,0 +1,71 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package oracle
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/luthersystems/svc/grpclogging"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var httpRequestDuration = prometheus.NewHistogramVec(
+	prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "Duration of HTTP requests served by the oracle, partitioned by method and status code.",
+		Buckets: prometheus.DefBuckets,
+	},
+	[]string{"method", "code"},
+)
+
+// requestDurationMiddleware times HTTP requests, if MetricsExemplars is
+// set.  The gateway serves requests before the gRPC server span starts, so
+// the exemplar is the trace of the incoming W3C trace context, if sampled.
+func (orc *Oracle) requestDurationMiddleware(next http.Handler) http.Handler {
+	if !orc.cfg.MetricsExemplars {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		sw := &statusWriter{ResponseWriter: w, code: http.StatusOK}
+		next.ServeHTTP(sw, r)
+		ctx := r.Context()
+		if !trace.SpanContextFromContext(ctx).IsValid() {
+			ctx = propagation.TraceContext{}.Extract(ctx, propagation.HeaderCarrier(r.Header))
+		}
+		obs := httpRequestDuration.WithLabelValues(r.Method, strconv.Itoa(sw.code))
+		grpclogging.ObserveWithExemplar(ctx, obs, time.Since(start).Seconds())
+	})
+}
+
+// statusWriter records the status code of a response.
+type statusWriter struct {
+	http.ResponseWriter
+	code        int
+	wroteHeader bool
+}
+
+func (w *statusWriter) WriteHeader(code int) {
+	if !w.wroteHeader {
+		w.code = code
+		w.wroteHeader = true
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush implements http.Flusher.
+func (w *statusWriter) Flush() {
+	w.wroteHeader = true
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
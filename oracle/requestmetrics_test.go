@@ -0,0 +1,61 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package oracle
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestDurationExemplars(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	cfg := DefaultConfig()
+	cfg.MetricsRegisterer = reg
+	cfg.MetricsExemplars = true
+	orc := newTestOracle(t, cfg)
+	require.NoError(t, orc.registerMetrics())
+
+	app := orc.requestDurationMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	}))
+	r := httptest.NewRequest(http.MethodPatch, "/v1/foo", nil)
+	r.Header.Set("traceparent", "00-0102030405060708090a0b0c0d0e0f10-0102030405060708-01")
+	app.ServeHTTP(httptest.NewRecorder(), r)
+
+	mfs, err := reg.Gather()
+	require.NoError(t, err)
+	var found bool
+	for _, mf := range mfs {
+		if mf.GetName() != "http_request_duration_seconds" {
+			continue
+		}
+		for _, m := range mf.GetMetric() {
+			labels := map[string]string{}
+			for _, l := range m.GetLabel() {
+				labels[l.GetName()] = l.GetValue()
+			}
+			if labels["method"] != http.MethodPatch || labels["code"] != "418" {
+				continue
+			}
+			for _, b := range m.GetHistogram().GetBucket() {
+				if ex := b.GetExemplar(); ex != nil {
+					require.Equal(t, "0102030405060708090a0b0c0d0e0f10", ex.GetLabel()[0].GetValue())
+					found = true
+				}
+			}
+		}
+	}
+	require.True(t, found, "missing exemplar")
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, metricsPath, nil)
+	req.Header.Set("Accept", "application/openmetrics-text; version=1.0.0")
+	orc.cfg.metricsHandler().ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Contains(t, rr.Header().Get("Content-Type"), "application/openmetrics-text")
+	require.Contains(t, rr.Body.String(), `# {trace_id="0102030405060708090a0b0c0d0e0f10"}`)
+}
@@ -0,0 +1,187 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package oracle
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxScheduleSearch bounds the search for the next time matching a cron
+// schedule, which never matches dates such as 30 February.
+const maxScheduleSearch = 5 * 366 * 24 * time.Hour
+
+// schedule computes the times a scheduled job runs.
+type schedule interface {
+	// next returns the first run time strictly after t.
+	next(t time.Time) (time.Time, bool)
+}
+
+// runSchedule calls fn with each time of s until ctx is done.  It returns
+// false if the schedule has no future runs.
+func runSchedule(ctx context.Context, s schedule, fn func(run time.Time)) bool {
+	for {
+		next, ok := s.next(time.Now())
+		if !ok {
+			return false
+		}
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return true
+		case <-timer.C:
+		}
+		fn(next)
+	}
+}
+
+// everySchedule runs at a fixed interval.
+type everySchedule time.Duration
+
+func (s everySchedule) next(t time.Time) (time.Time, bool) {
+	return t.Add(time.Duration(s)), true
+}
+
+// cronSchedule runs at the times matching a cron expression, in UTC.
+type cronSchedule struct {
+	minute, hour, dom, month, dow uint64
+	// domStar and dowStar record unrestricted fields, which follow the
+	// cron rule that a day matches either restricted day field.
+	domStar, dowStar bool
+}
+
+// parseSchedule parses a standard five field cron expression (minute hour
+// day-of-month month day-of-week, evaluated in UTC), a shorthand of
+// @hourly, @daily, @weekly or @monthly, or "@every <duration>".
+func parseSchedule(spec string) (schedule, error) {
+	spec = strings.TrimSpace(spec)
+	switch spec {
+	case "@hourly":
+		spec = "0 * * * *"
+	case "@daily", "@midnight":
+		spec = "0 0 * * *"
+	case "@weekly":
+		spec = "0 0 * * 0"
+	case "@monthly":
+		spec = "0 0 1 * *"
+	}
+	if d, ok := strings.CutPrefix(spec, "@every "); ok {
+		every, err := time.ParseDuration(strings.TrimSpace(d))
+		if err != nil {
+			return nil, fmt.Errorf("schedule %q: %w", spec, err)
+		}
+		if every < time.Minute {
+			return nil, fmt.Errorf("schedule %q: interval under a minute", spec)
+		}
+		return everySchedule(every), nil
+	}
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("schedule %q: expected 5 fields", spec)
+	}
+	s := &cronSchedule{
+		domStar: fields[2] == "*",
+		dowStar: fields[4] == "*",
+	}
+	var err error
+	for i, f := range []struct {
+		bits     *uint64
+		min, max int
+	}{
+		{&s.minute, 0, 59},
+		{&s.hour, 0, 23},
+		{&s.dom, 1, 31},
+		{&s.month, 1, 12},
+		{&s.dow, 0, 7},
+	} {
+		*f.bits, err = parseCronField(fields[i], f.min, f.max)
+		if err != nil {
+			return nil, fmt.Errorf("schedule %q: %w", spec, err)
+		}
+	}
+	// Sunday is both 0 and 7.
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1
+	}
+	return s, nil
+}
+
+// parseCronField parses a comma separated list of values, ranges (a-b),
+// and steps (*/n or a-b/n) into a bit set.
+func parseCronField(field string, min, max int) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		rng, stepStr, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			var err error
+			step, err = strconv.Atoi(stepStr)
+			if err != nil || step < 1 {
+				return 0, fmt.Errorf("invalid step %q", part)
+			}
+		}
+		lo, hi := min, max
+		if rng != "*" {
+			loStr, hiStr, isRange := strings.Cut(rng, "-")
+			var err error
+			lo, err = strconv.Atoi(loStr)
+			if err != nil {
+				return 0, fmt.Errorf("invalid value %q", part)
+			}
+			hi = lo
+			if isRange {
+				hi, err = strconv.Atoi(hiStr)
+				if err != nil {
+					return 0, fmt.Errorf("invalid value %q", part)
+				}
+			} else if hasStep {
+				hi = max
+			}
+		}
+		if lo < min || hi > max || lo > hi {
+			return 0, fmt.Errorf("value out of range %q", part)
+		}
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+func (s *cronSchedule) next(t time.Time) (time.Time, bool) {
+	t = t.UTC().Truncate(time.Minute).Add(time.Minute)
+	limit := t.Add(maxScheduleSearch)
+	for t.Before(limit) {
+		if s.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+			continue
+		}
+		if !s.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, time.UTC)
+			continue
+		}
+		if s.hour&(1<<uint(t.Hour())) == 0 {
+			t = t.Truncate(time.Hour).Add(time.Hour)
+			continue
+		}
+		if s.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t, true
+	}
+	return time.Time{}, false
+}
+
+func (s *cronSchedule) dayMatches(t time.Time) bool {
+	dom := s.dom&(1<<uint(t.Day())) != 0
+	dow := s.dow&(1<<uint(t.Weekday())) != 0
+	if s.domStar || s.dowStar {
+		return dom && dow
+	}
+	return dom || dow
+}
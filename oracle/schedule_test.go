@@ -0,0 +1,49 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package oracle
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSchedule(t *testing.T) {
+	start := time.Date(2024, 3, 1, 10, 30, 15, 0, time.UTC) // Friday
+	var tests = []struct {
+		spec string
+		want time.Time
+	}{
+		{"@hourly", time.Date(2024, 3, 1, 11, 0, 0, 0, time.UTC)},
+		{"@daily", time.Date(2024, 3, 2, 0, 0, 0, 0, time.UTC)},
+		{"@weekly", time.Date(2024, 3, 3, 0, 0, 0, 0, time.UTC)},
+		{"@monthly", time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)},
+		{"@every 2h", start.Add(2 * time.Hour)},
+		{"*/15 * * * *", time.Date(2024, 3, 1, 10, 45, 0, 0, time.UTC)},
+		{"0 9-17 * * 1-5", time.Date(2024, 3, 1, 11, 0, 0, 0, time.UTC)},
+		{"0 8 * * 1", time.Date(2024, 3, 4, 8, 0, 0, 0, time.UTC)},
+		{"0 8 * * 7", time.Date(2024, 3, 3, 8, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"30 6 1,15 * *", time.Date(2024, 3, 15, 6, 30, 0, 0, time.UTC)},
+		// Either restricted day field matches.
+		{"0 0 15 * 6", time.Date(2024, 3, 2, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			s, err := parseSchedule(tt.spec)
+			require.NoError(t, err)
+			next, ok := s.next(start)
+			require.True(t, ok)
+			require.Equal(t, tt.want, next)
+		})
+	}
+	for _, spec := range []string{"", "* * * *", "60 * * * *", "* * * * 8", "*/0 * * * *", "5-1 * * * *", "@every 1s", "@every x"} {
+		_, err := parseSchedule(spec)
+		require.Error(t, err, spec)
+	}
+	s, err := parseSchedule("0 0 30 2 *")
+	require.NoError(t, err)
+	_, ok := s.next(start)
+	require.False(t, ok)
+}
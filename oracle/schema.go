@@ -0,0 +1,294 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package oracle
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+)
+
+const (
+	// schemazPath is used to serve the schemas of the registered methods on
+	// the metrics server.
+	// IMPORTANT: this should not be accessible externally
+	schemazPath = "/schemaz"
+
+	// httpRuleExtension is the method option holding the HTTP binding of a
+	// method.
+	httpRuleExtension = "google.api.http"
+)
+
+// Schema describes gRPC methods and the messages they exchange, derived from
+// the registered proto descriptors.  It lets tooling generate forms and
+// mocks without the proto files.
+type Schema struct {
+	// Methods are the described methods, ordered by name.
+	Methods []*MethodSchema `json:"methods"`
+	// Messages are the messages referenced by the methods, including
+	// nested messages, by full name.
+	Messages map[string]*MessageSchema `json:"messages"`
+}
+
+// MethodSchema describes a gRPC method.
+type MethodSchema struct {
+	// Method is the full gRPC method name, e.g. "/pkg.v1.FooService/GetFoo".
+	Method string `json:"method"`
+	// Request is the full name of the request message.
+	Request string `json:"request"`
+	// Response is the full name of the response message.
+	Response string `json:"response"`
+	// ClientStreaming is true for client streaming methods.
+	ClientStreaming bool `json:"client_streaming,omitempty"`
+	// ServerStreaming is true for server streaming methods.
+	ServerStreaming bool `json:"server_streaming,omitempty"`
+	// HTTP are the HTTP bindings of the method exposed by the gateway.
+	HTTP []*HTTPBinding `json:"http,omitempty"`
+}
+
+// HTTPBinding is an HTTP binding of a method.
+type HTTPBinding struct {
+	// Method is the HTTP method, e.g. "GET".
+	Method string `json:"method"`
+	// Path is the path template, e.g. "/v1/foo/{id}".
+	Path string `json:"path"`
+	// Body is the request field mapped to the request body, "*" for the
+	// whole request.
+	Body string `json:"body,omitempty"`
+}
+
+// MessageSchema describes a message.
+type MessageSchema struct {
+	// Fields are the message fields, in declaration order.
+	Fields []*FieldSchema `json:"fields"`
+}
+
+// FieldSchema describes a message field.
+type FieldSchema struct {
+	// Name is the proto field name.
+	Name string `json:"name"`
+	// JSONName is the field name in JSON requests and responses.
+	JSONName string `json:"json_name"`
+	// Number is the field number.
+	Number int32 `json:"number"`
+	// Type is the field type, e.g. "string", "int64", "enum" or
+	// "message".  Map fields have the type of their values.
+	Type string `json:"type"`
+	// Repeated is true for repeated fields.
+	Repeated bool `json:"repeated,omitempty"`
+	// MapKey is the key type of map fields.
+	MapKey string `json:"map_key,omitempty"`
+	// Optional is true for fields with explicit presence.
+	Optional bool `json:"optional,omitempty"`
+	// Oneof is the name of the oneof containing the field.
+	Oneof string `json:"oneof,omitempty"`
+	// Message is the full name of the message type of message fields.
+	Message string `json:"message,omitempty"`
+	// Enum are the value names of enum fields.
+	Enum []string `json:"enum,omitempty"`
+}
+
+// DescribeServices returns the schema of the methods of gRPC services,
+// identified by their full names, from the global proto registry.  It may
+// be called by code generators as well as served by the oracle.
+func DescribeServices(services ...string) (*Schema, error) {
+	schema := &Schema{
+		Methods:  []*MethodSchema{},
+		Messages: make(map[string]*MessageSchema),
+	}
+	for _, name := range services {
+		d, err := protoregistry.GlobalFiles.FindDescriptorByName(protoreflect.FullName(name))
+		if err != nil {
+			return nil, fmt.Errorf("describe service %s: %w", name, err)
+		}
+		sd, ok := d.(protoreflect.ServiceDescriptor)
+		if !ok {
+			return nil, fmt.Errorf("describe service %s: not a service", name)
+		}
+		methods := sd.Methods()
+		for i := 0; i < methods.Len(); i++ {
+			schema.addMethod(sd, methods.Get(i))
+		}
+	}
+	sort.Slice(schema.Methods, func(i, j int) bool {
+		return schema.Methods[i].Method < schema.Methods[j].Method
+	})
+	return schema, nil
+}
+
+// addMethod adds a method and its messages to the schema.
+func (s *Schema) addMethod(sd protoreflect.ServiceDescriptor, md protoreflect.MethodDescriptor) {
+	s.Methods = append(s.Methods, &MethodSchema{
+		Method:          fmt.Sprintf("/%s/%s", sd.FullName(), md.Name()),
+		Request:         string(md.Input().FullName()),
+		Response:        string(md.Output().FullName()),
+		ClientStreaming: md.IsStreamingClient(),
+		ServerStreaming: md.IsStreamingServer(),
+		HTTP:            httpBindings(md),
+	})
+	s.addMessage(md.Input())
+	s.addMessage(md.Output())
+}
+
+// addMessage adds a message and the messages it references to the schema.
+func (s *Schema) addMessage(msg protoreflect.MessageDescriptor) {
+	name := string(msg.FullName())
+	if _, ok := s.Messages[name]; ok {
+		return
+	}
+	ms := &MessageSchema{Fields: []*FieldSchema{}}
+	s.Messages[name] = ms
+	fields := msg.Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		f := &FieldSchema{
+			Name:     string(fd.Name()),
+			JSONName: fd.JSONName(),
+			Number:   int32(fd.Number()),
+			Repeated: fd.IsList(),
+			Optional: fd.HasOptionalKeyword(),
+		}
+		if oneof := fd.ContainingOneof(); oneof != nil && !oneof.IsSynthetic() {
+			f.Oneof = string(oneof.Name())
+		}
+		value := fd
+		if fd.IsMap() {
+			f.MapKey = fd.MapKey().Kind().String()
+			value = fd.MapValue()
+		}
+		f.Type = value.Kind().String()
+		switch {
+		case value.Message() != nil:
+			f.Type = "message"
+			f.Message = string(value.Message().FullName())
+			s.addMessage(value.Message())
+		case value.Enum() != nil:
+			values := value.Enum().Values()
+			for j := 0; j < values.Len(); j++ {
+				f.Enum = append(f.Enum, string(values.Get(j).Name()))
+			}
+		}
+		ms.Fields = append(ms.Fields, f)
+	}
+}
+
+// httpBindings returns the google.api.http bindings of a method, if the
+// annotations are registered.
+func httpBindings(md protoreflect.MethodDescriptor) []*HTTPBinding {
+	xt, err := protoregistry.GlobalTypes.FindExtensionByName(httpRuleExtension)
+	if err != nil || md.Options() == nil {
+		return nil
+	}
+	opts := md.Options().ProtoReflect()
+	if !opts.Has(xt.TypeDescriptor()) {
+		return nil
+	}
+	rule := opts.Get(xt.TypeDescriptor()).Message()
+	bindings := []*HTTPBinding{}
+	addRule := func(rule protoreflect.Message) {
+		fields := rule.Descriptor().Fields()
+		b := &HTTPBinding{Body: rule.Get(fields.ByName("body")).String()}
+		for _, method := range []string{"get", "put", "post", "delete", "patch"} {
+			if fd := fields.ByName(protoreflect.Name(method)); rule.Has(fd) {
+				b.Method, b.Path = httpMethods[method], rule.Get(fd).String()
+			}
+		}
+		if fd := fields.ByName("custom"); rule.Has(fd) {
+			custom := rule.Get(fd).Message()
+			b.Method = custom.Get(custom.Descriptor().Fields().ByName("kind")).String()
+			b.Path = custom.Get(custom.Descriptor().Fields().ByName("path")).String()
+		}
+		if b.Path != "" {
+			bindings = append(bindings, b)
+		}
+	}
+	addRule(rule)
+	additional := rule.Get(rule.Descriptor().Fields().ByName("additional_bindings")).List()
+	for i := 0; i < additional.Len(); i++ {
+		addRule(additional.Get(i).Message())
+	}
+	return bindings
+}
+
+// httpMethods are the HTTP methods of google.api.HttpRule patterns.
+var httpMethods = map[string]string{
+	"get":    http.MethodGet,
+	"put":    http.MethodPut,
+	"post":   http.MethodPost,
+	"delete": http.MethodDelete,
+	"patch":  http.MethodPatch,
+}
+
+// setServices records the gRPC services registered with the server.
+func (orc *Oracle) setServices(server *grpc.Server) {
+	services := make([]string, 0)
+	for name := range server.GetServiceInfo() {
+		services = append(services, name)
+	}
+	sort.Strings(services)
+	orc.services = services
+}
+
+// schemazHandler serves the schema of the registered methods as JSON.  The
+// method query parameter restricts the schema to a single method and the
+// messages it references.
+func (orc *Oracle) schemazHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		schema, err := DescribeServices(orc.services...)
+		if err != nil {
+			orc.log(r.Context()).WithError(err).Errorf("schemaz")
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if method := r.URL.Query().Get("method"); method != "" {
+			if schema = schema.method(method); schema == nil {
+				http.NotFound(w, r)
+				return
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(schema); err != nil {
+			orc.log(r.Context()).WithError(err).Errorf("schemaz response error")
+		}
+	})
+}
+
+// method returns the schema of a single method, or nil if the method is not
+// described.
+func (s *Schema) method(name string) *Schema {
+	for _, m := range s.Methods {
+		if m.Method != name {
+			continue
+		}
+		sub := &Schema{
+			Methods:  []*MethodSchema{m},
+			Messages: make(map[string]*MessageSchema),
+		}
+		sub.addReferenced(s, m.Request)
+		sub.addReferenced(s, m.Response)
+		return sub
+	}
+	return nil
+}
+
+// addReferenced copies a message and the messages it references from src.
+func (s *Schema) addReferenced(src *Schema, name string) {
+	ms, ok := src.Messages[name]
+	if !ok {
+		return
+	}
+	if _, ok := s.Messages[name]; ok {
+		return
+	}
+	s.Messages[name] = ms
+	for _, f := range ms.Fields {
+		if f.Message != "" {
+			s.addReferenced(src, f.Message)
+		}
+	}
+}
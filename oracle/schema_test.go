@@ -0,0 +1,61 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package oracle
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDescribeServices(t *testing.T) {
+	jsonErrorRequest(t)
+	schema, err := DescribeServices("oracle.jsontest.OrderService")
+	require.NoError(t, err)
+	require.Len(t, schema.Methods, 1)
+	m := schema.Methods[0]
+	require.Equal(t, jsonErrorMethod, m.Method)
+	require.Equal(t, "oracle.jsontest.CreateOrderRequest", m.Request)
+	require.Empty(t, m.HTTP)
+
+	req := schema.Messages[m.Request]
+	require.NotNil(t, req)
+	require.Len(t, req.Fields, 2)
+	require.Equal(t, &FieldSchema{Name: "order", JSONName: "order", Number: 1, Type: "message", Message: "oracle.jsontest.Order"}, req.Fields[0])
+	require.Equal(t, "google.protobuf.Timestamp", req.Fields[1].Message)
+	require.Contains(t, schema.Messages, "google.protobuf.Timestamp")
+
+	order := schema.Messages["oracle.jsontest.Order"]
+	require.NotNil(t, order)
+	require.Equal(t, "enum", order.Fields[0].Type)
+	require.Equal(t, []string{"ORDER_STATUS_UNSPECIFIED", "ORDER_STATUS_OPEN"}, order.Fields[0].Enum)
+
+	_, err = DescribeServices("oracle.jsontest.Missing")
+	require.Error(t, err)
+	_, err = DescribeServices("oracle.jsontest.Order")
+	require.Error(t, err)
+}
+
+func TestSchemazHandler(t *testing.T) {
+	jsonErrorRequest(t)
+	orc := newTestOracle(t, DefaultConfig())
+	orc.services = []string{"oracle.jsontest.OrderService"}
+	get := func(target string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		orc.schemazHandler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+		return w
+	}
+
+	w := get(schemazPath + "?method=" + jsonErrorMethod)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	var schema Schema
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &schema))
+	require.Len(t, schema.Methods, 1)
+	require.Len(t, schema.Messages, 3)
+
+	require.Equal(t, http.StatusNotFound, get(schemazPath+"?method=/oracle.jsontest.OrderService/Missing").Code)
+}
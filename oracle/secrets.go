@@ -0,0 +1,240 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package oracle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultSecretResolveTimeout bounds the resolution of the secret references
+// of the config at startup.
+const defaultSecretResolveTimeout = 30 * time.Second
+
+// SecretResolver resolves references to secrets held in a secret store,
+// e.g. AWS Secrets Manager, SSM Parameter Store or Azure Key Vault.
+type SecretResolver interface {
+	// ResolveSecret returns the value of the secret referenced by ref,
+	// e.g. "aws-sm://name" or "azkv://vault/secret".
+	ResolveSecret(ctx context.Context, ref string) (string, error)
+}
+
+// SecretResolverFunc adapts a function to the SecretResolver interface.
+type SecretResolverFunc func(ctx context.Context, ref string) (string, error)
+
+// ResolveSecret implements SecretResolver.
+func (f SecretResolverFunc) ResolveSecret(ctx context.Context, ref string) (string, error) {
+	return f(ctx, ref)
+}
+
+// SecretRotationHook is called when the value of a secret reference changes
+// on refresh.
+type SecretRotationHook func(ctx context.Context, ref string, value string)
+
+// AddSecretResolver resolves config values of the form "<scheme>://..." with
+// r.  Config fields tagged with `secret:"true"`, such as API keys, the admin
+// token and webhook secrets, may hold such references instead of the
+// secrets themselves; they are resolved when the oracle is created, which
+// fails if a reference cannot be resolved.  Values of other schemes are
+// used as is.
+func (c *Config) AddSecretResolver(scheme string, r SecretResolver) {
+	if c == nil {
+		return
+	}
+	if c.secretResolvers == nil {
+		c.secretResolvers = make(map[string]SecretResolver)
+	}
+	c.secretResolvers[scheme] = r
+}
+
+// AddSecretRotationHook calls fn when a secret reference resolves to a new
+// value on refresh; see SecretRefreshInterval.  Config values are resolved
+// once, so the hook is responsible for applying rotated secrets.
+func (c *Config) AddSecretRotationHook(fn SecretRotationHook) {
+	if c == nil {
+		return
+	}
+	c.secretRotationHooks = append(c.secretRotationHooks, fn)
+}
+
+// validSecrets validates the secret resolution configuration.
+func (c *Config) validSecrets() error {
+	for scheme, r := range c.secretResolvers {
+		if scheme == "" || strings.Contains(scheme, "://") {
+			return fmt.Errorf("secret resolver: invalid scheme %q", scheme)
+		}
+		if r == nil {
+			return fmt.Errorf("secret resolver %s: missing resolver", scheme)
+		}
+	}
+	if c.SecretRefreshInterval < 0 {
+		return fmt.Errorf("negative secret refresh interval")
+	}
+	return nil
+}
+
+// secretCache caches resolved secret references.
+type secretCache struct {
+	resolvers map[string]SecretResolver
+	hooks     []SecretRotationHook
+
+	mut    sync.Mutex
+	values map[string]string
+}
+
+func newSecretCache(cfg *Config) *secretCache {
+	return &secretCache{
+		resolvers: cfg.secretResolvers,
+		hooks:     cfg.secretRotationHooks,
+		values:    make(map[string]string),
+	}
+}
+
+// resolver returns the resolver of ref, or nil if ref is not a reference.
+func (s *secretCache) resolver(ref string) SecretResolver {
+	scheme, _, ok := strings.Cut(ref, "://")
+	if !ok {
+		return nil
+	}
+	return s.resolvers[scheme]
+}
+
+// resolve returns the value of ref, resolving it on first use.  Values
+// which are not references are returned as is.
+func (s *secretCache) resolve(ctx context.Context, ref string) (string, error) {
+	r := s.resolver(ref)
+	if r == nil {
+		return ref, nil
+	}
+	s.mut.Lock()
+	v, ok := s.values[ref]
+	s.mut.Unlock()
+	if ok {
+		return v, nil
+	}
+	v, err := r.ResolveSecret(ctx, ref)
+	if err != nil {
+		return "", fmt.Errorf("resolve secret %s: %w", ref, err)
+	}
+	s.mut.Lock()
+	s.values[ref] = v
+	s.mut.Unlock()
+	return v, nil
+}
+
+// refresh resolves the cached references again, calling the rotation hooks
+// for those whose value changed.  References which fail to resolve keep
+// their cached value.
+func (s *secretCache) refresh(ctx context.Context) error {
+	s.mut.Lock()
+	refs := make([]string, 0, len(s.values))
+	for ref := range s.values {
+		refs = append(refs, ref)
+	}
+	s.mut.Unlock()
+	var errs []error
+	for _, ref := range refs {
+		v, err := s.resolver(ref).ResolveSecret(ctx, ref)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("resolve secret %s: %w", ref, err))
+			continue
+		}
+		s.mut.Lock()
+		changed := s.values[ref] != v
+		s.values[ref] = v
+		s.mut.Unlock()
+		if changed {
+			for _, hook := range s.hooks {
+				hook(ctx, ref, v)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// resolveSecrets replaces the secret references held by the fields of cfg
+// tagged with `secret:"true"`.  Slices are copied before their elements are
+// resolved so the config cfg was copied from is not modified.
+func (s *secretCache) resolveSecrets(ctx context.Context, cfg *Config) error {
+	if len(s.resolvers) == 0 {
+		return nil
+	}
+	return s.resolveStruct(ctx, reflect.ValueOf(cfg).Elem())
+}
+
+func (s *secretCache) resolveStruct(ctx context.Context, v reflect.Value) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := v.Field(i)
+		if !t.Field(i).IsExported() {
+			continue
+		}
+		switch f.Kind() {
+		case reflect.String:
+			if t.Field(i).Tag.Get("secret") != "true" || f.String() == "" {
+				continue
+			}
+			val, err := s.resolve(ctx, f.String())
+			if err != nil {
+				return err
+			}
+			f.SetString(val)
+		case reflect.Struct:
+			if err := s.resolveStruct(ctx, f); err != nil {
+				return err
+			}
+		case reflect.Slice:
+			if f.Len() == 0 || f.Type().Elem().Kind() != reflect.Struct {
+				continue
+			}
+			cp := reflect.MakeSlice(f.Type(), f.Len(), f.Len())
+			reflect.Copy(cp, f)
+			for j := 0; j < cp.Len(); j++ {
+				if err := s.resolveStruct(ctx, cp.Index(j)); err != nil {
+					return err
+				}
+			}
+			f.Set(cp)
+		}
+	}
+	return nil
+}
+
+// Secret returns the value of a secret reference, resolved with the
+// configured SecretResolver and cached.  Values which are not references of
+// a configured scheme are returned as is.
+func (orc *Oracle) Secret(ctx context.Context, ref string) (string, error) {
+	return orc.secrets.resolve(ctx, ref)
+}
+
+// RefreshSecrets resolves the secret references used so far again, and
+// calls the rotation hooks of those whose value changed.
+func (orc *Oracle) RefreshSecrets(ctx context.Context) error {
+	return orc.secrets.refresh(ctx)
+}
+
+// runSecretRefresh refreshes secrets every SecretRefreshInterval until ctx
+// is done.
+func (orc *Oracle) runSecretRefresh(ctx context.Context) {
+	interval := orc.cfg.SecretRefreshInterval
+	if interval <= 0 || len(orc.cfg.secretResolvers) == 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if err := orc.RefreshSecrets(ctx); err != nil {
+			orc.log(ctx).WithError(err).Warnf("secret refresh failed")
+		}
+	}
+}
@@ -0,0 +1,92 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package oracle
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// memSecrets is an in-memory secret store.
+type memSecrets struct {
+	mut     sync.Mutex
+	secrets map[string]string
+	calls   int
+}
+
+func (m *memSecrets) ResolveSecret(ctx context.Context, ref string) (string, error) {
+	m.mut.Lock()
+	defer m.mut.Unlock()
+	m.calls++
+	v, ok := m.secrets[ref]
+	if !ok {
+		return "", fmt.Errorf("not found")
+	}
+	return v, nil
+}
+
+func (m *memSecrets) set(ref string, v string) {
+	m.mut.Lock()
+	defer m.mut.Unlock()
+	m.secrets[ref] = v
+}
+
+func TestSecretResolution(t *testing.T) {
+	store := &memSecrets{secrets: map[string]string{
+		"mem://api-key":        "key1",
+		"mem://webhook-secret": "whsec",
+	}}
+	cfg := DefaultConfig()
+	cfg.APIKeyHeader = "X-API-Key"
+	cfg.APIKeys = []APIKey{{Subject: "ci", Key: "mem://api-key"}}
+	cfg.Webhooks = []WebhookEndpoint{
+		{URL: "https://a.example", Secret: "mem://webhook-secret"},
+		{URL: "https://b.example", Secret: "mem://webhook-secret"},
+		{URL: "https://c.example", Secret: "plain"},
+	}
+	cfg.AddSecretResolver("mem", store)
+	var rotated []string
+	cfg.AddSecretRotationHook(func(ctx context.Context, ref string, value string) {
+		rotated = append(rotated, ref+"="+value)
+	})
+	orc := newTestOracle(t, cfg)
+
+	require.Contains(t, orc.apiKeys, sha256.Sum256([]byte("key1")))
+	require.Equal(t, "whsec", orc.cfg.Webhooks[0].Secret)
+	require.Equal(t, "whsec", orc.cfg.Webhooks[1].Secret)
+	require.Equal(t, "plain", orc.cfg.Webhooks[2].Secret)
+	// References are cached and the given config is not modified.
+	require.Equal(t, 2, store.calls)
+	require.Equal(t, "mem://api-key", cfg.APIKeys[0].Key)
+
+	v, err := orc.Secret(context.Background(), "mem://api-key")
+	require.NoError(t, err)
+	require.Equal(t, "key1", v)
+	v, err = orc.Secret(context.Background(), "https://a.example")
+	require.NoError(t, err)
+	require.Equal(t, "https://a.example", v)
+
+	store.set("mem://api-key", "key2")
+	require.NoError(t, orc.RefreshSecrets(context.Background()))
+	require.Equal(t, []string{"mem://api-key=key2"}, rotated)
+	v, err = orc.Secret(context.Background(), "mem://api-key")
+	require.NoError(t, err)
+	require.Equal(t, "key2", v)
+}
+
+func TestSecretResolutionError(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Webhooks = []WebhookEndpoint{{URL: "https://a.example", Secret: "mem://missing"}}
+	cfg.AddSecretResolver("mem", &memSecrets{secrets: map[string]string{}})
+	_, err := newOracle(cfg)
+	require.ErrorContains(t, err, "mem://missing")
+
+	cfg = DefaultConfig()
+	cfg.AddSecretResolver("mem://", &memSecrets{})
+	require.Error(t, cfg.Valid())
+}
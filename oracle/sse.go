@@ -0,0 +1,220 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package oracle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/luthersystems/svc/svcerr"
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc/metadata"
+)
+
+// defaultSSEHeartbeat is the default interval between heartbeat comments.
+const defaultSSEHeartbeat = 15 * time.Second
+
+var (
+	sseConnections = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "sse_connections",
+			Help: "Open server-sent event connections, partitioned by path.",
+		},
+		[]string{"path"},
+	)
+	sseEventsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "sse_events_total",
+			Help: "How many server-sent events were sent, partitioned by path.",
+		},
+		[]string{"path"},
+	)
+)
+
+// SSEEvent is a server-sent event.
+type SSEEvent struct {
+	// ID is the event ID, sent back by clients in the Last-Event-ID header
+	// when they reconnect.
+	ID string
+	// Event is the event type.  If empty clients dispatch a "message" event.
+	Event string
+	// Data is the event payload.
+	Data []byte
+}
+
+// EventSource streams events to a server-sent event connection by calling
+// send, until ctx is done or an error occurs.  lastEventID is the ID of the
+// last event received by a reconnecting client, or empty.  EventSource may
+// bridge a phylum event subscription or poll the phylum.
+type EventSource func(ctx context.Context, lastEventID string, send func(*SSEEvent) error) error
+
+// SSEAuthorizer authorizes a server-sent event connection given the claims of
+// the requesting user.
+type SSEAuthorizer func(ctx context.Context, claims map[string]interface{}) error
+
+// SSEOption configures a server-sent event endpoint.
+type SSEOption func(*sseEndpoint)
+
+// WithSSEHeartbeat sets the interval between heartbeat comments, which keep
+// idle connections open through proxies.  Defaults to 15 seconds.  A duration
+// of zero or less disables heartbeats.
+func WithSSEHeartbeat(d time.Duration) SSEOption {
+	return func(e *sseEndpoint) {
+		e.heartbeat = d
+	}
+}
+
+// WithSSEAuthorizer requires connections to present claims which are accepted
+// by fn.  The claims are obtained using the configured ClaimsGetter.
+func WithSSEAuthorizer(fn SSEAuthorizer) SSEOption {
+	return func(e *sseEndpoint) {
+		e.authorize = fn
+	}
+}
+
+type sseEndpoint struct {
+	source    EventSource
+	heartbeat time.Duration
+	authorize SSEAuthorizer
+}
+
+// AddSSEPath serves a server-sent event (text/event-stream) endpoint at path,
+// streaming events from source.
+func (c *Config) AddSSEPath(path string, source EventSource, opts ...SSEOption) {
+	if c == nil {
+		return
+	}
+	e := &sseEndpoint{source: source, heartbeat: defaultSSEHeartbeat}
+	for _, opt := range opts {
+		opt(e)
+	}
+	if c.ssePaths == nil {
+		c.ssePaths = make(map[string]*sseEndpoint)
+	}
+	c.ssePaths[path] = e
+}
+
+// httpMetadataContext returns a context carrying the forwarded headers of r
+// as incoming grpc metadata, as the grpc-gateway would, so that helpers such
+// as the ClaimsGetter may be used outside of grpc handlers.  Claims are
+// cached for the duration of the request.
+func (orc *Oracle) httpMetadataContext(r *http.Request) context.Context {
+	md := metadata.MD{}
+	policy := orc.cfg.HeaderForwarding
+	for _, h := range orc.gatewayForwardedHeaders() {
+		if vals := r.Header.Values(h); len(vals) > 0 && !policy.oversize(h, vals) {
+			md.Append(strings.ToLower(policy.metadataKey(h)), vals...)
+		}
+	}
+	return withClaimsCache(metadata.NewIncomingContext(r.Context(), md))
+}
+
+// writeSSEEvent writes ev in the text/event-stream format.
+func writeSSEEvent(w http.ResponseWriter, ev *SSEEvent) error {
+	var b strings.Builder
+	if ev.ID != "" {
+		fmt.Fprintf(&b, "id: %s\n", strings.ReplaceAll(ev.ID, "\n", ""))
+	}
+	if ev.Event != "" {
+		fmt.Fprintf(&b, "event: %s\n", strings.ReplaceAll(ev.Event, "\n", ""))
+	}
+	for _, line := range strings.Split(string(ev.Data), "\n") {
+		fmt.Fprintf(&b, "data: %s\n", line)
+	}
+	b.WriteString("\n")
+	_, err := w.Write([]byte(b.String()))
+	return err
+}
+
+func (orc *Oracle) sseHandler(path string, e *sseEndpoint) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := orc.httpMetadataContext(r)
+		if r.Method != http.MethodGet {
+			ex := svcerr.BusinessException(ctx, "method not allowed")
+			if err := writeExceptionHTTP(w, http.StatusMethodNotAllowed, ex); err != nil {
+				orc.log(ctx).WithError(err).Errorf("sse response error")
+			}
+			return
+		}
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			orc.log(ctx).Errorf("sse: response writer does not support flushing")
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if e.authorize != nil {
+			claims, err := orc.GetClaims(ctx)
+			if err == nil {
+				err = e.authorize(ctx, claims)
+			}
+			if err != nil {
+				orc.log(ctx).WithError(err).Infof("sse unauthorized")
+				ex := svcerr.SecurityException(ctx, "unauthorized")
+				if err := writeExceptionHTTP(w, http.StatusUnauthorized, ex); err != nil {
+					orc.log(ctx).WithError(err).Errorf("sse response error")
+				}
+				return
+			}
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+		flusher.Flush()
+
+		sseConnections.WithLabelValues(path).Inc()
+		defer sseConnections.WithLabelValues(path).Dec()
+
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		events := make(chan *SSEEvent)
+		errs := make(chan error, 1)
+		go func() {
+			errs <- e.source(ctx, r.Header.Get("Last-Event-ID"), func(ev *SSEEvent) error {
+				select {
+				case events <- ev:
+					return nil
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			})
+		}()
+
+		// A nil channel never receives, disabling heartbeats.
+		var heartbeat <-chan time.Time
+		if e.heartbeat > 0 {
+			ticker := time.NewTicker(e.heartbeat)
+			defer ticker.Stop()
+			heartbeat = ticker.C
+		}
+		for {
+			select {
+			case ev := <-events:
+				if err := writeSSEEvent(w, ev); err != nil {
+					orc.log(ctx).WithError(err).Debugf("sse write")
+					return
+				}
+				flusher.Flush()
+				sseEventsTotal.WithLabelValues(path).Inc()
+			case <-heartbeat:
+				if _, err := w.Write([]byte(": heartbeat\n\n")); err != nil {
+					orc.log(ctx).WithError(err).Debugf("sse heartbeat")
+					return
+				}
+				flusher.Flush()
+			case err := <-errs:
+				if err != nil && !errors.Is(err, context.Canceled) {
+					orc.log(ctx).WithError(err).Warnf("sse event source")
+				}
+				return
+			case <-ctx.Done():
+				return
+			}
+		}
+	})
+}
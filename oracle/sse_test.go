@@ -0,0 +1,64 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package oracle
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSSEHandler(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.AddSSEPath("/v1/events", func(ctx context.Context, lastEventID string, send func(*SSEEvent) error) error {
+		require.Equal(t, "1", lastEventID)
+		for i := 2; i <= 3; i++ {
+			err := send(&SSEEvent{ID: fmt.Sprint(i), Event: "update", Data: []byte("a\nb")})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	orc := newTestOracle(t, cfg)
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/events", nil)
+	req.Header.Set("Last-Event-ID", "1")
+	rr := httptest.NewRecorder()
+	orc.sseHandler("/v1/events", orc.cfg.ssePaths["/v1/events"]).ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Equal(t, "text/event-stream", rr.Header().Get("Content-Type"))
+	require.Equal(t, "id: 2\nevent: update\ndata: a\ndata: b\n\nid: 3\nevent: update\ndata: a\ndata: b\n\n", rr.Body.String())
+}
+
+func TestSSEHandlerUnauthorized(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.AddSSEPath("/v1/events", func(ctx context.Context, lastEventID string, send func(*SSEEvent) error) error {
+		t.Fatal("unexpected event source call")
+		return nil
+	}, WithSSEAuthorizer(func(ctx context.Context, claims map[string]interface{}) error {
+		return nil
+	}))
+	orc := newTestOracle(t, cfg)
+
+	rr := httptest.NewRecorder()
+	orc.sseHandler("/v1/events", orc.cfg.ssePaths["/v1/events"]).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/v1/events", nil))
+	require.Equal(t, http.StatusUnauthorized, rr.Code)
+}
+
+func TestSSEHandlerNoHeartbeat(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.AddSSEPath("/v1/events", func(ctx context.Context, lastEventID string, send func(*SSEEvent) error) error {
+		return send(&SSEEvent{Data: []byte("x")})
+	}, WithSSEHeartbeat(0))
+	orc := newTestOracle(t, cfg)
+
+	rr := httptest.NewRecorder()
+	orc.sseHandler("/v1/events", orc.cfg.ssePaths["/v1/events"]).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/v1/events", nil))
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Equal(t, "data: x\n\n", rr.Body.String())
+}
@@ -0,0 +1,131 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package oracle
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	startupInitialBackoff = 250 * time.Millisecond
+	startupMaxBackoff     = 10 * time.Second
+	startupCheckTimeout   = 5 * time.Second
+)
+
+// DependencyCheck returns an error if a dependency is not reachable.
+type DependencyCheck func(ctx context.Context) error
+
+type startupCheck struct {
+	name  string
+	check DependencyCheck
+}
+
+// AddStartupCheck registers a dependency which must be reachable before the
+// oracle accepts traffic.  Checks only run when StartupMaxWait is set.
+func (c *Config) AddStartupCheck(name string, check DependencyCheck) {
+	if c == nil {
+		return
+	}
+	c.startupChecks = append(c.startupChecks, startupCheck{name: name, check: check})
+}
+
+// validStartup validates the startup gate configuration.
+func (c *Config) validStartup() error {
+	if c.StartupMaxWait < 0 {
+		return fmt.Errorf("invalid startup max wait")
+	}
+	for _, sc := range c.startupChecks {
+		if sc.name == "" || sc.check == nil {
+			return fmt.Errorf("startup check: missing name or check")
+		}
+	}
+	return nil
+}
+
+// gatewayCheck checks the shiroclient gateway and phylum are healthy.
+func (orc *Oracle) gatewayCheck(ctx context.Context) error {
+	for _, report := range orc.phylumHealthCheck(ctx) {
+		if !strings.EqualFold(report.GetStatus(), "UP") {
+			return fmt.Errorf("%s is %s", report.GetServiceName(), report.GetStatus())
+		}
+	}
+	return nil
+}
+
+// httpCheck returns a check that url responds 200 to GET requests.
+func httpCheck(url string) DependencyCheck {
+	return func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("unexpected status: %d", resp.StatusCode)
+		}
+		return nil
+	}
+}
+
+// startupChecks returns the checks run by the startup gate.
+func (orc *Oracle) startupChecks() []startupCheck {
+	var checks []startupCheck
+	if !orc.cfg.EmulateCC {
+		checks = append(checks, startupCheck{name: "gateway", check: orc.gatewayCheck})
+	}
+	if orc.cfg.JWKSEndpoint != "" {
+		checks = append(checks, startupCheck{name: "jwks", check: httpCheck(orc.cfg.JWKSEndpoint)})
+	}
+	return append(checks, orc.cfg.startupChecks...)
+}
+
+// waitForDependencies blocks until all startup checks pass.  Failing checks
+// are retried with exponential backoff until StartupMaxWait elapses, unless
+// StartupFailFast is set.  The gate is disabled if StartupMaxWait is zero.
+func (orc *Oracle) waitForDependencies(ctx context.Context) error {
+	if orc.cfg.StartupMaxWait == 0 {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, orc.cfg.StartupMaxWait)
+	defer cancel()
+	for _, sc := range orc.startupChecks() {
+		backoff := startupInitialBackoff
+		for attempt := 1; ; attempt++ {
+			cctx, ccancel := context.WithTimeout(ctx, startupCheckTimeout)
+			err := sc.check(cctx)
+			ccancel()
+			if err == nil {
+				orc.log(ctx).WithField("dependency", sc.name).Infof("startup dependency ready")
+				break
+			}
+			log := orc.log(ctx).WithError(err).WithFields(logrus.Fields{
+				"dependency": sc.name,
+				"attempt":    attempt,
+			})
+			if orc.cfg.StartupFailFast {
+				return fmt.Errorf("startup dependency %s unavailable: %w", sc.name, err)
+			}
+			log.Warnf("startup dependency unavailable")
+			select {
+			case <-time.After(backoff):
+			case <-ctx.Done():
+				return fmt.Errorf("startup dependency %s unavailable after %v: %w", sc.name, orc.cfg.StartupMaxWait, err)
+			}
+			backoff *= 2
+			if backoff > startupMaxBackoff {
+				backoff = startupMaxBackoff
+			}
+		}
+	}
+	return nil
+}
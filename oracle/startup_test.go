@@ -0,0 +1,58 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package oracle
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWaitForDependencies(t *testing.T) {
+	jwks := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"keys":[]}`))
+	}))
+	defer jwks.Close()
+
+	calls := 0
+	cfg := DefaultConfig()
+	cfg.StartupMaxWait = 5 * time.Second
+	cfg.JWKSEndpoint = jwks.URL
+	cfg.AddStartupCheck("db", func(ctx context.Context) error {
+		calls++
+		if calls < 2 {
+			return errors.New("not ready")
+		}
+		return nil
+	})
+	orc := newTestOracle(t, cfg)
+	require.NoError(t, orc.waitForDependencies(context.Background()))
+	require.Equal(t, 2, calls)
+}
+
+func TestWaitForDependenciesFailFast(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.StartupMaxWait = time.Minute
+	cfg.StartupFailFast = true
+	cfg.AddStartupCheck("db", func(ctx context.Context) error {
+		return errors.New("not ready")
+	})
+	orc := newTestOracle(t, cfg)
+	err := orc.waitForDependencies(context.Background())
+	require.ErrorContains(t, err, "startup dependency db unavailable")
+}
+
+func TestWaitForDependenciesTimeout(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.StartupMaxWait = 100 * time.Millisecond
+	cfg.AddStartupCheck("db", func(ctx context.Context) error {
+		return errors.New("not ready")
+	})
+	orc := newTestOracle(t, cfg)
+	require.Error(t, orc.waitForDependencies(context.Background()))
+}
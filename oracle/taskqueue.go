@@ -0,0 +1,150 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package oracle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/luthersystems/svc/oracle/tasks"
+	"google.golang.org/protobuf/proto"
+)
+
+// ErrTasksDisabled is returned by EnqueueTask when no task queue is
+// configured.
+var ErrTasksDisabled = errors.New("task queue not configured")
+
+// TaskQueue configures a queue of asynchronous tasks, consumed by handlers
+// registered with AddTaskHandler while the oracle runs.  Tasks are queued
+// in SQS, or in memory when the phylum is emulated.
+type TaskQueue struct {
+	// SQSQueueURL is the URL of the SQS queue of tasks.
+	SQSQueueURL string `yaml:"sqs-queue-url"`
+	// SQSDeadLetterQueueURL is the URL of an SQS queue receiving tasks
+	// which failed permanently or exhausted their attempts.  Without it
+	// such tasks are logged and dropped.
+	SQSDeadLetterQueueURL string `yaml:"sqs-dead-letter-queue-url"`
+	// AWSRegion is the region of the SQS queues.
+	AWSRegion string `yaml:"aws-region"`
+	// Workers is the number of tasks handled concurrently.  Defaults to 4.
+	Workers int `yaml:"workers"`
+	// MaxAttempts is the number of attempts before a failing task is
+	// dead-lettered.  Defaults to 5.
+	MaxAttempts int `yaml:"max-attempts"`
+}
+
+// valid validates the task queue configuration.
+func (q TaskQueue) valid() error {
+	if q.Workers < 0 {
+		return fmt.Errorf("task queue: invalid workers")
+	}
+	if q.MaxAttempts < 0 {
+		return fmt.Errorf("task queue: invalid max attempts")
+	}
+	if q.SQSDeadLetterQueueURL != "" && q.SQSQueueURL == "" {
+		return fmt.Errorf("task queue: dead-letter queue requires sqs-queue-url")
+	}
+	return nil
+}
+
+// taskHandler handles tasks of the type of task.
+type taskHandler struct {
+	task    proto.Message
+	handler tasks.Handler
+}
+
+// AddTaskHandler registers the handler of queued tasks of the type of task;
+// see tasks.HandlerFunc to handle a concrete task type.
+func (c *Config) AddTaskHandler(task proto.Message, handler tasks.Handler) {
+	if c == nil {
+		return
+	}
+	c.taskHandlers = append(c.taskHandlers, taskHandler{task: task, handler: handler})
+}
+
+// tasksEnabled returns true if a task queue is configured.
+func (c *Config) tasksEnabled() bool {
+	return c.EmulateCC || c.TaskQueue.SQSQueueURL != ""
+}
+
+// initTasks creates the task queue client and runner.
+func (orc *Oracle) initTasks(ctx context.Context) error {
+	if !orc.cfg.tasksEnabled() {
+		return nil
+	}
+	var queue, deadLetter tasks.Queue
+	if orc.cfg.EmulateCC {
+		queue = tasks.NewMemoryQueue()
+	} else {
+		q, err := tasks.NewSQSQueue(ctx, orc.cfg.TaskQueue.AWSRegion, orc.cfg.TaskQueue.SQSQueueURL)
+		if err != nil {
+			return fmt.Errorf("task queue: %w", err)
+		}
+		queue = q
+		if orc.cfg.TaskQueue.SQSDeadLetterQueueURL != "" {
+			dlq, err := tasks.NewSQSQueue(ctx, orc.cfg.TaskQueue.AWSRegion, orc.cfg.TaskQueue.SQSDeadLetterQueueURL)
+			if err != nil {
+				return fmt.Errorf("task dead-letter queue: %w", err)
+			}
+			deadLetter = dlq
+		}
+	}
+	opts := []tasks.RunnerOption{
+		tasks.WithLogBase(orc.logBase),
+		tasks.WithTracer(orc.tracer),
+	}
+	if orc.cfg.TaskQueue.Workers > 0 {
+		opts = append(opts, tasks.WithWorkers(orc.cfg.TaskQueue.Workers))
+	}
+	if orc.cfg.TaskQueue.MaxAttempts > 0 {
+		opts = append(opts, tasks.WithMaxAttempts(orc.cfg.TaskQueue.MaxAttempts))
+	}
+	if deadLetter != nil {
+		opts = append(opts, tasks.WithDeadLetterQueue(deadLetter))
+	}
+	orc.taskQueue = queue
+	orc.taskClient = tasks.NewClient(queue)
+	orc.taskRunner = tasks.NewRunner(queue, opts...)
+	for _, h := range orc.cfg.taskHandlers {
+		orc.taskRunner.Handle(h.task, h.handler)
+	}
+	return nil
+}
+
+// runTasks consumes tasks until ctx is done.  The returned channel is
+// closed once running tasks have finished.
+func (orc *Oracle) runTasks(ctx context.Context) <-chan struct{} {
+	done := make(chan struct{})
+	if orc.taskRunner == nil || len(orc.cfg.taskHandlers) == 0 {
+		close(done)
+		return done
+	}
+	go func() {
+		defer close(done)
+		orc.log(ctx).Infof("task runner start")
+		if err := orc.taskRunner.Run(ctx); err != nil {
+			orc.log(ctx).WithError(err).Errorf("task runner failed")
+		}
+	}()
+	return done
+}
+
+// closeTasks releases the task queue.
+func (orc *Oracle) closeTasks() {
+	if q, ok := orc.taskQueue.(*tasks.MemoryQueue); ok {
+		q.Close()
+	}
+}
+
+// EnqueueTask enqueues a task to be handled asynchronously by the handler
+// registered for its type with AddTaskHandler, e.g. to offload long-running
+// work from a request handler.  The task handler context carries the log
+// fields and baggage of ctx, and its span is linked to the span of ctx.
+// ErrTasksDisabled is returned if no task queue is configured.
+func (orc *Oracle) EnqueueTask(ctx context.Context, task proto.Message, opts ...tasks.EnqueueOption) error {
+	if orc.taskClient == nil {
+		return ErrTasksDisabled
+	}
+	return orc.taskClient.Enqueue(ctx, task, opts...)
+}
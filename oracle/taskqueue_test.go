@@ -0,0 +1,51 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package oracle
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/luthersystems/svc/oracle/tasks"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestTaskQueueValid(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.TaskQueue.Workers = -1
+	require.Error(t, cfg.Valid())
+	cfg.TaskQueue.Workers = 2
+	cfg.TaskQueue.SQSDeadLetterQueueURL = "https://sqs/dlq"
+	require.Error(t, cfg.Valid())
+	cfg.TaskQueue.SQSQueueURL = "https://sqs/queue"
+	require.NoError(t, cfg.Valid())
+}
+
+func TestEnqueueTask(t *testing.T) {
+	handled := make(chan string, 1)
+	cfg := DefaultConfig()
+	cfg.AddTaskHandler(&wrapperspb.StringValue{}, tasks.HandlerFunc(func(ctx context.Context, task *wrapperspb.StringValue) error {
+		handled <- task.GetValue()
+		return nil
+	}))
+	orc := newTestOracle(t, cfg)
+	require.ErrorIs(t, orc.EnqueueTask(context.Background(), wrapperspb.String("x")), ErrTasksDisabled)
+
+	// The in-memory queue of an emulated phylum.
+	orc.cfg.EmulateCC = true
+	require.NoError(t, orc.initTasks(context.Background()))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := orc.runTasks(ctx)
+	require.NoError(t, orc.EnqueueTask(ctx, wrapperspb.String("hello")))
+	select {
+	case v := <-handled:
+		require.Equal(t, "hello", v)
+	case <-time.After(5 * time.Second):
+		t.Fatal("task not handled")
+	}
+	cancel()
+	<-done
+}
@@ -0,0 +1,126 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package tasks
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Queue transports encoded tasks.
+type Queue interface {
+	// Send enqueues a task body, delivered after delay.
+	Send(ctx context.Context, body []byte, delay time.Duration) error
+	// Receive waits for tasks, returning an empty slice if none arrived
+	// within the queue's polling period.
+	Receive(ctx context.Context) ([]Message, error)
+}
+
+// Message is a task delivered by a Queue.
+type Message interface {
+	// Body returns the encoded task.
+	Body() []byte
+	// Attempt returns the delivery attempt, starting at 1.
+	Attempt() int
+	// Ack removes the task from the queue.
+	Ack(ctx context.Context) error
+	// Retry redelivers the task after delay.
+	Retry(ctx context.Context, delay time.Duration) error
+}
+
+// MemoryQueue is an in-process queue, used when emulating the phylum.
+// Tasks are lost when the process exits.
+type MemoryQueue struct {
+	mut     sync.Mutex
+	pending []*memoryMessage
+	ready   chan struct{}
+	closed  bool
+}
+
+var _ Queue = &MemoryQueue{}
+
+// NewMemoryQueue returns an empty in-process queue.
+func NewMemoryQueue() *MemoryQueue {
+	return &MemoryQueue{ready: make(chan struct{}, 1)}
+}
+
+type memoryMessage struct {
+	queue   *MemoryQueue
+	body    []byte
+	attempt int
+}
+
+func (m *memoryMessage) Body() []byte { return m.body }
+
+func (m *memoryMessage) Attempt() int { return m.attempt }
+
+func (m *memoryMessage) Ack(ctx context.Context) error { return nil }
+
+func (m *memoryMessage) Retry(ctx context.Context, delay time.Duration) error {
+	m.queue.push(&memoryMessage{queue: m.queue, body: m.body, attempt: m.attempt + 1}, delay)
+	return nil
+}
+
+// Send implements Queue.
+func (q *MemoryQueue) Send(ctx context.Context, body []byte, delay time.Duration) error {
+	q.push(&memoryMessage{queue: q, body: body, attempt: 1}, delay)
+	return nil
+}
+
+func (q *MemoryQueue) push(m *memoryMessage, delay time.Duration) {
+	if delay > 0 {
+		time.AfterFunc(delay, func() {
+			q.push(m, 0)
+		})
+		return
+	}
+	q.mut.Lock()
+	if q.closed {
+		q.mut.Unlock()
+		return
+	}
+	q.pending = append(q.pending, m)
+	q.mut.Unlock()
+	select {
+	case q.ready <- struct{}{}:
+	default:
+	}
+}
+
+// Receive implements Queue.
+func (q *MemoryQueue) Receive(ctx context.Context) ([]Message, error) {
+	for {
+		q.mut.Lock()
+		if len(q.pending) > 0 {
+			msgs := make([]Message, len(q.pending))
+			for i, m := range q.pending {
+				msgs[i] = m
+			}
+			q.pending = nil
+			q.mut.Unlock()
+			return msgs, nil
+		}
+		q.mut.Unlock()
+		select {
+		case <-q.ready:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// Len returns the number of tasks ready for delivery.
+func (q *MemoryQueue) Len() int {
+	q.mut.Lock()
+	defer q.mut.Unlock()
+	return len(q.pending)
+}
+
+// Close stops accepting tasks.  Delayed and retried tasks which are not yet
+// ready are dropped.
+func (q *MemoryQueue) Close() {
+	q.mut.Lock()
+	q.closed = true
+	q.mut.Unlock()
+}
@@ -0,0 +1,298 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package tasks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/luthersystems/svc/grpclogging"
+	"github.com/luthersystems/svc/opttrace"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+const (
+	defaultWorkers      = 4
+	defaultMaxAttempts  = 5
+	defaultRetryBackoff = 5 * time.Second
+	defaultMaxBackoff   = 10 * time.Minute
+	defaultTaskTimeout  = 5 * time.Minute
+	// receiveErrorBackoff is the delay before receiving again after an
+	// error.
+	receiveErrorBackoff = time.Second
+)
+
+// Results of the tasks_processed_total metric.
+const (
+	resultSuccess    = "success"
+	resultRetry      = "retry"
+	resultDeadLetter = "dead_letter"
+)
+
+var tasksProcessed = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "tasks_processed_total",
+		Help: "How many queued tasks were processed, partitioned by task type and result.",
+	},
+	[]string{"type", "result"},
+)
+
+// Collectors returns the prometheus collectors populated by runners.
+func Collectors() []prometheus.Collector {
+	return []prometheus.Collector{tasksProcessed}
+}
+
+// RunnerOption configures a Runner.
+type RunnerOption func(*Runner)
+
+// WithWorkers sets the number of tasks handled concurrently.  Defaults to 4.
+func WithWorkers(n int) RunnerOption {
+	return func(r *Runner) {
+		r.workers = n
+	}
+}
+
+// WithMaxAttempts sets the number of attempts before a failing task is
+// dead-lettered.  Defaults to 5.
+func WithMaxAttempts(n int) RunnerOption {
+	return func(r *Runner) {
+		r.maxAttempts = n
+	}
+}
+
+// WithRetryBackoff sets the delay before the first retry, which doubles with
+// each attempt up to max.  Defaults to 5 seconds, up to 10 minutes.
+func WithRetryBackoff(initial time.Duration, max time.Duration) RunnerOption {
+	return func(r *Runner) {
+		r.backoff = initial
+		r.maxBackoff = max
+	}
+}
+
+// WithTaskTimeout bounds the duration of a handler.  Defaults to 5 minutes.
+// With SQS, the queue visibility timeout must exceed the task timeout.
+func WithTaskTimeout(d time.Duration) RunnerOption {
+	return func(r *Runner) {
+		r.timeout = d
+	}
+}
+
+// WithDeadLetterQueue sends tasks which failed permanently, exhausted their
+// attempts, or could not be decoded to q.  Without a dead-letter queue such
+// tasks are logged and dropped.
+func WithDeadLetterQueue(q Queue) RunnerOption {
+	return func(r *Runner) {
+		r.deadLetter = q
+	}
+}
+
+// WithLogBase sets the base logrus entry of handler logs.
+func WithLogBase(log *logrus.Entry) RunnerOption {
+	return func(r *Runner) {
+		r.log = log
+	}
+}
+
+// WithTracer sets the tracer creating task spans.  Each task span starts a
+// new trace linked to the span of the enqueuing request.
+func WithTracer(t *opttrace.Tracer) RunnerOption {
+	return func(r *Runner) {
+		r.tracer = t
+	}
+}
+
+// Runner consumes tasks from a queue, dispatching them to handlers by task
+// type.
+type Runner struct {
+	queue       Queue
+	handlers    map[protoreflect.FullName]Handler
+	workers     int
+	maxAttempts int
+	backoff     time.Duration
+	maxBackoff  time.Duration
+	timeout     time.Duration
+	deadLetter  Queue
+	log         *logrus.Entry
+	tracer      *opttrace.Tracer
+	propagator  propagation.TextMapPropagator
+}
+
+// NewRunner returns a runner consuming tasks from queue.
+func NewRunner(queue Queue, opts ...RunnerOption) *Runner {
+	r := &Runner{
+		queue:       queue,
+		handlers:    make(map[protoreflect.FullName]Handler),
+		workers:     defaultWorkers,
+		maxAttempts: defaultMaxAttempts,
+		backoff:     defaultRetryBackoff,
+		maxBackoff:  defaultMaxBackoff,
+		timeout:     defaultTaskTimeout,
+		log:         logrus.NewEntry(logrus.StandardLogger()),
+		tracer:      &opttrace.Tracer{},
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// Handle registers the handler of tasks of the type of task.  Handlers must
+// be registered before Run.
+func (r *Runner) Handle(task proto.Message, h Handler) {
+	r.handlers[task.ProtoReflect().Descriptor().FullName()] = h
+}
+
+// Run consumes tasks until ctx is done, then waits for running handlers to
+// finish.  Handler contexts are not cancelled with ctx, but bounded by the
+// task timeout.
+func (r *Runner) Run(ctx context.Context) error {
+	if r.workers < 1 {
+		return fmt.Errorf("tasks: invalid workers: %d", r.workers)
+	}
+	slots := make(chan struct{}, r.workers)
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	for {
+		msgs, err := r.queue.Receive(ctx)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			r.log.WithError(err).Warnf("task receive failed")
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(receiveErrorBackoff):
+			}
+			continue
+		}
+		for _, msg := range msgs {
+			slots <- struct{}{}
+			wg.Add(1)
+			go func(msg Message) {
+				defer wg.Done()
+				defer func() { <-slots }()
+				r.process(context.WithoutCancel(ctx), msg)
+			}(msg)
+		}
+	}
+}
+
+// process handles a delivered task, then acknowledges, retries or
+// dead-letters it.
+func (r *Runner) process(ctx context.Context, msg Message) {
+	log := r.log.WithField("task_attempt", msg.Attempt())
+	env, err := decodeEnvelope(msg.Body())
+	if err != nil {
+		r.deadLetterTask(ctx, log, msg, "unknown", err)
+		return
+	}
+	task, err := env.task()
+	if err != nil {
+		r.deadLetterTask(ctx, log, msg, "unknown", err)
+		return
+	}
+	taskType := string(task.ProtoReflect().Descriptor().FullName())
+	log = log.WithField("task_type", taskType)
+	h, ok := r.handlers[task.ProtoReflect().Descriptor().FullName()]
+	if !ok {
+		r.deadLetterTask(ctx, log, msg, taskType, fmt.Errorf("no handler for task %s", taskType))
+		return
+	}
+
+	taskCtx, span := r.taskContext(ctx, env, taskType, msg.Attempt())
+	defer span.End()
+	log = grpclogging.GetLogrusEntry(taskCtx, r.log)
+	err = r.handle(taskCtx, h, task)
+	switch {
+	case err == nil:
+		tasksProcessed.WithLabelValues(taskType, resultSuccess).Inc()
+		if err := msg.Ack(ctx); err != nil {
+			log.WithError(err).Warnf("task ack failed")
+		}
+	case IsPermanent(err) || msg.Attempt() >= r.maxAttempts:
+		span.RecordError(err)
+		r.deadLetterTask(ctx, log, msg, taskType, err)
+	default:
+		span.RecordError(err)
+		delay := r.retryDelay(msg.Attempt())
+		log.WithError(err).WithField("retry_delay", delay.String()).Warnf("task failed, retrying")
+		tasksProcessed.WithLabelValues(taskType, resultRetry).Inc()
+		if err := msg.Retry(ctx, delay); err != nil {
+			log.WithError(err).Warnf("task retry failed")
+		}
+	}
+}
+
+// taskContext returns the context of a task handler, restoring the log
+// fields, baggage and trace link of the enqueuing request.
+func (r *Runner) taskContext(ctx context.Context, env *envelope, taskType string, attempt int) (context.Context, trace.Span) {
+	origin := opttrace.CaptureOrigin(r.textMapPropagator().Extract(ctx, propagation.MapCarrier(env.Trace)))
+	ctx = grpclogging.NewContext(ctx)
+	grpclogging.AddLogrusFields(ctx, env.LogFields)
+	grpclogging.AddLogrusFields(ctx, logrus.Fields{
+		"task_type":    taskType,
+		"task_attempt": attempt,
+	})
+	return r.tracer.LinkedSpan(ctx, origin, "task "+taskType, trace.WithSpanKind(trace.SpanKindConsumer))
+}
+
+// handle runs a handler, recovering panics.
+func (r *Runner) handle(ctx context.Context, h Handler, task proto.Message) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("task panic: %v", p)
+			grpclogging.GetLogrusEntry(ctx, r.log).WithField("stack", string(debug.Stack())).Errorf("task panic")
+		}
+	}()
+	return h(ctx, task)
+}
+
+func (r *Runner) retryDelay(attempt int) time.Duration {
+	delay := r.backoff
+	for i := 1; i < attempt && delay < r.maxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > r.maxBackoff {
+		delay = r.maxBackoff
+	}
+	return delay
+}
+
+// deadLetterTask moves a failed task to the dead-letter queue, if any, and
+// removes it from the queue.
+func (r *Runner) deadLetterTask(ctx context.Context, log *logrus.Entry, msg Message, taskType string, cause error) {
+	tasksProcessed.WithLabelValues(taskType, resultDeadLetter).Inc()
+	if r.deadLetter == nil {
+		log.WithError(cause).Errorf("task failed, dropping")
+	} else {
+		log.WithError(cause).Errorf("task failed, dead-lettering")
+		if err := r.deadLetter.Send(ctx, msg.Body(), 0); err != nil {
+			// Leave the task on the queue to retry dead-lettering.
+			log.WithError(errors.Join(cause, err)).Errorf("task dead-letter failed")
+			return
+		}
+	}
+	if err := msg.Ack(ctx); err != nil {
+		log.WithError(err).Warnf("task ack failed")
+	}
+}
+
+func (r *Runner) textMapPropagator() propagation.TextMapPropagator {
+	if r.propagator != nil {
+		return r.propagator
+	}
+	return otel.GetTextMapPropagator()
+}
@@ -0,0 +1,139 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package tasks
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awscfg "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+const (
+	// sqsMaxMessages is the maximum number of messages per receive.
+	sqsMaxMessages = 10
+	// sqsWaitTime is the long polling duration of a receive, in seconds.
+	sqsWaitTime = 20
+	// sqsMaxDelay is the maximum delay of a sent message.
+	sqsMaxDelay = 15 * time.Minute
+	// sqsMaxVisibility is the maximum visibility timeout of a message.
+	sqsMaxVisibility = 12 * time.Hour
+)
+
+// sqsAPI is the subset of the SQS client used by SQSQueue.
+type sqsAPI interface {
+	SendMessage(ctx context.Context, params *sqs.SendMessageInput, optFns ...func(*sqs.Options)) (*sqs.SendMessageOutput, error)
+	ReceiveMessage(ctx context.Context, params *sqs.ReceiveMessageInput, optFns ...func(*sqs.Options)) (*sqs.ReceiveMessageOutput, error)
+	DeleteMessage(ctx context.Context, params *sqs.DeleteMessageInput, optFns ...func(*sqs.Options)) (*sqs.DeleteMessageOutput, error)
+	ChangeMessageVisibility(ctx context.Context, params *sqs.ChangeMessageVisibilityInput, optFns ...func(*sqs.Options)) (*sqs.ChangeMessageVisibilityOutput, error)
+}
+
+// SQSQueue is a Queue backed by AWS SQS.  Retries are scheduled by changing
+// the visibility timeout of the message, and attempts are counted by SQS.
+type SQSQueue struct {
+	client   sqsAPI
+	queueURL string
+}
+
+var _ Queue = &SQSQueue{}
+
+// NewSQSQueue returns a queue for the SQS queue at queueURL, using the
+// default AWS credentials.
+func NewSQSQueue(ctx context.Context, region string, queueURL string) (*SQSQueue, error) {
+	if queueURL == "" {
+		return nil, fmt.Errorf("NewSQSQueue: requires queue url")
+	}
+	awsCfg, err := awscfg.LoadDefaultConfig(ctx, awscfg.WithRegion(region))
+	if err != nil {
+		return nil, err
+	}
+	return &SQSQueue{
+		client:   sqs.NewFromConfig(awsCfg),
+		queueURL: queueURL,
+	}, nil
+}
+
+// Send implements Queue.
+func (q *SQSQueue) Send(ctx context.Context, body []byte, delay time.Duration) error {
+	if delay > sqsMaxDelay {
+		return fmt.Errorf("sqs delay exceeds %v", sqsMaxDelay)
+	}
+	_, err := q.client.SendMessage(ctx, &sqs.SendMessageInput{
+		QueueUrl:     aws.String(q.queueURL),
+		MessageBody:  aws.String(string(body)),
+		DelaySeconds: int32(delay.Round(time.Second) / time.Second),
+	})
+	if err != nil {
+		return fmt.Errorf("sqs send: %w", err)
+	}
+	return nil
+}
+
+// Receive implements Queue.
+func (q *SQSQueue) Receive(ctx context.Context) ([]Message, error) {
+	out, err := q.client.ReceiveMessage(ctx, &sqs.ReceiveMessageInput{
+		QueueUrl:            aws.String(q.queueURL),
+		MaxNumberOfMessages: sqsMaxMessages,
+		WaitTimeSeconds:     sqsWaitTime,
+		AttributeNames:      []types.QueueAttributeName{types.QueueAttributeName(types.MessageSystemAttributeNameApproximateReceiveCount)},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("sqs receive: %w", err)
+	}
+	msgs := make([]Message, 0, len(out.Messages))
+	for _, m := range out.Messages {
+		attempt, err := strconv.Atoi(m.Attributes[string(types.MessageSystemAttributeNameApproximateReceiveCount)])
+		if err != nil || attempt < 1 {
+			attempt = 1
+		}
+		msgs = append(msgs, &sqsMessage{
+			queue:         q,
+			body:          []byte(aws.ToString(m.Body)),
+			receiptHandle: m.ReceiptHandle,
+			attempt:       attempt,
+		})
+	}
+	return msgs, nil
+}
+
+type sqsMessage struct {
+	queue         *SQSQueue
+	body          []byte
+	receiptHandle *string
+	attempt       int
+}
+
+func (m *sqsMessage) Body() []byte { return m.body }
+
+func (m *sqsMessage) Attempt() int { return m.attempt }
+
+func (m *sqsMessage) Ack(ctx context.Context) error {
+	_, err := m.queue.client.DeleteMessage(ctx, &sqs.DeleteMessageInput{
+		QueueUrl:      aws.String(m.queue.queueURL),
+		ReceiptHandle: m.receiptHandle,
+	})
+	if err != nil {
+		return fmt.Errorf("sqs delete: %w", err)
+	}
+	return nil
+}
+
+func (m *sqsMessage) Retry(ctx context.Context, delay time.Duration) error {
+	if delay > sqsMaxVisibility {
+		delay = sqsMaxVisibility
+	}
+	_, err := m.queue.client.ChangeMessageVisibility(ctx, &sqs.ChangeMessageVisibilityInput{
+		QueueUrl:          aws.String(m.queue.queueURL),
+		ReceiptHandle:     m.receiptHandle,
+		VisibilityTimeout: int32(delay.Round(time.Second) / time.Second),
+	})
+	if err != nil {
+		return fmt.Errorf("sqs change visibility: %w", err)
+	}
+	return nil
+}
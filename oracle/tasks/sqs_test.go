@@ -0,0 +1,77 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package tasks
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeSQS struct {
+	sent       []*sqs.SendMessageInput
+	deleted    []string
+	visibility map[string]int32
+	messages   []types.Message
+}
+
+func (f *fakeSQS) SendMessage(ctx context.Context, params *sqs.SendMessageInput, optFns ...func(*sqs.Options)) (*sqs.SendMessageOutput, error) {
+	f.sent = append(f.sent, params)
+	return &sqs.SendMessageOutput{}, nil
+}
+
+func (f *fakeSQS) ReceiveMessage(ctx context.Context, params *sqs.ReceiveMessageInput, optFns ...func(*sqs.Options)) (*sqs.ReceiveMessageOutput, error) {
+	msgs := f.messages
+	f.messages = nil
+	return &sqs.ReceiveMessageOutput{Messages: msgs}, nil
+}
+
+func (f *fakeSQS) DeleteMessage(ctx context.Context, params *sqs.DeleteMessageInput, optFns ...func(*sqs.Options)) (*sqs.DeleteMessageOutput, error) {
+	f.deleted = append(f.deleted, aws.ToString(params.ReceiptHandle))
+	return &sqs.DeleteMessageOutput{}, nil
+}
+
+func (f *fakeSQS) ChangeMessageVisibility(ctx context.Context, params *sqs.ChangeMessageVisibilityInput, optFns ...func(*sqs.Options)) (*sqs.ChangeMessageVisibilityOutput, error) {
+	f.visibility[aws.ToString(params.ReceiptHandle)] = params.VisibilityTimeout
+	return &sqs.ChangeMessageVisibilityOutput{}, nil
+}
+
+func TestSQSQueue(t *testing.T) {
+	ctx := context.Background()
+	fake := &fakeSQS{visibility: make(map[string]int32)}
+	q := &SQSQueue{client: fake, queueURL: "https://sqs/queue"}
+
+	require.NoError(t, q.Send(ctx, []byte("body"), 10*time.Second))
+	require.Len(t, fake.sent, 1)
+	require.Equal(t, "body", aws.ToString(fake.sent[0].MessageBody))
+	require.Equal(t, int32(10), fake.sent[0].DelaySeconds)
+	require.Error(t, q.Send(ctx, []byte("body"), time.Hour))
+
+	fake.messages = []types.Message{
+		{
+			Body:          aws.String("a"),
+			ReceiptHandle: aws.String("ra"),
+			Attributes:    map[string]string{"ApproximateReceiveCount": "3"},
+		},
+		{
+			Body:          aws.String("b"),
+			ReceiptHandle: aws.String("rb"),
+		},
+	}
+	msgs, err := q.Receive(ctx)
+	require.NoError(t, err)
+	require.Len(t, msgs, 2)
+	require.Equal(t, []byte("a"), msgs[0].Body())
+	require.Equal(t, 3, msgs[0].Attempt())
+	require.Equal(t, 1, msgs[1].Attempt())
+
+	require.NoError(t, msgs[0].Ack(ctx))
+	require.Equal(t, []string{"ra"}, fake.deleted)
+	require.NoError(t, msgs[1].Retry(ctx, time.Minute))
+	require.Equal(t, int32(60), fake.visibility["rb"])
+}
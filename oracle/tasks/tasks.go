@@ -0,0 +1,166 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+// Package tasks offloads long-running work from request handlers to a queue
+// of typed proto tasks.  A Client enqueues tasks, carrying the trace and log
+// context of the enqueuing request, and a Runner consumes them with
+// retries and dead-letter handling.
+package tasks
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/luthersystems/svc/grpclogging"
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+// Handler processes a task.  Returned errors are retried unless wrapped
+// with Permanent.
+type Handler func(ctx context.Context, task proto.Message) error
+
+// HandlerFunc adapts a function taking a concrete task type to a Handler.
+func HandlerFunc[T proto.Message](fn func(ctx context.Context, task T) error) Handler {
+	return func(ctx context.Context, task proto.Message) error {
+		t, ok := task.(T)
+		if !ok {
+			return Permanent(fmt.Errorf("unexpected task type: %T", task))
+		}
+		return fn(ctx, t)
+	}
+}
+
+// permanentError is a task error which must not be retried.
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string {
+	return e.err.Error()
+}
+
+func (e *permanentError) Unwrap() error {
+	return e.err
+}
+
+// Permanent marks a task error as permanent, so the task is dead-lettered
+// without further attempts.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// IsPermanent returns true if err was marked with Permanent.
+func IsPermanent(err error) bool {
+	var p *permanentError
+	return errors.As(err, &p)
+}
+
+// envelope is the queued form of a task.
+type envelope struct {
+	// Task is the binary encoding of an anypb.Any holding the task.
+	Task []byte `json:"task"`
+	// Trace carries the trace context and baggage of the enqueuing request.
+	Trace map[string]string `json:"trace,omitempty"`
+	// LogFields are the log fields of the enqueuing request.
+	LogFields logrus.Fields `json:"log_fields,omitempty"`
+	// EnqueuedAt is the time the task was enqueued.
+	EnqueuedAt time.Time `json:"enqueued_at"`
+}
+
+func (e *envelope) task() (proto.Message, error) {
+	a := &anypb.Any{}
+	if err := proto.Unmarshal(e.Task, a); err != nil {
+		return nil, fmt.Errorf("task: %w", err)
+	}
+	msg, err := a.UnmarshalNew()
+	if err != nil {
+		return nil, fmt.Errorf("task %s: %w", a.GetTypeUrl(), err)
+	}
+	return msg, nil
+}
+
+func decodeEnvelope(body []byte) (*envelope, error) {
+	e := &envelope{}
+	if err := json.Unmarshal(body, e); err != nil {
+		return nil, fmt.Errorf("task envelope: %w", err)
+	}
+	return e, nil
+}
+
+// Client enqueues tasks.
+type Client struct {
+	queue      Queue
+	propagator propagation.TextMapPropagator
+	now        func() time.Time
+}
+
+// NewClient returns a client enqueuing tasks to queue.
+func NewClient(queue Queue) *Client {
+	return &Client{
+		queue: queue,
+		now:   time.Now,
+	}
+}
+
+// EnqueueOption configures an enqueued task.
+type EnqueueOption func(*enqueueConfig)
+
+type enqueueConfig struct {
+	delay time.Duration
+}
+
+// WithDelay delays the delivery of a task.  SQS limits the delay to 15
+// minutes.
+func WithDelay(d time.Duration) EnqueueOption {
+	return func(cfg *enqueueConfig) {
+		cfg.delay = d
+	}
+}
+
+// Enqueue enqueues task.  The trace context, baggage and log fields of ctx
+// are restored when the task is handled.
+func (c *Client) Enqueue(ctx context.Context, task proto.Message, opts ...EnqueueOption) error {
+	cfg := &enqueueConfig{}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	a, err := anypb.New(task)
+	if err != nil {
+		return fmt.Errorf("enqueue task: %w", err)
+	}
+	b, err := proto.Marshal(a)
+	if err != nil {
+		return fmt.Errorf("enqueue task: %w", err)
+	}
+	carrier := propagation.MapCarrier{}
+	c.textMapPropagator().Inject(ctx, carrier)
+	body, err := json.Marshal(&envelope{
+		Task:       b,
+		Trace:      carrier,
+		LogFields:  grpclogging.GetLogrusFields(ctx),
+		EnqueuedAt: c.now().UTC(),
+	})
+	if err != nil {
+		return fmt.Errorf("enqueue task: %w", err)
+	}
+	if err := c.queue.Send(ctx, body, cfg.delay); err != nil {
+		return fmt.Errorf("enqueue task: %w", err)
+	}
+	return nil
+}
+
+func (c *Client) textMapPropagator() propagation.TextMapPropagator {
+	if c.propagator != nil {
+		return c.propagator
+	}
+	return otel.GetTextMapPropagator()
+}
@@ -0,0 +1,159 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package tasks
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/luthersystems/svc/grpclogging"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/propagation"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+type result struct {
+	task    string
+	attempt interface{}
+	reqID   interface{}
+	tenant  string
+}
+
+func newTestRunner(queue Queue, opts ...RunnerOption) *Runner {
+	opts = append([]RunnerOption{
+		WithRetryBackoff(time.Millisecond, 10*time.Millisecond),
+		WithLogBase(logrus.NewEntry(logrus.New())),
+	}, opts...)
+	r := NewRunner(queue, opts...)
+	r.propagator = propagation.Baggage{}
+	return r
+}
+
+func runUntil(t *testing.T, r *Runner, done <-chan struct{}) {
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan error)
+	go func() {
+		stopped <- r.Run(ctx)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for tasks")
+	}
+	cancel()
+	require.NoError(t, <-stopped)
+}
+
+func TestEnqueueRun(t *testing.T) {
+	queue := NewMemoryQueue()
+	defer queue.Close()
+	client := NewClient(queue)
+	client.propagator = propagation.Baggage{}
+
+	ctx := grpclogging.NewContext(context.Background())
+	grpclogging.AddLogrusField(ctx, "req_id", "request-id")
+	member, err := baggage.NewMember("tenant", "acme")
+	require.NoError(t, err)
+	bag, err := baggage.New(member)
+	require.NoError(t, err)
+	ctx = baggage.ContextWithBaggage(ctx, bag)
+	require.NoError(t, client.Enqueue(ctx, wrapperspb.String("hello")))
+
+	results := make(chan result, 10)
+	done := make(chan struct{})
+	r := newTestRunner(queue)
+	r.Handle(&wrapperspb.StringValue{}, HandlerFunc(func(ctx context.Context, task *wrapperspb.StringValue) error {
+		fields := grpclogging.GetLogrusFields(ctx)
+		results <- result{
+			task:    task.GetValue(),
+			attempt: fields["task_attempt"],
+			reqID:   fields["req_id"],
+			tenant:  baggage.FromContext(ctx).Member("tenant").Value(),
+		}
+		if fields["task_attempt"] == 1 {
+			return errors.New("transient")
+		}
+		close(done)
+		return nil
+	}))
+	runUntil(t, r, done)
+
+	first := <-results
+	require.Equal(t, result{task: "hello", attempt: 1, reqID: "request-id", tenant: "acme"}, first)
+	second := <-results
+	require.Equal(t, 2, second.attempt)
+	require.Equal(t, "request-id", second.reqID)
+}
+
+func TestDeadLetter(t *testing.T) {
+	queue := NewMemoryQueue()
+	defer queue.Close()
+	dlq := NewMemoryQueue()
+	client := NewClient(queue)
+	ctx := context.Background()
+
+	var mut sync.Mutex
+	attempts := make(map[string]int)
+	r := newTestRunner(queue, WithDeadLetterQueue(dlq), WithMaxAttempts(3))
+	r.Handle(&wrapperspb.StringValue{}, func(ctx context.Context, task proto.Message) error {
+		v := task.(*wrapperspb.StringValue).GetValue()
+		mut.Lock()
+		attempts[v]++
+		mut.Unlock()
+		switch v {
+		case "permanent":
+			return Permanent(errors.New("invalid"))
+		case "panic":
+			panic("boom")
+		}
+		return errors.New("failed")
+	})
+	require.NoError(t, client.Enqueue(ctx, wrapperspb.String("permanent")))
+	require.NoError(t, client.Enqueue(ctx, wrapperspb.String("exhausted")))
+	require.NoError(t, client.Enqueue(ctx, wrapperspb.String("panic")))
+	// No handler is registered for the task type.
+	require.NoError(t, client.Enqueue(ctx, durationpb.New(time.Second)))
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for dlq.Len() < 4 {
+			time.Sleep(time.Millisecond)
+		}
+	}()
+	runUntil(t, r, done)
+
+	mut.Lock()
+	defer mut.Unlock()
+	require.Equal(t, map[string]int{"permanent": 1, "exhausted": 3, "panic": 3}, attempts)
+	msgs, err := dlq.Receive(ctx)
+	require.NoError(t, err)
+	require.Len(t, msgs, 4)
+	var dead []string
+	for _, msg := range msgs {
+		env, err := decodeEnvelope(msg.Body())
+		require.NoError(t, err)
+		task, err := env.task()
+		require.NoError(t, err)
+		if v, ok := task.(*wrapperspb.StringValue); ok {
+			dead = append(dead, v.GetValue())
+		}
+	}
+	require.ElementsMatch(t, []string{"permanent", "exhausted", "panic"}, dead)
+}
+
+func TestRetryDelay(t *testing.T) {
+	r := NewRunner(NewMemoryQueue(), WithRetryBackoff(time.Second, 5*time.Second))
+	require.Equal(t, time.Second, r.retryDelay(1))
+	require.Equal(t, 2*time.Second, r.retryDelay(2))
+	require.Equal(t, 4*time.Second, r.retryDelay(3))
+	require.Equal(t, 5*time.Second, r.retryDelay(4))
+	require.Equal(t, 5*time.Second, r.retryDelay(100))
+}
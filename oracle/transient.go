@@ -0,0 +1,132 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package oracle
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/luthersystems/shiroclient-sdk-go/shiroclient"
+	"google.golang.org/grpc/metadata"
+)
+
+// ErrClaimsNotConfigured is returned by GetClaims when no ClaimsGetter has
+// been configured.
+var ErrClaimsNotConfigured = errors.New("claims getter not configured")
+
+// ClaimsGetter returns the verified claims of the user making a request.
+type ClaimsGetter func(ctx context.Context) (map[string]interface{}, error)
+
+// SetClaimsGetter configures the function used to obtain the verified claims
+// of the user making a request.
+func (c *Config) SetClaimsGetter(fn ClaimsGetter) {
+	if c == nil {
+		return
+	}
+	c.claimsGetter = fn
+}
+
+// GetClaims returns the verified claims of the user making the request,
+// checked against the configured ClaimsSchema.  Claims are obtained once per
+// request and cached in the request context.
+//
+// Requests presenting an API key in the configured APIKeyHeader are
+// authenticated with the key instead, and receive synthetic claims.
+func (orc *Oracle) GetClaims(ctx context.Context) (map[string]interface{}, error) {
+	_, hasAPIKey := orc.requestAPIKey(ctx)
+	if orc.cfg.claimsGetter == nil && !hasAPIKey {
+		return nil, ErrClaimsNotConfigured
+	}
+	cache, ok := ctx.Value(claimsCacheKey{}).(*claimsCache)
+	if !ok {
+		return orc.verifiedClaims(ctx)
+	}
+	cache.once.Do(func() {
+		cache.claims, cache.err = orc.verifiedClaims(ctx)
+	})
+	return cache.claims, cache.err
+}
+
+// TransientField maps request metadata, either an HTTP header or a claim of
+// the requesting user, to a field of the transient data passed to the phylum.
+// Exactly one of Header and Claim must be set.
+type TransientField struct {
+	// Header is the name of a request header.
+	Header string `yaml:"header"`
+	// Claim is the name of a user claim.
+	Claim string `yaml:"claim"`
+	// Field is the transient data field.
+	Field string `yaml:"field"`
+}
+
+func (f TransientField) valid() error {
+	if f.Field == "" {
+		return fmt.Errorf("transient field: missing field")
+	}
+	if (f.Header == "") == (f.Claim == "") {
+		return fmt.Errorf("transient field %s: exactly one of header and claim required", f.Field)
+	}
+	return nil
+}
+
+// transientHeaders returns the headers which must be forwarded by the gateway
+// for transient field mappings.
+func (c *Config) transientHeaders() []string {
+	var headers []string
+	for _, f := range c.TransientFields {
+		if f.Header != "" {
+			headers = append(headers, f.Header)
+		}
+	}
+	return headers
+}
+
+// transientConfigs passes request metadata to the phylum as transient data,
+// according to the configured TransientFields.  Missing values are omitted.
+func (orc *Oracle) transientConfigs(ctx context.Context) []shiroclient.Config {
+	if len(orc.cfg.TransientFields) == 0 {
+		return nil
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	var claims map[string]interface{}
+	var claimsErr error
+	var claimsLoaded bool
+	var configs []shiroclient.Config
+	for _, f := range orc.cfg.TransientFields {
+		var val []byte
+		if f.Header != "" {
+			vals := md.Get(strings.ToLower(f.Header))
+			if len(vals) == 0 {
+				continue
+			}
+			val = []byte(vals[0])
+		} else {
+			if !claimsLoaded {
+				claims, claimsErr = orc.GetClaims(ctx)
+				claimsLoaded = true
+				if claimsErr != nil && !errors.Is(claimsErr, ErrClaimsNotConfigured) {
+					orc.log(ctx).WithError(claimsErr).Debugf("transient claims unavailable")
+				}
+			}
+			claim, ok := claims[f.Claim]
+			if !ok {
+				continue
+			}
+			if s, ok := claim.(string); ok {
+				val = []byte(s)
+			} else {
+				b, err := json.Marshal(claim)
+				if err != nil {
+					orc.log(ctx).WithError(err).Warnf("transient claim %s", f.Claim)
+					continue
+				}
+				val = b
+			}
+		}
+		configs = append(configs, shiroclient.WithTransientData(f.Field, val))
+	}
+	return configs
+}
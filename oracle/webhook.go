@@ -0,0 +1,363 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package oracle
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/luthersystems/svc/docstore"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// WebhookSignatureHeader carries the HMAC-SHA256 signature of outbound
+	// webhooks, in the form "t=<unix time>,v1=<hex signature>".  The
+	// signature is computed over "<unix time>.<body>".
+	WebhookSignatureHeader = "X-Webhook-Signature"
+	// WebhookEventHeader carries the event type of outbound webhooks.
+	WebhookEventHeader = "X-Webhook-Event"
+	// WebhookIDHeader carries the unique delivery ID of outbound webhooks,
+	// which is stable across retries.
+	WebhookIDHeader = "X-Webhook-ID"
+
+	defaultWebhookMaxAttempts = 5
+	defaultWebhookQueueSize   = 1000
+	defaultWebhookWorkers     = 4
+	defaultWebhookTimeout     = 10 * time.Second
+	defaultWebhookBackoff     = time.Second
+	webhookDeadLetterDir      = "webhooks/dead-letter"
+)
+
+// ErrWebhookQueueFull is returned by EnqueueWebhook when deliveries cannot
+// keep up with enqueued events.
+var ErrWebhookQueueFull = errors.New("webhook queue full")
+
+var (
+	webhookDeliveriesTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "webhook_deliveries_total",
+			Help: "How many webhook delivery attempts were made, partitioned by result.",
+		},
+		[]string{"result"},
+	)
+	webhookDeliveryDuration = prometheus.NewHistogram(
+		prometheus.HistogramOpts{
+			Name:    "webhook_delivery_duration_seconds",
+			Help:    "Duration of webhook delivery attempts.",
+			Buckets: prometheus.DefBuckets,
+		},
+	)
+)
+
+// WebhookEndpoint is a partner endpoint receiving webhooks.
+type WebhookEndpoint struct {
+	// URL receives webhooks as POST requests.
+	URL string `yaml:"url"`
+	// Secret signs webhooks delivered to the endpoint.
+	Secret string `yaml:"secret" secret:"true"`
+	// Events restricts the event types delivered to the endpoint.  If empty
+	// all events are delivered.
+	Events []string `yaml:"events"`
+	// ClientCredentials sends a client credentials bearer token with
+	// webhooks delivered to the endpoint.
+	ClientCredentials bool `yaml:"client-credentials"`
+}
+
+func (e WebhookEndpoint) valid() error {
+	u, err := url.Parse(e.URL)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return fmt.Errorf("webhook: invalid url %q", e.URL)
+	}
+	if e.Secret == "" {
+		return fmt.Errorf("webhook %s: missing secret", u.Host)
+	}
+	return nil
+}
+
+func (e WebhookEndpoint) subscribed(event string) bool {
+	if len(e.Events) == 0 {
+		return true
+	}
+	for _, ev := range e.Events {
+		if ev == event {
+			return true
+		}
+	}
+	return false
+}
+
+// SetWebhookDeadLetterStore configures a store for webhooks which could not
+// be delivered after WebhookMaxAttempts.  Without a store such webhooks are
+// logged and dropped.
+func (c *Config) SetWebhookDeadLetterStore(store docstore.Putter) {
+	if c == nil {
+		return
+	}
+	c.webhookDeadLetter = store
+}
+
+// validWebhooks validates the webhook configuration.
+func (c *Config) validWebhooks() error {
+	for _, e := range c.Webhooks {
+		if err := e.valid(); err != nil {
+			return err
+		}
+		if e.ClientCredentials && !c.ClientCredentials.enabled() {
+			return fmt.Errorf("webhook %s: client credentials not configured", e.URL)
+		}
+	}
+	if c.WebhookMaxAttempts < 0 {
+		return fmt.Errorf("invalid webhook max attempts")
+	}
+	return nil
+}
+
+// SignWebhook returns the signature header value of a webhook body sent at t.
+func SignWebhook(secret string, t time.Time, body []byte) string {
+	ts := strconv.FormatInt(t.Unix(), 10)
+	return fmt.Sprintf("t=%s,v1=%s", ts, webhookHMAC(secret, ts, body))
+}
+
+func webhookHMAC(secret string, ts string, body []byte) string {
+	return hex.EncodeToString(webhookMAC(secret, []byte(ts), []byte("."), body))
+}
+
+// webhookDelivery is a webhook to be delivered to an endpoint.
+type webhookDelivery struct {
+	ID        string          `json:"id"`
+	Event     string          `json:"event"`
+	URL       string          `json:"url"`
+	Payload   json.RawMessage `json:"payload"`
+	Attempts  int             `json:"attempts"`
+	LastError string          `json:"last_error,omitempty"`
+	secret    string
+	authorize bool
+}
+
+// webhookDispatcher delivers webhooks asynchronously with retries.
+type webhookDispatcher struct {
+	endpoints   []WebhookEndpoint
+	client      *http.Client
+	tokens      *TokenSource
+	queue       chan *webhookDelivery
+	deadLetter  docstore.Putter
+	maxAttempts int
+	backoff     time.Duration
+	log         *logrus.Entry
+	now         func() time.Time
+
+	// mu serializes enqueuers so the queue capacity checked for an event
+	// is still available when its deliveries are queued.
+	mu     sync.Mutex
+	closed bool
+	stop   chan struct{}
+	wg     sync.WaitGroup
+}
+
+func newWebhookDispatcher(cfg *Config, log *logrus.Entry, tokens *TokenSource) *webhookDispatcher {
+	maxAttempts := cfg.WebhookMaxAttempts
+	if maxAttempts == 0 {
+		maxAttempts = defaultWebhookMaxAttempts
+	}
+	d := &webhookDispatcher{
+		endpoints:   cfg.Webhooks,
+		client:      &http.Client{Timeout: defaultWebhookTimeout},
+		tokens:      tokens,
+		queue:       make(chan *webhookDelivery, defaultWebhookQueueSize),
+		deadLetter:  cfg.webhookDeadLetter,
+		maxAttempts: maxAttempts,
+		backoff:     defaultWebhookBackoff,
+		log:         log.WithField("component", "webhook"),
+		now:         time.Now,
+		stop:        make(chan struct{}),
+	}
+	for i := 0; i < defaultWebhookWorkers; i++ {
+		d.wg.Add(1)
+		go d.run()
+	}
+	return d
+}
+
+// close stops delivery.  Webhooks which have not been delivered are written
+// to the dead letter store.  Events enqueued after close are rejected with
+// ErrOracleStopped.
+func (d *webhookDispatcher) close() {
+	d.mu.Lock()
+	d.closed = true
+	d.mu.Unlock()
+	close(d.stop)
+	d.wg.Wait()
+	for {
+		select {
+		case del := <-d.queue:
+			del.LastError = "oracle stopped"
+			d.deadLetterDelivery(del)
+		default:
+			return
+		}
+	}
+}
+
+func (d *webhookDispatcher) enqueue(event string, payload interface{}) error {
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("webhook payload: %w", err)
+	}
+	var dels []*webhookDelivery
+	for _, e := range d.endpoints {
+		if !e.subscribed(event) {
+			continue
+		}
+		id, err := webhookID()
+		if err != nil {
+			return err
+		}
+		dels = append(dels, &webhookDelivery{ID: id, Event: event, URL: e.URL, Payload: b, secret: e.Secret, authorize: e.ClientCredentials})
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed {
+		return ErrOracleStopped
+	}
+	// The event is queued for all endpoints or none.  Workers only drain
+	// the queue, so the space checked here remains available.
+	if cap(d.queue)-len(d.queue) < len(dels) {
+		return ErrWebhookQueueFull
+	}
+	for _, del := range dels {
+		d.queue <- del
+	}
+	return nil
+}
+
+func webhookID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("webhook id: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func (d *webhookDispatcher) run() {
+	defer d.wg.Done()
+	for {
+		select {
+		case <-d.stop:
+			return
+		case del := <-d.queue:
+			d.deliver(del)
+		}
+	}
+}
+
+// deliver attempts delivery with exponential backoff until it succeeds, the
+// maximum number of attempts is reached, or the dispatcher is stopped.
+func (d *webhookDispatcher) deliver(del *webhookDelivery) {
+	backoff := d.backoff
+	for {
+		del.Attempts++
+		err := d.attempt(del)
+		if err == nil {
+			webhookDeliveriesTotal.WithLabelValues("success").Inc()
+			return
+		}
+		del.LastError = err.Error()
+		if del.Attempts >= d.maxAttempts {
+			webhookDeliveriesTotal.WithLabelValues("failed").Inc()
+			d.deadLetterDelivery(del)
+			return
+		}
+		webhookDeliveriesTotal.WithLabelValues("retry").Inc()
+		select {
+		case <-time.After(backoff):
+			backoff *= 2
+		case <-d.stop:
+			d.deadLetterDelivery(del)
+			return
+		}
+	}
+}
+
+func (d *webhookDispatcher) attempt(del *webhookDelivery) error {
+	start := d.now()
+	defer func() {
+		webhookDeliveryDuration.Observe(time.Since(start).Seconds())
+	}()
+	req, err := http.NewRequest(http.MethodPost, del.URL, bytes.NewReader(del.Payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WebhookEventHeader, del.Event)
+	req.Header.Set(WebhookIDHeader, del.ID)
+	req.Header.Set(WebhookSignatureHeader, SignWebhook(del.secret, start, del.Payload))
+	if del.authorize && d.tokens != nil {
+		if err := d.tokens.authorize(req); err != nil {
+			return err
+		}
+	}
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status: %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func (d *webhookDispatcher) deadLetterDelivery(del *webhookDelivery) {
+	log := d.log.WithFields(logrus.Fields{
+		"webhook_id":    del.ID,
+		"webhook_event": del.Event,
+		"attempts":      del.Attempts,
+		"last_error":    del.LastError,
+	})
+	if d.deadLetter == nil {
+		log.Errorf("webhook undeliverable")
+		return
+	}
+	b, err := json.Marshal(del)
+	if err != nil {
+		log.WithError(err).Errorf("webhook dead letter marshal")
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), defaultWebhookTimeout)
+	defer cancel()
+	key := fmt.Sprintf("%s/%s.json", webhookDeadLetterDir, del.ID)
+	if err := d.deadLetter.Put(ctx, key, b); err != nil {
+		log.WithError(err).Errorf("webhook dead letter")
+		return
+	}
+	log.Warnf("webhook dead lettered")
+}
+
+// EnqueueWebhook queues an event for delivery to all subscribed webhook
+// endpoints.  The payload is marshaled as JSON.  Delivery is asynchronous;
+// EnqueueWebhook only returns an error if the event could not be queued.
+// The event is queued for every subscribed endpoint or, if the queue is
+// full (ErrWebhookQueueFull) or the oracle stopped (ErrOracleStopped), for
+// none of them.
+func (orc *Oracle) EnqueueWebhook(ctx context.Context, event string, payload interface{}) error {
+	if orc.webhooks == nil {
+		return nil
+	}
+	if err := orc.webhooks.enqueue(event, payload); err != nil {
+		orc.log(ctx).WithError(err).WithField("webhook_event", event).Errorf("enqueue webhook")
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package oracle
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+type memPutter struct {
+	mut  sync.Mutex
+	docs map[string][]byte
+}
+
+func (m *memPutter) Put(ctx context.Context, key string, body []byte) error {
+	m.mut.Lock()
+	defer m.mut.Unlock()
+	m.docs[key] = body
+	return nil
+}
+
+func TestWebhookDelivery(t *testing.T) {
+	received := make(chan *http.Request, 1)
+	var body []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = io.ReadAll(r.Body)
+		received <- r
+	}))
+	defer srv.Close()
+
+	cfg := DefaultConfig()
+	cfg.Webhooks = []WebhookEndpoint{
+		{URL: srv.URL, Secret: "secret", Events: []string{"claim.updated"}},
+	}
+	require.NoError(t, cfg.validWebhooks())
+	d := newWebhookDispatcher(cfg, logrus.NewEntry(logrus.New()), nil)
+	defer d.close()
+
+	require.NoError(t, d.enqueue("claim.created", map[string]string{"id": "1"}))
+	require.NoError(t, d.enqueue("claim.updated", map[string]string{"id": "2"}))
+	select {
+	case r := <-received:
+		require.Equal(t, "claim.updated", r.Header.Get(WebhookEventHeader))
+		require.JSONEq(t, `{"id":"2"}`, string(body))
+		sig := r.Header.Get(WebhookSignatureHeader)
+		ts := strings.TrimPrefix(strings.Split(sig, ",")[0], "t=")
+		require.Equal(t, "v1="+webhookHMAC("secret", ts, body), strings.Split(sig, ",")[1])
+	case <-time.After(5 * time.Second):
+		t.Fatal("webhook not delivered")
+	}
+}
+
+func TestWebhookDeadLetter(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	store := &memPutter{docs: make(map[string][]byte)}
+	cfg := DefaultConfig()
+	cfg.Webhooks = []WebhookEndpoint{{URL: srv.URL, Secret: "secret"}}
+	cfg.WebhookMaxAttempts = 2
+	cfg.SetWebhookDeadLetterStore(store)
+	d := newWebhookDispatcher(cfg, logrus.NewEntry(logrus.New()), nil)
+	d.backoff = time.Millisecond
+
+	require.NoError(t, d.enqueue("claim.updated", map[string]string{"id": "1"}))
+	require.Eventually(t, func() bool {
+		store.mut.Lock()
+		defer store.mut.Unlock()
+		return len(store.docs) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	d.close()
+	for _, doc := range store.docs {
+		require.Contains(t, string(doc), `"attempts":2`)
+		require.Contains(t, string(doc), "unexpected status: 500")
+	}
+}
+
+func TestWebhookEndpointInvalid(t *testing.T) {
+	require.Error(t, WebhookEndpoint{URL: "ftp://x", Secret: "s"}.valid())
+	require.Error(t, WebhookEndpoint{URL: "https://x"}.valid())
+}
+
+func TestWebhookEnqueueAllOrNone(t *testing.T) {
+	d := &webhookDispatcher{
+		endpoints: []WebhookEndpoint{
+			{URL: "https://a.example", Secret: "secret"},
+			{URL: "https://b.example", Secret: "secret"},
+		},
+		queue: make(chan *webhookDelivery, 1),
+		log:   logrus.NewEntry(logrus.New()),
+		stop:  make(chan struct{}),
+	}
+	require.ErrorIs(t, d.enqueue("claim.updated", map[string]string{"id": "1"}), ErrWebhookQueueFull)
+	require.Empty(t, d.queue)
+
+	d.close()
+	d.queue = make(chan *webhookDelivery, 2)
+	require.ErrorIs(t, d.enqueue("claim.updated", map[string]string{"id": "1"}), ErrOracleStopped)
+	require.Empty(t, d.queue)
+}
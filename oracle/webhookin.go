@@ -0,0 +1,262 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package oracle
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/luthersystems/svc/svcerr"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// WebhookScheme is a signature scheme used by third-party webhooks.
+type WebhookScheme string
+
+const (
+	// WebhookSchemeStripe verifies a "Stripe-Signature: t=<unix>,v1=<hex>"
+	// header computed over "<unix>.<body>".  Outbound webhooks sent by the
+	// oracle use the same format in WebhookSignatureHeader.
+	WebhookSchemeStripe WebhookScheme = "stripe"
+	// WebhookSchemeGitHub verifies a "X-Hub-Signature-256: sha256=<hex>"
+	// header computed over the body.
+	WebhookSchemeGitHub WebhookScheme = "github"
+	// WebhookSchemeHMACSHA256 verifies a hex HMAC-SHA256 of the body in a
+	// configurable header, optionally prefixed with "sha256=".  If a
+	// timestamp header is configured the signature is computed over
+	// "<timestamp>.<body>".
+	WebhookSchemeHMACSHA256 WebhookScheme = "hmac-sha256"
+
+	defaultWebhookTolerance     = 5 * time.Minute
+	defaultWebhookSigHeader     = "X-Signature"
+	defaultInboundWebhookMaxLen = 1 << 20
+)
+
+var errWebhookSignature = errors.New("invalid webhook signature")
+
+// InboundWebhookHandler handles a verified third-party webhook.  body is the
+// raw request body, exactly as signed.
+type InboundWebhookHandler func(ctx context.Context, body []byte) error
+
+// InboundWebhookOption configures an inbound webhook endpoint.
+type InboundWebhookOption func(*inboundWebhook)
+
+// WithWebhookTolerance sets the maximum age of timestamped webhooks, to
+// protect against replay.  Defaults to 5 minutes.
+func WithWebhookTolerance(d time.Duration) InboundWebhookOption {
+	return func(w *inboundWebhook) {
+		w.tolerance = d
+	}
+}
+
+// WithWebhookSignatureHeader sets the signature header of the hmac-sha256
+// scheme.  Defaults to X-Signature.
+func WithWebhookSignatureHeader(header string) InboundWebhookOption {
+	return func(w *inboundWebhook) {
+		w.sigHeader = header
+	}
+}
+
+// WithWebhookTimestampHeader includes the named timestamp header (in unix
+// seconds) in hmac-sha256 signatures and enforces the replay tolerance.
+func WithWebhookTimestampHeader(header string) InboundWebhookOption {
+	return func(w *inboundWebhook) {
+		w.tsHeader = header
+	}
+}
+
+type inboundWebhook struct {
+	scheme    WebhookScheme
+	secret    string
+	handler   InboundWebhookHandler
+	tolerance time.Duration
+	sigHeader string
+	tsHeader  string
+	now       func() time.Time
+}
+
+// AddInboundWebhook serves an endpoint at path receiving third-party webhooks
+// signed with secret using scheme.  Verified webhooks are passed to handler;
+// see GRPCWebhookHandler to translate them into oracle service calls.
+func (c *Config) AddInboundWebhook(path string, scheme WebhookScheme, secret string, handler InboundWebhookHandler, opts ...InboundWebhookOption) {
+	if c == nil {
+		return
+	}
+	w := &inboundWebhook{
+		scheme:    scheme,
+		secret:    secret,
+		handler:   handler,
+		tolerance: defaultWebhookTolerance,
+		sigHeader: defaultWebhookSigHeader,
+		now:       time.Now,
+	}
+	for _, opt := range opts {
+		opt(w)
+	}
+	if c.inboundWebhooks == nil {
+		c.inboundWebhooks = make(map[string]*inboundWebhook)
+	}
+	c.inboundWebhooks[path] = w
+}
+
+// validInboundWebhooks validates the inbound webhook configuration.
+func (c *Config) validInboundWebhooks() error {
+	for path, w := range c.inboundWebhooks {
+		switch w.scheme {
+		case WebhookSchemeStripe, WebhookSchemeGitHub, WebhookSchemeHMACSHA256:
+		default:
+			return fmt.Errorf("inbound webhook %s: unsupported scheme %q", path, w.scheme)
+		}
+		if w.secret == "" {
+			return fmt.Errorf("inbound webhook %s: missing secret", path)
+		}
+		if w.handler == nil {
+			return fmt.Errorf("inbound webhook %s: missing handler", path)
+		}
+	}
+	return nil
+}
+
+// GRPCWebhookHandler translates webhooks into calls to a method of the oracle
+// service.  The body is unmarshaled from JSON into the request message
+// returned by newReq, ignoring unknown fields.
+func GRPCWebhookHandler[K proto.Message, R proto.Message](newReq func() K, method func(context.Context, K) (R, error)) InboundWebhookHandler {
+	return func(ctx context.Context, body []byte) error {
+		req := newReq()
+		if err := (protojson.UnmarshalOptions{DiscardUnknown: true}).Unmarshal(body, req); err != nil {
+			return fmt.Errorf("webhook unmarshal: %w", err)
+		}
+		_, err := method(ctx, req)
+		return err
+	}
+}
+
+func webhookMAC(secret string, parts ...[]byte) []byte {
+	mac := hmac.New(sha256.New, []byte(secret))
+	for _, p := range parts {
+		mac.Write(p)
+	}
+	return mac.Sum(nil)
+}
+
+func hexEqual(sig string, mac []byte) bool {
+	b, err := hex.DecodeString(sig)
+	return err == nil && hmac.Equal(b, mac)
+}
+
+// checkTimestamp enforces the replay tolerance on a unix timestamp.
+func (w *inboundWebhook) checkTimestamp(ts string) error {
+	sec, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return errWebhookSignature
+	}
+	age := w.now().Sub(time.Unix(sec, 0))
+	if age > w.tolerance || age < -w.tolerance {
+		return fmt.Errorf("webhook timestamp outside tolerance")
+	}
+	return nil
+}
+
+// verify checks the signature of a webhook with the given body.
+func (w *inboundWebhook) verify(h http.Header, body []byte) error {
+	switch w.scheme {
+	case WebhookSchemeStripe:
+		var ts string
+		var sigs []string
+		for _, part := range strings.Split(h.Get("Stripe-Signature"), ",") {
+			k, v, _ := strings.Cut(strings.TrimSpace(part), "=")
+			switch k {
+			case "t":
+				ts = v
+			case "v1":
+				sigs = append(sigs, v)
+			}
+		}
+		if err := w.checkTimestamp(ts); err != nil {
+			return err
+		}
+		mac := webhookMAC(w.secret, []byte(ts), []byte("."), body)
+		for _, sig := range sigs {
+			if hexEqual(sig, mac) {
+				return nil
+			}
+		}
+	case WebhookSchemeGitHub:
+		sig, ok := strings.CutPrefix(h.Get("X-Hub-Signature-256"), "sha256=")
+		if ok && hexEqual(sig, webhookMAC(w.secret, body)) {
+			return nil
+		}
+	case WebhookSchemeHMACSHA256:
+		sig := strings.TrimPrefix(h.Get(w.sigHeader), "sha256=")
+		mac := webhookMAC(w.secret, body)
+		if w.tsHeader != "" {
+			ts := h.Get(w.tsHeader)
+			if err := w.checkTimestamp(ts); err != nil {
+				return err
+			}
+			mac = webhookMAC(w.secret, []byte(ts), []byte("."), body)
+		}
+		if hexEqual(sig, mac) {
+			return nil
+		}
+	}
+	return errWebhookSignature
+}
+
+func (orc *Oracle) inboundWebhookHandler(w *inboundWebhook) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		ctx := orc.httpMetadataContext(r)
+		writeEx := func(code int, msg string) {
+			ex := svcerr.BusinessException(ctx, msg)
+			if code == http.StatusUnauthorized {
+				ex = svcerr.SecurityException(ctx, msg)
+			} else if code >= 500 {
+				ex = svcerr.ServiceException(ctx, msg)
+			}
+			if err := writeExceptionHTTP(rw, code, ex); err != nil {
+				orc.log(ctx).WithError(err).Errorf("webhook response error")
+			}
+		}
+		if r.Method != http.MethodPost {
+			writeEx(http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+		body, err := io.ReadAll(io.LimitReader(r.Body, defaultInboundWebhookMaxLen+1))
+		if err != nil {
+			writeEx(http.StatusBadRequest, "unable to read body")
+			return
+		}
+		if len(body) > defaultInboundWebhookMaxLen {
+			writeEx(http.StatusRequestEntityTooLarge, "body too large")
+			return
+		}
+		// Preserve the raw body for any handler reading the request.
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		if err := w.verify(r.Header, body); err != nil {
+			orc.log(ctx).WithError(err).WithField("path", r.URL.Path).Warnf("webhook verification failed")
+			writeEx(http.StatusUnauthorized, "invalid signature")
+			return
+		}
+		if err := w.handler(ctx, body); err != nil {
+			orc.log(ctx).WithError(err).WithField("path", r.URL.Path).Errorf("webhook handler")
+			writeEx(http.StatusInternalServerError, "webhook failed")
+			return
+		}
+		rw.Header().Set("Content-Type", "application/json")
+		rw.WriteHeader(http.StatusOK)
+		if _, err := rw.Write([]byte("{}")); err != nil {
+			orc.log(ctx).WithError(err).Errorf("webhook response error")
+		}
+	})
+}
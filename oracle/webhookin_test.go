@@ -0,0 +1,104 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package oracle
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestInboundWebhook(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	body := []byte(`{"id":"evt_1"}`)
+	var handled []byte
+	handler := func(ctx context.Context, b []byte) error {
+		handled = b
+		return nil
+	}
+	orc := newTestOracle(t, DefaultConfig())
+
+	var tests = []struct {
+		name   string
+		scheme WebhookScheme
+		header http.Header
+		code   int
+	}{
+		{
+			name:   "stripe",
+			scheme: WebhookSchemeStripe,
+			header: http.Header{"Stripe-Signature": {SignWebhook("s", now, body)}},
+			code:   http.StatusOK,
+		},
+		{
+			name:   "stripe replay",
+			scheme: WebhookSchemeStripe,
+			header: http.Header{"Stripe-Signature": {SignWebhook("s", now.Add(-time.Hour), body)}},
+			code:   http.StatusUnauthorized,
+		},
+		{
+			name:   "github",
+			scheme: WebhookSchemeGitHub,
+			header: http.Header{"X-Hub-Signature-256": {"sha256=" + hex.EncodeToString(webhookMAC("s", body))}},
+			code:   http.StatusOK,
+		},
+		{
+			name:   "github wrong secret",
+			scheme: WebhookSchemeGitHub,
+			header: http.Header{"X-Hub-Signature-256": {"sha256=" + hex.EncodeToString(webhookMAC("x", body))}},
+			code:   http.StatusUnauthorized,
+		},
+		{
+			name:   "generic",
+			scheme: WebhookSchemeHMACSHA256,
+			header: http.Header{"X-Signature": {hex.EncodeToString(webhookMAC("s", body))}},
+			code:   http.StatusOK,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handled = nil
+			cfg := DefaultConfig()
+			cfg.AddInboundWebhook("/v1/webhook", tt.scheme, "s", handler)
+			require.NoError(t, cfg.validInboundWebhooks())
+			w := cfg.inboundWebhooks["/v1/webhook"]
+			w.now = func() time.Time { return now }
+			req := httptest.NewRequest(http.MethodPost, "/v1/webhook", bytes.NewReader(body))
+			req.Header = tt.header
+			rr := httptest.NewRecorder()
+			orc.inboundWebhookHandler(w).ServeHTTP(rr, req)
+			require.Equal(t, tt.code, rr.Code)
+			if tt.code == http.StatusOK {
+				require.Equal(t, body, handled)
+			} else {
+				require.Nil(t, handled)
+			}
+		})
+	}
+}
+
+func TestInboundWebhookTimestampHeader(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	body := []byte(`{}`)
+	cfg := DefaultConfig()
+	cfg.AddInboundWebhook("/v1/webhook", WebhookSchemeHMACSHA256, "s",
+		func(ctx context.Context, b []byte) error { return nil },
+		WithWebhookTimestampHeader("X-Timestamp"))
+	w := cfg.inboundWebhooks["/v1/webhook"]
+	w.now = func() time.Time { return now }
+	ts := strconv.FormatInt(now.Unix(), 10)
+	h := http.Header{
+		"X-Timestamp": {ts},
+		"X-Signature": {"sha256=" + webhookHMAC("s", ts, body)},
+	}
+	require.NoError(t, w.verify(h, body))
+	h.Set("X-Timestamp", strconv.FormatInt(now.Unix()-3600, 10))
+	require.Error(t, w.verify(h, body))
+}
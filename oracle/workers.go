@@ -0,0 +1,171 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package oracle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/luthersystems/svc/grpclogging"
+	"github.com/luthersystems/svc/opttrace"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	defaultBackgroundWorkers      = 16
+	defaultBackgroundDrainTimeout = 30 * time.Second
+)
+
+// ErrOracleStopped is returned by Go once the oracle is shutting down.
+var ErrOracleStopped = errors.New("oracle stopped")
+
+var (
+	backgroundTaskDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "background_task_duration_seconds",
+			Help:    "Duration of background tasks started with Go, partitioned by name and result.",
+			Buckets: prometheus.DefBuckets,
+		},
+		[]string{"name", "result"},
+	)
+	backgroundTasksRunning = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "background_tasks_running",
+			Help: "How many background tasks started with Go are running.",
+		},
+	)
+)
+
+// validBackgroundWorkers validates the background worker configuration.
+func (c *Config) validBackgroundWorkers() error {
+	if c.BackgroundWorkers < 0 {
+		return fmt.Errorf("invalid background workers")
+	}
+	if c.BackgroundDrainTimeout < 0 {
+		return fmt.Errorf("invalid background drain timeout")
+	}
+	return nil
+}
+
+// workerPool bounds and tracks background tasks.
+type workerPool struct {
+	slots        chan struct{}
+	drainTimeout time.Duration
+	// ctx is the parent of task contexts, cancelled if tasks do not finish
+	// within the drain timeout.
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mut     sync.Mutex
+	stopped bool
+	wg      sync.WaitGroup
+}
+
+func newWorkerPool(cfg *Config) *workerPool {
+	size := cfg.BackgroundWorkers
+	if size == 0 {
+		size = defaultBackgroundWorkers
+	}
+	drainTimeout := cfg.BackgroundDrainTimeout
+	if drainTimeout == 0 {
+		drainTimeout = defaultBackgroundDrainTimeout
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &workerPool{
+		slots:        make(chan struct{}, size),
+		drainTimeout: drainTimeout,
+		ctx:          ctx,
+		cancel:       cancel,
+	}
+}
+
+// acquire reserves a worker, waiting until one is free or ctx is done.
+func (p *workerPool) acquire(ctx context.Context) error {
+	select {
+	case p.slots <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	p.mut.Lock()
+	defer p.mut.Unlock()
+	if p.stopped {
+		<-p.slots
+		return ErrOracleStopped
+	}
+	p.wg.Add(1)
+	return nil
+}
+
+func (p *workerPool) release() {
+	<-p.slots
+	p.wg.Done()
+}
+
+// close stops accepting tasks and waits for running tasks to finish.  Tasks
+// still running after the drain timeout have their context cancelled.
+func (p *workerPool) close(log *logrus.Entry) {
+	p.mut.Lock()
+	p.stopped = true
+	p.mut.Unlock()
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(p.drainTimeout):
+		log.Warnf("background tasks did not finish before drain timeout, cancelling")
+		p.cancel()
+		<-done
+	}
+	p.cancel()
+}
+
+// detachedContext returns a context for a background task started from ctx.
+// It is not cancelled with ctx, but carries its log fields and trace
+// baggage.
+func (p *workerPool) detachedContext(ctx context.Context) context.Context {
+	taskCtx := grpclogging.NewContext(p.ctx)
+	grpclogging.AddLogrusFields(taskCtx, grpclogging.GetLogrusFields(ctx))
+	return opttrace.CaptureOrigin(ctx).Context(taskCtx)
+}
+
+// Go runs fn in the background on a bounded pool of workers, e.g. to fan out
+// notifications from a handler without delaying its response.  Go waits for
+// a free worker until ctx is done.  The task context is not cancelled with
+// ctx, but preserves its log fields and baggage, and the task span is linked
+// to the span of ctx.  Panics are recovered and logged.  Running tasks are
+// drained when the oracle shuts down, after which Go returns
+// ErrOracleStopped.
+func (orc *Oracle) Go(ctx context.Context, name string, fn func(ctx context.Context)) error {
+	if err := orc.workers.acquire(ctx); err != nil {
+		return err
+	}
+	origin := opttrace.CaptureOrigin(ctx)
+	taskCtx := orc.workers.detachedContext(ctx)
+	backgroundTasksRunning.Inc()
+	go func() {
+		defer orc.workers.release()
+		defer backgroundTasksRunning.Dec()
+		taskCtx, span := orc.tracer.LinkedSpan(taskCtx, origin, name)
+		defer span.End()
+		log := orc.log(taskCtx).WithField("background_task", name)
+		start := time.Now()
+		result := "success"
+		defer func() {
+			if p := recover(); p != nil {
+				result = "panic"
+				log.WithError(fmt.Errorf("panic: %v", p)).WithField("stack", string(debug.Stack())).Errorf("background task panic")
+			}
+			backgroundTaskDuration.WithLabelValues(name, result).Observe(time.Since(start).Seconds())
+		}()
+		fn(taskCtx)
+	}()
+	return nil
+}
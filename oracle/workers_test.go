@@ -0,0 +1,61 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package oracle
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/luthersystems/svc/grpclogging"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGo(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.BackgroundWorkers = 2
+	orc := newTestOracle(t, cfg)
+
+	ctx, cancel := context.WithCancel(grpclogging.NewContext(context.Background()))
+	grpclogging.AddLogrusField(ctx, "req_id", "req-1")
+	release := make(chan struct{})
+	var ran atomic.Int32
+	for i := 0; i < 2; i++ {
+		require.NoError(t, orc.Go(ctx, "notify", func(taskCtx context.Context) {
+			<-release
+			require.NoError(t, taskCtx.Err())
+			require.Equal(t, "req-1", grpclogging.ReqID(taskCtx))
+			ran.Add(1)
+		}))
+	}
+	// The pool is full, so Go waits until ctx is done.
+	waitCtx, waitCancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer waitCancel()
+	require.ErrorIs(t, orc.Go(waitCtx, "notify", func(context.Context) {}), context.DeadlineExceeded)
+
+	// Tasks are detached from the request.
+	cancel()
+	close(release)
+	orc.workers.close(orc.logBase)
+	require.EqualValues(t, 2, ran.Load())
+	require.ErrorIs(t, orc.Go(context.Background(), "notify", func(context.Context) {}), ErrOracleStopped)
+}
+
+func taskCount(t *testing.T, name string, result string) uint64 {
+	m := &dto.Metric{}
+	require.NoError(t, backgroundTaskDuration.WithLabelValues(name, result).(prometheus.Metric).Write(m))
+	return m.GetHistogram().GetSampleCount()
+}
+
+func TestGoPanic(t *testing.T) {
+	orc := newTestOracle(t, DefaultConfig())
+	panics := taskCount(t, "boom", "panic")
+	require.NoError(t, orc.Go(context.Background(), "boom", func(context.Context) {
+		panic("boom")
+	}))
+	orc.workers.close(orc.logBase)
+	require.Equal(t, panics+1, taskCount(t, "boom", "panic"))
+}
@@ -0,0 +1,32 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+/*
+Package promreg registers prometheus collectors which may already have been
+registered, e.g. by a previous server or interceptor in the same process.
+*/
+package promreg
+
+import (
+	"errors"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Register registers c with reg.  If an identical collector is already
+// registered with reg the existing collector is returned, so that callers
+// populate the collector reg exposes.  A nil reg disables registration.
+func Register[C prometheus.Collector](reg prometheus.Registerer, c C) (C, error) {
+	if reg == nil {
+		return c, nil
+	}
+	if err := reg.Register(c); err != nil {
+		var are prometheus.AlreadyRegisteredError
+		if errors.As(err, &are) {
+			if existing, ok := are.ExistingCollector.(C); ok {
+				return existing, nil
+			}
+		}
+		return c, err
+	}
+	return c, nil
+}
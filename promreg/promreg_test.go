@@ -0,0 +1,31 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package promreg
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+)
+
+func newCounter() *prometheus.CounterVec {
+	return prometheus.NewCounterVec(prometheus.CounterOpts{Name: "test_total", Help: "Test."}, []string{"x"})
+}
+
+func TestRegister(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	c1, err := Register(reg, newCounter())
+	require.NoError(t, err)
+	c2, err := Register(reg, newCounter())
+	require.NoError(t, err)
+	require.Same(t, c1, c2)
+
+	_, err = Register(reg, prometheus.NewCounterVec(prometheus.CounterOpts{Name: "test_total", Help: "Other."}, []string{"y"}))
+	require.Error(t, err)
+
+	c3 := newCounter()
+	c, err := Register(nil, c3)
+	require.NoError(t, err)
+	require.Same(t, c3, c)
+}
@@ -0,0 +1,16 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+/*
+Package protos provides helpers for working with the protobuf messages of
+services built on this module.
+
+ToLVal and FromLVal convert messages to and from ELPS values, so Go code in
+the oracle and ELPS code in the phylum share one canonical data shape:
+
+	v, err := protos.ToLVal(req)
+	if err != nil {
+		return err
+	}
+	// (get v "accountId") in ELPS
+*/
+package protos
@@ -0,0 +1,413 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package protos
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/luthersystems/elps/lisp"
+	"github.com/luthersystems/elps/lisp/lisplib/libjson"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// ToLVal converts msg to the ELPS value the phylum would load from its JSON
+// encoding, without encoding it.  Messages become sorted maps keyed by the
+// field json_name, repeated fields become vectors, enums become their value
+// names, integers become ints and bytes become bytes.  Only populated fields
+// are included.  Well-known types, such as google.protobuf.Timestamp, take
+// their canonical JSON form.
+func ToLVal(msg proto.Message) (*lisp.LVal, error) {
+	return messageToLVal(msg.ProtoReflect())
+}
+
+// FromLVal populates msg from an ELPS value in the form returned by ToLVal.
+// Fields may be keyed by json_name or by proto field name, and enums may be
+// given as names or numbers.  Nil values leave fields unset.
+func FromLVal(v *lisp.LVal, msg proto.Message) error {
+	proto.Reset(msg)
+	return lvalToMessage(v, msg.ProtoReflect())
+}
+
+// wellKnownTypes are the well-known types with a special JSON form.
+var wellKnownTypes = map[protoreflect.FullName]bool{
+	"google.protobuf.Any":         true,
+	"google.protobuf.Timestamp":   true,
+	"google.protobuf.Duration":    true,
+	"google.protobuf.Struct":      true,
+	"google.protobuf.Value":       true,
+	"google.protobuf.ListValue":   true,
+	"google.protobuf.FieldMask":   true,
+	"google.protobuf.Empty":       true,
+	"google.protobuf.DoubleValue": true,
+	"google.protobuf.FloatValue":  true,
+	"google.protobuf.Int64Value":  true,
+	"google.protobuf.UInt64Value": true,
+	"google.protobuf.Int32Value":  true,
+	"google.protobuf.UInt32Value": true,
+	"google.protobuf.BoolValue":   true,
+	"google.protobuf.StringValue": true,
+	"google.protobuf.BytesValue":  true,
+}
+
+// wellKnown returns true if md is a well-known type with a special JSON form.
+func wellKnown(md protoreflect.MessageDescriptor) bool {
+	return wellKnownTypes[md.FullName()]
+}
+
+func messageToLVal(m protoreflect.Message) (*lisp.LVal, error) {
+	if wellKnown(m.Descriptor()) {
+		b, err := protojson.Marshal(m.Interface())
+		if err != nil {
+			return nil, err
+		}
+		v := libjson.Load(b, false)
+		if v.Type == lisp.LError {
+			return nil, fmt.Errorf("%s: %v", m.Descriptor().FullName(), v)
+		}
+		return v, nil
+	}
+	out := lisp.SortedMap()
+	var err error
+	m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		var lv *lisp.LVal
+		lv, err = fieldToLVal(fd, v)
+		if err != nil {
+			err = fmt.Errorf("%s: %w", fd.JSONName(), err)
+			return false
+		}
+		out.MapSet(fd.JSONName(), lv)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func fieldToLVal(fd protoreflect.FieldDescriptor, v protoreflect.Value) (*lisp.LVal, error) {
+	switch {
+	case fd.IsList():
+		list := v.List()
+		cells := make([]*lisp.LVal, list.Len())
+		for i := range cells {
+			lv, err := singularToLVal(fd, list.Get(i))
+			if err != nil {
+				return nil, err
+			}
+			cells[i] = lv
+		}
+		return lisp.Vector(cells), nil
+	case fd.IsMap():
+		out := lisp.SortedMap()
+		var err error
+		v.Map().Range(func(k protoreflect.MapKey, v protoreflect.Value) bool {
+			var lv *lisp.LVal
+			lv, err = singularToLVal(fd.MapValue(), v)
+			if err != nil {
+				return false
+			}
+			out.MapSet(k.String(), lv)
+			return true
+		})
+		if err != nil {
+			return nil, err
+		}
+		return out, nil
+	default:
+		return singularToLVal(fd, v)
+	}
+}
+
+func singularToLVal(fd protoreflect.FieldDescriptor, v protoreflect.Value) (*lisp.LVal, error) {
+	switch fd.Kind() {
+	case protoreflect.BoolKind:
+		return lisp.Bool(v.Bool()), nil
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind,
+		protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		return lisp.Int(int(v.Int())), nil
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind,
+		protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		if v.Uint() > math.MaxInt64 {
+			return nil, fmt.Errorf("integer overflow: %d", v.Uint())
+		}
+		return lisp.Int(int(v.Uint())), nil
+	case protoreflect.FloatKind, protoreflect.DoubleKind:
+		return lisp.Float(v.Float()), nil
+	case protoreflect.StringKind:
+		return lisp.String(v.String()), nil
+	case protoreflect.BytesKind:
+		return lisp.Bytes(append([]byte(nil), v.Bytes()...)), nil
+	case protoreflect.EnumKind:
+		if ev := fd.Enum().Values().ByNumber(v.Enum()); ev != nil {
+			return lisp.String(string(ev.Name())), nil
+		}
+		return lisp.Int(int(v.Enum())), nil
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		return messageToLVal(v.Message())
+	default:
+		return nil, fmt.Errorf("unsupported kind: %v", fd.Kind())
+	}
+}
+
+// lvalElements returns the elements of a vector or list.
+func lvalElements(v *lisp.LVal) ([]*lisp.LVal, error) {
+	switch {
+	case v.Type == lisp.LArray && v.Cells[0].Len() == 1:
+		return v.Cells[1].Cells, nil
+	case v.Type == lisp.LSExpr:
+		return v.Cells, nil
+	default:
+		return nil, fmt.Errorf("expected vector or list, got %v", v.Type)
+	}
+}
+
+// mapKey returns the string form of a sorted-map key.
+func mapKey(k *lisp.LVal) (string, error) {
+	switch k.Type {
+	case lisp.LString, lisp.LSymbol:
+		return k.Str, nil
+	default:
+		return "", fmt.Errorf("unsupported map key type: %v", k.Type)
+	}
+}
+
+// mapEntries returns the key-value pairs of a sorted map.
+func mapEntries(v *lisp.LVal) ([]*lisp.LVal, error) {
+	if v.Type != lisp.LSortMap {
+		return nil, fmt.Errorf("expected sorted-map, got %v", v.Type)
+	}
+	entries := v.MapEntries()
+	if entries.Type == lisp.LError {
+		return nil, fmt.Errorf("%v", entries)
+	}
+	return entries.Cells, nil
+}
+
+func lvalToMessage(v *lisp.LVal, m protoreflect.Message) error {
+	if v.IsNil() {
+		return nil
+	}
+	md := m.Descriptor()
+	if wellKnown(md) {
+		b, err := libjson.Dump(v, false)
+		if err != nil {
+			return err
+		}
+		return protojson.Unmarshal(b, m.Interface())
+	}
+	entries, err := mapEntries(v)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		name, err := mapKey(e.Cells[0])
+		if err != nil {
+			return err
+		}
+		fd := md.Fields().ByJSONName(name)
+		if fd == nil {
+			fd = md.Fields().ByName(protoreflect.Name(name))
+		}
+		if fd == nil {
+			return fmt.Errorf("%s: unknown field %q", md.FullName(), name)
+		}
+		if err := lvalToField(e.Cells[1], m, fd); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func lvalToField(v *lisp.LVal, m protoreflect.Message, fd protoreflect.FieldDescriptor) error {
+	if v.IsNil() {
+		return nil
+	}
+	switch {
+	case fd.IsList():
+		elems, err := lvalElements(v)
+		if err != nil {
+			return err
+		}
+		list := m.Mutable(fd).List()
+		for _, e := range elems {
+			if fd.Kind() == protoreflect.MessageKind || fd.Kind() == protoreflect.GroupKind {
+				elem := list.NewElement()
+				if err := lvalToMessage(e, elem.Message()); err != nil {
+					return err
+				}
+				list.Append(elem)
+				continue
+			}
+			pv, err := lvalToScalar(e, fd)
+			if err != nil {
+				return err
+			}
+			list.Append(pv)
+		}
+		return nil
+	case fd.IsMap():
+		entries, err := mapEntries(v)
+		if err != nil {
+			return err
+		}
+		mp := m.Mutable(fd).Map()
+		for _, e := range entries {
+			k, err := mapKey(e.Cells[0])
+			if err != nil {
+				return err
+			}
+			mk, err := parseMapKey(k, fd.MapKey())
+			if err != nil {
+				return err
+			}
+			vd := fd.MapValue()
+			if vd.Kind() == protoreflect.MessageKind {
+				val := mp.NewValue()
+				if err := lvalToMessage(e.Cells[1], val.Message()); err != nil {
+					return err
+				}
+				mp.Set(mk, val)
+				continue
+			}
+			pv, err := lvalToScalar(e.Cells[1], vd)
+			if err != nil {
+				return err
+			}
+			mp.Set(mk, pv)
+		}
+		return nil
+	case fd.Kind() == protoreflect.MessageKind || fd.Kind() == protoreflect.GroupKind:
+		return lvalToMessage(v, m.Mutable(fd).Message())
+	default:
+		pv, err := lvalToScalar(v, fd)
+		if err != nil {
+			return err
+		}
+		m.Set(fd, pv)
+		return nil
+	}
+}
+
+// parseMapKey converts a sorted-map key to a proto map key.
+func parseMapKey(k string, fd protoreflect.FieldDescriptor) (protoreflect.MapKey, error) {
+	if fd.Kind() == protoreflect.StringKind {
+		return protoreflect.ValueOfString(k).MapKey(), nil
+	}
+	pv, err := lvalToScalar(lisp.String(k), fd)
+	if err != nil {
+		return protoreflect.MapKey{}, err
+	}
+	return pv.MapKey(), nil
+}
+
+// lvalInt returns the integer value of an int, float with no fractional part,
+// or numeric string.
+func lvalInt(v *lisp.LVal) (int64, error) {
+	switch v.Type {
+	case lisp.LInt:
+		return int64(v.Int), nil
+	case lisp.LFloat:
+		if v.Float != math.Trunc(v.Float) {
+			return 0, fmt.Errorf("non-integer number: %v", v.Float)
+		}
+		return int64(v.Float), nil
+	case lisp.LString:
+		n, err := strconv.ParseInt(v.Str, 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid integer: %q", v.Str)
+		}
+		return n, nil
+	default:
+		return 0, fmt.Errorf("expected integer, got %v", v.Type)
+	}
+}
+
+func lvalToScalar(v *lisp.LVal, fd protoreflect.FieldDescriptor) (protoreflect.Value, error) {
+	switch fd.Kind() {
+	case protoreflect.BoolKind:
+		if v.Type != lisp.LSymbol || (v.Str != lisp.TrueSymbol && v.Str != lisp.FalseSymbol) {
+			return protoreflect.Value{}, fmt.Errorf("expected boolean, got %v", v)
+		}
+		return protoreflect.ValueOfBool(v.Str == lisp.TrueSymbol), nil
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		n, err := lvalInt(v)
+		if err != nil {
+			return protoreflect.Value{}, err
+		}
+		if n < math.MinInt32 || n > math.MaxInt32 {
+			return protoreflect.Value{}, fmt.Errorf("integer overflow: %d", n)
+		}
+		return protoreflect.ValueOfInt32(int32(n)), nil
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		n, err := lvalInt(v)
+		if err != nil {
+			return protoreflect.Value{}, err
+		}
+		return protoreflect.ValueOfInt64(n), nil
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		n, err := lvalInt(v)
+		if err != nil {
+			return protoreflect.Value{}, err
+		}
+		if n < 0 || n > math.MaxUint32 {
+			return protoreflect.Value{}, fmt.Errorf("integer overflow: %d", n)
+		}
+		return protoreflect.ValueOfUint32(uint32(n)), nil
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		n, err := lvalInt(v)
+		if err != nil {
+			return protoreflect.Value{}, err
+		}
+		if n < 0 {
+			return protoreflect.Value{}, fmt.Errorf("integer overflow: %d", n)
+		}
+		return protoreflect.ValueOfUint64(uint64(n)), nil
+	case protoreflect.FloatKind, protoreflect.DoubleKind:
+		var f float64
+		switch v.Type {
+		case lisp.LFloat:
+			f = v.Float
+		case lisp.LInt:
+			f = float64(v.Int)
+		default:
+			return protoreflect.Value{}, fmt.Errorf("expected number, got %v", v.Type)
+		}
+		if fd.Kind() == protoreflect.FloatKind {
+			return protoreflect.ValueOfFloat32(float32(f)), nil
+		}
+		return protoreflect.ValueOfFloat64(f), nil
+	case protoreflect.StringKind:
+		if v.Type != lisp.LString {
+			return protoreflect.Value{}, fmt.Errorf("expected string, got %v", v.Type)
+		}
+		return protoreflect.ValueOfString(v.Str), nil
+	case protoreflect.BytesKind:
+		switch v.Type {
+		case lisp.LBytes:
+			return protoreflect.ValueOfBytes(append([]byte(nil), v.Bytes()...)), nil
+		case lisp.LString:
+			return protoreflect.ValueOfBytes([]byte(v.Str)), nil
+		default:
+			return protoreflect.Value{}, fmt.Errorf("expected bytes, got %v", v.Type)
+		}
+	case protoreflect.EnumKind:
+		if v.Type == lisp.LString || v.Type == lisp.LSymbol {
+			ev := fd.Enum().Values().ByName(protoreflect.Name(v.Str))
+			if ev == nil {
+				return protoreflect.Value{}, fmt.Errorf("unknown %s value %q", fd.Enum().FullName(), v.Str)
+			}
+			return protoreflect.ValueOfEnum(ev.Number()), nil
+		}
+		n, err := lvalInt(v)
+		if err != nil {
+			return protoreflect.Value{}, err
+		}
+		return protoreflect.ValueOfEnum(protoreflect.EnumNumber(n)), nil
+	default:
+		return protoreflect.Value{}, fmt.Errorf("unsupported kind: %v", fd.Kind())
+	}
+}
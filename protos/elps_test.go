@@ -0,0 +1,99 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package protos
+
+import (
+	"testing"
+	"time"
+
+	common "buf.build/gen/go/luthersystems/protos/protocolbuffers/go/common/v1"
+	"github.com/luthersystems/elps/lisp"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestToLVal(t *testing.T) {
+	msg := &common.Exception{
+		Id:                "e1",
+		Type:              common.Exception_BUSINESS,
+		Description:       "bad",
+		ExceptionMetadata: map[string]string{"b": "2", "a": "1"},
+	}
+	v, err := ToLVal(msg)
+	require.NoError(t, err)
+	require.Equal(t, lisp.LSortMap, v.Type)
+	require.Equal(t, `(sorted-map "description" "bad" "exceptionMetadata" (sorted-map "a" "1" "b" "2") "id" "e1" "type" "BUSINESS")`, v.String())
+
+	got := &common.Exception{}
+	require.NoError(t, FromLVal(v, got))
+	require.True(t, proto.Equal(msg, got))
+}
+
+func TestLValRoundTrip(t *testing.T) {
+	msg := &descriptorpb.DescriptorProto{
+		Name: proto.String("Account"),
+		Field: []*descriptorpb.FieldDescriptorProto{
+			{
+				Name:     proto.String("account_id"),
+				JsonName: proto.String("accountId"),
+				Number:   proto.Int32(1),
+				Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+				Type:     descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum(),
+			},
+		},
+		Options:       &descriptorpb.MessageOptions{Deprecated: proto.Bool(true)},
+		ReservedRange: []*descriptorpb.DescriptorProto_ReservedRange{{Start: proto.Int32(5), End: proto.Int32(10)}},
+	}
+	v, err := ToLVal(msg)
+	require.NoError(t, err)
+	fields := v.MapGet("field")
+	require.Equal(t, lisp.LArray, fields.Type)
+	field := fields.Cells[1].Cells[0]
+	require.Equal(t, 1, field.MapGet("number").Int)
+	require.Equal(t, "TYPE_STRING", field.MapGet("type").Str)
+	require.Equal(t, lisp.Bool(true).Str, v.MapGet("options").MapGet("deprecated").Str)
+
+	got := &descriptorpb.DescriptorProto{}
+	require.NoError(t, FromLVal(v, got))
+	require.True(t, proto.Equal(msg, got))
+}
+
+func TestFromLVal(t *testing.T) {
+	v := lisp.SortedMap()
+	v.MapSet("name", lisp.String("x"))
+	v.MapSet("number", lisp.Float(3))
+	v.MapSet("type", lisp.Int(int(descriptorpb.FieldDescriptorProto_TYPE_BOOL)))
+	v.MapSet("json_name", lisp.String("xx"))
+	v.MapSet("label", lisp.Nil())
+	got := &descriptorpb.FieldDescriptorProto{}
+	require.NoError(t, FromLVal(v, got))
+	require.Equal(t, "x", got.GetName())
+	require.Equal(t, int32(3), got.GetNumber())
+	require.Equal(t, descriptorpb.FieldDescriptorProto_TYPE_BOOL, got.GetType())
+	require.Equal(t, "xx", got.GetJsonName())
+	require.Nil(t, got.Label)
+
+	for name, bad := range map[string]func(*lisp.LVal){
+		"unknown field": func(v *lisp.LVal) { v.MapSet("bogus", lisp.Int(1)) },
+		"wrong type":    func(v *lisp.LVal) { v.MapSet("name", lisp.Int(1)) },
+		"fraction":      func(v *lisp.LVal) { v.MapSet("number", lisp.Float(1.5)) },
+		"unknown enum":  func(v *lisp.LVal) { v.MapSet("type", lisp.String("TYPE_BOGUS")) },
+	} {
+		v := lisp.SortedMap()
+		bad(v)
+		require.Error(t, FromLVal(v, &descriptorpb.FieldDescriptorProto{}), name)
+	}
+}
+
+func TestLValWellKnown(t *testing.T) {
+	ts := timestamppb.New(time.Date(2024, 6, 1, 2, 0, 0, 0, time.UTC))
+	v, err := ToLVal(ts)
+	require.NoError(t, err)
+	require.Equal(t, lisp.String("2024-06-01T02:00:00Z").Str, v.Str)
+
+	got := &timestamppb.Timestamp{}
+	require.NoError(t, FromLVal(v, got))
+	require.True(t, proto.Equal(ts, got))
+}
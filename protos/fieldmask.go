@@ -0,0 +1,161 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package protos
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+)
+
+// ErrInvalidFieldMask is returned when a field mask path does not name a
+// field of the message.  Handlers typically report it as InvalidArgument.
+var ErrInvalidFieldMask = errors.New("invalid field mask")
+
+// ValidateFieldMask returns an error wrapping ErrInvalidFieldMask if a path
+// of mask does not name a field of msg.  Paths use proto field names, e.g.
+// "address.postal_code".  Only the last field of a path may be repeated or a
+// map.
+func ValidateFieldMask(msg proto.Message, mask *fieldmaskpb.FieldMask) error {
+	md := msg.ProtoReflect().Descriptor()
+	for _, path := range mask.GetPaths() {
+		if _, err := resolvePath(md, path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resolvePath returns the fields named by the segments of path.
+func resolvePath(md protoreflect.MessageDescriptor, path string) ([]protoreflect.FieldDescriptor, error) {
+	if path == "" {
+		return nil, fmt.Errorf("%w: empty path", ErrInvalidFieldMask)
+	}
+	segments := strings.Split(path, ".")
+	fields := make([]protoreflect.FieldDescriptor, len(segments))
+	for i, name := range segments {
+		if md == nil {
+			return nil, fmt.Errorf("%w: %q: %s is not a message", ErrInvalidFieldMask, path, fields[i-1].Name())
+		}
+		fd := md.Fields().ByName(protoreflect.Name(name))
+		if fd == nil {
+			return nil, fmt.Errorf("%w: %q: unknown field %q of %s", ErrInvalidFieldMask, path, name, md.FullName())
+		}
+		fields[i] = fd
+		md = nil
+		if fd.Message() != nil && !fd.IsList() && !fd.IsMap() {
+			md = fd.Message()
+		}
+	}
+	return fields, nil
+}
+
+// ApplyFieldMask copies the fields of src named by mask to dst, which must
+// be messages of the same type.  A field unset in src is cleared in dst, so
+// the mask can be used to clear fields.  Repeated and map fields are
+// replaced.  The mask is validated before dst is modified.
+func ApplyFieldMask(dst, src proto.Message, mask *fieldmaskpb.FieldMask) error {
+	dm, sm := dst.ProtoReflect(), src.ProtoReflect()
+	if dm.Descriptor().FullName() != sm.Descriptor().FullName() {
+		return fmt.Errorf("apply field mask: mismatched types %s and %s", dm.Descriptor().FullName(), sm.Descriptor().FullName())
+	}
+	paths := make([][]protoreflect.FieldDescriptor, 0, len(mask.GetPaths()))
+	for _, path := range mask.GetPaths() {
+		fields, err := resolvePath(dm.Descriptor(), path)
+		if err != nil {
+			return err
+		}
+		paths = append(paths, fields)
+	}
+	for _, fields := range paths {
+		applyPath(dm, sm, fields)
+	}
+	return nil
+}
+
+// applyPath copies the field at the end of path from src to dst.
+func applyPath(dst, src protoreflect.Message, path []protoreflect.FieldDescriptor) {
+	fd := path[0]
+	if len(path) > 1 {
+		// Below a message unset in src, the fields of dst are cleared.
+		if src.Has(fd) || dst.Has(fd) {
+			applyPath(dst.Mutable(fd).Message(), src.Get(fd).Message(), path[1:])
+		}
+		return
+	}
+	if !src.Has(fd) {
+		dst.Clear(fd)
+		return
+	}
+	dst.Set(fd, cloneValue(dst, fd, src.Get(fd)))
+}
+
+// cloneValue returns a deep copy of v, a value of the field fd of another
+// message, for setting on m, so m does not share storage with the source.
+func cloneValue(m protoreflect.Message, fd protoreflect.FieldDescriptor, v protoreflect.Value) protoreflect.Value {
+	switch {
+	case fd.IsList():
+		src := v.List()
+		nv := m.NewField(fd)
+		list := nv.List()
+		for i := 0; i < src.Len(); i++ {
+			list.Append(cloneSingular(fd, src.Get(i)))
+		}
+		return nv
+	case fd.IsMap():
+		nv := m.NewField(fd)
+		mp := nv.Map()
+		v.Map().Range(func(k protoreflect.MapKey, v protoreflect.Value) bool {
+			mp.Set(k, cloneSingular(fd.MapValue(), v))
+			return true
+		})
+		return nv
+	default:
+		return cloneSingular(fd, v)
+	}
+}
+
+// cloneSingular returns a deep copy of a singular value.
+func cloneSingular(fd protoreflect.FieldDescriptor, v protoreflect.Value) protoreflect.Value {
+	switch {
+	case fd.Message() != nil:
+		return protoreflect.ValueOfMessage(proto.Clone(v.Message().Interface()).ProtoReflect())
+	case fd.Kind() == protoreflect.BytesKind:
+		return protoreflect.ValueOfBytes(append([]byte(nil), v.Bytes()...))
+	default:
+		return v
+	}
+}
+
+// MergePatch applies patch to dst, which must be messages of the same type.
+// With a non-empty mask, MergePatch is equivalent to ApplyFieldMask.
+// Otherwise the populated fields of patch are merged into dst: singular
+// message fields are merged recursively, while scalar, repeated and map
+// fields replace those of dst.  Unlike proto.Merge, repeated fields are not
+// appended.
+func MergePatch(dst, patch proto.Message, mask *fieldmaskpb.FieldMask) error {
+	if len(mask.GetPaths()) > 0 {
+		return ApplyFieldMask(dst, patch, mask)
+	}
+	dm, pm := dst.ProtoReflect(), patch.ProtoReflect()
+	if dm.Descriptor().FullName() != pm.Descriptor().FullName() {
+		return fmt.Errorf("merge patch: mismatched types %s and %s", dm.Descriptor().FullName(), pm.Descriptor().FullName())
+	}
+	mergePatch(dm, pm)
+	return nil
+}
+
+func mergePatch(dst, patch protoreflect.Message) {
+	patch.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		if fd.Message() != nil && !fd.IsList() && !fd.IsMap() && !wellKnown(fd.Message()) {
+			mergePatch(dst.Mutable(fd).Message(), v.Message())
+			return true
+		}
+		dst.Set(fd, cloneValue(dst, fd, v))
+		return true
+	})
+}
@@ -0,0 +1,87 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package protos
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+)
+
+func testMessage() *descriptorpb.DescriptorProto {
+	return &descriptorpb.DescriptorProto{
+		Name: proto.String("Account"),
+		Field: []*descriptorpb.FieldDescriptorProto{
+			{Name: proto.String("id")},
+		},
+		Options: &descriptorpb.MessageOptions{
+			Deprecated: proto.Bool(true),
+			MapEntry:   proto.Bool(false),
+		},
+	}
+}
+
+func TestValidateFieldMask(t *testing.T) {
+	msg := testMessage()
+	require.NoError(t, ValidateFieldMask(msg, &fieldmaskpb.FieldMask{Paths: []string{"name", "field", "options.deprecated"}}))
+	for _, path := range []string{"", "bogus", "options.bogus", "field.name", "name.length"} {
+		err := ValidateFieldMask(msg, &fieldmaskpb.FieldMask{Paths: []string{path}})
+		require.ErrorIs(t, err, ErrInvalidFieldMask, path)
+	}
+}
+
+func TestApplyFieldMask(t *testing.T) {
+	dst := testMessage()
+	src := &descriptorpb.DescriptorProto{
+		Name:    proto.String("Customer"),
+		Field:   []*descriptorpb.FieldDescriptorProto{{Name: proto.String("customer_id")}},
+		Options: &descriptorpb.MessageOptions{MapEntry: proto.Bool(true)},
+	}
+	err := ApplyFieldMask(dst, src, &fieldmaskpb.FieldMask{Paths: []string{"field", "options.deprecated", "options.map_entry"}})
+	require.NoError(t, err)
+	require.Equal(t, "Account", dst.GetName())
+	require.Len(t, dst.GetField(), 1)
+	require.Equal(t, "customer_id", dst.GetField()[0].GetName())
+	require.Nil(t, dst.GetOptions().Deprecated)
+	require.True(t, dst.GetOptions().GetMapEntry())
+
+	// dst does not share storage with src.
+	src.Field[0].Name = proto.String("changed")
+	require.Equal(t, "customer_id", dst.GetField()[0].GetName())
+
+	// clearing a nested field below an unset message.
+	dst = testMessage()
+	require.NoError(t, ApplyFieldMask(dst, &descriptorpb.DescriptorProto{}, &fieldmaskpb.FieldMask{Paths: []string{"options.deprecated"}}))
+	require.Nil(t, dst.GetOptions().Deprecated)
+	require.False(t, dst.GetOptions().GetMapEntry())
+
+	// invalid masks leave dst unmodified.
+	dst = testMessage()
+	err = ApplyFieldMask(dst, src, &fieldmaskpb.FieldMask{Paths: []string{"name", "bogus"}})
+	require.ErrorIs(t, err, ErrInvalidFieldMask)
+	require.True(t, proto.Equal(testMessage(), dst))
+
+	require.Error(t, ApplyFieldMask(dst, &descriptorpb.FieldDescriptorProto{}, &fieldmaskpb.FieldMask{}))
+}
+
+func TestMergePatch(t *testing.T) {
+	dst := testMessage()
+	patch := &descriptorpb.DescriptorProto{
+		Field:   []*descriptorpb.FieldDescriptorProto{{Name: proto.String("customer_id")}},
+		Options: &descriptorpb.MessageOptions{MapEntry: proto.Bool(true)},
+	}
+	require.NoError(t, MergePatch(dst, patch, nil))
+	require.Equal(t, "Account", dst.GetName())
+	require.Len(t, dst.GetField(), 1)
+	require.Equal(t, "customer_id", dst.GetField()[0].GetName())
+	require.True(t, dst.GetOptions().GetDeprecated())
+	require.True(t, dst.GetOptions().GetMapEntry())
+
+	dst = testMessage()
+	require.NoError(t, MergePatch(dst, patch, &fieldmaskpb.FieldMask{Paths: []string{"name"}}))
+	require.Empty(t, dst.GetName())
+	require.Equal(t, "id", dst.GetField()[0].GetName())
+}
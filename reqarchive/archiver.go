@@ -26,7 +26,11 @@ type archiver struct {
 	logBase      *logrus.Entry
 	traceHeader  string
 	ignoredPaths map[string]bool
+	filter       filter
+	index        bool
 	backend      backend
+	redact       redaction
+	now          func() time.Time
 }
 
 type backend interface {
@@ -34,18 +38,28 @@ type backend interface {
 	Done()
 }
 
-type objectData struct {
+// Record is the archived form of a request.  gRPC calls are archived with
+// the full gRPC method as the path and GRPCMethod as the method.
+type Record struct {
 	Path   string                  `json:"path"`
 	Query  string                  `json:"query"`
 	Method string                  `json:"method"`
 	Body   *json.RawMessage        `json:"body"`
 	Claims *jwtgo.RegisteredClaims `json:"claims"`
+	Time   time.Time               `json:"time"`
+	// Metadata is the incoming metadata of a gRPC call, with sensitive
+	// values redacted.
+	Metadata map[string][]string `json:"metadata,omitempty"`
+	// Response is the response of a successful gRPC call.
+	Response *json.RawMessage `json:"response,omitempty"`
+	// Code is the status code of a gRPC call.
+	Code string `json:"code,omitempty"`
 }
 
 // Wrap implements the Middleware interface
 func (a *archiver) Wrap(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if !ignoredPath(a.ignoredPaths, r.URL.Path) {
+		if !ignoredPath(a.ignoredPaths, r.URL.Path) && !a.filter.skip(r) {
 			err := a.put(r)
 			if err != nil {
 				a.log(r).WithError(err).Error("request archiver put failed")
@@ -99,7 +113,7 @@ func requestCookie(request *http.Request, name string) *http.Cookie {
 }
 
 // put writes a JSON document containing a request path, method, query string
-// and body to S3
+// and body to S3, and index entries when enabled
 func (a *archiver) put(r *http.Request) error {
 	reqID := a.reqID(r)
 	if reqID == "" {
@@ -117,32 +131,60 @@ func (a *archiver) put(r *http.Request) error {
 	var reqClaims *jwtgo.RegisteredClaims
 	cookie := requestCookie(r, "authorization")
 	if cookie != nil {
-		parser := &jwtgo.Parser{}
-		token, _, err := parser.ParseUnverified(cookie.Value, &jwtgo.RegisteredClaims{})
-		// Don't log, just omit invalid cookies
-		if err == nil {
-			reqClaims, _ = token.Claims.(*jwtgo.RegisteredClaims)
-		}
+		reqClaims = parseClaims(cookie.Value)
 	}
-	content := objectData{
+	content := Record{
 		Path:   r.URL.Path,
 		Query:  r.URL.RawQuery,
 		Method: r.Method,
 		Body:   nil,
 		Claims: reqClaims,
+		Time:   a.timeNow(),
 	}
 	if bodyIsJSON {
 		body := json.RawMessage(bodyContent)
 		content.Body = &body
 	}
+	return a.write(r.Context(), reqID, &content)
+}
+
+// write writes an archived request to the backend, and index entries when
+// enabled.
+func (a *archiver) write(ctx context.Context, reqID string, content *Record) error {
 	jsonContent, err := json.Marshal(content)
 	if err != nil {
 		return err
 	}
-	a.backend.Write(r.Context(), reqID, jsonContent)
+	a.backend.Write(ctx, reqID, jsonContent)
+	if a.index {
+		if err := validRequestID(reqID); err != nil {
+			return err
+		}
+		return a.writeIndex(ctx, content, reqID)
+	}
 	return nil
 }
 
+// parseClaims returns the unverified claims of a JWT, or nil if the token is
+// invalid.
+func parseClaims(token string) *jwtgo.RegisteredClaims {
+	parser := &jwtgo.Parser{}
+	parsed, _, err := parser.ParseUnverified(token, &jwtgo.RegisteredClaims{})
+	// Don't log, just omit invalid tokens
+	if err != nil {
+		return nil
+	}
+	claims, _ := parsed.Claims.(*jwtgo.RegisteredClaims)
+	return claims
+}
+
+func (a *archiver) timeNow() time.Time {
+	if a.now != nil {
+		return a.now().UTC()
+	}
+	return time.Now().UTC()
+}
+
 func (a *archiver) logReqID(reqID string) *logrus.Entry {
 	return a.logBase.WithField("req_id", reqID)
 }
@@ -8,7 +8,9 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"regexp"
 	"testing"
+	"time"
 
 	"github.com/luthersystems/svc/midware"
 	"github.com/sirupsen/logrus"
@@ -33,7 +35,7 @@ func setTraceHeader(r *http.Request, id string) {
 func TestPut(t *testing.T) {
 	backend := &mockBackend{
 		test: func(_ string, content []byte) {
-			var data objectData
+			var data Record
 			err := json.Unmarshal(content, &data)
 			require.NoError(t, err)
 			require.Equal(t, "/foo", data.Path)
@@ -81,3 +83,67 @@ func TestFilter(t *testing.T) {
 	archiver.Wrap(next).ServeHTTP(rr, req)
 	require.Len(t, hook.Entries, 0)
 }
+
+func TestFilterPatterns(t *testing.T) {
+	cfg := &config{}
+	for _, opt := range []Option{
+		WithIncludedPathPattern("/v1/*"),
+		WithIgnoredPathPattern("/v1/health*"),
+		WithIgnoredPathRegexp(regexp.MustCompile(`^/v1/internal/`)),
+		WithIgnoredMethod("options"),
+		WithSampleRate(1),
+		WithMethodSampleRate(http.MethodGet, 0.01),
+	} {
+		opt(cfg)
+	}
+	f := cfg.filter
+	f.random = func() float64 { return 0.5 }
+	var tests = []struct {
+		method string
+		path   string
+		skip   bool
+	}{
+		{http.MethodPost, "/v1/claims", false},
+		{http.MethodPost, "/v2/claims", true},
+		{http.MethodPost, "/v1/healthcheck", true},
+		{http.MethodPost, "/v1/internal/x", true},
+		{http.MethodOptions, "/v1/claims", true},
+		{http.MethodGet, "/v1/claims", true},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.path, nil)
+		require.Equal(t, tt.skip, f.skip(req), "%s %s", tt.method, tt.path)
+	}
+	f.random = func() float64 { return 0.001 }
+	require.False(t, f.skip(httptest.NewRequest(http.MethodGet, "/v1/claims", nil)))
+}
+
+func TestPutIndex(t *testing.T) {
+	written := make(map[string][]byte)
+	backend := &mockBackend{
+		test: func(key string, content []byte) {
+			written[key] = content
+		},
+	}
+	logger, _ := logtest.NewNullLogger()
+	archiver := &archiver{
+		logBase:     logrus.NewEntry(logger),
+		backend:     backend,
+		traceHeader: midware.DefaultTraceHeader,
+		index:       true,
+		now:         func() time.Time { return time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC) },
+	}
+	req := httptest.NewRequest(http.MethodPost, "/foo", nil)
+	setTraceHeader(req, "request-id")
+	require.NoError(t, archiver.put(req))
+	require.Len(t, written, 2)
+	require.Contains(t, written, "request-id")
+	var entry IndexEntry
+	require.NoError(t, json.Unmarshal(written["index/date/2024-03-01/request-id"], &entry))
+	require.Equal(t, "request-id", entry.RequestID)
+	require.Equal(t, "/foo", entry.Path)
+	require.Equal(t, http.MethodPost, entry.Method)
+
+	setTraceHeader(req, "../escape")
+	require.Error(t, archiver.put(req))
+}
@@ -0,0 +1,159 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package reqarchive
+
+import (
+	"math/rand"
+	"net/http"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// filter selects the requests which are archived, in addition to the exact
+// ignored paths.
+type filter struct {
+	// included restricts archival to matching paths when non-empty.
+	included []pathMatcher
+	ignored  []pathMatcher
+	// ignoredMethods are upper case HTTP methods which are never archived.
+	ignoredMethods map[string]bool
+	// sampleRate is the fraction of requests archived, or nil to archive
+	// every request.
+	sampleRate *float64
+	// methodSampleRates override sampleRate for specific methods.
+	methodSampleRates map[string]float64
+	// random returns a number in [0, 1).  It defaults to rand.Float64.
+	random func() float64
+}
+
+// pathMatcher matches URL paths.
+type pathMatcher func(p string) bool
+
+func globMatcher(pattern string) pathMatcher {
+	return func(p string) bool {
+		ok, err := path.Match(pattern, p)
+		return err == nil && ok
+	}
+}
+
+func regexpMatcher(re *regexp.Regexp) pathMatcher {
+	return re.MatchString
+}
+
+func anyMatch(matchers []pathMatcher, p string) bool {
+	for _, m := range matchers {
+		if m(p) {
+			return true
+		}
+	}
+	return false
+}
+
+// skip returns true if r must not be archived.
+func (f *filter) skip(r *http.Request) bool {
+	return f.skipCall(r.Method, r.URL.Path)
+}
+
+// skipCall returns true if a request using method on path must not be
+// archived.
+func (f *filter) skipCall(method string, p string) bool {
+	if f.ignoredMethods[method] {
+		return true
+	}
+	if len(f.included) > 0 && !anyMatch(f.included, p) {
+		return true
+	}
+	if anyMatch(f.ignored, p) {
+		return true
+	}
+	return !f.sample(method)
+}
+
+// sample returns true if a request using method is selected for archival.
+func (f *filter) sample(method string) bool {
+	rate, ok := f.methodSampleRates[method]
+	if !ok {
+		if f.sampleRate == nil {
+			return true
+		}
+		rate = *f.sampleRate
+	}
+	if rate >= 1 {
+		return true
+	}
+	if rate <= 0 {
+		return false
+	}
+	random := f.random
+	if random == nil {
+		random = rand.Float64 // #nosec G404
+	}
+	return random() < rate
+}
+
+// WithIgnoredPathPattern skips URL paths matching a glob pattern, in the
+// syntax of path.Match (e.g. "/v1/health*").  It can be called more than once.
+func WithIgnoredPathPattern(pattern string) Option {
+	return func(cfg *config) {
+		cfg.filter.ignored = append(cfg.filter.ignored, globMatcher(pattern))
+	}
+}
+
+// WithIgnoredPathRegexp skips URL paths matching re.  It can be called more
+// than once.
+func WithIgnoredPathRegexp(re *regexp.Regexp) Option {
+	return func(cfg *config) {
+		cfg.filter.ignored = append(cfg.filter.ignored, regexpMatcher(re))
+	}
+}
+
+// WithIncludedPathPattern restricts archival to URL paths matching a glob
+// pattern, in the syntax of path.Match.  It can be called more than once, in
+// which case paths matching any pattern are archived.  Ignored paths are
+// skipped even when included.
+func WithIncludedPathPattern(pattern string) Option {
+	return func(cfg *config) {
+		cfg.filter.included = append(cfg.filter.included, globMatcher(pattern))
+	}
+}
+
+// WithIncludedPathRegexp restricts archival to URL paths matching re.  It
+// can be called more than once.
+func WithIncludedPathRegexp(re *regexp.Regexp) Option {
+	return func(cfg *config) {
+		cfg.filter.included = append(cfg.filter.included, regexpMatcher(re))
+	}
+}
+
+// WithIgnoredMethod skips requests using an HTTP method (e.g. GET).  It can
+// be called more than once.
+func WithIgnoredMethod(method string) Option {
+	return func(cfg *config) {
+		if cfg.filter.ignoredMethods == nil {
+			cfg.filter.ignoredMethods = make(map[string]bool, 1)
+		}
+		cfg.filter.ignoredMethods[strings.ToUpper(method)] = true
+	}
+}
+
+// WithSampleRate archives only a random fraction of requests, between 0 and
+// 1.  Defaults to 1, archiving every request.
+func WithSampleRate(rate float64) Option {
+	return func(cfg *config) {
+		cfg.filter.sampleRate = &rate
+	}
+}
+
+// WithMethodSampleRate overrides the sample rate for requests using an HTTP
+// method.  For example, to archive every mutation but only 1% of reads:
+//
+//	WithMethodSampleRate(http.MethodGet, 0.01)
+func WithMethodSampleRate(method string, rate float64) Option {
+	return func(cfg *config) {
+		if cfg.filter.methodSampleRates == nil {
+			cfg.filter.methodSampleRates = make(map[string]float64, 1)
+		}
+		cfg.filter.methodSampleRates[strings.ToUpper(method)] = rate
+	}
+}
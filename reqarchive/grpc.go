@@ -0,0 +1,232 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package reqarchive
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	jwtgo "github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+const (
+	// GRPCMethod is the method of archived gRPC calls.  It can be passed to
+	// WithIgnoredMethod and WithMethodSampleRate.
+	GRPCMethod = "GRPC"
+
+	// redactedValue replaces redacted metadata and proto fields.
+	redactedValue = "REDACTED"
+)
+
+// defaultRedactedMetadata are metadata keys whose values are never archived.
+var defaultRedactedMetadata = []string{
+	"authorization",
+	"cookie",
+	"grpcgateway-authorization",
+	"grpcgateway-cookie",
+}
+
+// redaction configures the redaction of sensitive values from archived gRPC
+// calls.
+type redaction struct {
+	// metadata are lower case metadata keys whose values are redacted.
+	metadata map[string]bool
+	// fields are the full or short names of redacted proto fields.
+	fields map[string]bool
+}
+
+// WithRedactedMetadata redacts the values of a gRPC metadata key from
+// archived calls.  Authorization and cookie metadata are always redacted.
+// It can be called more than once.
+func WithRedactedMetadata(key string) Option {
+	return func(cfg *config) {
+		if cfg.redact.metadata == nil {
+			cfg.redact.metadata = make(map[string]bool, 1)
+		}
+		cfg.redact.metadata[strings.ToLower(key)] = true
+	}
+}
+
+// WithRedactedField redacts a proto field from archived gRPC requests and
+// responses, identified by its full name (e.g. "pkg.v1.User.ssn") or its
+// name (e.g. "ssn").  Fields marked with the debug_redact option are always
+// redacted.  It can be called more than once.
+func WithRedactedField(name string) Option {
+	return func(cfg *config) {
+		if cfg.redact.fields == nil {
+			cfg.redact.fields = make(map[string]bool, 1)
+		}
+		cfg.redact.fields[name] = true
+	}
+}
+
+// UnaryServerInterceptor returns a gRPC interceptor archiving unary calls,
+// including their metadata and proto request and response, to the backend
+// of the archiver.  The path filters of the archiver apply to the full gRPC
+// method.
+func (a *archiver) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if ignoredPath(a.ignoredPaths, info.FullMethod) || a.filter.skipCall(GRPCMethod, info.FullMethod) {
+			return handler(ctx, req)
+		}
+		resp, err := handler(ctx, req)
+		reqID := a.grpcReqID(ctx)
+		record := a.grpcRecord(ctx, info.FullMethod, req, resp, err)
+		// The archive outlives the call.
+		if err := a.write(context.WithoutCancel(ctx), reqID, record); err != nil {
+			a.logReqID(reqID).WithError(err).Error("request archiver put failed")
+		}
+		return resp, err
+	}
+}
+
+// grpcReqID returns the request ID of a gRPC call.
+func (a *archiver) grpcReqID(ctx context.Context) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if ids := md.Get(a.traceHeader); len(ids) > 0 && ids[0] != "" {
+		return ids[0]
+	}
+	return uuid.New().String()
+}
+
+// grpcRecord returns the archived form of a gRPC call.
+func (a *archiver) grpcRecord(ctx context.Context, fullMethod string, req interface{}, resp interface{}, callErr error) *Record {
+	md, _ := metadata.FromIncomingContext(ctx)
+	record := &Record{
+		Path:     fullMethod,
+		Method:   GRPCMethod,
+		Claims:   grpcClaims(md),
+		Time:     a.timeNow(),
+		Metadata: a.redact.redactMetadata(md),
+		Code:     status.Code(callErr).String(),
+	}
+	record.Body = a.marshalMessage(req)
+	if callErr == nil {
+		record.Response = a.marshalMessage(resp)
+	}
+	return record
+}
+
+// marshalMessage returns the redacted JSON form of a proto message, or nil
+// if v is not a proto message.
+func (a *archiver) marshalMessage(v interface{}) *json.RawMessage {
+	msg, ok := v.(proto.Message)
+	if !ok || msg == nil {
+		return nil
+	}
+	msg = proto.Clone(msg)
+	a.redact.redactMessage(msg.ProtoReflect())
+	b, err := protojson.Marshal(msg)
+	if err != nil {
+		a.logBase.WithError(err).Debug("request archiver unable to marshal message")
+		return nil
+	}
+	raw := json.RawMessage(b)
+	return &raw
+}
+
+// grpcClaims returns the claims of the JWT in the authorization metadata or
+// cookie of a call.
+func grpcClaims(md metadata.MD) *jwtgo.RegisteredClaims {
+	for _, key := range []string{"authorization", "grpcgateway-authorization"} {
+		for _, v := range md.Get(key) {
+			if token, ok := strings.CutPrefix(v, "Bearer "); ok {
+				return parseClaims(token)
+			}
+		}
+	}
+	for _, key := range []string{"cookie", "grpcgateway-cookie"} {
+		for _, v := range md.Get(key) {
+			r := &http.Request{Header: http.Header{"Cookie": {v}}}
+			if cookie := requestCookie(r, "authorization"); cookie != nil {
+				return parseClaims(cookie.Value)
+			}
+		}
+	}
+	return nil
+}
+
+// redactMetadata returns a copy of md with sensitive values redacted.
+func (r redaction) redactMetadata(md metadata.MD) map[string][]string {
+	if len(md) == 0 {
+		return nil
+	}
+	out := make(map[string][]string, len(md))
+	for k, vs := range md {
+		if r.metadata[k] || isDefaultRedactedMetadata(k) {
+			out[k] = []string{redactedValue}
+			continue
+		}
+		out[k] = append([]string(nil), vs...)
+	}
+	return out
+}
+
+func isDefaultRedactedMetadata(key string) bool {
+	for _, k := range defaultRedactedMetadata {
+		if key == k {
+			return true
+		}
+	}
+	return false
+}
+
+// redactMessage redacts sensitive fields of msg in place.  Redacted string
+// and bytes fields are replaced with a marker, other fields are cleared.
+func (r redaction) redactMessage(msg protoreflect.Message) {
+	msg.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		if r.sensitive(fd) {
+			redactField(msg, fd)
+			return true
+		}
+		switch {
+		case fd.IsList() && fd.Message() != nil:
+			list := v.List()
+			for i := 0; i < list.Len(); i++ {
+				r.redactMessage(list.Get(i).Message())
+			}
+		case fd.IsMap() && fd.MapValue().Message() != nil:
+			v.Map().Range(func(_ protoreflect.MapKey, mv protoreflect.Value) bool {
+				r.redactMessage(mv.Message())
+				return true
+			})
+		case !fd.IsList() && !fd.IsMap() && fd.Message() != nil:
+			r.redactMessage(v.Message())
+		}
+		return true
+	})
+}
+
+// sensitive returns true if the field must be redacted.
+func (r redaction) sensitive(fd protoreflect.FieldDescriptor) bool {
+	if r.fields[string(fd.FullName())] || r.fields[string(fd.Name())] {
+		return true
+	}
+	opts, ok := fd.Options().(*descriptorpb.FieldOptions)
+	return ok && opts.GetDebugRedact()
+}
+
+func redactField(msg protoreflect.Message, fd protoreflect.FieldDescriptor) {
+	if fd.IsList() || fd.IsMap() {
+		msg.Clear(fd)
+		return
+	}
+	switch fd.Kind() {
+	case protoreflect.StringKind:
+		msg.Set(fd, protoreflect.ValueOfString(redactedValue))
+	case protoreflect.BytesKind:
+		msg.Set(fd, protoreflect.ValueOfBytes([]byte(redactedValue)))
+	default:
+		msg.Clear(fd)
+	}
+}
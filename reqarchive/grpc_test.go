@@ -0,0 +1,102 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package reqarchive
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	jwtgo "github.com/golang-jwt/jwt/v4"
+	"github.com/luthersystems/svc/midware"
+	"github.com/sirupsen/logrus"
+	logtest "github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+func newGRPCArchiver(written map[string][]byte, opts ...Option) *archiver {
+	cfg := &config{}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	logger, _ := logtest.NewNullLogger()
+	return &archiver{
+		logBase:     logrus.NewEntry(logger),
+		traceHeader: midware.DefaultTraceHeader,
+		filter:      cfg.filter,
+		redact:      cfg.redact,
+		backend: &mockBackend{
+			test: func(key string, content []byte) {
+				written[key] = content
+			},
+		},
+	}
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	written := make(map[string][]byte)
+	a := newGRPCArchiver(written,
+		WithRedactedField("google.protobuf.DescriptorProto.name"),
+		WithRedactedMetadata("x-api-key"))
+	token, err := jwtgo.NewWithClaims(jwtgo.SigningMethodHS256, jwtgo.RegisteredClaims{Subject: "alice"}).SignedString([]byte("key"))
+	require.NoError(t, err)
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(
+		"x-request-id", "request-id",
+		"authorization", "Bearer "+token,
+		"x-api-key", "secret",
+		"user-agent", "test"))
+	req := &descriptorpb.FileDescriptorProto{
+		Name:        proto.String("a.proto"),
+		MessageType: []*descriptorpb.DescriptorProto{{Name: proto.String("Secret")}},
+	}
+	info := &grpc.UnaryServerInfo{FullMethod: "/pkg.v1.Service/Method"}
+	resp, err := a.UnaryServerInterceptor()(ctx, req, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return &descriptorpb.FileDescriptorProto{Package: proto.String("pkg")}, nil
+	})
+	require.NoError(t, err)
+	require.NotNil(t, resp)
+	// The request passed to the handler is not redacted.
+	require.Equal(t, "Secret", req.GetMessageType()[0].GetName())
+
+	require.Contains(t, written, "request-id")
+	var record Record
+	require.NoError(t, json.Unmarshal(written["request-id"], &record))
+	require.Equal(t, "/pkg.v1.Service/Method", record.Path)
+	require.Equal(t, GRPCMethod, record.Method)
+	require.Equal(t, codes.OK.String(), record.Code)
+	require.Equal(t, "alice", record.Claims.Subject)
+	require.Equal(t, []string{redactedValue}, record.Metadata["authorization"])
+	require.Equal(t, []string{redactedValue}, record.Metadata["x-api-key"])
+	require.Equal(t, []string{"test"}, record.Metadata["user-agent"])
+	require.JSONEq(t, `{"name":"a.proto","messageType":[{"name":"REDACTED"}]}`, string(*record.Body))
+	require.JSONEq(t, `{"package":"pkg"}`, string(*record.Response))
+}
+
+func TestUnaryServerInterceptorError(t *testing.T) {
+	written := make(map[string][]byte)
+	a := newGRPCArchiver(written, WithIgnoredPathPattern("/grpc.health.v1.Health/*"))
+	interceptor := a.UnaryServerInterceptor()
+	failed := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, status.Error(codes.NotFound, "missing")
+	}
+
+	_, err := interceptor(context.Background(), &descriptorpb.FileDescriptorProto{}, &grpc.UnaryServerInfo{FullMethod: "/grpc.health.v1.Health/Check"}, failed)
+	require.Equal(t, codes.NotFound, status.Code(err))
+	require.Empty(t, written)
+
+	_, err = interceptor(context.Background(), &descriptorpb.FileDescriptorProto{}, &grpc.UnaryServerInfo{FullMethod: "/pkg.v1.Service/Method"}, failed)
+	require.Equal(t, codes.NotFound, status.Code(err))
+	require.Len(t, written, 1)
+	for _, content := range written {
+		var record Record
+		require.NoError(t, json.Unmarshal(content, &record))
+		require.Equal(t, codes.NotFound.String(), record.Code)
+		require.Nil(t, record.Response)
+	}
+}
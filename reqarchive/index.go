@@ -0,0 +1,92 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package reqarchive
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+)
+
+const (
+	// indexDir contains index entries, alongside archived requests.
+	indexDir = "index"
+	// indexDateLayout formats the day of a date index entry.
+	indexDateLayout = "2006-01-02"
+)
+
+// ErrNotFound is returned by a Reader when an archived request does not exist.
+var ErrNotFound = errors.New("archived request not found")
+
+// IndexEntry summarizes an archived request in the archive index.
+type IndexEntry struct {
+	RequestID string    `json:"request_id"`
+	Time      time.Time `json:"time"`
+	Method    string    `json:"method"`
+	Path      string    `json:"path"`
+	Subject   string    `json:"subject,omitempty"`
+}
+
+// Reader locates and retrieves archived requests.  Requests are only listed
+// if they were archived with WithIndex.
+type Reader interface {
+	// ListByDate lists the requests archived on the UTC day of date.
+	ListByDate(ctx context.Context, date time.Time) ([]*IndexEntry, error)
+	// ListBySubject lists the requests archived for a JWT subject.
+	ListBySubject(ctx context.Context, subject string) ([]*IndexEntry, error)
+	// GetByRequestID returns the archived request with the given ID.
+	GetByRequestID(ctx context.Context, reqID string) (*Record, error)
+}
+
+func dateIndexDir(date time.Time) string {
+	return path.Join(indexDir, "date", date.UTC().Format(indexDateLayout))
+}
+
+func subjectIndexDir(subject string) string {
+	return path.Join(indexDir, "subject", url.PathEscape(subject))
+}
+
+// indexKeys returns the keys, relative to the archive prefix, of the index
+// entries for a request.
+func indexKeys(entry *IndexEntry) []string {
+	id := url.PathEscape(entry.RequestID)
+	keys := []string{path.Join(dateIndexDir(entry.Time), id)}
+	if entry.Subject != "" {
+		keys = append(keys, path.Join(subjectIndexDir(entry.Subject), id))
+	}
+	return keys
+}
+
+// writeIndex writes the index entries for an archived request.
+func (a *archiver) writeIndex(ctx context.Context, record *Record, reqID string) error {
+	entry := &IndexEntry{
+		RequestID: reqID,
+		Time:      record.Time,
+		Method:    record.Method,
+		Path:      record.Path,
+	}
+	if record.Claims != nil {
+		entry.Subject = record.Claims.Subject
+	}
+	content, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("index entry: %w", err)
+	}
+	for _, key := range indexKeys(entry) {
+		a.backend.Write(ctx, key, content)
+	}
+	return nil
+}
+
+// validRequestID returns an error if reqID cannot be used as an object key.
+func validRequestID(reqID string) error {
+	if reqID == "" || strings.Contains(reqID, "/") || reqID == "." || reqID == ".." {
+		return fmt.Errorf("invalid request id: %q", reqID)
+	}
+	return nil
+}
@@ -16,6 +16,9 @@ type config struct {
 	ignoredPaths map[string]bool
 	timeout      time.Duration
 	traceHeader  string
+	filter       filter
+	index        bool
+	redact       redaction
 }
 
 // WithLogBase sets a base logrus Entry for logging of errors.
@@ -49,3 +52,11 @@ func WithTraceHeader(header string) Option {
 		cfg.traceHeader = header
 	}
 }
+
+// WithIndex writes an index of archived requests by day and JWT subject
+// alongside the archived requests, so they can be located with a Reader.
+func WithIndex() Option {
+	return func(cfg *config) {
+		cfg.index = true
+	}
+}
@@ -5,16 +5,20 @@ package reqarchive
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"sync"
 	"time"
 
 	awscfg "github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/luthersystems/svc/midware"
 	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
 )
 
 type s3Backend struct {
@@ -58,6 +62,26 @@ func NewS3Archiver(region, bucket, prefix string, opts ...Option) (midware.Middl
 	if prefix == "" {
 		return nil, errors.New("NewS3Archiver: requires non-empty prefix")
 	}
+	return newS3Archiver(region, bucket, prefix, opts...)
+}
+
+// NewS3GRPCArchiver returns a gRPC unary server interceptor that archives
+// calls to an AWS S3 bucket, like NewS3Archiver.  The request ID is read
+// from the metadata key of the trace header, and generated if missing.  When
+// gateway traffic is archived by both interceptor and middleware, they must
+// use different prefixes.
+func NewS3GRPCArchiver(region, bucket, prefix string, opts ...Option) (grpc.UnaryServerInterceptor, error) {
+	if prefix == "" {
+		return nil, errors.New("NewS3GRPCArchiver: requires non-empty prefix")
+	}
+	a, err := newS3Archiver(region, bucket, prefix, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return a.UnaryServerInterceptor(), nil
+}
+
+func newS3Archiver(region, bucket, prefix string, opts ...Option) (*archiver, error) {
 	cfg := &config{
 		timeout:     defaultTimeout,
 		traceHeader: midware.DefaultTraceHeader,
@@ -69,7 +93,10 @@ func NewS3Archiver(region, bucket, prefix string, opts ...Option) (midware.Middl
 	a := &archiver{
 		logBase:      cfg.logBase,
 		ignoredPaths: cfg.ignoredPaths,
+		filter:       cfg.filter,
+		index:        cfg.index,
 		traceHeader:  cfg.traceHeader,
+		redact:       cfg.redact,
 	}
 	awsCfg, err := awscfg.LoadDefaultConfig(
 		context.TODO(),
@@ -89,3 +116,104 @@ func NewS3Archiver(region, bucket, prefix string, opts ...Option) (midware.Middl
 	a.backend = backend
 	return a, nil
 }
+
+// S3Reader reads requests archived by an S3 archiver.
+type S3Reader struct {
+	client *s3.Client
+	bucket string
+	prefix string
+}
+
+var _ Reader = &S3Reader{}
+
+// NewS3Reader returns a Reader for requests archived with NewS3Archiver using
+// the same bucket and prefix.
+func NewS3Reader(region, bucket, prefix string) (*S3Reader, error) {
+	if prefix == "" {
+		return nil, errors.New("NewS3Reader: requires non-empty prefix")
+	}
+	awsCfg, err := awscfg.LoadDefaultConfig(
+		context.TODO(),
+		awscfg.WithRegion(region),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &S3Reader{
+		client: s3.NewFromConfig(awsCfg),
+		bucket: bucket,
+		prefix: prefix,
+	}, nil
+}
+
+// ListByDate implements Reader.
+func (r *S3Reader) ListByDate(ctx context.Context, date time.Time) ([]*IndexEntry, error) {
+	return r.list(ctx, dateIndexDir(date))
+}
+
+// ListBySubject implements Reader.
+func (r *S3Reader) ListBySubject(ctx context.Context, subject string) ([]*IndexEntry, error) {
+	return r.list(ctx, subjectIndexDir(subject))
+}
+
+// GetByRequestID implements Reader.
+func (r *S3Reader) GetByRequestID(ctx context.Context, reqID string) (*Record, error) {
+	if err := validRequestID(reqID); err != nil {
+		return nil, err
+	}
+	b, err := r.get(ctx, fmt.Sprintf("%s/%s", r.prefix, reqID))
+	if err != nil {
+		return nil, err
+	}
+	record := &Record{}
+	if err := json.Unmarshal(b, record); err != nil {
+		return nil, fmt.Errorf("archived request: %w", err)
+	}
+	return record, nil
+}
+
+func (r *S3Reader) get(ctx context.Context, key string) ([]byte, error) {
+	out, err := r.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(r.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		var noSuchKey *types.NoSuchKey
+		if errors.As(err, &noSuchKey) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("s3 get: %w", err)
+	}
+	defer out.Body.Close()
+	b, err := io.ReadAll(out.Body)
+	if err != nil {
+		return nil, fmt.Errorf("s3 get: %w", err)
+	}
+	return b, nil
+}
+
+func (r *S3Reader) list(ctx context.Context, dir string) ([]*IndexEntry, error) {
+	var entries []*IndexEntry
+	paginator := s3.NewListObjectsV2Paginator(r.client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(r.bucket),
+		Prefix: aws.String(fmt.Sprintf("%s/%s/", r.prefix, dir)),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("s3 list: %w", err)
+		}
+		for _, obj := range page.Contents {
+			b, err := r.get(ctx, aws.StringValue(obj.Key))
+			if err != nil {
+				return nil, err
+			}
+			entry := &IndexEntry{}
+			if err := json.Unmarshal(b, entry); err != nil {
+				return nil, fmt.Errorf("index entry: %w", err)
+			}
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
@@ -0,0 +1,124 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package svcerr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	common "buf.build/gen/go/luthersystems/protos/protocolbuffers/go/common/v1"
+	"github.com/luthersystems/svc/grpclogging"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc/status"
+)
+
+// ExceptionChainField is the log field holding the rendered cause chain of
+// a failed request.
+const ExceptionChainField = "exception_chain"
+
+// causeError is an exception caused by other exceptions, e.g. those
+// returned by a downstream service.  Only the top-level exception is
+// presented to clients.
+type causeError struct {
+	stat   *status.Status
+	except *common.Exception
+	causes []*common.Exception
+}
+
+// Error implements error.
+func (e *causeError) Error() string {
+	return e.except.GetDescription()
+}
+
+// GRPCStatus returns the status presented to clients.
+func (e *causeError) GRPCStatus() *status.Status {
+	return e.stat
+}
+
+// DownstreamException returns the exception carried by an error returned
+// from another service, i.e. the details of a gRPC status error or the
+// exception of a raw Luther error.  It returns nil if err carries no
+// exception.
+func DownstreamException(err error) *common.Exception {
+	var ce *causeError
+	if errors.As(err, &ce) {
+		return ce.except
+	}
+	var r raiser
+	if errors.As(err, &r) {
+		return r.GetException()
+	}
+	stat, ok := status.FromError(err)
+	if !ok {
+		return nil
+	}
+	for _, detail := range stat.Details() {
+		if except, ok := detail.(*common.Exception); ok {
+			return except
+		}
+	}
+	return nil
+}
+
+// WrapException returns an error presenting except to clients, recording
+// cause as the exception's cause.  The cause is typically an error returned
+// by a downstream service, and its exception (original ID and type
+// included) is kept in the chain rendered in the request log by
+// AppErrorUnaryInterceptor.  Causes without an exception are recorded by
+// their error message.
+func WrapException(except *common.Exception, cause error) error {
+	var causes []*common.Exception
+	var ce *causeError
+	if errors.As(cause, &ce) {
+		causes = append([]*common.Exception{ce.except}, ce.causes...)
+	} else if downstream := DownstreamException(cause); downstream != nil {
+		causes = []*common.Exception{downstream}
+	} else if cause != nil {
+		causes = []*common.Exception{{Description: cause.Error()}}
+	}
+	code, _ := exceptionCode(except.GetType())
+	stat := status.New(code, except.GetDescription())
+	if withDetails, err := stat.WithDetails(except); err == nil {
+		stat = withDetails
+	}
+	return &causeError{
+		stat:   stat,
+		except: except,
+		causes: causes,
+	}
+}
+
+// ExceptionChain returns the exception presented by err followed by its
+// causes, outermost first.  It returns nil if err was not created by
+// WrapException.
+func ExceptionChain(err error) []*common.Exception {
+	var ce *causeError
+	if !errors.As(err, &ce) {
+		return nil
+	}
+	return append([]*common.Exception{ce.except}, ce.causes...)
+}
+
+// FormatExceptionChain renders an exception chain on a single line, e.g.
+//
+//	BUSINESS(id=abc): payment failed <- SERVICE_NOT_AVAILABLE(id=def): kyc unavailable
+func FormatExceptionChain(chain []*common.Exception) string {
+	parts := make([]string, 0, len(chain))
+	for _, except := range chain {
+		parts = append(parts, fmt.Sprintf("%s(id=%s): %s", except.GetType(), except.GetId(), except.GetDescription()))
+	}
+	return strings.Join(parts, " <- ")
+}
+
+// logExceptionChain adds the cause chain of err to the request log fields.
+func logExceptionChain(ctx context.Context, err error) {
+	chain := ExceptionChain(err)
+	if len(chain) < 2 {
+		return
+	}
+	grpclogging.AddLogrusFields(ctx, logrus.Fields{
+		ExceptionChainField: FormatExceptionChain(chain),
+	})
+}
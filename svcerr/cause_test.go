@@ -0,0 +1,77 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package svcerr
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	common "buf.build/gen/go/luthersystems/protos/protocolbuffers/go/common/v1"
+	"github.com/luthersystems/svc/grpclogging"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestWrapException(t *testing.T) {
+	ctx := context.Background()
+	downstream := ServiceException(ctx, "kyc unavailable")
+	downstream.Id = "downstream-id"
+	stat, err := status.New(codes.Unavailable, "kyc unavailable").WithDetails(downstream)
+	require.NoError(t, err)
+	require.True(t, proto.Equal(downstream, DownstreamException(stat.Err())))
+	require.Nil(t, DownstreamException(fmt.Errorf("plain")))
+
+	top := BusinessException(ctx, "payment failed")
+	top.Id = "top-id"
+	wrapped := WrapException(top, stat.Err())
+
+	// only the top-level exception is presented to clients.
+	wrappedStat, ok := status.FromError(wrapped)
+	require.True(t, ok)
+	require.Equal(t, codes.InvalidArgument, wrappedStat.Code())
+	require.Len(t, wrappedStat.Details(), 1)
+	require.True(t, proto.Equal(top, wrappedStat.Details()[0].(*common.Exception)))
+
+	chain := ExceptionChain(wrapped)
+	require.Len(t, chain, 2)
+	require.Equal(t, "top-id", chain[0].GetId())
+	require.Equal(t, "downstream-id", chain[1].GetId())
+	require.Equal(t, common.Exception_SERVICE_NOT_AVAILABLE, chain[1].GetType())
+
+	outer := UnexpectedException(ctx, "checkout failed")
+	outer.Id = "outer-id"
+	chain = ExceptionChain(WrapException(outer, fmt.Errorf("payment: %w", wrapped)))
+	require.Len(t, chain, 3)
+	require.Equal(t,
+		"UNEXPECTED(id=outer-id): checkout failed <- BUSINESS(id=top-id): payment failed <- SERVICE_NOT_AVAILABLE(id=downstream-id): kyc unavailable",
+		FormatExceptionChain(chain))
+
+	chain = ExceptionChain(WrapException(outer, fmt.Errorf("connection refused")))
+	require.Len(t, chain, 2)
+	require.Equal(t, "connection refused", chain[1].GetDescription())
+
+	require.Nil(t, ExceptionChain(stat.Err()))
+}
+
+func TestExceptionChainLogged(t *testing.T) {
+	entry := logrus.NewEntry(logrus.New())
+	log := func(ctx context.Context) *logrus.Entry {
+		return entry
+	}
+	ctx := grpclogging.NewContext(context.Background())
+	cause := status.Error(codes.Unavailable, "kyc unavailable")
+	intercept := AppErrorUnaryInterceptor(log)
+	_, err := intercept(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/test.v1.Service/Method"}, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return &common.ExceptionResponse{}, WrapException(BusinessException(ctx, "payment failed"), cause)
+	})
+	stat, ok := status.FromError(err)
+	require.True(t, ok)
+	require.Equal(t, codes.InvalidArgument, stat.Code())
+	require.Equal(t, "payment failed", stat.Message())
+	require.Contains(t, grpclogging.GetLogrusFields(ctx)[ExceptionChainField], "kyc unavailable")
+}
@@ -0,0 +1,79 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package svcerr
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	common "buf.build/gen/go/luthersystems/protos/protocolbuffers/go/common/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// maxResponseBody is the largest error response body read by
+// FromHTTPResponse.
+const maxResponseBody = 1 << 20
+
+// Sentinel errors matching raw Luther errors by exception type with
+// errors.Is, e.g. errors.Is(err, svcerr.ErrBusiness).  Use errors.As with
+// the raw error types, e.g. *BusinessError, to access the exception.
+var (
+	ErrBusiness       = errors.New("business exception")
+	ErrSecurity       = errors.New("security exception")
+	ErrInfrastructure = errors.New("infrastructure exception")
+	ErrService        = errors.New("service exception")
+	ErrUnexpected     = errors.New("unexpected exception")
+)
+
+// Is reports whether target is the sentinel error of the exception type.
+func (s *lutherError) Is(target error) bool {
+	switch s.GetType() {
+	case common.Exception_BUSINESS:
+		return target == ErrBusiness
+	case common.Exception_SECURITY_VIOLATION:
+		return target == ErrSecurity
+	case common.Exception_INFRASTRUCTURE:
+		return target == ErrInfrastructure
+	case common.Exception_SERVICE_NOT_AVAILABLE:
+		return target == ErrService
+	default:
+		return target == ErrUnexpected
+	}
+}
+
+// ParseExceptionResponse returns the exception of an ExceptionResponse JSON
+// body, as returned by the REST/JSON API of a failed request.  Business
+// exceptions returned with a response payload are parsed as well, as the
+// payload carries the exception in its exception field.  An error is
+// returned if body is not JSON or carries no exception.
+func ParseExceptionResponse(body []byte) (*common.Exception, error) {
+	resp := &common.ExceptionResponse{}
+	if err := (protojson.UnmarshalOptions{DiscardUnknown: true}).Unmarshal(body, resp); err != nil {
+		return nil, fmt.Errorf("parse exception response: %w", err)
+	}
+	if resp.GetException() == nil {
+		return nil, fmt.Errorf("parse exception response: missing exception")
+	}
+	return resp.GetException(), nil
+}
+
+// FromHTTPResponse returns nil if resp has a 2xx status.  Otherwise it
+// reads the response body and returns the raw Luther error of its
+// exception, e.g. *BusinessError, or an error describing the status if the
+// body carries no exception.  The caller must still close the body.
+func FromHTTPResponse(resp *http.Response) error {
+	if resp.StatusCode >= 200 && resp.StatusCode <= 299 {
+		return nil
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseBody))
+	if err != nil {
+		return fmt.Errorf("response status %d: read body: %w", resp.StatusCode, err)
+	}
+	except, err := ParseExceptionResponse(body)
+	if err != nil {
+		return fmt.Errorf("response status %d: %w", resp.StatusCode, err)
+	}
+	return ExceptionError(except)
+}
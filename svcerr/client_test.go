@@ -0,0 +1,60 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package svcerr
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	common "buf.build/gen/go/luthersystems/protos/protocolbuffers/go/common/v1"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseExceptionResponse(t *testing.T) {
+	except, err := ParseExceptionResponse([]byte(`{"exception": {"id": "abc", "type": "BUSINESS", "description": "insufficient funds"}}`))
+	require.NoError(t, err)
+	require.Equal(t, "abc", except.GetId())
+	require.Equal(t, common.Exception_BUSINESS, except.GetType())
+
+	// business exception payloads carry other fields.
+	except, err = ParseExceptionResponse([]byte(`{"account": {"id": "1"}, "exception": {"type": "BUSINESS"}}`))
+	require.NoError(t, err)
+	require.Equal(t, common.Exception_BUSINESS, except.GetType())
+
+	_, err = ParseExceptionResponse([]byte(`{}`))
+	require.Error(t, err)
+	_, err = ParseExceptionResponse([]byte(`upstream connect error`))
+	require.Error(t, err)
+}
+
+func TestFromHTTPResponse(t *testing.T) {
+	response := func(code int, body string) *http.Response {
+		return &http.Response{StatusCode: code, Body: io.NopCloser(strings.NewReader(body))}
+	}
+	require.NoError(t, FromHTTPResponse(response(http.StatusOK, `{}`)))
+
+	err := FromHTTPResponse(response(http.StatusForbidden, `{"exception": {"id": "abc", "type": "SECURITY_VIOLATION", "description": "denied"}}`))
+	var es *SecurityError
+	require.ErrorAs(t, err, &es)
+	require.Equal(t, "abc", es.GetId())
+	require.True(t, errors.Is(fmt.Errorf("call: %w", err), ErrSecurity))
+	require.False(t, errors.Is(err, ErrBusiness))
+
+	err = FromHTTPResponse(response(http.StatusBadGateway, `bad gateway`))
+	require.ErrorContains(t, err, "response status 502")
+	require.False(t, errors.Is(err, ErrUnexpected))
+}
+
+func TestErrorIs(t *testing.T) {
+	require.ErrorIs(t, NewBusinessError("business"), ErrBusiness)
+	require.ErrorIs(t, NewSecurityError("security"), ErrSecurity)
+	require.ErrorIs(t, NewInfrastructureError("infrastructure"), ErrInfrastructure)
+	require.ErrorIs(t, NewServiceError("service"), ErrService)
+	require.ErrorIs(t, NewUnexpectedError("unexpected"), ErrUnexpected)
+	require.ErrorIs(t, ExceptionError(&common.Exception{}), ErrUnexpected)
+	require.NotErrorIs(t, NewBusinessError("business"), ErrService)
+}
@@ -0,0 +1,30 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package svcerr
+
+import (
+	"context"
+	"time"
+)
+
+type clockCtxKey struct{}
+
+// WithClock returns a context whose exceptions are timestamped with now
+// instead of the current time, e.g. a fixed clock so that test responses
+// are reproducible.
+func WithClock(ctx context.Context, now func() time.Time) context.Context {
+	return context.WithValue(ctx, clockCtxKey{}, now)
+}
+
+// clockNow returns the current time of the clock of ctx.
+func clockNow(ctx context.Context) time.Time {
+	if now, ok := ctx.Value(clockCtxKey{}).(func() time.Time); ok && now != nil {
+		return now()
+	}
+	return time.Now()
+}
+
+// timestamp returns the formatted time of an exception created with ctx.
+func timestamp(ctx context.Context) string {
+	return clockNow(ctx).Format(TimestampFormat)
+}
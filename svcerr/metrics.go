@@ -0,0 +1,151 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package svcerr
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	common "buf.build/gen/go/luthersystems/protos/protocolbuffers/go/common/v1"
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc/status"
+)
+
+// ErrMetricsRegistered is returned by RegisterMetrics when metrics have
+// already been registered with the registerer.
+var ErrMetricsRegistered = errors.New("svcerr metrics already registered")
+
+var (
+	exceptionTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "exception_total",
+			Help: "How many exception responses, partitioned by exception type, method and HTTP status code.",
+		},
+		[]string{"type", "method", "code"},
+	)
+	warningTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "warning_total",
+			Help: "How many warnings in successful responses, partitioned by exception type and method.",
+		},
+		[]string{"type", "method"},
+	)
+	errorDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "error_request_duration_seconds",
+			Help:    "Duration of requests that produced an error, partitioned by method and gRPC code.",
+			Buckets: prometheus.DefBuckets,
+		},
+		[]string{"method", "code"},
+	)
+	truncationTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "exception_truncated_total",
+			Help: "How many errors were truncated to fit size limits, partitioned by method and truncated part.",
+		},
+		[]string{"method", "part"},
+	)
+	reportTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "error_reports_total",
+			Help: "How many errors were forwarded to the error reporter, partitioned by result.",
+		},
+		[]string{"result"},
+	)
+
+	// metricsMut guards lazy registration with the default registerer.
+	metricsMut sync.Mutex
+	// metricsRegistered is set once metrics are registered with any
+	// registerer, or registration is disabled.
+	metricsRegistered atomic.Bool
+)
+
+// Collectors returns the prometheus collectors populated by the package, for
+// callers which register them themselves.
+func Collectors() []prometheus.Collector {
+	return []prometheus.Collector{exceptionTotal, warningTotal, errorDuration, truncationTotal, reportTotal}
+}
+
+// RegisterMetrics registers the package's metrics with reg.  Metrics may be
+// registered with several registerers; ErrMetricsRegistered is returned if
+// they are already registered with reg.  If RegisterMetrics is not called
+// before errors are handled, metrics are lazily registered with the default
+// prometheus registerer.  A nil reg disables lazy registration.
+func RegisterMetrics(reg prometheus.Registerer) error {
+	metricsMut.Lock()
+	defer metricsMut.Unlock()
+	err := registerMetrics(reg)
+	if err == nil || errors.Is(err, ErrMetricsRegistered) {
+		metricsRegistered.Store(true)
+	}
+	return err
+}
+
+func registerMetrics(reg prometheus.Registerer) error {
+	if reg == nil {
+		return nil
+	}
+	var registered bool
+	for _, c := range Collectors() {
+		if err := reg.Register(c); err != nil {
+			var are prometheus.AlreadyRegisteredError
+			if !errors.As(err, &are) {
+				return err
+			}
+			registered = true
+		}
+	}
+	if registered {
+		return ErrMetricsRegistered
+	}
+	return nil
+}
+
+// ensureMetrics lazily registers metrics with the default registerer, unless
+// RegisterMetrics has been called.
+func ensureMetrics() {
+	if metricsRegistered.Load() {
+		return
+	}
+	metricsMut.Lock()
+	defer metricsMut.Unlock()
+	if !metricsRegistered.Load() {
+		_ = registerMetrics(prometheus.DefaultRegisterer)
+		metricsRegistered.Store(true)
+	}
+}
+
+// incExceptionMetric records prometheus metrics about a returned exception.
+func incExceptionMetric(ctx context.Context, e *common.Exception, httpCode int) {
+	ensureMetrics()
+	exceptionTotal.WithLabelValues(e.GetType().String(), rpcMethod(ctx), strconv.Itoa(httpCode)).Inc()
+}
+
+// incWarningMetric records prometheus metrics about a returned warning.
+func incWarningMetric(method string, e *common.Exception) {
+	ensureMetrics()
+	warningTotal.WithLabelValues(e.GetType().String(), method).Inc()
+}
+
+// observeErrorDuration records the duration of a request that returned an
+// error.
+func observeErrorDuration(method string, err error, dur time.Duration) {
+	ensureMetrics()
+	errorDuration.WithLabelValues(method, status.Code(err).String()).Observe(dur.Seconds())
+}
+
+// incTruncationMetric records prometheus metrics about a truncated error.
+func incTruncationMetric(method string, part string) {
+	ensureMetrics()
+	truncationTotal.WithLabelValues(method, part).Inc()
+}
+
+// incReportMetric records prometheus metrics about a reported error.
+func incReportMetric(result string) {
+	ensureMetrics()
+	reportTotal.WithLabelValues(result).Inc()
+}
@@ -0,0 +1,21 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package svcerr
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegisterMetrics(t *testing.T) {
+	reg1 := prometheus.NewRegistry()
+	reg2 := prometheus.NewRegistry()
+	require.NoError(t, RegisterMetrics(reg1))
+	require.ErrorIs(t, RegisterMetrics(reg1), ErrMetricsRegistered)
+	// A second registerer is not ignored.
+	require.NoError(t, RegisterMetrics(reg2))
+	require.True(t, reg2.Unregister(exceptionTotal))
+	require.NoError(t, RegisterMetrics(nil))
+}
@@ -0,0 +1,249 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package svcerr
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	common "buf.build/gen/go/luthersystems/protos/protocolbuffers/go/common/v1"
+	"github.com/luthersystems/svc/grpclogging"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// DefaultReportRateLimit is the default maximum number of errors
+	// reported per minute.
+	DefaultReportRateLimit = 60
+
+	// maxReportFrames is the maximum number of stack frames reported.
+	maxReportFrames = 64
+	// scrubbedText replaces values removed by ScrubPII.
+	scrubbedText = "[scrubbed]"
+)
+
+// Report results.
+const (
+	reportSent    = "sent"
+	reportLimited = "rate_limited"
+)
+
+// StackFrame is a frame of the stack trace of a reported error, innermost
+// first.
+type StackFrame struct {
+	Function string
+	File     string
+	Line     int
+}
+
+// ErrorReport is an error forwarded to an ErrorReporter.  Text fields have
+// been scrubbed of personal data.
+type ErrorReport struct {
+	// Error is the message of the masked or infrastructure error.
+	Error string
+	// Exception is the exception presented to the client.
+	Exception *common.Exception
+	// Stack is the stack trace of the error if it carries one, or the
+	// stack where it was reported.
+	Stack     []StackFrame
+	ReqID     string
+	Method    string
+	Tags      map[string]string
+	Timestamp time.Time
+}
+
+// ErrorReporter forwards errors to an error reporting backend, such as
+// Sentry.  ReportError is called while serving requests, so implementations
+// must not block on the backend.
+type ErrorReporter interface {
+	ReportError(ctx context.Context, r *ErrorReport)
+}
+
+// ReportOptions configures the forwarding of errors to an ErrorReporter.
+type ReportOptions struct {
+	// RateLimit is the maximum number of errors reported per minute.
+	// Further errors are dropped and counted by the error_reports_total
+	// metric.  If zero DefaultReportRateLimit is used.
+	RateLimit int
+	// Scrub removes personal data from report text.  If nil ScrubPII is
+	// used.
+	Scrub func(string) string
+	// Tags are added to every report, e.g. the environment or release.
+	Tags map[string]string
+}
+
+// errorReporting holds the reporter and its rate limit state.
+type errorReporting struct {
+	reporter ErrorReporter
+	opts     ReportOptions
+
+	mut         sync.Mutex
+	windowStart time.Time
+	windowCount int
+}
+
+var (
+	reportingMut sync.RWMutex
+	reporting    *errorReporting
+)
+
+// SetErrorReporter forwards internal errors masked by
+// AppErrorUnaryInterceptor and ErrIntercept, i.e. those which clients
+// receive as "Internal server error", and INFRASTRUCTURE exceptions
+// returned by handlers through AppErrorUnaryInterceptor to r, with the
+// request ID, method and stack.  A nil r disables reporting.
+func SetErrorReporter(r ErrorReporter, opts ReportOptions) {
+	reportingMut.Lock()
+	defer reportingMut.Unlock()
+	if r == nil {
+		reporting = nil
+		return
+	}
+	if opts.RateLimit <= 0 {
+		opts.RateLimit = DefaultReportRateLimit
+	}
+	if opts.Scrub == nil {
+		opts.Scrub = ScrubPII
+	}
+	reporting = &errorReporting{reporter: r, opts: opts}
+}
+
+func getErrorReporting() *errorReporting {
+	reportingMut.RLock()
+	defer reportingMut.RUnlock()
+	return reporting
+}
+
+// allow returns true if the rate limit allows another report at now.
+func (rep *errorReporting) allow(now time.Time) bool {
+	rep.mut.Lock()
+	defer rep.mut.Unlock()
+	if now.Sub(rep.windowStart) >= time.Minute || now.Before(rep.windowStart) {
+		rep.windowStart, rep.windowCount = now, 0
+	}
+	if rep.windowCount >= rep.opts.RateLimit {
+		return false
+	}
+	rep.windowCount++
+	return true
+}
+
+// reportError forwards cause, presented to the client as except, to the
+// error reporter.  Client cancelations are not reported.
+func reportError(ctx context.Context, cause error, except *common.Exception) {
+	rep := getErrorReporting()
+	if rep == nil || errors.Is(cause, context.Canceled) {
+		return
+	}
+	now := clockNow(ctx)
+	if !rep.allow(now) {
+		incReportMetric(reportLimited)
+		return
+	}
+	msg := except.GetDescription()
+	if cause != nil {
+		msg = cause.Error()
+	}
+	reported := &common.Exception{
+		Id:          except.GetId(),
+		Type:        except.GetType(),
+		Timestamp:   except.GetTimestamp(),
+		Description: rep.opts.Scrub(except.GetDescription()),
+	}
+	tags := map[string]string{
+		"exception_type": except.GetType().String(),
+	}
+	if cause != nil {
+		tags["grpc_code"] = status.Code(cause).String()
+	}
+	for k, v := range rep.opts.Tags {
+		tags[k] = rep.opts.Scrub(v)
+	}
+	rep.reporter.ReportError(ctx, &ErrorReport{
+		Error:     rep.opts.Scrub(msg),
+		Exception: reported,
+		Stack:     errorStack(cause),
+		ReqID:     grpclogging.ReqID(ctx),
+		Method:    reportMethod(ctx),
+		Tags:      tags,
+		Timestamp: now,
+	})
+	incReportMetric(reportSent)
+}
+
+// reportInfrastructure reports err if it presents an INFRASTRUCTURE
+// exception to the client.  The cause is the error returned by the handler,
+// if any.
+func reportInfrastructure(ctx context.Context, cause error, err error) {
+	stat, ok := status.FromError(err)
+	if !ok || len(stat.Details()) != 1 {
+		return
+	}
+	except, ok := stat.Details()[0].(*common.Exception)
+	if !ok || except.GetType() != common.Exception_INFRASTRUCTURE {
+		return
+	}
+	reportError(ctx, cause, except)
+}
+
+// reportMethod returns the gRPC method of a request served by the gRPC
+// server or the gateway.
+func reportMethod(ctx context.Context) string {
+	if method, ok := grpc.Method(ctx); ok {
+		return method
+	}
+	return rpcMethod(ctx)
+}
+
+// errorStack returns the stack trace of err, if an error in its chain
+// records the program counters where it was created with a Callers method,
+// or else the current stack outside of this package.
+func errorStack(err error) []StackFrame {
+	var pcs []uintptr
+	var withCallers interface{ Callers() []uintptr }
+	current := !errors.As(err, &withCallers)
+	if current {
+		pcs = make([]uintptr, maxReportFrames+8)
+		pcs = pcs[:runtime.Callers(2, pcs)]
+	} else {
+		pcs = withCallers.Callers()
+	}
+	var stack []StackFrame
+	frames := runtime.CallersFrames(pcs)
+	for {
+		frame, more := frames.Next()
+		skip := current && strings.HasPrefix(frame.Function, "github.com/luthersystems/svc/svcerr.")
+		if !skip && frame.Function != "" {
+			stack = append(stack, StackFrame{Function: frame.Function, File: frame.File, Line: frame.Line})
+		}
+		if !more || len(stack) == maxReportFrames {
+			return stack
+		}
+	}
+}
+
+var piiRegexps = []*regexp.Regexp{
+	// Email addresses.
+	regexp.MustCompile(`[a-zA-Z0-9._%+-]+@[a-zA-Z0-9.-]+\.[a-zA-Z]{2,}`),
+	// JSON web tokens.
+	regexp.MustCompile(`eyJ[a-zA-Z0-9_-]*\.[a-zA-Z0-9_-]+\.[a-zA-Z0-9_-]*`),
+	// Authorization credentials.
+	regexp.MustCompile(`(?i)\b(bearer|basic)\s+[a-zA-Z0-9._~+/=-]+`),
+	// Card and account numbers, and phone numbers.
+	regexp.MustCompile(`\+?\d(?:[ -]?\d){8,18}`),
+}
+
+// ScrubPII replaces email addresses, tokens, card numbers and phone numbers
+// in s with "[scrubbed]".  It is the default scrubber of error reports.
+func ScrubPII(s string) string {
+	for _, re := range piiRegexps {
+		s = re.ReplaceAllString(s, scrubbedText)
+	}
+	return s
+}
@@ -0,0 +1,113 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package svcerr
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	common "buf.build/gen/go/luthersystems/protos/protocolbuffers/go/common/v1"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type memReporter struct {
+	mut     sync.Mutex
+	reports []*ErrorReport
+}
+
+func (m *memReporter) ReportError(ctx context.Context, r *ErrorReport) {
+	m.mut.Lock()
+	defer m.mut.Unlock()
+	m.reports = append(m.reports, r)
+}
+
+// stackError records the stack where it was created.
+type stackError struct {
+	msg string
+	pcs []uintptr
+}
+
+func newStackError(msg string) *stackError {
+	pcs := make([]uintptr, 32)
+	return &stackError{msg: msg, pcs: pcs[:runtime.Callers(1, pcs)]}
+}
+
+func (e *stackError) Error() string      { return e.msg }
+func (e *stackError) Callers() []uintptr { return e.pcs }
+
+func TestErrorReporter(t *testing.T) {
+	rep := &memReporter{}
+	SetErrorReporter(rep, ReportOptions{RateLimit: 2, Tags: map[string]string{"env": "test"}})
+	defer SetErrorReporter(nil, ReportOptions{})
+
+	entry := logrus.NewEntry(logrus.New())
+	log := func(ctx context.Context) *logrus.Entry {
+		return entry
+	}
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	ctx := WithClock(context.Background(), func() time.Time { return now })
+	intercept := AppErrorUnaryInterceptor(log)
+	call := func(err error) error {
+		_, err = intercept(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/test.v1.Service/Method"}, func(ctx context.Context, req interface{}) (interface{}, error) {
+			return &common.ExceptionResponse{}, err
+		})
+		return err
+	}
+	sent := testutil.ToFloat64(reportTotal.WithLabelValues(reportSent))
+	limited := testutil.ToFloat64(reportTotal.WithLabelValues(reportLimited))
+
+	// Masked errors are reported, scrubbed.
+	err := call(newStackError("lookup alice@example.com failed"))
+	require.Equal(t, codes.Internal, status.Code(err))
+	// Other errors, and client cancelations, are not.
+	require.Equal(t, codes.InvalidArgument, status.Code(call(status.Error(codes.InvalidArgument, "bad"))))
+	call(context.Canceled)
+	call(status.Error(codes.Internal, "database unavailable"))
+	require.Len(t, rep.reports, 2)
+
+	r := rep.reports[0]
+	require.Equal(t, "lookup [scrubbed] failed", r.Error)
+	require.Equal(t, common.Exception_UNEXPECTED, r.Exception.GetType())
+	require.Equal(t, "Internal server error", r.Exception.GetDescription())
+	require.Equal(t, now, r.Timestamp)
+	require.Equal(t, "test", r.Tags["env"])
+	require.NotEmpty(t, r.Stack)
+	require.Contains(t, r.Stack[0].Function, "newStackError")
+
+	// INFRASTRUCTURE exceptions are reported.
+	r = rep.reports[1]
+	require.Equal(t, "rpc error: code = Internal desc = database unavailable", r.Error)
+	require.Equal(t, common.Exception_INFRASTRUCTURE, r.Exception.GetType())
+	require.Equal(t, "Internal", r.Tags["grpc_code"])
+
+	// Reports are rate limited.
+	call(errors.New("another"))
+	require.Len(t, rep.reports, 2)
+	require.Equal(t, sent+2, testutil.ToFloat64(reportTotal.WithLabelValues(reportSent)))
+	require.Equal(t, limited+1, testutil.ToFloat64(reportTotal.WithLabelValues(reportLimited)))
+	now = now.Add(time.Minute)
+	call(errors.New("another"))
+	require.Len(t, rep.reports, 3)
+}
+
+func TestScrubPII(t *testing.T) {
+	for in, want := range map[string]string{
+		"user bob.smith+x@example.co.uk not found":   "user [scrubbed] not found",
+		"card 4111 1111 1111 1111 declined":          "card [scrubbed] declined",
+		"call +44 20 7946 0958":                      "call [scrubbed]",
+		"Authorization: Bearer abc.def-ghi":          "Authorization: [scrubbed]",
+		"token eyJhbGciOi.eyJzdWIiOiIx.sig rejected": "token [scrubbed] rejected",
+		"order 1234 failed":                          "order 1234 failed",
+	} {
+		require.Equal(t, want, ScrubPII(in), in)
+	}
+}
@@ -0,0 +1,269 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+// Package sentry implements an svcerr.ErrorReporter sending errors to
+// Sentry, or a service accepting Sentry events, e.g. GlitchTip.
+//
+// Events are sent to the envelope endpoint of the project identified by the
+// DSN.  They are sent asynchronously, and dropped rather than blocking
+// requests if Sentry is slow or unavailable.
+package sentry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/luthersystems/svc/svcerr"
+)
+
+const (
+	clientName     = "luthersystems-svc/1.0"
+	defaultTimeout = 5 * time.Second
+	queueSize      = 100
+)
+
+var _ svcerr.ErrorReporter = &Reporter{}
+
+// Option configures a Reporter.
+type Option func(*Reporter)
+
+// WithEnvironment sets the environment of events, e.g. "production".
+func WithEnvironment(env string) Option {
+	return func(r *Reporter) {
+		r.environment = env
+	}
+}
+
+// WithRelease sets the release of events, e.g. the service version.
+func WithRelease(release string) Option {
+	return func(r *Reporter) {
+		r.release = release
+	}
+}
+
+// WithServerName sets the server name of events, e.g. the host name.
+func WithServerName(name string) Option {
+	return func(r *Reporter) {
+		r.serverName = name
+	}
+}
+
+// WithHTTPClient sets the client sending events.
+func WithHTTPClient(c *http.Client) Option {
+	return func(r *Reporter) {
+		r.client = c
+	}
+}
+
+// Reporter sends error reports to Sentry.  Close stops the reporter.
+type Reporter struct {
+	dsn         string
+	endpoint    string
+	auth        string
+	environment string
+	release     string
+	serverName  string
+	client      *http.Client
+
+	queue chan *event
+	done  chan struct{}
+	once  sync.Once
+
+	mut    sync.Mutex
+	closed bool
+}
+
+// New returns a reporter sending events to the project of dsn, e.g.
+// "https://<key>@o0.ingest.sentry.io/<project>".
+func New(dsn string, opts ...Option) (*Reporter, error) {
+	u, err := url.Parse(dsn)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid dsn")
+	}
+	key := u.User.Username()
+	dir, project := path.Split(strings.TrimSuffix(u.Path, "/"))
+	if key == "" || project == "" {
+		return nil, fmt.Errorf("invalid dsn: missing key or project")
+	}
+	endpoint := url.URL{
+		Scheme: u.Scheme,
+		Host:   u.Host,
+		Path:   path.Join(dir, "api", project, "envelope") + "/",
+	}
+	r := &Reporter{
+		dsn:      dsn,
+		endpoint: endpoint.String(),
+		auth:     fmt.Sprintf("Sentry sentry_version=7, sentry_client=%s, sentry_key=%s", clientName, key),
+		client:   &http.Client{Timeout: defaultTimeout},
+		queue:    make(chan *event, queueSize),
+		done:     make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	go r.run()
+	return r, nil
+}
+
+// event is a Sentry event.
+type event struct {
+	EventID     string            `json:"event_id"`
+	Timestamp   time.Time         `json:"timestamp"`
+	Level       string            `json:"level"`
+	Platform    string            `json:"platform"`
+	Environment string            `json:"environment,omitempty"`
+	Release     string            `json:"release,omitempty"`
+	ServerName  string            `json:"server_name,omitempty"`
+	Transaction string            `json:"transaction,omitempty"`
+	Exception   exceptions        `json:"exception"`
+	Tags        map[string]string `json:"tags,omitempty"`
+	Extra       map[string]string `json:"extra,omitempty"`
+}
+
+type exceptions struct {
+	Values []exception `json:"values"`
+}
+
+type exception struct {
+	Type       string      `json:"type"`
+	Value      string      `json:"value"`
+	Stacktrace *stacktrace `json:"stacktrace,omitempty"`
+}
+
+type stacktrace struct {
+	// Frames are ordered outermost first.
+	Frames []frame `json:"frames"`
+}
+
+type frame struct {
+	Function string `json:"function"`
+	Filename string `json:"filename"`
+	AbsPath  string `json:"abs_path"`
+	Lineno   int    `json:"lineno"`
+}
+
+// ReportError implements svcerr.ErrorReporter.
+func (r *Reporter) ReportError(ctx context.Context, report *svcerr.ErrorReport) {
+	ev := r.event(report)
+	r.mut.Lock()
+	defer r.mut.Unlock()
+	if r.closed {
+		return
+	}
+	select {
+	case r.queue <- ev:
+	default:
+	}
+}
+
+// event returns the Sentry event of a report.
+func (r *Reporter) event(report *svcerr.ErrorReport) *event {
+	tags := map[string]string{}
+	for k, v := range report.Tags {
+		tags[k] = v
+	}
+	if report.ReqID != "" {
+		tags["req_id"] = report.ReqID
+	}
+	ex := exception{
+		Type:  report.Exception.GetType().String(),
+		Value: report.Error,
+	}
+	if len(report.Stack) > 0 {
+		ex.Stacktrace = &stacktrace{}
+		for i := len(report.Stack) - 1; i >= 0; i-- {
+			f := report.Stack[i]
+			ex.Stacktrace.Frames = append(ex.Stacktrace.Frames, frame{
+				Function: f.Function,
+				Filename: path.Base(f.File),
+				AbsPath:  f.File,
+				Lineno:   f.Line,
+			})
+		}
+	}
+	return &event{
+		EventID:     strings.ReplaceAll(uuid.NewString(), "-", ""),
+		Timestamp:   report.Timestamp.UTC(),
+		Level:       "error",
+		Platform:    "go",
+		Environment: r.environment,
+		Release:     r.release,
+		ServerName:  r.serverName,
+		Transaction: report.Method,
+		Exception:   exceptions{Values: []exception{ex}},
+		Tags:        tags,
+		Extra: map[string]string{
+			"exception_id":          report.Exception.GetId(),
+			"exception_description": report.Exception.GetDescription(),
+		},
+	}
+}
+
+func (r *Reporter) run() {
+	defer close(r.done)
+	for ev := range r.queue {
+		r.send(ev)
+	}
+}
+
+// send posts an event in an envelope.  Errors are dropped, as reporting
+// them would report further errors.
+func (r *Reporter) send(ev *event) {
+	payload, err := json.Marshal(ev)
+	if err != nil {
+		return
+	}
+	header, err := json.Marshal(map[string]string{
+		"event_id": ev.EventID,
+		"dsn":      r.dsn,
+		"sent_at":  time.Now().UTC().Format(time.RFC3339),
+	})
+	if err != nil {
+		return
+	}
+	var body bytes.Buffer
+	body.Write(header)
+	fmt.Fprintf(&body, "\n{\"type\":\"event\",\"length\":%d}\n", len(payload))
+	body.Write(payload)
+	body.WriteByte('\n')
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.endpoint, &body)
+	if err != nil {
+		return
+	}
+	req.Header.Set("Content-Type", "application/x-sentry-envelope")
+	req.Header.Set("X-Sentry-Auth", r.auth)
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+}
+
+// Close stops accepting reports and waits for queued events to be sent, or
+// until ctx is done.
+func (r *Reporter) Close(ctx context.Context) error {
+	r.once.Do(func() {
+		r.mut.Lock()
+		r.closed = true
+		close(r.queue)
+		r.mut.Unlock()
+	})
+	select {
+	case <-r.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,84 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package sentry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	common "buf.build/gen/go/luthersystems/protos/protocolbuffers/go/common/v1"
+	"github.com/luthersystems/svc/svcerr"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNew(t *testing.T) {
+	for _, dsn := range []string{"", "not a url", "https://sentry.example.com/1", "https://key@sentry.example.com/"} {
+		_, err := New(dsn)
+		require.Error(t, err, dsn)
+	}
+	r, err := New("https://key@sentry.example.com/prefix/42")
+	require.NoError(t, err)
+	require.Equal(t, "https://sentry.example.com/prefix/api/42/envelope/", r.endpoint)
+	require.NoError(t, r.Close(context.Background()))
+}
+
+func TestReportError(t *testing.T) {
+	type request struct {
+		path, auth string
+		body       []byte
+	}
+	received := make(chan request, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received <- request{path: r.URL.Path, auth: r.Header.Get("X-Sentry-Auth"), body: body}
+	}))
+	defer srv.Close()
+
+	dsn := "http://public@" + srv.Listener.Addr().String() + "/7"
+	r, err := New(dsn, WithEnvironment("test"), WithRelease("v1.2.3"))
+	require.NoError(t, err)
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	r.ReportError(context.Background(), &svcerr.ErrorReport{
+		Error:     "connection refused",
+		Exception: &common.Exception{Id: "req-1", Type: common.Exception_UNEXPECTED, Description: "Internal server error"},
+		Stack: []svcerr.StackFrame{
+			{Function: "main.inner", File: "/src/inner.go", Line: 10},
+			{Function: "main.outer", File: "/src/outer.go", Line: 20},
+		},
+		ReqID:     "req-1",
+		Method:    "/test.v1.Service/Method",
+		Tags:      map[string]string{"exception_type": "UNEXPECTED"},
+		Timestamp: now,
+	})
+	require.NoError(t, r.Close(context.Background()))
+
+	req := <-received
+	require.Equal(t, "/api/7/envelope/", req.path)
+	require.Contains(t, req.auth, "sentry_key=public")
+	lines := bytes.Split(bytes.TrimSpace(req.body), []byte("\n"))
+	require.Len(t, lines, 3)
+	var ev event
+	require.NoError(t, json.Unmarshal(lines[2], &ev))
+	require.Len(t, ev.EventID, 32)
+	require.Equal(t, now, ev.Timestamp)
+	require.Equal(t, "test", ev.Environment)
+	require.Equal(t, "v1.2.3", ev.Release)
+	require.Equal(t, "/test.v1.Service/Method", ev.Transaction)
+	require.Equal(t, "req-1", ev.Tags["req_id"])
+	require.Equal(t, "UNEXPECTED", ev.Tags["exception_type"])
+	require.Len(t, ev.Exception.Values, 1)
+	ex := ev.Exception.Values[0]
+	require.Equal(t, "connection refused", ex.Value)
+	// Sentry frames are outermost first.
+	require.Equal(t, "main.outer", ex.Stacktrace.Frames[0].Function)
+	require.Equal(t, "inner.go", ex.Stacktrace.Frames[1].Filename)
+
+	// Reports after Close are dropped.
+	r.ReportError(context.Background(), &svcerr.ErrorReport{})
+}
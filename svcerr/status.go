@@ -0,0 +1,179 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package svcerr
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	common "buf.build/gen/go/luthersystems/protos/protocolbuffers/go/common/v1"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// methodStatusKey identifies the exceptions of a type returned by a method.
+type methodStatusKey struct {
+	method string
+	typ    common.Exception_Type
+}
+
+var (
+	methodStatusMut sync.RWMutex
+	methodStatuses  = map[methodStatusKey]int{}
+)
+
+// SetMethodHTTPStatus pins the HTTP status of exceptions of type t returned
+// by a gRPC method, e.g. 404 instead of 400 for business exceptions returned
+// by "/pkg.v1.FooService/GetFoo" when the entity is missing.  The method is
+// the full gRPC method name.
+//
+// ErrIntercept responds with the pinned status.  When the status has a gRPC
+// equivalent, AppErrorUnaryInterceptor also returns errors with the
+// equivalent gRPC code, so that gRPC clients observe the same status.
+// Setting a zero status removes the override.
+func SetMethodHTTPStatus(method string, t common.Exception_Type, httpStatus int) {
+	methodStatusMut.Lock()
+	defer methodStatusMut.Unlock()
+	key := methodStatusKey{method: method, typ: t}
+	if httpStatus == 0 {
+		delete(methodStatuses, key)
+		return
+	}
+	methodStatuses[key] = httpStatus
+}
+
+// RegisterMethodHTTPStatusOptions pins HTTP statuses from method options
+// annotating the methods of a service.  The option xt must be a string (or
+// repeated string) extension of google.protobuf.MethodOptions, whose values
+// are of the form "<exception type>=<http status>", e.g.:
+//
+//	extend google.protobuf.MethodOptions {
+//	  repeated string http_status = 50001;
+//	}
+//
+//	rpc GetFoo(GetFooRequest) returns (GetFooResponse) {
+//	  option (http_status) = "BUSINESS=404";
+//	}
+func RegisterMethodHTTPStatusOptions(sd protoreflect.ServiceDescriptor, xt protoreflect.ExtensionType) error {
+	xd := xt.TypeDescriptor()
+	if xd.ContainingMessage().FullName() != (&descriptorpb.MethodOptions{}).ProtoReflect().Descriptor().FullName() {
+		return fmt.Errorf("svcerr: %s does not extend method options", xd.FullName())
+	}
+	if xd.Kind() != protoreflect.StringKind {
+		return fmt.Errorf("svcerr: %s is not a string option", xd.FullName())
+	}
+	methods := sd.Methods()
+	for i := 0; i < methods.Len(); i++ {
+		md := methods.Get(i)
+		opts, ok := md.Options().(*descriptorpb.MethodOptions)
+		if !ok || opts == nil {
+			continue
+		}
+		opt := opts.ProtoReflect()
+		if !opt.Has(xd) {
+			continue
+		}
+		var values []string
+		if v := opt.Get(xd); xd.IsList() {
+			for j := 0; j < v.List().Len(); j++ {
+				values = append(values, v.List().Get(j).String())
+			}
+		} else {
+			values = append(values, v.String())
+		}
+		method := fmt.Sprintf("/%s/%s", sd.FullName(), md.Name())
+		for _, value := range values {
+			t, code, err := parseMethodHTTPStatus(value)
+			if err != nil {
+				return fmt.Errorf("svcerr: %s: %w", method, err)
+			}
+			SetMethodHTTPStatus(method, t, code)
+		}
+	}
+	return nil
+}
+
+// parseMethodHTTPStatus parses a "<exception type>=<http status>" option.
+func parseMethodHTTPStatus(s string) (common.Exception_Type, int, error) {
+	name, code, ok := strings.Cut(s, "=")
+	if !ok {
+		return 0, 0, fmt.Errorf("invalid http status option %q", s)
+	}
+	t, ok := common.Exception_Type_value[strings.TrimSpace(name)]
+	if !ok || common.Exception_Type(t) == common.Exception_INVALID_TYPE {
+		return 0, 0, fmt.Errorf("invalid exception type %q", name)
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(code))
+	if err != nil || http.StatusText(n) == "" {
+		return 0, 0, fmt.Errorf("invalid http status %q", code)
+	}
+	return common.Exception_Type(t), n, nil
+}
+
+// methodHTTPStatus returns the HTTP status pinned for exceptions of type t
+// returned by method, if any.
+func methodHTTPStatus(method string, t common.Exception_Type) (int, bool) {
+	methodStatusMut.RLock()
+	defer methodStatusMut.RUnlock()
+	code, ok := methodStatuses[methodStatusKey{method: method, typ: t}]
+	return code, ok
+}
+
+// detailExceptionType returns the exception type of an error detail, which
+// is either an exception or a response raising one.
+func detailExceptionType(detail interface{}) (common.Exception_Type, bool) {
+	switch d := detail.(type) {
+	case *common.Exception:
+		return d.GetType(), true
+	case raiser:
+		if d.GetException() == nil {
+			return 0, false
+		}
+		return d.GetException().GetType(), true
+	default:
+		return 0, false
+	}
+}
+
+// httpStatusCode returns the gRPC code mapped to an HTTP status by the
+// gateway.  The first code in numeric order is preferred, e.g.
+// codes.InvalidArgument for 400.
+func httpStatusCode(httpStatus int) (codes.Code, bool) {
+	for c := codes.Canceled; c <= codes.Unauthenticated; c++ {
+		if runtime.HTTPStatusFromCode(c) == httpStatus {
+			return c, true
+		}
+	}
+	return codes.Unknown, false
+}
+
+// overrideMethodStatus replaces the code of err, returned by method, with the
+// gRPC equivalent of its pinned HTTP status.
+func overrideMethodStatus(method string, err error) error {
+	stat, ok := status.FromError(err)
+	if !ok || len(stat.Details()) != 1 {
+		return err
+	}
+	t, ok := detailExceptionType(stat.Details()[0])
+	if !ok {
+		return err
+	}
+	httpStatus, ok := methodHTTPStatus(method, t)
+	if !ok || runtime.HTTPStatusFromCode(stat.Code()) == httpStatus {
+		return err
+	}
+	code, ok := httpStatusCode(httpStatus)
+	if !ok {
+		// The gateway applies the status.
+		return err
+	}
+	p := stat.Proto()
+	p.Code = int32(code)
+	return status.ErrorProto(p)
+}
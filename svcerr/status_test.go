@@ -0,0 +1,128 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package svcerr
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	common "buf.build/gen/go/luthersystems/protos/protocolbuffers/go/common/v1"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+func TestMethodHTTPStatus(t *testing.T) {
+	const method = "/svcerr.test.FooService/GetFoo"
+	SetMethodHTTPStatus(method, common.Exception_BUSINESS, http.StatusNotFound)
+	SetMethodHTTPStatus(method, common.Exception_SECURITY_VIOLATION, http.StatusUnprocessableEntity)
+	defer SetMethodHTTPStatus(method, common.Exception_BUSINESS, 0)
+	defer SetMethodHTTPStatus(method, common.Exception_SECURITY_VIOLATION, 0)
+
+	entry := logrus.NewEntry(logrus.New())
+	log := func(ctx context.Context) *logrus.Entry {
+		return entry
+	}
+	intercept := AppErrorUnaryInterceptor(log)
+	call := func(fullMethod string, err error) error {
+		_, err = intercept(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: fullMethod},
+			func(ctx context.Context, req interface{}) (interface{}, error) {
+				return &common.ExceptionResponse{}, err
+			})
+		return err
+	}
+
+	require.Equal(t, codes.NotFound, status.Code(call(method, NewBusinessError("missing foo"))))
+	require.Equal(t, codes.InvalidArgument, status.Code(call("/svcerr.test.FooService/ListFoo", NewBusinessError("bad"))))
+	// 422 has no gRPC equivalent and is applied by the gateway.
+	err := call(method, NewSecurityError("denied"))
+	require.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	serve := func(err error) *httptest.ResponseRecorder {
+		mux := runtime.NewServeMux()
+		r := httptest.NewRequest(http.MethodGet, "/v1/foo/1", nil)
+		ctx, aerr := runtime.AnnotateContext(r.Context(), mux, r, method)
+		require.NoError(t, aerr)
+		w := httptest.NewRecorder()
+		ErrIntercept(log)(ctx, mux, &runtime.JSONPb{}, w, r, err)
+		return w
+	}
+	require.Equal(t, http.StatusNotFound, serve(call(method, NewBusinessError("missing foo"))).Code)
+	require.Equal(t, http.StatusUnprocessableEntity, serve(err).Code)
+	require.Equal(t, http.StatusServiceUnavailable, serve(call(method, NewServiceError("down"))).Code)
+}
+
+func TestRegisterMethodHTTPStatusOptions(t *testing.T) {
+	optFD, err := protodesc.NewFile(&descriptorpb.FileDescriptorProto{
+		Name:       proto.String("svcerr/status_option_test.proto"),
+		Package:    proto.String("svcerr.test"),
+		Syntax:     proto.String("proto3"),
+		Dependency: []string{descriptorpb.File_google_protobuf_descriptor_proto.Path()},
+		Extension: []*descriptorpb.FieldDescriptorProto{{
+			Name:     proto.String("http_status"),
+			JsonName: proto.String("httpStatus"),
+			Number:   proto.Int32(50001),
+			Label:    descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum(),
+			Type:     descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum(),
+			Extendee: proto.String(".google.protobuf.MethodOptions"),
+		}},
+	}, protoregistry.GlobalFiles)
+	require.NoError(t, err)
+	files := new(protoregistry.Files)
+	require.NoError(t, files.RegisterFile(optFD))
+	xt := dynamicpb.NewExtensionType(optFD.Extensions().Get(0))
+
+	opts := &descriptorpb.MethodOptions{}
+	list := opts.ProtoReflect().Mutable(xt.TypeDescriptor()).List()
+	list.Append(protoreflect.ValueOfString("BUSINESS=404"))
+	list.Append(protoreflect.ValueOfString("SECURITY_VIOLATION=401"))
+
+	svcFD, err := protodesc.NewFile(&descriptorpb.FileDescriptorProto{
+		Name:       proto.String("svcerr/status_service_test.proto"),
+		Package:    proto.String("svcerr.test"),
+		Syntax:     proto.String("proto3"),
+		Dependency: []string{optFD.Path()},
+		MessageType: []*descriptorpb.DescriptorProto{{
+			Name: proto.String("Empty"),
+		}},
+		Service: []*descriptorpb.ServiceDescriptorProto{{
+			Name: proto.String("BarService"),
+			Method: []*descriptorpb.MethodDescriptorProto{{
+				Name:       proto.String("GetBar"),
+				InputType:  proto.String(".svcerr.test.Empty"),
+				OutputType: proto.String(".svcerr.test.Empty"),
+				Options:    opts,
+			}},
+		}},
+	}, files)
+	require.NoError(t, err)
+
+	const method = "/svcerr.test.BarService/GetBar"
+	defer SetMethodHTTPStatus(method, common.Exception_BUSINESS, 0)
+	defer SetMethodHTTPStatus(method, common.Exception_SECURITY_VIOLATION, 0)
+	require.NoError(t, RegisterMethodHTTPStatusOptions(svcFD.Services().Get(0), xt))
+	code, ok := methodHTTPStatus(method, common.Exception_BUSINESS)
+	require.True(t, ok)
+	require.Equal(t, http.StatusNotFound, code)
+	code, ok = methodHTTPStatus(method, common.Exception_SECURITY_VIOLATION)
+	require.True(t, ok)
+	require.Equal(t, http.StatusUnauthorized, code)
+	_, ok = methodHTTPStatus(method, common.Exception_UNEXPECTED)
+	require.False(t, ok)
+
+	for _, s := range []string{"BUSINESS", "NOPE=404", "BUSINESS=abc", "BUSINESS=999"} {
+		_, _, err := parseMethodHTTPStatus(s)
+		require.Error(t, err, s)
+	}
+}
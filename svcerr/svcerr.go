@@ -16,7 +16,6 @@ import (
 	common "buf.build/gen/go/luthersystems/protos/protocolbuffers/go/common/v1"
 	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
 	"github.com/luthersystems/svc/grpclogging"
-	"github.com/prometheus/client_golang/prometheus"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
@@ -29,9 +28,6 @@ const (
 	TimestampFormat = time.RFC3339
 )
 
-// incExceptionMetric records prometheus metrics about a returned exception.
-var incExceptionMetric func(*common.Exception)
-
 var _ error = &lutherError{}
 
 // lutherError represents a Luther managed error.
@@ -44,6 +40,42 @@ func (s *lutherError) Error() string {
 	return s.GetDescription()
 }
 
+// GetException returns the exception presented by the error.
+func (s *lutherError) GetException() *common.Exception {
+	return &s.Exception
+}
+
+// ExceptionError returns the raw Luther error of the exception's type, e.g.
+// a *BusinessError for a business exception.  It is typically used to
+// convert exceptions returned by other services back into errors.  The
+// exception is copied, and exceptions of invalid or unknown type result in
+// an *UnexpectedError.
+func ExceptionError(except *common.Exception) error {
+	var err interface {
+		error
+		setException(*common.Exception)
+	}
+	switch except.GetType() {
+	case common.Exception_BUSINESS:
+		err = &BusinessError{}
+	case common.Exception_SECURITY_VIOLATION:
+		err = &SecurityError{}
+	case common.Exception_INFRASTRUCTURE:
+		err = &InfrastructureError{}
+	case common.Exception_SERVICE_NOT_AVAILABLE:
+		err = &ServiceError{}
+	default:
+		err = &UnexpectedError{}
+	}
+	err.setException(except)
+	return err
+}
+
+// setException copies except into the error.
+func (s *lutherError) setException(except *common.Exception) {
+	proto.Merge(&s.Exception, except)
+}
+
 // NewUnexpectedError constructs an unexpected error.
 func NewUnexpectedError(message string) *UnexpectedError {
 	return &UnexpectedError{
@@ -114,20 +146,15 @@ type ServiceError struct {
 	lutherError
 }
 
-func init() {
-	{ // register exception type counts
-		exceptionTotal := prometheus.NewCounterVec(
-			prometheus.CounterOpts{
-				Name: "exception_total",
-				Help: "How many exception responses, partitioned by exception type.",
-			},
-			[]string{"type"},
-		)
-		incExceptionMetric = func(e *common.Exception) {
-			exceptionTotal.WithLabelValues(e.GetType().String()).Inc()
-		}
-		prometheus.MustRegister(exceptionTotal)
+// rpcMethod returns the gRPC method being served by the gateway.  The set of
+// methods is bounded by the registered services, any other request (e.g.,
+// unmatched routes) is reported as "unknown".
+func rpcMethod(ctx context.Context) string {
+	method, ok := runtime.RPCMethod(ctx)
+	if !ok || method == "" {
+		return "unknown"
 	}
+	return method
 }
 
 // raiser raises exceptions
@@ -135,9 +162,13 @@ type raiser interface {
 	GetException() *common.Exception
 }
 
-func internalError(ctx context.Context) error {
+// internalError returns the error presented to clients in place of cause,
+// which is masked.  The cause is forwarded to the error reporter.
+func internalError(ctx context.Context, cause error) error {
+	except := UnexpectedException(ctx, "Internal server error")
+	reportError(ctx, cause, except)
 	intStat, intErr := status.New(codes.Internal, "Internal server error").
-		WithDetails(UnexpectedException(ctx, "Internal server error"))
+		WithDetails(except)
 	if intErr != nil {
 		// This should never throw an error, and indicates a serious problem.
 		panic(intErr)
@@ -179,14 +210,14 @@ func grpcToLutherError(ctx context.Context, log grpclogging.ServiceLogger, err e
 				// ignore client cancelations of request
 				log(ctx).WithError(err).Errorf("unhandled error")
 			}
-			return internalError(ctx)
+			return internalError(ctx, err)
 		}
 	}
 
 	if len(stat.Details()) > 1 {
 		// non-conventional error with more than one details
 		log(ctx).WithError(err).Errorf("error with len(details)=%d", len(stat.Details()))
-		return internalError(ctx)
+		return internalError(ctx, err)
 	}
 
 	if len(stat.Details()) == 1 {
@@ -198,7 +229,7 @@ func grpcToLutherError(ctx context.Context, log grpclogging.ServiceLogger, err e
 	switch stat.Code() {
 	case codes.OK:
 		log(ctx).WithError(err).Errorf("OK status code in error")
-		return internalError(ctx)
+		return internalError(ctx, err)
 	case codes.Canceled:
 		pbErr = UnexpectedException(ctx, stat.Message())
 	case codes.Unknown:
@@ -236,7 +267,7 @@ func grpcToLutherError(ctx context.Context, log grpclogging.ServiceLogger, err e
 		pbErr = SecurityException(ctx, "unauthenticated")
 	default:
 		log(ctx).WithError(err).Errorf("unknown code")
-		return internalError(ctx)
+		return internalError(ctx, err)
 	}
 
 	// case 3: coerced gRPC error into gRPC error with common.Exception
@@ -244,7 +275,7 @@ func grpcToLutherError(ctx context.Context, log grpclogging.ServiceLogger, err e
 	statDetails, statErr := stat.WithDetails(pbErr)
 	if statErr != nil {
 		log(ctx).WithError(statErr).Errorf("exception coercion")
-		return internalError(ctx)
+		return internalError(ctx, statErr)
 	}
 
 	return statDetails.Err()
@@ -256,6 +287,19 @@ func grpcToLutherError(ctx context.Context, log grpclogging.ServiceLogger, err e
 // a details field set to a single element array with an element of type
 // common.ExceptionResponse.
 //
+// Successful responses may carry non-fatal warnings, added by handlers with
+// AddWarning or set directly on the response warnings field.  Warnings are
+// counted separately from exceptions.
+//
+// The status of exceptions returned by specific methods may be pinned with
+// SetMethodHTTPStatus or RegisterMethodHTTPStatusOptions.
+//
+// Oversized exception descriptions and details are truncated to the limits
+// set with SetDetailLimits.  Errors are not truncated by default.
+//
+// Masked errors and INFRASTRUCTURE exceptions are forwarded to the reporter
+// set with SetErrorReporter.
+//
 // By convention, the application should only return errors that fall into the
 // following handled cases:
 //
@@ -278,9 +322,30 @@ func grpcToLutherError(ctx context.Context, log grpclogging.ServiceLogger, err e
 // Non-conventional errors are replaced with a generic "Internal server error"
 // error, and must log the original error so that we can debug and remove them.
 func AppErrorUnaryInterceptor(log grpclogging.ServiceLogger) func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	intercept := appErrorUnaryInterceptor(log)
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		start := time.Now()
+		var cause error
+		resp, err := intercept(ctx, req, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+			resp, err := handler(ctx, req)
+			cause = err
+			return resp, err
+		})
+		if err != nil {
+			reportInfrastructure(ctx, cause, err)
+			err = overrideMethodStatus(info.FullMethod, err)
+			err = truncateUnaryError(ctx, info.FullMethod, err)
+			observeErrorDuration(info.FullMethod, err, time.Since(start))
+		}
+		return resp, err
+	}
+}
+
+func appErrorUnaryInterceptor(log grpclogging.ServiceLogger) func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
 	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
 		// Defer to the method's handler and save the results to pass through
 		// for the interceptor's caller.
+		ctx, warnings := withWarnings(ctx)
 		resp, err := handler(ctx, req)
 
 		// crack open response and see if it had an exception
@@ -288,44 +353,30 @@ func AppErrorUnaryInterceptor(log grpclogging.ServiceLogger) func(ctx context.Co
 		if !ok {
 			// We expect all response to have an optional "exception" field.
 			log(ctx).WithError(err).Errorf("message response has wrong type")
-			return nil, internalError(ctx)
+			return nil, internalError(ctx, errors.Join(fmt.Errorf("message response has wrong type %T", resp), err))
 		}
 
 		if r.GetException() == nil && err == nil {
-			// happy path, no errors
+			// happy path, no errors.  Warnings do not convert the response
+			// into an error.
+			handleWarnings(ctx, log, info.FullMethod, resp, warnings)
 			return resp, nil
 		}
 
 		if r.GetException() != nil && err != nil {
 			// by convention we never return both a response and an error
 			log(ctx).WithError(err).Errorf("error and non-nil response object violates convention")
-			return nil, internalError(ctx)
+			return nil, internalError(ctx, err)
 		}
 
 		if r.GetException() != nil && err == nil {
 			// coerce luther error into grpc/luther error
-			var code codes.Code
 			except := r.GetException()
-			switch except.GetType() {
-			case common.Exception_INVALID_TYPE:
+			code, ok := exceptionCode(except.GetType())
+			if !ok && except.GetType() == common.Exception_INVALID_TYPE {
 				log(ctx).Errorf("exception missing type")
-				code = codes.Internal // 500
-			case common.Exception_BUSINESS:
-				// code = codes.FailedPrecondition // Docs say this maps  to 400, but it maps to 412 unforuntately.
-				// Unfortunately we use InvalidArgument, which is not really
-				// correct, but does properly map to status 400.
-				code = codes.InvalidArgument
-			case common.Exception_SERVICE_NOT_AVAILABLE:
-				code = codes.Unavailable // 503
-			case common.Exception_INFRASTRUCTURE:
-				code = codes.DataLoss // 500
-			case common.Exception_UNEXPECTED:
-				code = codes.Unknown // 500
-			case common.Exception_SECURITY_VIOLATION:
-				code = codes.PermissionDenied // 403
-			default:
+			} else if !ok {
 				log(ctx).Errorf("unknown exception type")
-				code = codes.Internal // 500
 			}
 			var details proto.Message
 			// HTTP 400 can contain payload
@@ -337,7 +388,7 @@ func AppErrorUnaryInterceptor(log grpclogging.ServiceLogger) func(ctx context.Co
 			msg, ok := details.(protoiface.MessageV1)
 			if !ok {
 				log(ctx).Errorf("wrong message type: %T", details)
-				return nil, internalError(ctx)
+				return nil, internalError(ctx, fmt.Errorf("wrong message type: %T", details))
 			}
 			stat, err := status.New(code, except.GetDescription()).WithDetails(msg)
 			if err == nil {
@@ -347,17 +398,40 @@ func AppErrorUnaryInterceptor(log grpclogging.ServiceLogger) func(ctx context.Co
 			}
 			// an error in the error handling :(
 			log(ctx).WithError(err).Errorf("cannot create error status")
-			return nil, internalError(ctx)
+			return nil, internalError(ctx, err)
 		}
 
 		if r.GetException() == nil && err != nil {
 			// coerce grpc error into grpc/luther error
+			logExceptionChain(ctx, err)
 			return nil, grpcToLutherError(ctx, log, err)
 		}
 
 		// this should never happen given the logic above.
 		log(ctx).WithError(err).Errorf("impossible case")
-		return nil, internalError(ctx)
+		return nil, internalError(ctx, err)
+	}
+}
+
+// exceptionCode returns the gRPC status code of an exception type.  Invalid
+// and unknown types map to codes.Internal and return false.
+func exceptionCode(t common.Exception_Type) (codes.Code, bool) {
+	switch t {
+	case common.Exception_BUSINESS:
+		// codes.FailedPrecondition is documented to map to 400, but it maps
+		// to 412 unfortunately.  Unfortunately we use InvalidArgument,
+		// which is not really correct, but does properly map to status 400.
+		return codes.InvalidArgument, true
+	case common.Exception_SERVICE_NOT_AVAILABLE:
+		return codes.Unavailable, true // 503
+	case common.Exception_INFRASTRUCTURE:
+		return codes.DataLoss, true // 500
+	case common.Exception_UNEXPECTED:
+		return codes.Unknown, true // 500
+	case common.Exception_SECURITY_VIOLATION:
+		return codes.PermissionDenied, true // 403
+	default:
+		return codes.Internal, false // 500
 	}
 }
 
@@ -375,13 +449,18 @@ func ErrIntercept(log grpclogging.ServiceLogger, handlers ...HTTPErrorHandler) H
 		}
 		w.Header().Set("Content-Type", marshaler.ContentType(nil))
 		err = grpcToLutherError(ctx, log, err)
+		err, truncated := truncateError(rpcMethod(ctx), err)
+		if truncated {
+			w.Header().Set(TruncatedHeader, "true")
+		}
 		stat, ok := status.FromError(err)
 		if !ok || len(stat.Details()) != 1 {
 			log(ctx).WithError(err).Errorf("unexpected error type, len(details)=%d", len(stat.Details()))
-			w.WriteHeader(runtime.HTTPStatusFromCode(http.StatusInternalServerError))
+			w.WriteHeader(http.StatusInternalServerError)
 			pbErr := &common.ExceptionResponse{
 				Exception: UnexpectedException(ctx, "Internal server error"),
 			}
+			reportError(ctx, err, pbErr.GetException())
 			b, err := marshaler.Marshal(pbErr)
 			if err != nil {
 				log(ctx).WithError(err).Errorf("marshal unexpected error")
@@ -391,11 +470,17 @@ func ErrIntercept(log grpclogging.ServiceLogger, handlers ...HTTPErrorHandler) H
 			if err != nil {
 				log(ctx).WithError(err).Errorf("write")
 			}
-			incExceptionMetric(pbErr.GetException())
+			incExceptionMetric(ctx, pbErr.GetException(), http.StatusInternalServerError)
 			return
 		}
 		detail := stat.Details()[0]
-		w.WriteHeader(runtime.HTTPStatusFromCode(stat.Code()))
+		httpCode := runtime.HTTPStatusFromCode(stat.Code())
+		if t, ok := detailExceptionType(detail); ok {
+			if pinned, ok := methodHTTPStatus(rpcMethod(ctx), t); ok {
+				httpCode = pinned
+			}
+		}
+		w.WriteHeader(httpCode)
 		pbDetail, ok := detail.(*common.Exception)
 		if !ok {
 			// Propagate payload for non-exception detail
@@ -418,7 +503,7 @@ func ErrIntercept(log grpclogging.ServiceLogger, handlers ...HTTPErrorHandler) H
 			log(ctx).WithError(err).Errorf("marshal detail error")
 			b = []byte(cannedExceptionJSON(ctx))
 		}
-		incExceptionMetric(pbErr.GetException())
+		incExceptionMetric(ctx, pbErr.GetException(), httpCode)
 		_, err = w.Write(b)
 		if err != nil {
 			log(ctx).WithError(err).Errorf("write")
@@ -439,7 +524,7 @@ func cannedExceptionJSON(ctx context.Context) string {
         "description": "Internal server error"
     }
 }
- `, grpclogging.ReqID(ctx), time.Now().Format(TimestampFormat))
+ `, grpclogging.ReqID(ctx), timestamp(ctx))
 }
 
 // UnexpectedException creates a protobuf unexpected exception.
@@ -447,7 +532,7 @@ func UnexpectedException(ctx context.Context, msg string) *common.Exception {
 	return &common.Exception{
 		Id:          grpclogging.ReqID(ctx),
 		Type:        common.Exception_UNEXPECTED,
-		Timestamp:   time.Now().Format(TimestampFormat),
+		Timestamp:   timestamp(ctx),
 		Description: msg,
 	}
 }
@@ -457,7 +542,7 @@ func BusinessException(ctx context.Context, msg string) *common.Exception {
 	return &common.Exception{
 		Id:          grpclogging.ReqID(ctx),
 		Type:        common.Exception_BUSINESS,
-		Timestamp:   time.Now().Format(TimestampFormat),
+		Timestamp:   timestamp(ctx),
 		Description: msg,
 	}
 }
@@ -467,7 +552,7 @@ func SecurityException(ctx context.Context, msg string) *common.Exception {
 	return &common.Exception{
 		Id:          grpclogging.ReqID(ctx),
 		Type:        common.Exception_SECURITY_VIOLATION,
-		Timestamp:   time.Now().Format(TimestampFormat),
+		Timestamp:   timestamp(ctx),
 		Description: msg,
 	}
 }
@@ -477,7 +562,7 @@ func InfrastructureException(ctx context.Context, msg string) *common.Exception
 	return &common.Exception{
 		Id:          grpclogging.ReqID(ctx),
 		Type:        common.Exception_INFRASTRUCTURE,
-		Timestamp:   time.Now().Format(TimestampFormat),
+		Timestamp:   timestamp(ctx),
 		Description: msg,
 	}
 }
@@ -487,7 +572,7 @@ func ServiceException(ctx context.Context, msg string) *common.Exception {
 	return &common.Exception{
 		Id:          grpclogging.ReqID(ctx),
 		Type:        common.Exception_SERVICE_NOT_AVAILABLE,
-		Timestamp:   time.Now().Format(TimestampFormat),
+		Timestamp:   timestamp(ctx),
 		Description: msg,
 	}
 }
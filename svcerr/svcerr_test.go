@@ -85,3 +85,27 @@ func TestRawError(t *testing.T) {
 	})
 
 }
+
+func TestExceptionError(t *testing.T) {
+	ctx := context.Background()
+	except := BusinessException(ctx, "insufficient funds")
+	except.Id = "downstream-id"
+	err := ExceptionError(except)
+	var eb *BusinessError
+	require.ErrorAs(t, err, &eb)
+	require.Equal(t, "insufficient funds", err.Error())
+	require.Equal(t, "downstream-id", DownstreamException(fmt.Errorf("call: %w", err)).GetId())
+
+	// the exception is copied.
+	except.Description = "changed"
+	require.Equal(t, "insufficient funds", err.Error())
+
+	var es *SecurityError
+	require.ErrorAs(t, ExceptionError(SecurityException(ctx, "denied")), &es)
+	var ev *ServiceError
+	require.ErrorAs(t, ExceptionError(ServiceException(ctx, "unavailable")), &ev)
+	var ei *InfrastructureError
+	require.ErrorAs(t, ExceptionError(InfrastructureException(ctx, "failed")), &ei)
+	var eu *UnexpectedError
+	require.ErrorAs(t, ExceptionError(nil), &eu)
+}
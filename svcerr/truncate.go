@@ -0,0 +1,200 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package svcerr
+
+import (
+	"context"
+	"sync"
+	"unicode/utf8"
+
+	common "buf.build/gen/go/luthersystems/protos/protocolbuffers/go/common/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/runtime/protoiface"
+)
+
+const (
+	// TruncatedHeader is the HTTP response header set by ErrIntercept when
+	// the exception was truncated.
+	TruncatedHeader = "X-Exception-Truncated"
+	// TruncatedTrailer is the gRPC trailer set by AppErrorUnaryInterceptor
+	// when the exception was truncated.
+	TruncatedTrailer = "exception-truncated"
+
+	// RecommendedMaxDescriptionSize is a suggested maximum size of
+	// exception descriptions and status messages, in bytes.
+	RecommendedMaxDescriptionSize = 2048
+	// RecommendedMaxDetailSize is a suggested maximum encoded size of an
+	// error detail, in bytes.  Error details are sent in the
+	// grpc-status-details-bin trailer, which is subject to the header size
+	// limits of the transport and of proxies in front of it.
+	RecommendedMaxDetailSize = 8192
+
+	// truncatedSuffix marks truncated descriptions.
+	truncatedSuffix = "... [truncated]"
+	// exceptionField is the response field holding exceptions, by
+	// convention.
+	exceptionField = "exception"
+
+	// Truncation reasons.
+	truncatedDescription = "description"
+	truncatedDetail      = "detail"
+)
+
+// DetailLimits bounds the size of errors returned to callers.  A zero limit
+// disables the corresponding truncation.
+type DetailLimits struct {
+	// MaxDescriptionSize is the maximum size of exception descriptions and
+	// status messages, in bytes.  Longer descriptions are truncated and
+	// suffixed with "... [truncated]".
+	MaxDescriptionSize int
+	// MaxDetailSize is the maximum encoded size of an error detail, in
+	// bytes.  Response payloads exceeding it are replaced with their
+	// exception, and exceptions exceeding it have their description
+	// shortened further.
+	MaxDetailSize int
+}
+
+var (
+	detailLimitsMut sync.RWMutex
+	detailLimits    DetailLimits
+)
+
+// SetDetailLimits sets the size limits applied to errors by
+// AppErrorUnaryInterceptor and ErrIntercept.  Errors are not truncated
+// unless limits are set, since truncation changes the errors seen by
+// callers.  Truncated errors are flagged
+// with TruncatedTrailer and TruncatedHeader, and counted by the
+// exception_truncated_total metric.
+func SetDetailLimits(limits DetailLimits) {
+	detailLimitsMut.Lock()
+	defer detailLimitsMut.Unlock()
+	detailLimits = limits
+}
+
+// getDetailLimits returns the current size limits.
+func getDetailLimits() DetailLimits {
+	detailLimitsMut.RLock()
+	defer detailLimitsMut.RUnlock()
+	return detailLimits
+}
+
+// truncateString truncates s to at most n bytes, suffix included, without
+// splitting UTF-8 characters.
+func truncateString(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	n -= len(truncatedSuffix)
+	if n < 0 {
+		n = 0
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n] + truncatedSuffix
+}
+
+// truncateException returns a copy of except whose encoding fits limits,
+// if it needs truncation.
+func truncateException(except *common.Exception, limits DetailLimits) (*common.Exception, bool) {
+	desc := except.GetDescription()
+	if limits.MaxDescriptionSize > 0 {
+		desc = truncateString(desc, limits.MaxDescriptionSize)
+	}
+	if limits.MaxDetailSize > 0 {
+		if over := proto.Size(except) - len(except.GetDescription()) + len(desc) - limits.MaxDetailSize; over > 0 {
+			desc = truncateString(desc, len(desc)-over)
+		}
+	}
+	if desc == except.GetDescription() {
+		return except, false
+	}
+	truncated := proto.Clone(except).(*common.Exception)
+	truncated.Description = desc
+	return truncated, true
+}
+
+// truncateError bounds the size of the status message and detail of err,
+// returned by method.  It reports whether err was truncated.
+func truncateError(method string, err error) (error, bool) {
+	limits := getDetailLimits()
+	stat, ok := status.FromError(err)
+	if !ok || len(stat.Details()) != 1 {
+		return err, false
+	}
+	detail, ok := stat.Details()[0].(proto.Message)
+	if !ok {
+		return err, false
+	}
+	msg := stat.Message()
+	if limits.MaxDescriptionSize > 0 {
+		msg = truncateString(msg, limits.MaxDescriptionSize)
+	}
+	reason := ""
+	if msg != stat.Message() {
+		reason = truncatedDescription
+	}
+	switch d := detail.(type) {
+	case *common.Exception:
+		if except, ok := truncateException(d, limits); ok {
+			detail, reason = except, truncatedDescription
+		}
+	case raiser:
+		except := d.GetException()
+		if except == nil {
+			break
+		}
+		truncated, ok := truncateException(except, limits)
+		if ok {
+			detail = withException(detail, truncated)
+			reason = truncatedDescription
+		}
+		if limits.MaxDetailSize > 0 && proto.Size(detail) > limits.MaxDetailSize {
+			// Business exceptions carry the response payload, which is
+			// dropped in favor of its exception when too large.
+			detail = truncated
+			reason = truncatedDetail
+		}
+	}
+	if reason == "" {
+		return err, false
+	}
+	msgV1, ok := detail.(protoiface.MessageV1)
+	if !ok {
+		return err, false
+	}
+	next, werr := status.New(stat.Code(), msg).WithDetails(msgV1)
+	if werr != nil {
+		return err, false
+	}
+	incTruncationMetric(method, reason)
+	return next.Err(), true
+}
+
+// withException returns a copy of a response with its exception field
+// replaced.
+func withException(resp proto.Message, except *common.Exception) proto.Message {
+	resp = proto.Clone(resp)
+	m := resp.ProtoReflect()
+	fd := m.Descriptor().Fields().ByName(exceptionField)
+	if fd == nil || fd.Message() == nil || fd.Message().FullName() != except.ProtoReflect().Descriptor().FullName() {
+		return resp
+	}
+	m.Set(fd, protoreflect.ValueOfMessage(except.ProtoReflect()))
+	return resp
+}
+
+// truncateUnaryError truncates an error returned by a gRPC method, flagging
+// it in the response trailer.
+func truncateUnaryError(ctx context.Context, method string, err error) error {
+	err, truncated := truncateError(method, err)
+	if truncated {
+		// The trailer cannot be set outside of a gRPC server.
+		_ = grpc.SetTrailer(ctx, metadata.Pairs(TruncatedTrailer, "true"))
+	}
+	return err
+}
@@ -0,0 +1,92 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package svcerr
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	common "buf.build/gen/go/luthersystems/protos/protocolbuffers/go/common/v1"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestTruncateString(t *testing.T) {
+	require.Equal(t, "short", truncateString("short", 20))
+	s := truncateString(strings.Repeat("é", 20), 20)
+	require.LessOrEqual(t, len(s), 20)
+	require.True(t, strings.HasSuffix(s, truncatedSuffix))
+	require.Equal(t, "éé"+truncatedSuffix, s)
+}
+
+func TestTruncateError(t *testing.T) {
+	require.Equal(t, DetailLimits{}, getDetailLimits())
+	SetDetailLimits(DetailLimits{MaxDescriptionSize: 100, MaxDetailSize: 200})
+	defer SetDetailLimits(DetailLimits{})
+
+	entry := logrus.NewEntry(logrus.New())
+	log := func(ctx context.Context) *logrus.Entry {
+		return entry
+	}
+	intercept := AppErrorUnaryInterceptor(log)
+	call := func(resp *common.ExceptionResponse, err error) error {
+		_, err = intercept(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: "/svcerr.test.FooService/GetFoo"},
+			func(ctx context.Context, req interface{}) (interface{}, error) {
+				return resp, err
+			})
+		return err
+	}
+
+	long := strings.Repeat("x", 1000)
+	err := call(&common.ExceptionResponse{}, NewBusinessError(long))
+	stat, ok := status.FromError(err)
+	require.True(t, ok)
+	require.LessOrEqual(t, len(stat.Message()), 100)
+	require.Len(t, stat.Details(), 1)
+	except := stat.Details()[0].(*common.Exception)
+	require.True(t, strings.HasSuffix(except.GetDescription(), truncatedSuffix))
+	require.LessOrEqual(t, proto.Size(except), 200)
+
+	// Business exceptions carry the response, whose exception is truncated.
+	err = call(&common.ExceptionResponse{Exception: BusinessException(context.Background(), long)}, nil)
+	stat, ok = status.FromError(err)
+	require.True(t, ok)
+	resp, ok := stat.Details()[0].(*common.ExceptionResponse)
+	require.True(t, ok)
+	require.True(t, strings.HasSuffix(resp.GetException().GetDescription(), truncatedSuffix))
+
+	// Oversized payloads are replaced with their exception.
+	SetDetailLimits(DetailLimits{MaxDescriptionSize: 1000, MaxDetailSize: 200})
+	err = call(&common.ExceptionResponse{Exception: BusinessException(context.Background(), long)}, nil)
+	except, ok = status.Convert(err).Details()[0].(*common.Exception)
+	require.True(t, ok)
+	require.LessOrEqual(t, proto.Size(except), 200)
+	SetDetailLimits(DetailLimits{MaxDescriptionSize: 100, MaxDetailSize: 200})
+
+	// Small errors are untouched.
+	err = call(&common.ExceptionResponse{}, NewBusinessError("bad"))
+	_, truncated := truncateError("/svcerr.test.FooService/GetFoo", err)
+	require.False(t, truncated)
+	require.Equal(t, "bad", status.Convert(err).Message())
+
+	// The gateway flags truncated errors.
+	mux := runtime.NewServeMux()
+	r := httptest.NewRequest(http.MethodGet, "/v1/foo", nil)
+	w := httptest.NewRecorder()
+	ErrIntercept(log)(r.Context(), mux, &runtime.JSONPb{}, w, r, NewSecurityError(long))
+	require.Equal(t, http.StatusForbidden, w.Code)
+	require.Equal(t, "true", w.Header().Get(TruncatedHeader))
+	require.Contains(t, w.Body.String(), truncatedSuffix)
+
+	// Disabled limits never truncate.
+	SetDetailLimits(DetailLimits{})
+	err = call(&common.ExceptionResponse{}, NewBusinessError(long))
+	require.Equal(t, long, status.Convert(err).Message())
+}
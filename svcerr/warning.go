@@ -0,0 +1,119 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package svcerr
+
+import (
+	"context"
+	"sync"
+
+	common "buf.build/gen/go/luthersystems/protos/protocolbuffers/go/common/v1"
+	"github.com/luthersystems/svc/grpclogging"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// WarningsField is the name of the response field holding warnings.  By
+// convention, responses which may succeed with warnings declare a field
+// `repeated common.v1.Exception warnings`.
+const WarningsField = "warnings"
+
+type warningsCtxKey struct{}
+
+// warnings collects the warnings of a request.
+type warnings struct {
+	mut        sync.Mutex
+	exceptions []*common.Exception
+}
+
+// withWarnings returns a context which collects warnings added with
+// AddWarning.
+func withWarnings(ctx context.Context) (context.Context, *warnings) {
+	w := &warnings{}
+	return context.WithValue(ctx, warningsCtxKey{}, w), w
+}
+
+func (w *warnings) get() []*common.Exception {
+	w.mut.Lock()
+	defer w.mut.Unlock()
+	return append([]*common.Exception(nil), w.exceptions...)
+}
+
+// AddWarning attaches a non-fatal exception to the response of a successful
+// request, e.g. a BusinessException describing a partially applied update.
+// Warnings are added to the response warnings field by
+// AppErrorUnaryInterceptor, and are discarded if the request fails.  The
+// context must have been initialized by AppErrorUnaryInterceptor, otherwise
+// AddWarning does nothing.
+func AddWarning(ctx context.Context, e *common.Exception) {
+	w, ok := ctx.Value(warningsCtxKey{}).(*warnings)
+	if !ok || e == nil {
+		return
+	}
+	w.mut.Lock()
+	defer w.mut.Unlock()
+	w.exceptions = append(w.exceptions, e)
+}
+
+// warningsField returns the warnings field of msg, if it has one.
+func warningsField(msg protoreflect.Message) protoreflect.FieldDescriptor {
+	fd := msg.Descriptor().Fields().ByName(WarningsField)
+	if fd == nil || !fd.IsList() || fd.Message() == nil {
+		return nil
+	}
+	if fd.Message().FullName() != (&common.Exception{}).ProtoReflect().Descriptor().FullName() {
+		return nil
+	}
+	return fd
+}
+
+// Warnings returns the warnings of a response.
+func Warnings(resp proto.Message) []*common.Exception {
+	if resp == nil {
+		return nil
+	}
+	msg := resp.ProtoReflect()
+	fd := warningsField(msg)
+	if fd == nil {
+		return nil
+	}
+	list := msg.Get(fd).List()
+	exceptions := make([]*common.Exception, 0, list.Len())
+	for i := 0; i < list.Len(); i++ {
+		e := &common.Exception{}
+		proto.Merge(e, list.Get(i).Message().Interface())
+		exceptions = append(exceptions, e)
+	}
+	return exceptions
+}
+
+// attachWarnings appends warnings to the response warnings field, returning
+// false if the response has no warnings field.
+func attachWarnings(resp proto.Message, exceptions []*common.Exception) bool {
+	msg := resp.ProtoReflect()
+	fd := warningsField(msg)
+	if fd == nil {
+		return false
+	}
+	list := msg.Mutable(fd).List()
+	for _, e := range exceptions {
+		elem := list.NewElement()
+		proto.Merge(elem.Message().Interface(), e)
+		list.Append(elem)
+	}
+	return true
+}
+
+// handleWarnings adds the collected warnings to a successful response and
+// records metrics for all of its warnings.
+func handleWarnings(ctx context.Context, log grpclogging.ServiceLogger, method string, resp interface{}, w *warnings) {
+	msg, ok := resp.(proto.Message)
+	if !ok {
+		return
+	}
+	if collected := w.get(); len(collected) > 0 && !attachWarnings(msg, collected) {
+		log(ctx).Warnf("response has no %s field, dropped %d warnings", WarningsField, len(collected))
+	}
+	for _, e := range Warnings(msg) {
+		incWarningMetric(method, e)
+	}
+}
@@ -0,0 +1,89 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package svcerr
+
+import (
+	"context"
+	"testing"
+
+	common "buf.build/gen/go/luthersystems/protos/protocolbuffers/go/common/v1"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// warningResponse is a response following the warnings convention.
+type warningResponse struct {
+	*dynamicpb.Message
+}
+
+func (r warningResponse) GetException() *common.Exception {
+	return nil
+}
+
+func newWarningResponse(t *testing.T) warningResponse {
+	exc := (&common.Exception{}).ProtoReflect().Descriptor()
+	fdp := &descriptorpb.FileDescriptorProto{
+		Name:       proto.String("svcerr/warning_test.proto"),
+		Package:    proto.String("svcerr.test"),
+		Syntax:     proto.String("proto3"),
+		Dependency: []string{exc.ParentFile().Path()},
+		MessageType: []*descriptorpb.DescriptorProto{{
+			Name: proto.String("Response"),
+			Field: []*descriptorpb.FieldDescriptorProto{{
+				Name:     proto.String(WarningsField),
+				JsonName: proto.String(WarningsField),
+				Number:   proto.Int32(1),
+				Label:    descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum(),
+				Type:     descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum(),
+				TypeName: proto.String("." + string(exc.FullName())),
+			}},
+		}},
+	}
+	fd, err := protodesc.NewFile(fdp, protoregistry.GlobalFiles)
+	require.NoError(t, err)
+	return warningResponse{dynamicpb.NewMessage(fd.Messages().ByName(protoreflect.Name("Response")))}
+}
+
+func TestWarnings(t *testing.T) {
+	entry := logrus.NewEntry(logrus.New())
+	log := func(ctx context.Context) *logrus.Entry {
+		return entry
+	}
+	intercept := AppErrorUnaryInterceptor(log)
+	info := &grpc.UnaryServerInfo{FullMethod: "/pkg.Service/Update"}
+	before := testutil.ToFloat64(warningTotal.WithLabelValues("BUSINESS", info.FullMethod))
+
+	resp := newWarningResponse(t)
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		AddWarning(ctx, BusinessException(ctx, "2 of 3 items updated"))
+		AddWarning(ctx, BusinessException(ctx, "item 3 is locked"))
+		return resp, nil
+	}
+	got, err := intercept(context.Background(), nil, info, handler)
+	require.NoError(t, err)
+	warnings := Warnings(got.(proto.Message))
+	require.Len(t, warnings, 2)
+	require.Equal(t, "2 of 3 items updated", warnings[0].GetDescription())
+	require.Equal(t, common.Exception_BUSINESS, warnings[1].GetType())
+	require.Equal(t, before+2, testutil.ToFloat64(warningTotal.WithLabelValues("BUSINESS", info.FullMethod)))
+
+	// Warnings without a warnings field are dropped, without failing.
+	plain := func(ctx context.Context, req interface{}) (interface{}, error) {
+		AddWarning(ctx, BusinessException(ctx, "dropped"))
+		return &common.ExceptionResponse{}, nil
+	}
+	got, err = intercept(context.Background(), nil, info, plain)
+	require.NoError(t, err)
+	require.Empty(t, Warnings(got.(proto.Message)))
+
+	// AddWarning outside the interceptor is a no-op.
+	AddWarning(context.Background(), BusinessException(context.Background(), "ignored"))
+}
@@ -0,0 +1,138 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+/*
+Package txctx tracks details of the phylum transactions performed while
+serving a request.  The details are stored in the request context by
+UnaryServerInterceptor and may be set by the code performing phylum calls and
+read back by other interceptors and middleware.
+*/
+package txctx
+
+import (
+	"context"
+	"strconv"
+	"sync"
+
+	"github.com/luthersystems/svc/grpclogging"
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+)
+
+// Span attributes holding transaction details.
+const (
+	AttrTransactionID  = attribute.Key("app.tx.id")
+	AttrCommitBlockNum = attribute.Key("app.tx.commit_block_num")
+	AttrMaxSimBlockNum = attribute.Key("app.tx.max_sim_block_num")
+)
+
+// Details describes the most recent phylum transaction performed while serving
+// a request.
+type Details struct {
+	// TransactionID is the ID of the committed transaction.
+	TransactionID string
+	// CommitBlockNum is the number of the block containing the committed
+	// transaction.
+	CommitBlockNum uint64
+	// MaxSimBlockNum is the highest block number observed while simulating
+	// the transaction.
+	MaxSimBlockNum uint64
+}
+
+// txCtxKey is a key to store transaction details within context.
+type txCtxKey struct{}
+
+type holder struct {
+	mut     sync.Mutex
+	details Details
+}
+
+// NewContext returns a new context initialized to hold transaction details.
+func NewContext(ctx context.Context) context.Context {
+	return context.WithValue(ctx, txCtxKey{}, &holder{})
+}
+
+func ctxHolder(ctx context.Context) *holder {
+	h, _ := ctx.Value(txCtxKey{}).(*holder)
+	return h
+}
+
+// Get returns the transaction details stored in ctx.  The zero value is
+// returned if ctx was not initialized by NewContext or no details were set.
+func Get(ctx context.Context) Details {
+	h := ctxHolder(ctx)
+	if h == nil {
+		return Details{}
+	}
+	h.mut.Lock()
+	defer h.mut.Unlock()
+	return h.details
+}
+
+// update modifies the details stored in ctx, if ctx was initialized by
+// NewContext.
+func update(ctx context.Context, fn func(*Details)) {
+	h := ctxHolder(ctx)
+	if h == nil {
+		return
+	}
+	h.mut.Lock()
+	defer h.mut.Unlock()
+	fn(&h.details)
+}
+
+// SetTransactionID records the ID of a committed transaction.
+func SetTransactionID(ctx context.Context, txID string) {
+	update(ctx, func(d *Details) { d.TransactionID = txID })
+}
+
+// SetCommitBlockNum records the block number of a committed transaction.
+func SetCommitBlockNum(ctx context.Context, blockNum uint64) {
+	update(ctx, func(d *Details) { d.CommitBlockNum = blockNum })
+}
+
+// SetMaxSimBlockNum records the highest block number observed during
+// simulation.
+func SetMaxSimBlockNum(ctx context.Context, blockNum uint64) {
+	update(ctx, func(d *Details) { d.MaxSimBlockNum = blockNum })
+}
+
+// Annotate attaches the transaction details stored in ctx, if any, to the
+// span of ctx as attributes and to the request log fields, so the ledger
+// transaction of a trace or log line can be found.  Unset details are
+// omitted.
+func Annotate(ctx context.Context) {
+	d := Get(ctx)
+	var attrs []attribute.KeyValue
+	fields := logrus.Fields{}
+	if d.TransactionID != "" {
+		attrs = append(attrs, AttrTransactionID.String(d.TransactionID))
+		fields["tx_id"] = d.TransactionID
+	}
+	if d.CommitBlockNum != 0 {
+		attrs = append(attrs, AttrCommitBlockNum.Int64(int64(d.CommitBlockNum)))
+		fields["commit_block_num"] = strconv.FormatUint(d.CommitBlockNum, 10)
+	}
+	if d.MaxSimBlockNum != 0 {
+		attrs = append(attrs, AttrMaxSimBlockNum.Int64(int64(d.MaxSimBlockNum)))
+		fields["max_sim_block_num"] = strconv.FormatUint(d.MaxSimBlockNum, 10)
+	}
+	if len(attrs) == 0 {
+		return
+	}
+	trace.SpanFromContext(ctx).SetAttributes(attrs...)
+	grpclogging.AddLogrusFields(ctx, fields)
+}
+
+// UnaryServerInterceptor returns an interceptor that initializes the request
+// context to hold transaction details, and annotates the request span and
+// log fields with the details once the handler returns.
+func UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx = NewContext(ctx)
+		resp, err := handler(ctx, req)
+		Annotate(ctx)
+		return resp, err
+	}
+}
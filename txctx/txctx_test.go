@@ -0,0 +1,52 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package txctx
+
+import (
+	"context"
+	"testing"
+
+	"github.com/luthersystems/svc/grpclogging"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"google.golang.org/grpc"
+)
+
+func TestDetails(t *testing.T) {
+	ctx := context.Background()
+	SetTransactionID(ctx, "ignored")
+	require.Equal(t, Details{}, Get(ctx))
+
+	ctx = NewContext(ctx)
+	SetTransactionID(ctx, "tx1")
+	SetCommitBlockNum(ctx, 10)
+	SetMaxSimBlockNum(ctx, 9)
+	require.Equal(t, Details{TransactionID: "tx1", CommitBlockNum: 10, MaxSimBlockNum: 9}, Get(ctx))
+}
+
+func TestAnnotate(t *testing.T) {
+	exp := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exp))
+	ctx, span := tp.Tracer("test").Start(grpclogging.NewContext(context.Background()), "request")
+
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		SetTransactionID(ctx, "tx1")
+		SetCommitBlockNum(ctx, 10)
+		return "ok", nil
+	}
+	_, err := UnaryServerInterceptor()(ctx, nil, &grpc.UnaryServerInfo{}, handler)
+	require.NoError(t, err)
+	span.End()
+
+	fields := grpclogging.GetLogrusFields(ctx)
+	require.Equal(t, "tx1", fields["tx_id"])
+	require.Equal(t, "10", fields["commit_block_num"])
+	require.NotContains(t, fields, "max_sim_block_num")
+	attrs := exp.GetSpans().Snapshots()[0].Attributes()
+	require.ElementsMatch(t, []attribute.KeyValue{
+		AttrTransactionID.String("tx1"),
+		AttrCommitBlockNum.Int64(10),
+	}, attrs)
+}
@@ -0,0 +1,208 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+/*
+Package yaml2json converts configuration documents, such as phylum configs,
+between YAML and JSON, and formats YAML canonically so that tooling writing
+back edited configs produces deterministic output and clean diffs.
+
+Canonical YAML has mapping keys sorted, block style collections indented by
+two spaces, and comments preserved alongside their keys.
+*/
+package yaml2json
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// indent is the indentation of canonical YAML.
+const indent = 2
+
+// YAML2JSON converts a YAML document to JSON.  Mapping keys must be
+// strings, and are sorted in the output.
+func YAML2JSON(b []byte) ([]byte, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(b, &doc); err != nil {
+		return nil, fmt.Errorf("yaml: %w", err)
+	}
+	if len(doc.Content) == 0 {
+		return []byte("null"), nil
+	}
+	v, err := nodeValue(doc.Content[0])
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(v)
+}
+
+// nodeValue returns the JSON value of a YAML node.
+func nodeValue(n *yaml.Node) (interface{}, error) {
+	switch n.Kind {
+	case yaml.AliasNode:
+		return nodeValue(n.Alias)
+	case yaml.MappingNode:
+		m := make(map[string]interface{}, len(n.Content)/2)
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			k, v := n.Content[i], n.Content[i+1]
+			if k.Tag == "!!merge" {
+				return nil, fmt.Errorf("line %d: merge keys are not supported", k.Line)
+			}
+			if k.Kind != yaml.ScalarNode {
+				return nil, fmt.Errorf("line %d: mapping key is not a string", k.Line)
+			}
+			val, err := nodeValue(v)
+			if err != nil {
+				return nil, err
+			}
+			m[k.Value] = val
+		}
+		return m, nil
+	case yaml.SequenceNode:
+		s := make([]interface{}, 0, len(n.Content))
+		for _, c := range n.Content {
+			val, err := nodeValue(c)
+			if err != nil {
+				return nil, err
+			}
+			s = append(s, val)
+		}
+		return s, nil
+	case yaml.ScalarNode:
+		switch n.ShortTag() {
+		case "!!null":
+			return nil, nil
+		case "!!bool", "!!float":
+			var v interface{}
+			if err := n.Decode(&v); err != nil {
+				return nil, err
+			}
+			if f, ok := v.(float64); ok && !isJSONNumber(f) {
+				return nil, fmt.Errorf("line %d: %s is not a JSON number", n.Line, n.Value)
+			}
+			return v, nil
+		case "!!int":
+			var v interface{}
+			if err := n.Decode(&v); err != nil {
+				return nil, err
+			}
+			// Keep the digits of large integers.
+			return json.Number(fmt.Sprint(v)), nil
+		default:
+			return n.Value, nil
+		}
+	}
+	return nil, fmt.Errorf("line %d: unsupported yaml node", n.Line)
+}
+
+// isJSONNumber returns true unless f is infinite or NaN.
+func isJSONNumber(f float64) bool {
+	return f == f && f-f == 0
+}
+
+// JSON2YAML converts a JSON document to canonical YAML.  Numbers keep their
+// JSON representation.
+func JSON2YAML(b []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, fmt.Errorf("json: %w", err)
+	}
+	if _, err := dec.Token(); !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("json: unexpected data after document")
+	}
+	return encode(valueNode(v))
+}
+
+// valueNode returns the YAML node of a JSON value.
+func valueNode(v interface{}) *yaml.Node {
+	switch v := v.(type) {
+	case nil:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null", Value: "null"}
+	case bool:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!bool", Value: strconv.FormatBool(v)}
+	case json.Number:
+		tag := "!!int"
+		if strings.ContainsAny(v.String(), ".eE") {
+			tag = "!!float"
+		}
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: tag, Value: v.String()}
+	case string:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: v}
+	case []interface{}:
+		n := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+		for _, e := range v {
+			n.Content = append(n.Content, valueNode(e))
+		}
+		return n
+	case map[string]interface{}:
+		n := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		for k, e := range v {
+			n.Content = append(n.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: k}, valueNode(e))
+		}
+		return n
+	}
+	panic(fmt.Sprintf("unexpected json value %T", v))
+}
+
+// Canonicalize formats a YAML document canonically: mapping keys are
+// sorted, collections use block style with two space indentation, and
+// comments are kept.  Canonicalizing canonical YAML returns it unchanged.
+func Canonicalize(b []byte) ([]byte, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(b, &doc); err != nil {
+		return nil, fmt.Errorf("yaml: %w", err)
+	}
+	if len(doc.Content) == 0 {
+		return nil, nil
+	}
+	return encode(&doc)
+}
+
+// encode renders a node as canonical YAML.
+func encode(n *yaml.Node) ([]byte, error) {
+	canonicalize(n)
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(indent)
+	if err := enc.Encode(n); err != nil {
+		return nil, fmt.Errorf("yaml: %w", err)
+	}
+	if err := enc.Close(); err != nil {
+		return nil, fmt.Errorf("yaml: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// canonicalize sorts the mapping keys of n and its descendants and resets
+// collection styles to block style.
+func canonicalize(n *yaml.Node) {
+	if n.Kind == yaml.MappingNode || n.Kind == yaml.SequenceNode {
+		n.Style &^= yaml.FlowStyle
+	}
+	for _, c := range n.Content {
+		canonicalize(c)
+	}
+	if n.Kind != yaml.MappingNode {
+		return
+	}
+	pairs := make([][2]*yaml.Node, 0, len(n.Content)/2)
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		pairs = append(pairs, [2]*yaml.Node{n.Content[i], n.Content[i+1]})
+	}
+	sort.SliceStable(pairs, func(i, j int) bool {
+		return pairs[i][0].Value < pairs[j][0].Value
+	})
+	n.Content = n.Content[:0]
+	for _, p := range pairs {
+		n.Content = append(n.Content, p[0], p[1])
+	}
+}
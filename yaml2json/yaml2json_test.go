@@ -0,0 +1,106 @@
+// Copyright © 2024 Luther Systems, Ltd. All right reserved.
+
+package yaml2json
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestYAML2JSON(t *testing.T) {
+	b, err := YAML2JSON([]byte(`
+name: config
+count: 12345678901234567
+ratio: 0.5
+hex: 0x1f
+enabled: true
+missing: ~
+tags: [a, "1"]
+defaults: &defaults
+  retries: 3
+service: *defaults
+`))
+	require.NoError(t, err)
+	require.JSONEq(t, `{
+		"name": "config",
+		"count": 12345678901234567,
+		"ratio": 0.5,
+		"hex": 31,
+		"enabled": true,
+		"missing": null,
+		"tags": ["a", "1"],
+		"defaults": {"retries": 3},
+		"service": {"retries": 3}
+	}`, string(b))
+	require.Contains(t, string(b), "12345678901234567")
+
+	b, err = YAML2JSON(nil)
+	require.NoError(t, err)
+	require.Equal(t, "null", string(b))
+
+	_, err = YAML2JSON([]byte("[1]: a\n"))
+	require.ErrorContains(t, err, "not a string")
+	_, err = YAML2JSON([]byte("a: .inf\n"))
+	require.ErrorContains(t, err, "not a JSON number")
+	_, err = YAML2JSON([]byte("a: [\n"))
+	require.Error(t, err)
+}
+
+func TestJSON2YAML(t *testing.T) {
+	b, err := JSON2YAML([]byte(`{"z": {"b": [1, 2.50, {"y": null, "x": "007"}], "a": true}, "a": "text", "n": 12345678901234567890}`))
+	require.NoError(t, err)
+	require.Equal(t, `a: text
+n: 12345678901234567890
+z:
+  a: true
+  b:
+    - 1
+    - 2.50
+    - x: "007"
+      y: null
+`, string(b))
+
+	// Round trip.
+	j, err := YAML2JSON(b)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"z": {"b": [1, 2.5, {"y": null, "x": "007"}], "a": true}, "a": "text", "n": 12345678901234567890}`, string(j))
+
+	_, err = JSON2YAML([]byte(`{"a": 1} {}`))
+	require.ErrorContains(t, err, "unexpected data")
+	_, err = JSON2YAML([]byte(`{"a":`))
+	require.Error(t, err)
+}
+
+func TestCanonicalize(t *testing.T) {
+	in := []byte(`# phylum config
+
+zeta: {b: 1, a: [x, y]}
+alpha:
+    # nested comment
+    d: "quoted"
+    c: 2 # trailing
+`)
+	b, err := Canonicalize(in)
+	require.NoError(t, err)
+	require.Equal(t, `# phylum config
+
+alpha:
+  c: 2 # trailing
+  # nested comment
+  d: "quoted"
+zeta:
+  a:
+    - x
+    - y
+  b: 1
+`, string(b))
+
+	again, err := Canonicalize(b)
+	require.NoError(t, err)
+	require.Equal(t, string(b), string(again))
+
+	b, err = Canonicalize(nil)
+	require.NoError(t, err)
+	require.Empty(t, b)
+}